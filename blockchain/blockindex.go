@@ -33,6 +33,11 @@ const (
 	// has failed validation, thus the block is also invalid.
 	statusInvalidAncestor
 
+	// statusAssumedValid indicates that the block is assumed to be valid
+	// since the chain was bootstrapped from a utxo snapshot based on it or
+	// one of its descendants, but that it has not been validated yet.
+	statusAssumedValid
+
 	// statusNone indicates that the block has no validation state flags set.
 	//
 	// NOTE: This must be defined last in order to avoid influencing iota.
@@ -52,6 +57,13 @@ func (status blockStatus) KnownValid() bool {
 	return status&statusValid != 0
 }
 
+// AssumedValid returns whether the block is assumed to be valid since the chain
+// was bootstrapped from a utxo snapshot based on it or one of its descendants.
+// This will return false once the block has been fully validated.
+func (status blockStatus) AssumedValid() bool {
+	return status&statusAssumedValid != 0
+}
+
 // KnownInvalid returns whether the block is known to be invalid. This may be
 // because the block itself failed validation or any of its ancestors is
 // invalid. This will return false for invalid blocks that have not been proven
@@ -232,6 +244,10 @@ type blockIndex struct {
 	sync.RWMutex
 	index map[chainhash.Hash]*blockNode
 	dirty map[*blockNode]struct{}
+
+	// chainTips houses the nodes which do not have any children.  In other
+	// words, it contains the last node of every branch of the tree.
+	chainTips map[*blockNode]struct{}
 }
 
 // newBlockIndex returns a new empty instance of a block index.  The index will
@@ -243,6 +259,7 @@ func newBlockIndex(db database.DB, chainParams *chaincfg.Params) *blockIndex {
 		chainParams: chainParams,
 		index:       make(map[chainhash.Hash]*blockNode),
 		dirty:       make(map[*blockNode]struct{}),
+		chainTips:   make(map[*blockNode]struct{}),
 	}
 }
 
@@ -284,6 +301,52 @@ func (bi *blockIndex) AddNode(node *blockNode) {
 // This function is NOT safe for concurrent access.
 func (bi *blockIndex) addNode(node *blockNode) {
 	bi.index[node.hash] = node
+
+	// The new node is the last node of its branch, which means its parent
+	// no longer is.
+	if node.parent != nil {
+		delete(bi.chainTips, node.parent)
+	}
+	bi.chainTips[node] = struct{}{}
+}
+
+// tips returns all of the nodes in the index which do not have any children.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) tips() []*blockNode {
+	bi.RLock()
+	tips := make([]*blockNode, 0, len(bi.chainTips))
+	for node := range bi.chainTips {
+		tips = append(tips, node)
+	}
+	bi.RUnlock()
+	return tips
+}
+
+// descendants returns all of the nodes in the index which have the passed node
+// as an ancestor.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) descendants(node *blockNode) []*blockNode {
+	bi.RLock()
+	defer bi.RUnlock()
+
+	children := make(map[*blockNode][]*blockNode)
+	for _, n := range bi.index {
+		if n.parent != nil && n.height > node.height {
+			children[n.parent] = append(children[n.parent], n)
+		}
+	}
+
+	var descendants []*blockNode
+	queue := children[node]
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		descendants = append(descendants, n)
+		queue = append(queue, children[n]...)
+	}
+	return descendants
 }
 
 // NodeStatus provides concurrent-safe access to the status field of a node.
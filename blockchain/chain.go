@@ -6,8 +6,11 @@
 package blockchain
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
+	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -101,6 +104,8 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	pruneTarget         uint64 // in bytes; 0 means pruning is disabled
+	utxoCache           *utxoCache
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -152,6 +157,20 @@ type BlockChain struct {
 	stateLock     sync.RWMutex
 	stateSnapshot *BestState
 
+	// utxoSetStats houses the statistics and MuHash of the utxo set of the
+	// best chain when they are maintained incrementally, and is nil
+	// otherwise.  Like the state snapshot, it is replaced rather than
+	// modified when blocks are connected and disconnected.  It is
+	// protected by the chain lock.
+	utxoSetStats *utxoSetStats
+
+	// bgChainState is the background chainstate which validates the chain
+	// up to the block the utxo snapshot the best chain was bootstrapped
+	// from is based on.  It is nil when the best chain was not bootstrapped
+	// from a utxo snapshot or once the snapshot has been validated.  It is
+	// protected by the chain lock.
+	bgChainState *backgroundChainState
+
 	// The following caches are used to efficiently keep track of the
 	// current deployment threshold state of each rule change deployment.
 	//
@@ -596,6 +615,23 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 	state := newBestState(node, blockSize, blockWeight, numTxns,
 		curTotalTxns+numTxns, node.CalcPastMedianTime())
 
+	// Update the utxo set statistics to account for the block when they
+	// are maintained incrementally.
+	var utxoSetStats *utxoSetStats
+	if b.utxoSetStats != nil {
+		overwritten, err := b.fetchOverwrittenUtxos(node, block)
+		if err != nil {
+			return err
+		}
+		utxoSetStats = b.utxoSetStats.clone()
+		utxoSetStats.connectBlock(block, node.height, stxos, overwritten)
+	}
+
+	// The changes to the utxo set are only written to the database when
+	// the utxo cache is flushed.  Otherwise, they are applied to the cache
+	// once the database updates below have succeeded.
+	flushUtxos := b.utxoCache.needsFlush(FlushPeriodic, view)
+
 	// Atomically insert info into the database.
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
@@ -611,12 +647,14 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails removing all of the utxos spent and adding the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
-		if err != nil {
-			return err
+		// Update the utxo set using the state of the utxo cache and
+		// view when flushing.  This entails removing all of the utxos
+		// spent and adding the new ones created by the block.
+		if flushUtxos {
+			err = b.utxoCache.flush(dbTx, view, &node.hash)
+			if err != nil {
+				return err
+			}
 		}
 
 		// Update the transaction spend journal by adding a record for
@@ -626,6 +664,13 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 			return err
 		}
 
+		if utxoSetStats != nil {
+			err = dbPutUtxoSetStats(dbTx, &node.hash, utxoSetStats)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
@@ -636,14 +681,31 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 			}
 		}
 
-		return nil
+		// Prune the oldest block data when it exceeds the prune
+		// target.
+		utxoHeight := b.lastFlushHeight()
+		if flushUtxos {
+			utxoHeight = node.height
+		}
+		return b.pruneBlocks(dbTx, node, utxoHeight)
 	})
 	if err != nil {
 		return err
 	}
 
+	// Either reset the utxo cache now that its contents have been committed
+	// to the database or apply the modifications in the view to it.
+	if flushUtxos {
+		b.utxoCache.markFlushed(&node.hash)
+	} else {
+		b.utxoCache.commit(view)
+	}
+	if utxoSetStats != nil {
+		b.utxoSetStats = utxoSetStats
+	}
+
 	// Prune fully spent entries and mark all entries in the view unmodified
-	// now that the modifications have been committed to the database.
+	// now that the modifications have been committed to the utxo set.
 	view.commit()
 
 	// This node is now the end of the best chain.
@@ -709,6 +771,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 	state := newBestState(prevNode, blockSize, blockWeight, numTxns,
 		newTotalTxns, prevNode.CalcPastMedianTime())
 
+	var utxoSetStats *utxoSetStats
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -723,10 +786,12 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails restoring all of the utxos spent and removing the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
+		// Update the utxo set using the state of the utxo cache and
+		// view.  This entails restoring all of the utxos spent and
+		// removing the new ones created by the block.  The cache is
+		// always flushed when disconnecting since restoring spent
+		// outputs relies on the database being up to date.
+		err = b.utxoCache.flush(dbTx, view, &prevNode.hash)
 		if err != nil {
 			return err
 		}
@@ -745,6 +810,17 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 			return err
 		}
 
+		// Update the utxo set statistics to account for the block
+		// when they are maintained incrementally.
+		if b.utxoSetStats != nil {
+			utxoSetStats = b.utxoSetStats.clone()
+			utxoSetStats.disconnectBlock(block, node.height, stxos)
+			err = dbPutUtxoSetStats(dbTx, &prevNode.hash, utxoSetStats)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being disconnected so they
 		// can update themselves accordingly.
@@ -761,6 +837,13 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 		return err
 	}
 
+	// Reset the utxo cache now that its contents have been committed to the
+	// database.
+	b.utxoCache.markFlushed(&prevNode.hash)
+	if utxoSetStats != nil {
+		b.utxoSetStats = utxoSetStats
+	}
+
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
@@ -837,6 +920,24 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	// Ensure the blocks to disconnect have not been pruned since their
+	// data is needed to restore the outputs they spent.
+	if detachNodes.Len() != 0 {
+		lastDetachNode := detachNodes.Back().Value.(*blockNode)
+		if err := b.checkPrunedDisconnect(lastDetachNode); err != nil {
+			return err
+		}
+	}
+
+	// Flush the utxo cache before disconnecting any blocks since restoring
+	// the outputs spent by some older blocks requires searching the utxo
+	// set in the database.
+	if detachNodes.Len() != 0 {
+		if err := b.flushUtxoCache(FlushRequired); err != nil {
+			return err
+		}
+	}
+
 	// Track the old and new best chains heads.
 	oldBest := tip
 	newBest := tip
@@ -875,7 +976,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err = view.fetchInputUtxos(b.db, block)
+		err = view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -942,7 +1043,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
 		if b.index.NodeStatus(n).KnownValid() {
-			err = view.fetchInputUtxos(b.db, block)
+			err = view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return err
 			}
@@ -963,7 +1064,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// In the case the block is determined to be invalid due to a
 		// rule violation, mark it as invalid and mark all of its
 		// descendants as having an invalid ancestor.
-		err = b.checkConnectBlock(n, block, view, nil)
+		err = b.checkConnectBlock(n, block, view, b.utxoCache, nil)
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
@@ -994,7 +1095,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1021,7 +1122,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1099,7 +1200,8 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		view.SetBestHash(parentHash)
 		stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, view, &stxos)
+			err := b.checkConnectBlock(node, block, view, b.utxoCache,
+				&stxos)
 			if err == nil {
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
@@ -1120,7 +1222,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
-			err := view.fetchInputUtxos(b.db, block)
+			err := view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return false, err
 			}
@@ -1204,6 +1306,367 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 	return err == nil, err
 }
 
+// bestCandidateTip returns the block node with the most cumulative work the
+// main chain could be reorganized to.  Neither the node nor any of its
+// ancestors may be known to be invalid and the block data for all of the nodes
+// that are not already part of the main chain must be available.  The current
+// tip of the main chain is preferred over nodes with the same amount of work.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestCandidateTip() *blockNode {
+	best := b.bestChain.Tip()
+	for _, tip := range b.index.tips() {
+		// Collect the nodes between the fork point with the main chain
+		// and the tip.
+		fork := b.bestChain.FindFork(tip)
+		var sideChain []*blockNode
+		for n := tip; n != nil && n != fork; n = n.parent {
+			sideChain = append(sideChain, n)
+		}
+
+		// Walk the side chain forwards from the fork point for as long
+		// as the nodes are usable.
+		for i := len(sideChain) - 1; i >= 0; i-- {
+			n := sideChain[i]
+			status := b.index.NodeStatus(n)
+			if status.KnownInvalid() || !status.HaveData() {
+				break
+			}
+			if n.workSum.Cmp(best.workSum) > 0 {
+				best = n
+			}
+		}
+	}
+
+	return best
+}
+
+// activateBestChain reorganizes the chain to the block with the most
+// cumulative work which is not known to be invalid.  Blocks that fail
+// validation while doing so are marked invalid and the next best block is
+// tried instead.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) activateBestChain() error {
+	for {
+		best := b.bestCandidateTip()
+		if best == b.bestChain.Tip() {
+			return nil
+		}
+
+		detachNodes, attachNodes := b.getReorganizeNodes(best)
+		err := b.reorganizeChain(detachNodes, attachNodes)
+		if err == nil {
+			continue
+		}
+
+		// The nodes which failed validation are marked invalid by the
+		// reorganize, so there might be another candidate to try.
+		if _, ok := err.(RuleError); ok &&
+			b.index.NodeStatus(best).KnownInvalid() {
+
+			continue
+		}
+		return err
+	}
+}
+
+// InvalidateBlock marks the block with the given hash along with all of its
+// descendants as invalid.  When the block is part of the main chain, it is
+// disconnected along with all of the blocks after it and the chain is
+// reorganized to the remaining valid block with the most cumulative work.
+//
+// The invalid status is persisted in the block index and remains in effect
+// until it is cleared by ReconsiderBlock.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		str := fmt.Sprintf("block %s is not known", hash)
+		return ruleError(ErrUnknownBlock, str)
+	}
+	if node.parent == nil {
+		str := fmt.Sprintf("block %s is the genesis block and can't "+
+			"be invalidated", hash)
+		return ruleError(ErrInvalidateGenesis, str)
+	}
+
+	// Refuse to invalidate main chain blocks which can't be disconnected
+	// because they have been pruned.
+	if b.bestChain.Contains(node) {
+		if err := b.checkPrunedDisconnect(node); err != nil {
+			return ruleError(ErrPrunedDisconnect, err.Error())
+		}
+	}
+
+	log.Infof("Invalidating block %v (height %d)", hash, node.height)
+
+	// Mark the block and its descendants invalid while remembering their
+	// previous statuses so they can be restored if the block can't be
+	// disconnected.
+	descendants := b.index.descendants(node)
+	prevStatuses := make([]blockStatus, 0, len(descendants)+1)
+	prevStatuses = append(prevStatuses, b.index.NodeStatus(node))
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	b.index.UnsetStatusFlags(node, statusValid)
+	for _, n := range descendants {
+		prevStatuses = append(prevStatuses, b.index.NodeStatus(n))
+		b.index.SetStatusFlags(n, statusInvalidAncestor)
+		b.index.UnsetStatusFlags(n, statusValid)
+	}
+
+	// Restore the previous statuses when the main chain can't be
+	// disconnected so the block index stays in sync with the chain state.
+	// They are flushed as well since disconnecting blocks flushes the
+	// block index, so the invalid statuses might already be persisted when
+	// disconnecting fails after some of the blocks were disconnected.
+	if err := b.invalidateMainChain(node); err != nil {
+		for i, n := range append([]*blockNode{node}, descendants...) {
+			b.index.UnsetStatusFlags(n, ^prevStatuses[i])
+			b.index.SetStatusFlags(n, prevStatuses[i])
+		}
+		if writeErr := b.index.flushToDB(); writeErr != nil {
+			log.Warnf("Error flushing block index changes to "+
+				"disk: %v", writeErr)
+		}
+		return err
+	}
+
+	// The block is no longer part of the main chain at this point, so the
+	// status changes above are persisted even when activating the best
+	// remaining chain fails.
+	err := b.activateBestChain()
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+
+	return err
+}
+
+// invalidateMainChain disconnects the passed node along with all of the nodes
+// after it when it is part of the main chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) invalidateMainChain(node *blockNode) error {
+	if !b.bestChain.Contains(node) {
+		return nil
+	}
+
+	detachNodes := list.New()
+	for n := b.bestChain.Tip(); n != node.parent; n = n.parent {
+		detachNodes.PushBack(n)
+	}
+	return b.reorganizeChain(detachNodes, list.New())
+}
+
+// ReconsiderBlock removes the invalid status from the block with the given
+// hash along with all of its ancestors and descendants, regardless of whether
+// the status was the result of a call to InvalidateBlock or the block failing
+// validation, and reorganizes the chain to the valid block with the most
+// cumulative work.  Blocks which are actually invalid will fail validation
+// again when the chain is reorganized to them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		str := fmt.Sprintf("block %s is not known", hash)
+		return ruleError(ErrUnknownBlock, str)
+	}
+
+	log.Infof("Reconsidering block %v (height %d)", hash, node.height)
+
+	const invalidFlags = statusValidateFailed | statusInvalidAncestor
+	for n := node; n != nil; n = n.parent {
+		if b.index.NodeStatus(n).KnownInvalid() {
+			b.index.UnsetStatusFlags(n, invalidFlags)
+		}
+	}
+	for _, n := range b.index.descendants(node) {
+		if b.index.NodeStatus(n).KnownInvalid() {
+			b.index.UnsetStatusFlags(n, invalidFlags)
+		}
+	}
+
+	err := b.activateBestChain()
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+
+	return err
+}
+
+// PreciousBlock treats the block with the given hash as if it was received
+// before any other block with the same amount of cumulative work.  When the
+// block is not part of the main chain, but has the same amount of work as the
+// current tip, the chain is reorganized to it.  Blocks with less work than the
+// current tip are not affected.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PreciousBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		str := fmt.Sprintf("block %s is not known", hash)
+		return ruleError(ErrUnknownBlock, str)
+	}
+
+	// Nothing to do when the block is already part of the main chain or it
+	// does not have enough work to become the tip.
+	if b.bestChain.Contains(node) ||
+		node.workSum.Cmp(b.bestChain.Tip().workSum) < 0 {
+
+		return nil
+	}
+
+	// The chain can only be reorganized to the block when none of the
+	// blocks on its side chain are known to be invalid and all of their
+	// data is available.
+	for n := node; n != nil && !b.bestChain.Contains(n); n = n.parent {
+		status := b.index.NodeStatus(n)
+		if status.KnownInvalid() || !status.HaveData() {
+			return nil
+		}
+	}
+
+	log.Infof("Treating block %v (height %d) as precious", hash,
+		node.height)
+
+	detachNodes, attachNodes := b.getReorganizeNodes(node)
+	err := b.reorganizeChain(detachNodes, attachNodes)
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+
+	return err
+}
+
+// TipStatus describes the state of the branch of the block tree that ends at a
+// chain tip.
+type TipStatus byte
+
+const (
+	// StatusActive indicates the tip is the end of the main chain.
+	StatusActive TipStatus = iota
+
+	// StatusValidFork indicates all of the blocks in the branch have been
+	// fully validated, but the branch is not part of the main chain.
+	StatusValidFork
+
+	// StatusValidHeaders indicates the data for all of the blocks in the
+	// branch is available, but the blocks have not all been fully
+	// validated.
+	StatusValidHeaders
+
+	// StatusHeadersOnly indicates the data for some of the blocks in the
+	// branch is not available.
+	StatusHeadersOnly
+
+	// StatusInvalid indicates the branch contains at least one block that
+	// is known to be invalid.
+	StatusInvalid
+)
+
+// tipStatusStrings is a map of tip statuses back to their constant names for
+// pretty printing.
+var tipStatusStrings = map[TipStatus]string{
+	StatusActive:       "active",
+	StatusValidFork:    "valid-fork",
+	StatusValidHeaders: "valid-headers",
+	StatusHeadersOnly:  "headers-only",
+	StatusInvalid:      "invalid",
+}
+
+// String returns the TipStatus as the human-readable name used by the
+// getchaintips RPC.
+func (status TipStatus) String() string {
+	if s, ok := tipStatusStrings[status]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown TipStatus (%d)", byte(status))
+}
+
+// ChainTip describes the last block of a branch of the block tree.
+type ChainTip struct {
+	// Height and Hash identify the last block of the branch.
+	Height int32
+	Hash   chainhash.Hash
+
+	// BranchLen is the number of blocks in the branch that are not part of
+	// the main chain.  It is zero for the tip of the main chain.
+	BranchLen int32
+
+	// Status is the state of the blocks in the branch.
+	Status TipStatus
+}
+
+// ChainTips returns information about the last block of every known branch of
+// the block tree sorted by descending height.  The tip of the main chain is
+// always included, even when it has children that are known to be invalid.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTips() []ChainTip {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	bestTip := b.bestChain.Tip()
+	chainTips := []ChainTip{{
+		Height: bestTip.height,
+		Hash:   bestTip.hash,
+		Status: StatusActive,
+	}}
+	for _, tip := range b.index.tips() {
+		if tip == bestTip {
+			continue
+		}
+
+		fork := b.bestChain.FindFork(tip)
+		chainTip := ChainTip{
+			Height:    tip.height,
+			Hash:      tip.hash,
+			BranchLen: tip.height - fork.height,
+			Status:    StatusValidFork,
+		}
+
+		// The status of the branch is the worst status of the blocks it
+		// is made of.
+		for n := tip; n != fork; n = n.parent {
+			status := b.index.NodeStatus(n)
+			switch {
+			case status.KnownInvalid():
+				chainTip.Status = StatusInvalid
+			case !status.HaveData() &&
+				chainTip.Status < StatusHeadersOnly:
+				chainTip.Status = StatusHeadersOnly
+			case !status.KnownValid() &&
+				chainTip.Status < StatusValidHeaders:
+				chainTip.Status = StatusValidHeaders
+			}
+		}
+		chainTips = append(chainTips, chainTip)
+	}
+
+	sort.Slice(chainTips, func(i, j int) bool {
+		if chainTips[i].Height != chainTips[j].Height {
+			return chainTips[i].Height > chainTips[j].Height
+		}
+		return bytes.Compare(chainTips[i].Hash[:], chainTips[j].Hash[:]) < 0
+	})
+	return chainTips
+}
+
 // isCurrent returns whether or not the chain believes it is current.  Several
 // factors are used to guess, but the key factors that allow the chain to
 // believe it is current are:
@@ -1700,6 +2163,42 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// Prune specifies the target size in bytes of the block data kept in
+	// the database.  Once the block data exceeds the target, the oldest
+	// blocks along with their spend journal entries are deleted while
+	// always keeping at least the most recent MinBlocksToKeep blocks.
+	//
+	// This field can be zero to disable pruning.  A database that has
+	// previously been pruned must not be loaded with pruning disabled.
+	Prune uint64
+
+	// UtxoCacheMaxSize specifies the maximum size in bytes of the utxo
+	// cache.  Modifications to the utxo set are accumulated in the cache
+	// and only written to the database once the cache exceeds this size,
+	// a periodic flush is due, or the cache is explicitly flushed.
+	//
+	// This field can be zero to write the modifications to the database
+	// after every block.
+	UtxoCacheMaxSize uint64
+
+	// UtxoSetStats specifies whether the statistics and MuHash of the utxo
+	// set are maintained incrementally as blocks are connected and
+	// disconnected so they can be fetched without scanning the utxo set.
+	// They are computed from the utxo set when enabled for a database which
+	// does not have them yet.
+	UtxoSetStats bool
+
+	// Snapshot specifies a utxo snapshot created by DumpUtxoSnapshot to
+	// bootstrap the chain from.  The snapshot must be based on one of the
+	// blocks listed by the AssumeUtxo field of the chain parameters and its
+	// utxo set must match the hash listed there.  The chain up to the
+	// block is validated by a background chainstate as the blocks are
+	// processed afterwards.  See MissingBackgroundBlocks.
+	//
+	// This field can only be set for a database which does not contain
+	// any blocks other than the genesis block yet.
+	Snapshot io.Reader
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1750,6 +2249,8 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
+		pruneTarget:         config.Prune,
+		utxoCache:           newUtxoCache(config.DB, config.UtxoCacheMaxSize),
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
@@ -1778,11 +2279,67 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Refuse to load a database that has been pruned without pruning
+	// enabled since the full block data is no longer available.
+	if config.Prune == 0 {
+		pruned, err := b.IsPruned()
+		if err != nil {
+			return nil, err
+		}
+		if pruned {
+			return nil, AssertError("blockchain.New database has " +
+				"been pruned, but pruning is not enabled")
+		}
+	}
+
 	// Perform any upgrades to the various chain-specific buckets as needed.
 	if err := b.maybeUpgradeDbBuckets(config.Interrupt); err != nil {
 		return nil, err
 	}
 
+	// Load the state of the background chainstate when the chain was
+	// bootstrapped from a utxo snapshot and bootstrap it from the passed
+	// one as requested.
+	if err := b.initBackgroundChainState(); err != nil {
+		return nil, err
+	}
+	if config.Snapshot != nil {
+		err := b.loadUtxoSnapshot(config.Snapshot, config.Interrupt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The optional indexes require all of the blocks to be available, so
+	// they can't be used before the utxo snapshot has been validated.
+	if config.IndexManager != nil && b.bgChainState != nil {
+		return nil, AssertError("blockchain.New optional indexes can " +
+			"not be enabled until the utxo snapshot the chain was " +
+			"bootstrapped from has been validated")
+	}
+
+	// Pruning could remove the data of blocks the background chainstate
+	// has yet to connect, so it can't be enabled before the utxo snapshot
+	// has been validated either.
+	if config.Prune != 0 && b.bgChainState != nil {
+		return nil, AssertError("blockchain.New pruning can not be " +
+			"enabled until the utxo snapshot the chain was " +
+			"bootstrapped from has been validated")
+	}
+
+	// Ensure the utxo set is consistent with the best chain, which might
+	// not be the case when the utxo cache was not flushed on shutdown.
+	if err := b.initUtxoCache(config.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// Load or compute the utxo set statistics when they are maintained
+	// incrementally.
+	err := b.initUtxoSetStats(config.UtxoSetStats, config.Interrupt)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -1797,6 +2354,24 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Finish falling back to the background chainstate when the utxo
+	// snapshot the chain was bootstrapped from was found to be invalid.
+	if bg := b.bgChainState; bg != nil && bg.invalid {
+		if err := b.fallBackToBackgroundChainState(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resume verifying the utxo set of the background chainstate when it
+	// has already connected the block the utxo snapshot is based on.
+	if bg := b.bgChainState; bg != nil && !bg.invalid &&
+		bg.tip == bg.snapshotNode {
+
+		if err := b.retireBackgroundChainState(); err != nil {
+			return nil, err
+		}
+	}
+
 	bestNode := b.bestChain.Tip()
 	log.Infof("Chain state (height %d, hash %v, totaltx %d, work %v)",
 		bestNode.height, bestNode.hash, b.stateSnapshot.TotalTxns,
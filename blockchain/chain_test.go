@@ -111,6 +111,75 @@ func TestHaveBlock(t *testing.T) {
 	}
 }
 
+// TestBlockTxCount ensures the number of transactions of main chain blocks is
+// read from the database and that an error is returned for blocks on a side
+// chain and blocks whose data has been pruned.
+func TestBlockTxCount(t *testing.T) {
+	// Load up blocks such that there is a side chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+	}
+
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	// Create a new database and chain instance to run tests against.
+	chain, teardownFunc, err := chainSetup("blocktxcount",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// The number of transactions of all main chain blocks must match.
+	for i := 0; i < 5; i++ {
+		numTxns, err := chain.BlockTxCount(blocks[i].Hash())
+		if err != nil {
+			t.Fatalf("BlockTxCount #%d unexpected error: %v", i, err)
+		}
+		want := uint64(len(blocks[i].Transactions()))
+		if numTxns != want {
+			t.Fatalf("BlockTxCount #%d got %d want %d", i, numTxns,
+				want)
+		}
+	}
+
+	// Block 3a is on a side chain.
+	_, err = chain.BlockTxCount(blocks[5].Hash())
+	if !isNotInMainChainErr(err) {
+		t.Fatalf("BlockTxCount on side chain block: unexpected error "+
+			"%v", err)
+	}
+
+	// The number of transactions can't be determined once the data of the
+	// block has been pruned.
+	node := chain.index.LookupNode(blocks[2].Hash())
+	chain.index.UnsetStatusFlags(node, statusDataStored)
+	if _, err := chain.BlockTxCount(blocks[2].Hash()); err == nil {
+		t.Fatal("BlockTxCount on pruned block: expected error")
+	}
+}
+
 // TestCalcSequenceLock tests the LockTimeToSequence function, and the
 // CalcSequenceLock method of a Chain instance. The tests exercise several
 // combinations of inputs to the CalcSequenceLock function in order to ensure
@@ -964,3 +1033,278 @@ func TestIntervalBlockHashes(t *testing.T) {
 		}
 	}
 }
+
+// TestInvalidateBlock ensures invalidating, reconsidering and preferring blocks
+// reorganizes the chain as expected.
+func TestInvalidateBlock(t *testing.T) {
+	// Load up blocks such that there is a side chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+	}
+
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("invalidateblock",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	block3, block4, block3a := blocks[3].Hash(), blocks[4].Hash(),
+		blocks[5].Hash()
+	tests := []struct {
+		name    string
+		op      func(*chainhash.Hash) error
+		hash    *chainhash.Hash
+		wantTip *chainhash.Hash
+	}{
+		{
+			// Disconnect the tip.  Block 3 remains the tip since it
+			// was already part of the main chain.
+			name:    "invalidate tip",
+			op:      chain.InvalidateBlock,
+			hash:    block4,
+			wantTip: block3,
+		},
+		{
+			name:    "precious side chain",
+			op:      chain.PreciousBlock,
+			hash:    block3a,
+			wantTip: block3a,
+		},
+		{
+			name:    "precious main chain block",
+			op:      chain.PreciousBlock,
+			hash:    block3,
+			wantTip: block3,
+		},
+		{
+			// Reorganize to the side chain.
+			name:    "invalidate main chain",
+			op:      chain.InvalidateBlock,
+			hash:    block3,
+			wantTip: block3a,
+		},
+		{
+			// Precious blocks must not be known to be invalid.
+			name:    "precious invalid block",
+			op:      chain.PreciousBlock,
+			hash:    block3,
+			wantTip: block3a,
+		},
+		{
+			// Reconsidering the tip also reconsiders its ancestors.
+			name:    "reconsider descendant",
+			op:      chain.ReconsiderBlock,
+			hash:    block4,
+			wantTip: block4,
+		},
+		{
+			name:    "invalidate side chain",
+			op:      chain.InvalidateBlock,
+			hash:    block3a,
+			wantTip: block4,
+		},
+	}
+
+	for _, test := range tests {
+		if err := test.op(test.hash); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		tip := chain.BestSnapshot().Hash
+		if tip != *test.wantTip {
+			t.Fatalf("%s: unexpected tip -- got %v, want %v",
+				test.name, tip, test.wantTip)
+		}
+	}
+
+	// Ensure the invalid status of the side chain block was persisted.
+	node := chain.index.LookupNode(block3a)
+	if !chain.index.NodeStatus(node).KnownInvalid() {
+		t.Fatalf("invalidated block %v is not known invalid", block3a)
+	}
+
+	// checkErrorCode ensures the passed error is a rule error with the
+	// passed error code.
+	checkErrorCode := func(err error, code ErrorCode) {
+		t.Helper()
+
+		if ruleErr, ok := err.(RuleError); !ok ||
+			ruleErr.ErrorCode != code {
+
+			t.Fatalf("unexpected error -- got %v, want %v", err,
+				code)
+		}
+	}
+
+	// Unknown blocks can't be invalidated, reconsidered or treated as
+	// precious.
+	unknown := chainhash.Hash{0x01}
+	checkErrorCode(chain.InvalidateBlock(&unknown), ErrUnknownBlock)
+	checkErrorCode(chain.ReconsiderBlock(&unknown), ErrUnknownBlock)
+	checkErrorCode(chain.PreciousBlock(&unknown), ErrUnknownBlock)
+
+	// The genesis block can't be invalidated.
+	err = chain.InvalidateBlock(blocks[0].Hash())
+	checkErrorCode(err, ErrInvalidateGenesis)
+
+	// Blocks whose data has been pruned can't be disconnected, so a pruned
+	// node must refuse to invalidate them and leave them untouched.
+	chain.pruneTarget = 1
+	node = chain.index.LookupNode(blocks[1].Hash())
+	chain.index.UnsetStatusFlags(node, statusDataStored)
+	err = chain.InvalidateBlock(blocks[1].Hash())
+	checkErrorCode(err, ErrPrunedDisconnect)
+	if chain.index.NodeStatus(node).KnownInvalid() {
+		t.Fatalf("pruned block %v was marked invalid", blocks[1].Hash())
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *block4 {
+		t.Fatalf("unexpected tip -- got %v, want %v", tip, block4)
+	}
+}
+
+// TestChainTips ensures the chain tips and the status of their branches are
+// reported as expected as the main chain changes.
+func TestChainTips(t *testing.T) {
+	// Load up blocks such that there is a side chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+	}
+
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("chaintips",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	block3, block4, block3a := blocks[3].Hash(), blocks[4].Hash(),
+		blocks[5].Hash()
+	tests := []struct {
+		name string
+		op   func() error
+		want []ChainTip
+	}{
+		{
+			// The side chain block was never connected, so it has
+			// not been fully validated.
+			name: "initial",
+			op:   func() error { return nil },
+			want: []ChainTip{
+				{Height: 4, Hash: *block4, Status: StatusActive},
+				{Height: 3, Hash: *block3a, BranchLen: 1,
+					Status: StatusValidHeaders},
+			},
+		},
+		{
+			name: "invalidate tip",
+			op:   func() error { return chain.InvalidateBlock(block4) },
+			want: []ChainTip{
+				{Height: 4, Hash: *block4, BranchLen: 1,
+					Status: StatusInvalid},
+				{Height: 3, Hash: *block3, Status: StatusActive},
+				{Height: 3, Hash: *block3a, BranchLen: 1,
+					Status: StatusValidHeaders},
+			},
+		},
+		{
+			name: "reorganize to side chain",
+			op:   func() error { return chain.InvalidateBlock(block3) },
+			want: []ChainTip{
+				{Height: 4, Hash: *block4, BranchLen: 2,
+					Status: StatusInvalid},
+				{Height: 3, Hash: *block3a, Status: StatusActive},
+			},
+		},
+		{
+			// The side chain was validated when it was connected.
+			name: "reconsider",
+			op:   func() error { return chain.ReconsiderBlock(block4) },
+			want: []ChainTip{
+				{Height: 4, Hash: *block4, Status: StatusActive},
+				{Height: 3, Hash: *block3a, BranchLen: 1,
+					Status: StatusValidFork},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if err := test.op(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		tips := chain.ChainTips()
+		if len(tips) != len(test.want) {
+			t.Fatalf("%s: unexpected number of tips -- got %d, "+
+				"want %d", test.name, len(tips), len(test.want))
+		}
+
+		// Tips with the same height are sorted by hash, so compare
+		// them regardless of their order.
+		for _, want := range test.want {
+			var found bool
+			for _, tip := range tips {
+				if tip == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("%s: missing tip %+v in %+v", test.name,
+					want, tips)
+			}
+		}
+		for i := 1; i < len(tips); i++ {
+			if tips[i].Height > tips[i-1].Height {
+				t.Fatalf("%s: tips not sorted by height: %+v",
+					test.name, tips)
+			}
+		}
+	}
+}
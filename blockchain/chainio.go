@@ -67,6 +67,33 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxosetv2")
 
+	// utxoStateConsistencyKeyName is the name of the db key used to store
+	// the hash of the block up to which the utxo set in the database is
+	// known to be consistent.  It differs from the best chain state when
+	// the utxo cache was not flushed prior to shutting down.
+	utxoStateConsistencyKeyName = []byte("utxostateconsistency")
+
+	// utxoSetStatsKeyName is the name of the db key used to store the
+	// incrementally maintained statistics and MuHash of the utxo set along
+	// with the hash of the block they are up to date with.
+	utxoSetStatsKeyName = []byte("utxosetstats")
+
+	// snapshotChainStateKeyName is the name of the db key used to store the
+	// state of the chainstate that was created from a utxo snapshot until
+	// it has been validated by the background chainstate.
+	snapshotChainStateKeyName = []byte("snapshotchainstate")
+
+	// bgUtxoSetBucketName is the name of the db bucket used to house the
+	// unspent transaction output set of the background chainstate which
+	// validates the chain up to the block a loaded utxo snapshot is based
+	// on.
+	bgUtxoSetBucketName = []byte("bgutxoset")
+
+	// bgUtxoStateConsistencyKeyName is the name of the db key used to store
+	// the hash of the block up to which the utxo set of the background
+	// chainstate in the database is known to be consistent.
+	bgUtxoStateConsistencyKeyName = []byte("bgutxostateconsistency")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -730,10 +757,22 @@ func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry,
 // When there is no entry for the provided output, nil will be returned for both
 // the entry and the error.
 func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, error) {
+	return dbFetchUtxoEntryFromBucket(dbTx, utxoSetBucketName, outpoint)
+}
+
+// dbFetchUtxoEntryFromBucket uses an existing database transaction to fetch the
+// specified transaction output from the utxo set housed in the bucket with the
+// passed name.
+//
+// When there is no entry for the provided output, nil will be returned for both
+// the entry and the error.
+func dbFetchUtxoEntryFromBucket(dbTx database.Tx, bucketName []byte,
+	outpoint wire.OutPoint) (*UtxoEntry, error) {
+
 	// Fetch the unspent transaction output information for the passed
 	// transaction output.  Return now when there is no entry.
 	key := outpointKey(outpoint)
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
 	serializedUtxo := utxoBucket.Get(*key)
 	recycleOutpointKey(key)
 	if serializedUtxo == nil {
@@ -771,8 +810,25 @@ func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, err
 // particular, only the entries that have been marked as modified are written
 // to the database.
 func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
-	for outpoint, entry := range view.entries {
+	return dbPutUtxoEntries(dbTx, view.entries)
+}
+
+// dbPutUtxoEntries uses an existing database transaction to update the utxo
+// set in the database based on the provided utxo entries.  Only the entries
+// that have been marked as modified are written to the database.
+func dbPutUtxoEntries(dbTx database.Tx, entries map[wire.OutPoint]*UtxoEntry) error {
+	return dbPutUtxoEntriesToBucket(dbTx, utxoSetBucketName, entries)
+}
+
+// dbPutUtxoEntriesToBucket uses an existing database transaction to update the
+// utxo set housed in the bucket with the passed name based on the provided utxo
+// entries.  Only the entries that have been marked as modified are written to
+// the database.
+func dbPutUtxoEntriesToBucket(dbTx database.Tx, bucketName []byte,
+	entries map[wire.OutPoint]*UtxoEntry) error {
+
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
+	for outpoint, entry := range entries {
 		// No need to update the database if the entry was not modified.
 		if entry == nil || !entry.isModified() {
 			continue
@@ -809,6 +865,103 @@ func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
 	return nil
 }
 
+// dbPutUtxoStateConsistency uses an existing database transaction to store the
+// hash of the block up to which the utxo set in the database is consistent.
+func dbPutUtxoStateConsistency(dbTx database.Tx, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Put(utxoStateConsistencyKeyName, hash[:])
+}
+
+// dbFetchUtxoStateConsistency uses an existing database transaction to fetch
+// the hash of the block up to which the utxo set in the database is
+// consistent.  Nil is returned when the consistency state has never been
+// stored.
+func dbFetchUtxoStateConsistency(dbTx database.Tx) *chainhash.Hash {
+	serialized := dbTx.Metadata().Get(utxoStateConsistencyKeyName)
+	if len(serialized) != chainhash.HashSize {
+		return nil
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	return &hash
+}
+
+// -----------------------------------------------------------------------------
+// The utxo set statistics are only stored when they are maintained
+// incrementally and consist of the hash of the block they are up to date with
+// followed by the statistics and the serialized MuHash3072 state:
+//
+//   <block hash><txouts><bogosize><total amount><muhash>
+//
+//   Field          Type             Size
+//   block hash     chainhash.Hash   chainhash.HashSize
+//   txouts         uint64           8
+//   bogosize       uint64           8
+//   total amount   uint64           8
+//   muhash         []byte           serializedMuHashSize
+// -----------------------------------------------------------------------------
+
+// serializedUtxoSetStatsSize is the size of serialized utxo set statistics.
+const serializedUtxoSetStatsSize = chainhash.HashSize + 24 + serializedMuHashSize
+
+// dbPutUtxoSetStats uses an existing database transaction to store the passed
+// utxo set statistics which are up to date with the block with the passed
+// hash.
+func dbPutUtxoSetStats(dbTx database.Tx, hash *chainhash.Hash, stats *utxoSetStats) error {
+	serialized := make([]byte, serializedUtxoSetStatsSize)
+	offset := copy(serialized, hash[:])
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.txOuts))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.bogoSize))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.totalAmount))
+	offset += 8
+	copy(serialized[offset:], stats.muHash.Serialize())
+	return dbTx.Metadata().Put(utxoSetStatsKeyName, serialized)
+}
+
+// dbFetchUtxoSetStats uses an existing database transaction to fetch the
+// stored utxo set statistics along with the hash of the block they are up to
+// date with.  Nil is returned when no statistics are stored.
+func dbFetchUtxoSetStats(dbTx database.Tx) (*chainhash.Hash, *utxoSetStats, error) {
+	serialized := dbTx.Metadata().Get(utxoSetStatsKeyName)
+	if serialized == nil {
+		return nil, nil, nil
+	}
+	if len(serialized) != serializedUtxoSetStatsSize {
+		return nil, nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo set statistics",
+		}
+	}
+
+	var hash chainhash.Hash
+	offset := copy(hash[:], serialized)
+	stats := &utxoSetStats{}
+	stats.txOuts = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	stats.bogoSize = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	stats.totalAmount = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	muHash, err := deserializeMuHash3072(serialized[offset:])
+	if err != nil {
+		return nil, nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt utxo set statistics: "+
+				"%v", err),
+		}
+	}
+	stats.muHash = muHash
+	return &hash, stats, nil
+}
+
+// dbRemoveUtxoSetStats uses an existing database transaction to remove the
+// stored utxo set statistics.
+func dbRemoveUtxoSetStats(dbTx database.Tx) error {
+	return dbTx.Metadata().Delete(utxoSetStatsKeyName)
+}
+
 // -----------------------------------------------------------------------------
 // The block index consists of two buckets with an entry for every block in the
 // main chain.  One bucket is for the hash to height mapping and the other is
@@ -1201,27 +1354,40 @@ func (b *BlockChain) initChainState() error {
 		}
 		b.bestChain.SetTip(tip)
 
-		// Load the raw block bytes for the best block.
-		blockBytes, err := dbTx.FetchBlock(&state.hash)
-		if err != nil {
-			return err
-		}
-		var block wire.MsgBlock
-		err = block.Deserialize(bytes.NewReader(blockBytes))
-		if err != nil {
-			return err
+		// Load the raw block bytes for the best block.  They are not
+		// available when the best block is the one a utxo snapshot the
+		// chain was bootstrapped from is based on, so the related state
+		// is left zero in that case.
+		var blockSize, blockWeight, numTxns uint64
+		if tip.status.HaveData() {
+			blockBytes, err := dbTx.FetchBlock(&state.hash)
+			if err != nil {
+				return err
+			}
+			var block wire.MsgBlock
+			err = block.Deserialize(bytes.NewReader(blockBytes))
+			if err != nil {
+				return err
+			}
+			blockSize = uint64(len(blockBytes))
+			blockWeight = uint64(GetBlockWeight(btcutil.NewBlock(&block)))
+			numTxns = uint64(len(block.Transactions))
 		}
 
 		// As a final consistency check, we'll run through all the
 		// nodes which are ancestors of the current chain tip, and mark
 		// them as valid if they aren't already marked as such.  This
 		// is a safe assumption as all the block before the current tip
-		// are valid by definition.
+		// are valid by definition.  The exception are the blocks which
+		// are only assumed to be valid since the chain was bootstrapped
+		// from a utxo snapshot.
 		for iterNode := tip; iterNode != nil; iterNode = iterNode.parent {
 			// If this isn't already marked as valid in the index, then
 			// we'll mark it as valid now to ensure consistency once
 			// we're up and running.
-			if !iterNode.status.KnownValid() {
+			if !iterNode.status.KnownValid() &&
+				!iterNode.status.AssumedValid() {
+
 				log.Infof("Block %v (height=%v) ancestor of "+
 					"chain tip not marked as valid, "+
 					"upgrading to valid for consistency",
@@ -1232,9 +1398,6 @@ func (b *BlockChain) initChainState() error {
 		}
 
 		// Initialize the state related to the best block.
-		blockSize := uint64(len(blockBytes))
-		blockWeight := uint64(GetBlockWeight(btcutil.NewBlock(&block)))
-		numTxns := uint64(len(block.Transactions))
 		b.stateSnapshot = newBestState(tip, blockSize, blockWeight,
 			numTxns, state.totalTxns, tip.CalcPastMedianTime())
 
@@ -1317,6 +1480,24 @@ func dbFetchBlockByNode(dbTx database.Tx, node *blockNode) (*btcutil.Block, erro
 	return block, nil
 }
 
+// dbFetchBlockTxCount uses an existing database transaction to retrieve the
+// number of transactions in the block with the provided hash.  Only the number
+// of transactions, which directly follows the header and is at most 9 bytes
+// long, is read rather than the entire block.
+func dbFetchBlockTxCount(dbTx database.Tx, hash *chainhash.Hash) (uint64, error) {
+	// The region can't extend past the end of the block since every block
+	// contains at least one transaction that is larger than the region.
+	region, err := dbTx.FetchBlockRegion(&database.BlockRegion{
+		Hash:   hash,
+		Offset: blockHdrSize,
+		Len:    wire.MaxVarIntPayload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wire.ReadVarInt(bytes.NewReader(region), 0)
+}
+
 // dbStoreBlockNode stores the block header and validation status to the block
 // index bucket. This overwrites the current entry if there exists one.
 func dbStoreBlockNode(dbTx database.Tx, node *blockNode) error {
@@ -1383,6 +1564,34 @@ func (b *BlockChain) BlockByHeight(blockHeight int32) (*btcutil.Block, error) {
 	return block, err
 }
 
+// BlockTxCount returns the number of transactions in the block from the main
+// chain with the given hash.  Only the number of transactions is read from the
+// database, so this is considerably cheaper than loading the block.  An error
+// is returned when the data of the block has been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockTxCount(hash *chainhash.Hash) (uint64, error) {
+	// Lookup the block hash in block index and ensure it is in the best
+	// chain.
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return 0, errNotInMainChain(str)
+	}
+	if !b.index.NodeStatus(node).HaveData() {
+		return 0, fmt.Errorf("block %s is not available since its data "+
+			"has been pruned", hash)
+	}
+
+	var numTxns uint64
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		numTxns, err = dbFetchBlockTxCount(dbTx, hash)
+		return err
+	})
+	return numTxns, err
+}
+
 // BlockByHash returns the block from the main chain with the given hash with
 // the appropriate chain height set.
 //
@@ -0,0 +1,758 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// bgProgressLogInterval is the number of blocks connected by the background
+// chainstate in between messages which log its progress.
+const bgProgressLogInterval = 10000
+
+// snapshotStatus identifies the state of the chainstate that was created from
+// a utxo snapshot.
+type snapshotStatus uint8
+
+const (
+	// snapshotLoading indicates the utxo snapshot is being loaded into the
+	// database.  A database which is left in this state can't be used.
+	snapshotLoading snapshotStatus = iota
+
+	// snapshotValidating indicates the chain up to the block the utxo
+	// snapshot is based on is being validated by the background
+	// chainstate.
+	snapshotValidating
+
+	// snapshotInvalid indicates the background chainstate found the utxo
+	// snapshot to be invalid and that the utxo set of the best chain, which
+	// was created from it, is being removed.
+	snapshotInvalid
+
+	// snapshotFallingBack indicates the utxo set of the best chain created
+	// from the invalid utxo snapshot has been removed and that the utxo set
+	// of the background chainstate is being moved in its place.
+	snapshotFallingBack
+)
+
+// -----------------------------------------------------------------------------
+// The state of the chainstate that was created from a utxo snapshot is only
+// stored until the snapshot has been validated by the background chainstate and
+// consists of the hash of the block the snapshot is based on followed by its
+// status:
+//
+//   <block hash><status>
+//
+//   Field        Type             Size
+//   block hash   chainhash.Hash   chainhash.HashSize
+//   status       uint8            1
+// -----------------------------------------------------------------------------
+
+// dbPutSnapshotChainState uses an existing database transaction to store the
+// state of the chainstate created from the utxo snapshot based on the block
+// with the passed hash.
+func dbPutSnapshotChainState(dbTx database.Tx, hash *chainhash.Hash,
+	status snapshotStatus) error {
+
+	serialized := make([]byte, chainhash.HashSize+1)
+	copy(serialized, hash[:])
+	serialized[chainhash.HashSize] = byte(status)
+	return dbTx.Metadata().Put(snapshotChainStateKeyName, serialized)
+}
+
+// dbFetchSnapshotChainState uses an existing database transaction to fetch the
+// hash of the block the utxo snapshot the chain was bootstrapped from is based
+// on along with the status of the chainstate created from it.  A nil hash is
+// returned when the chain was not bootstrapped from a utxo snapshot or the
+// snapshot has been validated.
+func dbFetchSnapshotChainState(dbTx database.Tx) (*chainhash.Hash, snapshotStatus, error) {
+	serialized := dbTx.Metadata().Get(snapshotChainStateKeyName)
+	if serialized == nil {
+		return nil, 0, nil
+	}
+	if len(serialized) != chainhash.HashSize+1 {
+		return nil, 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt snapshot chainstate",
+		}
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	return &hash, snapshotStatus(serialized[chainhash.HashSize]), nil
+}
+
+// backgroundChainState is a chainstate which validates the chain from the
+// genesis block up to the block the utxo snapshot the best chain was
+// bootstrapped from is based on.  It has its own utxo set, which is expected to
+// match the one of the snapshot once it reaches that block, at which point the
+// background chainstate retires itself.
+//
+// The blocks before the snapshot block are only known by their headers until
+// they are downloaded, which is why the block data is processed separately from
+// the blocks of the best chain.  See MissingBackgroundBlocks.
+type backgroundChainState struct {
+	// utxoCache is the cache of the utxo set of the background chainstate
+	// and tip is the last block it has connected.
+	utxoCache *utxoCache
+	tip       *blockNode
+
+	// snapshotNode is the block the utxo snapshot is based on.
+	snapshotNode *blockNode
+
+	// invalid indicates the background chainstate found the utxo snapshot
+	// to be invalid, in which case it stops validating blocks and the best
+	// chain falls back to it.
+	invalid bool
+
+	// verifying indicates the utxo set of the background chainstate is
+	// being hashed in order to compare it with the one of the snapshot
+	// after it connected the snapshot block.
+	verifying bool
+}
+
+// initBackgroundChainState loads the state of the background chainstate from
+// the database when the chain was bootstrapped from a utxo snapshot which is
+// yet to be validated.
+func (b *BlockChain) initBackgroundChainState() error {
+	var snapshotHash, tipHash *chainhash.Hash
+	var status snapshotStatus
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		snapshotHash, status, err = dbFetchSnapshotChainState(dbTx)
+		if err != nil || snapshotHash == nil {
+			return err
+		}
+
+		serialized := dbTx.Metadata().Get(bgUtxoStateConsistencyKeyName)
+		if len(serialized) == chainhash.HashSize {
+			tipHash = new(chainhash.Hash)
+			copy(tipHash[:], serialized)
+		}
+		return nil
+	})
+	if err != nil || snapshotHash == nil {
+		return err
+	}
+
+	if status == snapshotLoading {
+		return AssertError("the database contains a partially loaded " +
+			"utxo snapshot and needs to be removed")
+	}
+	snapshotNode := b.index.LookupNode(snapshotHash)
+	if snapshotNode == nil || !b.bestChain.Contains(snapshotNode) {
+		return AssertError(fmt.Sprintf("utxo snapshot block %v is not "+
+			"in the main chain", snapshotHash))
+	}
+	if tipHash == nil {
+		return AssertError("background chainstate tip is missing")
+	}
+	tip := b.index.LookupNode(tipHash)
+	if tip == nil || !b.bestChain.Contains(tip) ||
+		tip.height > snapshotNode.height {
+
+		return AssertError(fmt.Sprintf("background chainstate tip %v "+
+			"is not in the main chain before the utxo snapshot "+
+			"block", tipHash))
+	}
+
+	cache := newUtxoCacheForBucket(b.db, b.utxoCache.maxSize,
+		bgUtxoSetBucketName, bgUtxoStateConsistencyKeyName)
+	cache.lastFlushHash = *tipHash
+	b.bgChainState = &backgroundChainState{
+		utxoCache:    cache,
+		tip:          tip,
+		snapshotNode: snapshotNode,
+		invalid:      status != snapshotValidating,
+	}
+
+	// The best chain finishes falling back to the background chainstate
+	// once the chain is fully initialized.
+	if b.bgChainState.invalid {
+		log.Errorf("The utxo snapshot the chain was bootstrapped from "+
+			"at block %v (height %d) is INVALID", snapshotHash,
+			snapshotNode.height)
+		return nil
+	}
+	log.Infof("Background chainstate validating the chain up to the "+
+		"utxo snapshot block %v (height %d) is at height %d",
+		snapshotHash, snapshotNode.height, tip.height)
+	return nil
+}
+
+// needsBackgroundBlock returns whether or not the passed block is needed by the
+// background chainstate but its data is not available yet.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) needsBackgroundBlock(node *blockNode) bool {
+	bg := b.bgChainState
+	return bg != nil && !bg.invalid && node.height > bg.tip.height &&
+		node.height <= bg.snapshotNode.height &&
+		!b.index.NodeStatus(node).HaveData() && b.bestChain.Contains(node)
+}
+
+// processBackgroundBlock stores the data of the passed block which is needed by
+// the background chainstate after performing the context free checks and the
+// checks of its transactions which depend on its position within the chain.
+// Its header was validated when the utxo snapshot was loaded.  Then, as many
+// blocks as possible are connected by the background chainstate.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) processBackgroundBlock(node *blockNode,
+	block *btcutil.Block, flags BehaviorFlags) error {
+
+	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		flags)
+	if err != nil {
+		return err
+	}
+	block.SetHeight(node.height)
+	err = b.checkBlockTransactionsContext(block, node.parent, flags)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbStoreBlock(dbTx, block)
+	})
+	if err != nil {
+		return err
+	}
+	b.index.SetStatusFlags(node, statusDataStored)
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	return b.connectBackgroundBlocks()
+}
+
+// connectBackgroundBlocks connects the blocks following the tip of the
+// background chainstate for which the block data is available.  The background
+// chainstate is retired once it has connected the block the utxo snapshot is
+// based on and found its utxo set to match the one of the snapshot.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectBackgroundBlocks() error {
+	bg := b.bgChainState
+	for !bg.invalid && bg.tip != bg.snapshotNode {
+		node := b.bestChain.NodeByHeight(bg.tip.height + 1)
+		if !b.index.NodeStatus(node).HaveData() {
+			return nil
+		}
+
+		var block *btcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		view := NewUtxoViewpoint()
+		view.SetBestHash(&bg.tip.hash)
+		err = b.checkConnectBlock(node, block, view, bg.utxoCache, nil)
+		if err != nil {
+			if _, ok := err.(RuleError); ok {
+				if err := b.invalidateSnapshot(node, err); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+
+		b.index.SetStatusFlags(node, statusValid)
+		b.index.UnsetStatusFlags(node, statusAssumedValid)
+
+		if bg.utxoCache.needsFlush(FlushPeriodic, view) {
+			err := b.db.Update(func(dbTx database.Tx) error {
+				return bg.utxoCache.flush(dbTx, view, &node.hash)
+			})
+			if err != nil {
+				return err
+			}
+			bg.utxoCache.markFlushed(&node.hash)
+
+			// Persist the statuses of the blocks connected so far
+			// along with the utxo set they are consistent with.
+			if err := b.index.flushToDB(); err != nil {
+				return err
+			}
+		} else {
+			bg.utxoCache.commit(view)
+		}
+		bg.tip = node
+
+		if node.height%bgProgressLogInterval == 0 {
+			log.Infof("Background chainstate validated the chain up "+
+				"to height %d of %d", node.height,
+				bg.snapshotNode.height)
+		}
+	}
+	if bg.invalid {
+		return nil
+	}
+
+	return b.retireBackgroundChainState()
+}
+
+// flushBackgroundUtxoCache writes the utxo cache of the background chainstate
+// to the database as of its tip when required by the passed mode.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) flushBackgroundUtxoCache(mode FlushMode) error {
+	bg := b.bgChainState
+	if bg == nil || bg.invalid || !bg.utxoCache.needsFlush(mode, nil) {
+		return nil
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return bg.utxoCache.flush(dbTx, nil, &bg.tip.hash)
+	})
+	if err != nil {
+		return err
+	}
+	bg.utxoCache.markFlushed(&bg.tip.hash)
+
+	// Persist the statuses of the blocks connected by the background
+	// chainstate along with the utxo set they are consistent with.
+	return b.index.flushToDB()
+}
+
+// invalidateSnapshot marks the utxo snapshot the chain was bootstrapped from as
+// invalid after the background chainstate failed to connect the passed block
+// with the passed error and makes the best chain fall back to the background
+// chainstate.  The block is marked invalid along with its descendants when the
+// error is a rule error.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) invalidateSnapshot(node *blockNode, cause error) error {
+	bg := b.bgChainState
+	bg.invalid = true
+	log.Errorf("Background chainstate failed to connect block %v "+
+		"(height %d): %v -- the utxo snapshot the chain was "+
+		"bootstrapped from at block %v (height %d) is INVALID", node.hash,
+		node.height, cause, bg.snapshotNode.hash, bg.snapshotNode.height)
+
+	if _, ok := cause.(RuleError); ok {
+		b.index.SetStatusFlags(node, statusValidateFailed)
+		for _, n := range b.index.descendants(node) {
+			b.index.SetStatusFlags(n, statusInvalidAncestor)
+		}
+	}
+
+	// The blocks after the tip of the background chainstate were either
+	// assumed to be valid or validated using the utxo set of the snapshot,
+	// so they have to be validated again.
+	for _, n := range b.index.descendants(bg.tip) {
+		b.index.UnsetStatusFlags(n, statusValid|statusAssumedValid)
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbPutSnapshotChainState(dbTx, &bg.snapshotNode.hash,
+			snapshotInvalid)
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.fallBackToBackgroundChainState()
+}
+
+// fallBackToBackgroundChainState replaces the utxo set of the best chain, which
+// was created from the invalid utxo snapshot, with the one of the background
+// chainstate and makes the tip of the background chainstate the tip of the best
+// chain.  The background chainstate is removed and the best chain is then
+// extended again with the blocks which are not known to be invalid.
+//
+// The utxo sets are modified in batches.  The progress is tracked by the state
+// of the snapshot chainstate, so a fallback which was interrupted is resumed
+// when the chain is loaded again.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) fallBackToBackgroundChainState() error {
+	bg := b.bgChainState
+	log.Infof("Falling back to the background chainstate at block %v "+
+		"(height %d)", bg.tip.hash, bg.tip.height)
+
+	// Write the utxo set of the background chainstate to the database so
+	// it can be moved and gather the state of its tip.
+	if bg.utxoCache.needsFlush(FlushRequired, nil) {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return bg.utxoCache.flush(dbTx, nil, &bg.tip.hash)
+		})
+		if err != nil {
+			return err
+		}
+		bg.utxoCache.markFlushed(&bg.tip.hash)
+	}
+	state, err := b.backgroundTipState()
+	if err != nil {
+		return err
+	}
+
+	var status snapshotStatus
+	err = b.db.View(func(dbTx database.Tx) error {
+		var err error
+		_, status, err = dbFetchSnapshotChainState(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the utxo set of the best chain.
+	for done := status != snapshotInvalid; !done; {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			cursor := utxoBucket.Cursor()
+			numDeleted := 0
+			for ok := cursor.First(); ok &&
+				numDeleted < snapshotBatchSize; ok = cursor.Next() {
+
+				if err := utxoBucket.Delete(cursor.Key()); err != nil {
+					return err
+				}
+				numDeleted++
+			}
+			if numDeleted == snapshotBatchSize {
+				return nil
+			}
+
+			done = true
+			return dbPutSnapshotChainState(dbTx,
+				&bg.snapshotNode.hash, snapshotFallingBack)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Move the utxo set of the background chainstate in its place.  The
+	// best state is updated along with the last batch and the state of
+	// the background chainstate is removed.
+	oldTip := b.bestChain.Tip()
+	for done := false; !done; {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			meta := dbTx.Metadata()
+			utxoBucket := meta.Bucket(utxoSetBucketName)
+			bgUtxoBucket := meta.Bucket(bgUtxoSetBucketName)
+			cursor := bgUtxoBucket.Cursor()
+			numMoved := 0
+			for ok := cursor.First(); ok &&
+				numMoved < snapshotBatchSize; ok = cursor.Next() {
+
+				key := append([]byte(nil), cursor.Key()...)
+				value := append([]byte(nil), cursor.Value()...)
+				if err := utxoBucket.Put(key, value); err != nil {
+					return err
+				}
+				if err := bgUtxoBucket.Delete(key); err != nil {
+					return err
+				}
+				numMoved++
+			}
+			if numMoved == snapshotBatchSize {
+				return nil
+			}
+
+			done = true
+			for n := oldTip; n != bg.tip; n = n.parent {
+				err := dbRemoveBlockIndex(dbTx, &n.hash, n.height)
+				if err != nil {
+					return err
+				}
+			}
+			err := dbPutBestState(dbTx, state, bg.tip.workSum)
+			if err != nil {
+				return err
+			}
+			err = dbPutUtxoStateConsistency(dbTx, &bg.tip.hash)
+			if err != nil {
+				return err
+			}
+			if err := meta.DeleteBucket(bgUtxoSetBucketName); err != nil {
+				return err
+			}
+			if err := meta.Delete(bgUtxoStateConsistencyKeyName); err != nil {
+				return err
+			}
+			return meta.Delete(snapshotChainStateKeyName)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	b.utxoCache = newUtxoCache(b.db, b.utxoCache.maxSize)
+	b.utxoCache.lastFlushHash = bg.tip.hash
+	b.bestChain.SetTip(bg.tip)
+	b.stateLock.Lock()
+	b.stateSnapshot = state
+	b.stateLock.Unlock()
+	b.bgChainState = nil
+
+	// The utxo set statistics maintained incrementally no longer match the
+	// utxo set, so they are removed until they are computed again when the
+	// chain is loaded the next time.
+	if b.utxoSetStats != nil {
+		if err := b.db.Update(dbRemoveUtxoSetStats); err != nil {
+			return err
+		}
+		b.utxoSetStats = nil
+		log.Warnf("The utxo set statistics will be computed again the " +
+			"next time the chain is loaded")
+	}
+
+	log.Infof("Fell back to the background chainstate at block %v "+
+		"(height %d)", bg.tip.hash, bg.tip.height)
+
+	return b.activateBestChain()
+}
+
+// backgroundTipState returns the best state of the chain ending at the tip of
+// the background chainstate.  The total number of transactions is counted by
+// reading the number of transactions of each block from the database, which
+// requires the data of all of the blocks up to the tip to be available.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) backgroundTipState() (*BestState, error) {
+	tip := b.bgChainState.tip
+	var block *btcutil.Block
+	var totalTxns uint64
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByNode(dbTx, tip)
+		if err != nil {
+			return err
+		}
+
+		for n := tip; n != nil; n = n.parent {
+			numTxns, err := dbFetchBlockTxCount(dbTx, &n.hash)
+			if err != nil {
+				return err
+			}
+			totalTxns += numTxns
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	numTxns := uint64(len(block.MsgBlock().Transactions))
+	blockSize := uint64(block.MsgBlock().SerializeSize())
+	blockWeight := uint64(GetBlockWeight(block))
+	return newBestState(tip, blockSize, blockWeight, numTxns, totalTxns,
+		tip.CalcPastMedianTime()), nil
+}
+
+// retireBackgroundChainState starts verifying the utxo set of the background
+// chainstate matches the hash of the utxo snapshot the chain was bootstrapped
+// from once it has connected the block the snapshot is based on.  The utxo set
+// no longer changes at that point, so it is hashed without holding the chain
+// state lock by verifyBackgroundChainState.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) retireBackgroundChainState() error {
+	bg := b.bgChainState
+	if bg.verifying {
+		return nil
+	}
+	if err := b.flushBackgroundUtxoCache(FlushRequired); err != nil {
+		return err
+	}
+
+	log.Infof("Background chainstate reached the utxo snapshot block %v "+
+		"(height %d), verifying its utxo set", bg.snapshotNode.hash,
+		bg.snapshotNode.height)
+
+	bg.verifying = true
+	go b.verifyBackgroundChainState(bg)
+	return nil
+}
+
+// verifyBackgroundChainState hashes the utxo set of the passed background
+// chainstate, which must have connected the block the utxo snapshot is based
+// on, and compares it with the hash of the snapshot.  The background chainstate
+// is removed when they match, in which case the chain is fully validated.
+// Otherwise, the best chain falls back to it.
+//
+// This function MUST be called without the chain state lock held.
+func (b *BlockChain) verifyBackgroundChainState(bg *backgroundChainState) {
+	var hash chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		hasher := newSerializedUtxoSetHasher(&bg.tip.hash)
+		err := dbForEachUtxo(dbTx, bgUtxoSetBucketName, func(
+			outpoint wire.OutPoint, entry *UtxoEntry, diskSize int) error {
+
+			hasher.add(outpoint, entry)
+			return nil
+		})
+		hash = hasher.sum()
+		return err
+	})
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.bgChainState != bg {
+		return
+	}
+	if err != nil {
+		// The verification is retried the next time the chain is
+		// loaded.
+		bg.verifying = false
+		log.Errorf("Unable to hash the utxo set of the background "+
+			"chainstate: %v", err)
+		return
+	}
+	assumeUtxo := b.assumeUtxoForBlock(&bg.snapshotNode.hash)
+	if assumeUtxo == nil || hash != *assumeUtxo.HashSerialized {
+		str := fmt.Sprintf("the hash %v of the validated utxo set does "+
+			"not match the one of the utxo snapshot", hash)
+		err := b.invalidateSnapshot(bg.snapshotNode, AssertError(str))
+		if err != nil {
+			log.Errorf("Unable to fall back to the background "+
+				"chainstate: %v", err)
+		}
+		return
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if err := meta.DeleteBucket(bgUtxoSetBucketName); err != nil {
+			return err
+		}
+		if err := meta.Delete(bgUtxoStateConsistencyKeyName); err != nil {
+			return err
+		}
+		return meta.Delete(snapshotChainStateKeyName)
+	})
+	if err != nil {
+		bg.verifying = false
+		log.Errorf("Unable to remove the background chainstate: %v", err)
+		return
+	}
+	b.bgChainState = nil
+
+	log.Infof("Background chainstate validated the utxo snapshot the "+
+		"chain was bootstrapped from at block %v (height %d)",
+		bg.snapshotNode.hash, bg.snapshotNode.height)
+}
+
+// MissingBackgroundBlocks returns the hashes of up to the passed number of
+// blocks which are needed by the background chainstate, but whose data is not
+// available yet, in order of their height.  These blocks need to be downloaded
+// and processed in order for the utxo snapshot the chain was bootstrapped from
+// to be validated.  Nil is returned when there is no background chainstate.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) MissingBackgroundBlocks(maxBlocks int) []*chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	bg := b.bgChainState
+	if bg == nil || bg.invalid {
+		return nil
+	}
+
+	var hashes []*chainhash.Hash
+	for height := bg.tip.height + 1; height <= bg.snapshotNode.height &&
+		len(hashes) < maxBlocks; height++ {
+
+		node := b.bestChain.NodeByHeight(height)
+		if !b.index.NodeStatus(node).HaveData() {
+			hashes = append(hashes, &node.hash)
+		}
+	}
+	return hashes
+}
+
+// ChainStateInfo houses information about a chainstate, which is a utxo set
+// along with the tip of the chain it is consistent with.
+type ChainStateInfo struct {
+	// Hash and Height identify the tip of the chainstate and Bits are its
+	// difficulty bits.
+	Hash   chainhash.Hash
+	Height int32
+	Bits   uint32
+
+	// SnapshotHash is the hash of the block the utxo snapshot the
+	// chainstate was created from is based on.  It is nil when the
+	// chainstate was not created from a utxo snapshot.
+	SnapshotHash *chainhash.Hash
+
+	// Validated indicates whether or not the chain up to the tip of the
+	// chainstate has been fully validated.
+	Validated bool
+
+	// Invalid indicates the chainstate was found to be invalid.
+	Invalid bool
+
+	// Progress is an estimate of how far the chainstate has progressed
+	// towards its target between 0 and 1.  The target of the background
+	// chainstate is the block the utxo snapshot is based on and the
+	// target of the best chain is the present time.
+	Progress float64
+}
+
+// ChainStates returns information about the chainstates.  There is only one
+// chainstate, which tracks the best chain, unless the chain was bootstrapped
+// from a utxo snapshot which is yet to be validated.  In that case, the
+// background chainstate which validates the chain up to the block the snapshot
+// is based on precedes the one of the best chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainStates() []ChainStateInfo {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var chainStates []ChainStateInfo
+	tip := b.bestChain.Tip()
+	active := ChainStateInfo{
+		Hash:      tip.hash,
+		Height:    tip.height,
+		Bits:      tip.bits,
+		Validated: true,
+	}
+
+	// Estimate the progress of the best chain by the time elapsed since
+	// the genesis block.
+	genesisTime := b.bestChain.Genesis().timestamp
+	elapsed := b.timeSource.AdjustedTime().Unix() - genesisTime
+	active.Progress = 1
+	if elapsed > 0 && tip.timestamp-genesisTime < elapsed {
+		active.Progress = float64(tip.timestamp-genesisTime) /
+			float64(elapsed)
+	}
+
+	if bg := b.bgChainState; bg != nil {
+		chainStates = append(chainStates, ChainStateInfo{
+			Hash:      bg.tip.hash,
+			Height:    bg.tip.height,
+			Bits:      bg.tip.bits,
+			Validated: true,
+			Invalid:   bg.invalid,
+			Progress: float64(bg.tip.height) /
+				float64(bg.snapshotNode.height),
+		})
+
+		active.SnapshotHash = &bg.snapshotNode.hash
+		active.Validated = false
+		active.Invalid = bg.invalid
+	}
+
+	return append(chainStates, active)
+}
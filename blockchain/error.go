@@ -220,6 +220,20 @@ const (
 	// current chain tip. This is not a block validation rule, but is required
 	// for block proposals submitted via getblocktemplate RPC.
 	ErrPrevBlockNotBest
+
+	// ErrUnknownBlock indicates that the block passed to InvalidateBlock,
+	// ReconsiderBlock or PreciousBlock is not known.  This is not a block
+	// validation rule.
+	ErrUnknownBlock
+
+	// ErrInvalidateGenesis indicates an attempt to invalidate the genesis
+	// block.  This is not a block validation rule.
+	ErrInvalidateGenesis
+
+	// ErrPrunedDisconnect indicates an attempt to invalidate a block of
+	// the main chain which can't be disconnected since its data has been
+	// pruned.  This is not a block validation rule.
+	ErrPrunedDisconnect
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -267,6 +281,9 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrPreviousBlockUnknown:      "ErrPreviousBlockUnknown",
 	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:          "ErrPrevBlockNotBest",
+	ErrUnknownBlock:              "ErrUnknownBlock",
+	ErrInvalidateGenesis:         "ErrInvalidateGenesis",
+	ErrPrunedDisconnect:          "ErrPrunedDisconnect",
 }
 
 // String returns the ErrorCode as a human-readable name.
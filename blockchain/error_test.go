@@ -58,6 +58,9 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPreviousBlockUnknown, "ErrPreviousBlockUnknown"},
 		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
+		{ErrUnknownBlock, "ErrUnknownBlock"},
+		{ErrInvalidateGenesis, "ErrInvalidateGenesis"},
+		{ErrPrunedDisconnect, "ErrPrunedDisconnect"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -0,0 +1,161 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// muHashNumSize is the size in bytes of the numbers MuHash3072
+	// operates on.
+	muHashNumSize = 384
+
+	// muHashPrimeDiff is the difference between 2^3072 and the prime the
+	// numbers are reduced by.
+	muHashPrimeDiff = 1103717
+
+	// serializedMuHashSize is the size of a serialized MuHash3072 state,
+	// which is its numerator followed by its denominator.
+	serializedMuHashSize = 2 * muHashNumSize
+)
+
+var (
+	// muHashPrime is the prime 2^3072 - 1103717 the multiplicative group
+	// of MuHash3072 is defined over.
+	muHashPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1),
+		muHashNumSize*8), big.NewInt(muHashPrimeDiff))
+
+	// muHashMask is the mask used to extract the low 3072 bits of a
+	// number when reducing it.
+	muHashMask = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1),
+		muHashNumSize*8), big.NewInt(1))
+
+	// bigMuHashPrimeDiff is muHashPrimeDiff as a big integer.
+	bigMuHashPrimeDiff = big.NewInt(muHashPrimeDiff)
+)
+
+// muHash3072 is a rolling hash of a set of byte strings as used by bitcoind to
+// commit to the utxo set.  Each element is mapped to a 3072-bit number and the
+// set is represented by the product of the numbers of its elements modulo a
+// prime, which makes it possible to both add and remove elements in any order.
+//
+// The numerator and denominator of the product are kept separately so that
+// removing an element doesn't require a modular inversion.
+type muHash3072 struct {
+	numerator   *big.Int
+	denominator *big.Int
+}
+
+// newMuHash3072 returns a MuHash3072 of the empty set.
+func newMuHash3072() *muHash3072 {
+	return &muHash3072{
+		numerator:   big.NewInt(1),
+		denominator: big.NewInt(1),
+	}
+}
+
+// muHashReduce reduces the passed number, which must be less than the square
+// of the prime, modulo the prime in place and returns it.  Since 2^3072 is
+// congruent to muHashPrimeDiff modulo the prime, the bits above 3072 are
+// folded into the lower bits by multiplying them by muHashPrimeDiff.
+func muHashReduce(n *big.Int) *big.Int {
+	var high big.Int
+	for n.BitLen() > muHashNumSize*8 {
+		high.Rsh(n, muHashNumSize*8)
+		high.Mul(&high, bigMuHashPrimeDiff)
+		n.And(n, muHashMask)
+		n.Add(n, &high)
+	}
+	if n.Cmp(muHashPrime) >= 0 {
+		n.Sub(n, muHashPrime)
+	}
+	return n
+}
+
+// muHashElement maps the passed data to a 3072-bit number by using its SHA256
+// hash as the key of a ChaCha20 keystream and interpreting the first 384 bytes
+// of it as a little-endian number.
+func muHashElement(data []byte) *big.Int {
+	key := sha256.Sum256(data)
+	var nonce [chacha20.NonceSize]byte
+	cipher, err := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	if err != nil {
+		// The key and nonce sizes are always valid.
+		panic(err)
+	}
+	var buf [muHashNumSize]byte
+	cipher.XORKeyStream(buf[:], buf[:])
+
+	// Convert to big endian for big.Int.
+	for i := 0; i < muHashNumSize/2; i++ {
+		buf[i], buf[muHashNumSize-1-i] = buf[muHashNumSize-1-i], buf[i]
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// Add adds the passed element to the set.
+func (h *muHash3072) Add(data []byte) {
+	muHashReduce(h.numerator.Mul(h.numerator, muHashElement(data)))
+}
+
+// Remove removes the passed element from the set.  Elements may be removed
+// before they are added, since only the final product is meaningful.
+func (h *muHash3072) Remove(data []byte) {
+	muHashReduce(h.denominator.Mul(h.denominator, muHashElement(data)))
+}
+
+// Clone returns a copy of the MuHash3072.
+func (h *muHash3072) Clone() *muHash3072 {
+	return &muHash3072{
+		numerator:   new(big.Int).Set(h.numerator),
+		denominator: new(big.Int).Set(h.denominator),
+	}
+}
+
+// Finalize returns the hash committing to the set, which is the SHA256 hash of
+// the little-endian serialization of the product of its elements.
+func (h *muHash3072) Finalize() chainhash.Hash {
+	inverse := new(big.Int).ModInverse(h.denominator, muHashPrime)
+	product := muHashReduce(inverse.Mul(inverse, h.numerator))
+
+	var buf [muHashNumSize]byte
+	product.FillBytes(buf[:])
+	for i := 0; i < muHashNumSize/2; i++ {
+		buf[i], buf[muHashNumSize-1-i] = buf[muHashNumSize-1-i], buf[i]
+	}
+	return chainhash.Hash(sha256.Sum256(buf[:]))
+}
+
+// Serialize returns the state of the MuHash3072 serialized as its big-endian
+// numerator followed by its big-endian denominator.
+func (h *muHash3072) Serialize() []byte {
+	serialized := make([]byte, serializedMuHashSize)
+	h.numerator.FillBytes(serialized[:muHashNumSize])
+	h.denominator.FillBytes(serialized[muHashNumSize:])
+	return serialized
+}
+
+// deserializeMuHash3072 decodes a MuHash3072 state from the passed serialized
+// byte slice.
+func deserializeMuHash3072(serialized []byte) (*muHash3072, error) {
+	if len(serialized) != serializedMuHashSize {
+		return nil, errDeserialize("unexpected muhash state size")
+	}
+	h := &muHash3072{
+		numerator:   new(big.Int).SetBytes(serialized[:muHashNumSize]),
+		denominator: new(big.Int).SetBytes(serialized[muHashNumSize:]),
+	}
+	if h.numerator.Cmp(muHashPrime) >= 0 ||
+		h.denominator.Cmp(muHashPrime) >= 0 || h.denominator.Sign() == 0 {
+
+		return nil, errDeserialize("muhash state is out of range")
+	}
+	return h, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+)
+
+// muHashTestElement returns the 32-byte element used by the bitcoind MuHash3072
+// tests for the passed integer.
+func muHashTestElement(i byte) []byte {
+	element := make([]byte, 32)
+	element[0] = i
+	return element
+}
+
+// TestMuHash3072 ensures MuHash3072 produces the expected hashes and that the
+// order in which elements are added and removed doesn't matter.
+func TestMuHash3072(t *testing.T) {
+	t.Parallel()
+
+	// Test vector from bitcoind.
+	h := newMuHash3072()
+	h.Add(muHashTestElement(0))
+	h.Add(muHashTestElement(1))
+	h.Remove(muHashTestElement(2))
+	want := "10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863"
+	if got := h.Finalize(); got.String() != want {
+		t.Fatalf("unexpected hash: got %v, want %v", got, want)
+	}
+
+	// Adding and removing the same elements in a different order must
+	// result in the same hash, and removing the elements again must result
+	// in the hash of the empty set.
+	empty := newMuHash3072().Finalize()
+	h2 := newMuHash3072()
+	h2.Remove(muHashTestElement(2))
+	h2.Add(muHashTestElement(3))
+	h2.Add(muHashTestElement(1))
+	h2.Add(muHashTestElement(0))
+	h2.Remove(muHashTestElement(3))
+	if h2.Finalize() != h.Finalize() {
+		t.Fatalf("hash depends on the order of the operations")
+	}
+	h2.Remove(muHashTestElement(0))
+	h2.Remove(muHashTestElement(1))
+	h2.Add(muHashTestElement(2))
+	if h2.Finalize() != empty {
+		t.Fatalf("hash of set with all elements removed is not the " +
+			"hash of the empty set")
+	}
+
+	// Serializing and deserializing the state must preserve the hash.
+	h3, err := deserializeMuHash3072(h.Serialize())
+	if err != nil {
+		t.Fatalf("unable to deserialize muhash: %v", err)
+	}
+	if h3.Finalize() != h.Finalize() {
+		t.Fatalf("deserialized muhash has a different hash")
+	}
+	if _, err := deserializeMuHash3072(h.Serialize()[1:]); err == nil {
+		t.Fatalf("deserialized truncated muhash")
+	}
+}
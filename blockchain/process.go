@@ -148,6 +148,19 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 	blockHash := block.Hash()
 	log.Tracef("Processing block %v", blockHash)
 
+	// The blocks before the block the utxo snapshot the best chain was
+	// bootstrapped from is based on are only known by their headers until
+	// they are downloaded for the background chainstate.
+	if node := b.index.LookupNode(blockHash); node != nil &&
+		b.needsBackgroundBlock(node) {
+
+		err := b.processBackgroundBlock(node, block, flags)
+		if err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+
 	// The block must not already exist in the main chain or side chains.
 	exists, err := b.blockExists(blockHash)
 	if err != nil {
@@ -0,0 +1,136 @@
+// Copyright (c) 2013-2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// MinBlocksToKeep is the minimum number of blocks at the tip of the
+	// main chain that are always kept when pruning.  This is the number of
+	// blocks that a node advertising the SFNodeNetworkLimited service flag
+	// must be able to serve as defined by BIP0159 and also ensures the
+	// chain is able to handle all but the deepest of reorganizations.
+	MinBlocksToKeep = 288
+)
+
+// pruneBlocks deletes the oldest block data along with the spend journal
+// entries of the deleted blocks once the total size of the block data exceeds
+// the configured prune target.  The most recent MinBlocksToKeep blocks of the
+// main chain as of the passed node are never deleted.  Neither are the blocks
+// after the passed utxo height, which is the height up to which the utxo set in
+// the database is consistent, since they are needed to reconstruct the utxo set
+// after an unclean shutdown.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneBlocks(dbTx database.Tx, node *blockNode, utxoHeight int32) error {
+	// Nothing to do when pruning is disabled or there are not enough
+	// blocks yet.
+	if b.pruneTarget == 0 || node.height < MinBlocksToKeep {
+		return nil
+	}
+
+	keepHeight := node.height - MinBlocksToKeep + 1
+	if utxoHeight+1 < keepHeight {
+		keepHeight = utxoHeight + 1
+	}
+	keepNode := node.Ancestor(keepHeight)
+	prunedHashes, err := dbTx.PruneBlocks(b.pruneTarget, &keepNode.hash)
+	if err != nil {
+		return err
+	}
+	if len(prunedHashes) == 0 {
+		return nil
+	}
+
+	// The spend journal entries are only needed to disconnect the blocks,
+	// which is no longer possible without the block data, so remove them
+	// as well.  Also, mark the block data as no longer available in the
+	// block index.
+	for i := range prunedHashes {
+		err := dbRemoveSpendJournalEntry(dbTx, &prunedHashes[i])
+		if err != nil {
+			return err
+		}
+
+		if n := b.index.LookupNode(&prunedHashes[i]); n != nil {
+			b.index.UnsetStatusFlags(n, statusDataStored)
+		}
+	}
+
+	log.Debugf("Pruned %d blocks older than height %d", len(prunedHashes),
+		keepNode.height)
+
+	return nil
+}
+
+// checkPrunedDisconnect returns an error when the main chain can't be
+// disconnected down to and including the passed node because the block data
+// needed to do so has been pruned.  Since block data is pruned from the oldest
+// block onwards, the data of all later blocks is available whenever that of
+// the passed node is.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkPrunedDisconnect(node *blockNode) error {
+	if b.pruneTarget == 0 || b.index.NodeStatus(node).HaveData() {
+		return nil
+	}
+
+	return fmt.Errorf("unable to disconnect block %v (height %d) since "+
+		"its data has been pruned -- the chain can only be rewound to "+
+		"blocks that are still stored", node.hash, node.height)
+}
+
+// IsPruned returns whether or not block data has ever been deleted from the
+// database as a result of pruning.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsPruned() (bool, error) {
+	var pruned bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		pruned, err = dbTx.BeenPruned()
+		return err
+	})
+	return pruned, err
+}
+
+// PruneHeight returns the height of the oldest block in the main chain for
+// which the full block data is still available.  This will be zero when the
+// block data has never been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneHeight() (int32, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	// The block data is always deleted from the oldest to the newest, so
+	// the main chain blocks which are still available form a contiguous
+	// range that ends at the tip.  Perform a binary search for the start
+	// of that range.
+	var pruneHeight int32
+	err := b.db.View(func(dbTx database.Tx) error {
+		low, high := int32(0), b.bestChain.Height()
+		for low < high {
+			mid := low + (high-low)/2
+			node := b.bestChain.NodeByHeight(mid)
+			hasBlock, err := dbTx.HasBlock(&node.hash)
+			if err != nil {
+				return err
+			}
+			if hasBlock {
+				high = mid
+			} else {
+				low = mid + 1
+			}
+		}
+		pruneHeight = low
+		return nil
+	})
+	return pruneHeight, err
+}
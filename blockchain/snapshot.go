@@ -0,0 +1,478 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// snapshotVersion is the current version of the utxo snapshot format.
+	snapshotVersion = 1
+
+	// snapshotBatchSize is the number of block headers or utxos which are
+	// written to the database in a single transaction when loading a utxo
+	// snapshot.
+	snapshotBatchSize = 100000
+
+	// maxSnapshotUtxoKeySize is the maximum size of the serialized
+	// outpoint of a utxo in a utxo snapshot, which is a hash followed by
+	// the VLQ encoded output index.
+	maxSnapshotUtxoKeySize = chainhash.HashSize + 5
+)
+
+// snapshotMagic identifies a utxo snapshot file.
+var snapshotMagic = [5]byte{'u', 't', 'x', 'o', 0xff}
+
+// -----------------------------------------------------------------------------
+// A utxo snapshot consists of a header identifying the snapshot, followed by
+// the headers of the blocks after the genesis block up to and including the
+// block the snapshot is based on, and the unspent transaction outputs as of
+// that block:
+//
+//   <magic><version><network><base hash><num headers><headers><num utxos><utxos>
+//
+//   Field          Type                 Size
+//   magic          [5]byte              5 bytes
+//   version        uint16               2 bytes
+//   network        wire.BitcoinNet      4 bytes
+//   base hash      chainhash.Hash       chainhash.HashSize
+//   num headers    uint32               4 bytes
+//   headers        []wire.BlockHeader   80 bytes * num headers
+//   num utxos      uint64               8 bytes
+//   utxos          []utxo               variable
+//
+// Each unspent transaction output is serialized as its key in the utxo set
+// bucket followed by the serialized utxo entry as described in chainio.go, both
+// prefixed by their length encoded as a variable length integer.  The outputs
+// are stored in order of their keys, which is also the order in which the
+// serialized hash of the utxo set commits to them.
+//
+// All numbers are encoded in little-endian byte order.
+// -----------------------------------------------------------------------------
+
+// SnapshotInfo houses information about a utxo snapshot.
+type SnapshotInfo struct {
+	// BaseHash and BaseHeight identify the block the snapshot is based on.
+	BaseHash   chainhash.Hash
+	BaseHeight int32
+
+	// NumUtxos is the number of unspent outputs in the snapshot.
+	NumUtxos uint64
+
+	// HashSerialized is the hash committing to the utxo set of the
+	// snapshot.  It is compatible with the hash_serialized_2 hash of
+	// bitcoind.
+	HashSerialized chainhash.Hash
+
+	// ChainTxCount is the total number of transactions in the chain up to
+	// and including the block the snapshot is based on.
+	ChainTxCount uint64
+}
+
+// DumpUtxoSnapshot writes a utxo snapshot of the utxo set as of the current
+// tip of the best chain to the passed writer.  The snapshot can later be used to
+// bootstrap a chain once an entry for it is added to the AssumeUtxo field of the
+// chain parameters.  The dump can be interrupted by closing the passed channel.
+//
+// The utxo cache is flushed prior to dumping the snapshot and the outputs are
+// read from a consistent snapshot of the database, so blocks can be processed
+// while the snapshot is dumped.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) DumpUtxoSnapshot(w io.Writer,
+	interrupt <-chan struct{}) (*SnapshotInfo, error) {
+
+	b.chainLock.Lock()
+	if err := b.flushUtxoCache(FlushRequired); err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	tip := b.bestChain.Tip()
+	info := &SnapshotInfo{
+		BaseHash:     tip.hash,
+		BaseHeight:   tip.height,
+		ChainTxCount: b.stateSnapshot.TotalTxns,
+	}
+	nodes := make([]*blockNode, tip.height)
+	for node := tip; node.parent != nil; node = node.parent {
+		nodes[node.height-1] = node
+	}
+
+	// The chain lock is released as soon as the database transaction has
+	// been started since it provides a consistent view of the utxo set as
+	// of the tip from then on.
+	locked := true
+	err := b.db.View(func(dbTx database.Tx) error {
+		b.chainLock.Unlock()
+		locked = false
+
+		consistentHash := dbFetchUtxoStateConsistency(dbTx)
+		if consistentHash == nil || *consistentHash != tip.hash {
+			return AssertError("utxo set is not consistent with the " +
+				"best chain after flushing the utxo cache")
+		}
+
+		// Count the unspent outputs since their number precedes them.
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		cursor := utxoBucket.Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			info.NumUtxos++
+		}
+
+		var scratch [8]byte
+		if _, err := w.Write(snapshotMagic[:]); err != nil {
+			return err
+		}
+		byteOrder.PutUint16(scratch[:2], snapshotVersion)
+		if _, err := w.Write(scratch[:2]); err != nil {
+			return err
+		}
+		byteOrder.PutUint32(scratch[:4], uint32(b.chainParams.Net))
+		if _, err := w.Write(scratch[:4]); err != nil {
+			return err
+		}
+		if _, err := w.Write(tip.hash[:]); err != nil {
+			return err
+		}
+		byteOrder.PutUint32(scratch[:4], uint32(len(nodes)))
+		if _, err := w.Write(scratch[:4]); err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			header := node.Header()
+			if err := header.Serialize(w); err != nil {
+				return err
+			}
+		}
+		byteOrder.PutUint64(scratch[:], info.NumUtxos)
+		if _, err := w.Write(scratch[:]); err != nil {
+			return err
+		}
+
+		var numWritten uint64
+		hasher := newSerializedUtxoSetHasher(&tip.hash)
+		err := dbForEachUtxo(dbTx, utxoSetBucketName, func(
+			outpoint wire.OutPoint, entry *UtxoEntry, diskSize int) error {
+
+			if numWritten%utxoSetStatsScanInterval == 0 &&
+				interruptRequested(interrupt) {
+
+				return errInterruptRequested
+			}
+
+			key := outpointKey(outpoint)
+			err := wire.WriteVarBytes(w, 0, *key)
+			recycleOutpointKey(key)
+			if err != nil {
+				return err
+			}
+			serialized, err := serializeUtxoEntry(entry)
+			if err != nil {
+				return err
+			}
+			if err := wire.WriteVarBytes(w, 0, serialized); err != nil {
+				return err
+			}
+
+			hasher.add(outpoint, entry)
+			numWritten++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if numWritten != info.NumUtxos {
+			return AssertError(fmt.Sprintf("wrote %d utxos instead "+
+				"of the %d counted", numWritten, info.NumUtxos))
+		}
+		info.HashSerialized = hasher.sum()
+		return nil
+	})
+	if locked {
+		b.chainLock.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// assumeUtxoForBlock returns the entry of the AssumeUtxo field of the chain
+// parameters for the utxo snapshot based on the block with the passed hash or
+// nil when there is none.
+func (b *BlockChain) assumeUtxoForBlock(hash *chainhash.Hash) *chaincfg.AssumeUtxo {
+	for i := range b.chainParams.AssumeUtxo {
+		assumeUtxo := &b.chainParams.AssumeUtxo[i]
+		if assumeUtxo.BlockHash.IsEqual(hash) {
+			return assumeUtxo
+		}
+	}
+	return nil
+}
+
+// loadUtxoSnapshot bootstraps the chain from the utxo snapshot read from the
+// passed reader.  The headers in the snapshot are validated and added to the
+// block index, and the utxo set of the snapshot replaces the one of the best
+// chain once it has been verified to match the hash listed in the chain
+// parameters.  The block the snapshot is based on becomes the tip of the best
+// chain and a background chainstate is created to validate the chain up to it.
+//
+// The chain must not contain any blocks other than the genesis block.
+func (b *BlockChain) loadUtxoSnapshot(r io.Reader, interrupt <-chan struct{}) error {
+	if b.bgChainState != nil || b.bestChain.Height() != 0 {
+		return AssertError("a utxo snapshot can only be loaded into a " +
+			"database which does not contain any blocks")
+	}
+
+	// Read and validate the header of the snapshot.
+	var magic [len(snapshotMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return fmt.Errorf("unable to read utxo snapshot: %v", err)
+	}
+	if magic != snapshotMagic {
+		return fmt.Errorf("the file is not a utxo snapshot")
+	}
+	var version uint16
+	var network uint32
+	var baseHash chainhash.Hash
+	var numHeaders uint32
+	err := readElements(r, &version, &network, &baseHash, &numHeaders)
+	if err != nil {
+		return fmt.Errorf("unable to read utxo snapshot: %v", err)
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("utxo snapshot version %d is not supported",
+			version)
+	}
+	if wire.BitcoinNet(network) != b.chainParams.Net {
+		return fmt.Errorf("utxo snapshot is for network %v instead of "+
+			"%v", wire.BitcoinNet(network), b.chainParams.Net)
+	}
+	assumeUtxo := b.assumeUtxoForBlock(&baseHash)
+	if assumeUtxo == nil {
+		return fmt.Errorf("utxo snapshot is based on block %v which is "+
+			"not a recognized snapshot block", baseHash)
+	}
+	if numHeaders != uint32(assumeUtxo.Height) {
+		return fmt.Errorf("utxo snapshot contains %d headers instead "+
+			"of %d", numHeaders, assumeUtxo.Height)
+	}
+
+	log.Infof("Loading utxo snapshot based on block %v (height %d)",
+		baseHash, assumeUtxo.Height)
+
+	// Validate the headers and add them to the block index.
+	nodes := make([]*blockNode, 0, numHeaders)
+	prevNode := b.bestChain.Genesis()
+	for i := uint32(0); i < numHeaders; i++ {
+		if i%snapshotBatchSize == 0 && interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		var header wire.BlockHeader
+		if err := header.Deserialize(r); err != nil {
+			return fmt.Errorf("unable to read utxo snapshot: %v", err)
+		}
+		if header.PrevBlock != prevNode.hash {
+			return fmt.Errorf("header %v of the utxo snapshot does "+
+				"not connect to the previous header",
+				header.BlockHash())
+		}
+		err := checkBlockHeaderSanity(&header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return err
+		}
+		err = b.checkBlockHeaderContext(&header, prevNode, BFNone)
+		if err != nil {
+			return err
+		}
+
+		node := newBlockNode(&header, prevNode)
+		node.status = statusAssumedValid
+		b.index.AddNode(node)
+		nodes = append(nodes, node)
+		prevNode = node
+	}
+	baseNode := prevNode
+	if baseNode.hash != baseHash {
+		return fmt.Errorf("the headers of the utxo snapshot end at block "+
+			"%v instead of %v", baseNode.hash, baseHash)
+	}
+
+	// Record that the snapshot is being loaded before modifying the
+	// database, so a database that is left with a partially loaded
+	// snapshot is detected.
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbPutSnapshotChainState(dbTx, &baseHash, snapshotLoading)
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+	for start := 0; start < len(nodes); start += snapshotBatchSize {
+		end := start + snapshotBatchSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		err := b.db.Update(func(dbTx database.Tx) error {
+			for _, node := range nodes[start:end] {
+				err := dbPutBlockIndex(dbTx, &node.hash, node.height)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Load the utxos into the utxo set while computing its hash.  The
+	// utxos are required to be stored in order of their keys, which both
+	// ensures there are no duplicates and that the hash commits to them in
+	// the same order as when it is computed from the database.
+	var numUtxos uint64
+	if err := readElements(r, &numUtxos); err != nil {
+		return fmt.Errorf("unable to read utxo snapshot: %v", err)
+	}
+	hasher := newSerializedUtxoSetHasher(&baseHash)
+	var prevKey []byte
+	for loaded := uint64(0); loaded < numUtxos; {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		err := b.db.Update(func(dbTx database.Tx) error {
+			utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			for i := 0; i < snapshotBatchSize && loaded < numUtxos; i++ {
+				key, err := wire.ReadVarBytes(r, 0,
+					maxSnapshotUtxoKeySize, "utxo key")
+				if err != nil {
+					return fmt.Errorf("unable to read utxo "+
+						"snapshot: %v", err)
+				}
+				serialized, err := wire.ReadVarBytes(r, 0,
+					wire.MaxBlockPayload, "utxo entry")
+				if err != nil {
+					return fmt.Errorf("unable to read utxo "+
+						"snapshot: %v", err)
+				}
+				if bytes.Compare(key, prevKey) <= 0 {
+					return fmt.Errorf("utxos of the utxo " +
+						"snapshot are not in order")
+				}
+				prevKey = key
+
+				if len(key) <= chainhash.HashSize {
+					return fmt.Errorf("utxo key of the utxo " +
+						"snapshot is too short")
+				}
+				var outpoint wire.OutPoint
+				copy(outpoint.Hash[:], key[:chainhash.HashSize])
+				index, size := deserializeVLQ(key[chainhash.HashSize:])
+				if chainhash.HashSize+size != len(key) ||
+					index > uint64(^uint32(0)) {
+
+					return fmt.Errorf("utxo key of the utxo " +
+						"snapshot is malformed")
+				}
+				outpoint.Index = uint32(index)
+				entry, err := deserializeUtxoEntry(serialized)
+				if err != nil {
+					return fmt.Errorf("utxo of the utxo "+
+						"snapshot is malformed: %v", err)
+				}
+				if entry.BlockHeight() > baseNode.height {
+					return fmt.Errorf("utxo %v of the utxo "+
+						"snapshot was created after the block "+
+						"the snapshot is based on", outpoint)
+				}
+
+				if err := utxoBucket.Put(key, serialized); err != nil {
+					return err
+				}
+				hasher.add(outpoint, entry)
+				loaded++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Loaded %d of %d utxos (%.1f%%)", loaded, numUtxos,
+			float64(loaded)*100/float64(numUtxos))
+	}
+	hash := hasher.sum()
+	if hash != *assumeUtxo.HashSerialized {
+		return fmt.Errorf("the hash %v of the utxo set of the utxo "+
+			"snapshot does not match the expected hash %v", hash,
+			assumeUtxo.HashSerialized)
+	}
+
+	// Make the block the snapshot is based on the tip of the best chain and
+	// create the background chainstate which validates the chain up to it
+	// starting from the genesis block.
+	state := newBestState(baseNode, 0, 0, 0, assumeUtxo.ChainTxCount,
+		baseNode.CalcPastMedianTime())
+	genesisHash := b.bestChain.Genesis().hash
+	err = b.db.Update(func(dbTx database.Tx) error {
+		err := dbPutBestState(dbTx, state, baseNode.workSum)
+		if err != nil {
+			return err
+		}
+		if err := dbPutUtxoStateConsistency(dbTx, &baseHash); err != nil {
+			return err
+		}
+
+		meta := dbTx.Metadata()
+		if _, err := meta.CreateBucket(bgUtxoSetBucketName); err != nil {
+			return err
+		}
+		err = meta.Put(bgUtxoStateConsistencyKeyName, genesisHash[:])
+		if err != nil {
+			return err
+		}
+
+		return dbPutSnapshotChainState(dbTx, &baseHash,
+			snapshotValidating)
+	})
+	if err != nil {
+		return err
+	}
+	b.bestChain.SetTip(baseNode)
+	b.stateSnapshot = state
+	b.utxoCache.lastFlushHash = baseHash
+
+	log.Infof("Loaded utxo snapshot with %d utxos based on block %v "+
+		"(height %d)", numUtxos, baseHash, baseNode.height)
+
+	return b.initBackgroundChainState()
+}
+
+// readElements reads the passed fixed size elements from the passed reader
+// using the byte order of the database.
+func readElements(r io.Reader, elements ...interface{}) error {
+	for _, element := range elements {
+		if err := binary.Read(r, byteOrder, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
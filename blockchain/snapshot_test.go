@@ -0,0 +1,444 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// waitForBackgroundChainState waits for the background chainstate of the passed
+// chain to be removed after it connected the block the utxo snapshot is based on
+// and its utxo set was verified.
+func waitForBackgroundChainState(t *testing.T, chain *BlockChain) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(chain.ChainStates()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("background chainstate was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestUtxoSnapshot ensures a chain can be bootstrapped from a utxo snapshot
+// dumped by another chain, that the background chainstate validates the chain
+// up to the snapshot block as its blocks are processed in any order and then
+// retires itself, and that invalid snapshots are rejected.
+func TestUtxoSnapshot(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	srcChain, teardownFunc, err := chainSetup("snapshotsrc",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Dump a snapshot based on the block before the last one.
+	const snapshotHeight = 3
+	srcChain.TstSetCoinbaseMaturity(1)
+	for i := 1; i <= snapshotHeight; i++ {
+		_, _, err := srcChain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	var snapshot bytes.Buffer
+	info, err := srcChain.DumpUtxoSnapshot(&snapshot, nil)
+	if err != nil {
+		t.Fatalf("unable to dump utxo snapshot: %v", err)
+	}
+	wantStats, err := srcChain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+	if info.BaseHash != *blocks[snapshotHeight].Hash() ||
+		info.BaseHeight != snapshotHeight ||
+		info.NumUtxos != uint64(wantStats.TxOuts) ||
+		info.HashSerialized != wantStats.HashSerialized ||
+		info.ChainTxCount != srcChain.BestSnapshot().TotalTxns {
+
+		t.Fatalf("unexpected snapshot info %+v", info)
+	}
+
+	params := chaincfg.MainNetParams
+	params.CoinbaseMaturity = 1
+	params.AssumeUtxo = []chaincfg.AssumeUtxo{{
+		Height:         info.BaseHeight,
+		BlockHash:      &info.BaseHash,
+		HashSerialized: &info.HashSerialized,
+		ChainTxCount:   info.ChainTxCount,
+	}}
+
+	// loadChain creates a chain instance for the passed database which is
+	// bootstrapped from the passed snapshot when it is not nil.
+	loadChain := func(db database.DB, params *chaincfg.Params,
+		snapshot []byte) (*BlockChain, error) {
+
+		config := &Config{
+			DB:          db,
+			ChainParams: params,
+			TimeSource:  NewMedianTime(),
+			SigCache:    txscript.NewSigCache(1000),
+		}
+		if snapshot != nil {
+			config.Snapshot = bytes.NewReader(snapshot)
+		}
+		return New(config)
+	}
+
+	// createDB creates a new database for a chain instance which is closed
+	// when the test finishes.
+	createDB := func(dbName string) database.DB {
+		t.Helper()
+
+		dbPath := filepath.Join(t.TempDir(), dbName)
+		db, err := database.Create(testDbType, dbPath, blockDataNet)
+		if err != nil {
+			t.Fatalf("error creating db: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	// The snapshot must be rejected when it doesn't match the hash in the
+	// chain parameters or isn't listed there at all.
+	badParams := params
+	badParams.AssumeUtxo = []chaincfg.AssumeUtxo{params.AssumeUtxo[0]}
+	badParams.AssumeUtxo[0].HashSerialized = &chainhash.Hash{0x01}
+	badDB := createDB("snapshotbad")
+	_, err = loadChain(badDB, &chaincfg.MainNetParams, snapshot.Bytes())
+	if err == nil {
+		t.Fatalf("loaded snapshot which is not listed in the params")
+	}
+	_, err = loadChain(badDB, &badParams, snapshot.Bytes())
+	if err == nil {
+		t.Fatalf("loaded snapshot with a mismatched hash")
+	}
+
+	// The snapshot can't be loaded into a chain which has blocks.
+	_, err = loadChain(srcChain.db, &params, snapshot.Bytes())
+	if err == nil {
+		t.Fatalf("loaded snapshot into a chain with blocks")
+	}
+
+	dstDB := createDB("snapshotdst")
+	chain, err := loadChain(dstDB, &params, snapshot.Bytes())
+	if err != nil {
+		t.Fatalf("unable to load snapshot: %v", err)
+	}
+
+	// The best chain must be at the snapshot block with the utxo set of
+	// the snapshot.
+	best := chain.BestSnapshot()
+	if best.Hash != info.BaseHash || best.Height != info.BaseHeight ||
+		best.TotalTxns != info.ChainTxCount {
+
+		t.Fatalf("unexpected best state after loading snapshot %+v",
+			best)
+	}
+	stats, err := chain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+	if *stats != *wantStats {
+		t.Fatalf("unexpected utxo set stats after loading snapshot "+
+			"%+v, want %+v", stats, wantStats)
+	}
+
+	checkChainStates := func(bgHeight int32) {
+		t.Helper()
+
+		chainStates := chain.ChainStates()
+		tip := blocks[len(blocks)-1]
+		if chain.BestSnapshot().Hash != *tip.Hash() {
+			tip = blocks[snapshotHeight]
+		}
+		if bgHeight == snapshotHeight {
+			if len(chainStates) != 1 || !chainStates[0].Validated ||
+				chainStates[0].SnapshotHash != nil ||
+				chainStates[0].Hash != *tip.Hash() {
+
+				t.Fatalf("unexpected chainstates after validating "+
+					"snapshot %+v", chainStates)
+			}
+			return
+		}
+		if len(chainStates) != 2 {
+			t.Fatalf("unexpected number of chainstates %d",
+				len(chainStates))
+		}
+		bg, active := chainStates[0], chainStates[1]
+		if bg.Height != bgHeight || bg.Hash != *blocks[bgHeight].Hash() ||
+			!bg.Validated || bg.SnapshotHash != nil ||
+			bg.Progress != float64(bgHeight)/snapshotHeight {
+
+			t.Fatalf("unexpected background chainstate %+v", bg)
+		}
+		if active.Hash != *tip.Hash() || active.Validated ||
+			active.SnapshotHash == nil ||
+			*active.SnapshotHash != info.BaseHash {
+
+			t.Fatalf("unexpected active chainstate %+v", active)
+		}
+	}
+	checkMissing := func(want ...int) {
+		t.Helper()
+
+		var wantHashes []*chainhash.Hash
+		for _, height := range want {
+			wantHashes = append(wantHashes, blocks[height].Hash())
+		}
+		missing := chain.MissingBackgroundBlocks(10)
+		if !reflect.DeepEqual(missing, wantHashes) {
+			t.Fatalf("unexpected missing background blocks %v, "+
+				"want %v", missing, wantHashes)
+		}
+	}
+	// checkAssumedValid ensures the blocks up to the passed height are
+	// fully validated while the ones after it up to the snapshot block
+	// are only assumed to be valid.
+	checkAssumedValid := func(validHeight int) {
+		t.Helper()
+
+		for height := 1; height <= snapshotHeight; height++ {
+			node := chain.index.LookupNode(blocks[height].Hash())
+			status := chain.index.NodeStatus(node)
+			valid := height <= validHeight
+			if status.KnownValid() != valid ||
+				status.AssumedValid() == valid {
+
+				t.Fatalf("unexpected status %v of block at height "+
+					"%d", status, height)
+			}
+		}
+	}
+	checkChainStates(0)
+	checkMissing(1, 2, 3)
+	checkAssumedValid(0)
+
+	// Blocks after the snapshot block extend the best chain.
+	isMainChain, _, err := chain.ProcessBlock(blocks[4], BFNone)
+	if err != nil || !isMainChain {
+		t.Fatalf("unable to extend the snapshot chain: %v", err)
+	}
+	checkChainStates(0)
+
+	// The background chainstate can only connect blocks in order, but they
+	// are stored in any order.
+	if _, _, err := chain.ProcessBlock(blocks[2], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 2: %v\n", err)
+	}
+	checkChainStates(0)
+	checkMissing(1, 3)
+	if _, _, err := chain.ProcessBlock(blocks[2], BFNone); err == nil {
+		t.Fatalf("processed background block twice")
+	}
+	if _, _, err := chain.ProcessBlock(blocks[1], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 1: %v\n", err)
+	}
+	checkChainStates(2)
+	checkMissing(3)
+	checkAssumedValid(2)
+
+	// The state of the background chainstate must be persisted.
+	if err := chain.FlushUtxoCache(FlushRequired); err != nil {
+		t.Fatalf("unable to flush utxo cache: %v", err)
+	}
+	chain, err = loadChain(chain.db, &params, nil)
+	if err != nil {
+		t.Fatalf("unable to reload chain: %v", err)
+	}
+	checkChainStates(2)
+	checkAssumedValid(2)
+
+	// Pruning can't be enabled before the snapshot has been validated.
+	_, err = New(&Config{
+		DB:          chain.db,
+		ChainParams: &params,
+		TimeSource:  NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+		Prune:       1,
+	})
+	if err == nil {
+		t.Fatalf("enabled pruning before the snapshot was validated")
+	}
+
+	// Connecting the snapshot block validates the snapshot, which retires
+	// the background chainstate.
+	if _, _, err := chain.ProcessBlock(blocks[3], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 3: %v\n", err)
+	}
+	waitForBackgroundChainState(t, chain)
+	checkChainStates(snapshotHeight)
+	checkMissing()
+	checkAssumedValid(snapshotHeight)
+	err = chain.db.View(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Bucket(bgUtxoSetBucketName) != nil {
+			t.Fatalf("background utxo set was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to view database: %v", err)
+	}
+	chain, err = loadChain(chain.db, &params, nil)
+	if err != nil {
+		t.Fatalf("unable to reload chain: %v", err)
+	}
+	checkChainStates(snapshotHeight)
+}
+
+// TestInvalidUtxoSnapshot ensures the best chain falls back to the background
+// chainstate when the utxo set it validated doesn't match the utxo snapshot the
+// chain was bootstrapped from and that the blocks after the snapshot block are
+// validated again using the utxo set of the background chainstate.
+func TestInvalidUtxoSnapshot(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	srcChain, teardownFunc, err := chainSetup("invalidsnapshotsrc",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	const snapshotHeight = 3
+	srcChain.TstSetCoinbaseMaturity(1)
+	for i := 1; i <= snapshotHeight; i++ {
+		_, _, err := srcChain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	var snapshot bytes.Buffer
+	info, err := srcChain.DumpUtxoSnapshot(&snapshot, nil)
+	if err != nil {
+		t.Fatalf("unable to dump utxo snapshot: %v", err)
+	}
+	if _, _, err := srcChain.ProcessBlock(blocks[4], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 4: %v\n", err)
+	}
+	wantStats, err := srcChain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+
+	params := chaincfg.MainNetParams
+	params.CoinbaseMaturity = 1
+	params.AssumeUtxo = []chaincfg.AssumeUtxo{{
+		Height:         info.BaseHeight,
+		BlockHash:      &info.BaseHash,
+		HashSerialized: &info.HashSerialized,
+		ChainTxCount:   info.ChainTxCount,
+	}}
+
+	dbPath := filepath.Join(t.TempDir(), "invalidsnapshotdst")
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer db.Close()
+	chain, err := New(&Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+		Snapshot:    bytes.NewReader(snapshot.Bytes()),
+	})
+	if err != nil {
+		t.Fatalf("unable to load snapshot: %v", err)
+	}
+	if _, _, err := chain.ProcessBlock(blocks[4], BFNone); err != nil {
+		t.Fatalf("unable to extend the snapshot chain: %v", err)
+	}
+
+	// Change the expected hash of the snapshot once it has been loaded so
+	// the background chainstate finds it to be invalid once it connects
+	// the snapshot block.
+	params.AssumeUtxo[0].HashSerialized = &chainhash.Hash{0x01}
+	for i := 1; i <= snapshotHeight; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// The best chain must have fallen back to the background chainstate
+	// and validated the block after the snapshot block again.
+	waitForBackgroundChainState(t, chain)
+	chainStates := chain.ChainStates()
+	if len(chainStates) != 1 || !chainStates[0].Validated ||
+		chainStates[0].Hash != *blocks[4].Hash() {
+
+		t.Fatalf("unexpected chainstates after falling back %+v",
+			chainStates)
+	}
+	best := chain.BestSnapshot()
+	if best.Hash != *blocks[4].Hash() ||
+		best.TotalTxns != srcChain.BestSnapshot().TotalTxns {
+
+		t.Fatalf("unexpected best state after falling back %+v", best)
+	}
+	for i := 1; i < len(blocks); i++ {
+		node := chain.index.LookupNode(blocks[i].Hash())
+		if !chain.index.NodeStatus(node).KnownValid() {
+			t.Fatalf("block at height %d is not valid", i)
+		}
+	}
+	stats, err := chain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+	if *stats != *wantStats {
+		t.Fatalf("unexpected utxo set stats after falling back %+v, "+
+			"want %+v", stats, wantStats)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Bucket(bgUtxoSetBucketName) != nil {
+			t.Fatalf("background utxo set was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to view database: %v", err)
+	}
+
+	// The chain must no longer have a background chainstate once it is
+	// loaded again.
+	if err := chain.FlushUtxoCache(FlushRequired); err != nil {
+		t.Fatalf("unable to flush utxo cache: %v", err)
+	}
+	chain, err = New(&Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("unable to reload chain: %v", err)
+	}
+	chainStates = chain.ChainStates()
+	if len(chainStates) != 1 || chainStates[0].Hash != *blocks[4].Hash() {
+		t.Fatalf("unexpected chainstates after reloading %+v",
+			chainStates)
+	}
+}
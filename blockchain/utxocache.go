@@ -0,0 +1,498 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// utxoFlushPeriodicInterval is the amount of time after the last flush
+	// at which a periodic flush of the utxo cache will be performed.
+	utxoFlushPeriodicInterval = time.Minute * 5
+
+	// utxoEntryOverhead is the approximate number of bytes used by each
+	// cached utxo entry excluding its public key script.  It accounts for
+	// the outpoint map key, the entry itself, the pointer to it, and the
+	// map bookkeeping.
+	utxoEntryOverhead = 100
+)
+
+// FlushMode is used to indicate the different urgency types for a flush of the
+// utxo cache.
+type FlushMode uint8
+
+const (
+	// FlushRequired is the flush mode that means a flush must be performed
+	// regardless of the cache state.  For example, right before shutting
+	// down.
+	FlushRequired FlushMode = iota
+
+	// FlushPeriodic is the flush mode that means a flush will be performed
+	// when the cache is full or when enough time has passed since the last
+	// flush.  This limits the amount of work required to recover from an
+	// unclean shutdown.
+	FlushPeriodic
+
+	// FlushIfNeeded is the flush mode that means a flush will only be
+	// performed when the cache exceeds its maximum size.
+	FlushIfNeeded
+)
+
+// UtxoCacheStats houses statistics about the current state of the utxo cache.
+type UtxoCacheStats struct {
+	// Entries is the number of utxo entries in the cache including entries
+	// that mark spent outputs which are yet to be removed from the
+	// database.
+	Entries uint64
+
+	// Size is the approximate memory used by the cached entries in bytes.
+	Size uint64
+
+	// MaxSize is the maximum size of the cache in bytes before it is
+	// flushed to the database.
+	MaxSize uint64
+
+	// Hits and Misses are the number of lookups that were respectively
+	// satisfied by the cache and that needed to consult the database.
+	Hits   uint64
+	Misses uint64
+
+	// Flushes is the number of times the cache has been flushed to the
+	// database.
+	Flushes uint64
+
+	// LastFlushHash and LastFlushTime are the hash of the best block and
+	// the time of the most recent flush.
+	LastFlushHash chainhash.Hash
+	LastFlushTime time.Time
+}
+
+// utxoCache is a write-back cache of the unspent transaction output set that
+// sits between the utxo views used when connecting blocks and the database.
+//
+// Entries that are loaded from the database are cached unmodified, while the
+// modifications made by connected blocks are accumulated in the cache and only
+// written to the database when the cache is flushed.  Outputs that are created
+// and spent again between flushes never touch the database at all.
+//
+// The database records the hash of the block up to which the utxo set it
+// contains is consistent.  That hash will trail the best chain after an
+// unclean shutdown, in which case the missing blocks are replayed on startup.
+type utxoCache struct {
+	db      database.DB
+	maxSize uint64
+
+	// bucketName and consistencyKeyName are the names of the db bucket
+	// that houses the utxo set and the db key that stores the hash of the
+	// block it is consistent with.
+	bucketName         []byte
+	consistencyKeyName []byte
+
+	// mtx protects all of the fields below.  The cache is also protected
+	// by the chain lock, however lookups only hold it for reads, so the
+	// additional mutex is required to safely add the entries they load.
+	mtx           sync.Mutex
+	entries       map[wire.OutPoint]*UtxoEntry
+	totalSize     uint64
+	hits          uint64
+	misses        uint64
+	flushes       uint64
+	lastFlushHash chainhash.Hash
+	lastFlushTime time.Time
+}
+
+// newUtxoCache returns a new utxo cache backed by the provided database that
+// will be flushed once its size exceeds maxSize bytes.
+func newUtxoCache(db database.DB, maxSize uint64) *utxoCache {
+	return newUtxoCacheForBucket(db, maxSize, utxoSetBucketName,
+		utxoStateConsistencyKeyName)
+}
+
+// newUtxoCacheForBucket returns a new utxo cache like newUtxoCache, but for the
+// utxo set housed in the db bucket with the passed name, which is consistent
+// with the block whose hash is stored under the passed db key.
+func newUtxoCacheForBucket(db database.DB, maxSize uint64, bucketName,
+	consistencyKeyName []byte) *utxoCache {
+
+	return &utxoCache{
+		db:                 db,
+		maxSize:            maxSize,
+		bucketName:         bucketName,
+		consistencyKeyName: consistencyKeyName,
+		entries:            make(map[wire.OutPoint]*UtxoEntry),
+		lastFlushTime:      time.Now(),
+	}
+}
+
+// entrySize returns the approximate number of bytes of memory used by the
+// passed entry when it is stored in the cache.
+func entrySize(entry *UtxoEntry) uint64 {
+	return utxoEntryOverhead + uint64(len(entry.pkScript))
+}
+
+// setEntry stores the passed entry in the cache while keeping the total size
+// up to date.
+//
+// This function MUST be called with the cache lock held.
+func (c *utxoCache) setEntry(outpoint wire.OutPoint, entry *UtxoEntry) {
+	if cached, ok := c.entries[outpoint]; ok {
+		c.totalSize -= entrySize(cached)
+	}
+	c.entries[outpoint] = entry
+	c.totalSize += entrySize(entry)
+}
+
+// removeEntry removes the passed entry from the cache while keeping the total
+// size up to date.
+//
+// This function MUST be called with the cache lock held.
+func (c *utxoCache) removeEntry(outpoint wire.OutPoint, entry *UtxoEntry) {
+	delete(c.entries, outpoint)
+	c.totalSize -= entrySize(entry)
+}
+
+// fetchEntries adds copies of the entries for the requested outpoints to the
+// passed map.  Entries that are not in the cache are loaded from the database
+// and added to the cache.  Outputs which are spent, or otherwise don't exist,
+// result in a nil entry.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) fetchEntries(entries map[wire.OutPoint]*UtxoEntry,
+	outpoints map[wire.OutPoint]struct{}) error {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var missing []wire.OutPoint
+	for outpoint := range outpoints {
+		cached, ok := c.entries[outpoint]
+		if !ok {
+			missing = append(missing, outpoint)
+			continue
+		}
+
+		c.hits++
+		if cached.IsSpent() {
+			entries[outpoint] = nil
+			continue
+		}
+
+		// The state of the entry relative to the database is only
+		// tracked by the cache, so the copy is neither modified nor
+		// fresh from the point of view of the caller.
+		entry := cached.Clone()
+		entry.packedFlags &^= tfModified | tfFresh
+		entries[outpoint] = entry
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	c.misses += uint64(len(missing))
+	return c.db.View(func(dbTx database.Tx) error {
+		for _, outpoint := range missing {
+			entry, err := dbFetchUtxoEntryFromBucket(dbTx,
+				c.bucketName, outpoint)
+			if err != nil {
+				return err
+			}
+
+			entries[outpoint] = entry
+			if entry != nil {
+				c.setEntry(outpoint, entry.Clone())
+			}
+		}
+
+		return nil
+	})
+}
+
+// commit applies the modified entries of the passed view to the cache.  The
+// changes are not written to the database until the cache is flushed.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) commit(view *UtxoViewpoint) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for outpoint, entry := range view.entries {
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+
+		cached := c.entries[outpoint]
+		if entry.IsSpent() {
+			switch {
+			// The output only exists in the cache, so it can simply
+			// be forgotten.
+			case cached != nil && cached.isFresh():
+				c.removeEntry(outpoint, cached)
+
+			// The output was created and spent without ever being
+			// added to the cache or the database.
+			case cached == nil && entry.isFresh():
+
+			// Keep a spent entry around so the output is removed from
+			// the database on the next flush.
+			default:
+				c.setEntry(outpoint, &UtxoEntry{
+					packedFlags: tfSpent | tfModified,
+				})
+			}
+			continue
+		}
+
+		// The output is only known to not exist in the database when it
+		// was either created after the last flush or never seen before
+		// and known to be new.
+		fresh := (cached == nil && entry.isFresh()) ||
+			(cached != nil && cached.isFresh())
+		flags := entry.packedFlags&tfCoinBase | tfModified
+		if fresh {
+			flags |= tfFresh
+		}
+		pkScript := make([]byte, len(entry.pkScript))
+		copy(pkScript, entry.pkScript)
+		c.setEntry(outpoint, &UtxoEntry{
+			amount:      entry.amount,
+			pkScript:    pkScript,
+			blockHeight: entry.blockHeight,
+			packedFlags: flags,
+		})
+	}
+}
+
+// needsFlush returns whether or not the cache needs to be flushed according to
+// the passed mode after the modifications in the passed view, which may be nil,
+// are applied to it.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) needsFlush(mode FlushMode, view *UtxoViewpoint) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	switch mode {
+	case FlushRequired:
+		return true
+
+	case FlushPeriodic:
+		if time.Since(c.lastFlushTime) > utxoFlushPeriodicInterval {
+			return true
+		}
+	}
+
+	size := c.totalSize
+	if view != nil {
+		for _, entry := range view.entries {
+			if entry != nil && entry.isModified() {
+				size += entrySize(entry)
+			}
+		}
+	}
+	return size > c.maxSize
+}
+
+// flush uses an existing database transaction to write all of the modified
+// entries in the cache followed by the modified entries in the passed view,
+// which may be nil, to the database.  The passed hash is recorded as the block
+// up to which the utxo set in the database is consistent.
+//
+// The cache is not reset until markFlushed is called once the database
+// transaction has been successfully committed.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) flush(dbTx database.Tx, view *UtxoViewpoint, hash *chainhash.Hash) error {
+	c.mtx.Lock()
+	err := dbPutUtxoEntriesToBucket(dbTx, c.bucketName, c.entries)
+	c.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if view != nil {
+		err := dbPutUtxoEntriesToBucket(dbTx, c.bucketName, view.entries)
+		if err != nil {
+			return err
+		}
+	}
+
+	return dbTx.Metadata().Put(c.consistencyKeyName, hash[:])
+}
+
+// markFlushed resets the cache after its contents have been successfully
+// written to the database as of the passed block hash.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) markFlushed(hash *chainhash.Hash) {
+	c.mtx.Lock()
+	c.entries = make(map[wire.OutPoint]*UtxoEntry)
+	c.totalSize = 0
+	c.flushes++
+	c.lastFlushHash = *hash
+	c.lastFlushTime = time.Now()
+	c.mtx.Unlock()
+}
+
+// flushUtxoCache writes the utxo cache to the database as of the current best
+// chain tip when required by the passed mode.  The utxo cache of the background
+// chainstate, if any, is flushed as well.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) flushUtxoCache(mode FlushMode) error {
+	if err := b.flushBackgroundUtxoCache(mode); err != nil {
+		return err
+	}
+	if !b.utxoCache.needsFlush(mode, nil) {
+		return nil
+	}
+
+	tip := b.bestChain.Tip()
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return b.utxoCache.flush(dbTx, nil, &tip.hash)
+	})
+	if err != nil {
+		return err
+	}
+	b.utxoCache.markFlushed(&tip.hash)
+
+	log.Debugf("Flushed utxo cache at height %d", tip.height)
+	return nil
+}
+
+// FlushUtxoCache writes the contents of the utxo cache to the database when
+// required by the passed mode.  It should be called with FlushRequired before
+// shutting down in order to avoid replaying blocks on the next startup.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FlushUtxoCache(mode FlushMode) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	return b.flushUtxoCache(mode)
+}
+
+// UtxoCacheStats returns statistics about the current state of the utxo cache.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoCacheStats() *UtxoCacheStats {
+	c := b.utxoCache
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return &UtxoCacheStats{
+		Entries:       uint64(len(c.entries)),
+		Size:          c.totalSize,
+		MaxSize:       c.maxSize,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Flushes:       c.flushes,
+		LastFlushHash: c.lastFlushHash,
+		LastFlushTime: c.lastFlushTime,
+	}
+}
+
+// lastFlushHeight returns the height of the block up to which the utxo set in
+// the database is consistent.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) lastFlushHeight() int32 {
+	b.utxoCache.mtx.Lock()
+	hash := b.utxoCache.lastFlushHash
+	b.utxoCache.mtx.Unlock()
+
+	node := b.index.LookupNode(&hash)
+	if node == nil {
+		return b.bestChain.Height()
+	}
+	return node.height
+}
+
+// initUtxoCache ensures the utxo set in the database is consistent with the
+// best chain.  When it is not, which happens when the utxo cache was not
+// flushed prior to an unclean shutdown, the blocks after the point the utxo set
+// is consistent with are replayed in order to reconstruct it.
+func (b *BlockChain) initUtxoCache(interrupt <-chan struct{}) error {
+	var consistentHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		consistentHash = dbFetchUtxoStateConsistency(dbTx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The utxo set of databases created prior to the introduction of the
+	// utxo cache is always consistent with the best chain, so simply store
+	// the consistency state in that case.
+	tip := b.bestChain.Tip()
+	if consistentHash == nil {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbPutUtxoStateConsistency(dbTx, &tip.hash)
+		})
+		if err != nil {
+			return err
+		}
+		consistentHash = &tip.hash
+	}
+	b.utxoCache.lastFlushHash = *consistentHash
+	if *consistentHash == tip.hash {
+		return nil
+	}
+
+	node := b.index.LookupNode(consistentHash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return AssertError(fmt.Sprintf("utxo set is consistent with block "+
+			"%v which is not in the main chain", consistentHash))
+	}
+
+	log.Infof("Reconstructing the utxo set from height %d to %d after an "+
+		"unclean shutdown", node.height+1, tip.height)
+
+	for n := b.bestChain.Next(node); n != nil; n = b.bestChain.Next(n) {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		var block *btcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, n)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		view := NewUtxoViewpoint()
+		if err := view.fetchInputUtxos(b.utxoCache, block); err != nil {
+			return err
+		}
+		if err := view.connectTransactions(block, nil); err != nil {
+			return err
+		}
+
+		if !b.utxoCache.needsFlush(FlushIfNeeded, view) {
+			b.utxoCache.commit(view)
+			continue
+		}
+		err = b.db.Update(func(dbTx database.Tx) error {
+			return b.utxoCache.flush(dbTx, view, &n.hash)
+		})
+		if err != nil {
+			return err
+		}
+		b.utxoCache.markFlushed(&n.hash)
+	}
+
+	return b.flushUtxoCache(FlushRequired)
+}
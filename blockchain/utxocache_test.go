@@ -0,0 +1,267 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dbHasUtxo returns whether or not the utxo set in the database contains an
+// unspent entry for the passed outpoint.
+func dbHasUtxo(t *testing.T, db database.DB, outpoint wire.OutPoint) bool {
+	t.Helper()
+
+	var entry *UtxoEntry
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo %v: %v", outpoint, err)
+	}
+	return entry != nil
+}
+
+// TestUtxoCacheCommit ensures the utxo cache correctly tracks the state of the
+// entries committed to it relative to the database and only writes the
+// necessary modifications when flushed.
+func TestUtxoCacheCommit(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("utxocachecommit",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	cache := newUtxoCache(chain.db, 1<<20)
+	txOut := wire.NewTxOut(5000, []byte{txscript.OP_TRUE})
+	dbOutpoint := wire.OutPoint{Hash: chainhash.Hash{0x01}}
+	freshOutpoint := wire.OutPoint{Hash: chainhash.Hash{0x02}}
+	keptOutpoint := wire.OutPoint{Hash: chainhash.Hash{0x03}}
+	hash := chainhash.Hash{0xff}
+
+	// Store an output directly in the database.
+	view := NewUtxoViewpoint()
+	view.addTxOut(dbOutpoint, txOut, false, 1)
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoView(dbTx, view)
+	})
+	if err != nil {
+		t.Fatalf("unable to store utxo: %v", err)
+	}
+
+	// Create two new outputs and commit them to the cache.  Neither of them
+	// should be written to the database yet.
+	view = NewUtxoViewpoint()
+	view.addTxOut(freshOutpoint, txOut, false, 2)
+	view.addTxOut(keptOutpoint, txOut, false, 2)
+	cache.commit(view)
+	view.commit()
+	if dbHasUtxo(t, chain.db, freshOutpoint) {
+		t.Fatal("committed utxo unexpectedly written to the database")
+	}
+	if cache.totalSize != 2*entrySize(view.LookupEntry(freshOutpoint)) {
+		t.Fatalf("unexpected cache size %d", cache.totalSize)
+	}
+
+	// Spend the output in the database along with one of the new outputs.
+	view = NewUtxoViewpoint()
+	err = view.fetchUtxosMain(cache, map[wire.OutPoint]struct{}{
+		dbOutpoint:    {},
+		freshOutpoint: {},
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxos: %v", err)
+	}
+	if cache.hits != 1 || cache.misses != 1 {
+		t.Fatalf("unexpected cache hits %d and misses %d", cache.hits,
+			cache.misses)
+	}
+	for _, outpoint := range []wire.OutPoint{dbOutpoint, freshOutpoint} {
+		entry := view.LookupEntry(outpoint)
+		if entry == nil {
+			t.Fatalf("missing utxo %v", outpoint)
+		}
+		entry.Spend()
+	}
+	cache.commit(view)
+
+	// The spent fresh output must be forgotten while the output from the
+	// database must be kept as spent so it is removed on flush.
+	if _, ok := cache.entries[freshOutpoint]; ok {
+		t.Fatal("spent fresh utxo is still cached")
+	}
+	if entry, ok := cache.entries[dbOutpoint]; !ok || !entry.IsSpent() {
+		t.Fatal("spent database utxo is not cached as spent")
+	}
+
+	// Flush the cache and ensure the database reflects all of the changes.
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return cache.flush(dbTx, nil, &hash)
+	})
+	if err != nil {
+		t.Fatalf("unable to flush cache: %v", err)
+	}
+	cache.markFlushed(&hash)
+	if dbHasUtxo(t, chain.db, dbOutpoint) {
+		t.Fatal("spent utxo still exists in the database")
+	}
+	if dbHasUtxo(t, chain.db, freshOutpoint) {
+		t.Fatal("spent fresh utxo was written to the database")
+	}
+	if !dbHasUtxo(t, chain.db, keptOutpoint) {
+		t.Fatal("unspent utxo was not written to the database")
+	}
+	if len(cache.entries) != 0 || cache.totalSize != 0 || cache.flushes != 1 {
+		t.Fatal("cache was not reset after flushing")
+	}
+
+	var consistentHash *chainhash.Hash
+	chain.db.View(func(dbTx database.Tx) error {
+		consistentHash = dbFetchUtxoStateConsistency(dbTx)
+		return nil
+	})
+	if consistentHash == nil || *consistentHash != hash {
+		t.Fatalf("unexpected utxo consistency hash %v", consistentHash)
+	}
+}
+
+// TestUtxoCacheReplay ensures the utxo set is reconstructed when the chain is
+// loaded after the utxo cache was not flushed.
+func TestUtxoCacheReplay(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxocachereplay",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	// Use a cache large enough to never be flushed while processing the
+	// blocks.
+	chain.utxoCache = newUtxoCache(chain.db, 1<<20)
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// The coinbase output of the tip block must only exist in the cache.
+	tipBlock := blocks[len(blocks)-1]
+	tipOutpoint := wire.OutPoint{Hash: *tipBlock.Transactions()[0].Hash()}
+	if dbHasUtxo(t, chain.db, tipOutpoint) {
+		t.Fatal("coinbase utxo unexpectedly written to the database")
+	}
+	entry, err := chain.FetchUtxoEntry(tipOutpoint)
+	if err != nil || entry == nil {
+		t.Fatalf("unable to fetch cached utxo: %v", err)
+	}
+	stats := chain.UtxoCacheStats()
+	if stats.Flushes != 0 || stats.Entries == 0 {
+		t.Fatalf("unexpected cache stats %+v", stats)
+	}
+
+	// Load the chain again from the same database without flushing the
+	// cache and ensure the utxo set was reconstructed.
+	paramsCopy := chaincfg.MainNetParams
+	chain, err = New(&Config{
+		DB:          chain.db,
+		ChainParams: &paramsCopy,
+		TimeSource:  NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("failed to create chain instance: %v", err)
+	}
+	if !dbHasUtxo(t, chain.db, tipOutpoint) {
+		t.Fatal("coinbase utxo of the tip block was not restored")
+	}
+	stats = chain.UtxoCacheStats()
+	if stats.LastFlushHash != *tipBlock.Hash() {
+		t.Fatalf("unexpected last flush hash %v", stats.LastFlushHash)
+	}
+}
+
+// TestScanUtxoSet ensures scanning the utxo set visits the outputs in both the
+// database and the utxo cache, and stops when requested.
+func TestScanUtxoSet(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("scanutxoset",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	txOut := wire.NewTxOut(5000, []byte{txscript.OP_TRUE})
+	dbOutpoint := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 200}
+	cachedOutpoint := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 1}
+
+	// Store an output directly in the database and another one in the
+	// cache only.
+	view := NewUtxoViewpoint()
+	view.addTxOut(dbOutpoint, txOut, false, 1)
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoView(dbTx, view)
+	})
+	if err != nil {
+		t.Fatalf("unable to store utxo: %v", err)
+	}
+	view = NewUtxoViewpoint()
+	view.addTxOut(cachedOutpoint, txOut, true, 2)
+	chain.utxoCache.commit(view)
+
+	var outpoints []wire.OutPoint
+	hash, height, err := chain.ScanUtxoSet(func(outpoint wire.OutPoint,
+		entry *UtxoEntry) error {
+
+		if entry.Amount() != txOut.Value {
+			t.Fatalf("unexpected amount %d", entry.Amount())
+		}
+		outpoints = append(outpoints, outpoint)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to scan utxo set: %v", err)
+	}
+	tip := chain.BestSnapshot()
+	if *hash != tip.Hash || height != tip.Height {
+		t.Fatalf("unexpected scan block %v (%d), want %v (%d)", hash,
+			height, tip.Hash, tip.Height)
+	}
+	if len(outpoints) != 2 || outpoints[0] != dbOutpoint ||
+		outpoints[1] != cachedOutpoint {
+
+		t.Fatalf("unexpected scanned outpoints %v", outpoints)
+	}
+
+	// Errors returned by the function stop the scan.
+	errStop := errors.New("stop")
+	var visited int
+	_, _, err = chain.ScanUtxoSet(func(wire.OutPoint, *UtxoEntry) error {
+		visited++
+		return errStop
+	})
+	if err != errStop || visited != 1 {
+		t.Fatalf("unexpected error %v after visiting %d outputs", err,
+			visited)
+	}
+}
@@ -0,0 +1,479 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// UtxoSetHashType identifies the hash committing to the utxo set that is
+// computed by FetchUtxoSetStats.
+type UtxoSetHashType uint8
+
+const (
+	// UtxoSetHashNone indicates no hash of the utxo set is computed.
+	UtxoSetHashNone UtxoSetHashType = iota
+
+	// UtxoSetHashSerialized indicates the double SHA256 hash of the
+	// serialized utxo set is computed.  It is compatible with the
+	// hash_serialized_2 hash of bitcoind.
+	UtxoSetHashSerialized
+
+	// UtxoSetHashMuHash indicates the MuHash3072 of the utxo set is
+	// computed.  It is compatible with the muhash hash of bitcoind.
+	UtxoSetHashMuHash
+)
+
+// utxoSetStatsScanInterval is the number of utxos that are scanned in between
+// checks for whether an interrupt has been requested.
+const utxoSetStatsScanInterval = 8192
+
+// UtxoSetStats houses statistics about the utxo set as of a block in the main
+// chain along with a hash committing to it.
+type UtxoSetStats struct {
+	// Hash and Height identify the block the statistics are up to date
+	// with.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Transactions is the number of transactions with unspent outputs and
+	// DiskSize is the size of the utxo set in the database.  They are only
+	// available when the utxo set was scanned.
+	Transactions int64
+	DiskSize     int64
+
+	// TxOuts is the number of unspent outputs, TotalAmount their total
+	// value and BogoSize a database independent measure of their size.
+	TxOuts      int64
+	BogoSize    int64
+	TotalAmount int64
+
+	// HashSerialized and MuHash are the hashes committing to the utxo set.
+	// Only the requested hash is set.
+	HashSerialized chainhash.Hash
+	MuHash         chainhash.Hash
+}
+
+// utxoBogoSize returns the size of an unspent output with the passed public
+// key script as accounted for by the bogosize statistic.  It's computed as
+// 32 bytes for the txid, 4 for the output index, 4 for the height and coinbase
+// flag, 8 for the amount and 2 for the length of the script in addition to the
+// script itself.
+func utxoBogoSize(pkScript []byte) int64 {
+	return 50 + int64(len(pkScript))
+}
+
+// serializeUtxoForMuHash returns the serialization of an unspent output which
+// is added to the MuHash3072 of the utxo set.  It is the outpoint, followed by
+// the height shifted left by one with the coinbase flag in the lowest bit, and
+// the output itself.
+func serializeUtxoForMuHash(outpoint wire.OutPoint, amount int64,
+	pkScript []byte, height int32, isCoinBase bool) []byte {
+
+	code := uint32(height) << 1
+	if isCoinBase {
+		code |= 1
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(chainhash.HashSize + 16 + wire.VarIntSerializeSize(
+		uint64(len(pkScript))) + len(pkScript))
+	buf.Write(outpoint.Hash[:])
+	var scratch [8]byte
+	binary.LittleEndian.PutUint32(scratch[:4], outpoint.Index)
+	buf.Write(scratch[:4])
+	binary.LittleEndian.PutUint32(scratch[:4], code)
+	buf.Write(scratch[:4])
+	binary.LittleEndian.PutUint64(scratch[:], uint64(amount))
+	buf.Write(scratch[:])
+
+	// Writing to a bytes.Buffer never fails.
+	_ = wire.WriteVarBytes(&buf, 0, pkScript)
+	return buf.Bytes()
+}
+
+// utxoSetStats houses the statistics of the utxo set which can be maintained
+// incrementally as blocks are connected and disconnected.
+type utxoSetStats struct {
+	txOuts      int64
+	bogoSize    int64
+	totalAmount int64
+	muHash      *muHash3072
+}
+
+// newUtxoSetStats returns the statistics of an empty utxo set.
+func newUtxoSetStats() *utxoSetStats {
+	return &utxoSetStats{muHash: newMuHash3072()}
+}
+
+// clone returns a deep copy of the statistics.
+func (s *utxoSetStats) clone() *utxoSetStats {
+	return &utxoSetStats{
+		txOuts:      s.txOuts,
+		bogoSize:    s.bogoSize,
+		totalAmount: s.totalAmount,
+		muHash:      s.muHash.Clone(),
+	}
+}
+
+// addUtxo updates the statistics to account for the passed unspent output
+// being added to the utxo set.
+func (s *utxoSetStats) addUtxo(outpoint wire.OutPoint, amount int64,
+	pkScript []byte, height int32, isCoinBase bool) {
+
+	s.txOuts++
+	s.bogoSize += utxoBogoSize(pkScript)
+	s.totalAmount += amount
+	s.muHash.Add(serializeUtxoForMuHash(outpoint, amount, pkScript,
+		height, isCoinBase))
+}
+
+// removeUtxo updates the statistics to account for the passed unspent output
+// being removed from the utxo set.
+func (s *utxoSetStats) removeUtxo(outpoint wire.OutPoint, amount int64,
+	pkScript []byte, height int32, isCoinBase bool) {
+
+	s.txOuts--
+	s.bogoSize -= utxoBogoSize(pkScript)
+	s.totalAmount -= amount
+	s.muHash.Remove(serializeUtxoForMuHash(outpoint, amount, pkScript,
+		height, isCoinBase))
+}
+
+// connectBlock updates the statistics to account for the passed block at the
+// passed height being connected, where stxos are the outputs spent by the
+// block in the order they are spent.
+//
+// The coinbases of the blocks violating BIP0030 overwrite the outputs of
+// earlier coinbases with the same hash, which must be passed as overwritten
+// so they are removed from the statistics.
+func (s *utxoSetStats) connectBlock(block *btcutil.Block, height int32,
+	stxos []SpentTxOut, overwritten map[wire.OutPoint]*UtxoEntry) {
+
+	for outpoint, entry := range overwritten {
+		if entry == nil {
+			continue
+		}
+		s.removeUtxo(outpoint, entry.Amount(), entry.PkScript(),
+			entry.BlockHeight(), entry.IsCoinBase())
+	}
+
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+				s.removeUtxo(txIn.PreviousOutPoint, stxo.Amount,
+					stxo.PkScript, stxo.Height, stxo.IsCoinBase)
+			}
+		}
+
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			// Provably unspendable outputs are never added to the
+			// utxo set.
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+
+			outpoint.Index = uint32(txOutIdx)
+			s.addUtxo(outpoint, txOut.Value, txOut.PkScript, height,
+				txIdx == 0)
+		}
+	}
+}
+
+// disconnectBlock updates the statistics to account for the passed block at
+// the passed height being disconnected, where stxos are the outputs spent by
+// the block in the order they are spent.
+func (s *utxoSetStats) disconnectBlock(block *btcutil.Block, height int32,
+	stxos []SpentTxOut) {
+
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+				s.addUtxo(txIn.PreviousOutPoint, stxo.Amount,
+					stxo.PkScript, stxo.Height, stxo.IsCoinBase)
+			}
+		}
+
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+
+			outpoint.Index = uint32(txOutIdx)
+			s.removeUtxo(outpoint, txOut.Value, txOut.PkScript,
+				height, txIdx == 0)
+		}
+	}
+}
+
+// fetchOverwrittenUtxos returns the unspent outputs which are overwritten by
+// the coinbase of the passed block when it is one of the two blocks which
+// violate BIP0030, and nil otherwise.
+func (b *BlockChain) fetchOverwrittenUtxos(node *blockNode,
+	block *btcutil.Block) (map[wire.OutPoint]*UtxoEntry, error) {
+
+	if !isBIP0030Node(node) {
+		return nil, nil
+	}
+
+	coinbase := block.Transactions()[0]
+	outpoints := make(map[wire.OutPoint]struct{})
+	prevOut := wire.OutPoint{Hash: *coinbase.Hash()}
+	for txOutIdx := range coinbase.MsgTx().TxOut {
+		prevOut.Index = uint32(txOutIdx)
+		outpoints[prevOut] = struct{}{}
+	}
+
+	overwritten := make(map[wire.OutPoint]*UtxoEntry, len(outpoints))
+	if err := b.utxoCache.fetchEntries(overwritten, outpoints); err != nil {
+		return nil, err
+	}
+	return overwritten, nil
+}
+
+// initUtxoSetStats loads the incrementally maintained statistics of the utxo
+// set when enabled, computing them from the utxo set when they are missing or
+// not up to date with the best chain.  Stored statistics are removed when
+// disabled since they would otherwise become stale.
+func (b *BlockChain) initUtxoSetStats(enabled bool, interrupt <-chan struct{}) error {
+	var hash *chainhash.Hash
+	var stats *utxoSetStats
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, stats, err = dbFetchUtxoSetStats(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		if hash == nil {
+			return nil
+		}
+		log.Infof("Removing the utxo set statistics")
+		return b.db.Update(dbRemoveUtxoSetStats)
+	}
+
+	tip := b.bestChain.Tip()
+	if hash != nil && *hash == tip.hash {
+		b.utxoSetStats = stats
+		return nil
+	}
+
+	log.Infof("Computing the utxo set statistics.  This might take a " +
+		"while...")
+
+	stats = newUtxoSetStats()
+	hash, _, err = b.scanUtxoSet(nil, func(outpoint wire.OutPoint,
+		entry *UtxoEntry, diskSize int) error {
+
+		if stats.txOuts%utxoSetStatsScanInterval == 0 &&
+			interruptRequested(interrupt) {
+
+			return errInterruptRequested
+		}
+
+		stats.addUtxo(outpoint, entry.Amount(), entry.PkScript(),
+			entry.BlockHeight(), entry.IsCoinBase())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *hash != tip.hash {
+		return AssertError(fmt.Sprintf("utxo set is consistent with "+
+			"block %v instead of the best block %v", hash, tip.hash))
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoSetStats(dbTx, hash, stats)
+	})
+	if err != nil {
+		return err
+	}
+	b.utxoSetStats = stats
+	return nil
+}
+
+// FetchUtxoSetStats returns statistics about the utxo set of the main chain
+// along with the requested hash committing to it.
+//
+// When the MuHash is requested and the statistics of the utxo set are
+// maintained incrementally, they are returned without scanning the utxo set,
+// in which case the number of transactions and the disk size are not
+// available.  Otherwise, the utxo set is scanned, which can be interrupted by
+// closing the passed channel.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchUtxoSetStats(hashType UtxoSetHashType,
+	interrupt <-chan struct{}) (*UtxoSetStats, error) {
+
+	if hashType == UtxoSetHashMuHash {
+		b.chainLock.RLock()
+		stats := b.utxoSetStats
+		tip := b.bestChain.Tip()
+		b.chainLock.RUnlock()
+
+		// The statistics are replaced rather than modified when blocks
+		// are connected and disconnected, so they can be used without
+		// holding the lock.
+		if stats != nil {
+			return &UtxoSetStats{
+				Hash:        tip.hash,
+				Height:      tip.height,
+				TxOuts:      stats.txOuts,
+				BogoSize:    stats.bogoSize,
+				TotalAmount: stats.totalAmount,
+				MuHash:      stats.muHash.Finalize(),
+			}, nil
+		}
+	}
+
+	var result UtxoSetStats
+	var muHash *muHash3072
+	if hashType == UtxoSetHashMuHash {
+		muHash = newMuHash3072()
+	}
+
+	var hasher *serializedUtxoSetHasher
+	begin := func(hash *chainhash.Hash) {
+		if hashType == UtxoSetHashSerialized {
+			hasher = newSerializedUtxoSetHasher(hash)
+		}
+	}
+
+	var prevHash chainhash.Hash
+	hash, height, err := b.scanUtxoSet(begin, func(outpoint wire.OutPoint,
+		entry *UtxoEntry, diskSize int) error {
+
+		if result.TxOuts%utxoSetStatsScanInterval == 0 &&
+			interruptRequested(interrupt) {
+
+			return errInterruptRequested
+		}
+
+		newTx := result.TxOuts == 0 || outpoint.Hash != prevHash
+		prevHash = outpoint.Hash
+		if newTx {
+			result.Transactions++
+		}
+		pkScript := entry.PkScript()
+		result.TxOuts++
+		result.BogoSize += utxoBogoSize(pkScript)
+		result.DiskSize += int64(diskSize)
+		result.TotalAmount += entry.Amount()
+
+		switch hashType {
+		case UtxoSetHashSerialized:
+			hasher.add(outpoint, entry)
+
+		case UtxoSetHashMuHash:
+			muHash.Add(serializeUtxoForMuHash(outpoint,
+				entry.Amount(), pkScript, entry.BlockHeight(),
+				entry.IsCoinBase()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Hash = *hash
+	result.Height = height
+
+	switch hashType {
+	case UtxoSetHashSerialized:
+		result.HashSerialized = hasher.sum()
+
+	case UtxoSetHashMuHash:
+		result.MuHash = muHash.Finalize()
+	}
+
+	return &result, nil
+}
+
+// serializedUtxoSetHasher computes the double SHA256 hash of the serialized
+// utxo set which is compatible with the hash_serialized_2 hash of bitcoind.
+//
+// The serialized utxo set consists of the hash of the block it is consistent
+// with followed by the unspent outputs grouped by the transaction they belong
+// to, with each group terminated by a zero.  The outputs must be added in the
+// order of their keys in the database for the groups to be formed correctly.
+type serializedUtxoSetHasher struct {
+	hasher     hash.Hash
+	serialized bytes.Buffer
+	numTxOuts  int64
+	prevHash   chainhash.Hash
+}
+
+// newSerializedUtxoSetHasher returns a hasher for the utxo set which is
+// consistent with the block with the passed hash.
+func newSerializedUtxoSetHasher(blockHash *chainhash.Hash) *serializedUtxoSetHasher {
+	h := &serializedUtxoSetHasher{hasher: sha256.New()}
+	h.hasher.Write(blockHash[:])
+	return h
+}
+
+// add adds the passed unspent output to the hashed utxo set.
+func (h *serializedUtxoSetHasher) add(outpoint wire.OutPoint, entry *UtxoEntry) {
+	serialized := &h.serialized
+	serialized.Reset()
+	if h.numTxOuts == 0 || outpoint.Hash != h.prevHash {
+		if h.numTxOuts != 0 {
+			serialized.WriteByte(0x00)
+		}
+		serialized.Write(outpoint.Hash[:])
+
+		// bitcoind serializes the result of a comparison rather than
+		// the height and coinbase flag here due to the precedence of
+		// its operators, which is reproduced so the hashes match.
+		var code uint64
+		if entry.BlockHeight() != 0 || entry.IsCoinBase() {
+			code = 1
+		}
+		writeVLQ(serialized, code)
+	}
+	writeVLQ(serialized, uint64(outpoint.Index)+1)
+	_ = wire.WriteVarBytes(serialized, 0, entry.PkScript())
+	writeVLQ(serialized, uint64(entry.Amount()))
+	h.hasher.Write(serialized.Bytes())
+
+	h.numTxOuts++
+	h.prevHash = outpoint.Hash
+}
+
+// sum returns the hash of the utxo set made up of the outputs added so far.  No
+// outputs may be added afterwards.
+func (h *serializedUtxoSetHasher) sum() chainhash.Hash {
+	if h.numTxOuts != 0 {
+		h.hasher.Write([]byte{0x00})
+	}
+	return chainhash.Hash(sha256.Sum256(h.hasher.Sum(nil)))
+}
+
+// writeVLQ writes the VLQ encoding of the passed number to the passed buffer.
+func writeVLQ(buf *bytes.Buffer, n uint64) {
+	var scratch [10]byte
+	size := putVLQ(scratch[:], n)
+	buf.Write(scratch[:size])
+}
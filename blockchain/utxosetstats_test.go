@@ -0,0 +1,165 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// scannedUtxoSetStats returns the statistics and MuHash of the utxo set of the passed chain computed
+// by scanning the utxo set rather than from the incrementally maintained
+// statistics.
+func scannedUtxoSetStats(t *testing.T, chain *BlockChain) *UtxoSetStats {
+	t.Helper()
+
+	chain.chainLock.Lock()
+	utxoSetStats := chain.utxoSetStats
+	chain.utxoSetStats = nil
+	chain.chainLock.Unlock()
+
+	stats, err := chain.FetchUtxoSetStats(UtxoSetHashMuHash, nil)
+	if err != nil {
+		t.Fatalf("unable to scan utxo set: %v", err)
+	}
+
+	chain.chainLock.Lock()
+	chain.utxoSetStats = utxoSetStats
+	chain.chainLock.Unlock()
+	return stats
+}
+
+// TestUtxoSetStats ensures the incrementally maintained utxo set statistics
+// match the ones computed by scanning the utxo set as blocks are connected
+// and disconnected, and that they are persisted across restarts.
+func TestUtxoSetStats(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxosetstats",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Reload the chain with the statistics enabled, which computes them
+	// from the utxo set of the genesis block.
+	newChain := func(utxoSetStats bool) *BlockChain {
+		paramsCopy := chaincfg.MainNetParams
+		chain, err := New(&Config{
+			DB:           chain.db,
+			ChainParams:  &paramsCopy,
+			TimeSource:   NewMedianTime(),
+			SigCache:     txscript.NewSigCache(1000),
+			UtxoSetStats: utxoSetStats,
+		})
+		if err != nil {
+			t.Fatalf("failed to create chain instance: %v", err)
+		}
+		chain.TstSetCoinbaseMaturity(1)
+		return chain
+	}
+	chain = newChain(true)
+
+	checkStats := func() *UtxoSetStats {
+		t.Helper()
+
+		stats, err := chain.FetchUtxoSetStats(UtxoSetHashMuHash, nil)
+		if err != nil {
+			t.Fatalf("unable to fetch utxo set stats: %v", err)
+		}
+		want := scannedUtxoSetStats(t, chain)
+		if stats.Hash != want.Hash || stats.Height != want.Height ||
+			stats.TxOuts != want.TxOuts ||
+			stats.BogoSize != want.BogoSize ||
+			stats.TotalAmount != want.TotalAmount ||
+			stats.MuHash != want.MuHash {
+
+			t.Fatalf("unexpected utxo set stats %+v, want %+v",
+				stats, want)
+		}
+		return stats
+	}
+	if stats := checkStats(); stats.TxOuts != 0 {
+		t.Fatalf("unexpected utxos in the genesis utxo set: %+v", stats)
+	}
+
+	var prevStats *UtxoSetStats
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+		if i == len(blocks)-2 {
+			prevStats = checkStats()
+			continue
+		}
+		checkStats()
+	}
+
+	// Disconnecting the tip block must restore the previous statistics.
+	tipHash := blocks[len(blocks)-1].Hash()
+	if err := chain.InvalidateBlock(tipHash); err != nil {
+		t.Fatalf("unable to invalidate block: %v", err)
+	}
+	if stats := checkStats(); *stats != *prevStats {
+		t.Fatalf("unexpected utxo set stats after disconnecting %+v, "+
+			"want %+v", stats, prevStats)
+	}
+
+	// The statistics must be loaded as they were when the chain is loaded
+	// again.
+	want, err := chain.FetchUtxoSetStats(UtxoSetHashMuHash, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+	chain = newChain(true)
+	got, err := chain.FetchUtxoSetStats(UtxoSetHashMuHash, nil)
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("unexpected loaded utxo set stats %+v, want %+v", got,
+			want)
+	}
+
+	// Scanning the utxo set for the serialized hash provides the number
+	// of transactions as well, and must be deterministic.
+	scanned, err := chain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to scan utxo set: %v", err)
+	}
+	if scanned.Transactions == 0 || scanned.TxOuts != want.TxOuts ||
+		scanned.DiskSize == 0 || scanned.TotalAmount != want.TotalAmount {
+
+		t.Fatalf("unexpected scanned utxo set stats %+v", scanned)
+	}
+	rescanned, err := chain.FetchUtxoSetStats(UtxoSetHashSerialized, nil)
+	if err != nil {
+		t.Fatalf("unable to scan utxo set: %v", err)
+	}
+	if rescanned.HashSerialized != scanned.HashSerialized {
+		t.Fatalf("serialized hash is not deterministic")
+	}
+
+	// Disabling the statistics must remove them from the database.
+	chain = newChain(false)
+	err = chain.db.View(func(dbTx database.Tx) error {
+		hash, _, err := dbFetchUtxoSetStats(dbTx)
+		if hash != nil {
+			t.Fatalf("utxo set stats were not removed")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo set stats: %v", err)
+	}
+}
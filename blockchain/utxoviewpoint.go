@@ -28,6 +28,12 @@ const (
 	// tfModified indicates that a txout has been modified since it was
 	// loaded.
 	tfModified
+
+	// tfFresh indicates that a txout was created after it was loaded and
+	// therefore does not exist in the database.  Such outputs can simply
+	// be forgotten when they are spent before being written to the
+	// database.
+	tfFresh
 )
 
 // UtxoEntry houses details about an individual transaction output in a utxo
@@ -58,6 +64,12 @@ func (entry *UtxoEntry) isModified() bool {
 	return entry.packedFlags&tfModified == tfModified
 }
 
+// isFresh returns whether or not the output is known to not exist in the
+// database.
+func (entry *UtxoEntry) isFresh() bool {
+	return entry.packedFlags&tfFresh == tfFresh
+}
+
 // IsCoinBase returns whether or not the output was contained in a coinbase
 // transaction.
 func (entry *UtxoEntry) IsCoinBase() bool {
@@ -190,16 +202,24 @@ func (view *UtxoViewpoint) addTxOut(outpoint wire.OutPoint, txOut *wire.TxOut, i
 	// possible (although extremely unlikely) that the existing entry is
 	// being replaced by a different transaction with the same hash.  This
 	// is allowed so long as the previous transaction is fully spent.
+	//
+	// New entries are marked fresh since they can't already exist in the
+	// database with the exception of coinbase outputs, which were able to
+	// be duplicated prior to BIP0034.
+	var flags txoFlags
 	entry := view.LookupEntry(outpoint)
 	if entry == nil {
 		entry = new(UtxoEntry)
 		view.entries[outpoint] = entry
+		if !isCoinBase {
+			flags |= tfFresh
+		}
 	}
 
 	entry.amount = txOut.Value
 	entry.pkScript = txOut.PkScript
 	entry.blockHeight = blockHeight
-	entry.packedFlags = tfModified
+	entry.packedFlags = flags | tfModified
 	if isCoinBase {
 		entry.packedFlags |= tfCoinBase
 	}
@@ -484,7 +504,7 @@ func (view *UtxoViewpoint) Entries() map[wire.OutPoint]*UtxoEntry {
 }
 
 // commit prunes all entries marked modified that are now fully spent and marks
-// all entries as unmodified.
+// all entries as unmodified and no longer fresh.
 func (view *UtxoViewpoint) commit() {
 	for outpoint, entry := range view.entries {
 		if entry == nil || (entry.isModified() && entry.IsSpent()) {
@@ -492,7 +512,7 @@ func (view *UtxoViewpoint) commit() {
 			continue
 		}
 
-		entry.packedFlags ^= tfModified
+		entry.packedFlags &^= tfModified | tfFresh
 	}
 }
 
@@ -503,37 +523,27 @@ func (view *UtxoViewpoint) commit() {
 // Upon completion of this function, the view will contain an entry for each
 // requested outpoint.  Spent outputs, or those which otherwise don't exist,
 // will result in a nil entry in the view.
-func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxosMain(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
 	}
 
 	// Load the requested set of unspent transaction outputs from the point
-	// of view of the end of the main chain.
+	// of view of the end of the main chain.  The utxo cache is consulted
+	// first and falls back to the database as needed.
 	//
 	// NOTE: Missing entries are not considered an error here and instead
 	// will result in nil entries in the view.  This is intentionally done
 	// so other code can use the presence of an entry in the store as a way
 	// to unnecessarily avoid attempting to reload it from the database.
-	return db.View(func(dbTx database.Tx) error {
-		for outpoint := range outpoints {
-			entry, err := dbFetchUtxoEntry(dbTx, outpoint)
-			if err != nil {
-				return err
-			}
-
-			view.entries[outpoint] = entry
-		}
-
-		return nil
-	})
+	return cache.fetchEntries(view.entries, outpoints)
 }
 
 // fetchUtxos loads the unspent transaction outputs for the provided set of
 // outputs into the view from the database as needed unless they already exist
 // in the view in which case they are ignored.
-func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxos(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -551,7 +561,7 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 	}
 
 	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // fetchInputUtxos loads the unspent transaction outputs for the inputs
@@ -559,7 +569,7 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 // database as needed.  In particular, referenced entries that are earlier in
 // the block are added to the view and entries that are already in the view are
 // not modified.
-func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block) error {
+func (view *UtxoViewpoint) fetchInputUtxos(cache *utxoCache, block *btcutil.Block) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
 	// block which are not yet in the chain.
@@ -606,7 +616,7 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block)
 	}
 
 	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // NewUtxoViewpoint returns a new empty unspent transaction output view.
@@ -642,7 +652,7 @@ func (b *BlockChain) FetchUtxoView(tx *btcutil.Tx) (*UtxoViewpoint, error) {
 	// chain.
 	view := NewUtxoViewpoint()
 	b.chainLock.RLock()
-	err := view.fetchUtxosMain(b.db, neededSet)
+	err := view.fetchUtxosMain(b.utxoCache, neededSet)
 	b.chainLock.RUnlock()
 	return view, err
 }
@@ -661,15 +671,103 @@ func (b *BlockChain) FetchUtxoEntry(outpoint wire.OutPoint) (*UtxoEntry, error)
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
-	var entry *UtxoEntry
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
-		return err
+	entries := make(map[wire.OutPoint]*UtxoEntry, 1)
+	err := b.utxoCache.fetchEntries(entries, map[wire.OutPoint]struct{}{
+		outpoint: {},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return entry, nil
+	return entries[outpoint], nil
+}
+
+// ScanUtxoSet calls the passed function with every unspent transaction output
+// in the utxo set, in order of the hash of the transaction that created it.
+// The scan is stopped when the function returns an error, which is then
+// returned.
+//
+// The utxo cache is flushed prior to the scan and the outputs are read from a
+// consistent snapshot of the database, so they reflect the state of the main
+// chain as of the returned block hash and height even when further blocks are
+// connected during the scan.
+//
+// This function is safe for concurrent access however the entries passed to
+// the function are NOT.
+func (b *BlockChain) ScanUtxoSet(fn func(wire.OutPoint, *UtxoEntry) error) (*chainhash.Hash, int32, error) {
+	return b.scanUtxoSet(nil, func(outpoint wire.OutPoint, entry *UtxoEntry,
+		diskSize int) error {
+
+		return fn(outpoint, entry)
+	})
+}
+
+// scanUtxoSet is the implementation of ScanUtxoSet which additionally passes
+// the size of the key and value of each entry in the database to the passed
+// function.  The begin function, when not nil, is called with the hash of the
+// block the utxo set is consistent with before the outputs are scanned.
+func (b *BlockChain) scanUtxoSet(begin func(*chainhash.Hash),
+	fn func(wire.OutPoint, *UtxoEntry, int) error) (*chainhash.Hash, int32, error) {
+
+	if err := b.FlushUtxoCache(FlushRequired); err != nil {
+		return nil, 0, err
+	}
+
+	var hash *chainhash.Hash
+	var height int32
+	err := b.db.View(func(dbTx database.Tx) error {
+		hash = dbFetchUtxoStateConsistency(dbTx)
+		if hash == nil {
+			return AssertError("utxo set consistency state is missing")
+		}
+		node := b.index.LookupNode(hash)
+		if node == nil {
+			return AssertError(fmt.Sprintf("utxo set is consistent "+
+				"with unknown block %v", hash))
+		}
+		height = node.height
+		if begin != nil {
+			begin(hash)
+		}
+
+		return dbForEachUtxo(dbTx, utxoSetBucketName, fn)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hash, height, nil
+}
+
+// dbForEachUtxo uses an existing database transaction to call the passed
+// function with every unspent transaction output in the utxo set housed in the
+// bucket with the passed name along with the size of its key and value in the
+// database.  The outputs are visited in order of their serialized keys.
+func dbForEachUtxo(dbTx database.Tx, bucketName []byte,
+	fn func(wire.OutPoint, *UtxoEntry, int) error) error {
+
+	cursor := dbTx.Metadata().Bucket(bucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		// The keys are serialized as <hash><index> where the index is VLQ
+		// encoded.
+		key := cursor.Key()
+		if len(key) <= chainhash.HashSize {
+			return errDeserialize("utxo key is too short")
+		}
+		var outpoint wire.OutPoint
+		copy(outpoint.Hash[:], key[:chainhash.HashSize])
+		index, _ := deserializeVLQ(key[chainhash.HashSize:])
+		outpoint.Index = uint32(index)
+
+		serialized := cursor.Value()
+		entry, err := deserializeUtxoEntry(serialized)
+		if err != nil {
+			return err
+		}
+		diskSize := len(key) + len(serialized)
+		if err := fn(outpoint, entry, diskSize); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -148,6 +148,49 @@ func TestCheckConnectBlockTemplate(t *testing.T) {
 	}
 }
 
+// TestCheckBlockHeader ensures block headers are only accepted when they
+// satisfy the proof of work and connect to a known block.
+func TestCheckBlockHeader(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("checkblockheader",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	if _, _, err := chain.ProcessBlock(blocks[1], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 1: %v", err)
+	}
+
+	// The header of the next block connects to the tip.
+	header := blocks[2].MsgBlock().Header
+	if err := chain.CheckBlockHeader(&header); err != nil {
+		t.Fatalf("CheckBlockHeader: unexpected error: %v", err)
+	}
+
+	// The header must satisfy its proof of work.
+	badPoW := header
+	badPoW.Nonce++
+	err = chain.CheckBlockHeader(&badPoW)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrHighHash {
+		t.Fatalf("CheckBlockHeader: unexpected error for header "+
+			"with invalid proof of work: %v", err)
+	}
+
+	// The header must connect to a known block.
+	header = blocks[3].MsgBlock().Header
+	err = chain.CheckBlockHeader(&header)
+	rerr, ok := err.(RuleError)
+	if !ok || rerr.ErrorCode != ErrPreviousBlockUnknown {
+		t.Fatalf("CheckBlockHeader: unexpected error for header "+
+			"with unknown parent: %v", err)
+	}
+}
+
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
@@ -18,3 +18,16 @@ type VersionResult struct {
 	Prerelease    string `json:"prerelease"`
 	BuildMetadata string `json:"buildmetadata"`
 }
+
+// GetUtxoCacheInfoResult models the data returned from the getutxocacheinfo
+// command.
+type GetUtxoCacheInfoResult struct {
+	Entries       uint64 `json:"entries"`
+	Size          uint64 `json:"size"`
+	MaxSize       uint64 `json:"maxsize"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Flushes       uint64 `json:"flushes"`
+	LastFlushHash string `json:"lastflushhash"`
+	LastFlushTime int64  `json:"lastflushtime"`
+}
@@ -60,9 +60,13 @@ type GetBlockStatsResult struct {
 	TotalOut           int64   `json:"total_out"`
 	TotalSize          int64   `json:"total_size"`
 	TotalWeight        int64   `json:"total_weight"`
+	TotalFee           int64   `json:"totalfee"`
 	Txs                int64   `json:"txs"`
 	UTXOIncrease       int64   `json:"utxo_increase"`
 	UTXOSizeIncrease   int64   `json:"utxo_size_inc"`
+
+	UTXOIncreaseActual     int64 `json:"utxo_increase_actual"`
+	UTXOSizeIncreaseActual int64 `json:"utxo_size_inc_actual"`
 }
 
 // GetBlockVerboseResult models the data from the getblock command when the
@@ -206,6 +210,32 @@ type UnifiedSoftFork struct {
 	Active                  bool                     `json:"active"`
 }
 
+// GetChainTipsResult models the data returned from the getchaintips command.
+type GetChainTipsResult struct {
+	Height    int32  `json:"height"`
+	Hash      string `json:"hash"`
+	BranchLen int32  `json:"branchlen"`
+	Status    string `json:"status"`
+}
+
+// ChainStateResult models the data of a chainstate returned from the
+// getchainstates command.
+type ChainStateResult struct {
+	Blocks               int32   `json:"blocks"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	Difficulty           float64 `json:"difficulty"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	SnapshotBlockHash    string  `json:"snapshot_blockhash,omitempty"`
+	Validated            bool    `json:"validated"`
+}
+
+// GetChainStatesResult models the data returned from the getchainstates
+// command.
+type GetChainStatesResult struct {
+	Headers     int32              `json:"headers"`
+	ChainStates []ChainStateResult `json:"chainstates"`
+}
+
 // UnifiedSoftForks describes the current softforks enabled the by the backend
 // in a unified manner, i.e, softforks with different activation types are
 // grouped together. This was a format introduced by bitcoind v0.19.0
@@ -310,31 +340,63 @@ type MempoolFees struct {
 }
 
 // GetMempoolEntryResult models the data returned from the getmempoolentry
-// command.
+// command.  It is also used for the verbose results of the getmempoolancestors
+// and getmempooldescendants commands.
 type GetMempoolEntryResult struct {
-	VSize           int32       `json:"vsize"`
-	Size            int32       `json:"size"`
-	Weight          int64       `json:"weight"`
-	Fee             float64     `json:"fee"`
-	ModifiedFee     float64     `json:"modifiedfee"`
-	Time            int64       `json:"time"`
-	Height          int64       `json:"height"`
-	DescendantCount int64       `json:"descendantcount"`
-	DescendantSize  int64       `json:"descendantsize"`
-	DescendantFees  float64     `json:"descendantfees"`
-	AncestorCount   int64       `json:"ancestorcount"`
-	AncestorSize    int64       `json:"ancestorsize"`
-	AncestorFees    float64     `json:"ancestorfees"`
-	WTxId           string      `json:"wtxid"`
-	Fees            MempoolFees `json:"fees"`
-	Depends         []string    `json:"depends"`
+	VSize             int32       `json:"vsize"`
+	Size              int32       `json:"size"`
+	Weight            int64       `json:"weight"`
+	Fee               float64     `json:"fee"`
+	ModifiedFee       float64     `json:"modifiedfee"`
+	Time              int64       `json:"time"`
+	Height            int64       `json:"height"`
+	DescendantCount   int64       `json:"descendantcount"`
+	DescendantSize    int64       `json:"descendantsize"`
+	DescendantFees    float64     `json:"descendantfees"`
+	AncestorCount     int64       `json:"ancestorcount"`
+	AncestorSize      int64       `json:"ancestorsize"`
+	AncestorFees      float64     `json:"ancestorfees"`
+	WTxId             string      `json:"wtxid"`
+	Fees              MempoolFees `json:"fees"`
+	Depends           []string    `json:"depends"`
+	SpentBy           []string    `json:"spentby"`
+	BIP125Replaceable bool        `json:"bip125-replaceable"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Loaded        bool    `json:"loaded"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
+}
+
+// SaveMempoolResult models the data returned from the savemempool command.
+type SaveMempoolResult struct {
+	Filename string `json:"filename"`
+}
+
+// TestMempoolAcceptFees models the fees of a transaction which would be
+// accepted to the mempool returned by the testmempoolaccept command.
+type TestMempoolAcceptFees struct {
+	// Base is the fee paid by the transaction in BTC.
+	Base float64 `json:"base"`
+}
+
+// TestMempoolAcceptResult models the data returned from the testmempoolaccept
+// command for each of the tested transactions.  The virtual size and fees are
+// only set when the transaction would be accepted.
+type TestMempoolAcceptResult struct {
+	TxID         string                 `json:"txid"`
+	Wtxid        string                 `json:"wtxid"`
+	Allowed      bool                   `json:"allowed"`
+	VSize        int64                  `json:"vsize,omitempty"`
+	Fees         *TestMempoolAcceptFees `json:"fees,omitempty"`
+	RejectReason string                 `json:"reject-reason,omitempty"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -417,10 +479,17 @@ type GetRawMempoolVerboseResult struct {
 	Vsize            int32    `json:"vsize"`
 	Weight           int32    `json:"weight"`
 	Fee              float64  `json:"fee"`
+	ModifiedFee      float64  `json:"modifiedfee"`
 	Time             int64    `json:"time"`
 	Height           int64    `json:"height"`
 	StartingPriority float64  `json:"startingpriority"`
 	CurrentPriority  float64  `json:"currentpriority"`
+	DescendantCount  int64    `json:"descendantcount"`
+	DescendantSize   int64    `json:"descendantsize"`
+	DescendantFees   float64  `json:"descendantfees"`
+	AncestorCount    int64    `json:"ancestorcount"`
+	AncestorSize     int64    `json:"ancestorsize"`
+	AncestorFees     float64  `json:"ancestorfees"`
 	Depends          []string `json:"depends"`
 }
 
@@ -443,6 +512,16 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// DumpTxOutSetResult models the data from the dumptxoutset command.
+type DumpTxOutSetResult struct {
+	CoinsWritten uint64 `json:"coins_written"`
+	BaseHash     string `json:"base_hash"`
+	BaseHeight   int32  `json:"base_height"`
+	Path         string `json:"path"`
+	TxOutSetHash string `json:"txoutset_hash"`
+	NChainTx     uint64 `json:"nchaintx"`
+}
+
 // GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
 type GetTxOutSetInfoResult struct {
 	Height         int64          `json:"height"`
@@ -451,10 +530,46 @@ type GetTxOutSetInfoResult struct {
 	TxOuts         int64          `json:"txouts"`
 	BogoSize       int64          `json:"bogosize"`
 	HashSerialized chainhash.Hash `json:"hash_serialized_2"`
+	MuHash         chainhash.Hash `json:"muhash"`
 	DiskSize       int64          `json:"disk_size"`
 	TotalAmount    btcutil.Amount `json:"total_amount"`
 }
 
+// MarshalJSON marshals the result of the gettxoutsetinfo JSON-RPC call with
+// the hashes as strings and the total amount in BTC.  The hashes which were
+// not computed and the statistics which are not available are omitted.
+func (g GetTxOutSetInfoResult) MarshalJSON() ([]byte, error) {
+	var hashSerialized, muHash string
+	if g.HashSerialized != (chainhash.Hash{}) {
+		hashSerialized = g.HashSerialized.String()
+	}
+	if g.MuHash != (chainhash.Hash{}) {
+		muHash = g.MuHash.String()
+	}
+
+	return json.Marshal(&struct {
+		Height         int64   `json:"height"`
+		BestBlock      string  `json:"bestblock"`
+		Transactions   int64   `json:"transactions,omitempty"`
+		TxOuts         int64   `json:"txouts"`
+		BogoSize       int64   `json:"bogosize"`
+		HashSerialized string  `json:"hash_serialized_2,omitempty"`
+		MuHash         string  `json:"muhash,omitempty"`
+		DiskSize       int64   `json:"disk_size,omitempty"`
+		TotalAmount    float64 `json:"total_amount"`
+	}{
+		Height:         g.Height,
+		BestBlock:      g.BestBlock.String(),
+		Transactions:   g.Transactions,
+		TxOuts:         g.TxOuts,
+		BogoSize:       g.BogoSize,
+		HashSerialized: hashSerialized,
+		MuHash:         muHash,
+		DiskSize:       g.DiskSize,
+		TotalAmount:    g.TotalAmount.ToBTC(),
+	})
+}
+
 // UnmarshalJSON unmarshals the result of the gettxoutsetinfo JSON-RPC call
 func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	// Step 1: Create type aliases of the original struct.
@@ -465,6 +580,7 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		BestBlock      string  `json:"bestblock"`
 		HashSerialized string  `json:"hash_serialized_2"`
+		MuHash         string  `json:"muhash"`
 		TotalAmount    float64 `json:"total_amount"`
 		*Alias
 	}{
@@ -484,12 +600,24 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 
 	g.BestBlock = *blockHash
 
-	serializedHash, err := chainhash.NewHashFromStr(aux.HashSerialized)
-	if err != nil {
-		return err
+	// Only the requested hash of the utxo set is included.
+	if aux.HashSerialized != "" {
+		serializedHash, err := chainhash.NewHashFromStr(aux.HashSerialized)
+		if err != nil {
+			return err
+		}
+
+		g.HashSerialized = *serializedHash
 	}
 
-	g.HashSerialized = *serializedHash
+	if aux.MuHash != "" {
+		muHash, err := chainhash.NewHashFromStr(aux.MuHash)
+		if err != nil {
+			return err
+		}
+
+		g.MuHash = *muHash
+	}
 
 	amount, err := btcutil.NewAmount(aux.TotalAmount)
 	if err != nil {
@@ -501,6 +629,34 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ScanTxOutSetUnspent models an unspent output matched by the scantxoutset
+// command.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Height       int32   `json:"height"`
+}
+
+// ScanTxOutSetResult models the data returned by the start action of the
+// scantxoutset command.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	TxOuts      int64                 `json:"txouts"`
+	Height      int32                 `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// ScanTxOutSetStatusResult models the data returned by the status action of
+// the scantxoutset command while a scan is in progress.
+type ScanTxOutSetStatusResult struct {
+	Progress float64 `json:"progress"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
 	TotalBytesRecv uint64 `json:"totalbytesrecv"`
@@ -753,6 +909,149 @@ type TxRawDecodeResult struct {
 	Vout     []Vout `json:"vout"`
 }
 
+// PsbtBip32Deriv models a BIP 32 derivation path of a public key within the
+// data returned from the decodepsbt command.
+type PsbtBip32Deriv struct {
+	PubKey            string `json:"pubkey"`
+	MasterFingerprint string `json:"master_fingerprint"`
+	Path              string `json:"path"`
+}
+
+// PsbtTaprootBip32Deriv models a BIP 32 derivation path of an x-only public
+// key, along with the leaves it is used in, within the data returned from the
+// decodepsbt command.
+type PsbtTaprootBip32Deriv struct {
+	PubKey            string   `json:"pubkey"`
+	MasterFingerprint string   `json:"master_fingerprint"`
+	Path              string   `json:"path"`
+	LeafHashes        []string `json:"leaf_hashes"`
+}
+
+// PsbtTaprootScriptPathSig models a signature of a tapscript leaf within the
+// data returned from the decodepsbt command.
+type PsbtTaprootScriptPathSig struct {
+	PubKey   string `json:"pubkey"`
+	LeafHash string `json:"leaf_hash"`
+	Sig      string `json:"sig"`
+}
+
+// PsbtTaprootScript models a tapscript leaf, along with the control blocks
+// which prove its inclusion, within the data returned from the decodepsbt
+// command.
+type PsbtTaprootScript struct {
+	Script        string   `json:"script"`
+	LeafVer       uint8    `json:"leaf_ver"`
+	ControlBlocks []string `json:"control_blocks"`
+}
+
+// PsbtTaprootTreeLeaf models a leaf of the taproot tree of an output within
+// the data returned from the decodepsbt command.
+type PsbtTaprootTreeLeaf struct {
+	Depth   uint8  `json:"depth"`
+	LeafVer uint8  `json:"leaf_ver"`
+	Script  string `json:"script"`
+}
+
+// PsbtWitnessUtxo models the witness UTXO of an input within the data returned
+// from the decodepsbt command.
+type PsbtWitnessUtxo struct {
+	Amount       float64            `json:"amount"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// DecodePsbtInput models the data of an input returned from the decodepsbt
+// command.
+type DecodePsbtInput struct {
+	NonWitnessUtxo        *TxRawDecodeResult         `json:"non_witness_utxo,omitempty"`
+	WitnessUtxo           *PsbtWitnessUtxo           `json:"witness_utxo,omitempty"`
+	PartialSignatures     map[string]string          `json:"partial_signatures,omitempty"`
+	Sighash               string                     `json:"sighash,omitempty"`
+	RedeemScript          *ScriptPubKeyResult        `json:"redeem_script,omitempty"`
+	WitnessScript         *ScriptPubKeyResult        `json:"witness_script,omitempty"`
+	Bip32Derivs           []PsbtBip32Deriv           `json:"bip32_derivs,omitempty"`
+	FinalScriptSig        *ScriptSig                 `json:"final_scriptSig,omitempty"`
+	FinalScriptWitness    []string                   `json:"final_scriptwitness,omitempty"`
+	TaprootKeyPathSig     string                     `json:"taproot_key_path_sig,omitempty"`
+	TaprootScriptPathSigs []PsbtTaprootScriptPathSig `json:"taproot_script_path_sigs,omitempty"`
+	TaprootScripts        []PsbtTaprootScript        `json:"taproot_scripts,omitempty"`
+	TaprootBip32Derivs    []PsbtTaprootBip32Deriv    `json:"taproot_bip32_derivs,omitempty"`
+	TaprootInternalKey    string                     `json:"taproot_internal_key,omitempty"`
+	TaprootMerkleRoot     string                     `json:"taproot_merkle_root,omitempty"`
+	PreviousTxid          string                     `json:"previous_txid,omitempty"`
+	PreviousVout          *uint32                    `json:"previous_vout,omitempty"`
+	Sequence              *uint32                    `json:"sequence,omitempty"`
+	TimeLocktime          uint32                     `json:"time_locktime,omitempty"`
+	HeightLocktime        uint32                     `json:"height_locktime,omitempty"`
+	Unknown               map[string]string          `json:"unknown,omitempty"`
+}
+
+// DecodePsbtOutput models the data of an output returned from the decodepsbt
+// command.
+type DecodePsbtOutput struct {
+	RedeemScript       *ScriptPubKeyResult     `json:"redeem_script,omitempty"`
+	WitnessScript      *ScriptPubKeyResult     `json:"witness_script,omitempty"`
+	Bip32Derivs        []PsbtBip32Deriv        `json:"bip32_derivs,omitempty"`
+	TaprootInternalKey string                  `json:"taproot_internal_key,omitempty"`
+	TaprootTree        []PsbtTaprootTreeLeaf   `json:"taproot_tree,omitempty"`
+	TaprootBip32Derivs []PsbtTaprootBip32Deriv `json:"taproot_bip32_derivs,omitempty"`
+	Amount             *float64                `json:"amount,omitempty"`
+	Script             *ScriptPubKeyResult     `json:"script,omitempty"`
+}
+
+// DecodePsbtResult models the data returned from the decodepsbt command.
+//
+// The fee is only set when the UTXOs of all inputs are known.
+type DecodePsbtResult struct {
+	Tx               TxRawDecodeResult  `json:"tx"`
+	PsbtVersion      uint32             `json:"psbt_version"`
+	FallbackLocktime *uint32            `json:"fallback_locktime,omitempty"`
+	TxModifiable     []string           `json:"tx_modifiable,omitempty"`
+	Unknown          map[string]string  `json:"unknown"`
+	Inputs           []DecodePsbtInput  `json:"inputs"`
+	Outputs          []DecodePsbtOutput `json:"outputs"`
+	Fee              *float64           `json:"fee,omitempty"`
+}
+
+// FinalizePsbtResult models the data returned from the finalizepsbt command.
+// Only one of Psbt and Hex is set, depending on whether the transaction was
+// extracted.
+type FinalizePsbtResult struct {
+	Psbt     string `json:"psbt,omitempty"`
+	Hex      string `json:"hex,omitempty"`
+	Complete bool   `json:"complete"`
+}
+
+// AnalyzePsbtMissing models the data which is missing to finalize an input
+// within the data returned from the analyzepsbt command.
+type AnalyzePsbtMissing struct {
+	Pubkeys       []string `json:"pubkeys,omitempty"`
+	Signatures    []string `json:"signatures,omitempty"`
+	RedeemScript  string   `json:"redeemscript,omitempty"`
+	WitnessScript string   `json:"witnessscript,omitempty"`
+}
+
+// AnalyzePsbtInput models the analysis of an input returned from the
+// analyzepsbt command.
+type AnalyzePsbtInput struct {
+	HasUtxo bool                `json:"has_utxo"`
+	IsFinal bool                `json:"is_final"`
+	Missing *AnalyzePsbtMissing `json:"missing,omitempty"`
+	Next    string              `json:"next,omitempty"`
+}
+
+// AnalyzePsbtResult models the data returned from the analyzepsbt command.
+//
+// The estimated size and fee rate are only set once all inputs are signed,
+// while the fee is set when the UTXOs of all inputs are known.
+type AnalyzePsbtResult struct {
+	Inputs           []AnalyzePsbtInput `json:"inputs,omitempty"`
+	EstimatedVSize   *int64             `json:"estimated_vsize,omitempty"`
+	EstimatedFeeRate *float64           `json:"estimated_feerate,omitempty"`
+	Fee              *float64           `json:"fee,omitempty"`
+	Next             string             `json:"next"`
+	Error            string             `json:"error,omitempty"`
+}
+
 // ValidateAddressChainResult models the data returned by the chain server
 // validateaddress command.
 //
@@ -10,6 +10,8 @@ import (
 	"reflect"
 	"strings"
 	"text/tabwriter"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
 // baseHelpDescs house the various help labels, types, and example values used
@@ -40,6 +42,10 @@ var baseHelpDescs = map[string]string{
 	"json-example-unknown":  "unknown",
 }
 
+// hashType is the reflect type of chainhash.Hash.  Results containing hashes
+// marshal them as strings, so they are described as such.
+var hashType = reflect.TypeOf(chainhash.Hash{})
+
 // descLookupFunc is a function which is used to lookup a description given
 // a key.
 type descLookupFunc func(string) string
@@ -47,6 +53,10 @@ type descLookupFunc func(string) string
 // reflectTypeToJSONType returns a string that represents the JSON type
 // associated with the provided Go type.
 func reflectTypeToJSONType(xT descLookupFunc, rt reflect.Type) string {
+	if rt == hashType {
+		return xT("json-type-string")
+	}
+
 	kind := rt.Kind()
 	if isNumeric(kind) {
 		return xT("json-type-numeric")
@@ -145,6 +155,9 @@ func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel in
 	if rt.Kind() == reflect.Ptr {
 		rt = rt.Elem()
 	}
+	if rt == hashType {
+		return []string{`"` + xT("json-example-string") + `"`}, false
+	}
 	kind := rt.Kind()
 	if isNumeric(kind) {
 		if kind == reflect.Float32 || kind == reflect.Float64 {
@@ -5,6 +5,15 @@ import (
 	"net/url"
 )
 
+// ZmqNotificationResult models a single notification returned from the
+// getzmqnotifications command.  It produces the same JSON as an entry of
+// GetZmqNotificationResult.
+type ZmqNotificationResult struct {
+	Type          string `json:"type"`
+	Address       string `json:"address"`
+	HighWaterMark int    `json:"hwm"`
+}
+
 // GetZmqNotificationResult models the data returned from the getzmqnotifications command.
 type GetZmqNotificationResult []struct {
 	Type          string   // Type of notification
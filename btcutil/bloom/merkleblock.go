@@ -5,6 +5,9 @@
 package bloom
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
@@ -80,6 +83,32 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 // NewMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
 // transaction index numbers based on the passed block and filter.
 func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock, []uint32) {
+	return newMerkleBlock(block, filter.MatchTxAndUpdate)
+}
+
+// NewMerkleBlockFromTxHashes returns a new *wire.MsgMerkleBlock and an array
+// of the matched transaction index numbers for the transactions in the passed
+// block whose hashes are in the provided set.  This is useful for generating
+// proofs that specific transactions are included in a block.
+func NewMerkleBlockFromTxHashes(block *btcutil.Block,
+	txHashes []*chainhash.Hash) (*wire.MsgMerkleBlock, []uint32) {
+
+	hashSet := make(map[chainhash.Hash]struct{}, len(txHashes))
+	for _, hash := range txHashes {
+		hashSet[*hash] = struct{}{}
+	}
+	return newMerkleBlock(block, func(tx *btcutil.Tx) bool {
+		_, ok := hashSet[*tx.Hash()]
+		return ok
+	})
+}
+
+// newMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the
+// matched transaction index numbers for the transactions in the passed block
+// for which the provided match function returns true.
+func newMerkleBlock(block *btcutil.Block,
+	match func(*btcutil.Tx) bool) (*wire.MsgMerkleBlock, []uint32) {
+
 	numTx := uint32(len(block.Transactions()))
 	mBlock := merkleBlock{
 		numTx:       numTx,
@@ -87,10 +116,10 @@ func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock,
 		matchedBits: make([]byte, 0, numTx),
 	}
 
-	// Find and keep track of any transactions that match the filter.
+	// Find and keep track of any transactions that match.
 	var matchedIndices []uint32
 	for txIndex, tx := range block.Transactions() {
-		if filter.MatchTxAndUpdate(tx) {
+		if match(tx) {
 			mBlock.matchedBits = append(mBlock.matchedBits, 0x01)
 			matchedIndices = append(matchedIndices, uint32(txIndex))
 		} else {
@@ -123,3 +152,143 @@ func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock,
 	}
 	return &msgMerkleBlock, matchedIndices
 }
+
+// maxMerkleBlockTxns is the maximum number of transactions a merkle block can
+// claim to commit to.  It is derived from the maximum block weight and the
+// smallest possible transaction size, and is used to reject proofs that claim
+// an impossible number of transactions before doing any work on them.
+const maxMerkleBlockTxns = blockchain.MaxBlockWeight /
+	(blockchain.WitnessScaleFactor * 60)
+
+// partialMerkleTree is used to house intermediate state while extracting the
+// matched transaction hashes from a wire.MsgMerkleBlock.
+type partialMerkleTree struct {
+	numTx    uint32
+	hashes   []*chainhash.Hash
+	flags    []byte
+	bitsUsed uint32
+	hashUsed uint32
+	matches  []*chainhash.Hash
+	bad      bool
+}
+
+// calcTreeWidth calculates and returns the the number of nodes (width) or a
+// merkle tree at the given depth-first height.
+func (p *partialMerkleTree) calcTreeWidth(height uint32) uint32 {
+	return (p.numTx + (1 << height) - 1) >> height
+}
+
+// traverseAndExtract recursively walks the partial merkle tree in the same
+// depth-first order it was built in, consuming flag bits and hashes, and
+// returns the hash of the sub-tree at the given height and position.  Any
+// matched leaf hashes are appended to the list of matches.  The bad flag is
+// set when the tree is malformed.
+func (p *partialMerkleTree) traverseAndExtract(height, pos uint32) *chainhash.Hash {
+	if p.bitsUsed >= uint32(len(p.flags))*8 {
+		p.bad = true
+		return &chainhash.Hash{}
+	}
+	isParent := p.flags[p.bitsUsed/8]&(1<<(p.bitsUsed%8)) != 0
+	p.bitsUsed++
+
+	// When the node is a leaf node or not a parent of a matched node, the
+	// next hash in the list is the hash of the sub-tree.
+	if height == 0 || !isParent {
+		if p.hashUsed >= uint32(len(p.hashes)) {
+			p.bad = true
+			return &chainhash.Hash{}
+		}
+		hash := p.hashes[p.hashUsed]
+		p.hashUsed++
+		if height == 0 && isParent {
+			p.matches = append(p.matches, hash)
+		}
+		return hash
+	}
+
+	// Otherwise, descend into the children to calculate the hash.
+	left := p.traverseAndExtract(height-1, pos*2)
+	right := left
+	if pos*2+1 < p.calcTreeWidth(height-1) {
+		right = p.traverseAndExtract(height-1, pos*2+1)
+
+		// The left and right branches should never be identical as
+		// the transaction hashes covered by them must each be unique.
+		// This prevents the duplicate transaction issue described by
+		// CVE-2012-2459.
+		if left.IsEqual(right) {
+			p.bad = true
+		}
+	}
+	return blockchain.HashMerkleBranches(left, right)
+}
+
+// ExtractMatches parses the partial merkle tree contained in the passed merkle
+// block and returns the hashes of the transactions it matches.  An error is
+// returned if the partial merkle tree is malformed or its computed root does
+// not match the merkle root committed to by the block header.
+func ExtractMatches(msg *wire.MsgMerkleBlock) ([]*chainhash.Hash, error) {
+	// An empty set will not work.
+	if msg.Transactions == 0 {
+		return nil, errors.New("merkle block contains no transactions")
+	}
+
+	// Check for excessively high numbers of transactions.
+	if msg.Transactions > maxMerkleBlockTxns {
+		str := fmt.Sprintf("merkle block claims %d transactions which "+
+			"exceeds the max of %d", msg.Transactions,
+			maxMerkleBlockTxns)
+		return nil, errors.New(str)
+	}
+
+	// There can never be more hashes provided than one for every
+	// transaction.
+	if uint32(len(msg.Hashes)) > msg.Transactions {
+		str := fmt.Sprintf("merkle block contains %d hashes for %d "+
+			"transactions", len(msg.Hashes), msg.Transactions)
+		return nil, errors.New(str)
+	}
+
+	// There must be at least one bit per node in the partial tree, and at
+	// least one node per hash.
+	if len(msg.Flags)*8 < len(msg.Hashes) {
+		str := fmt.Sprintf("merkle block contains %d flag bits for %d "+
+			"hashes", len(msg.Flags)*8, len(msg.Hashes))
+		return nil, errors.New(str)
+	}
+
+	// Calculate the number of merkle branches (height) in the tree.
+	p := partialMerkleTree{
+		numTx:  msg.Transactions,
+		hashes: msg.Hashes,
+		flags:  msg.Flags,
+	}
+	height := uint32(0)
+	for p.calcTreeWidth(height) > 1 {
+		height++
+	}
+
+	// Traverse the partial tree to calculate the merkle root.
+	root := p.traverseAndExtract(height, 0)
+	if p.bad {
+		return nil, errors.New("merkle block contains a malformed " +
+			"partial merkle tree")
+	}
+
+	// All hashes and all bytes of flags must have been consumed.
+	if (p.bitsUsed+7)/8 != uint32(len(msg.Flags)) {
+		return nil, errors.New("merkle block contains unused flag bits")
+	}
+	if p.hashUsed != uint32(len(msg.Hashes)) {
+		return nil, errors.New("merkle block contains unused hashes")
+	}
+
+	// The calculated root must match the one committed to by the header.
+	if !root.IsEqual(&msg.Header.MerkleRoot) {
+		str := fmt.Sprintf("merkle block root %v does not match the "+
+			"header merkle root %v", root, msg.Header.MerkleRoot)
+		return nil, errors.New(str)
+	}
+
+	return p.matches, nil
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"testing"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -72,3 +73,123 @@ func TestMerkleBlock3(t *testing.T) {
 		return
 	}
 }
+
+// buildTestBlock returns a block with the given number of unique transactions
+// and a valid merkle root.
+func buildTestBlock(numTx int) *btcutil.Block {
+	var msgBlock wire.MsgBlock
+	for i := 0; i < numTx; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(i)}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		msgBlock.AddTransaction(tx)
+	}
+	blk := btcutil.NewBlock(&msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(blk.Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return btcutil.NewBlock(&msgBlock)
+}
+
+// TestExtractMatches ensures partial merkle trees created for specific
+// transaction hashes round trip through ExtractMatches and that malformed
+// trees are rejected.
+func TestExtractMatches(t *testing.T) {
+	tests := []struct {
+		numTx   int
+		matches []int
+	}{
+		{numTx: 1, matches: []int{0}},
+		{numTx: 1, matches: nil},
+		{numTx: 2, matches: []int{1}},
+		{numTx: 7, matches: []int{0, 6}},
+		{numTx: 9, matches: []int{2, 3, 8}},
+		{numTx: 16, matches: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}},
+	}
+
+	for i, test := range tests {
+		blk := buildTestBlock(test.numTx)
+		var want []*chainhash.Hash
+		for _, idx := range test.matches {
+			want = append(want, blk.Transactions()[idx].Hash())
+		}
+
+		mBlock, indices := bloom.NewMerkleBlockFromTxHashes(blk, want)
+		if len(indices) != len(test.matches) {
+			t.Errorf("test %d: unexpected matched indices - got %v, "+
+				"want %v", i, indices, test.matches)
+			continue
+		}
+
+		got, err := bloom.ExtractMatches(mBlock)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if len(got) != len(want) {
+			t.Errorf("test %d: unexpected number of matches - got "+
+				"%d, want %d", i, len(got), len(want))
+			continue
+		}
+		for j := range want {
+			if !got[j].IsEqual(want[j]) {
+				t.Errorf("test %d: mismatched match %d - got %v, "+
+					"want %v", i, j, got[j], want[j])
+			}
+		}
+	}
+
+	// Ensure a variety of malformed merkle blocks are rejected.
+	blk := buildTestBlock(5)
+	txHashes := []*chainhash.Hash{blk.Transactions()[3].Hash()}
+	valid, _ := bloom.NewMerkleBlockFromTxHashes(blk, txHashes)
+	copyBlock := func() *wire.MsgMerkleBlock {
+		mBlock := *valid
+		mBlock.Hashes = append([]*chainhash.Hash(nil), valid.Hashes...)
+		mBlock.Flags = append([]byte(nil), valid.Flags...)
+		return &mBlock
+	}
+
+	badTests := []struct {
+		name   string
+		mutate func(*wire.MsgMerkleBlock)
+	}{{
+		name:   "no transactions",
+		mutate: func(m *wire.MsgMerkleBlock) { m.Transactions = 0 },
+	}, {
+		name:   "too many transactions",
+		mutate: func(m *wire.MsgMerkleBlock) { m.Transactions = 1 << 30 },
+	}, {
+		name:   "more hashes than transactions",
+		mutate: func(m *wire.MsgMerkleBlock) { m.Transactions = 1 },
+	}, {
+		name:   "missing flags",
+		mutate: func(m *wire.MsgMerkleBlock) { m.Flags = nil },
+	}, {
+		name: "unused hashes",
+		mutate: func(m *wire.MsgMerkleBlock) {
+			m.Hashes = append(m.Hashes, &chainhash.Hash{})
+		},
+	}, {
+		name: "unused flags",
+		mutate: func(m *wire.MsgMerkleBlock) {
+			m.Flags = append(m.Flags, 0x00)
+		},
+	}, {
+		name: "bad merkle root",
+		mutate: func(m *wire.MsgMerkleBlock) {
+			m.Header.MerkleRoot = chainhash.Hash{0x01}
+		},
+	}, {
+		name: "modified hash",
+		mutate: func(m *wire.MsgMerkleBlock) {
+			m.Hashes[0] = &chainhash.Hash{0x01}
+		},
+	}}
+	for _, test := range badTests {
+		mBlock := copyBlock()
+		test.mutate(mBlock)
+		if _, err := bloom.ExtractMatches(mBlock); err == nil {
+			t.Errorf("%s: did not receive expected error", test.name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"strings"
+)
+
+const (
+	// ChecksumLen is the number of characters in a descriptor checksum.
+	ChecksumLen = 8
+
+	// inputCharset is the set of characters that may appear in a
+	// descriptor.  The position of each character is used to compute the
+	// checksum, and the characters are ordered so that the most common
+	// ones in descriptors are grouped together in the low 5 bits.
+	inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// checksumCharset is the bech32 character set used to encode the
+	// checksum.
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// polyMod computes the next value of the checksum BCH code given the current
+// state and the next 5-bit value.
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// Checksum returns the checksum of the passed descriptor, which must not
+// include a checksum itself.  ErrInvalidCharacter is returned if the
+// descriptor contains a character outside of the descriptor character set.
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls := 0
+	clsCount := 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos == -1 {
+			return "", ErrInvalidCharacter
+		}
+
+		// Emit a symbol for the position inside the group, for every
+		// character.
+		c = polyMod(c, pos&31)
+
+		// Accumulate the group numbers and emit them for every three
+		// characters.
+		cls = cls*3 + (pos >> 5)
+		clsCount++
+		if clsCount == 3 {
+			c = polyMod(c, cls)
+			cls = 0
+			clsCount = 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+
+	// Shift further to determine the checksum.
+	for i := 0; i < ChecksumLen; i++ {
+		c = polyMod(c, 0)
+	}
+
+	// Prevent appending zeroes from not affecting the checksum.
+	c ^= 1
+
+	var checksum [ChecksumLen]byte
+	for i := 0; i < ChecksumLen; i++ {
+		checksum[i] = checksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(checksum[:]), nil
+}
+
+// AddChecksum returns the passed descriptor with its checksum appended.
+func AddChecksum(desc string) (string, error) {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// splitChecksum splits the passed descriptor into the descriptor itself and
+// its checksum, if any.  The checksum is verified when present, and
+// ErrMissingChecksum is returned when it is absent but required.
+func splitChecksum(desc string, requireChecksum bool) (string, string, error) {
+	idx := strings.IndexByte(desc, '#')
+	if idx == -1 {
+		if requireChecksum {
+			return "", "", ErrMissingChecksum
+		}
+		if _, err := Checksum(desc); err != nil {
+			return "", "", err
+		}
+		return desc, "", nil
+	}
+
+	desc, checksum := desc[:idx], desc[idx+1:]
+	if len(checksum) != ChecksumLen {
+		return "", "", ErrInvalidChecksum
+	}
+	want, err := Checksum(desc)
+	if err != nil {
+		return "", "", err
+	}
+	if checksum != want {
+		return "", "", ErrInvalidChecksum
+	}
+	return desc, checksum, nil
+}
@@ -0,0 +1,732 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// maxBareMultiSigKeys is the maximum number of keys allowed in a
+	// multisig script which isn't wrapped in P2SH or P2WSH.  Larger bare
+	// multisig scripts are nonstandard.
+	maxBareMultiSigKeys = 3
+
+	// maxTapTreeDepth is the maximum depth of a taproot script tree.
+	maxTapTreeDepth = 128
+)
+
+// scriptContext describes where a script expression appears, which
+// determines the expressions and keys that are allowed in it.
+type scriptContext uint8
+
+const (
+	// contextTop is the top level of a descriptor.
+	contextTop scriptContext = iota
+
+	// contextP2SH is inside of an sh() expression.
+	contextP2SH
+
+	// contextP2WSH is inside of a wsh() expression.
+	contextP2WSH
+
+	// contextTapLeaf is a leaf in the script tree of a tr() expression.
+	contextTapLeaf
+)
+
+// scriptType identifies the function of a script expression.
+type scriptType uint8
+
+const (
+	scriptPK scriptType = iota
+	scriptPKH
+	scriptWPKH
+	scriptSH
+	scriptWSH
+	scriptMulti
+	scriptSortedMulti
+	scriptTR
+	scriptAddr
+	scriptRaw
+)
+
+// scriptNames maps script types to their names in descriptors.
+var scriptNames = map[scriptType]string{
+	scriptPK:          "pk",
+	scriptPKH:         "pkh",
+	scriptWPKH:        "wpkh",
+	scriptSH:          "sh",
+	scriptWSH:         "wsh",
+	scriptMulti:       "multi",
+	scriptSortedMulti: "sortedmulti",
+	scriptTR:          "tr",
+	scriptAddr:        "addr",
+	scriptRaw:         "raw",
+}
+
+// scriptExpr is a script expression in a descriptor.
+type scriptExpr struct {
+	typ scriptType
+
+	// keys holds the keys of pk, pkh, wpkh, multi, sortedmulti and tr
+	// expressions, and threshold the number of signatures required by a
+	// multisig.
+	keys      []*keyExpr
+	threshold int
+
+	// sub is the script wrapped by sh and wsh expressions, and tree the
+	// optional script tree of tr expressions.
+	sub  *scriptExpr
+	tree *tapTree
+
+	// addr and script are the contents of addr and raw expressions.
+	addr   btcutil.Address
+	script []byte
+}
+
+// tapTree is a node in the script tree of a tr() expression.  It is either a
+// leaf with a script or a branch with two children.
+type tapTree struct {
+	leaf        *scriptExpr
+	left, right *tapTree
+}
+
+// String returns the script tree in descriptor form.
+func (t *tapTree) String(private bool) string {
+	if t.leaf != nil {
+		return t.leaf.String(private)
+	}
+	return "{" + t.left.String(private) + "," + t.right.String(private) + "}"
+}
+
+// String returns the script expression in descriptor form.  Private keys are
+// only included when the private flag is set.
+func (s *scriptExpr) String(private bool) string {
+	var args []string
+	switch s.typ {
+	case scriptSH, scriptWSH:
+		args = append(args, s.sub.String(private))
+	case scriptMulti, scriptSortedMulti:
+		args = append(args, strconv.Itoa(s.threshold))
+	case scriptAddr:
+		args = append(args, s.addr.EncodeAddress())
+	case scriptRaw:
+		args = append(args, hex.EncodeToString(s.script))
+	}
+	for _, key := range s.keys {
+		args = append(args, key.String(private))
+	}
+	if s.tree != nil {
+		args = append(args, s.tree.String(private))
+	}
+	return scriptNames[s.typ] + "(" + strings.Join(args, ",") + ")"
+}
+
+// walkKeys calls the passed function with every key in the script expression
+// and its sub-expressions.
+func (s *scriptExpr) walkKeys(f func(*keyExpr)) {
+	for _, key := range s.keys {
+		f(key)
+	}
+	if s.sub != nil {
+		s.sub.walkKeys(f)
+	}
+	var walkTree func(*tapTree)
+	walkTree = func(t *tapTree) {
+		if t == nil {
+			return
+		}
+		if t.leaf != nil {
+			t.leaf.walkKeys(f)
+		}
+		walkTree(t.left)
+		walkTree(t.right)
+	}
+	walkTree(s.tree)
+}
+
+// Expansion houses the scripts and address produced by expanding a descriptor
+// at a specific index.
+type Expansion struct {
+	// OutputScript is the script to be used in transaction outputs.
+	OutputScript []byte
+
+	// RedeemScript is the script committed to by a P2SH output script, if
+	// any.
+	RedeemScript []byte
+
+	// WitnessScript is the script committed to by a P2WSH output script,
+	// if any.
+	WitnessScript []byte
+
+	// Address is the address corresponding to the output script, or nil
+	// when there isn't one, such as for P2PK and bare multisig scripts.
+	Address btcutil.Address
+}
+
+// multiSigScript returns a multisig script requiring the passed number of
+// signatures from the passed serialized public keys.
+func multiSigScript(threshold int, pubKeys [][]byte) ([]byte, error) {
+	builder := txscript.NewScriptBuilder().AddInt64(int64(threshold))
+	for _, pubKey := range pubKeys {
+		builder.AddData(pubKey)
+	}
+	builder.AddInt64(int64(len(pubKeys)))
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	return builder.Script()
+}
+
+// expand expands the script expression at the passed index into the passed
+// expansion and returns the script it produces.
+func (s *scriptExpr) expand(index uint32, exp *Expansion,
+	params *chaincfg.Params) ([]byte, error) {
+
+	pubKeys := make([][]byte, 0, len(s.keys))
+	for _, key := range s.keys {
+		pubKey, err := key.pubKeyAt(index)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	switch s.typ {
+	case scriptPK:
+		// P2PK outputs have no address.  The encoding of
+		// btcutil.AddressPubKey is the one of the P2PKH address of
+		// the key, which produces a different output script.
+		exp.Address = nil
+		return txscript.NewScriptBuilder().AddData(pubKeys[0]).
+			AddOp(txscript.OP_CHECKSIG).Script()
+
+	case scriptPKH:
+		addr, err := btcutil.NewAddressPubKeyHash(
+			btcutil.Hash160(pubKeys[0]), params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exp.Address = addr
+		return txscript.PayToAddrScript(addr)
+
+	case scriptWPKH:
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(
+			btcutil.Hash160(pubKeys[0]), params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exp.Address = addr
+		return txscript.PayToAddrScript(addr)
+
+	case scriptSH:
+		redeemScript, err := s.sub.expand(index, exp, params)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := btcutil.NewAddressScriptHash(redeemScript, params)
+		if err != nil {
+			return nil, err
+		}
+		exp.RedeemScript = redeemScript
+		exp.Address = addr
+		return txscript.PayToAddrScript(addr)
+
+	case scriptWSH:
+		witnessScript, err := s.sub.expand(index, exp, params)
+		if err != nil {
+			return nil, err
+		}
+		scriptHash := sha256.Sum256(witnessScript)
+		addr, err := btcutil.NewAddressWitnessScriptHash(
+			scriptHash[:], params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exp.WitnessScript = witnessScript
+		exp.Address = addr
+		return txscript.PayToAddrScript(addr)
+
+	case scriptMulti, scriptSortedMulti:
+		if s.typ == scriptSortedMulti {
+			sort.Slice(pubKeys, func(i, j int) bool {
+				return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+			})
+		}
+		exp.Address = nil
+		return multiSigScript(s.threshold, pubKeys)
+
+	case scriptTR:
+		internalKey, err := schnorr.ParsePubKey(pubKeys[0])
+		if err != nil {
+			return nil, err
+		}
+		outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+		if s.tree != nil {
+			root, err := s.tree.tapNode(index, params)
+			if err != nil {
+				return nil, err
+			}
+			rootHash := root.TapHash()
+			outputKey = txscript.ComputeTaprootOutputKey(
+				internalKey, rootHash[:],
+			)
+		}
+		addr, err := btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(outputKey), params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exp.Address = addr
+		return txscript.PayToAddrScript(addr)
+
+	case scriptAddr:
+		exp.Address = s.addr
+		return txscript.PayToAddrScript(s.addr)
+
+	case scriptRaw:
+		exp.Address = nil
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			s.script, params,
+		)
+		if err == nil && len(addrs) == 1 &&
+			class != txscript.MultiSigTy &&
+			class != txscript.PubKeyTy {
+
+			exp.Address = addrs[0]
+		}
+		return s.script, nil
+	}
+
+	return nil, fmt.Errorf("unknown script type %d", s.typ)
+}
+
+// tapNode returns the script tree as a txscript.TapNode with the keys derived
+// at the passed index.
+func (t *tapTree) tapNode(index uint32,
+	params *chaincfg.Params) (txscript.TapNode, error) {
+
+	if t.leaf != nil {
+		var exp Expansion
+		script, err := t.leaf.expand(index, &exp, params)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.NewBaseTapLeaf(script), nil
+	}
+
+	left, err := t.left.tapNode(index, params)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.right.tapNode(index, params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewTapBranch(left, right), nil
+}
+
+// splitArgs splits the passed string at the commas which aren't nested inside
+// of parentheses, brackets or braces.
+func splitArgs(str string) ([]string, error) {
+	var args []string
+	var depth int
+	start := 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unexpected %q character",
+					str[i])
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced expression")
+	}
+	return append(args, str[start:]), nil
+}
+
+// parseFunc splits an expression of the form name(args) into its name and its
+// arguments.  An error is returned when the expression isn't of that form.
+func parseFunc(expr string) (string, []string, error) {
+	open := strings.IndexByte(expr, '(')
+	if open == -1 || !strings.HasSuffix(expr, ")") {
+		return "", nil, fmt.Errorf("%q is not a valid script "+
+			"expression", expr)
+	}
+	args, err := splitArgs(expr[open+1 : len(expr)-1])
+	if err != nil {
+		return "", nil, fmt.Errorf("%q is not a valid script "+
+			"expression: %v", expr, err)
+	}
+	return expr[:open], args, nil
+}
+
+// parseScript parses a script expression in the passed context.
+func parseScript(expr string, ctx scriptContext,
+	params *chaincfg.Params) (*scriptExpr, error) {
+
+	name, args, err := parseFunc(expr)
+	if err != nil {
+		return nil, err
+	}
+	wantArgs := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s() expects %d argument(s), got %d",
+				name, n, len(args))
+		}
+		return nil
+	}
+
+	// Keys are serialized as x-only public keys in tapscript leaves and
+	// uncompressed keys are only allowed outside of segwit scripts.
+	allowUncompressed := ctx == contextTop || ctx == contextP2SH
+	xOnly := ctx == contextTapLeaf
+
+	switch name {
+	case "pk", "pkh":
+		if name == "pkh" && ctx == contextTapLeaf {
+			return nil, errors.New("pkh() is not allowed in " +
+				"tapscript")
+		}
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		key, err := parseKey(args[0], allowUncompressed, xOnly, params)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %v", name, err)
+		}
+		typ := scriptPK
+		if name == "pkh" {
+			typ = scriptPKH
+		}
+		return &scriptExpr{typ: typ, keys: []*keyExpr{key}}, nil
+
+	case "wpkh":
+		if ctx != contextTop && ctx != contextP2SH {
+			return nil, errors.New("wpkh() is only allowed at the " +
+				"top level or inside sh()")
+		}
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		key, err := parseKey(args[0], false, false, params)
+		if err != nil {
+			return nil, fmt.Errorf("wpkh(): %v", err)
+		}
+		return &scriptExpr{typ: scriptWPKH, keys: []*keyExpr{key}}, nil
+
+	case "sh", "wsh":
+		subCtx := contextP2SH
+		if name == "sh" && ctx != contextTop {
+			return nil, errors.New("sh() is only allowed at the " +
+				"top level")
+		}
+		if name == "wsh" {
+			if ctx != contextTop && ctx != contextP2SH {
+				return nil, errors.New("wsh() is only allowed " +
+					"at the top level or inside sh()")
+			}
+			subCtx = contextP2WSH
+		}
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		sub, err := parseScript(args[0], subCtx, params)
+		if err != nil {
+			return nil, err
+		}
+		typ := scriptSH
+		if name == "wsh" {
+			typ = scriptWSH
+		}
+		return &scriptExpr{typ: typ, sub: sub}, nil
+
+	case "multi", "sortedmulti":
+		if ctx == contextTapLeaf {
+			return nil, fmt.Errorf("%s() is not allowed in "+
+				"tapscript", name)
+		}
+		return parseMultiSig(name, args, ctx, params)
+
+	case "tr":
+		if ctx != contextTop {
+			return nil, errors.New("tr() is only allowed at the " +
+				"top level")
+		}
+		return parseTaproot(args, params)
+
+	case "addr":
+		if ctx != contextTop {
+			return nil, errors.New("addr() is only allowed at the " +
+				"top level")
+		}
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		addr, err := btcutil.DecodeAddress(args[0], params)
+		if err != nil || !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %q is not valid",
+				args[0])
+		}
+		return &scriptExpr{typ: scriptAddr, addr: addr}, nil
+
+	case "raw":
+		if ctx != contextTop {
+			return nil, errors.New("raw() is only allowed at the " +
+				"top level")
+		}
+		if err := wantArgs(1); err != nil {
+			return nil, err
+		}
+		if !isHex(args[0]) {
+			return nil, fmt.Errorf("raw script %q is not hex",
+				args[0])
+		}
+		script, _ := hex.DecodeString(args[0])
+		return &scriptExpr{typ: scriptRaw, script: script}, nil
+	}
+
+	return nil, fmt.Errorf("%s() is not a valid descriptor function", name)
+}
+
+// parseMultiSig parses the arguments of a multi() or sortedmulti() expression
+// in the passed context.
+func parseMultiSig(name string, args []string, ctx scriptContext,
+	params *chaincfg.Params) (*scriptExpr, error) {
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("%s() expects a threshold and at "+
+			"least one key", name)
+	}
+	numKeys := len(args) - 1
+	threshold, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil || threshold < 1 || threshold > uint64(numKeys) {
+		return nil, fmt.Errorf("%s(): multisig threshold %q is not "+
+			"between 1 and %d", name, args[0], numKeys)
+	}
+	if numKeys > txscript.MaxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("%s(): cannot have %d keys in a "+
+			"multisig, only at most %d", name, numKeys,
+			txscript.MaxPubKeysPerMultiSig)
+	}
+	if ctx == contextTop && numKeys > maxBareMultiSigKeys {
+		return nil, fmt.Errorf("%s(): cannot have %d keys in a bare "+
+			"multisig, only at most %d", name, numKeys,
+			maxBareMultiSigKeys)
+	}
+
+	allowUncompressed := ctx == contextTop || ctx == contextP2SH
+	s := &scriptExpr{
+		typ:       scriptMulti,
+		threshold: int(threshold),
+		keys:      make([]*keyExpr, 0, numKeys),
+	}
+	if name == "sortedmulti" {
+		s.typ = scriptSortedMulti
+	}
+	scriptSize := 3
+	for _, arg := range args[1:] {
+		key, err := parseKey(arg, allowUncompressed, false, params)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %v", name, err)
+		}
+		s.keys = append(s.keys, key)
+
+		keySize := 33
+		if !key.compressed {
+			keySize = 65
+		}
+		scriptSize += keySize + 1
+	}
+
+	// The redeem script of a P2SH output must be pushed by the signature
+	// script, so it's limited to the maximum script element size.
+	if ctx == contextP2SH && scriptSize > txscript.MaxScriptElementSize {
+		return nil, fmt.Errorf("%s(): P2SH script is too large, %d "+
+			"bytes is larger than %d bytes", name, scriptSize,
+			txscript.MaxScriptElementSize)
+	}
+	return s, nil
+}
+
+// parseTaproot parses the arguments of a tr() expression.
+func parseTaproot(args []string, params *chaincfg.Params) (*scriptExpr, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("tr() expects 1 or 2 arguments, got %d",
+			len(args))
+	}
+	key, err := parseKey(args[0], false, true, params)
+	if err != nil {
+		return nil, fmt.Errorf("tr(): %v", err)
+	}
+	s := &scriptExpr{typ: scriptTR, keys: []*keyExpr{key}}
+	if len(args) == 2 {
+		s.tree, err = parseTapTree(args[1], 0, params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseTapTree parses a taproot script tree at the passed depth.  A tree is
+// either a script expression or a pair of trees enclosed in braces.
+func parseTapTree(expr string, depth int,
+	params *chaincfg.Params) (*tapTree, error) {
+
+	if !strings.HasPrefix(expr, "{") {
+		leaf, err := parseScript(expr, contextTapLeaf, params)
+		if err != nil {
+			return nil, err
+		}
+		return &tapTree{leaf: leaf}, nil
+	}
+
+	if depth >= maxTapTreeDepth {
+		return nil, fmt.Errorf("tr(): max script tree depth of %d "+
+			"exceeded", maxTapTreeDepth)
+	}
+	if !strings.HasSuffix(expr, "}") {
+		return nil, fmt.Errorf("tr(): script tree %q is missing a "+
+			"closing brace", expr)
+	}
+	branches, err := splitArgs(expr[1 : len(expr)-1])
+	if err != nil || len(branches) != 2 {
+		return nil, fmt.Errorf("tr(): script tree %q must have "+
+			"exactly two branches", expr)
+	}
+	left, err := parseTapTree(branches[0], depth+1, params)
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseTapTree(branches[1], depth+1, params)
+	if err != nil {
+		return nil, err
+	}
+	return &tapTree{left: left, right: right}, nil
+}
+
+// Descriptor is a parsed output script descriptor as described by BIP0380
+// and the related BIPs.
+type Descriptor struct {
+	script *scriptExpr
+	params *chaincfg.Params
+}
+
+// Parse parses the passed output script descriptor for the passed network.
+// The checksum is verified when the descriptor has one, and is required to be
+// present when requireChecksum is set.
+func Parse(desc string, requireChecksum bool,
+	params *chaincfg.Params) (*Descriptor, error) {
+
+	desc, _, err := splitChecksum(desc, requireChecksum)
+	if err != nil {
+		return nil, err
+	}
+	script, err := parseScript(desc, contextTop, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{script: script, params: params}, nil
+}
+
+// String returns the descriptor in canonical form along with its checksum.
+// Private keys are replaced by their public keys, and hardened derivation
+// steps are marked with an apostrophe.
+func (d *Descriptor) String() string {
+	// The canonical form only consists of valid characters, so adding
+	// the checksum can't fail.
+	desc, _ := AddChecksum(d.script.String(false))
+	return desc
+}
+
+// PrivateString returns the descriptor in canonical form along with its
+// checksum, including any private keys it was parsed with.
+func (d *Descriptor) PrivateString() string {
+	desc, _ := AddChecksum(d.script.String(true))
+	return desc
+}
+
+// IsRange returns whether the descriptor contains ranged keys, in which case
+// it expands to a different script for every index.
+func (d *Descriptor) IsRange() bool {
+	var isRange bool
+	d.script.walkKeys(func(key *keyExpr) {
+		isRange = isRange || key.isRange()
+	})
+	return isRange
+}
+
+// IsSolvable returns whether the descriptor has all of the information
+// needed to sign for its outputs given the private keys.  Only addr() and
+// raw() descriptors are not solvable.
+func (d *Descriptor) IsSolvable() bool {
+	return d.script.typ != scriptAddr && d.script.typ != scriptRaw
+}
+
+// HasPrivateKeys returns whether the descriptor contains at least one
+// private key.
+func (d *Descriptor) HasPrivateKeys() bool {
+	var hasPrivateKeys bool
+	d.script.walkKeys(func(key *keyExpr) {
+		hasPrivateKeys = hasPrivateKeys || key.hasPrivateKey()
+	})
+	return hasPrivateKeys
+}
+
+// Expand returns the scripts and address the descriptor produces at the
+// passed index.  The index is ignored when the descriptor isn't ranged, and
+// must be less than hdkeychain.HardenedKeyStart otherwise.
+func (d *Descriptor) Expand(index uint32) (*Expansion, error) {
+	if index >= hdkeychain.HardenedKeyStart && d.IsRange() {
+		return nil, ErrInvalidIndex
+	}
+
+	var exp Expansion
+	script, err := d.script.expand(index, &exp, d.params)
+	if err != nil {
+		return nil, err
+	}
+	exp.OutputScript = script
+	return &exp, nil
+}
+
+// Address returns the address the descriptor produces at the passed index.
+// ErrNoAddress is returned when the output script has no address form.
+func (d *Descriptor) Address(index uint32) (btcutil.Address, error) {
+	exp, err := d.Expand(index)
+	if err != nil {
+		return nil, err
+	}
+	if exp.Address == nil {
+		return nil, ErrNoAddress
+	}
+	return exp.Address, nil
+}
@@ -0,0 +1,377 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// testXPub and testXPrv are unrelated extended keys used throughout
+	// the tests.
+	testXPub = "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgb" +
+		"mJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL"
+	testXPrv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPq" +
+		"jiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+)
+
+// sha256Hash returns the SHA256 hash of the passed data.
+func sha256Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// TestChecksum ensures descriptor checksums are calculated and verified
+// correctly.
+func TestChecksum(t *testing.T) {
+	checksum, err := Checksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checksum != "89f8spxm" {
+		t.Fatalf("unexpected checksum: got %s, want 89f8spxm", checksum)
+	}
+
+	tests := []struct {
+		desc            string
+		requireChecksum bool
+		err             error
+	}{
+		{"raw(deadbeef)#89f8spxm", true, nil},
+		{"raw(deadbeef)", false, nil},
+		{"raw(deadbeef)", true, ErrMissingChecksum},
+		{"raw(deadbeef)#", false, ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spxmx", false, ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spxn", false, ErrInvalidChecksum},
+		{"raw(deedbeef)#89f8spxm", false, ErrInvalidChecksum},
+		{"raw(deadbeef)#89f8spxm#89f8spxm", false, ErrInvalidChecksum},
+		{"raw(deadbeefé)", false, ErrInvalidCharacter},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.desc, test.requireChecksum,
+			&chaincfg.MainNetParams)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: unexpected error: got %v, want %v",
+				test.desc, err, test.err)
+		}
+	}
+}
+
+// TestParse ensures valid descriptors are parsed, normalized and expanded
+// correctly.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		desc       string
+		canonical  string
+		script     string
+		addr       string
+		isRange    bool
+		isSolvable bool
+		hasPrivate bool
+	}{{
+		desc:       "pk(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)",
+		canonical:  "pk(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)#gn28ywm7",
+		script:     "210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac",
+		isSolvable: true,
+	}, {
+		desc:       "pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)",
+		canonical:  "pkh(02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5)#8fhd9pwu",
+		script:     "76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac",
+		addr:       "1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+		isSolvable: true,
+	}, {
+		desc:       "tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		canonical:  "tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)#dh4fyxrd",
+		script:     "512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11",
+		addr:       "bc1pw74tdcrxlzn5r8z6ku2vztr86fgq0m245s72mjktf4afwzsf8ugs0gs8zu",
+		isSolvable: true,
+	}, {
+		desc:       "sh(multi(2,022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01,03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe))",
+		canonical:  "sh(multi(2,022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01,03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe))#y9zthqta",
+		script:     "a914a6a8b030a38762f4c1f5cbe387b61a3c5da5cd2687",
+		addr:       "3GtEB3yg3r5de2cDJG48SkQwxfxJumKQdN",
+		isSolvable: true,
+	}, {
+		// The keys are sorted in the script.
+		desc:       "sortedmulti(1,03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe,022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01)",
+		canonical:  "sortedmulti(1,03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe,022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01)#8dlsxf35",
+		script:     "5121022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a012103acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe52ae",
+		isSolvable: true,
+	}, {
+		// Private keys are replaced by public keys and hardened steps
+		// are normalized to apostrophes.
+		desc:       "sh(wpkh(" + testXPrv + "/10/20/30/40/*h))",
+		canonical:  "sh(wpkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8/10/20/30/40/*'))#cgrkhzrc",
+		script:     "a9149a4d9901d6af519b2a23d4a2f51650fcba87ce7b87",
+		addr:       "3Fktwfew1dVGUoDoA1g8jJHFmPTgdq7Wwk",
+		isRange:    true,
+		isSolvable: true,
+		hasPrivate: true,
+	}, {
+		desc:       "addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)",
+		canonical:  "addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP)#anrx2lt5",
+		script:     "76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac",
+		addr:       "1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+		isSolvable: false,
+	}, {
+		desc:       "raw(76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac)",
+		canonical:  "raw(76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac)#ez0xj3l2",
+		script:     "76a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac",
+		addr:       "1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP",
+		isSolvable: false,
+	}, {
+		// P2PK output scripts have no address.
+		desc:       "raw(210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac)",
+		canonical:  "raw(210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac)#nprh0rpt",
+		script:     "210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac",
+		isSolvable: false,
+	}, {
+		desc:       "raw(6a)",
+		canonical:  "raw(6a)#4mhr9ur5",
+		script:     "6a",
+		isSolvable: false,
+	}}
+
+	for _, test := range tests {
+		desc, err := Parse(test.desc, false, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if desc.String() != test.canonical {
+			t.Errorf("%s: unexpected canonical form: got %s, want %s",
+				test.desc, desc.String(), test.canonical)
+		}
+		if desc.IsRange() != test.isRange ||
+			desc.IsSolvable() != test.isSolvable ||
+			desc.HasPrivateKeys() != test.hasPrivate {
+
+			t.Errorf("%s: unexpected flags: range %v, solvable "+
+				"%v, private %v", test.desc, desc.IsRange(),
+				desc.IsSolvable(), desc.HasPrivateKeys())
+		}
+
+		exp, err := desc.Expand(0)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if hex.EncodeToString(exp.OutputScript) != test.script {
+			t.Errorf("%s: unexpected script: got %x, want %s",
+				test.desc, exp.OutputScript, test.script)
+		}
+		addr, err := desc.Address(0)
+		switch {
+		case test.addr == "" && !errors.Is(err, ErrNoAddress):
+			t.Errorf("%s: unexpected address error: got %v, "+
+				"want %v", test.desc, err, ErrNoAddress)
+		case test.addr != "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+		case test.addr != "" && addr.EncodeAddress() != test.addr:
+			t.Errorf("%s: unexpected address: got %s, want %s",
+				test.desc, addr.EncodeAddress(), test.addr)
+		}
+
+		// The canonical form must parse to the same descriptor.
+		reparsed, err := Parse(desc.String(), true,
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Errorf("%s: unable to parse canonical form: %v",
+				test.desc, err)
+			continue
+		}
+		if reparsed.String() != desc.String() {
+			t.Errorf("%s: canonical form is not stable: %s",
+				test.desc, reparsed.String())
+		}
+	}
+}
+
+// TestExpandRange ensures ranged descriptors expand to the scripts of the
+// derived children.
+func TestExpandRange(t *testing.T) {
+	extKey, err := hdkeychain.NewKeyFromString(testXPub)
+	if err != nil {
+		t.Fatalf("unable to parse extended key: %v", err)
+	}
+	origin := "[d34db33f/44'/0'/0']"
+	desc, err := Parse("wsh(multi(1,"+origin+testXPub+"/1/*,"+
+		"03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe))",
+		false, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to parse descriptor: %v", err)
+	}
+	if !desc.IsRange() {
+		t.Fatalf("descriptor is not ranged")
+	}
+
+	for index := uint32(0); index < 3; index++ {
+		branch, err := extKey.Derive(1)
+		if err != nil {
+			t.Fatalf("unable to derive key: %v", err)
+		}
+		child, err := branch.Derive(index)
+		if err != nil {
+			t.Fatalf("unable to derive key: %v", err)
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			t.Fatalf("unable to get public key: %v", err)
+		}
+		other, _ := hex.DecodeString("03acd484e2f0c7f65309ad178a9f559" +
+			"abde09796974c57e714c35f110dfc27ccbe")
+		witnessScript, err := multiSigScript(1, [][]byte{
+			pubKey.SerializeCompressed(), other,
+		})
+		if err != nil {
+			t.Fatalf("unable to build script: %v", err)
+		}
+		addr, err := btcutil.NewAddressWitnessScriptHash(
+			sha256Hash(witnessScript), &chaincfg.MainNetParams,
+		)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+
+		exp, err := desc.Expand(index)
+		if err != nil {
+			t.Fatalf("unable to expand descriptor: %v", err)
+		}
+		if hex.EncodeToString(exp.WitnessScript) !=
+			hex.EncodeToString(witnessScript) {
+
+			t.Fatalf("index %d: unexpected witness script: got "+
+				"%x, want %x", index, exp.WitnessScript,
+				witnessScript)
+		}
+		if exp.Address.EncodeAddress() != addr.EncodeAddress() {
+			t.Fatalf("index %d: unexpected address: got %s, "+
+				"want %s", index, exp.Address, addr)
+		}
+	}
+
+	if _, err := desc.Expand(hdkeychain.HardenedKeyStart); !errors.Is(
+		err, ErrInvalidIndex) {
+
+		t.Fatalf("unexpected error: got %v, want %v", err,
+			ErrInvalidIndex)
+	}
+
+	// Hardened derivation requires the extended private key.
+	desc, err = Parse("pkh("+testXPub+"/*')", false,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to parse descriptor: %v", err)
+	}
+	if _, err := desc.Expand(0); !errors.Is(err, ErrHardenedFromPublic) {
+		t.Fatalf("unexpected error: got %v, want %v", err,
+			ErrHardenedFromPublic)
+	}
+}
+
+// TestTaprootTree ensures tr() descriptors with script trees commit to the
+// expected tapscript merkle root.
+func TestTaprootTree(t *testing.T) {
+	internalKeyHex := "a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82" +
+		"b8b56ac1c540c5bd"
+	leafKeyHex := "669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbe" +
+		"c52421adbd0"
+	desc, err := Parse("tr("+internalKeyHex+",{pk("+leafKeyHex+"),pk("+
+		testXPub+"/0/*)})", false, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to parse descriptor: %v", err)
+	}
+
+	extKey, err := hdkeychain.NewKeyFromString(testXPub)
+	if err != nil {
+		t.Fatalf("unable to parse extended key: %v", err)
+	}
+	branch, _ := extKey.Derive(0)
+	child, _ := branch.Derive(5)
+	childKey, _ := child.ECPubKey()
+
+	leafKey, _ := hex.DecodeString(leafKeyHex)
+	leafScript := func(key []byte) []byte {
+		script, _ := txscript.NewScriptBuilder().AddData(key).
+			AddOp(txscript.OP_CHECKSIG).Script()
+		return script
+	}
+	tree := txscript.AssembleTaprootScriptTree(
+		txscript.NewBaseTapLeaf(leafScript(leafKey)),
+		txscript.NewBaseTapLeaf(leafScript(
+			schnorr.SerializePubKey(childKey),
+		)),
+	)
+	rootHash := tree.RootNode.TapHash()
+	internalKeyBytes, _ := hex.DecodeString(internalKeyHex)
+	internalKey, _ := schnorr.ParsePubKey(internalKeyBytes)
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+
+	addr, err := desc.Address(5)
+	if err != nil {
+		t.Fatalf("unable to get address: %v", err)
+	}
+	want, _ := btcutil.NewAddressTaproot(
+		schnorr.SerializePubKey(outputKey), &chaincfg.MainNetParams,
+	)
+	if addr.EncodeAddress() != want.EncodeAddress() {
+		t.Fatalf("unexpected address: got %s, want %s", addr, want)
+	}
+}
+
+// TestParseErrors ensures invalid descriptors are rejected.
+func TestParseErrors(t *testing.T) {
+	const (
+		pubKey       = "03acd484e2f0c7f65309ad178a9f559abde09796974c57e714c35f110dfc27ccbe"
+		uncompressed = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d9" +
+			"59f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a6" +
+			"8554199c47d08ffb10d4b8"
+	)
+	tests := []struct {
+		name string
+		desc string
+	}{
+		{"unknown function", "foo(" + pubKey + ")"},
+		{"missing parenthesis", "pk(" + pubKey},
+		{"unbalanced parentheses", "pk(" + pubKey + "))"},
+		{"too many arguments", "pk(" + pubKey + "," + pubKey + ")"},
+		{"invalid key", "pk(03acd4)"},
+		{"uncompressed wpkh", "wpkh(" + uncompressed + ")"},
+		{"uncompressed wsh", "wsh(pk(" + uncompressed + "))"},
+		{"nested sh", "sh(sh(pk(" + pubKey + ")))"},
+		{"wsh in wsh", "wsh(wsh(pk(" + pubKey + ")))"},
+		{"wpkh in wsh", "wsh(wpkh(" + pubKey + "))"},
+		{"tr in sh", "sh(tr(" + pubKey + "))"},
+		{"addr in sh", "sh(addr(1cMh228HTCiwS8ZsaakH8A8wze1JR5ZsP))"},
+		{"zero threshold", "multi(0," + pubKey + ")"},
+		{"threshold too high", "multi(2," + pubKey + ")"},
+		{"large bare multisig", "multi(1," + pubKey + "," + pubKey +
+			"," + pubKey + "," + pubKey + ")"},
+		{"multi in tapscript", "tr(" + pubKey + ",multi(1," + pubKey + "))"},
+		{"unbalanced tree", "tr(" + pubKey + ",{pk(" + pubKey + ")})"},
+		{"wrong network address", "addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)"},
+		{"wrong network key", "pkh(tpubD6NzVbkrYhZ4WaWSyoBvQwbpLkojyoTZPRsgXELWz3Popb3qkjcJyJUGLnL4qHHoQvao8ESaAstxYSnhyswJ76uZPStJRJCTKvosUCJZL5B/*)"},
+		{"invalid raw", "raw(0)"},
+		{"bad fingerprint", "pk([d34db3/0]" + pubKey + ")"},
+		{"unclosed origin", "pk([d34db33f/0" + pubKey + ")"},
+		{"path out of range", "pkh(" + testXPub + "/2147483648)"},
+		{"invalid path", "pkh(" + testXPub + "/*/1)"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.desc, false, &chaincfg.MainNetParams)
+		if err == nil {
+			t.Errorf("%s: did not receive expected error", test.name)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package descriptor implements output script descriptors as described by
+BIP0380 and the related BIPs.
+
+An output script descriptor is a human readable language which describes a
+set of output scripts along with the information needed to solve them.  For
+example, wpkh([d34db33f/84'/0'/0']xpub.../0/*) describes the P2WPKH output
+scripts for the unhardened children of the external chain of a BIP0084
+account.
+
+Parse parses a descriptor for a specific network, verifying its checksum when
+present, and returns a Descriptor which can be expanded at an index into the
+output script, redeem script, witness script and address it produces.
+
+The following script expressions are supported:
+
+	pk(KEY)                   P2PK output
+	pkh(KEY)                  P2PKH output
+	wpkh(KEY)                 P2WPKH output
+	sh(SCRIPT)                P2SH output wrapping the script
+	wsh(SCRIPT)               P2WSH output wrapping the script
+	multi(k,KEY,...)          multisig output requiring k signatures
+	sortedmulti(k,KEY,...)    multisig output with the keys sorted
+	tr(KEY)                   P2TR output with only a key spend path
+	tr(KEY,TREE)              P2TR output committing to a script tree of pk
+	                          leaves, where TREE is a leaf or {TREE,TREE}
+	addr(ADDRESS)             output script of the address
+	raw(HEX)                  the hex-encoded output script
+
+Keys may be hex-encoded public keys, private keys in WIF, or extended keys
+followed by a derivation path, optionally preceded by a key origin of the form
+[fingerprint/path].  Extended keys may end in /* or /*' to describe a range of
+keys over the unhardened or hardened children of the path respectively.
+*/
+package descriptor
@@ -0,0 +1,40 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"errors"
+)
+
+var (
+	// ErrInvalidCharacter describes an error in which a descriptor
+	// contains a character outside of the descriptor character set.
+	ErrInvalidCharacter = errors.New("descriptor contains an invalid " +
+		"character")
+
+	// ErrMissingChecksum describes an error in which a descriptor is
+	// required to have a checksum, but does not.
+	ErrMissingChecksum = errors.New("missing descriptor checksum")
+
+	// ErrInvalidChecksum describes an error in which the checksum of a
+	// descriptor is malformed or does not match the descriptor.
+	ErrInvalidChecksum = errors.New("invalid descriptor checksum")
+
+	// ErrInvalidIndex describes an error in which the caller attempted to
+	// expand a ranged descriptor at an index which isn't a valid unhardened
+	// child index.
+	ErrInvalidIndex = errors.New("descriptor index is out of range")
+
+	// ErrNoAddress describes an error in which the caller requested the
+	// address of a descriptor that has no address form, such as a bare
+	// multisig or raw script.
+	ErrNoAddress = errors.New("descriptor does not have a corresponding " +
+		"address")
+
+	// ErrHardenedFromPublic describes an error in which a descriptor
+	// requires hardened derivation from an extended public key.
+	ErrHardenedFromPublic = errors.New("cannot derive a hardened key " +
+		"from a public key")
+)
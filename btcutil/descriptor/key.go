@@ -0,0 +1,359 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// deriveType describes how the final child of a ranged extended key is
+// derived.
+type deriveType uint8
+
+const (
+	// deriveNone indicates the key is not ranged.
+	deriveNone deriveType = iota
+
+	// deriveUnhardened indicates the key is ranged over the unhardened
+	// children of the extended key, as in xpub.../*.
+	deriveUnhardened
+
+	// deriveHardened indicates the key is ranged over the hardened
+	// children of the extended key, as in xprv.../*'.
+	deriveHardened
+)
+
+// keyOrigin describes where a key came from: the fingerprint of the master
+// key it was derived from and the derivation path from that master key.
+type keyOrigin struct {
+	fingerprint uint32
+	path        []uint32
+}
+
+// String returns the key origin in descriptor form without the enclosing
+// brackets.
+func (o *keyOrigin) String() string {
+	var fp [4]byte
+	binary.BigEndian.PutUint32(fp[:], o.fingerprint)
+	return hex.EncodeToString(fp[:]) + formatPath(o.path)
+}
+
+// keyExpr is a key expression in a descriptor.  It is either a single public
+// key, a single private key in WIF, or an extended key along with a
+// derivation path and an optional final ranged step.
+type keyExpr struct {
+	origin *keyOrigin
+
+	// pubKey is set for single public keys, and wif for single private
+	// keys.
+	pubKey *btcec.PublicKey
+	wif    *btcutil.WIF
+
+	// compressed indicates whether single keys are serialized in the
+	// compressed or uncompressed form.  Keys derived from extended keys
+	// are always compressed.
+	compressed bool
+
+	// extKey, path and derive are set for extended keys.
+	extKey *hdkeychain.ExtendedKey
+	path   []uint32
+	derive deriveType
+
+	// xOnly indicates the key is serialized as a 32-byte x-only public key
+	// because it's used in a taproot context.
+	xOnly bool
+}
+
+// isRange returns whether the key is ranged.
+func (k *keyExpr) isRange() bool {
+	return k.derive != deriveNone
+}
+
+// hasPrivateKey returns whether the key expression includes private key
+// material.
+func (k *keyExpr) hasPrivateKey() bool {
+	return k.wif != nil || (k.extKey != nil && k.extKey.IsPrivate())
+}
+
+// String returns the key expression in descriptor form.  Private keys are
+// only included when the private flag is set, and are otherwise replaced by
+// the corresponding public keys.
+func (k *keyExpr) String(private bool) string {
+	var str string
+	if k.origin != nil {
+		str = "[" + k.origin.String() + "]"
+	}
+
+	switch {
+	case k.wif != nil && private:
+		return str + k.wif.String()
+
+	case k.extKey != nil:
+		extKey := k.extKey
+		if !private {
+			// Neutering can only fail for unknown HD versions,
+			// which are rejected when parsing.
+			extKey, _ = extKey.Neuter()
+		}
+		str += extKey.String() + formatPath(k.path)
+		switch k.derive {
+		case deriveUnhardened:
+			str += "/*"
+		case deriveHardened:
+			str += "/*'"
+		}
+		return str
+	}
+
+	pubKey := k.pubKey
+	if k.wif != nil {
+		pubKey = k.wif.PrivKey.PubKey()
+	}
+	return str + hex.EncodeToString(k.serialize(pubKey))
+}
+
+// serialize returns the passed public key, which must have been produced by
+// this key expression, serialized in the form used in scripts.
+func (k *keyExpr) serialize(pubKey *btcec.PublicKey) []byte {
+	switch {
+	case k.xOnly:
+		return schnorr.SerializePubKey(pubKey)
+	case k.compressed:
+		return pubKey.SerializeCompressed()
+	default:
+		return pubKey.SerializeUncompressed()
+	}
+}
+
+// pubKeyAt returns the public key of the key expression at the passed index,
+// serialized in the form used in scripts.  The index is ignored when the key
+// is not ranged.
+func (k *keyExpr) pubKeyAt(index uint32) ([]byte, error) {
+	switch {
+	case k.pubKey != nil:
+		return k.serialize(k.pubKey), nil
+	case k.wif != nil:
+		return k.serialize(k.wif.PrivKey.PubKey()), nil
+	}
+
+	path := k.path
+	switch k.derive {
+	case deriveUnhardened:
+		path = append(path[:len(path):len(path)], index)
+	case deriveHardened:
+		path = append(path[:len(path):len(path)],
+			index+hdkeychain.HardenedKeyStart)
+	}
+
+	extKey := k.extKey
+	for _, child := range path {
+		var err error
+		extKey, err = extKey.Derive(child)
+		if errors.Is(err, hdkeychain.ErrDeriveHardFromPublic) {
+			return nil, ErrHardenedFromPublic
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	pubKey, err := extKey.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return k.serialize(pubKey), nil
+}
+
+// formatPath returns the passed derivation path in descriptor form, with each
+// step prefixed by a slash and hardened steps marked by an apostrophe.
+func formatPath(path []uint32) string {
+	var sb strings.Builder
+	for _, child := range path {
+		sb.WriteByte('/')
+		if child >= hdkeychain.HardenedKeyStart {
+			sb.WriteString(strconv.FormatUint(uint64(
+				child-hdkeychain.HardenedKeyStart), 10))
+			sb.WriteByte('\'')
+			continue
+		}
+		sb.WriteString(strconv.FormatUint(uint64(child), 10))
+	}
+	return sb.String()
+}
+
+// parsePathStep parses a single step of a derivation path, which is a
+// decimal child number optionally followed by an apostrophe or h to denote
+// hardened derivation.
+func parsePathStep(step string) (uint32, error) {
+	var hardened bool
+	if strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h") {
+		step = step[:len(step)-1]
+		hardened = true
+	}
+
+	child, err := strconv.ParseUint(step, 10, 32)
+	if err != nil || child >= hdkeychain.HardenedKeyStart {
+		return 0, fmt.Errorf("key path value %q is out of range", step)
+	}
+	if hardened {
+		child += hdkeychain.HardenedKeyStart
+	}
+	return uint32(child), nil
+}
+
+// parseKeyOrigin parses a key origin of the form fingerprint/path... with the
+// enclosing brackets already removed.
+func parseKeyOrigin(str string) (*keyOrigin, error) {
+	steps := strings.Split(str, "/")
+	if len(steps[0]) != 8 {
+		return nil, fmt.Errorf("fingerprint %q is not 4 bytes", steps[0])
+	}
+	fp, err := hex.DecodeString(steps[0])
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint %q is not hex", steps[0])
+	}
+
+	origin := &keyOrigin{
+		fingerprint: binary.BigEndian.Uint32(fp),
+		path:        make([]uint32, 0, len(steps)-1),
+	}
+	for _, step := range steps[1:] {
+		child, err := parsePathStep(step)
+		if err != nil {
+			return nil, err
+		}
+		origin.path = append(origin.path, child)
+	}
+	return origin, nil
+}
+
+// isHex returns whether the passed string is a non-empty even-length string
+// of lowercase or uppercase hex characters.
+func isHex(str string) bool {
+	if len(str) == 0 || len(str)%2 != 0 {
+		return false
+	}
+	_, err := hex.DecodeString(str)
+	return err == nil
+}
+
+// parseKey parses a key expression.  Uncompressed keys are rejected unless
+// allowUncompressed is set, and keys are serialized as x-only public keys when
+// xOnly is set.
+func parseKey(str string, allowUncompressed, xOnly bool,
+	params *chaincfg.Params) (*keyExpr, error) {
+
+	key := &keyExpr{xOnly: xOnly}
+
+	// Parse the key origin, if any.
+	if strings.HasPrefix(str, "[") {
+		end := strings.IndexByte(str, ']')
+		if end == -1 {
+			return nil, errors.New("key origin start '[' " +
+				"character without matching ']' character")
+		}
+		origin, err := parseKeyOrigin(str[1:end])
+		if err != nil {
+			return nil, err
+		}
+		key.origin = origin
+		str = str[end+1:]
+	}
+	if strings.ContainsAny(str, "[]") {
+		return nil, errors.New("multiple key origins are not allowed")
+	}
+
+	steps := strings.Split(str, "/")
+	if len(steps) == 1 {
+		// Attempt to parse a hex-encoded public key.
+		if isHex(str) {
+			pubKeyBytes, _ := hex.DecodeString(str)
+			if xOnly && len(pubKeyBytes) == schnorr.PubKeyBytesLen {
+				pubKey, err := schnorr.ParsePubKey(pubKeyBytes)
+				if err != nil {
+					return nil, fmt.Errorf("pubkey %q is "+
+						"invalid", str)
+				}
+				key.pubKey = pubKey
+				return key, nil
+			}
+
+			pubKey, err := btcec.ParsePubKey(pubKeyBytes)
+			if err != nil {
+				return nil, fmt.Errorf("pubkey %q is invalid", str)
+			}
+			key.compressed = len(pubKeyBytes) == btcec.PubKeyBytesLenCompressed
+			if !key.compressed && !allowUncompressed {
+				return nil, errors.New("uncompressed keys are " +
+					"not allowed")
+			}
+			key.pubKey = pubKey
+			return key, nil
+		}
+
+		// Attempt to parse a private key in WIF.
+		if wif, err := btcutil.DecodeWIF(str); err == nil {
+			if !wif.IsForNet(params) {
+				return nil, fmt.Errorf("private key %q is not "+
+					"for network %s", str, params.Name)
+			}
+			key.compressed = wif.CompressPubKey
+			if !key.compressed && !allowUncompressed {
+				return nil, errors.New("uncompressed keys are " +
+					"not allowed")
+			}
+			key.wif = wif
+			return key, nil
+		}
+	}
+
+	// Otherwise, the key must be an extended key followed by an optional
+	// derivation path.
+	extKey, err := hdkeychain.NewKeyFromString(steps[0])
+	if err != nil {
+		return nil, fmt.Errorf("key %q is not valid", steps[0])
+	}
+	if !extKey.IsForNet(params) {
+		return nil, fmt.Errorf("extended key %q is not for network %s",
+			steps[0], params.Name)
+	}
+	if _, err := extKey.Neuter(); err != nil {
+		return nil, fmt.Errorf("extended key %q has an unknown "+
+			"version", steps[0])
+	}
+	key.extKey = extKey
+	key.compressed = true
+
+	steps = steps[1:]
+	if len(steps) > 0 {
+		switch steps[len(steps)-1] {
+		case "*":
+			key.derive = deriveUnhardened
+			steps = steps[:len(steps)-1]
+		case "*'", "*h":
+			key.derive = deriveHardened
+			steps = steps[:len(steps)-1]
+		}
+	}
+	key.path = make([]uint32, 0, len(steps))
+	for _, step := range steps {
+		child, err := parsePathStep(step)
+		if err != nil {
+			return nil, err
+		}
+		key.path = append(key.path, child)
+	}
+	return key, nil
+}
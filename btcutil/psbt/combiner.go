@@ -0,0 +1,350 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+// The Combiner merges several PSBTs for the same transaction, such as the
+// ones returned by multiple co-signers, into a single one which contains the
+// union of their key-value pairs.
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// UniqueID returns the hash which identifies the transaction of the PSBT
+// independent of the data attached to its inputs and outputs.  For version 0
+// packets, it is the hash of the unsigned transaction.  For version 2 packets,
+// the transaction is hashed with the determined lock time and the sequence
+// numbers of the inputs set to zero as defined in BIP 370, since they may be
+// changed by Updaters and Combiners.
+func (p *Packet) UniqueID() (chainhash.Hash, error) {
+	if p.Version == Version0 {
+		return p.UnsignedTx.TxHash(), nil
+	}
+
+	lockTime, err := p.DetermineLockTime()
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	tx := p.UnsignedTx.Copy()
+	tx.LockTime = lockTime
+	for _, txIn := range tx.TxIn {
+		txIn.Sequence = 0
+	}
+	return tx.TxHash(), nil
+}
+
+// Combine merges the passed PSBTs, which must be of the same version and
+// describe the same transaction, into a new PSBT containing the key-value
+// pairs of all of them.  Duplicate entries are only included once.  When the
+// PSBTs contain different values for the same key, the value of the PSBT
+// passed first is kept, which is allowed by BIP 174.  For version 2 packets,
+// inputs and outputs remain modifiable only if they are modifiable in all of
+// the PSBTs.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	// The first packet serves as the base all others are merged into.  A
+	// copy of it is created by serializing and parsing it again, which
+	// also ensures it is valid.
+	var b bytes.Buffer
+	if err := packets[0].Serialize(&b); err != nil {
+		return nil, err
+	}
+	combined, err := NewFromRawBytes(&b, false)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueID, err := combined.UniqueID()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packets[1:] {
+		if err := p.SanityCheck(); err != nil {
+			return nil, err
+		}
+		otherID, err := p.UniqueID()
+		if err != nil {
+			return nil, err
+		}
+		if p.Version != combined.Version || otherID != uniqueID ||
+			len(p.Inputs) != len(combined.Inputs) ||
+			len(p.Outputs) != len(combined.Outputs) {
+
+			return nil, ErrCombineMismatch
+		}
+
+		combined.Unknowns = combineUnknowns(
+			combined.Unknowns, p.Unknowns,
+		)
+		if combined.FallbackLockTime == nil {
+			combined.FallbackLockTime = p.FallbackLockTime
+		}
+
+		// Inputs and outputs remain modifiable only if they are
+		// modifiable in all of the packets, while a signature using
+		// SIGHASH_SINGLE in any of them is carried over.
+		combined.TxModifiable &= p.TxModifiable | HasSigHashSingle
+		combined.TxModifiable |= p.TxModifiable & HasSigHashSingle
+
+		for i := range p.Inputs {
+			combined.Inputs[i].combine(&p.Inputs[i])
+		}
+		for i := range p.Outputs {
+			combined.Outputs[i].combine(&p.Outputs[i])
+		}
+	}
+
+	// The lock times required by the inputs of version 2 packets might
+	// have changed, so the lock time of the transaction is determined
+	// again.
+	lockTime, err := combined.DetermineLockTime()
+	if err != nil {
+		return nil, err
+	}
+	combined.UnsignedTx.LockTime = lockTime
+
+	return combined, nil
+}
+
+// combine adds the key-value pairs of the passed input which are missing from
+// the target input.
+func (pi *PInput) combine(other *PInput) {
+	if pi.NonWitnessUtxo == nil {
+		pi.NonWitnessUtxo = other.NonWitnessUtxo
+	}
+	if pi.WitnessUtxo == nil {
+		pi.WitnessUtxo = other.WitnessUtxo
+	}
+	for _, sig := range other.PartialSigs {
+		exists := false
+		for _, x := range pi.PartialSigs {
+			if bytes.Equal(x.PubKey, sig.PubKey) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.PartialSigs = append(pi.PartialSigs, sig)
+		}
+	}
+	if pi.SighashType == 0 {
+		pi.SighashType = other.SighashType
+	}
+	if pi.RedeemScript == nil {
+		pi.RedeemScript = other.RedeemScript
+	}
+	if pi.WitnessScript == nil {
+		pi.WitnessScript = other.WitnessScript
+	}
+	pi.Bip32Derivation = combineBip32Derivations(
+		pi.Bip32Derivation, other.Bip32Derivation,
+	)
+	if pi.FinalScriptSig == nil {
+		pi.FinalScriptSig = other.FinalScriptSig
+	}
+	if pi.FinalScriptWitness == nil {
+		pi.FinalScriptWitness = other.FinalScriptWitness
+	}
+	if pi.TaprootKeySpendSig == nil {
+		pi.TaprootKeySpendSig = other.TaprootKeySpendSig
+	}
+	for _, sig := range other.TaprootScriptSpendSig {
+		exists := false
+		for _, x := range pi.TaprootScriptSpendSig {
+			if x.EqualKey(sig) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.TaprootScriptSpendSig = append(
+				pi.TaprootScriptSpendSig, sig,
+			)
+		}
+	}
+	for _, leafScript := range other.TaprootLeafScript {
+		exists := false
+		for _, x := range pi.TaprootLeafScript {
+			if bytes.Equal(x.ControlBlock, leafScript.ControlBlock) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.TaprootLeafScript = append(
+				pi.TaprootLeafScript, leafScript,
+			)
+		}
+	}
+	pi.TaprootBip32Derivation = combineTaprootBip32Derivations(
+		pi.TaprootBip32Derivation, other.TaprootBip32Derivation,
+	)
+	if pi.TaprootInternalKey == nil {
+		pi.TaprootInternalKey = other.TaprootInternalKey
+	}
+	if pi.TaprootMerkleRoot == nil {
+		pi.TaprootMerkleRoot = other.TaprootMerkleRoot
+	}
+	pi.MuSig2Participants = combineMuSig2Participants(
+		pi.MuSig2Participants, other.MuSig2Participants,
+	)
+	for _, nonce := range other.MuSig2PubNonces {
+		exists := false
+		for _, x := range pi.MuSig2PubNonces {
+			if x.EqualKey(nonce) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.MuSig2PubNonces = append(pi.MuSig2PubNonces, nonce)
+		}
+	}
+	for _, sig := range other.MuSig2PartialSigs {
+		exists := false
+		for _, x := range pi.MuSig2PartialSigs {
+			if x.EqualKey(sig) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.MuSig2PartialSigs = append(pi.MuSig2PartialSigs, sig)
+		}
+	}
+	if pi.RequiredTimeLockTime == 0 {
+		pi.RequiredTimeLockTime = other.RequiredTimeLockTime
+	}
+	if pi.RequiredHeightLockTime == 0 {
+		pi.RequiredHeightLockTime = other.RequiredHeightLockTime
+	}
+	for _, unknown := range other.Unknowns {
+		exists := false
+		for _, x := range pi.Unknowns {
+			if bytes.Equal(x.Key, unknown.Key) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			pi.Unknowns = append(pi.Unknowns, unknown)
+		}
+	}
+}
+
+// combine adds the key-value pairs of the passed output which are missing from
+// the target output.
+func (po *POutput) combine(other *POutput) {
+	if po.RedeemScript == nil {
+		po.RedeemScript = other.RedeemScript
+	}
+	if po.WitnessScript == nil {
+		po.WitnessScript = other.WitnessScript
+	}
+	po.Bip32Derivation = combineBip32Derivations(
+		po.Bip32Derivation, other.Bip32Derivation,
+	)
+	if po.TaprootInternalKey == nil {
+		po.TaprootInternalKey = other.TaprootInternalKey
+	}
+	if po.TaprootTapTree == nil {
+		po.TaprootTapTree = other.TaprootTapTree
+	}
+	po.TaprootBip32Derivation = combineTaprootBip32Derivations(
+		po.TaprootBip32Derivation, other.TaprootBip32Derivation,
+	)
+	po.MuSig2Participants = combineMuSig2Participants(
+		po.MuSig2Participants, other.MuSig2Participants,
+	)
+}
+
+// combineBip32Derivations returns the passed derivations along with the
+// additional ones for public keys which are not part of them yet.
+func combineBip32Derivations(derivations,
+	additional []*Bip32Derivation) []*Bip32Derivation {
+
+	for _, derivation := range additional {
+		exists := false
+		for _, x := range derivations {
+			if bytes.Equal(x.PubKey, derivation.PubKey) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			derivations = append(derivations, derivation)
+		}
+	}
+
+	return derivations
+}
+
+// combineTaprootBip32Derivations returns the passed taproot derivations along
+// with the additional ones for x-only public keys which are not part of them
+// yet.
+func combineTaprootBip32Derivations(derivations,
+	additional []*TaprootBip32Derivation) []*TaprootBip32Derivation {
+
+	for _, derivation := range additional {
+		exists := false
+		for _, x := range derivations {
+			if bytes.Equal(x.XOnlyPubKey, derivation.XOnlyPubKey) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			derivations = append(derivations, derivation)
+		}
+	}
+
+	return derivations
+}
+
+// combineMuSig2Participants returns the passed MuSig2 participants infos along
+// with the additional ones for aggregate keys which are not part of them yet.
+func combineMuSig2Participants(participants,
+	additional []*MuSig2Participants) []*MuSig2Participants {
+
+	for _, p := range additional {
+		exists := false
+		for _, x := range participants {
+			if x.AggKey.IsEqual(p.AggKey) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			participants = append(participants, p)
+		}
+	}
+
+	return participants
+}
+
+// combineUnknowns returns the passed global unknowns along with the additional
+// ones for keys which are not part of them yet.
+func combineUnknowns(unknowns, additional []Unknown) []Unknown {
+	for _, unknown := range additional {
+		exists := false
+		for _, x := range unknowns {
+			if bytes.Equal(x.Key, unknown.Key) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			unknowns = append(unknowns, unknown)
+		}
+	}
+
+	return unknowns
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// testPartialSig returns a partial signature of the key derived from the
+// passed seed byte.
+func testPartialSig(seed byte) *PartialSig {
+	privKey, pubKey := btcec.PrivKeyFromBytes(
+		bytes.Repeat([]byte{seed}, 32),
+	)
+	sig := ecdsa.Sign(privKey, chainhash.HashB([]byte{seed}))
+	return &PartialSig{
+		PubKey:    pubKey.SerializeCompressed(),
+		Signature: append(sig.Serialize(), byte(txscript.SigHashAll)),
+	}
+}
+
+// testPacket returns a packet spending two outputs to a single output with
+// the passed version.
+func testPacket(t *testing.T, version uint32) *Packet {
+	t.Helper()
+
+	inputs := []*wire.OutPoint{
+		{Hash: chainhash.Hash{0x01}, Index: 0},
+		{Hash: chainhash.Hash{0x02}, Index: 1},
+	}
+	outputs := []*wire.TxOut{
+		wire.NewTxOut(1000, []byte{txscript.OP_TRUE}),
+	}
+	packet, err := New(
+		inputs, outputs, 2, 0,
+		[]uint32{wire.MaxTxInSequenceNum, wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	require.NoError(t, packet.SetVersion(version))
+
+	return packet
+}
+
+// TestCombine ensures PSBTs for the same transaction are combined into a PSBT
+// containing the union of their key-value pairs.
+func TestCombine(t *testing.T) {
+	sig1, sig2 := testPartialSig(1), testPartialSig(2)
+	derivation := &Bip32Derivation{
+		PubKey:               sig1.PubKey,
+		MasterKeyFingerprint: 1,
+		Bip32Path:            []uint32{1, 2},
+	}
+
+	// Each co-signer adds its own signature to the first input, while
+	// both add the same derivation and different redeem scripts.
+	packet1 := testPacket(t, Version0)
+	packet1.Inputs[0].PartialSigs = []*PartialSig{sig1}
+	packet1.Inputs[0].RedeemScript = []byte{txscript.OP_1}
+	packet1.Outputs[0].Bip32Derivation = []*Bip32Derivation{derivation}
+	packet2 := testPacket(t, Version0)
+	packet2.Inputs[0].PartialSigs = []*PartialSig{sig2}
+	packet2.Inputs[0].RedeemScript = []byte{txscript.OP_2}
+	packet2.Inputs[1].WitnessUtxo = wire.NewTxOut(
+		2000, bytes.Repeat([]byte{txscript.OP_TRUE}, 2),
+	)
+	packet2.Outputs[0].Bip32Derivation = []*Bip32Derivation{derivation}
+	packet2.Unknowns = []Unknown{{Key: []byte{0xfc, 0x01}, Value: nil}}
+
+	combined, err := Combine(packet1, packet2)
+	require.NoError(t, err)
+	require.Len(t, combined.Inputs[0].PartialSigs, 2)
+	require.Equal(t, []byte{txscript.OP_1}, combined.Inputs[0].RedeemScript)
+	require.Equal(t, packet2.Inputs[1].WitnessUtxo,
+		combined.Inputs[1].WitnessUtxo)
+	require.Len(t, combined.Outputs[0].Bip32Derivation, 1)
+	require.Equal(t, packet2.Unknowns, combined.Unknowns)
+
+	// The passed packets don't gain any key-value pairs.
+	require.Len(t, packet1.Inputs[0].PartialSigs, 1)
+	require.Nil(t, packet1.Inputs[1].WitnessUtxo)
+
+	// Combining is idempotent and the result can be serialized and parsed
+	// again.
+	combinedAgain, err := Combine(combined, packet1, packet2)
+	require.NoError(t, err)
+	var b1, b2 bytes.Buffer
+	require.NoError(t, combined.Serialize(&b1))
+	require.NoError(t, combinedAgain.Serialize(&b2))
+	require.Equal(t, b1.Bytes(), b2.Bytes())
+	_, err = NewFromRawBytes(&b1, false)
+	require.NoError(t, err)
+
+	// PSBTs for different transactions or of different versions can't be
+	// combined.
+	other := testPacket(t, Version0)
+	other.UnsignedTx.TxOut[0].Value++
+	_, err = Combine(packet1, other)
+	require.ErrorIs(t, err, ErrCombineMismatch)
+	_, err = Combine(packet1, testPacket(t, Version2))
+	require.ErrorIs(t, err, ErrCombineMismatch)
+	_, err = Combine()
+	require.Error(t, err)
+}
+
+// TestCombineV2 ensures version 2 PSBTs are combined taking the fields which
+// are specific to them into account.
+func TestCombineV2(t *testing.T) {
+	packet1 := testPacket(t, Version2)
+	packet1.TxModifiable = InputsModifiable | OutputsModifiable
+	packet1.Inputs[0].RequiredHeightLockTime = 100
+
+	// The sequence numbers don't contribute to the identity of version 2
+	// packets since they may be changed by Updaters.
+	packet2 := testPacket(t, Version2)
+	packet2.UnsignedTx.TxIn[1].Sequence = 1
+	packet2.TxModifiable = InputsModifiable | HasSigHashSingle
+	packet2.Inputs[1].RequiredHeightLockTime = 100
+	packet2.Inputs[1].RequiredTimeLockTime = txscript.LockTimeThreshold
+
+	combined, err := Combine(packet1, packet2)
+	require.NoError(t, err)
+	require.Equal(t, InputsModifiable|HasSigHashSingle,
+		combined.TxModifiable)
+	require.Equal(t, wire.MaxTxInSequenceNum,
+		combined.UnsignedTx.TxIn[1].Sequence)
+	require.EqualValues(t, 100, combined.UnsignedTx.LockTime)
+	require.EqualValues(t, 100, combined.Inputs[1].RequiredHeightLockTime)
+
+	// The lock time determined by the inputs contributes to the identity
+	// of version 2 packets.
+	packet2.Inputs[1].RequiredHeightLockTime = 200
+	_, err = Combine(packet1, packet2)
+	require.ErrorIs(t, err, ErrCombineMismatch)
+}
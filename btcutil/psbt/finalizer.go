@@ -45,7 +45,8 @@ func isFinalizableWitnessInput(pInput *PInput) bool {
 
 		case txscript.IsPayToTaproot(pkScript):
 			if pInput.TaprootKeySpendSig == nil &&
-				pInput.TaprootScriptSpendSig == nil {
+				pInput.TaprootScriptSpendSig == nil &&
+				!hasMuSig2KeySpendSigs(pInput) {
 
 				return false
 			}
@@ -131,7 +132,8 @@ func isFinalizable(p *Packet, inIndex int) bool {
 
 	// The input cannot be finalized without any signatures.
 	if pInput.PartialSigs == nil && pInput.TaprootKeySpendSig == nil &&
-		pInput.TaprootScriptSpendSig == nil {
+		pInput.TaprootScriptSpendSig == nil &&
+		pInput.MuSig2PartialSigs == nil {
 
 		return false
 	}
@@ -512,6 +514,19 @@ func finalizeTaprootInput(p *Packet, inIndex int) error {
 		pInput            = &p.Inputs[inIndex]
 	)
 
+	// The partial signatures of a MuSig2 key path spend are aggregated
+	// into the final key spend signature first.
+	if len(pInput.TaprootKeySpendSig) == 0 &&
+		hasMuSig2KeySpendSigs(pInput) {
+
+		pInput.TaprootKeySpendSig, err = aggregateMuSig2KeySpendSig(
+			p, inIndex,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	// What spend path did we take?
 	switch {
 	// Key spend path.
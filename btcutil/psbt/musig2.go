@@ -0,0 +1,465 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// musig2SigKeyLength is the length of the key data of MuSig2 public
+	// nonces and partial signatures of key path spends, which consists of
+	// the public key of the participant followed by the aggregate public
+	// key.
+	musig2SigKeyLength = 2 * btcec.PubKeyBytesLenCompressed
+
+	// musig2LeafSigKeyLength is the length of the key data of MuSig2
+	// public nonces and partial signatures of script path spends, which
+	// additionally contains the leaf hash.
+	musig2LeafSigKeyLength = musig2SigKeyLength + 32
+
+	// musig2PartialSigLength is the length of a serialized MuSig2 partial
+	// signature.
+	musig2PartialSigLength = 32
+)
+
+// MuSig2Participants encapsulates the public keys of the participants of a
+// MuSig2 session along with the public key they aggregate to, as defined in
+// BIP 373.
+type MuSig2Participants struct {
+	// AggKey is the aggregate public key of the participants before any
+	// tweaks are applied.
+	AggKey *btcec.PublicKey
+
+	// Keys are the public keys of the participants in the order they are
+	// aggregated in.
+	Keys []*btcec.PublicKey
+}
+
+// SortBefore returns true if this participants info's key is
+// lexicographically smaller than the given other participants info's key and
+// should come first when being sorted.
+func (m *MuSig2Participants) SortBefore(other *MuSig2Participants) bool {
+	return bytes.Compare(
+		m.AggKey.SerializeCompressed(), other.AggKey.SerializeCompressed(),
+	) < 0
+}
+
+// MuSig2PubNonce encapsulates the public nonce a participant of a MuSig2
+// session provides for signing an input.
+type MuSig2PubNonce struct {
+	// PubKey is the public key of the participant providing the nonce.
+	PubKey *btcec.PublicKey
+
+	// AggKey is the aggregate public key of the participants before any
+	// tweaks are applied.
+	AggKey *btcec.PublicKey
+
+	// LeafHash is the hash of the leaf script for script path spends, and
+	// nil for key path spends.
+	LeafHash []byte
+
+	// PubNonce is the public nonce of the participant.
+	PubNonce [musig2.PubNonceSize]byte
+}
+
+// key returns the key data identifying the public nonce.
+func (m *MuSig2PubNonce) key() []byte {
+	return musig2SigKey(m.PubKey, m.AggKey, m.LeafHash)
+}
+
+// EqualKey returns true if this public nonce's key data is the same as the
+// given public nonce's key data.
+func (m *MuSig2PubNonce) EqualKey(other *MuSig2PubNonce) bool {
+	return bytes.Equal(m.key(), other.key())
+}
+
+// SortBefore returns true if this public nonce's key is lexicographically
+// smaller than the given other public nonce's key and should come first when
+// being sorted.
+func (m *MuSig2PubNonce) SortBefore(other *MuSig2PubNonce) bool {
+	return bytes.Compare(m.key(), other.key()) < 0
+}
+
+// MuSig2PartialSig encapsulates the partial signature a participant of a
+// MuSig2 session provides for an input.
+type MuSig2PartialSig struct {
+	// PubKey is the public key of the participant providing the partial
+	// signature.
+	PubKey *btcec.PublicKey
+
+	// AggKey is the aggregate public key of the participants before any
+	// tweaks are applied.
+	AggKey *btcec.PublicKey
+
+	// LeafHash is the hash of the leaf script for script path spends, and
+	// nil for key path spends.
+	LeafHash []byte
+
+	// PartialSig is the partial signature of the participant.
+	PartialSig musig2.PartialSignature
+}
+
+// key returns the key data identifying the partial signature.
+func (m *MuSig2PartialSig) key() []byte {
+	return musig2SigKey(m.PubKey, m.AggKey, m.LeafHash)
+}
+
+// EqualKey returns true if this partial signature's key data is the same as
+// the given partial signature's key data.
+func (m *MuSig2PartialSig) EqualKey(other *MuSig2PartialSig) bool {
+	return bytes.Equal(m.key(), other.key())
+}
+
+// SortBefore returns true if this partial signature's key is lexicographically
+// smaller than the given other partial signature's key and should come first
+// when being sorted.
+func (m *MuSig2PartialSig) SortBefore(other *MuSig2PartialSig) bool {
+	return bytes.Compare(m.key(), other.key()) < 0
+}
+
+// musig2SigKey returns the key data of a MuSig2 public nonce or partial
+// signature, which is the public key of the participant followed by the
+// aggregate public key and the optional leaf hash.
+func musig2SigKey(pubKey, aggKey *btcec.PublicKey, leafHash []byte) []byte {
+	key := make([]byte, 0, musig2LeafSigKeyLength)
+	key = append(key, pubKey.SerializeCompressed()...)
+	key = append(key, aggKey.SerializeCompressed()...)
+	return append(key, leafHash...)
+}
+
+// readMuSig2SigKey deserializes the key data of a MuSig2 public nonce or
+// partial signature.
+func readMuSig2SigKey(keydata []byte) (*btcec.PublicKey, *btcec.PublicKey,
+	[]byte, error) {
+
+	if len(keydata) != musig2SigKeyLength &&
+		len(keydata) != musig2LeafSigKeyLength {
+
+		return nil, nil, nil, ErrInvalidKeydata
+	}
+
+	pubKey, err := btcec.ParsePubKey(
+		keydata[:btcec.PubKeyBytesLenCompressed],
+	)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidKeydata
+	}
+	aggKey, err := btcec.ParsePubKey(
+		keydata[btcec.PubKeyBytesLenCompressed:musig2SigKeyLength],
+	)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidKeydata
+	}
+
+	var leafHash []byte
+	if len(keydata) == musig2LeafSigKeyLength {
+		leafHash = keydata[musig2SigKeyLength:]
+	}
+
+	return pubKey, aggKey, leafHash, nil
+}
+
+// readMuSig2Participants deserializes the aggregate public key in the passed
+// key data and the participant public keys in the passed value.
+func readMuSig2Participants(keydata,
+	value []byte) (*MuSig2Participants, error) {
+
+	if len(keydata) != btcec.PubKeyBytesLenCompressed {
+		return nil, ErrInvalidKeydata
+	}
+	aggKey, err := btcec.ParsePubKey(keydata)
+	if err != nil {
+		return nil, ErrInvalidKeydata
+	}
+
+	if len(value) == 0 || len(value)%btcec.PubKeyBytesLenCompressed != 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+	participants := MuSig2Participants{
+		AggKey: aggKey,
+		Keys: make(
+			[]*btcec.PublicKey, 0,
+			len(value)/btcec.PubKeyBytesLenCompressed,
+		),
+	}
+	for i := 0; i < len(value); i += btcec.PubKeyBytesLenCompressed {
+		key, err := btcec.ParsePubKey(
+			value[i : i+btcec.PubKeyBytesLenCompressed],
+		)
+		if err != nil {
+			return nil, ErrInvalidPsbtFormat
+		}
+		participants.Keys = append(participants.Keys, key)
+	}
+
+	return &participants, nil
+}
+
+// serializeMuSig2Participants serializes the participant public keys of the
+// passed participants info to its raw value representation.
+func serializeMuSig2Participants(m *MuSig2Participants) []byte {
+	value := make(
+		[]byte, 0, len(m.Keys)*btcec.PubKeyBytesLenCompressed,
+	)
+	for _, key := range m.Keys {
+		value = append(value, key.SerializeCompressed()...)
+	}
+	return value
+}
+
+// readMuSig2PubNonce deserializes a MuSig2 public nonce from the passed key
+// data and value.
+func readMuSig2PubNonce(keydata, value []byte) (*MuSig2PubNonce, error) {
+	pubKey, aggKey, leafHash, err := readMuSig2SigKey(keydata)
+	if err != nil {
+		return nil, err
+	}
+
+	// The public nonce consists of two compressed public keys.
+	if len(value) != musig2.PubNonceSize {
+		return nil, ErrInvalidPsbtFormat
+	}
+	for i := 0; i < len(value); i += btcec.PubKeyBytesLenCompressed {
+		_, err := btcec.ParsePubKey(
+			value[i : i+btcec.PubKeyBytesLenCompressed],
+		)
+		if err != nil {
+			return nil, ErrInvalidPsbtFormat
+		}
+	}
+
+	nonce := MuSig2PubNonce{
+		PubKey:   pubKey,
+		AggKey:   aggKey,
+		LeafHash: leafHash,
+	}
+	copy(nonce.PubNonce[:], value)
+
+	return &nonce, nil
+}
+
+// readMuSig2PartialSig deserializes a MuSig2 partial signature from the passed
+// key data and value.
+func readMuSig2PartialSig(keydata, value []byte) (*MuSig2PartialSig, error) {
+	pubKey, aggKey, leafHash, err := readMuSig2SigKey(keydata)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) != musig2PartialSigLength {
+		return nil, ErrInvalidPsbtFormat
+	}
+	sig := MuSig2PartialSig{
+		PubKey:   pubKey,
+		AggKey:   aggKey,
+		LeafHash: leafHash,
+	}
+	if err := sig.PartialSig.Decode(bytes.NewReader(value)); err != nil {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	return &sig, nil
+}
+
+// serializeMuSig2PartialSig serializes the passed MuSig2 partial signature to
+// its raw value representation.
+func serializeMuSig2PartialSig(m *MuSig2PartialSig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.PartialSig.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// aggregateMuSig2KeySpendSig combines the MuSig2 partial signatures for the key
+// path spend of the taproot input at the passed index into its final Schnorr
+// signature, including the sighash type unless it is the default one.  The
+// participants whose aggregate key, tweaked as defined in BIP 341, matches the
+// taproot output key must have provided both their public nonces and partial
+// signatures.
+func aggregateMuSig2KeySpendSig(p *Packet, inIndex int) ([]byte, error) {
+	pInput := &p.Inputs[inIndex]
+	if pInput.WitnessUtxo == nil ||
+		!txscript.IsPayToTaproot(pInput.WitnessUtxo.PkScript) {
+
+		return nil, ErrInvalidPsbtFormat
+	}
+	outputKey := pInput.WitnessUtxo.PkScript[2:]
+
+	// The taproot output key commits to the merkle root of the script
+	// tree, or to nothing at all as defined in BIP 86 if there is none.
+	merkleRoot := pInput.TaprootMerkleRoot
+	tweak := musig2.WithBIP86KeyTweak()
+	if merkleRoot != nil {
+		tweak = musig2.WithTaprootKeyTweak(merkleRoot)
+	}
+
+	var (
+		participants *MuSig2Participants
+		aggKey       *musig2.AggregateKey
+	)
+	for _, x := range pInput.MuSig2Participants {
+		key, _, _, err := musig2.AggregateKeys(x.Keys, false, tweak)
+		if err != nil {
+			return nil, err
+		}
+		if key.PreTweakedKey.IsEqual(x.AggKey) && bytes.Equal(
+			schnorr.SerializePubKey(key.FinalKey), outputKey,
+		) {
+
+			participants, aggKey = x, key
+			break
+		}
+	}
+	if participants == nil {
+		return nil, fmt.Errorf("MuSig2 participants of taproot " +
+			"output key not found")
+	}
+
+	// Each participant must have provided both its public nonce and its
+	// partial signature for the key path spend.
+	var (
+		pubNonces   = make([][musig2.PubNonceSize]byte, 0, len(participants.Keys))
+		partialSigs = make([]*musig2.PartialSignature, 0, len(participants.Keys))
+	)
+	for _, key := range participants.Keys {
+		target := musig2SigKey(key, participants.AggKey, nil)
+
+		var nonce *MuSig2PubNonce
+		for _, x := range pInput.MuSig2PubNonces {
+			if bytes.Equal(x.key(), target) {
+				nonce = x
+				break
+			}
+		}
+		var sig *MuSig2PartialSig
+		for _, x := range pInput.MuSig2PartialSigs {
+			if bytes.Equal(x.key(), target) {
+				sig = x
+				break
+			}
+		}
+		if nonce == nil || sig == nil {
+			return nil, fmt.Errorf("MuSig2 public nonce or partial "+
+				"signature of participant %x missing",
+				key.SerializeCompressed())
+		}
+
+		pubNonces = append(pubNonces, nonce.PubNonce)
+		partialSigs = append(partialSigs, &sig.PartialSig)
+	}
+	aggNonce, err := musig2.AggregateNonces(pubNonces)
+	if err != nil {
+		return nil, err
+	}
+
+	// The partial signatures commit to the signature hash of the input,
+	// which depends on the outputs spent by all inputs.
+	prevOutFetcher, err := PrevOutputFetcher(p)
+	if err != nil {
+		return nil, err
+	}
+	sigHashes := txscript.NewTxSigHashes(p.UnsignedTx, prevOutFetcher)
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		sigHashes, pInput.SighashType, p.UnsignedTx, inIndex,
+		prevOutFetcher,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var msg [32]byte
+	copy(msg[:], sigHash)
+
+	finalNonce, err := musig2FinalNonce(aggNonce, aggKey.FinalKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	combineOpt := musig2.WithBip86TweakedCombine(
+		msg, participants.Keys, false,
+	)
+	if merkleRoot != nil {
+		combineOpt = musig2.WithTaprootTweakedCombine(
+			msg, participants.Keys, merkleRoot, false,
+		)
+	}
+	sig := musig2.CombineSigs(finalNonce, partialSigs, combineOpt)
+
+	// An invalid partial signature results in an invalid final signature,
+	// which is rejected here instead of producing an unspendable witness.
+	if !sig.Verify(msg[:], aggKey.FinalKey) {
+		return nil, fmt.Errorf("aggregated MuSig2 signature is invalid")
+	}
+
+	serializedSig := sig.Serialize()
+	if pInput.SighashType != txscript.SigHashDefault {
+		serializedSig = append(serializedSig, byte(pInput.SighashType))
+	}
+
+	return serializedSig, nil
+}
+
+// musig2FinalNonce returns the nonce R of the final signature of a MuSig2
+// session given the aggregate public nonce of all participants, the final
+// aggregate key and the message being signed.  It mirrors the computation the
+// musig2 package performs when signing, which allows the partial signatures
+// to be combined without being one of the signers:
+//
+//   - b = H(tag=MuSig/noncecoef, aggNonce || x(aggKey) || msg)
+//   - R = R1 + b*R2, or the generator point if R is the point at infinity
+func musig2FinalNonce(aggNonce [musig2.PubNonceSize]byte,
+	aggKey *btcec.PublicKey, msg [32]byte) (*btcec.PublicKey, error) {
+
+	var nonceMsgBuf bytes.Buffer
+	nonceMsgBuf.Write(aggNonce[:])
+	nonceMsgBuf.Write(schnorr.SerializePubKey(aggKey))
+	nonceMsgBuf.Write(msg[:])
+	nonceBlindHash := chainhash.TaggedHash(
+		musig2.NonceBlindTag, nonceMsgBuf.Bytes(),
+	)
+	var nonceBlinder btcec.ModNScalar
+	nonceBlinder.SetByteSlice(nonceBlindHash[:])
+
+	r1, err := btcec.ParseJacobian(
+		aggNonce[:btcec.PubKeyBytesLenCompressed],
+	)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := btcec.ParseJacobian(
+		aggNonce[btcec.PubKeyBytesLenCompressed:],
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce, infinityPoint btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&nonceBlinder, &r2, &r2)
+	btcec.AddNonConst(&r1, &r2, &nonce)
+	if nonce == infinityPoint {
+		btcec.Generator().AsJacobian(&nonce)
+	}
+
+	nonce.ToAffine()
+	return btcec.NewPublicKey(&nonce.X, &nonce.Y), nil
+}
+
+// hasMuSig2KeySpendSigs returns true if the passed input contains MuSig2
+// partial signatures for a key path spend.
+func hasMuSig2KeySpendSigs(pInput *PInput) bool {
+	for _, sig := range pInput.MuSig2PartialSigs {
+		if sig.LeafHash == nil {
+			return true
+		}
+	}
+	return false
+}
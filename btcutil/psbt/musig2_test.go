@@ -0,0 +1,323 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// musig2Signer is a participant of the MuSig2 session in the tests, which
+// works on its own copy of the PSBT.
+type musig2Signer struct {
+	privKey *btcec.PrivateKey
+	nonces  *musig2.Nonces
+	packet  *Packet
+}
+
+// copyPacket returns a deep copy of the passed packet by serializing and
+// parsing it again, which is how co-signers exchange PSBTs.
+func copyPacket(t *testing.T, p *Packet) *Packet {
+	t.Helper()
+
+	var b bytes.Buffer
+	require.NoError(t, p.Serialize(&b))
+	copied, err := NewFromRawBytes(&b, false)
+	require.NoError(t, err)
+
+	return copied
+}
+
+// TestMuSig2KeySpend tests the whole flow of three MuSig2 signers spending a
+// BIP 86 taproot output with the key path, each exchanging their public nonces
+// and partial signatures through the PSBT, which is then finalized by
+// aggregating them.
+func TestMuSig2KeySpend(t *testing.T) {
+	var (
+		signers []*musig2Signer
+		keys    []*btcec.PublicKey
+	)
+	for i := byte(1); i <= 3; i++ {
+		privKey, pubKey := btcec.PrivKeyFromBytes(
+			bytes.Repeat([]byte{i}, 32),
+		)
+		signers = append(signers, &musig2Signer{privKey: privKey})
+		keys = append(keys, pubKey)
+	}
+
+	aggKey, _, _, err := musig2.AggregateKeys(
+		keys, false, musig2.WithBIP86KeyTweak(),
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_1).
+		AddData(schnorr.SerializePubKey(aggKey.FinalKey)).
+		Script()
+	require.NoError(t, err)
+	utxo := &wire.TxOut{Value: 100_000, PkScript: pkScript}
+
+	// The creator sets up the PSBT spending the output along with the
+	// participants of the session.
+	prevOut := &wire.OutPoint{Hash: chainhash.Hash{1}, Index: 2}
+	packet, err := New(
+		[]*wire.OutPoint{prevOut},
+		[]*wire.TxOut{{Value: 90_000, PkScript: pkScript}}, 2, 0,
+		[]uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	updater, err := NewUpdater(packet)
+	require.NoError(t, err)
+	require.NoError(t, updater.AddInWitnessUtxo(utxo, 0))
+	require.NoError(t, updater.AddInMuSig2Participants(
+		aggKey.PreTweakedKey, keys, 0,
+	))
+	require.Equal(t, ErrDuplicateKey, updater.AddInMuSig2Participants(
+		aggKey.PreTweakedKey, keys, 0,
+	))
+	require.NoError(t, updater.AddOutMuSig2Participants(
+		aggKey.PreTweakedKey, keys, 0,
+	))
+
+	// The participants must aggregate to the passed key.
+	require.Equal(t, ErrInvalidPsbtFormat, updater.AddOutMuSig2Participants(
+		aggKey.FinalKey, keys, 0,
+	))
+
+	// Each signer adds its public nonce to its own copy of the PSBT.
+	nonceCopies := make([]*Packet, 0, len(signers))
+	for i, signer := range signers {
+		signer.nonces, err = musig2.GenNonces(
+			musig2.WithPublicKey(signer.privKey.PubKey()),
+			musig2.WithNonceSecretKeyAux(signer.privKey),
+		)
+		require.NoError(t, err)
+
+		signer.packet = copyPacket(t, packet)
+		updater, err := NewUpdater(signer.packet)
+		require.NoError(t, err)
+		require.NoError(t, updater.AddInMuSig2PubNonce(
+			keys[i], aggKey.PreTweakedKey, nil,
+			signer.nonces.PubNonce, 0,
+		))
+		require.Equal(t, ErrDuplicateKey, updater.AddInMuSig2PubNonce(
+			keys[i], aggKey.PreTweakedKey, nil,
+			signer.nonces.PubNonce, 0,
+		))
+
+		nonceCopies = append(nonceCopies, signer.packet)
+	}
+
+	// Only participants of the session can provide nonces.
+	_, otherKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{9}, 32))
+	updater, err = NewUpdater(copyPacket(t, packet))
+	require.NoError(t, err)
+	require.Equal(t, ErrInvalidPsbtFormat, updater.AddInMuSig2PubNonce(
+		otherKey, aggKey.PreTweakedKey, nil,
+		signers[0].nonces.PubNonce, 0,
+	))
+
+	// The nonces are combined and survive a serialization round trip.
+	withNonces, err := Combine(nonceCopies...)
+	require.NoError(t, err)
+	withNonces = copyPacket(t, withNonces)
+	pInput := withNonces.Inputs[0]
+	require.Len(t, pInput.MuSig2Participants, 1)
+	require.True(t, pInput.MuSig2Participants[0].AggKey.IsEqual(
+		aggKey.PreTweakedKey,
+	))
+	require.Len(t, pInput.MuSig2Participants[0].Keys, len(keys))
+	for i, key := range keys {
+		require.True(t, pInput.MuSig2Participants[0].Keys[i].IsEqual(
+			key,
+		))
+	}
+	require.Len(t, withNonces.Outputs[0].MuSig2Participants, 1)
+	require.Len(t, pInput.MuSig2PubNonces, len(signers))
+
+	// The input cannot be finalized without partial signatures.
+	_, err = MaybeFinalize(withNonces, 0)
+	require.Equal(t, ErrNotFinalizable, err)
+
+	// Each signer aggregates the nonces, signs and adds its partial
+	// signature to its own copy of the PSBT.
+	prevOutFetcher, err := PrevOutputFetcher(withNonces)
+	require.NoError(t, err)
+	sigHashes := txscript.NewTxSigHashes(
+		withNonces.UnsignedTx, prevOutFetcher,
+	)
+	sigHash, err := txscript.CalcTaprootSignatureHash(
+		sigHashes, txscript.SigHashDefault, withNonces.UnsignedTx, 0,
+		prevOutFetcher,
+	)
+	require.NoError(t, err)
+	var msg [32]byte
+	copy(msg[:], sigHash)
+
+	sigCopies := make([]*Packet, 0, len(signers))
+	for i, signer := range signers {
+		signer.packet = copyPacket(t, withNonces)
+		pubNonces := make([][musig2.PubNonceSize]byte, 0, len(signers))
+		for _, nonce := range signer.packet.Inputs[0].MuSig2PubNonces {
+			pubNonces = append(pubNonces, nonce.PubNonce)
+		}
+		aggNonce, err := musig2.AggregateNonces(pubNonces)
+		require.NoError(t, err)
+
+		partialSig, err := musig2.Sign(
+			signer.nonces.SecNonce, signer.privKey, aggNonce, keys,
+			msg, musig2.WithBip86SignTweak(),
+		)
+		require.NoError(t, err)
+
+		updater, err := NewUpdater(signer.packet)
+		require.NoError(t, err)
+		require.NoError(t, updater.AddInMuSig2PartialSig(
+			keys[i], aggKey.PreTweakedKey, nil, partialSig, 0,
+		))
+
+		sigCopies = append(sigCopies, signer.packet)
+	}
+
+	// The final signature can't be aggregated before all partial
+	// signatures are present.
+	_, err = MaybeFinalize(copyPacket(t, sigCopies[0]), 0)
+	require.Error(t, err)
+
+	signed, err := Combine(sigCopies...)
+	require.NoError(t, err)
+	signed = copyPacket(t, signed)
+	require.Len(t, signed.Inputs[0].MuSig2PartialSigs, len(signers))
+
+	// The partial signatures are aggregated into the key spend signature
+	// of the final witness, which is valid for the transaction.
+	success, err := MaybeFinalize(signed, 0)
+	require.NoError(t, err)
+	require.True(t, success)
+	require.Nil(t, signed.Inputs[0].MuSig2PartialSigs)
+
+	tx, err := Extract(signed)
+	require.NoError(t, err)
+	require.Len(t, tx.TxIn[0].Witness, 1)
+
+	vm, err := txscript.NewEngine(
+		utxo.PkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx, prevOutFetcher), utxo.Value,
+		prevOutFetcher,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+
+	// A corrupted partial signature results in an invalid signature,
+	// which the finalizer refuses to aggregate.
+	corrupted, err := Combine(sigCopies...)
+	require.NoError(t, err)
+	partialSig := &corrupted.Inputs[0].MuSig2PartialSigs[0].PartialSig
+	partialSig.S.Add(new(btcec.ModNScalar).SetInt(1))
+	_, err = MaybeFinalize(corrupted, 0)
+	require.Error(t, err)
+}
+
+// TestReadInvalidMuSig2Fields tests that malformed MuSig2 fields of inputs and
+// outputs are rejected when parsing a PSBT.
+func TestReadInvalidMuSig2Fields(t *testing.T) {
+	_, pubKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	key := pubKey.SerializeCompressed()
+	sigKey := append(append([]byte{}, key...), key...)
+
+	testCases := []struct {
+		name    string
+		keyType byte
+		output  bool
+		keydata []byte
+		value   []byte
+		err     error
+	}{{
+		name:    "participants short aggregate key",
+		keyType: byte(MuSig2ParticipantsInputType),
+		keydata: key[:32],
+		value:   key,
+		err:     ErrInvalidKeydata,
+	}, {
+		name:    "participants truncated key",
+		keyType: byte(MuSig2ParticipantsInputType),
+		keydata: key,
+		value:   append(append([]byte{}, key...), key[:32]...),
+		err:     ErrInvalidPsbtFormat,
+	}, {
+		name:    "output participants no keys",
+		keyType: byte(MuSig2ParticipantsOutputType),
+		output:  true,
+		keydata: key,
+		value:   nil,
+		err:     ErrInvalidPsbtFormat,
+	}, {
+		name:    "nonce short key data",
+		keyType: byte(MuSig2PubNonceType),
+		keydata: key,
+		value:   sigKey,
+		err:     ErrInvalidKeydata,
+	}, {
+		name:    "nonce short value",
+		keyType: byte(MuSig2PubNonceType),
+		keydata: sigKey,
+		value:   key,
+		err:     ErrInvalidPsbtFormat,
+	}, {
+		name:    "partial sig short leaf hash",
+		keyType: byte(MuSig2PartialSigType),
+		keydata: append(append([]byte{}, sigKey...), 1, 2, 3),
+		value:   bytes.Repeat([]byte{1}, 32),
+		err:     ErrInvalidKeydata,
+	}, {
+		name:    "partial sig overflow",
+		keyType: byte(MuSig2PartialSigType),
+		keydata: sigKey,
+		value:   bytes.Repeat([]byte{0xff}, 32),
+		err:     ErrInvalidPsbtFormat,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			packet, err := New(
+				[]*wire.OutPoint{{Index: 1}},
+				[]*wire.TxOut{{Value: 1}}, 2, 0,
+				[]uint32{wire.MaxTxInSequenceNum},
+			)
+			require.NoError(t, err)
+
+			var b bytes.Buffer
+			require.NoError(t, packet.Serialize(&b))
+			raw := b.Bytes()
+
+			// Both the input and the output map are empty, so the
+			// field is inserted before the separator of the
+			// respective map at the end of the PSBT.
+			idx := len(raw) - 2
+			if tc.output {
+				idx = len(raw) - 1
+			}
+
+			var field bytes.Buffer
+			require.NoError(t, serializeKVPairWithType(
+				&field, tc.keyType, tc.keydata, tc.value,
+			))
+			invalid := append(append([]byte{}, raw[:idx]...),
+				field.Bytes()...)
+			invalid = append(invalid, raw[idx:]...)
+
+			_, err = NewFromRawBytes(bytes.NewReader(invalid), false)
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
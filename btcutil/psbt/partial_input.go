@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -28,7 +29,20 @@ type PInput struct {
 	TaprootBip32Derivation []*TaprootBip32Derivation
 	TaprootInternalKey     []byte
 	TaprootMerkleRoot      []byte
+	MuSig2Participants     []*MuSig2Participants
+	MuSig2PubNonces        []*MuSig2PubNonce
+	MuSig2PartialSigs      []*MuSig2PartialSig
 	Unknowns               []*Unknown
+
+	// RequiredTimeLockTime is the minimum Unix timestamp this input of a
+	// version 2 PSBT requires as the lock time of the transaction.  It is
+	// zero if no time based lock time is required.
+	RequiredTimeLockTime uint32
+
+	// RequiredHeightLockTime is the minimum block height this input of a
+	// version 2 PSBT requires as the lock time of the transaction.  It is
+	// zero if no height based lock time is required.
+	RequiredHeightLockTime uint32
 }
 
 // NewPsbtInput creates an instance of PsbtInput given either a nonWitnessUtxo
@@ -67,7 +81,10 @@ func (pi *PInput) IsSane() bool {
 }
 
 // deserialize attempts to deserialize a new PInput from the passed io.Reader.
-func (pi *PInput) deserialize(r io.Reader) error {
+// The transaction input is only passed for version 2 packets, where the input
+// fields of the PSBT describe it and must be present.
+func (pi *PInput) deserialize(r io.Reader, txIn *wire.TxIn) error {
+	var hasPrevTxid, hasPrevIndex, hasSequence bool
 	for {
 		keyint, keydata, err := getKey(r)
 		if err != nil {
@@ -84,7 +101,15 @@ func (pi *PInput) deserialize(r io.Reader) error {
 			return err
 		}
 
-		switch InputType(keyint) {
+		// The fields describing the transaction input are only known in
+		// version 2 packets, so they are treated like any proprietary
+		// type in version 0 packets.
+		inputType := InputType(keyint)
+		if txIn == nil && isInputV2Type(inputType) {
+			inputType = ProprietaryInputType
+		}
+
+		switch inputType {
 
 		case NonWitnessUtxoType:
 			if pi.NonWitnessUtxo != nil {
@@ -364,6 +389,119 @@ func (pi *PInput) deserialize(r io.Reader) error {
 
 			pi.TaprootMerkleRoot = value
 
+		case MuSig2ParticipantsInputType:
+			participants, err := readMuSig2Participants(
+				keydata, value,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Duplicate keys are not allowed.
+			for _, x := range pi.MuSig2Participants {
+				if x.AggKey.IsEqual(participants.AggKey) {
+					return ErrDuplicateKey
+				}
+			}
+
+			pi.MuSig2Participants = append(
+				pi.MuSig2Participants, participants,
+			)
+
+		case MuSig2PubNonceType:
+			nonce, err := readMuSig2PubNonce(keydata, value)
+			if err != nil {
+				return err
+			}
+
+			// Duplicate keys are not allowed.
+			for _, x := range pi.MuSig2PubNonces {
+				if x.EqualKey(nonce) {
+					return ErrDuplicateKey
+				}
+			}
+
+			pi.MuSig2PubNonces = append(pi.MuSig2PubNonces, nonce)
+
+		case MuSig2PartialSigType:
+			sig, err := readMuSig2PartialSig(keydata, value)
+			if err != nil {
+				return err
+			}
+
+			// Duplicate keys are not allowed.
+			for _, x := range pi.MuSig2PartialSigs {
+				if x.EqualKey(sig) {
+					return ErrDuplicateKey
+				}
+			}
+
+			pi.MuSig2PartialSigs = append(pi.MuSig2PartialSigs, sig)
+
+		case PreviousTxidType:
+			if hasPrevTxid {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != chainhash.HashSize {
+				return ErrInvalidKeydata
+			}
+			copy(txIn.PreviousOutPoint.Hash[:], value)
+			hasPrevTxid = true
+
+		case PreviousOutputIndexType:
+			if hasPrevIndex {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return ErrInvalidKeydata
+			}
+			txIn.PreviousOutPoint.Index = binary.LittleEndian.Uint32(
+				value,
+			)
+			hasPrevIndex = true
+
+		case SequenceType:
+			if hasSequence {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return ErrInvalidKeydata
+			}
+			txIn.Sequence = binary.LittleEndian.Uint32(value)
+			hasSequence = true
+
+		case RequiredTimeLockTimeType:
+			if pi.RequiredTimeLockTime != 0 {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return ErrInvalidKeydata
+			}
+
+			// Time based lock times start at the lock time
+			// threshold.
+			lockTime := binary.LittleEndian.Uint32(value)
+			if lockTime < txscript.LockTimeThreshold {
+				return ErrInvalidKeydata
+			}
+			pi.RequiredTimeLockTime = lockTime
+
+		case RequiredHeightLockTimeType:
+			if pi.RequiredHeightLockTime != 0 {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return ErrInvalidKeydata
+			}
+
+			// Height based lock times are below the lock time
+			// threshold.
+			lockTime := binary.LittleEndian.Uint32(value)
+			if lockTime == 0 || lockTime >= txscript.LockTimeThreshold {
+				return ErrInvalidKeydata
+			}
+			pi.RequiredHeightLockTime = lockTime
+
 		default:
 			// A fall through case for any proprietary types.
 			keyintanddata := []byte{byte(keyint)}
@@ -385,11 +523,18 @@ func (pi *PInput) deserialize(r io.Reader) error {
 		}
 	}
 
+	// The previous outpoint must be present in version 2 packets.
+	if txIn != nil && (!hasPrevTxid || !hasPrevIndex) {
+		return ErrInvalidPsbtFormat
+	}
+
 	return nil
 }
 
 // serialize attempts to serialize the target PInput into the passed io.Writer.
-func (pi *PInput) serialize(w io.Writer) error {
+// The transaction input is only passed for version 2 packets, where the input
+// fields of the PSBT describe it.
+func (pi *PInput) serialize(w io.Writer, txIn *wire.TxIn) error {
 
 	if !pi.IsSane() {
 		return ErrInvalidPsbtFormat
@@ -572,6 +717,55 @@ func (pi *PInput) serialize(w io.Writer) error {
 				return err
 			}
 		}
+
+		sort.Slice(pi.MuSig2Participants, func(i, j int) bool {
+			return pi.MuSig2Participants[i].SortBefore(
+				pi.MuSig2Participants[j],
+			)
+		})
+		for _, participants := range pi.MuSig2Participants {
+			err := serializeKVPairWithType(
+				w, uint8(MuSig2ParticipantsInputType),
+				participants.AggKey.SerializeCompressed(),
+				serializeMuSig2Participants(participants),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(pi.MuSig2PubNonces, func(i, j int) bool {
+			return pi.MuSig2PubNonces[i].SortBefore(
+				pi.MuSig2PubNonces[j],
+			)
+		})
+		for _, nonce := range pi.MuSig2PubNonces {
+			err := serializeKVPairWithType(
+				w, uint8(MuSig2PubNonceType), nonce.key(),
+				nonce.PubNonce[:],
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(pi.MuSig2PartialSigs, func(i, j int) bool {
+			return pi.MuSig2PartialSigs[i].SortBefore(
+				pi.MuSig2PartialSigs[j],
+			)
+		})
+		for _, sig := range pi.MuSig2PartialSigs {
+			value, err := serializeMuSig2PartialSig(sig)
+			if err != nil {
+				return err
+			}
+			err = serializeKVPairWithType(
+				w, uint8(MuSig2PartialSigType), sig.key(), value,
+			)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if pi.FinalScriptSig != nil {
@@ -592,6 +786,12 @@ func (pi *PInput) serialize(w io.Writer) error {
 		}
 	}
 
+	if txIn != nil {
+		if err := serializeInputV2(w, pi, txIn); err != nil {
+			return err
+		}
+	}
+
 	// Unknown is a special case; we don't have a key type, only a key and
 	// a value field
 	for _, kv := range pi.Unknowns {
@@ -603,3 +803,69 @@ func (pi *PInput) serialize(w io.Writer) error {
 
 	return nil
 }
+
+// isInputV2Type returns true if the passed input type is only known in version
+// 2 packets.
+func isInputV2Type(inputType InputType) bool {
+	switch inputType {
+	case PreviousTxidType, PreviousOutputIndexType, SequenceType,
+		RequiredTimeLockTimeType, RequiredHeightLockTimeType:
+
+		return true
+	}
+
+	return false
+}
+
+// serializeInputV2 writes out the fields of a version 2 PSBT input which
+// describe the passed transaction input along with the lock times it requires.
+func serializeInputV2(w io.Writer, pi *PInput, txIn *wire.TxIn) error {
+	err := serializeKVPairWithType(
+		w, uint8(PreviousTxidType), nil, txIn.PreviousOutPoint.Hash[:],
+	)
+	if err != nil {
+		return err
+	}
+
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], txIn.PreviousOutPoint.Index)
+	err = serializeKVPairWithType(
+		w, uint8(PreviousOutputIndexType), nil, buf[:],
+	)
+	if err != nil {
+		return err
+	}
+
+	// The sequence number is assumed to be final if omitted.
+	if txIn.Sequence != wire.MaxTxInSequenceNum {
+		binary.LittleEndian.PutUint32(buf[:], txIn.Sequence)
+		err := serializeKVPairWithType(
+			w, uint8(SequenceType), nil, buf[:],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pi.RequiredTimeLockTime != 0 {
+		binary.LittleEndian.PutUint32(buf[:], pi.RequiredTimeLockTime)
+		err := serializeKVPairWithType(
+			w, uint8(RequiredTimeLockTimeType), nil, buf[:],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pi.RequiredHeightLockTime != 0 {
+		binary.LittleEndian.PutUint32(buf[:], pi.RequiredHeightLockTime)
+		err := serializeKVPairWithType(
+			w, uint8(RequiredHeightLockTimeType), nil, buf[:],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package psbt
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
 	"sort"
 
@@ -17,6 +18,7 @@ type POutput struct {
 	TaprootInternalKey     []byte
 	TaprootTapTree         []byte
 	TaprootBip32Derivation []*TaprootBip32Derivation
+	MuSig2Participants     []*MuSig2Participants
 }
 
 // NewPsbtOutput creates an instance of PsbtOutput; the three parameters
@@ -32,7 +34,10 @@ func NewPsbtOutput(redeemScript []byte, witnessScript []byte,
 }
 
 // deserialize attempts to recode a new POutput from the passed io.Reader.
-func (po *POutput) deserialize(r io.Reader) error {
+// The transaction output is only passed for version 2 packets, where the
+// output fields of the PSBT describe it and must be present.
+func (po *POutput) deserialize(r io.Reader, txOut *wire.TxOut) error {
+	var hasAmount, hasScript bool
 	for {
 		keyint, keydata, err := getKey(r)
 		if err != nil {
@@ -141,18 +146,69 @@ func (po *POutput) deserialize(r io.Reader) error {
 				po.TaprootBip32Derivation, taprootDerivation,
 			)
 
+		case MuSig2ParticipantsOutputType:
+			participants, err := readMuSig2Participants(
+				keydata, value,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Duplicate keys are not allowed.
+			for _, x := range po.MuSig2Participants {
+				if x.AggKey.IsEqual(participants.AggKey) {
+					return ErrDuplicateKey
+				}
+			}
+
+			po.MuSig2Participants = append(
+				po.MuSig2Participants, participants,
+			)
+
+		case AmountType:
+			if txOut == nil {
+				return ErrInvalidPsbtFormat
+			}
+			if hasAmount {
+				return ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 8 {
+				return ErrInvalidKeydata
+			}
+			txOut.Value = int64(binary.LittleEndian.Uint64(value))
+			hasAmount = true
+
+		case ScriptType:
+			if txOut == nil {
+				return ErrInvalidPsbtFormat
+			}
+			if hasScript {
+				return ErrDuplicateKey
+			}
+			if keydata != nil {
+				return ErrInvalidKeydata
+			}
+			txOut.PkScript = value
+			hasScript = true
+
 		default:
 			// Unknown type is allowed for inputs but not outputs.
 			return ErrInvalidPsbtFormat
 		}
 	}
 
+	// The amount and script must be present in version 2 packets.
+	if txOut != nil && (!hasAmount || !hasScript) {
+		return ErrInvalidPsbtFormat
+	}
+
 	return nil
 }
 
 // serialize attempts to write out the target POutput into the passed
-// io.Writer.
-func (po *POutput) serialize(w io.Writer) error {
+// io.Writer.  The transaction output is only passed for version 2 packets,
+// where the output fields of the PSBT describe it.
+func (po *POutput) serialize(w io.Writer, txOut *wire.TxOut) error {
 	if po.RedeemScript != nil {
 		err := serializeKVPairWithType(
 			w, uint8(RedeemScriptOutputType), nil, po.RedeemScript,
@@ -226,5 +282,39 @@ func (po *POutput) serialize(w io.Writer) error {
 		}
 	}
 
+	sort.Slice(po.MuSig2Participants, func(i, j int) bool {
+		return po.MuSig2Participants[i].SortBefore(
+			po.MuSig2Participants[j],
+		)
+	})
+	for _, participants := range po.MuSig2Participants {
+		err := serializeKVPairWithType(
+			w, uint8(MuSig2ParticipantsOutputType),
+			participants.AggKey.SerializeCompressed(),
+			serializeMuSig2Participants(participants),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if txOut != nil {
+		var amount [8]byte
+		binary.LittleEndian.PutUint64(amount[:], uint64(txOut.Value))
+		err := serializeKVPairWithType(
+			w, uint8(AmountType), nil, amount[:],
+		)
+		if err != nil {
+			return err
+		}
+
+		err = serializeKVPairWithType(
+			w, uint8(ScriptType), nil, txOut.PkScript,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -10,8 +10,9 @@ package psbt
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
-
+	"fmt"
 	"io"
 
 	"github.com/btcsuite/btcd/wire"
@@ -28,6 +29,36 @@ var (
 	}
 )
 
+const (
+	// Version0 is the version of PSBTs as defined in BIP 174, which house
+	// the complete unsigned transaction in the global section.
+	Version0 uint32 = 0
+
+	// Version2 is the version of PSBTs as defined in BIP 370, which house
+	// the fields of the unsigned transaction in the global, input and
+	// output sections instead, allowing inputs and outputs to be added.
+	Version2 uint32 = 2
+)
+
+// minTxVersionV2 is the lowest transaction version allowed in version 2 PSBTs.
+const minTxVersionV2 = 2
+
+// The following flags are used in the TxModifiable field of version 2 PSBTs.
+const (
+	// InputsModifiable signals that inputs may be added to or removed from
+	// the PSBT.
+	InputsModifiable uint8 = 1 << 0
+
+	// OutputsModifiable signals that outputs may be added to or removed
+	// from the PSBT.
+	OutputsModifiable uint8 = 1 << 1
+
+	// HasSigHashSingle signals that the PSBT contains a signature using
+	// SIGHASH_SINGLE, so its input and the output with the same index must
+	// be kept together when inputs and outputs are added or removed.
+	HasSigHashSingle uint8 = 1 << 2
+)
+
 // MaxPsbtValueLength is the size of the largest transaction serialization
 // that could be passed in a NonWitnessUtxo field. This is definitely
 //less than 4M.
@@ -102,6 +133,22 @@ var (
 	// scriptwitness given is not supported by this codebase, or is otherwise
 	// not valid.
 	ErrUnsupportedScriptType = errors.New("Unsupported script type")
+
+	// ErrUnsupportedVersion indicates that the version of a PSBT is not
+	// supported by this codebase.
+	ErrUnsupportedVersion = errors.New("Unsupported PSBT version")
+
+	// ErrInvalidLockTime indicates that the inputs of a version 2 PSBT
+	// require lock times of different types, so no lock time can satisfy
+	// all of them.
+	ErrInvalidLockTime = errors.New("Inputs require conflicting lock " +
+		"time types")
+
+	// ErrCombineMismatch indicates that PSBTs which were passed to the
+	// Combiner don't describe the same transaction or are of different
+	// versions.
+	ErrCombineMismatch = errors.New("Cannot combine PSBTs for different " +
+		"transactions")
 )
 
 // Unknown is a struct encapsulating a key-value pair for which the key type is
@@ -130,6 +177,20 @@ type Packet struct {
 
 	// Unknowns are the set of custom types (global only) within this PSBT.
 	Unknowns []Unknown
+
+	// Version is the version of this PSBT, either Version0 or Version2.
+	// The unsigned transaction is always available in UnsignedTx, but
+	// version 2 packets serialize its fields separately.
+	Version uint32
+
+	// FallbackLockTime is the lock time of the transaction of a version 2
+	// PSBT if none of its inputs require a lock time.  It is nil if not
+	// present, in which case the fallback lock time is zero.
+	FallbackLockTime *uint32
+
+	// TxModifiable houses the InputsModifiable, OutputsModifiable and
+	// HasSigHashSingle flags of a version 2 PSBT.
+	TxModifiable uint8
 }
 
 // validateUnsignedTx returns true if the transaction is unsigned.  Note that
@@ -194,40 +255,19 @@ func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
 		return nil, ErrInvalidMagicBytes
 	}
 
-	// Next we parse the GLOBAL section.  There is currently only 1 known
-	// key type, UnsignedTx.  We insist this exists first; unknowns are
-	// allowed, but only after.
-	keyint, keydata, err := getKey(r)
-	if err != nil {
-		return nil, err
-	}
-	if GlobalType(keyint) != UnsignedTxType || keydata != nil {
-		return nil, ErrInvalidPsbtFormat
-	}
-
-	// Now that we've verified the global type is present, we'll decode it
-	// into a proper unsigned transaction, and validate it.
-	value, err := wire.ReadVarBytes(
-		r, 0, MaxPsbtValueLength, "PSBT value",
+	// Next we parse the GLOBAL section.  The fields it must contain depend
+	// on the version of the PSBT, so they are only validated once the
+	// complete section was read.  Unknowns are allowed as well.
+	var (
+		msgTx            *wire.MsgTx
+		version          *uint32
+		txVersion        *int32
+		fallbackLockTime *uint32
+		inputCount       *uint64
+		outputCount      *uint64
+		txModifiable     *uint8
+		unknownSlice     []Unknown
 	)
-	if err != nil {
-		return nil, err
-	}
-	msgTx := wire.NewMsgTx(2)
-
-	// BIP-0174 states: "The transaction must be in the old serialization
-	// format (without witnesses)."
-	err = msgTx.DeserializeNoWitness(bytes.NewReader(value))
-	if err != nil {
-		return nil, err
-	}
-	if !validateUnsignedTX(msgTx) {
-		return nil, ErrInvalidRawTxSigned
-	}
-
-	// Next we parse any unknowns that may be present, making sure that we
-	// break at the separator.
-	var unknownSlice []Unknown
 	for {
 		keyint, keydata, err := getKey(r)
 		if err != nil {
@@ -244,51 +284,199 @@ func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
 			return nil, err
 		}
 
-		keyintanddata := []byte{byte(keyint)}
-		keyintanddata = append(keyintanddata, keydata...)
+		switch GlobalType(keyint) {
+		case UnsignedTxType:
+			if msgTx != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil {
+				return nil, ErrInvalidPsbtFormat
+			}
+
+			// BIP-0174 states: "The transaction must be in the old
+			// serialization format (without witnesses)."
+			msgTx = wire.NewMsgTx(2)
+			err = msgTx.DeserializeNoWitness(bytes.NewReader(value))
+			if err != nil {
+				return nil, err
+			}
+			if !validateUnsignedTX(msgTx) {
+				return nil, ErrInvalidRawTxSigned
+			}
+
+		case VersionType:
+			if version != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return nil, ErrInvalidKeydata
+			}
+			v := binary.LittleEndian.Uint32(value)
+			version = &v
+
+		case TxVersionType:
+			if txVersion != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return nil, ErrInvalidKeydata
+			}
+			v := int32(binary.LittleEndian.Uint32(value))
+			txVersion = &v
+
+		case FallbackLockTimeType:
+			if fallbackLockTime != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 4 {
+				return nil, ErrInvalidKeydata
+			}
+			v := binary.LittleEndian.Uint32(value)
+			fallbackLockTime = &v
+
+		case InputCountType, OutputCountType:
+			count := &inputCount
+			if GlobalType(keyint) == OutputCountType {
+				count = &outputCount
+			}
+			if *count != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil {
+				return nil, ErrInvalidKeydata
+			}
+			v, err := readCompactSize(value)
+			if err != nil {
+				return nil, err
+			}
+			*count = &v
+
+		case TxModifiableType:
+			if txModifiable != nil {
+				return nil, ErrDuplicateKey
+			}
+			if keydata != nil || len(value) != 1 {
+				return nil, ErrInvalidKeydata
+			}
+			v := value[0]
+			txModifiable = &v
+
+		default:
+			keyintanddata := []byte{byte(keyint)}
+			keyintanddata = append(keyintanddata, keydata...)
+
+			newUnknown := Unknown{
+				Key:   keyintanddata,
+				Value: value,
+			}
+			unknownSlice = append(unknownSlice, newUnknown)
+		}
+	}
+
+	// Version 0 packets must contain the unsigned transaction and none of
+	// the fields describing it in version 2 packets, while version 2
+	// packets must describe it with those fields instead.
+	newPsbt := Packet{
+		Unknowns: unknownSlice,
+	}
+	if version != nil {
+		newPsbt.Version = *version
+	}
+	switch newPsbt.Version {
+	case Version0:
+		if msgTx == nil || txVersion != nil ||
+			fallbackLockTime != nil || inputCount != nil ||
+			outputCount != nil || txModifiable != nil {
+
+			return nil, ErrInvalidPsbtFormat
+		}
+
+	case Version2:
+		if msgTx != nil || txVersion == nil || inputCount == nil ||
+			outputCount == nil {
+
+			return nil, ErrInvalidPsbtFormat
+		}
+		if *txVersion < minTxVersionV2 {
+			return nil, ErrInvalidPsbtFormat
+		}
 
-		newUnknown := Unknown{
-			Key:   keyintanddata,
-			Value: value,
+		msgTx = wire.NewMsgTx(*txVersion)
+		newPsbt.FallbackLockTime = fallbackLockTime
+		if txModifiable != nil {
+			newPsbt.TxModifiable = *txModifiable
 		}
-		unknownSlice = append(unknownSlice, newUnknown)
+
+	default:
+		return nil, ErrUnsupportedVersion
 	}
+	newPsbt.UnsignedTx = msgTx
 
-	// Next we parse the INPUT section.
-	inSlice := make([]PInput, len(msgTx.TxIn))
-	for i := range msgTx.TxIn {
+	// Next we parse the INPUT section.  The inputs of version 2 packets
+	// also describe the inputs of the unsigned transaction.  The slices
+	// are grown as inputs are read to avoid trusting the input count.
+	if newPsbt.Version == Version0 {
+		newPsbt.Inputs = make([]PInput, 0, len(msgTx.TxIn))
+	}
+	for i := 0; newPsbt.Version == Version0 && i < len(msgTx.TxIn); i++ {
 		input := PInput{}
-		err = input.deserialize(r)
+		err := input.deserialize(r, nil)
 		if err != nil {
 			return nil, err
 		}
 
-		inSlice[i] = input
+		newPsbt.Inputs = append(newPsbt.Inputs, input)
+	}
+	for i := uint64(0); newPsbt.Version == Version2 && i < *inputCount; i++ {
+		input := PInput{}
+		txIn := &wire.TxIn{Sequence: wire.MaxTxInSequenceNum}
+		err := input.deserialize(r, txIn)
+		if err != nil {
+			return nil, err
+		}
+
+		newPsbt.Inputs = append(newPsbt.Inputs, input)
+		msgTx.AddTxIn(txIn)
 	}
 
 	// Next we parse the OUTPUT section.
-	outSlice := make([]POutput, len(msgTx.TxOut))
-	for i := range msgTx.TxOut {
+	if newPsbt.Version == Version0 {
+		newPsbt.Outputs = make([]POutput, 0, len(msgTx.TxOut))
+	}
+	for i := 0; newPsbt.Version == Version0 && i < len(msgTx.TxOut); i++ {
 		output := POutput{}
-		err = output.deserialize(r)
+		err := output.deserialize(r, nil)
 		if err != nil {
 			return nil, err
 		}
 
-		outSlice[i] = output
+		newPsbt.Outputs = append(newPsbt.Outputs, output)
 	}
+	for i := uint64(0); newPsbt.Version == Version2 && i < *outputCount; i++ {
+		output := POutput{}
+		txOut := &wire.TxOut{}
+		err := output.deserialize(r, txOut)
+		if err != nil {
+			return nil, err
+		}
 
-	// Populate the new Packet object
-	newPsbt := Packet{
-		UnsignedTx: msgTx,
-		Inputs:     inSlice,
-		Outputs:    outSlice,
-		Unknowns:   unknownSlice,
+		newPsbt.Outputs = append(newPsbt.Outputs, output)
+		msgTx.AddTxOut(txOut)
+	}
+
+	// The lock time of version 2 packets is determined by the fallback
+	// lock time and the lock times required by the inputs.
+	if newPsbt.Version == Version2 {
+		lockTime, err := newPsbt.DetermineLockTime()
+		if err != nil {
+			return nil, err
+		}
+		msgTx.LockTime = lockTime
 	}
 
 	// Extended sanity checking is applied here to make sure the
 	// externally-passed Packet follows all the rules.
-	if err = newPsbt.SanityCheck(); err != nil {
+	if err := newPsbt.SanityCheck(); err != nil {
 		return nil, err
 	}
 
@@ -305,22 +493,55 @@ func (p *Packet) Serialize(w io.Writer) error {
 		return err
 	}
 
-	// Next we prep to write out the unsigned transaction by first
-	// serializing it into an intermediate buffer.
-	serializedTx := bytes.NewBuffer(
-		make([]byte, 0, p.UnsignedTx.SerializeSize()),
-	)
-	if err := p.UnsignedTx.SerializeNoWitness(serializedTx); err != nil {
-		return err
+	switch p.Version {
+	case Version0:
+		// Next we prep to write out the unsigned transaction by first
+		// serializing it into an intermediate buffer.
+		serializedTx := bytes.NewBuffer(
+			make([]byte, 0, p.UnsignedTx.SerializeSize()),
+		)
+		err := p.UnsignedTx.SerializeNoWitness(serializedTx)
+		if err != nil {
+			return err
+		}
+
+		// Now that we have the serialized transaction, we'll write it
+		// out to the proper global type.
+		err = serializeKVPairWithType(
+			w, uint8(UnsignedTxType), nil, serializedTx.Bytes(),
+		)
+		if err != nil {
+			return err
+		}
+
+	case Version2:
+		// Version 2 packets describe the unsigned transaction with
+		// separate global fields instead.
+		if err := p.serializeGlobalV2(w); err != nil {
+			return err
+		}
+
+	default:
+		return ErrUnsupportedVersion
 	}
 
-	// Now that we have the serialized transaction, we'll write it out to
-	// the proper global type.
-	err := serializeKVPairWithType(
-		w, uint8(UnsignedTxType), nil, serializedTx.Bytes(),
-	)
-	if err != nil {
-		return err
+	// The version is only written for packets other than version 0, for
+	// which it is optional.
+	if p.Version != Version0 {
+		var version [4]byte
+		binary.LittleEndian.PutUint32(version[:], p.Version)
+		err := serializeKVPairWithType(
+			w, uint8(VersionType), nil, version[:],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, kv := range p.Unknowns {
+		if err := serializeKVpair(w, kv.Key, kv.Value); err != nil {
+			return err
+		}
 	}
 
 	// With that our global section is done, so we'll write out the
@@ -330,8 +551,12 @@ func (p *Packet) Serialize(w io.Writer) error {
 		return err
 	}
 
-	for _, pInput := range p.Inputs {
-		err := pInput.serialize(w)
+	for i, pInput := range p.Inputs {
+		var txIn *wire.TxIn
+		if p.Version == Version2 {
+			txIn = p.UnsignedTx.TxIn[i]
+		}
+		err := pInput.serialize(w, txIn)
 		if err != nil {
 			return err
 		}
@@ -341,8 +566,12 @@ func (p *Packet) Serialize(w io.Writer) error {
 		}
 	}
 
-	for _, pOutput := range p.Outputs {
-		err := pOutput.serialize(w)
+	for i, pOutput := range p.Outputs {
+		var txOut *wire.TxOut
+		if p.Version == Version2 {
+			txOut = p.UnsignedTx.TxOut[i]
+		}
+		err := pOutput.serialize(w, txOut)
 		if err != nil {
 			return err
 		}
@@ -355,6 +584,55 @@ func (p *Packet) Serialize(w io.Writer) error {
 	return nil
 }
 
+// serializeGlobalV2 writes out the global fields which describe the unsigned
+// transaction of a version 2 packet.
+func (p *Packet) serializeGlobalV2(w io.Writer) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(p.UnsignedTx.Version))
+	err := serializeKVPairWithType(w, uint8(TxVersionType), nil, buf[:])
+	if err != nil {
+		return err
+	}
+
+	if p.FallbackLockTime != nil {
+		binary.LittleEndian.PutUint32(buf[:], *p.FallbackLockTime)
+		err := serializeKVPairWithType(
+			w, uint8(FallbackLockTimeType), nil, buf[:],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = serializeKVPairWithType(
+		w, uint8(InputCountType), nil,
+		compactSizeBytes(uint64(len(p.UnsignedTx.TxIn))),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = serializeKVPairWithType(
+		w, uint8(OutputCountType), nil,
+		compactSizeBytes(uint64(len(p.UnsignedTx.TxOut))),
+	)
+	if err != nil {
+		return err
+	}
+
+	if p.TxModifiable != 0 {
+		err := serializeKVPairWithType(
+			w, uint8(TxModifiableType), nil,
+			[]byte{p.TxModifiable},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // B64Encode returns the base64 encoding of the serialization of
 // the current PSBT, or an error if the encoding fails.
 func (p *Packet) B64Encode() (string, error) {
@@ -379,6 +657,108 @@ func (p *Packet) IsComplete() bool {
 	return true
 }
 
+// DetermineLockTime returns the lock time of the transaction of the PSBT.  For
+// version 0 packets, it is the lock time of the unsigned transaction.  For
+// version 2 packets, it is determined by the lock times required by the inputs
+// as defined in BIP 370, falling back to FallbackLockTime when no input
+// requires one.  ErrInvalidLockTime is returned when the inputs require lock
+// times of different types.
+func (p *Packet) DetermineLockTime() (uint32, error) {
+	if p.Version == Version0 {
+		return p.UnsignedTx.LockTime, nil
+	}
+
+	// Inputs may require a height and a time based lock time at the same
+	// time, so the type which is supported by all of the inputs requiring
+	// a lock time is chosen, preferring heights.
+	var (
+		heightLockTime, timeLockTime uint32
+		allHeights, allTimes         = true, true
+		required                     bool
+	)
+	for _, pInput := range p.Inputs {
+		if pInput.RequiredHeightLockTime == 0 &&
+			pInput.RequiredTimeLockTime == 0 {
+
+			continue
+		}
+		required = true
+
+		if pInput.RequiredHeightLockTime == 0 {
+			allHeights = false
+		} else if pInput.RequiredHeightLockTime > heightLockTime {
+			heightLockTime = pInput.RequiredHeightLockTime
+		}
+
+		if pInput.RequiredTimeLockTime == 0 {
+			allTimes = false
+		} else if pInput.RequiredTimeLockTime > timeLockTime {
+			timeLockTime = pInput.RequiredTimeLockTime
+		}
+	}
+
+	switch {
+	case !required:
+		if p.FallbackLockTime != nil {
+			return *p.FallbackLockTime, nil
+		}
+		return 0, nil
+
+	case allHeights:
+		return heightLockTime, nil
+
+	case allTimes:
+		return timeLockTime, nil
+
+	default:
+		return 0, ErrInvalidLockTime
+	}
+}
+
+// SetVersion converts the PSBT to the passed version.  Converting a version 0
+// packet to version 2 requires a transaction version of at least 2 and keeps
+// the lock time of the transaction as the fallback lock time.  Converting a
+// version 2 packet to version 0 fixes the lock time of the transaction and
+// drops the lock times required by the inputs along with the TxModifiable
+// flags, which are not supported by version 0 packets.
+func (p *Packet) SetVersion(version uint32) error {
+	switch version {
+	case p.Version:
+		return nil
+
+	case Version0:
+		lockTime, err := p.DetermineLockTime()
+		if err != nil {
+			return err
+		}
+		p.UnsignedTx.LockTime = lockTime
+		p.FallbackLockTime = nil
+		p.TxModifiable = 0
+		for i := range p.Inputs {
+			p.Inputs[i].RequiredTimeLockTime = 0
+			p.Inputs[i].RequiredHeightLockTime = 0
+		}
+
+	case Version2:
+		if p.UnsignedTx.Version < minTxVersionV2 {
+			return fmt.Errorf("transaction version %d is not "+
+				"supported by version 2 PSBTs",
+				p.UnsignedTx.Version)
+		}
+		p.FallbackLockTime = nil
+		if p.UnsignedTx.LockTime != 0 {
+			lockTime := p.UnsignedTx.LockTime
+			p.FallbackLockTime = &lockTime
+		}
+
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	p.Version = version
+	return nil
+}
+
 // SanityCheck checks conditions on a PSBT to ensure that it obeys the
 // rules of BIP174, and returns true if so, false if not.
 func (p *Packet) SanityCheck() error {
@@ -387,6 +767,33 @@ func (p *Packet) SanityCheck() error {
 		return ErrInvalidRawTxSigned
 	}
 
+	// The fields which are specific to version 2 packets must not be set
+	// in version 0 packets, where they would silently get lost.
+	switch p.Version {
+	case Version0:
+		if p.FallbackLockTime != nil || p.TxModifiable != 0 {
+			return ErrInvalidPsbtFormat
+		}
+		for _, tin := range p.Inputs {
+			if tin.RequiredTimeLockTime != 0 ||
+				tin.RequiredHeightLockTime != 0 {
+
+				return ErrInvalidPsbtFormat
+			}
+		}
+
+	case Version2:
+		if p.UnsignedTx.Version < minTxVersionV2 {
+			return ErrInvalidPsbtFormat
+		}
+		if _, err := p.DetermineLockTime(); err != nil {
+			return err
+		}
+
+	default:
+		return ErrUnsupportedVersion
+	}
+
 	for _, tin := range p.Inputs {
 		if !tin.IsSane() {
 			return ErrInvalidPsbtFormat
@@ -1502,3 +1502,267 @@ func TestWitnessForNonWitnessUtxo(t *testing.T) {
 		t.Fatalf("unable to extract funding TX: %v", err)
 	}
 }
+
+// TestVersion2RoundTrip ensures version 0 packets are converted to version 2
+// and back and that version 2 packets are serialized and parsed again with the
+// fields describing the unsigned transaction.
+func TestVersion2RoundTrip(t *testing.T) {
+	packet := testPacket(t, Version0)
+	packet.UnsignedTx.LockTime = 1000
+	packet.UnsignedTx.TxIn[0].Sequence = 10
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(
+		2000, bytes.Repeat([]byte{txscript.OP_TRUE}, 2),
+	)
+	unsignedTx := packet.UnsignedTx.Copy()
+
+	require.NoError(t, packet.SetVersion(Version2))
+	require.Equal(t, Version2, packet.Version)
+	require.NotNil(t, packet.FallbackLockTime)
+	require.EqualValues(t, 1000, *packet.FallbackLockTime)
+	packet.TxModifiable = InputsModifiable
+	packet.Inputs[1].RequiredHeightLockTime = 2000
+
+	var b bytes.Buffer
+	require.NoError(t, packet.Serialize(&b))
+	serialized := b.Bytes()
+	parsed, err := NewFromRawBytes(bytes.NewReader(serialized), false)
+	require.NoError(t, err)
+
+	// The required lock time of the second input takes precedence over
+	// the fallback lock time.
+	unsignedTx.LockTime = 2000
+	require.Equal(t, unsignedTx, parsed.UnsignedTx)
+	require.Equal(t, Version2, parsed.Version)
+	require.Equal(t, packet.FallbackLockTime, parsed.FallbackLockTime)
+	require.Equal(t, InputsModifiable, parsed.TxModifiable)
+	require.EqualValues(t, 2000, parsed.Inputs[1].RequiredHeightLockTime)
+	require.Equal(t, packet.Inputs[0].WitnessUtxo,
+		parsed.Inputs[0].WitnessUtxo)
+
+	b.Reset()
+	require.NoError(t, parsed.Serialize(&b))
+	require.Equal(t, serialized, b.Bytes())
+
+	// Converting back to version 0 fixes the lock time and drops the
+	// fields which are specific to version 2.
+	require.NoError(t, parsed.SetVersion(Version0))
+	require.Equal(t, unsignedTx, parsed.UnsignedTx)
+	require.Nil(t, parsed.FallbackLockTime)
+	require.Zero(t, parsed.TxModifiable)
+	require.Zero(t, parsed.Inputs[1].RequiredHeightLockTime)
+	b.Reset()
+	require.NoError(t, parsed.Serialize(&b))
+	parsed, err = NewFromRawBytes(&b, false)
+	require.NoError(t, err)
+	require.Equal(t, unsignedTx.TxHash(), parsed.UnsignedTx.TxHash())
+
+	// Transactions with a version below 2 can't be described by version 2
+	// packets.
+	packet = testPacket(t, Version0)
+	packet.UnsignedTx.Version = 1
+	require.Error(t, packet.SetVersion(Version2))
+	require.ErrorIs(t, packet.SetVersion(1), ErrUnsupportedVersion)
+}
+
+// TestDetermineLockTime ensures the lock time of version 2 packets is
+// determined by the lock times required by their inputs as defined in BIP 370.
+func TestDetermineLockTime(t *testing.T) {
+	const (
+		height = 100
+		time   = txscript.LockTimeThreshold + 100
+	)
+	fallback := uint32(50)
+
+	tests := []struct {
+		name     string
+		fallback *uint32
+		heights  [2]uint32
+		times    [2]uint32
+		lockTime uint32
+		err      error
+	}{{
+		name:     "no requirements without fallback",
+		lockTime: 0,
+	}, {
+		name:     "no requirements with fallback",
+		fallback: &fallback,
+		lockTime: fallback,
+	}, {
+		name:     "maximum height",
+		fallback: &fallback,
+		heights:  [2]uint32{height, height + 1},
+		lockTime: height + 1,
+	}, {
+		name:     "maximum time",
+		times:    [2]uint32{time + 1, time},
+		lockTime: time + 1,
+	}, {
+		name:     "height preferred when both are supported",
+		heights:  [2]uint32{height, height},
+		times:    [2]uint32{time, time},
+		lockTime: height,
+	}, {
+		name:     "time supported by all inputs",
+		heights:  [2]uint32{height, 0},
+		times:    [2]uint32{time, time},
+		lockTime: time,
+	}, {
+		name:    "conflicting types",
+		heights: [2]uint32{height, 0},
+		times:   [2]uint32{0, time},
+		err:     ErrInvalidLockTime,
+	}}
+
+	for _, test := range tests {
+		packet := testPacket(t, Version2)
+		packet.FallbackLockTime = test.fallback
+		for i := range packet.Inputs {
+			packet.Inputs[i].RequiredHeightLockTime = test.heights[i]
+			packet.Inputs[i].RequiredTimeLockTime = test.times[i]
+		}
+
+		lockTime, err := packet.DetermineLockTime()
+		require.ErrorIs(t, err, test.err, test.name)
+		require.Equal(t, test.lockTime, lockTime, test.name)
+	}
+}
+
+// TestReadInvalidPsbtV2 ensures serializations violating the rules of BIP 370
+// are rejected.
+func TestReadInvalidPsbtV2(t *testing.T) {
+	var u32 [4]byte
+	uint32Bytes := func(v uint32) []byte {
+		binary.LittleEndian.PutUint32(u32[:], v)
+		return append([]byte{}, u32[:]...)
+	}
+
+	type kv struct {
+		key   byte
+		value []byte
+	}
+	global := func(txVersion, inputs, outputs []byte) []kv {
+		kvs := []kv{{byte(VersionType), uint32Bytes(Version2)}}
+		if txVersion != nil {
+			kvs = append(kvs, kv{byte(TxVersionType), txVersion})
+		}
+		if inputs != nil {
+			kvs = append(kvs, kv{byte(InputCountType), inputs})
+		}
+		if outputs != nil {
+			kvs = append(kvs, kv{byte(OutputCountType), outputs})
+		}
+		return kvs
+	}
+	validInput := []kv{
+		{byte(PreviousTxidType), make([]byte, 32)},
+		{byte(PreviousOutputIndexType), uint32Bytes(0)},
+	}
+	validOutput := []kv{
+		{byte(AmountType), make([]byte, 8)},
+		{byte(ScriptType), []byte{txscript.OP_TRUE}},
+	}
+	serialize := func(sections ...[]kv) []byte {
+		var b bytes.Buffer
+		b.Write(psbtMagic[:])
+		for _, section := range sections {
+			for _, pair := range section {
+				err := serializeKVPairWithType(
+					&b, pair.key, nil, pair.value,
+				)
+				require.NoError(t, err)
+			}
+			b.WriteByte(0x00)
+		}
+		return b.Bytes()
+	}
+
+	// Make sure the base case is valid.
+	valid := serialize(
+		global(uint32Bytes(2), []byte{1}, []byte{1}), validInput,
+		validOutput,
+	)
+	_, err := NewFromRawBytes(bytes.NewReader(valid), false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		serialized []byte
+	}{{
+		name: "unsupported version",
+		serialized: serialize(
+			[]kv{{byte(VersionType), uint32Bytes(1)}},
+		),
+	}, {
+		name: "missing tx version",
+		serialized: serialize(
+			global(nil, []byte{1}, []byte{1}), validInput,
+			validOutput,
+		),
+	}, {
+		name: "tx version below 2",
+		serialized: serialize(
+			global(uint32Bytes(1), []byte{1}, []byte{1}),
+			validInput, validOutput,
+		),
+	}, {
+		name: "missing input count",
+		serialized: serialize(
+			global(uint32Bytes(2), nil, []byte{1}), validInput,
+			validOutput,
+		),
+	}, {
+		name: "missing previous output index",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{1}, []byte{1}),
+			validInput[:1], validOutput,
+		),
+	}, {
+		name: "missing output script",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{1}, []byte{1}),
+			validInput, validOutput[:1],
+		),
+	}, {
+		name: "height lock time above threshold",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{1}, []byte{1}),
+			append([]kv{{
+				byte(RequiredHeightLockTimeType),
+				uint32Bytes(txscript.LockTimeThreshold),
+			}}, validInput...), validOutput,
+		),
+	}, {
+		name: "time lock time below threshold",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{1}, []byte{1}),
+			append([]kv{{
+				byte(RequiredTimeLockTimeType),
+				uint32Bytes(txscript.LockTimeThreshold - 1),
+			}}, validInput...), validOutput,
+		),
+	}, {
+		name: "duplicate previous txid",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{1}, []byte{1}),
+			append(validInput, validInput[0]), validOutput,
+		),
+	}, {
+		name: "more inputs than present",
+		serialized: serialize(
+			global(uint32Bytes(2), []byte{2}, []byte{1}),
+			validInput, validOutput,
+		),
+	}, {
+		name: "version 0 with tx version",
+		serialized: serialize(
+			[]kv{{byte(TxVersionType), uint32Bytes(2)}},
+		),
+	}}
+
+	for _, test := range tests {
+		_, err := NewFromRawBytes(
+			bytes.NewReader(test.serialized), false,
+		)
+		require.Error(t, err, test.name)
+	}
+}
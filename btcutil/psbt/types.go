@@ -30,6 +30,45 @@ const (
 	// extended public key.
 	XpubType GlobalType = 1
 
+	// TxVersionType is an empty key ({0x02}) which is only used in version
+	// 2 PSBTs, where it is required.
+	//
+	// The value is the 32-bit little endian signed integer representing
+	// the version number of the transaction being created. It must be at
+	// least 2.
+	TxVersionType GlobalType = 2
+
+	// FallbackLockTimeType is an empty key ({0x03}) which is only used in
+	// version 2 PSBTs.
+	//
+	// The value is the 32-bit little endian unsigned integer representing
+	// the transaction locktime to use if no inputs specify a required
+	// locktime. If omitted, it is assumed to be zero.
+	FallbackLockTimeType GlobalType = 3
+
+	// InputCountType is an empty key ({0x04}) which is only used in
+	// version 2 PSBTs, where it is required.
+	//
+	// The value is a compact size unsigned integer representing the number
+	// of inputs in this PSBT.
+	InputCountType GlobalType = 4
+
+	// OutputCountType is an empty key ({0x05}) which is only used in
+	// version 2 PSBTs, where it is required.
+	//
+	// The value is a compact size unsigned integer representing the number
+	// of outputs in this PSBT.
+	OutputCountType GlobalType = 5
+
+	// TxModifiableType is an empty key ({0x06}) which is only used in
+	// version 2 PSBTs.
+	//
+	// The value is an 8-bit little endian unsigned integer used as a
+	// bitfield for flags denoting whether inputs and outputs can be added
+	// and whether a signature with SIGHASH_SINGLE was added. See the
+	// InputsModifiable, OutputsModifiable and HasSigHashSingle flags.
+	TxModifiableType GlobalType = 6
+
 	// VersionType houses the global version number of this PSBT. There is
 	// no key (only contains the byte type), then the value if omitted, is
 	// assumed to be zero.
@@ -114,6 +153,45 @@ const (
 	// scripts necessary for the input to pass validation.
 	FinalScriptWitnessType InputType = 8
 
+	// PreviousTxidType is an empty key ({0x0e}) which is only used in
+	// version 2 PSBTs, where it is required.
+	//
+	// The value is the 32 byte txid of the previous transaction whose
+	// output at PreviousOutputIndexType is being spent.
+	PreviousTxidType InputType = 0x0e
+
+	// PreviousOutputIndexType is an empty key ({0x0f}) which is only used
+	// in version 2 PSBTs, where it is required.
+	//
+	// The value is the 32-bit little endian integer representing the index
+	// of the output being spent in the transaction with the txid of
+	// PreviousTxidType.
+	PreviousOutputIndexType InputType = 0x0f
+
+	// SequenceType is an empty key ({0x10}) which is only used in version
+	// 2 PSBTs.
+	//
+	// The value is the 32-bit little endian unsigned integer for the
+	// sequence number of this input. If omitted, the sequence number is
+	// assumed to be the final sequence number (0xffffffff).
+	SequenceType InputType = 0x10
+
+	// RequiredTimeLockTimeType is an empty key ({0x11}) which is only used
+	// in version 2 PSBTs.
+	//
+	// The value is the 32-bit little endian unsigned integer greater than
+	// or equal to 500000000 representing the minimum Unix timestamp that
+	// this input requires to be set as the transaction's lock time.
+	RequiredTimeLockTimeType InputType = 0x11
+
+	// RequiredHeightLockTimeType is an empty key ({0x12}) which is only
+	// used in version 2 PSBTs.
+	//
+	// The value is the 32-bit little endian unsigned integer greater than 0
+	// and less than 500000000 representing the minimum block height that
+	// this input requires to be set as the transaction's lock time.
+	RequiredHeightLockTimeType InputType = 0x12
+
 	// TaprootKeySpendSignatureType is an empty key ({0x13}). The value is
 	// a 64-byte Schnorr signature or a 65-byte Schnorr signature with the
 	// one byte sighash type appended to it.
@@ -151,6 +229,31 @@ const (
 	// 32-byte hash denoting the root hash of a merkle tree of scripts.
 	TaprootMerkleRootType InputType = 0x18
 
+	// MuSig2ParticipantsInputType is a type that carries the MuSig2
+	// aggregate public key along with the key
+	// ({0x1a}|{aggregate pubkey}).
+	//
+	// The value is the list of 33-byte compressed public keys of the
+	// participants which were aggregated, in the order they were
+	// aggregated in.
+	MuSig2ParticipantsInputType InputType = 0x1a
+
+	// MuSig2PubNonceType is a type that carries the public key of the
+	// participant providing the nonce, the aggregate public key and the
+	// optional leaf hash of a script path spend along with the key
+	// ({0x1b}|{pubkey}|{aggregate pubkey}|{leafhash}).
+	//
+	// The value is the 66-byte public nonce of the participant.
+	MuSig2PubNonceType InputType = 0x1b
+
+	// MuSig2PartialSigType is a type that carries the public key of the
+	// participant providing the signature, the aggregate public key and
+	// the optional leaf hash of a script path spend along with the key
+	// ({0x1c}|{pubkey}|{aggregate pubkey}|{leafhash}).
+	//
+	// The value is the 32-byte partial signature of the participant.
+	MuSig2PartialSigType InputType = 0x1c
+
 	// ProprietaryInputType is a custom type for use by devs.
 	//
 	// The key ({0xFC}|<prefix>|{subtype}|{key data}), is a Variable length
@@ -184,6 +287,20 @@ const (
 	// Public keys are those needed to spend this output.
 	Bip32DerivationOutputType OutputType = 2
 
+	// AmountType is an empty key ({0x03}) which is only used in version 2
+	// PSBTs, where it is required.
+	//
+	// The value is the 64-bit signed little endian integer representing
+	// the output's amount in satoshis.
+	AmountType OutputType = 3
+
+	// ScriptType is an empty key ({0x04}) which is only used in version 2
+	// PSBTs, where it is required.
+	//
+	// The value is the script for this output, also known as the
+	// scriptPubKey.
+	ScriptType OutputType = 4
+
 	// TaprootInternalKeyOutputType is an empty key ({0x05}). The value is
 	// an x-only pubkey denoting the internal public key used for
 	// constructing a taproot key.
@@ -200,4 +317,13 @@ const (
 	// followed by said number of 32-byte leaf hashes. The rest of the value
 	// is then identical to the Bip32DerivationInputType value.
 	TaprootBip32DerivationOutputType OutputType = 7
+
+	// MuSig2ParticipantsOutputType is a type that carries the MuSig2
+	// aggregate public key along with the key
+	// ({0x08}|{aggregate pubkey}).
+	//
+	// The value is the list of 33-byte compressed public keys of the
+	// participants which were aggregated, in the order they were
+	// aggregated in.
+	MuSig2ParticipantsOutputType OutputType = 8
 )
@@ -14,6 +14,8 @@ import (
 	"bytes"
 	"crypto/sha256"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -375,3 +377,195 @@ func (u *Updater) AddOutWitnessScript(witnessScript []byte,
 
 	return nil
 }
+
+// AddInMuSig2Participants takes the public keys of the participants of a
+// MuSig2 session, in the order they are aggregated in, and adds them to the
+// input at index inIndex along with the aggregate public key, which must be
+// their aggregate before any tweaks are applied.
+func (u *Updater) AddInMuSig2Participants(aggKey *btcec.PublicKey,
+	keys []*btcec.PublicKey, inIndex int) error {
+
+	participants, err := newMuSig2Participants(aggKey, keys)
+	if err != nil {
+		return err
+	}
+
+	// Don't allow duplicate keys.
+	for _, x := range u.Upsbt.Inputs[inIndex].MuSig2Participants {
+		if x.AggKey.IsEqual(aggKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Inputs[inIndex].MuSig2Participants = append(
+		u.Upsbt.Inputs[inIndex].MuSig2Participants, participants,
+	)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddOutMuSig2Participants takes the public keys of the participants of a
+// MuSig2 session, in the order they are aggregated in, and adds them to the
+// output at index outIndex along with the aggregate public key, which must be
+// their aggregate before any tweaks are applied.
+func (u *Updater) AddOutMuSig2Participants(aggKey *btcec.PublicKey,
+	keys []*btcec.PublicKey, outIndex int) error {
+
+	participants, err := newMuSig2Participants(aggKey, keys)
+	if err != nil {
+		return err
+	}
+
+	// Don't allow duplicate keys.
+	for _, x := range u.Upsbt.Outputs[outIndex].MuSig2Participants {
+		if x.AggKey.IsEqual(aggKey) {
+			return ErrDuplicateKey
+		}
+	}
+
+	u.Upsbt.Outputs[outIndex].MuSig2Participants = append(
+		u.Upsbt.Outputs[outIndex].MuSig2Participants, participants,
+	)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddInMuSig2PubNonce adds the public nonce of the participant with the
+// passed public key of the MuSig2 session with the passed aggregate key to the
+// input at index inIndex.  The leaf hash is only set for script path spends
+// and must be nil for key path spends.  The participants of the session must
+// have been added to the input before.
+func (u *Updater) AddInMuSig2PubNonce(pubKey, aggKey *btcec.PublicKey,
+	leafHash []byte, pubNonce [musig2.PubNonceSize]byte,
+	inIndex int) error {
+
+	pInput := &u.Upsbt.Inputs[inIndex]
+	err := checkMuSig2Participant(pInput, pubKey, aggKey, leafHash)
+	if err != nil {
+		return err
+	}
+
+	nonce := MuSig2PubNonce{
+		PubKey:   pubKey,
+		AggKey:   aggKey,
+		LeafHash: leafHash,
+		PubNonce: pubNonce,
+	}
+
+	// Don't allow duplicate keys.
+	for _, x := range pInput.MuSig2PubNonces {
+		if x.EqualKey(&nonce) {
+			return ErrDuplicateKey
+		}
+	}
+
+	pInput.MuSig2PubNonces = append(pInput.MuSig2PubNonces, &nonce)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddInMuSig2PartialSig adds the partial signature of the participant with the
+// passed public key of the MuSig2 session with the passed aggregate key to the
+// input at index inIndex.  The leaf hash is only set for script path spends
+// and must be nil for key path spends.  The participants of the session must
+// have been added to the input before.
+//
+// NOTE: The partial signature is not verified, an invalid one causes the
+// aggregation of the final signature by the Finalizer to fail.
+func (u *Updater) AddInMuSig2PartialSig(pubKey, aggKey *btcec.PublicKey,
+	leafHash []byte, partialSig *musig2.PartialSignature,
+	inIndex int) error {
+
+	pInput := &u.Upsbt.Inputs[inIndex]
+	err := checkMuSig2Participant(pInput, pubKey, aggKey, leafHash)
+	if err != nil {
+		return err
+	}
+	if partialSig == nil || partialSig.S == nil {
+		return ErrInvalidPsbtFormat
+	}
+
+	sig := MuSig2PartialSig{
+		PubKey:     pubKey,
+		AggKey:     aggKey,
+		LeafHash:   leafHash,
+		PartialSig: *partialSig,
+	}
+
+	// Don't allow duplicate keys.
+	for _, x := range pInput.MuSig2PartialSigs {
+		if x.EqualKey(&sig) {
+			return ErrDuplicateKey
+		}
+	}
+
+	pInput.MuSig2PartialSigs = append(pInput.MuSig2PartialSigs, &sig)
+
+	if err := u.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newMuSig2Participants returns the participants info for the passed keys
+// after checking that they aggregate to the passed aggregate key.
+func newMuSig2Participants(aggKey *btcec.PublicKey,
+	keys []*btcec.PublicKey) (*MuSig2Participants, error) {
+
+	if aggKey == nil || len(keys) == 0 {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	key, _, _, err := musig2.AggregateKeys(keys, false)
+	if err != nil {
+		return nil, err
+	}
+	if !key.PreTweakedKey.IsEqual(aggKey) {
+		return nil, ErrInvalidPsbtFormat
+	}
+
+	return &MuSig2Participants{
+		AggKey: aggKey,
+		Keys:   keys,
+	}, nil
+}
+
+// checkMuSig2Participant returns an error unless the passed public key is one
+// of the participants of the MuSig2 session with the passed aggregate key of
+// the input.
+func checkMuSig2Participant(pInput *PInput, pubKey, aggKey *btcec.PublicKey,
+	leafHash []byte) error {
+
+	if pubKey == nil || aggKey == nil {
+		return ErrInvalidPsbtFormat
+	}
+	if leafHash != nil && len(leafHash) != 32 {
+		return ErrInvalidKeydata
+	}
+
+	for _, participants := range pInput.MuSig2Participants {
+		if !participants.AggKey.IsEqual(aggKey) {
+			continue
+		}
+		for _, key := range participants.Keys {
+			if key.IsEqual(pubKey) {
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidPsbtFormat
+}
@@ -282,6 +282,26 @@ func readTxOut(txout []byte) (*wire.TxOut, error) {
 	return wire.NewTxOut(int64(valueSer), scriptPubKey), nil
 }
 
+// readCompactSize decodes the passed value of a key-value pair as a compact
+// size unsigned integer, ensuring there is no trailing data.
+func readCompactSize(value []byte) (uint64, error) {
+	r := bytes.NewReader(value)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || r.Len() != 0 {
+		return 0, ErrInvalidKeydata
+	}
+
+	return count, nil
+}
+
+// compactSizeBytes returns the passed integer encoded as a compact size
+// unsigned integer.
+func compactSizeBytes(count uint64) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarInt(&buf, 0, count)
+	return buf.Bytes()
+}
+
 // SumUtxoInputValues tries to extract the sum of all inputs specified in the
 // UTXO fields of the PSBT. An error is returned if an input is specified that
 // does not contain any UTXO information.
@@ -325,6 +345,42 @@ func SumUtxoInputValues(packet *Packet) (int64, error) {
 	return inputSum, nil
 }
 
+// PrevOutputFetcher returns a txscript.MultiPrevOutFetcher containing the
+// outputs spent by all inputs of the PSBT as specified in their UTXO fields,
+// as required to compute taproot signature hashes. An error is returned if an
+// input is specified that does not contain any UTXO information.
+func PrevOutputFetcher(packet *Packet) (*txscript.MultiPrevOutFetcher, error) {
+	if len(packet.UnsignedTx.TxIn) != len(packet.Inputs) {
+		return nil, fmt.Errorf("TX input length doesn't match PSBT " +
+			"input length")
+	}
+
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for idx, in := range packet.Inputs {
+		txIn := packet.UnsignedTx.TxIn[idx]
+
+		switch {
+		case in.WitnessUtxo != nil:
+			fetcher.AddPrevOut(txIn.PreviousOutPoint, in.WitnessUtxo)
+
+		case in.NonWitnessUtxo != nil:
+			utxOuts := in.NonWitnessUtxo.TxOut
+			opIdx := txIn.PreviousOutPoint.Index
+			if opIdx >= uint32(len(utxOuts)) {
+				return nil, fmt.Errorf("input %d has malformed "+
+					"TxOut field", idx)
+			}
+
+			fetcher.AddPrevOut(txIn.PreviousOutPoint, utxOuts[opIdx])
+
+		default:
+			return nil, fmt.Errorf("input %d has no UTXO "+
+				"information", idx)
+		}
+	}
+	return fetcher, nil
+}
+
 // TxOutsEqual returns true if two transaction outputs are equal.
 func TxOutsEqual(out1, out2 *wire.TxOut) bool {
 	if out1 == nil || out2 == nil {
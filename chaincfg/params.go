@@ -77,6 +77,25 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// AssumeUtxo identifies a utxo snapshot that is known to be valid, which allows
+// a node to be bootstrapped by loading the snapshot instead of validating the
+// chain up to the block it is based on first.  The chain up to the block is
+// still validated in the background afterwards.
+type AssumeUtxo struct {
+	// Height and BlockHash identify the block the snapshot is based on.
+	Height    int32
+	BlockHash *chainhash.Hash
+
+	// HashSerialized is the hash committing to the utxo set as of the
+	// block.  It is compatible with the hash_serialized_2 hash of
+	// bitcoind.
+	HashSerialized *chainhash.Hash
+
+	// ChainTxCount is the total number of transactions in the chain up to
+	// and including the block.
+	ChainTxCount uint64
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -230,6 +249,13 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// AssumeUtxo lists the utxo snapshots which may be loaded ordered from
+	// oldest to newest.  Entries are created from the output of the
+	// dumptxoutset RPC of a node which has fully validated the chain up
+	// to the snapshot block.  Utxo snapshots can't be loaded on networks
+	// without any entries.
+	AssumeUtxo []AssumeUtxo
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -0,0 +1,230 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	flags "github.com/jessevdk/go-flags"
+)
+
+type config struct {
+	Tx          string   `short:"t" long:"tx" description:"Hex encoded raw transaction spending the input to debug" required:"true"`
+	Input       int      `short:"i" long:"input" description:"Index of the input to debug"`
+	PrevOuts    []string `short:"p" long:"prevout" description:"Output spent by the input as <amount in satoshi>:<hex encoded pkScript>; specify it once per input in order for taproot spends of transactions with several inputs" required:"true"`
+	NoStandard  bool     `long:"nostandard" description:"Only apply the consensus script verification flags instead of the standard ones"`
+	JSON        bool     `short:"j" long:"json" description:"Write the trace of the execution as JSON"`
+	Interactive bool     `short:"I" long:"interactive" description:"Wait for input before executing each step"`
+}
+
+func main() {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+
+	vm, err := newEngine(&cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.JSON {
+		succeeded, err := writeTrace(vm, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot export trace: %v\n", err)
+			os.Exit(1)
+		}
+		if !succeeded {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := debug(vm, cfg.Interactive, os.Stdin, os.Stdout); err != nil {
+		fmt.Printf("script failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("script succeeded")
+}
+
+// newEngine returns the script engine executing the input of the transaction
+// selected by the passed config.
+func newEngine(cfg *config) (*txscript.Engine, error) {
+	rawTx, err := hex.DecodeString(cfg.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode transaction: %v", err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return nil, fmt.Errorf("cannot parse transaction: %v", err)
+	}
+	if cfg.Input < 0 || cfg.Input >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input index %d out of range, the "+
+			"transaction has %d inputs", cfg.Input, len(tx.TxIn))
+	}
+
+	prevOuts := make([]*wire.TxOut, 0, len(cfg.PrevOuts))
+	for _, prevOut := range cfg.PrevOuts {
+		txOut, err := parsePrevOut(prevOut)
+		if err != nil {
+			return nil, err
+		}
+		prevOuts = append(prevOuts, txOut)
+	}
+
+	// The signature hash of taproot spends commits to the outputs spent
+	// by all inputs, while other spends only commit to their own one.
+	var (
+		prevOut        *wire.TxOut
+		prevOutFetcher txscript.PrevOutputFetcher
+	)
+	switch len(prevOuts) {
+	case len(tx.TxIn):
+		fetcher := txscript.NewMultiPrevOutFetcher(nil)
+		for i, txIn := range tx.TxIn {
+			fetcher.AddPrevOut(txIn.PreviousOutPoint, prevOuts[i])
+		}
+		prevOut, prevOutFetcher = prevOuts[cfg.Input], fetcher
+
+	case 1:
+		prevOut = prevOuts[0]
+		if txscript.IsPayToTaproot(prevOut.PkScript) {
+			return nil, errors.New("the outputs spent by all " +
+				"inputs are required for taproot spends")
+		}
+		prevOutFetcher = txscript.NewCannedPrevOutputFetcher(
+			prevOut.PkScript, prevOut.Value,
+		)
+
+	default:
+		return nil, fmt.Errorf("%d prevouts specified, either the one "+
+			"spent by the input or one per input is required",
+			len(prevOuts))
+	}
+
+	scriptFlags := txscript.StandardVerifyFlags
+	if cfg.NoStandard {
+		scriptFlags = txscript.ScriptBip16 |
+			txscript.ScriptVerifyDERSignatures |
+			txscript.ScriptVerifyCheckLockTimeVerify |
+			txscript.ScriptVerifyCheckSequenceVerify |
+			txscript.ScriptVerifyWitness |
+			txscript.ScriptVerifyTaproot
+	}
+
+	return txscript.NewEngine(
+		prevOut.PkScript, &tx, cfg.Input, scriptFlags, nil,
+		txscript.NewTxSigHashes(&tx, prevOutFetcher), prevOut.Value,
+		prevOutFetcher,
+	)
+}
+
+// parsePrevOut parses an output specified as <amount>:<pkScript>.
+func parsePrevOut(prevOut string) (*wire.TxOut, error) {
+	parts := strings.Split(prevOut, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid prevout %q, expected "+
+			"<amount>:<pkScript>", prevOut)
+	}
+	amount, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prevout amount %q: %v",
+			parts[0], err)
+	}
+	pkScript, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid prevout pkScript %q: %v",
+			parts[1], err)
+	}
+
+	return wire.NewTxOut(amount, pkScript), nil
+}
+
+// writeTrace executes the passed engine and writes the trace of the execution
+// as JSON to the passed writer.  It returns whether or not the script
+// succeeded.
+func writeTrace(vm *txscript.Engine, w io.Writer) (bool, error) {
+	trace := txscript.TraceExecution(vm)
+	exported, err := trace.ExportJSON()
+	if err != nil {
+		return false, err
+	}
+	if _, err := fmt.Fprintln(w, string(exported)); err != nil {
+		return false, err
+	}
+	return trace.Error == "", nil
+}
+
+// debug replays the execution of the passed engine step by step, writing each
+// step to the passed writer.  When interactive, a command is read from the
+// passed reader before each step.
+func debug(vm *txscript.Engine, interactive bool, r io.Reader,
+	w io.Writer) error {
+
+	vm.SetStepHook(func(step *txscript.TraceStep) {
+		writeStep(w, step)
+	})
+
+	input := bufio.NewScanner(r)
+	for done := false; !done; {
+		if interactive {
+			fmt.Fprint(w, "[enter] step, (c)ontinue, (q)uit: ")
+			if !input.Scan() {
+				return errors.New("aborted")
+			}
+
+			switch strings.TrimSpace(input.Text()) {
+			case "c":
+				interactive = false
+			case "q":
+				return errors.New("aborted")
+			}
+		}
+
+		var err error
+		done, err = vm.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return vm.CheckErrorCondition(true)
+}
+
+// writeStep writes the passed step in a human-readable form.
+func writeStep(w io.Writer, step *txscript.TraceStep) {
+	var skipped string
+	if !step.Executed {
+		skipped = " (skipped)"
+	}
+	fmt.Fprintf(w, "%02x:%04x: %s%s\n", step.ScriptIndex,
+		step.OpcodeIndex, step.Opcode, skipped)
+	fmt.Fprintf(w, "  stack:     %s\n", strings.Join(step.StackAfter, " "))
+	fmt.Fprintf(w, "  altstack:  %s\n",
+		strings.Join(step.AltStackAfter, " "))
+	fmt.Fprintf(w, "  condstack: %s\n", strings.Join(step.CondStack, " "))
+	if step.SigOpsBudget != nil {
+		fmt.Fprintf(w, "  sigops budget: %d\n", *step.SigOpsBudget)
+	}
+	if step.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", step.Error)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// tx170Hex is the first transaction sending bitcoin from one person to
+	// another, included in block 170.  It spends the pay-to-pubkey
+	// coinbase output of block 9.
+	tx170Hex = "0100000001c997a5e56e104102fa209c6a852dd90660a20b2d9c352" +
+		"423edce25857fcd3704000000004847304402204e45e16932b8af514961a1d" +
+		"3a1a25fdf3f4f7732e9d624c6c61548ab5fb8cd410220181522ec8eca07de48" +
+		"60a4acdd12909d831cc56cbbac4622082221a8768d1d0901ffffffff0200ca9" +
+		"a3b00000000434104ae1a62fe09c5f51b13905f07f06b99a2f7159b2225f374" +
+		"cd378d71302fa28414e7aab37397f554a7df5f142c21c1b7303b8a0626f1bad" +
+		"ed5c72a704f7e6cd84cac00286bee0000000043410411db93e1dcdb8a016b49" +
+		"840f8c53bc1eb68a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744" +
+		"464f82e160bfa9b8b64f9d4c03f999b8643f656b412a3ac00000000"
+
+	// block9CoinbaseOut is the output spent by tx170Hex.
+	block9CoinbaseOut = "5000000000:410411db93e1dcdb8a016b49840f8c53bc1eb68" +
+		"a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e160bfa9" +
+		"b8b64f9d4c03f999b8643f656b412a3ac"
+)
+
+// TestWriteTrace ensures the JSON trace of a known spend matches the expected
+// trace and that a spend with the wrong prevout fails.
+func TestWriteTrace(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config{
+		Tx:       tx170Hex,
+		PrevOuts: []string{block9CoinbaseOut},
+	}
+	vm, err := newEngine(cfg)
+	if err != nil {
+		t.Fatalf("newEngine: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	succeeded, err := writeTrace(vm, &buf)
+	if err != nil {
+		t.Fatalf("writeTrace: unexpected error: %v", err)
+	}
+	if !succeeded {
+		t.Fatalf("known spend failed:\n%s", buf.String())
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "tx170.json"))
+	if err != nil {
+		t.Fatalf("unable to read expected trace: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("unexpected trace:\n%s\nwant:\n%s", buf.String(),
+			want)
+	}
+
+	// The signature doesn't verify against the public key of another
+	// pay-to-pubkey output.
+	cfg.PrevOuts = []string{"1000000000:4104ae1a62fe09c5f51b13905f07f06b99" +
+		"a2f7159b2225f374cd378d71302fa28414e7aab37397f554a7df5f142c21c1" +
+		"b7303b8a0626f1baded5c72a704f7e6cd84cac"}
+	vm, err = newEngine(cfg)
+	if err != nil {
+		t.Fatalf("newEngine: unexpected error: %v", err)
+	}
+	buf.Reset()
+	succeeded, err = writeTrace(vm, &buf)
+	if err != nil {
+		t.Fatalf("writeTrace: unexpected error: %v", err)
+	}
+	if succeeded {
+		t.Fatalf("spend of the wrong prevout succeeded")
+	}
+	if !strings.Contains(buf.String(), `"error":`) {
+		t.Fatalf("trace of failed spend has no error:\n%s",
+			buf.String())
+	}
+}
+
+// TestDebug ensures the step by step execution of a known spend succeeds and
+// can be aborted when interactive.
+func TestDebug(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config{
+		Tx:       tx170Hex,
+		PrevOuts: []string{block9CoinbaseOut},
+	}
+	vm, err := newEngine(cfg)
+	if err != nil {
+		t.Fatalf("newEngine: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := debug(vm, true, strings.NewReader("\nc\n"), &buf); err != nil {
+		t.Fatalf("debug: unexpected error: %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "01:0001: OP_CHECKSIG\n"+
+		"  stack:     01\n") {
+
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+
+	vm, err = newEngine(cfg)
+	if err != nil {
+		t.Fatalf("newEngine: unexpected error: %v", err)
+	}
+	err = debug(vm, true, strings.NewReader("\nq\n"), &buf)
+	if err == nil || err.Error() != "aborted" {
+		t.Fatalf("debug: unexpected error: %v", err)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
@@ -42,6 +43,7 @@ const (
 	defaultLogDirname            = "logs"
 	defaultLogFilename           = "btcd.log"
 	defaultMaxPeers              = 125
+	defaultMaxCmpctHBPeers       = 3
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
 	defaultConnectTimeout        = time.Second * 30
@@ -66,6 +68,8 @@ const (
 	sampleConfigFilename         = "sample-btcd.conf"
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
+	pruneMinSize                 = 1536
+	defaultUtxoCacheMaxSizeMiB   = 250
 )
 
 var (
@@ -95,6 +99,7 @@ func minUint32(a, b uint32) uint32 {
 //
 // See loadConfig for details on the configuration load process.
 type config struct {
+	AddAssumeUtxos       []string      `long:"addassumeutxo" description:"Add a utxo snapshot which may be loaded with --loadsnapshot on the regression and simulation test networks.  Format: '<height>:<base hash>:<txoutset hash>:<nchaintx>' as returned by the dumptxoutset RPC"`
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	AddPeers             []string      `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
@@ -120,10 +125,18 @@ type config struct {
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
+	LimitAncestorCount   int64         `long:"limitancestorcount" description:"Do not accept transactions into the mempool if the number of their unconfirmed ancestors, including themselves, exceeds this value"`
+	LimitAncestorSize    int64         `long:"limitancestorsize" description:"Do not accept transactions into the mempool if their total virtual size along with all of their unconfirmed ancestors exceeds this value in kilobytes"`
+	LimitDescendantCount int64         `long:"limitdescendantcount" description:"Do not accept transactions into the mempool if any of their unconfirmed ancestors would have more descendants in the mempool, including themselves, than this value"`
+	LimitDescendantSize  int64         `long:"limitdescendantsize" description:"Do not accept transactions into the mempool if any of their unconfirmed ancestors would exceed this total virtual size in kilobytes along with all of their descendants in the mempool"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
+	LoadSnapshot         string        `long:"loadsnapshot" description:"Bootstrap the chain from the utxo snapshot in the given file created by the dumptxoutset RPC and validate it in the background -- NOTE: Only allowed with an empty data directory and the snapshot must be listed in the chain parameters or added with --addassumeutxo"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxMempool           int64         `long:"maxmempool" description:"Keep the transaction memory pool below the given size in megabytes by evicting the transactions paying the lowest fees"`
+	MaxCmpctHBPeers      int           `long:"maxcmpcthbpeers" description:"Max number of peers that are requested to relay new blocks as BIP0152 compact blocks without announcing them first (high-bandwidth mode) -- 0 to disable"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	MempoolExpiry        time.Duration `long:"mempoolexpiry" description:"Do not keep transactions in the memory pool longer than the given duration"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
@@ -133,6 +146,7 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
+	NoPersistMempool     bool          `long:"nopersistmempool" description:"Do not save the mempool to mempool.dat in the data directory on shutdown and load it on startup"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	NoWinService         bool          `long:"nowinservice" description:"Do not start as a background service on Windows -- NOTE: This flag only works on the command line, not in the config file"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
@@ -142,6 +156,7 @@ type config struct {
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
+	Prune                uint64        `long:"prune" description:"Prune already validated blocks from the database once the stored block data exceeds the target size in MiB -- The minimum value is 1536 and 0 disables pruning"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass            string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
@@ -161,6 +176,8 @@ type config struct {
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	UtxoCacheMaxSizeMiB  uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the UTXO cache -- Modifications to the UTXO set are only written to the database once the cache exceeds this size, periodically, and on shutdown"`
+	UtxoSetStats         bool          `long:"utxosetstats" description:"Maintain the statistics and MuHash of the UTXO set as blocks are connected so the gettxoutsetinfo RPC can return them without scanning the UTXO set"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
 	SigNet               bool          `long:"signet" description:"Use the signet test network"`
 	SigNetChallenge      string        `long:"signetchallenge" description:"Connect to a custom signet network defined by this challenge instead of using the global default signet test network -- Can be specified multiple times"`
@@ -173,9 +190,16 @@ type config struct {
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ZMQPubHashBlock      string        `long:"zmqpubhashblock" description:"Publish the hash of each block connected to the main chain to the given ZMQ address (eg. tcp://127.0.0.1:28332)"`
+	ZMQPubHashTx         string        `long:"zmqpubhashtx" description:"Publish the hash of each new mempool transaction and transaction in connected or disconnected blocks to the given ZMQ address"`
+	ZMQPubRawBlock       string        `long:"zmqpubrawblock" description:"Publish each serialized block connected to the main chain to the given ZMQ address"`
+	ZMQPubRawTx          string        `long:"zmqpubrawtx" description:"Publish each serialized new mempool transaction and transaction in connected or disconnected blocks to the given ZMQ address"`
+	ZMQPubSequence       string        `long:"zmqpubsequence" description:"Publish block connection and disconnection as well as mempool acceptance events to the given ZMQ address"`
+	ZMQPubHWM            int           `long:"zmqpubhwm" description:"Max number of outbound messages queued for each ZMQ subscriber before further messages are dropped"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
+	addAssumeUtxos       []chaincfg.AssumeUtxo
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          []btcutil.Address
 	minRelayTxFee        btcutil.Amount
@@ -379,6 +403,67 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// newAssumeUtxoFromStr parses utxo snapshot entries in the
+// '<height>:<base hash>:<txoutset hash>:<nchaintx>' format.
+func newAssumeUtxoFromStr(assumeUtxo string) (chaincfg.AssumeUtxo, error) {
+	parts := strings.Split(assumeUtxo, ":")
+	if len(parts) != 4 {
+		return chaincfg.AssumeUtxo{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q -- use the syntax "+
+			"<height>:<base hash>:<txoutset hash>:<nchaintx>",
+			assumeUtxo)
+	}
+
+	height, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || height <= 0 {
+		return chaincfg.AssumeUtxo{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed height", assumeUtxo)
+	}
+	blockHash, err := chainhash.NewHashFromStr(parts[1])
+	if err != nil || len(parts[1]) == 0 {
+		return chaincfg.AssumeUtxo{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed base hash",
+			assumeUtxo)
+	}
+	hashSerialized, err := chainhash.NewHashFromStr(parts[2])
+	if err != nil || len(parts[2]) == 0 {
+		return chaincfg.AssumeUtxo{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed txoutset hash",
+			assumeUtxo)
+	}
+	chainTxCount, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil || chainTxCount == 0 {
+		return chaincfg.AssumeUtxo{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed nchaintx",
+			assumeUtxo)
+	}
+
+	return chaincfg.AssumeUtxo{
+		Height:         int32(height),
+		BlockHash:      blockHash,
+		HashSerialized: hashSerialized,
+		ChainTxCount:   chainTxCount,
+	}, nil
+}
+
+// parseAssumeUtxos checks the utxo snapshot strings for valid syntax
+// ('<height>:<base hash>:<txoutset hash>:<nchaintx>') and parses them to
+// chaincfg.AssumeUtxo instances.
+func parseAssumeUtxos(assumeUtxoStrings []string) ([]chaincfg.AssumeUtxo, error) {
+	if len(assumeUtxoStrings) == 0 {
+		return nil, nil
+	}
+	assumeUtxos := make([]chaincfg.AssumeUtxo, len(assumeUtxoStrings))
+	for i, assumeUtxoString := range assumeUtxoStrings {
+		assumeUtxo, err := newAssumeUtxoFromStr(assumeUtxoString)
+		if err != nil {
+			return nil, err
+		}
+		assumeUtxos[i] = assumeUtxo
+	}
+	return assumeUtxos, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -416,6 +501,8 @@ func loadConfig() (*config, []string, error) {
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
+		MaxCmpctHBPeers:      defaultMaxCmpctHBPeers,
+		ZMQPubHWM:            zmq.DefaultHighWaterMark,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
@@ -435,7 +522,14 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxMempool:           mempool.DefaultMaxPoolSize / 1000000,
+		MempoolExpiry:        mempool.DefaultExpiry,
+		LimitAncestorCount:   mempool.DefaultMaxAncestorCount,
+		LimitAncestorSize:    mempool.DefaultMaxAncestorSize / 1000,
+		LimitDescendantCount: mempool.DefaultMaxDescendantCount,
+		LimitDescendantSize:  mempool.DefaultMaxDescendantSize / 1000,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		UtxoCacheMaxSizeMiB:  defaultUtxoCacheMaxSizeMiB,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
@@ -848,6 +942,26 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The ZMQ high water mark must allow at least one queued message.
+	if cfg.ZMQPubHWM <= 0 {
+		str := "%s: The zmqpubhwm option must be greater than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.ZMQPubHWM)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The number of high-bandwidth compact block peers can't be negative.
+	if cfg.MaxCmpctHBPeers < 0 {
+		str := "%s: The maxcmpcthbpeers option may not be less than " +
+			"0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxCmpctHBPeers)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max orphan count to a sane vlue.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -858,6 +972,49 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the mempool package limits are sane.
+	packageLimits := []struct {
+		option string
+		value  int64
+	}{
+		{"limitancestorcount", cfg.LimitAncestorCount},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", cfg.LimitDescendantCount},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+	}
+	for _, limit := range packageLimits {
+		if limit.value < 1 {
+			str := "%s: The %s option may not be less than 1 " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, limit.option,
+				limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// The mempool must be able to hold several packages of the maximum
+	// descendant size.
+	minMempool := (cfg.LimitDescendantSize*40 + 999) / 1000
+	if cfg.MaxMempool < minMempool {
+		str := "%s: The maxmempool option may not be less than %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, minMempool, cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.MempoolExpiry < time.Hour {
+		str := "%s: The mempoolexpiry option may not be less than " +
+			"1h -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -924,6 +1081,96 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the prune target is large enough to always keep the minimum
+	// number of blocks around.
+	if cfg.Prune != 0 && cfg.Prune < pruneMinSize {
+		err := fmt.Errorf("%s: the minimum value for --prune is %d MiB",
+			funcName, pruneMinSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune and --txindex do not mix.
+	if cfg.Prune != 0 && cfg.TxIndex {
+		err := fmt.Errorf("%s: the --prune and --txindex options may "+
+			"not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune and --addrindex do not mix.
+	if cfg.Prune != 0 && cfg.AddrIndex {
+		err := fmt.Errorf("%s: the --prune and --addrindex options may "+
+			"not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --loadsnapshot and the optional indexes do not mix since they can't be
+	// built until the snapshot has been validated.
+	if cfg.LoadSnapshot != "" && (cfg.TxIndex || cfg.AddrIndex ||
+		!cfg.NoCFilters) {
+
+		err := fmt.Errorf("%s: the --loadsnapshot option requires the "+
+			"--nocfilters option and may not be activated at the "+
+			"same time as the --txindex or --addrindex options",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --loadsnapshot and --prune do not mix.
+	if cfg.LoadSnapshot != "" && cfg.Prune != 0 {
+		err := fmt.Errorf("%s: the --loadsnapshot and --prune options "+
+			"may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Check the utxo snapshots for syntax errors.  They can only be added
+	// on the test networks where the chain is created locally since a node
+	// bootstrapped from a bogus snapshot would follow an invalid chain
+	// until the background validation catches up.
+	cfg.addAssumeUtxos, err = parseAssumeUtxos(cfg.AddAssumeUtxos)
+	if err != nil {
+		str := "%s: Error parsing utxo snapshots: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if len(cfg.addAssumeUtxos) > 0 && !cfg.RegressionTest && !cfg.SimNet {
+		str := "%s: the --addassumeutxo option is only supported on " +
+			"the regression and simulation test networks"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --loadsnapshot requires the utxo snapshot to be listed in the chain
+	// parameters or added with --addassumeutxo, so it can't be used on
+	// networks without any.
+	if cfg.LoadSnapshot != "" && len(activeNetParams.AssumeUtxo) == 0 &&
+		len(cfg.addAssumeUtxos) == 0 {
+
+		err := fmt.Errorf("%s: the --loadsnapshot option is not "+
+			"supported on %s since no utxo snapshots are known for "+
+			"it -- use --addassumeutxo to add one", funcName,
+			activeNetParams.Name)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LoadSnapshot != "" {
+		cfg.LoadSnapshot = cleanAndExpandPath(cfg.LoadSnapshot)
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]btcutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
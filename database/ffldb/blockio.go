@@ -168,6 +168,15 @@ type blockStore struct {
 	// new blocks are written to.
 	writeCursor *writeCursor
 
+	// firstFileNum is the number of the oldest flat file that is still on
+	// disk.  It is only ever advanced when old files are pruned.
+	//
+	// firstFileMtx protects concurrent access to firstFileNum.  It is
+	// never held while acquiring any of the other mutexes, so it is not
+	// part of the locking order described above.
+	firstFileMtx sync.RWMutex
+	firstFileNum uint32
+
 	// These functions are set to openFile, openWriteFile, and deleteFile by
 	// default, but are exposed here to allow the whitebox tests to replace
 	// them when working with mock files.
@@ -307,6 +316,61 @@ func (s *blockStore) deleteFile(fileNum uint32) error {
 	return nil
 }
 
+// pruneFile closes the passed flat file number if it is currently open and then
+// removes it from disk.  The oldest file number tracked by the store is
+// advanced accordingly.
+//
+// This function MUST only be called during a write transaction and must NOT be
+// called for the current write file.
+func (s *blockStore) pruneFile(fileNum uint32) error {
+	// Close the file under the write lock for the file in case any readers
+	// are currently reading from it so it's not closed out from under them.
+	s.obfMutex.Lock()
+	if blockFile, ok := s.openBlockFiles[fileNum]; ok {
+		s.lruMutex.Lock()
+		s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+		delete(s.fileNumToLRUElem, fileNum)
+		s.lruMutex.Unlock()
+
+		blockFile.Lock()
+		_ = blockFile.file.Close()
+		blockFile.Unlock()
+		delete(s.openBlockFiles, fileNum)
+	}
+	s.obfMutex.Unlock()
+
+	if err := s.deleteFileFunc(fileNum); err != nil {
+		return err
+	}
+
+	s.firstFileMtx.Lock()
+	if fileNum >= s.firstFileNum {
+		s.firstFileNum = fileNum + 1
+	}
+	s.firstFileMtx.Unlock()
+	return nil
+}
+
+// fileSize returns the size in bytes of the block file for the passed flat file
+// number as it is on disk.
+func (s *blockStore) fileSize(fileNum uint32) (uint64, error) {
+	st, err := os.Stat(blockFilePath(s.basePath, fileNum))
+	if err != nil {
+		return 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	return uint64(st.Size()), nil
+}
+
+// oldestFileNum returns the number of the oldest flat file that is still on
+// disk.
+func (s *blockStore) oldestFileNum() uint32 {
+	s.firstFileMtx.RLock()
+	fileNum := s.firstFileNum
+	s.firstFileMtx.RUnlock()
+	return fileNum
+}
+
 // blockFile attempts to return an existing file handle for the passed flat file
 // number if it is already open as well as marking it as most recently used.  It
 // will also open the file when it's not already open subject to the rules
@@ -713,14 +777,33 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 }
 
 // scanBlockFiles searches the database directory for all flat block files to
-// find the end of the most recent file.  This position is considered the
-// current write cursor which is also stored in the metadata.  Thus, it is used
-// to detect unexpected shutdowns in the middle of writes so the block files
-// can be reconciled.
-func scanBlockFiles(dbPath string) (int, uint32) {
+// find the oldest file as well as the end of the most recent file.  This
+// position is considered the current write cursor which is also stored in the
+// metadata.  Thus, it is used to detect unexpected shutdowns in the middle of
+// writes so the block files can be reconciled.
+//
+// The oldest file will be something other than the first file when old block
+// files have been pruned, so the scan starts from the lowest numbered file
+// found in the directory.
+func scanBlockFiles(dbPath string) (int, int, uint32) {
+	firstFile := -1
+	pattern := filepath.Join(dbPath, "*"+filepath.Ext(blockFilenameTemplate))
+	filePaths, _ := filepath.Glob(pattern)
+	for _, filePath := range filePaths {
+		var fileNum uint32
+		_, err := fmt.Sscanf(filepath.Base(filePath), blockFilenameTemplate,
+			&fileNum)
+		if err != nil {
+			continue
+		}
+		if firstFile == -1 || int(fileNum) < firstFile {
+			firstFile = int(fileNum)
+		}
+	}
+
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	for i := firstFile; firstFile != -1; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -731,9 +814,9 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 		fileLen = uint32(st.Size())
 	}
 
-	log.Tracef("Scan found latest block file #%d with length %d", lastFile,
-		fileLen)
-	return lastFile, fileLen
+	log.Tracef("Scan found oldest block file #%d and latest block file #%d "+
+		"with length %d", firstFile, lastFile, fileLen)
+	return firstFile, lastFile, fileLen
 }
 
 // newBlockStore returns a new block store with the current block file number
@@ -742,8 +825,9 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
-	fileNum, fileOff := scanBlockFiles(basePath)
+	firstFileNum, fileNum, fileOff := scanBlockFiles(basePath)
 	if fileNum == -1 {
+		firstFileNum = 0
 		fileNum = 0
 		fileOff = 0
 	}
@@ -755,6 +839,7 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 		openBlockFiles:   make(map[uint32]*lockableFile),
 		openBlocksLRU:    list.New(),
 		fileNumToLRUElem: make(map[uint32]*list.Element),
+		firstFileNum:     uint32(firstFileNum),
 
 		writeCursor: &writeCursor{
 			curFile:    &lockableFile{},
@@ -966,6 +966,11 @@ type transaction struct {
 	pendingBlocks    map[chainhash.Hash]int
 	pendingBlockData []pendingBlock
 
+	// Block files that need to be removed from disk on commit as a result
+	// of pruning.  They are only removed once the associated block index
+	// entries have been deleted from persistent storage.
+	pendingPrunes map[uint32]struct{}
+
 	// Keys that need to be stored or deleted on commit.
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
@@ -1583,6 +1588,150 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks deletes the oldest block files until the total size of the block
+// files is at or below the provided target size (in bytes) and returns the
+// hashes of all blocks that were stored in the deleted files.  Neither the
+// current write file nor any files at or after the one housing the block
+// identified by the keep hash are deleted.
+//
+// The block index entries for the deleted blocks are removed as a part of the
+// transaction while the files themselves are removed from disk once the
+// transaction has been committed and the index changes have been flushed to
+// persistent storage.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the block identified by the keep hash does not exist
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(targetSize uint64, keep *chainhash.Hash) ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Files at or after the current write file are never deleted.
+	store := tx.db.store
+	wc := store.writeCursor
+	wc.RLock()
+	curFileNum := wc.curFileNum
+	curOffset := wc.curOffset
+	wc.RUnlock()
+	keepFileNum := curFileNum
+
+	// Also keep all files at or after the one which houses the block that
+	// must be kept.  Blocks that are still pending are going to be written
+	// to the current write file, so there is nothing more to do for them.
+	if keep != nil {
+		if _, exists := tx.pendingBlocks[*keep]; !exists {
+			blockRow, err := tx.fetchBlockRow(keep)
+			if err != nil {
+				return nil, err
+			}
+			location := deserializeBlockLoc(blockRow)
+			if location.blockFileNum < keepFileNum {
+				keepFileNum = location.blockFileNum
+			}
+		}
+	}
+
+	// Calculate the total size of the block files while skipping any files
+	// that are already scheduled to be pruned by this transaction.  The
+	// files prior to the current write file are no longer written to, so
+	// their size on disk is final.
+	firstFileNum := store.oldestFileNum()
+	fileSizes := make(map[uint32]uint64, curFileNum-firstFileNum)
+	totalSize := uint64(curOffset)
+	for fileNum := firstFileNum; fileNum < curFileNum; fileNum++ {
+		if _, ok := tx.pendingPrunes[fileNum]; ok {
+			continue
+		}
+		size, err := store.fileSize(fileNum)
+		if err != nil {
+			return nil, err
+		}
+		fileSizes[fileNum] = size
+		totalSize += size
+	}
+	if totalSize <= targetSize {
+		return nil, nil
+	}
+
+	// Select the oldest files to delete until the target size is reached.
+	pruneFiles := make(map[uint32]struct{})
+	for fileNum := firstFileNum; fileNum < keepFileNum; fileNum++ {
+		if totalSize <= targetSize {
+			break
+		}
+		if _, ok := tx.pendingPrunes[fileNum]; ok {
+			continue
+		}
+
+		pruneFiles[fileNum] = struct{}{}
+		totalSize -= fileSizes[fileNum]
+	}
+	if len(pruneFiles) == 0 {
+		return nil, nil
+	}
+
+	// Remove the block index entries for all blocks housed in the files
+	// that are being deleted.
+	var prunedHashes []chainhash.Hash
+	cursor := tx.blockIdxBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		location := deserializeBlockLoc(cursor.Value())
+		if _, ok := pruneFiles[location.blockFileNum]; !ok {
+			continue
+		}
+
+		var hash chainhash.Hash
+		copy(hash[:], cursor.Key())
+		prunedHashes = append(prunedHashes, hash)
+	}
+	for i := range prunedHashes {
+		err := tx.blockIdxBucket.Delete(prunedHashes[i][:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Schedule the files to be deleted from disk on commit.
+	if tx.pendingPrunes == nil {
+		tx.pendingPrunes = make(map[uint32]struct{})
+	}
+	for fileNum := range pruneFiles {
+		tx.pendingPrunes[fileNum] = struct{}{}
+	}
+
+	log.Debugf("Pruning %d block files housing %d blocks", len(pruneFiles),
+		len(prunedHashes))
+
+	return prunedHashes, nil
+}
+
+// BeenPruned returns whether or not any block files have ever been deleted via
+// PruneBlocks.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) BeenPruned() (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	return tx.db.store.oldestFileNum() > 0 || len(tx.pendingPrunes) > 0, nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1593,6 +1742,9 @@ func (tx *transaction) close() {
 	tx.pendingBlocks = nil
 	tx.pendingBlockData = nil
 
+	// Clear pending block files that would have been pruned on commit.
+	tx.pendingPrunes = nil
+
 	// Clear pending keys that would have been written or deleted on commit.
 	tx.pendingKeys = nil
 	tx.pendingRemove = nil
@@ -1666,7 +1818,30 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Remove any pruned block files now that the block index entries that
+	// reference them have been removed from persistent storage.  There is
+	// no need to return any errors here since the metadata no longer
+	// references the files, so the worst case is they are left behind.
+	pruneFileNums := make([]uint32, 0, len(tx.pendingPrunes))
+	for fileNum := range tx.pendingPrunes {
+		pruneFileNums = append(pruneFileNums, fileNum)
+	}
+	sort.Slice(pruneFileNums, func(i, j int) bool {
+		return pruneFileNums[i] < pruneFileNums[j]
+	})
+	for _, fileNum := range pruneFileNums {
+		log.Debugf("Pruning block file %d", fileNum)
+		if err := tx.db.store.pruneFile(fileNum); err != nil {
+			log.Warnf("Failed to delete pruned block file number "+
+				"%d: %v", fileNum, err)
+		}
+	}
+
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
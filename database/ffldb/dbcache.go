@@ -526,11 +526,18 @@ func (c *dbCache) flush() error {
 // needsFlush returns whether or not the database cache needs to be flushed to
 // persistent storage based on its current size, whether or not adding all of
 // the entries in the passed database transaction would cause it to exceed the
-// configured limit, and how much time has elapsed since the last time the cache
-// was flushed.
+// configured limit, how much time has elapsed since the last time the cache
+// was flushed, and whether or not the transaction prunes any block files.
 //
 // This function MUST be called with the database write lock held.
 func (c *dbCache) needsFlush(tx *transaction) bool {
+	// A flush is needed when block files are being pruned so that the
+	// removal of the block index entries which reference them is persisted
+	// before the files are deleted from disk.
+	if len(tx.pendingPrunes) > 0 {
+		return true
+	}
+
 	// A flush is needed when more time has elapsed than the configured
 	// flush interval.
 	if time.Since(c.lastFlush) > c.flushInterval {
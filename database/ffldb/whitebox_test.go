@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -704,3 +705,181 @@ func TestFailureScenarios(t *testing.T) {
 	// Test various corruption scenarios.
 	testCorruption(tc)
 }
+
+// TestPruneBlocks ensures pruning removes the oldest block files along with
+// their block index entries, honors the keep block, and that the pruned state
+// of the database survives reopening it.
+func TestPruneBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-pruneblocks")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer func() {
+		idb.Close()
+	}()
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	const maxFileSize = 2048
+	idb.(*db).store.maxBlockFileSize = maxFileSize
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for i, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return fmt.Errorf("StoreBlock #%d: %v", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+
+	// Ensure a read-only transaction can't prune and that nothing has been
+	// pruned yet.
+	err = idb.View(func(tx database.Tx) error {
+		_, err := tx.PruneBlocks(0, nil)
+		if !checkDbError(t, "PruneBlocks", err, database.ErrTxNotWritable) {
+			return errSubTestFail
+		}
+
+		pruned, err := tx.BeenPruned()
+		if err != nil {
+			return err
+		}
+		if pruned {
+			return fmt.Errorf("BeenPruned: unexpected pruned database")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Ensure nothing is pruned when the target is the total size of the
+	// block files on disk, which is less than the maximum file size times
+	// the number of files since blocks don't fill the files exactly.
+	filePaths, err := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if err != nil {
+		t.Fatalf("Glob: unexpected error: %v", err)
+	}
+	var diskSize, lastFileSize uint64
+	for _, filePath := range filePaths {
+		st, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatalf("Stat: unexpected error: %v", err)
+		}
+		lastFileSize = uint64(st.Size())
+		diskSize += lastFileSize
+	}
+	if diskSize-lastFileSize >= uint64(len(filePaths)-1)*maxFileSize {
+		t.Fatalf("block files are unexpectedly full")
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		prunedHashes, err := tx.PruneBlocks(diskSize, nil)
+		if err != nil {
+			return err
+		}
+		if len(prunedHashes) != 0 {
+			return fmt.Errorf("PruneBlocks: pruned %d blocks at "+
+				"the size on disk", len(prunedHashes))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Prune down to a handful of files while keeping one of the most
+	// recent blocks.  The keep block must prevent the target from being
+	// reached.
+	keepIdx := len(blocks) - 30
+	keepHash := blocks[keepIdx].Hash()
+	var prunedHashes []chainhash.Hash
+	err = idb.Update(func(tx database.Tx) error {
+		var err error
+		prunedHashes, err = tx.PruneBlocks(maxFileSize, keepHash)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: unexpected error: %v", err)
+	}
+	if len(prunedHashes) == 0 || len(prunedHashes) > keepIdx {
+		t.Fatalf("PruneBlocks: unexpected number of pruned blocks %d "+
+			"(keep index %d)", len(prunedHashes), keepIdx)
+	}
+
+	// The pruned blocks must be the oldest blocks.
+	prunedSet := make(map[chainhash.Hash]struct{})
+	for _, hash := range prunedHashes {
+		prunedSet[hash] = struct{}{}
+	}
+	for i := 0; i < len(prunedHashes); i++ {
+		if _, ok := prunedSet[*blocks[i].Hash()]; !ok {
+			t.Fatalf("PruneBlocks: block #%d was not pruned", i)
+		}
+	}
+
+	// Ensure the pruned blocks are gone, the remaining blocks can still be
+	// loaded, and the files were removed from disk.
+	checkBlocks := func(idb database.DB) {
+		t.Helper()
+
+		err := idb.View(func(tx database.Tx) error {
+			for i, block := range blocks {
+				hash := block.Hash()
+				_, wantPruned := prunedSet[*hash]
+				hasBlock, err := tx.HasBlock(hash)
+				if err != nil {
+					return err
+				}
+				if hasBlock == wantPruned {
+					return fmt.Errorf("HasBlock #%d: got %v, "+
+						"want %v", i, hasBlock, !wantPruned)
+				}
+				if wantPruned {
+					continue
+				}
+				if _, err := tx.FetchBlock(hash); err != nil {
+					return fmt.Errorf("FetchBlock #%d: %v",
+						i, err)
+				}
+			}
+
+			pruned, err := tx.BeenPruned()
+			if err != nil {
+				return err
+			}
+			if !pruned {
+				return fmt.Errorf("BeenPruned: database not " +
+					"marked pruned")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	checkBlocks(idb)
+	if fileExists(blockFilePath(dbPath, 0)) {
+		t.Fatalf("pruned block file 0 still exists")
+	}
+
+	// Ensure the database can be reopened with the oldest files missing.
+	idb.Close()
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to reopen pruned database: %v", err)
+	}
+	checkBlocks(idb)
+}
@@ -389,6 +389,35 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks deletes the oldest stored blocks until the total size of
+	// the block storage is at or below the provided target size (in bytes)
+	// and returns the hashes of all blocks that were deleted.  No block
+	// that was stored at the same time as or after the block identified by
+	// the keep hash is deleted which allows the caller to enforce a window
+	// of recent blocks that must always be available.  The keep hash may be
+	// nil if the caller does not need that behavior.
+	//
+	// Depending on the backend implementation, the blocks might not be
+	// removed from the underlying storage until the transaction is
+	// committed, however they are no longer available from the viewpoint
+	// of the transaction once this function returns.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockNotFound if the block identified by the keep hash does
+	//     not exist
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	PruneBlocks(targetSize uint64, keep *chainhash.Hash) ([]chainhash.Hash, error)
+
+	// BeenPruned returns whether or not blocks have ever been deleted from
+	// the block storage via PruneBlocks.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	BeenPruned() (bool, error)
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
@@ -21,6 +21,11 @@ Usage:
   btcd [OPTIONS]
 
 Application Options:
+      --addassumeutxo=        Add a utxo snapshot which may be loaded with
+                              --loadsnapshot on the regression and simulation
+                              test networks.  Format: '<height>:<base
+                              hash>:<txoutset hash>:<nchaintx>' as returned by
+                              the dumptxoutset RPC
       --addcheckpoint=        Add a custom checkpoint.  Format:
                               '<height>:<hash>'
   -a, --addpeer=              Add a peer to connect with at startup
@@ -67,17 +72,47 @@ Application Options:
       --externalip=           Add an ip to the list of local addresses we claim
                               to listen on to peers
       --generate              Generate (mine) bitcoins using the CPU
+      --limitancestorcount=   Do not accept transactions into the mempool if the
+                              number of their unconfirmed ancestors, including
+                              themselves, exceeds this value (default: 25)
+      --limitancestorsize=    Do not accept transactions into the mempool if
+                              their total virtual size along with all of their
+                              unconfirmed ancestors exceeds this value in
+                              kilobytes (default: 101)
+      --limitdescendantcount= Do not accept transactions into the mempool if any
+                              of their unconfirmed ancestors would have more
+                              descendants in the mempool, including themselves,
+                              than this value (default: 25)
+      --limitdescendantsize=  Do not accept transactions into the mempool if any
+                              of their unconfirmed ancestors would exceed this
+                              total virtual size in kilobytes along with all of
+                              their descendants in the mempool (default: 101)
       --limitfreerelay=       Limit relay of transactions with no transaction
                               fee to the given amount in thousands of bytes per
                               minute (default: 15)
       --listen=               Add an interface/port to listen for connections
                               (default all interfaces port: 8333, testnet:
                               18333, signet: 38333)
+      --loadsnapshot=         Bootstrap the chain from the utxo snapshot in the
+                              given file created by the dumptxoutset RPC and
+                              validate it in the background -- NOTE: Only
+                              allowed with an empty data directory and the
+                              snapshot must be listed in the chain parameters
+                              or added with --addassumeutxo
       --logdir=               Directory to log output
+      --maxmempool=           Keep the transaction memory pool below the given
+                              size in megabytes by evicting the transactions
+                              paying the lowest fees (default: 300)
+      --maxcmpcthbpeers=      Max number of peers that are requested to relay
+                              new blocks as BIP0152 compact blocks without
+                              announcing them first (high-bandwidth mode) -- 0
+                              to disable (default: 3)
       --maxorphantx=          Max number of orphan transactions to keep in
                               memory (default: 100)
       --maxpeers=             Max number of inbound and outbound peers
                               (default: 125)
+      --mempoolexpiry=        Do not keep transactions in the memory pool longer
+                              than the given duration (default: 336h0m0s)
       --miningaddr=           Add the specified payment address to the list of
                               addresses to use for generated blocks -- At least
                               one address is required if the generate option is
@@ -95,6 +130,8 @@ Application Options:
                               also specifying listen interfaces via --listen
       --noonion               Disable connecting to tor hidden services
       --nopeerbloomfilters    Disable bloom filtering support
+      --nopersistmempool      Do not save the mempool to mempool.dat in the data
+                              directory on shutdown and load it on startup
       --norelaypriority       Do not require free or low-fee transactions to
                               have high priority for relaying
       --norpc                 Disable built-in RPC server -- NOTE: The RPC
@@ -110,6 +147,10 @@ Application Options:
       --onionuser=            Username for onion proxy server
       --profile=              Enable HTTP profiling on given port -- NOTE port
                               must be between 1024 and 65536
+      --prune=                Prune already validated blocks from the database
+                              once the stored block data exceeds the target
+                              size in MiB -- The minimum value is 1536 and 0
+                              disables pruning
       --proxy=                Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
       --proxypass=            Password for proxy server
       --proxyuser=            Username for proxy server
@@ -149,9 +190,34 @@ Application Options:
       --uacomment=            Comment to add to the user agent -- See BIP 14
                               for more information.
       --upnp                  Use UPnP to map our listening port outside of NAT
+      --utxocachemaxsize=     The maximum size in MiB of the UTXO cache --
+                              Modifications to the UTXO set are only written to
+                              the database once the cache exceeds this size,
+                              periodically, and on shutdown (default: 250)
+      --utxosetstats          Maintain the statistics and MuHash of the UTXO
+                              set as blocks are connected so the
+                              gettxoutsetinfo RPC can return them without
+                              scanning the UTXO set
   -V, --version               Display version information and exit
       --whitelist=            Add an IP network or IP that will not be banned.
                               (eg. 192.168.1.0/24 or ::1)
+      --zmqpubhashblock=      Publish the hash of each block connected to the
+                              main chain to the given ZMQ address (eg.
+                              tcp://127.0.0.1:28332)
+      --zmqpubhashtx=         Publish the hash of each new mempool transaction
+                              and transaction in connected or disconnected
+                              blocks to the given ZMQ address
+      --zmqpubrawblock=       Publish each serialized block connected to the
+                              main chain to the given ZMQ address
+      --zmqpubrawtx=          Publish each serialized new mempool transaction
+                              and transaction in connected or disconnected
+                              blocks to the given ZMQ address
+      --zmqpubsequence=       Publish block connection and disconnection as
+                              well as mempool acceptance events to the given
+                              ZMQ address
+      --zmqpubhwm=            Max number of outbound messages queued for each
+                              ZMQ subscriber before further messages are
+                              dropped (default: 1000)
 
 Help Options:
   -h, --help           Show this help message
@@ -0,0 +1,142 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+//go:build rpctest
+// +build rpctest
+
+package integration
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/integration/rpctest"
+)
+
+// waitForChainStates polls the getchainstates RPC of the passed harness until
+// the passed function accepts the result or the timeout expires.
+func waitForChainStates(r *rpctest.Harness, timeout time.Duration,
+	done func(*btcjson.GetChainStatesResult) bool) (*btcjson.GetChainStatesResult, error) {
+
+	deadline := time.Now().Add(timeout)
+	for {
+		chainStates, err := r.Client.GetChainStates()
+		if err != nil {
+			return nil, err
+		}
+		if done(chainStates) || time.Now().After(deadline) {
+			return chainStates, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// TestLoadSnapshot ensures a node can be bootstrapped from a utxo snapshot
+// created by the dumptxoutset RPC of another node through the --loadsnapshot
+// and --addassumeutxo options, and that it validates the chain up to the
+// snapshot in the background once it is connected to the other node.
+func TestLoadSnapshot(t *testing.T) {
+	t.Parallel()
+
+	// Create a chain with some spendable outputs on the first node and
+	// dump its utxo set.
+	r, err := rpctest.New(&chaincfg.RegressionNetParams, nil, nil, "")
+	if err != nil {
+		t.Fatalf("unable to create primary harness: %v", err)
+	}
+	if err := r.SetUp(true, 25); err != nil {
+		t.Fatalf("unable to setup test chain: %v", err)
+	}
+	defer r.TearDown()
+
+	path := filepath.Join(t.TempDir(), "utxo.dat")
+	dump, err := r.Client.DumpTxOutSet(path)
+	if err != nil {
+		t.Fatalf("unable to dump utxo set: %v", err)
+	}
+
+	// Loading the snapshot is rejected on the regression test network
+	// unless it's added with --addassumeutxo.
+	args := []string{"--nocfilters", "--loadsnapshot=" + path}
+	noEntry, err := rpctest.New(&chaincfg.RegressionNetParams, nil, args, "")
+	if err != nil {
+		t.Fatalf("unable to create harness: %v", err)
+	}
+	if err := noEntry.SetUp(false, 0); err == nil {
+		noEntry.TearDown()
+		t.Fatalf("node without a utxo snapshot entry loaded the " +
+			"snapshot")
+	}
+	noEntry.TearDown()
+
+	// Bootstrap the second node from the snapshot.
+	assumeUtxo := fmt.Sprintf("--addassumeutxo=%d:%s:%s:%d",
+		dump.BaseHeight, dump.BaseHash, dump.TxOutSetHash, dump.NChainTx)
+	args = append(args, assumeUtxo)
+	s, err := rpctest.New(&chaincfg.RegressionNetParams, nil, args, "")
+	if err != nil {
+		t.Fatalf("unable to create snapshot harness: %v", err)
+	}
+	if err := s.SetUp(false, 0); err != nil {
+		t.Fatalf("unable to bootstrap node from utxo snapshot: %v", err)
+	}
+	defer s.TearDown()
+
+	// The snapshot chainstate is the best chain right away while the
+	// background chainstate only contains the genesis block.
+	bestHash, height, err := s.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	if bestHash.String() != dump.BaseHash || height != dump.BaseHeight {
+		t.Fatalf("best block is %v (height %d) instead of the "+
+			"snapshot block %v (height %d)", bestHash, height,
+			dump.BaseHash, dump.BaseHeight)
+	}
+	info, err := s.Client.GetTxOutSetInfo()
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+	if info.HashSerialized.String() != dump.TxOutSetHash ||
+		uint64(info.TxOuts) != dump.CoinsWritten {
+
+		t.Fatalf("unexpected utxo set of the loaded snapshot: %+v",
+			info)
+	}
+	chainStates, err := s.Client.GetChainStates()
+	if err != nil {
+		t.Fatalf("unable to get chainstates: %v", err)
+	}
+	if len(chainStates.ChainStates) != 2 ||
+		chainStates.ChainStates[1].Validated ||
+		chainStates.ChainStates[1].SnapshotBlockHash != dump.BaseHash {
+
+		t.Fatalf("unexpected getchainstates result: %+v", chainStates)
+	}
+
+	// Once connected to the first node, the blocks up to the snapshot are
+	// downloaded and validated in the background, after which only the
+	// validated chainstate is left.
+	if err := rpctest.ConnectNode(s, r); err != nil {
+		t.Fatalf("unable to connect nodes: %v", err)
+	}
+	chainStates, err = waitForChainStates(s, time.Minute,
+		func(result *btcjson.GetChainStatesResult) bool {
+			return len(result.ChainStates) == 1
+		})
+	if err != nil {
+		t.Fatalf("unable to get chainstates: %v", err)
+	}
+	if len(chainStates.ChainStates) != 1 ||
+		!chainStates.ChainStates[0].Validated ||
+		chainStates.ChainStates[0].BestBlockHash != dump.BaseHash {
+
+		t.Fatalf("utxo snapshot was not validated: %+v", chainStates)
+	}
+}
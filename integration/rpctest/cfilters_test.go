@@ -0,0 +1,223 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+//go:build rpctest
+// +build rpctest
+
+package rpctest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// cfSyncTimeout is the amount of time to wait for a response to a compact
+// filter request before failing the test.
+const cfSyncTimeout = time.Second * 10
+
+// cfSyncPeer houses an outbound peer connected to a harness node along with
+// the channels its compact filter messages are delivered on.
+type cfSyncPeer struct {
+	*peer.Peer
+	verack    chan struct{}
+	checkpts  chan *wire.MsgCFCheckpt
+	cfheaders chan *wire.MsgCFHeaders
+	cfilters  chan *wire.MsgCFilter
+}
+
+// newCFSyncPeer connects a new outbound peer to the passed harness and waits
+// for the version handshake to complete.
+func newCFSyncPeer(r *Harness, t *testing.T) *cfSyncPeer {
+	p := &cfSyncPeer{
+		verack:    make(chan struct{}, 1),
+		checkpts:  make(chan *wire.MsgCFCheckpt, 1),
+		cfheaders: make(chan *wire.MsgCFHeaders, 1),
+		cfilters:  make(chan *wire.MsgCFilter, wire.MaxGetCFiltersReqRange),
+	}
+	cfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(_ *peer.Peer, _ *wire.MsgVerAck) {
+				p.verack <- struct{}{}
+			},
+			OnCFCheckpt: func(_ *peer.Peer, msg *wire.MsgCFCheckpt) {
+				p.checkpts <- msg
+			},
+			OnCFHeaders: func(_ *peer.Peer, msg *wire.MsgCFHeaders) {
+				p.cfheaders <- msg
+			},
+			OnCFilter: func(_ *peer.Peer, msg *wire.MsgCFilter) {
+				p.cfilters <- msg
+			},
+		},
+		UserAgentName:    "cfsync",
+		UserAgentVersion: "1.0.0",
+		ChainParams:      r.ActiveNet,
+		Services:         wire.SFNodeWitness,
+		TrickleInterval:  time.Second * 10,
+	}
+
+	var err error
+	p.Peer, err = peer.NewOutboundPeer(cfg, r.P2PAddress())
+	if err != nil {
+		t.Fatalf("unable to create outbound peer: %v", err)
+	}
+	conn, err := net.Dial("tcp", r.P2PAddress())
+	if err != nil {
+		t.Fatalf("unable to connect to harness: %v", err)
+	}
+	p.AssociateConnection(conn)
+
+	select {
+	case <-p.verack:
+	case <-time.After(cfSyncTimeout):
+		t.Fatalf("timeout waiting for verack")
+	}
+	return p
+}
+
+// testSyncCFilters ensures a light client is able to sync the regular compact
+// filters from a harness node over the P2P network as defined by BIP0157 and
+// that invalid requests result in a disconnect.
+func testSyncCFilters(r *Harness, t *testing.T) {
+	p := newCFSyncPeer(r, t)
+	defer p.Disconnect()
+
+	if p.Services()&wire.SFNodeCF != wire.SFNodeCF {
+		t.Fatalf("harness does not advertise compact filters: %v",
+			p.Services())
+	}
+
+	bestHash, bestHeight, err := r.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+
+	// Request the checkpoints up to the tip twice to ensure the cached
+	// response matches the original one.
+	var checkpt *wire.MsgCFCheckpt
+	for i := 0; i < 2; i++ {
+		p.QueueMessage(&wire.MsgGetCFCheckpt{
+			FilterType: wire.GCSFilterRegular,
+			StopHash:   *bestHash,
+		}, nil)
+		select {
+		case msg := <-p.checkpts:
+			wantLen := int(bestHeight) / wire.CFCheckptInterval
+			if len(msg.FilterHeaders) != wantLen {
+				t.Fatalf("unexpected number of checkpoints - "+
+					"got %d, want %d", len(msg.FilterHeaders),
+					wantLen)
+			}
+			if checkpt != nil && msg.StopHash != checkpt.StopHash {
+				t.Fatalf("cached cfcheckpt has stop hash %v, "+
+					"want %v", msg.StopHash, checkpt.StopHash)
+			}
+			checkpt = msg
+		case <-time.After(cfSyncTimeout):
+			t.Fatalf("timeout waiting for cfcheckpt")
+		}
+	}
+
+	// Sync the filter headers from the genesis block to the tip.
+	p.QueueMessage(&wire.MsgGetCFHeaders{
+		FilterType:  wire.GCSFilterRegular,
+		StartHeight: 0,
+		StopHash:    *bestHash,
+	}, nil)
+	var cfheaders *wire.MsgCFHeaders
+	select {
+	case cfheaders = <-p.cfheaders:
+	case <-time.After(cfSyncTimeout):
+		t.Fatalf("timeout waiting for cfheaders")
+	}
+	if len(cfheaders.FilterHashes) != int(bestHeight)+1 {
+		t.Fatalf("unexpected number of filter hashes - got %d, want %d",
+			len(cfheaders.FilterHashes), bestHeight+1)
+	}
+	if cfheaders.PrevFilterHeader != (chainhash.Hash{}) {
+		t.Fatalf("unexpected previous filter header for genesis %v",
+			cfheaders.PrevFilterHeader)
+	}
+
+	// Sync the filters themselves and make sure they commit to the filter
+	// hashes and that the resulting header chain matches the one served
+	// over RPC.
+	p.QueueMessage(&wire.MsgGetCFilters{
+		FilterType:  wire.GCSFilterRegular,
+		StartHeight: 0,
+		StopHash:    *bestHash,
+	}, nil)
+	var header chainhash.Hash
+	for height := int32(0); height <= bestHeight; height++ {
+		var msg *wire.MsgCFilter
+		select {
+		case msg = <-p.cfilters:
+		case <-time.After(cfSyncTimeout):
+			t.Fatalf("timeout waiting for cfilter at height %d",
+				height)
+		}
+
+		blockHash, err := r.Client.GetBlockHash(int64(height))
+		if err != nil {
+			t.Fatalf("unable to get block hash: %v", err)
+		}
+		if msg.BlockHash != *blockHash {
+			t.Fatalf("cfilter at height %d for block %v, want %v",
+				height, msg.BlockHash, blockHash)
+		}
+		rpcFilter, err := r.Client.GetCFilter(blockHash,
+			wire.GCSFilterRegular)
+		if err != nil {
+			t.Fatalf("unable to get cfilter: %v", err)
+		}
+		if !bytes.Equal(msg.Data, rpcFilter.Data) {
+			t.Fatalf("cfilter for block %v does not match RPC "+
+				"result", blockHash)
+		}
+
+		filterHash := chainhash.DoubleHashH(msg.Data)
+		if filterHash != *cfheaders.FilterHashes[height] {
+			t.Fatalf("cfilter for block %v has hash %v, want %v",
+				blockHash, filterHash,
+				cfheaders.FilterHashes[height])
+		}
+		header = chainhash.DoubleHashH(append(filterHash[:],
+			header[:]...))
+	}
+
+	rpcHeader, err := r.Client.GetCFilterHeader(bestHash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		t.Fatalf("unable to get cfilter header: %v", err)
+	}
+	if header != rpcHeader.PrevFilterHeader {
+		t.Fatalf("synced filter header %v does not match RPC result %v",
+			header, rpcHeader.PrevFilterHeader)
+	}
+
+	// A request with a start height past the stop hash must result in a
+	// disconnect.
+	p.QueueMessage(&wire.MsgGetCFilters{
+		FilterType:  wire.GCSFilterRegular,
+		StartHeight: uint32(bestHeight) + 1,
+		StopHash:    *bestHash,
+	}, nil)
+	disconnected := make(chan struct{})
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(cfSyncTimeout):
+		t.Fatalf("peer not disconnected after invalid getcfilters")
+	}
+}
@@ -9,16 +9,20 @@
 package rpctest
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 )
 
 func testSendOutputs(r *Harness, t *testing.T) {
@@ -547,6 +551,619 @@ func testMemWalletLockedOutputs(r *Harness, t *testing.T) {
 	}
 }
 
+func testEstimateSmartFee(r *Harness, t *testing.T) {
+	// Confirmation targets outside of the tracked range are rejected.
+	for _, confTarget := range []int64{0, 1009} {
+		_, err := r.Client.EstimateSmartFee(confTarget, nil)
+		if err == nil {
+			t.Fatalf("estimatesmartfee with target %d did not "+
+				"return an error", confTarget)
+		}
+	}
+
+	// The harness only mined blocks without any transactions that were
+	// observed in the mempool, so there is no data to estimate from.
+	mode := btcjson.EstimateModeEconomical
+	result, err := r.Client.EstimateSmartFee(6, &mode)
+	if err != nil {
+		t.Fatalf("unable to estimate fee: %v", err)
+	}
+	if result.FeeRate != nil || len(result.Errors) == 0 {
+		t.Fatalf("unexpected fee estimate without data: %+v", result)
+	}
+}
+
+func testGetBlockStats(r *Harness, t *testing.T) {
+	// Mine any transactions left in the mempool by earlier tests, then
+	// mine a block with a single transaction.
+	if _, err := r.Client.Generate(1); err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to generate pkscript to addr: %v", err)
+	}
+	output := wire.NewTxOut(btcutil.SatoshiPerBitcoin, addrScript)
+	txid, err := r.SendOutputs([]*wire.TxOut{output}, 10)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	tx, err := r.Client.GetRawTransaction(txid)
+	if err != nil {
+		t.Fatalf("unable to get transaction: %v", err)
+	}
+	blockHashes, err := r.Client.Generate(1)
+	if err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+	_, height, err := r.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+
+	stats, err := r.Client.GetBlockStats(blockHashes[0], nil)
+	if err != nil {
+		t.Fatalf("unable to get block stats: %v", err)
+	}
+	txSize := int64(tx.MsgTx().SerializeSize())
+	if stats.Height != int64(height) || stats.Txs != 2 ||
+		stats.Ins != int64(len(tx.MsgTx().TxIn)) ||
+		stats.Outs != int64(len(tx.MsgTx().TxOut))+1 ||
+		stats.TotalSize != txSize || stats.MinTxSize != txSize ||
+		stats.MaxTxSize != txSize || stats.MedianTxSize != txSize {
+
+		t.Fatalf("unexpected block stats: %+v", stats)
+	}
+	if stats.TotalFee == 0 || stats.MinFee != stats.TotalFee ||
+		stats.MaxFee != stats.TotalFee || stats.MedianFee != stats.TotalFee ||
+		stats.AverageFee != stats.TotalFee {
+
+		t.Fatalf("unexpected fee stats: %+v", stats)
+	}
+	for _, feeRate := range stats.FeeratePercentiles {
+		if feeRate != stats.AverageFeeRate || feeRate == 0 {
+			t.Fatalf("unexpected fee rate percentiles: %v",
+				stats.FeeratePercentiles)
+		}
+	}
+
+	// Only the selected statistics are returned when requested by height.
+	selected := []string{"txs", "totalfee"}
+	stats, err = r.Client.GetBlockStats(int(height), &selected)
+	if err != nil {
+		t.Fatalf("unable to get block stats: %v", err)
+	}
+	if stats.Txs != 2 || stats.TotalFee == 0 || stats.Height != 0 {
+		t.Fatalf("unexpected selected block stats: %+v", stats)
+	}
+
+	// Unknown statistics and heights beyond the tip are rejected.
+	invalid := []string{"txs", "unknown"}
+	if _, err := r.Client.GetBlockStats(int(height), &invalid); err == nil {
+		t.Fatalf("getblockstats with unknown statistic did not " +
+			"return an error")
+	}
+	if _, err := r.Client.GetBlockStats(int(height)+1, nil); err == nil {
+		t.Fatalf("getblockstats beyond the tip did not return an error")
+	}
+}
+
+func testGetTxOutProof(r *Harness, t *testing.T) {
+	// Mine a block containing a single transaction paying to the wallet.
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to get new address: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to generate pkscript to addr: %v", err)
+	}
+	output := wire.NewTxOut(btcutil.SatoshiPerBitcoin, addrScript)
+	txid, err := r.SendOutputs([]*wire.TxOut{output}, 10)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	blockHashes, err := r.Client.Generate(1)
+	if err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+
+	// Generate a proof for the transaction and ensure it verifies.
+	proof, err := r.Client.GetTxOutProof([]*chainhash.Hash{txid},
+		blockHashes[0])
+	if err != nil {
+		t.Fatalf("unable to get tx out proof: %v", err)
+	}
+	if proof.Header.BlockHash() != *blockHashes[0] {
+		t.Fatalf("proof is for block %v, want %v",
+			proof.Header.BlockHash(), blockHashes[0])
+	}
+	txHashes, err := r.Client.VerifyTxOutProof(proof)
+	if err != nil {
+		t.Fatalf("unable to verify tx out proof: %v", err)
+	}
+	if len(txHashes) != 1 || *txHashes[0] != *txid {
+		t.Fatalf("proof verified txids %v, want %v", txHashes, txid)
+	}
+
+	// A proof with a tampered transaction count must not verify.
+	proof.Transactions++
+	txHashes, err = r.Client.VerifyTxOutProof(proof)
+	if err != nil {
+		t.Fatalf("unable to verify tx out proof: %v", err)
+	}
+	if len(txHashes) != 0 {
+		t.Fatalf("tampered proof verified txids %v", txHashes)
+	}
+
+	// Requesting a proof for a transaction not in the block must fail.
+	_, err = r.Client.GetTxOutProof([]*chainhash.Hash{{0x01}},
+		blockHashes[0])
+	if err == nil {
+		t.Fatalf("proof for unknown transaction did not fail")
+	}
+
+	// The harness doesn't run with the transaction index, so the block
+	// can't be located without its hash.
+	_, err = r.Client.GetTxOutProof([]*chainhash.Hash{txid}, nil)
+	if err == nil {
+		t.Fatalf("proof without block hash or txindex did not fail")
+	}
+}
+
+func testDescriptors(r *Harness, t *testing.T) {
+	seed := bytes.Repeat([]byte{0x01}, hdkeychain.RecommendedSeedLen)
+	master, err := hdkeychain.NewMaster(seed, r.ActiveNet)
+	if err != nil {
+		t.Fatalf("unable to create master key: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("unable to neuter master key: %v", err)
+	}
+
+	// The canonical form of a descriptor without private keys is the
+	// descriptor itself along with its checksum.
+	desc := "wpkh(" + xpub.String() + "/0/*)"
+	info, err := r.Client.GetDescriptorInfo(desc)
+	if err != nil {
+		t.Fatalf("unable to get descriptor info: %v", err)
+	}
+	if info.Descriptor != desc+"#"+info.Checksum || !info.IsRange ||
+		!info.IsSolvable || info.HasPrivateKeys {
+
+		t.Fatalf("unexpected descriptor info: %+v", info)
+	}
+
+	// Derive a range of addresses and ensure they match the ones derived
+	// from the extended key directly.
+	descRange := &btcjson.DescriptorRange{Value: []int{2, 4}}
+	addrs, err := r.Client.DeriveAddresses(info.Descriptor, descRange)
+	if err != nil {
+		t.Fatalf("unable to derive addresses: %v", err)
+	}
+	if len(*addrs) != 3 {
+		t.Fatalf("unexpected number of addresses: got %d, want 3",
+			len(*addrs))
+	}
+	branch, err := xpub.Derive(0)
+	if err != nil {
+		t.Fatalf("unable to derive key: %v", err)
+	}
+	for i, addr := range *addrs {
+		child, err := branch.Derive(uint32(i + 2))
+		if err != nil {
+			t.Fatalf("unable to derive key: %v", err)
+		}
+		pubKey, err := child.ECPubKey()
+		if err != nil {
+			t.Fatalf("unable to get public key: %v", err)
+		}
+		want, err := btcutil.NewAddressWitnessPubKeyHash(
+			btcutil.Hash160(pubKey.SerializeCompressed()),
+			r.ActiveNet,
+		)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		if addr != want.EncodeAddress() {
+			t.Fatalf("unexpected address %d: got %s, want %s", i,
+				addr, want.EncodeAddress())
+		}
+	}
+
+	// Ranged descriptors require a range, and deriving addresses requires
+	// the checksum.
+	if _, err := r.Client.DeriveAddresses(info.Descriptor, nil); err == nil {
+		t.Fatalf("deriving addresses without a range did not fail")
+	}
+	if _, err := r.Client.DeriveAddresses(desc, descRange); err == nil {
+		t.Fatalf("deriving addresses without a checksum did not fail")
+	}
+}
+
+func testScanTxOutSet(r *Harness, t *testing.T) {
+	// No scan is in progress, so there is neither a status to report nor
+	// a scan to abort.
+	status, err := r.Client.ScanTxOutSetStatus()
+	if err != nil {
+		t.Fatalf("unable to get scan status: %v", err)
+	}
+	if status != nil {
+		t.Fatalf("unexpected scan status: %+v", status)
+	}
+	aborted, err := r.Client.ScanTxOutSetAbort()
+	if err != nil {
+		t.Fatalf("unable to abort scan: %v", err)
+	}
+	if aborted {
+		t.Fatalf("aborted scan when none was in progress")
+	}
+
+	// Pay to a child of an extended key outside of the wallet and mine
+	// the transaction.
+	seed := bytes.Repeat([]byte{0x02}, hdkeychain.RecommendedSeedLen)
+	master, err := hdkeychain.NewMaster(seed, r.ActiveNet)
+	if err != nil {
+		t.Fatalf("unable to create master key: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("unable to neuter master key: %v", err)
+	}
+	child, err := xpub.Derive(7)
+	if err != nil {
+		t.Fatalf("unable to derive key: %v", err)
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		t.Fatalf("unable to get public key: %v", err)
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey.SerializeCompressed()), r.ActiveNet,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to generate pkscript to addr: %v", err)
+	}
+	output := wire.NewTxOut(btcutil.SatoshiPerBitcoin, addrScript)
+	txid, err := r.SendOutputs([]*wire.TxOut{output}, 10)
+	if err != nil {
+		t.Fatalf("coinbase spend failed: %v", err)
+	}
+	if _, err := r.Client.Generate(1); err != nil {
+		t.Fatalf("unable to generate single block: %v", err)
+	}
+	_, height, err := r.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+
+	// Both the address and the ranged descriptor including the child must
+	// find the output.
+	scanObjects := [][]btcjson.ScanObject{
+		{{Desc: "addr(" + addr.EncodeAddress() + ")"}},
+		{{Desc: "wpkh(" + xpub.String() + "/*)"}},
+	}
+	for _, objs := range scanObjects {
+		result, err := r.Client.ScanTxOutSet(objs)
+		if err != nil {
+			t.Fatalf("unable to scan utxo set: %v", err)
+		}
+		if !result.Success || result.Height != height ||
+			result.TxOuts == 0 || len(result.Unspents) != 1 {
+
+			t.Fatalf("unexpected scan result: %+v", result)
+		}
+		unspent := result.Unspents[0]
+		if unspent.TxID != txid.String() || unspent.Height != height ||
+			unspent.Amount != 1 || result.TotalAmount != 1 {
+
+			t.Fatalf("unexpected unspent output: %+v", unspent)
+		}
+	}
+
+	// A range beyond the child must not find the output.
+	result, err := r.Client.ScanTxOutSet([]btcjson.ScanObject{{
+		Desc:  "wpkh(" + xpub.String() + "/*)",
+		Range: &btcjson.DescriptorRange{Value: []int{8, 10}},
+	}})
+	if err != nil {
+		t.Fatalf("unable to scan utxo set: %v", err)
+	}
+	if len(result.Unspents) != 0 || result.TotalAmount != 0 {
+		t.Fatalf("unexpected scan result: %+v", result)
+	}
+}
+
+func testGetTxOutSetInfo(r *Harness, t *testing.T) {
+	// Create a second harness which maintains the utxo set statistics
+	// incrementally and sync it to the main harness.
+	harness, err := New(&chaincfg.SimNetParams, nil,
+		[]string{"--utxosetstats"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.SetUp(false, 0); err != nil {
+		t.Fatalf("unable to complete rpctest setup: %v", err)
+	}
+	defer harness.TearDown()
+
+	if err := ConnectNode(harness, r); err != nil {
+		t.Fatalf("unable to connect harnesses: %v", err)
+	}
+	if err := JoinNodes([]*Harness{r, harness}, Blocks); err != nil {
+		t.Fatalf("unable to join node on blocks: %v", err)
+	}
+
+	bestHash, height, err := r.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	info, err := r.Client.GetTxOutSetInfo()
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+	if info.BestBlock != *bestHash || info.Height != int64(height) ||
+		info.Transactions == 0 || info.TxOuts < info.Transactions ||
+		info.BogoSize == 0 || info.DiskSize == 0 ||
+		info.TotalAmount == 0 || info.HashSerialized == (chainhash.Hash{}) ||
+		info.MuHash != (chainhash.Hash{}) {
+
+		t.Fatalf("unexpected utxo set info: %+v", info)
+	}
+
+	// The utxo set of both harnesses is the same, so the serialized hashes
+	// must match.
+	otherInfo, err := harness.Client.GetTxOutSetInfo()
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+	if otherInfo.HashSerialized != info.HashSerialized {
+		t.Fatalf("serialized hashes of the same utxo set differ: %v "+
+			"and %v", otherInfo.HashSerialized, info.HashSerialized)
+	}
+
+	// The MuHash computed by scanning the utxo set must match the one
+	// maintained incrementally, which doesn't provide the number of
+	// transactions and the disk size.
+	muHashInfo, err := r.Client.GetTxOutSetInfoHashType(
+		btcjson.TxOutSetHashMuHash)
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+	otherMuHashInfo, err := harness.Client.GetTxOutSetInfoHashType(
+		btcjson.TxOutSetHashMuHash)
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+	if muHashInfo.MuHash == (chainhash.Hash{}) ||
+		muHashInfo.HashSerialized != (chainhash.Hash{}) ||
+		otherMuHashInfo.MuHash != muHashInfo.MuHash ||
+		otherMuHashInfo.TxOuts != info.TxOuts ||
+		otherMuHashInfo.BogoSize != info.BogoSize ||
+		otherMuHashInfo.TotalAmount != info.TotalAmount ||
+		otherMuHashInfo.Transactions != 0 || otherMuHashInfo.DiskSize != 0 {
+
+		t.Fatalf("unexpected utxo set info %+v, scanned %+v",
+			otherMuHashInfo, muHashInfo)
+	}
+
+	// Unknown hash types are rejected.
+	_, err = r.Client.GetTxOutSetInfoHashType("unknown")
+	if err == nil {
+		t.Fatalf("gettxoutsetinfo with unknown hash type did not " +
+			"return an error")
+	}
+}
+
+func testDumpTxOutSet(r *Harness, t *testing.T) {
+	bestHash, height, err := r.Client.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	info, err := r.Client.GetTxOutSetInfo()
+	if err != nil {
+		t.Fatalf("unable to get utxo set info: %v", err)
+	}
+
+	// The snapshot must contain the whole utxo set as of the best block.
+	path := filepath.Join(t.TempDir(), "utxo.dat")
+	result, err := r.Client.DumpTxOutSet(path)
+	if err != nil {
+		t.Fatalf("unable to dump utxo set: %v", err)
+	}
+	if result.Path != path || result.BaseHash != bestHash.String() ||
+		result.BaseHeight != height ||
+		result.CoinsWritten != uint64(info.TxOuts) ||
+		result.TxOutSetHash != info.HashSerialized.String() ||
+		result.NChainTx == 0 {
+
+		t.Fatalf("unexpected dumptxoutset result: %+v", result)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("unable to find utxo snapshot: %v", err)
+	}
+
+	// Existing files are not overwritten.
+	if _, err := r.Client.DumpTxOutSet(path); err == nil {
+		t.Fatalf("dumptxoutset overwrote an existing file")
+	}
+
+	// The chain was not bootstrapped from a snapshot, so there is only the
+	// chainstate of the best chain.
+	chainStates, err := r.Client.GetChainStates()
+	if err != nil {
+		t.Fatalf("unable to get chainstates: %v", err)
+	}
+	if chainStates.Headers != height || len(chainStates.ChainStates) != 1 {
+		t.Fatalf("unexpected getchainstates result: %+v", chainStates)
+	}
+	chainState := chainStates.ChainStates[0]
+	if chainState.Blocks != height ||
+		chainState.BestBlockHash != bestHash.String() ||
+		!chainState.Validated || chainState.SnapshotBlockHash != "" {
+
+		t.Fatalf("unexpected chainstate: %+v", chainState)
+	}
+}
+
+func testSaveMempool(r *Harness, t *testing.T) {
+	// The mempool is loaded in the background on startup, so wait for it
+	// to finish.
+	var loaded bool
+	for i := 0; i < 50 && !loaded; i++ {
+		info, err := r.Client.GetMempoolInfo()
+		if err != nil {
+			t.Fatalf("unable to get mempool info: %v", err)
+		}
+		loaded = info.Loaded
+		if !loaded {
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+	if !loaded {
+		t.Fatalf("mempool was not loaded")
+	}
+
+	// The mempool is saved to the data directory of the node.
+	path, err := r.Client.SaveMempool()
+	if err != nil {
+		t.Fatalf("unable to save mempool: %v", err)
+	}
+	if filepath.Base(path) != "mempool.dat" {
+		t.Fatalf("unexpected mempool file path %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("unable to find mempool file: %v", err)
+	}
+}
+
+func testTestMempoolAccept(r *Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to generate pkscript to addr: %v", err)
+	}
+	output := wire.NewTxOut(5e8, addrScript)
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	defer r.UnlockOutputs(tx.TxIn)
+
+	poolHashes, err := r.Client.GetRawMempool()
+	if err != nil {
+		t.Fatalf("unable to get mempool: %v", err)
+	}
+
+	// The transaction would be accepted with the default maximum fee
+	// rate.
+	results, err := r.Client.TestMempoolAccept([]*wire.MsgTx{tx}, 0.1)
+	if err != nil {
+		t.Fatalf("unable to test transaction: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("unexpected number of results %d", len(results))
+	}
+	result := results[0]
+	if !result.Allowed || result.TxID != tx.TxHash().String() ||
+		result.VSize == 0 || result.Fees == nil ||
+		result.Fees.Base <= 0 {
+
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// It is rejected when it pays more than the maximum fee rate.
+	results, err = r.Client.TestMempoolAccept([]*wire.MsgTx{tx}, 0.00001)
+	if err != nil {
+		t.Fatalf("unable to test transaction: %v", err)
+	}
+	if results[0].Allowed || results[0].RejectReason != "max-fee-exceeded" {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+
+	// Testing the transaction leaves the mempool untouched.
+	newPoolHashes, err := r.Client.GetRawMempool()
+	if err != nil {
+		t.Fatalf("unable to get mempool: %v", err)
+	}
+	if len(newPoolHashes) != len(poolHashes) {
+		t.Fatalf("mempool changed from %d to %d transactions",
+			len(poolHashes), len(newPoolHashes))
+	}
+
+	// Empty packages are rejected.
+	_, err = r.Client.TestMempoolAccept(nil, 0.1)
+	if err == nil {
+		t.Fatalf("empty package was tested")
+	}
+}
+
+func testGetMempoolEntry(r *Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	addrScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to generate pkscript to addr: %v", err)
+	}
+	output := wire.NewTxOut(5e8, addrScript)
+	txid, err := r.SendOutputs([]*wire.TxOut{output}, 10)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+
+	entry, err := r.Client.GetMempoolEntry(txid.String())
+	if err != nil {
+		t.Fatalf("unable to get mempool entry: %v", err)
+	}
+	if entry.VSize == 0 || entry.Fees.Base <= 0 ||
+		entry.Fees.Modified != entry.Fees.Base || entry.WTxId == "" ||
+		entry.AncestorCount != 1 || entry.DescendantCount != 1 {
+
+		t.Fatalf("unexpected mempool entry %+v", entry)
+	}
+
+	// The transaction only spends confirmed outputs and isn't spent yet.
+	ancestors, err := r.Client.GetMempoolAncestors(txid)
+	if err != nil {
+		t.Fatalf("unable to get mempool ancestors: %v", err)
+	}
+	if len(ancestors) != 0 {
+		t.Fatalf("unexpected mempool ancestors %v", ancestors)
+	}
+	descendants, err := r.Client.GetMempoolDescendantsVerbose(txid)
+	if err != nil {
+		t.Fatalf("unable to get mempool descendants: %v", err)
+	}
+	if len(descendants) != 0 {
+		t.Fatalf("unexpected mempool descendants %v", descendants)
+	}
+
+	// Transactions which are not in the mempool are rejected.
+	_, err = r.Client.GetMempoolAncestorsVerbose(&chainhash.Hash{0x01})
+	if err == nil {
+		t.Fatalf("got mempool ancestors of unknown transaction")
+	}
+
+	// Mine the transaction to leave the mempool empty for later tests.
+	if _, err := r.Client.Generate(1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+}
+
 var harnessTestCases = []HarnessTestCase{
 	testSendOutputs,
 	testConnectNode,
@@ -557,6 +1174,17 @@ var harnessTestCases = []HarnessTestCase{
 	testGenerateAndSubmitBlockWithCustomCoinbaseOutputs,
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testSyncCFilters,
+	testEstimateSmartFee,
+	testGetBlockStats,
+	testGetTxOutProof,
+	testDescriptors,
+	testScanTxOutSet,
+	testGetTxOutSetInfo,
+	testDumpTxOutSet,
+	testSaveMempool,
+	testTestMempoolAccept,
+	testGetMempoolEntry,
 }
 
 var mainHarness *Harness
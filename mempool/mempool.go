@@ -5,9 +5,11 @@
 package mempool
 
 import (
+	"container/heap"
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -48,6 +50,20 @@ const (
 	// can be evicted from the mempool when accepting a transaction
 	// replacement.
 	MaxReplacementEvictions = 100
+
+	// DefaultMaxPoolSize is the default maximum total serialized size in
+	// bytes of the transactions in the mempool.
+	DefaultMaxPoolSize = 300 * 1000 * 1000
+
+	// DefaultExpiry is the default maximum amount of time a transaction is
+	// kept in the mempool.
+	DefaultExpiry = time.Hour * 336
+
+	// rollingFeeHalfLife is the amount of time it takes for the rolling
+	// minimum fee rate, which is raised when transactions are evicted from
+	// a full mempool, to decay to half of its value.  The rate decays
+	// faster while the mempool is less than half full.
+	rollingFeeHalfLife = time.Hour * 12
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -102,7 +118,26 @@ type Config struct {
 
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
-	FeeEstimator *FeeEstimator
+	FeeEstimator *SmartFeeEstimator
+
+	// TxAdded defines the optional function to call when a transaction is
+	// added to the pool, including transactions that are added back after
+	// the block containing them was disconnected.
+	//
+	// It is called with the mempool lock held so the calls are made in the
+	// same order as the changes to the pool, which means it must not call
+	// back into the pool.
+	TxAdded func(tx *btcutil.Tx)
+
+	// TxRemoved defines the optional function to call when a transaction
+	// is removed from the pool for any reason other than being included in
+	// a block connected to the main chain, such as being replaced,
+	// conflicting with a block, expiring or being evicted.
+	//
+	// It is called with the mempool lock held so the calls are made in the
+	// same order as the changes to the pool, which means it must not call
+	// back into the pool.
+	TxRemoved func(tx *btcutil.Tx)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -148,6 +183,37 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// MaxAncestorCount is the maximum number of unconfirmed ancestors,
+	// including the transaction itself, a transaction may have in order
+	// to be accepted into the mempool.
+	MaxAncestorCount int64
+
+	// MaxAncestorSize is the maximum total virtual size of a transaction
+	// along with all of its unconfirmed ancestors.
+	MaxAncestorSize int64
+
+	// MaxDescendantCount is the maximum number of descendants in the
+	// mempool, including the transaction itself, any transaction in the
+	// mempool may have.  Transactions which would exceed the limit for
+	// any of their ancestors are rejected.
+	MaxDescendantCount int64
+
+	// MaxDescendantSize is the maximum total virtual size of any
+	// transaction in the mempool along with all of its descendants in the
+	// mempool.
+	MaxDescendantSize int64
+
+	// MaxPoolSize is the maximum total serialized size in bytes of the
+	// transactions in the mempool.  The transactions with the lowest
+	// descendant fee rates are evicted once it is exceeded.  A value of
+	// zero disables the limit.
+	MaxPoolSize int64
+
+	// Expiry is the maximum amount of time a transaction is kept in the
+	// mempool before it is removed by ExpireTransactions.  A value of
+	// zero disables the expiry.
+	Expiry time.Duration
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -158,6 +224,84 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// AncestorCount, AncestorSize and AncestorFees are the number, total
+	// virtual size and total modified fees of the transaction along with
+	// all of its ancestors in the pool.
+	AncestorCount int64
+	AncestorSize  int64
+	AncestorFees  int64
+
+	// DescendantCount, DescendantSize and DescendantFees are the number,
+	// total virtual size and total modified fees of the transaction along
+	// with all of its descendants in the pool.
+	DescendantCount int64
+	DescendantSize  int64
+	DescendantFees  int64
+
+	// evictIndex is the index of the transaction in the eviction queue of
+	// the pool.
+	evictIndex int
+}
+
+// evictionFeeRate returns the fee rate in satoshi per 1000 bytes the passed
+// transaction is evicted by when the pool is full, which is the fee rate of
+// its descendant package based on the modified fees.  Transactions paying a
+// higher fee rate than their descendants are scored by their own fee rate
+// instead since evicting their low fee descendants first frees more space.
+func evictionFeeRate(txD *TxDesc) float64 {
+	feeRate := float64(txD.DescendantFees) * 1000 /
+		float64(txD.DescendantSize)
+	ownFeeRate := float64(txD.ModifiedFee()) * 1000 /
+		float64(GetTxVirtualSize(txD.Tx))
+	if ownFeeRate > feeRate {
+		return ownFeeRate
+	}
+	return feeRate
+}
+
+// evictionQueue implements a priority queue of the transactions in the pool
+// ordered by their eviction fee rates with the lowest one first.  It keeps the
+// index of each transaction in its descriptor so that the position of the
+// transaction can be fixed when its package statistics change.
+type evictionQueue []*TxDesc
+
+// Len returns the number of transactions in the queue.  It is part of the
+// heap.Interface implementation.
+func (eq evictionQueue) Len() int {
+	return len(eq)
+}
+
+// Less returns whether the transaction at index i should be evicted before the
+// transaction at index j.  It is part of the heap.Interface implementation.
+func (eq evictionQueue) Less(i, j int) bool {
+	return evictionFeeRate(eq[i]) < evictionFeeRate(eq[j])
+}
+
+// Swap swaps the transactions at the passed indices in the queue.  It is part
+// of the heap.Interface implementation.
+func (eq evictionQueue) Swap(i, j int) {
+	eq[i], eq[j] = eq[j], eq[i]
+	eq[i].evictIndex = i
+	eq[j].evictIndex = j
+}
+
+// Push pushes the passed transaction descriptor onto the queue.  It is part of
+// the heap.Interface implementation.
+func (eq *evictionQueue) Push(x interface{}) {
+	txD := x.(*TxDesc)
+	txD.evictIndex = len(*eq)
+	*eq = append(*eq, txD)
+}
+
+// Pop removes the last transaction descriptor from the queue and returns it.
+// It is part of the heap.Interface implementation.
+func (eq *evictionQueue) Pop() interface{} {
+	n := len(*eq)
+	txD := (*eq)[n-1]
+	(*eq)[n-1] = nil
+	*eq = (*eq)[0 : n-1]
+	return txD
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -175,6 +319,7 @@ type orphanTx struct {
 type TxPool struct {
 	// The following variables must only be used atomically.
 	lastUpdated int64 // last time pool was updated
+	loaded      int32 // whether or not the persisted pool was loaded
 
 	mtx           sync.RWMutex
 	cfg           Config
@@ -185,6 +330,26 @@ type TxPool struct {
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
+	// totalSize is the total serialized size of the transactions in the
+	// pool.
+	totalSize int64
+
+	// evictQueue orders the transactions in the pool by the fee rates they
+	// are evicted by when the pool is full.
+	evictQueue evictionQueue
+
+	// feeDeltas houses the fee adjustments of transactions by their hash.
+	// They are persisted along with the pool and kept for transactions
+	// which are not in the pool.
+	feeDeltas map[chainhash.Hash]int64
+
+	// rollingMinFeeRate is the minimum fee rate in satoshi per 1000 bytes
+	// transactions must pay to be accepted after transactions have been
+	// evicted since the pool was full.  It decays over time starting at
+	// lastRollingFeeUpdate.
+	rollingMinFeeRate    float64
+	lastRollingFeeUpdate time.Time
+
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
@@ -479,20 +644,53 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 	}
 
 	// Remove the transaction if needed.
-	if txDesc, exists := mp.pool[*txHash]; exists {
-		// Remove unconfirmed address index entries associated with the
-		// transaction if enabled.
-		if mp.cfg.AddrIndex != nil {
-			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
-		}
+	if mp.removeFromPool(tx) && mp.cfg.TxRemoved != nil {
+		mp.cfg.TxRemoved(tx)
+	}
+}
 
-		// Mark the referenced outpoints as unspent by the pool.
-		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
-			delete(mp.outpoints, txIn.PreviousOutPoint)
-		}
-		delete(mp.pool, *txHash)
-		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+// removeFromPool removes the passed transaction from the pool along with all
+// of the state associated with it and returns whether or not the transaction
+// was in the pool.  Unlike removeTransaction, it does not remove the
+// transactions which redeem its outputs and does not call the TxRemoved
+// callback.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeFromPool(tx *btcutil.Tx) bool {
+	txHash := tx.Hash()
+	txDesc, exists := mp.pool[*txHash]
+	if !exists {
+		return false
+	}
+
+	// Remove unconfirmed address index entries associated with the
+	// transaction if enabled.
+	if mp.cfg.AddrIndex != nil {
+		mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
+	}
+
+	// Remove the transaction from the package statistics of its ancestors
+	// and descendants.
+	mp.removePackageStats(txDesc)
+	heap.Remove(&mp.evictQueue, txDesc.evictIndex)
+
+	// Mark the referenced outpoints as unspent by the pool.
+	for _, txIn := range txDesc.Tx.MsgTx().TxIn {
+		delete(mp.outpoints, txIn.PreviousOutPoint)
 	}
+	delete(mp.pool, *txHash)
+	mp.totalSize -= int64(txDesc.Tx.MsgTx().SerializeSize())
+	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+	// Stop tracking the transaction for fee estimation if enabled.
+	// Transactions that are included in a block have already been
+	// registered with the fee estimator along with the block at this
+	// point.
+	if mp.cfg.FeeEstimator != nil {
+		mp.cfg.FeeEstimator.RemoveTransaction(txHash)
+	}
+
+	return true
 }
 
 // RemoveTransaction removes the passed transaction from the mempool. When the
@@ -508,6 +706,20 @@ func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 	mp.mtx.Unlock()
 }
 
+// RemoveMinedTransaction removes the passed transaction, which was included in
+// a block connected to the main chain, from the mempool.  Transactions that
+// redeem outputs of the transaction are not removed since they are still
+// valid.  Unlike RemoveTransaction, the removal is not reported to the
+// TxRemoved callback since it is implied by the block.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) RemoveMinedTransaction(tx *btcutil.Tx) {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	mp.removeFromPool(tx)
+	mp.mtx.Unlock()
+}
+
 // RemoveDoubleSpends removes all transactions which spend outputs spent by the
 // passed transaction from the memory pool.  Removing those transactions then
 // leads to removing all transactions which rely on them, recursively.  This is
@@ -532,8 +744,12 @@ func (mp *TxPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
 //
+// The transaction is only recorded for fee estimation and reported through the
+// TxAdded callback when the notify flag is set.  It is not set for
+// transactions which were already in the pool before, such as restored ones.
+//
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil.Tx, height int32, fee int64) *TxDesc {
+func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil.Tx, height int32, fee int64, notify bool) *TxDesc {
 	// Add the transaction to the pool and mark the referenced outpoints
 	// as spent by the pool.
 	txD := &TxDesc{
@@ -543,30 +759,206 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
 
+	// Transactions that spend outputs of other mempool transactions are
+	// not used for fee estimation since their fee rate doesn't reflect
+	// the fee rate required for them to confirm.
+	hasPoolParents := false
+	for _, txIn := range tx.MsgTx().TxIn {
+		if _, exists := mp.pool[txIn.PreviousOutPoint.Hash]; exists {
+			hasPoolParents = true
+			break
+		}
+	}
+
 	mp.pool[*tx.Hash()] = txD
+	mp.totalSize += int64(tx.MsgTx().SerializeSize())
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
+	// Track the ancestor and descendant packages the transaction is part
+	// of.
+	mp.addPackageStats(txD)
+	heap.Push(&mp.evictQueue, txD)
+
 	// Add unconfirmed address index entries associated with the transaction
 	// if enabled.
 	if mp.cfg.AddrIndex != nil {
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
 
+	if !notify {
+		return txD
+	}
+
 	// Record this tx for fee estimation if enabled.
-	if mp.cfg.FeeEstimator != nil {
+	if mp.cfg.FeeEstimator != nil && !hasPoolParents {
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	if mp.cfg.TxAdded != nil {
+		mp.cfg.TxAdded(tx)
+	}
+
 	return txD
 }
 
+// minFeeRate returns the rolling minimum fee rate in satoshi per 1000 bytes
+// transactions must pay to be accepted into the pool after decaying it based
+// on the time since it was last updated.  It is zero when no transactions were
+// evicted recently.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) minFeeRate() float64 {
+	if mp.rollingMinFeeRate == 0 {
+		return 0
+	}
+
+	halfLife := rollingFeeHalfLife
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	switch {
+	case mp.totalSize < maxSize/4:
+		halfLife /= 4
+	case mp.totalSize < maxSize/2:
+		halfLife /= 2
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(mp.lastRollingFeeUpdate)
+	mp.rollingMinFeeRate /= math.Pow(2, elapsed.Seconds()/halfLife.Seconds())
+	mp.lastRollingFeeUpdate = now
+
+	// Stop requiring a minimum fee rate once it has decayed to a value
+	// that is insignificant compared to the minimum relay fee.
+	if mp.rollingMinFeeRate < float64(mp.cfg.Policy.MinRelayTxFee)/2 {
+		mp.rollingMinFeeRate = 0
+	}
+	return mp.rollingMinFeeRate
+}
+
+// trimToSize evicts the transactions with the lowest descendant fee rates,
+// based on their modified fees, along with their descendants until the total
+// size of the pool no longer exceeds the maximum size of the policy.  The
+// rolling minimum fee rate is raised above the fee rates of the evicted
+// packages so they are not replaced with transactions paying the same fee
+// rate.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) trimToSize() {
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize == 0 {
+		return
+	}
+
+	for mp.totalSize > maxSize {
+		// The transaction with the lowest eviction fee rate is at the
+		// front of the eviction queue.
+		worst := mp.evictQueue[0]
+		worstFeeRate := evictionFeeRate(worst)
+
+		newMinFeeRate := worstFeeRate +
+			float64(mp.cfg.Policy.MinRelayTxFee)
+		if newMinFeeRate > mp.minFeeRate() {
+			mp.rollingMinFeeRate = newMinFeeRate
+			mp.lastRollingFeeUpdate = time.Now()
+		}
+
+		log.Debugf("Evicting transaction %v (fee_rate=%v sat/kb) and "+
+			"its %d descendants since the mempool is full",
+			worst.Tx.Hash(), int64(worstFeeRate),
+			worst.DescendantCount-1)
+		mp.removeTransaction(worst.Tx, true)
+	}
+}
+
+// restoreReplaced adds the transactions described by the passed descriptors,
+// which were removed from the pool when a replacement that was evicted right
+// away was added, back to the pool.  Transactions whose inputs are no longer
+// available since their ancestors were evicted as well are not restored.
+//
+// The restored transactions are not reported through the TxAdded callback
+// since their removal was not reported through the TxRemoved callback either.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) restoreReplaced(replaced []*TxDesc) {
+	// Restore the transactions with fewer ancestors first so that each
+	// transaction is restored after its ancestors.
+	sort.Slice(replaced, func(i, j int) bool {
+		return replaced[i].AncestorCount < replaced[j].AncestorCount
+	})
+
+	for _, txD := range replaced {
+		utxoView, err := mp.fetchInputUtxos(txD.Tx)
+		if err != nil {
+			log.Debugf("Unable to restore replaced transaction %v: %v",
+				txD.Tx.Hash(), err)
+			continue
+		}
+
+		available := true
+		for _, txIn := range txD.Tx.MsgTx().TxIn {
+			entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+			if entry == nil || entry.IsSpent() ||
+				mp.outpoints[txIn.PreviousOutPoint] != nil {
+
+				available = false
+				break
+			}
+		}
+		if !available {
+			log.Debugf("Not restoring replaced transaction %v since "+
+				"its inputs are no longer available", txD.Tx.Hash())
+			continue
+		}
+
+		restored := mp.addTransaction(utxoView, txD.Tx, txD.Height,
+			txD.Fee, false)
+		restored.Added = txD.Added
+		restored.StartingPriority = txD.StartingPriority
+	}
+}
+
+// expireTransactions removes the transactions that were added to the pool
+// before the passed time along with all transactions which depend on them.  It
+// returns the number of removed transactions.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) expireTransactions(cutoff time.Time) int {
+	numBefore := len(mp.pool)
+	for _, txD := range mp.pool {
+		if txD.Added.Before(cutoff) {
+			mp.removeTransaction(txD.Tx, true)
+		}
+	}
+	return numBefore - len(mp.pool)
+}
+
+// ExpireTransactions removes the transactions which have been in the pool for
+// longer than the expiry time of the policy along with all transactions which
+// depend on them.  It is intended to be called periodically.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ExpireTransactions() {
+	if mp.cfg.Policy.Expiry == 0 {
+		return
+	}
+
+	mp.mtx.Lock()
+	numExpired := mp.expireTransactions(time.Now().Add(-mp.cfg.Policy.Expiry))
+	mp.mtx.Unlock()
+
+	if numExpired > 0 {
+		log.Debugf("Expired %d %s from the mempool", numExpired,
+			pickNoun(numExpired, "transaction", "transactions"))
+	}
+}
+
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
 // If it does, we'll check whether each of those transactions are signaling for
@@ -825,10 +1217,144 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
+// sumPackageStats returns the number, total virtual size and total modified
+// fees of the passed transaction along with the passed related transactions in
+// the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) sumPackageStats(txD *TxDesc,
+	related map[chainhash.Hash]*btcutil.Tx) (int64, int64, int64) {
+
+	count, size, fees := int64(1), GetTxVirtualSize(txD.Tx), txD.ModifiedFee()
+	for hash := range related {
+		relatedDesc := mp.pool[hash]
+		count++
+		size += GetTxVirtualSize(relatedDesc.Tx)
+		fees += relatedDesc.ModifiedFee()
+	}
+	return count, size, fees
+}
+
+// addPackageStats calculates the ancestor and descendant package statistics
+// of the passed transaction which was just added to the pool and updates those
+// of the transactions it is related to.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addPackageStats(txD *TxDesc) {
+	ancestors := mp.txAncestors(txD.Tx, nil)
+	descendants := mp.txDescendants(txD.Tx, nil)
+	txD.AncestorCount, txD.AncestorSize, txD.AncestorFees =
+		mp.sumPackageStats(txD, ancestors)
+	txD.DescendantCount, txD.DescendantSize, txD.DescendantFees =
+		mp.sumPackageStats(txD, descendants)
+
+	// The transaction usually doesn't have any descendants in the pool,
+	// so it simply joins the descendant packages of its ancestors.
+	if len(descendants) == 0 {
+		vsize := GetTxVirtualSize(txD.Tx)
+		for hash := range ancestors {
+			ancestor := mp.pool[hash]
+			ancestor.DescendantCount++
+			ancestor.DescendantSize += vsize
+			ancestor.DescendantFees += txD.ModifiedFee()
+			heap.Fix(&mp.evictQueue, ancestor.evictIndex)
+		}
+		return
+	}
+
+	// Otherwise, such as when a transaction is added back to the pool
+	// during a reorg, it links its ancestors with its descendants, so
+	// recalculate the packages of all of them.
+	for hash := range ancestors {
+		ancestor := mp.pool[hash]
+		ancestor.DescendantCount, ancestor.DescendantSize,
+			ancestor.DescendantFees = mp.sumPackageStats(ancestor,
+			mp.txDescendants(ancestor.Tx, nil))
+		heap.Fix(&mp.evictQueue, ancestor.evictIndex)
+	}
+	for hash := range descendants {
+		descendant := mp.pool[hash]
+		descendant.AncestorCount, descendant.AncestorSize,
+			descendant.AncestorFees = mp.sumPackageStats(descendant,
+			mp.txAncestors(descendant.Tx, nil))
+	}
+}
+
+// removePackageStats removes the passed transaction, which is about to be
+// removed from the pool, from the package statistics of its ancestors and
+// descendants.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removePackageStats(txD *TxDesc) {
+	vsize := GetTxVirtualSize(txD.Tx)
+	for hash := range mp.txAncestors(txD.Tx, nil) {
+		ancestor := mp.pool[hash]
+		ancestor.DescendantCount--
+		ancestor.DescendantSize -= vsize
+		ancestor.DescendantFees -= txD.ModifiedFee()
+		heap.Fix(&mp.evictQueue, ancestor.evictIndex)
+	}
+	for hash := range mp.txDescendants(txD.Tx, nil) {
+		descendant := mp.pool[hash]
+		descendant.AncestorCount--
+		descendant.AncestorSize -= vsize
+		descendant.AncestorFees -= txD.ModifiedFee()
+	}
+}
+
+// checkPackageLimits ensures that adding the passed transaction with the
+// passed virtual size to the pool would not exceed the ancestor and
+// descendant package limits of the policy, neither for the transaction itself
+// nor for any of its ancestors.  The optional package is taken into account as
+// if its transactions were already in the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *btcutil.Tx, vsize int64,
+	pkg *txPackage) error {
+
+	policy := &mp.cfg.Policy
+	ancestors := mp.packageTxAncestors(tx, pkg)
+	if int64(len(ancestors))+1 > policy.MaxAncestorCount {
+		str := fmt.Sprintf("transaction %v has too many unconfirmed "+
+			"ancestors [limit: %d]", tx.Hash(),
+			policy.MaxAncestorCount)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	ancestorSize := vsize
+	for hash := range ancestors {
+		ancestor := mp.packageTxDesc(hash, pkg)
+		ancestorSize += GetTxVirtualSize(ancestor.Tx)
+
+		if ancestor.DescendantCount+1 > policy.MaxDescendantCount {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"descendant count of unconfirmed transaction "+
+				"%v [limit: %d]", tx.Hash(), hash,
+				policy.MaxDescendantCount)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		if ancestor.DescendantSize+vsize > policy.MaxDescendantSize {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"descendant size of unconfirmed transaction "+
+				"%v [limit: %d]", tx.Hash(), hash,
+				policy.MaxDescendantSize)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+	if ancestorSize > policy.MaxAncestorSize {
+		str := fmt.Sprintf("transaction %v exceeds the ancestor size "+
+			"limit [limit: %d]", tx.Hash(), policy.MaxAncestorSize)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	return nil
+}
+
 // validateReplacement determines whether a transaction is deemed as a valid
 // replacement of all of its conflicts according to the RBF policy. If it is
 // valid, no error is returned. Otherwise, an error is returned indicating what
-// went wrong.
+// went wrong.  The fees of the replacement and the conflicting transactions
+// are adjusted by their fee deltas.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
@@ -866,20 +1392,22 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	// easy-to-reason about way to prevent DoS attacks via replacements.
 	var (
 		txSize           = GetTxVirtualSize(tx)
-		txFeeRate        = txFee * 1000 / txSize
+		modifiedFee      = txFee + mp.feeDeltas[*tx.Hash()]
+		txFeeRate        = modifiedFee * 1000 / txSize
 		conflictsFee     int64
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
+		conflictFee := mp.pool[hash].ModifiedFee()
+		conflictFeeRate := conflictFee * 1000 / GetTxVirtualSize(conflict)
+		if txFeeRate <= conflictFeeRate {
 			str := fmt.Sprintf("replacement transaction %v has an "+
 				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
-				txFeeRate)
+				"has %v", tx.Hash(), conflictFeeRate, txFeeRate)
 			return nil, txRuleError(wire.RejectInsufficientFee, str)
 		}
 
-		conflictsFee += mp.pool[hash].Fee
+		conflictsFee += conflictFee
 
 		// We'll track each conflict's parents to ensure the replacement
 		// isn't spending any new unconfirmed inputs.
@@ -892,10 +1420,10 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	// transactions it intends to replace and pay for its own bandwidth,
 	// which is determined by our minimum relay fee.
 	minFee := calcMinRequiredTxRelayFee(txSize, mp.cfg.Policy.MinRelayTxFee)
-	if txFee < conflictsFee+minFee {
+	if modifiedFee < conflictsFee+minFee {
 		str := fmt.Sprintf("replacement transaction %v has an "+
 			"insufficient absolute fee: needs %v, has %v",
-			tx.Hash(), conflictsFee+minFee, txFee)
+			tx.Hash(), conflictsFee+minFee, modifiedFee)
 		return nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
@@ -923,8 +1451,17 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 // MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
 // more details.
 //
+// When a package is passed, the transaction is only validated as if the
+// transactions of the package which were validated before were in the pool.
+// It is added to the package instead of the pool and nothing is relayed.
+//
+// The notify flag is passed on to addTransaction.  It is only unset for
+// transactions which are loaded from a persisted pool.
+//
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit,
+	rejectDupOrphans, notify bool, pkg *txPackage) ([]*chainhash.Hash, *TxDesc, error) {
+
 	txHash := tx.Hash()
 
 	// If a transaction has witness data, and segwit isn't active yet, If
@@ -1019,6 +1556,12 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	if err != nil {
 		return nil, nil, err
 	}
+	if pkg != nil {
+		err := pkg.checkDoubleSpend(tx, isReplacement)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Fetch all of the unspent transaction outputs referenced by the inputs
 	// to this transaction.  This function also attempts to fetch the
@@ -1031,6 +1574,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		}
 		return nil, nil, err
 	}
+	if pkg != nil {
+		pkg.addInputUtxos(tx, utxoView)
+	}
 
 	// Don't allow the transaction if it exists in the main chain and is
 	// already fully spent.
@@ -1155,6 +1701,20 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
+	// Don't allow transactions paying less than the rolling minimum fee
+	// rate which is raised when transactions are evicted from the pool
+	// since it is full.
+	if minFeeRate := mp.minFeeRate(); minFeeRate > 0 {
+		minPoolFee := int64(minFeeRate * float64(serializedSize) / 1000)
+		if txFee < minPoolFee {
+			str := fmt.Sprintf("transaction %v has %d fees which is "+
+				"under the mempool minimum fee of %d", txHash,
+				txFee, minPoolFee)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee,
+				str)
+		}
+	}
+
 	// Require that free transactions have sufficient priority to be mined
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
@@ -1204,6 +1764,15 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		}
 	}
 
+	// Don't allow the transaction to exceed the ancestor and descendant
+	// package limits.  They bound the size of the packages which have to
+	// be tracked by the pool and considered when selecting transactions
+	// for a block.
+	err = mp.checkPackageLimits(tx, serializedSize, pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Verify crypto signatures for each input and reject the transaction if
 	// any don't verify.
 	err = blockchain.ValidateTransactionScripts(tx, utxoView,
@@ -1216,10 +1785,24 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// The transaction is only added to the package when it is tested and
+	// doesn't exceed the maximum fee rate of the package.
+	if pkg != nil {
+		if err := pkg.checkMaxFeeRate(tx, txFee); err != nil {
+			return nil, nil, err
+		}
+		txD := mp.addPackageTx(pkg, utxoView, tx, bestHeight, txFee)
+		return nil, txD, nil
+	}
+
 	// Now that we've deemed the transaction as valid, we can add it to the
 	// mempool. If it ended up replacing any transactions, we'll remove them
-	// first.
+	// first.  Their descriptors are kept in case they have to be restored,
+	// so their removal is only reported once the replacement stays in the
+	// pool.
+	replaced := make([]*TxDesc, 0, len(conflicts))
 	for _, conflict := range conflicts {
+		replaced = append(replaced, mp.pool[*conflict.Hash()])
 		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
 			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
 			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
@@ -1228,9 +1811,25 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		// The conflict set should already include the descendants for
 		// each one, so we don't need to remove the redeemers within
 		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+		mp.removeFromPool(conflict)
+	}
+	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee, notify)
+
+	// Evict the transactions with the lowest fee rates if the pool has
+	// grown too large.  The transaction is rejected when it is evicted
+	// itself, in which case the transactions it replaced are restored.
+	mp.trimToSize()
+	if _, exists := mp.pool[*txHash]; !exists {
+		mp.restoreReplaced(replaced)
+		str := fmt.Sprintf("transaction %v has been rejected since the "+
+			"mempool is full", txHash)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+	if mp.cfg.TxRemoved != nil {
+		for _, txD := range replaced {
+			mp.cfg.TxRemoved(txD.Tx)
+		}
 	}
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
@@ -1252,7 +1851,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 func (mp *TxPool) MaybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true,
+		true, nil)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
@@ -1295,7 +1895,7 @@ func (mp *TxPool) processOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
 				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
+					tx, true, true, false, true, nil)
 				if err != nil {
 					// The orphan is now invalid, so there
 					// is no way any other orphans which
@@ -1379,7 +1979,7 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 
 	// Potentially accept the transaction to the memory pool.
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+		true, true, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1423,6 +2023,51 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 	return nil, err
 }
 
+// PrioritiseTransaction adds the passed fee delta in satoshi to the fee delta
+// of the transaction with the passed hash, which need not be in the pool yet.
+// The fee deltas adjust the fees of transactions when selecting them for
+// blocks, when validating replacements and when evicting transactions from a
+// full pool, while the fees actually paid remain unchanged.  They are kept
+// until they are changed back to zero.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(hash *chainhash.Hash, feeDelta int64) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	newFeeDelta := mp.feeDeltas[*hash] + feeDelta
+	if newFeeDelta == 0 {
+		delete(mp.feeDeltas, *hash)
+	} else {
+		mp.feeDeltas[*hash] = newFeeDelta
+	}
+
+	txD, exists := mp.pool[*hash]
+	if !exists {
+		return
+	}
+
+	// Update the modified fees of the packages the transaction is part of
+	// and mark the pool as updated so new block templates pick up the
+	// change.
+	txD.FeeDelta = newFeeDelta
+	txD.AncestorFees += feeDelta
+	txD.DescendantFees += feeDelta
+	heap.Fix(&mp.evictQueue, txD.evictIndex)
+	for ancestorHash := range mp.txAncestors(txD.Tx, nil) {
+		ancestor := mp.pool[ancestorHash]
+		ancestor.DescendantFees += feeDelta
+		heap.Fix(&mp.evictQueue, ancestor.evictIndex)
+	}
+	for descendantHash := range mp.txDescendants(txD.Tx, nil) {
+		mp.pool[descendantHash].AncestorFees += feeDelta
+	}
+	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+	log.Debugf("Prioritised transaction %v with fee delta %d", hash,
+		newFeeDelta)
+}
+
 // Count returns the number of transactions in the main pool.  It does not
 // include the orphan pool.
 //
@@ -1517,10 +2162,17 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 			Vsize:            int32(GetTxVirtualSize(tx)),
 			Weight:           int32(blockchain.GetTransactionWeight(tx)),
 			Fee:              btcutil.Amount(desc.Fee).ToBTC(),
+			ModifiedFee:      btcutil.Amount(desc.ModifiedFee()).ToBTC(),
 			Time:             desc.Added.Unix(),
 			Height:           int64(desc.Height),
 			StartingPriority: desc.StartingPriority,
 			CurrentPriority:  currentPriority,
+			DescendantCount:  desc.DescendantCount,
+			DescendantSize:   desc.DescendantSize,
+			DescendantFees:   btcutil.Amount(desc.DescendantFees).ToBTC(),
+			AncestorCount:    desc.AncestorCount,
+			AncestorSize:     desc.AncestorSize,
+			AncestorFees:     btcutil.Amount(desc.AncestorFees).ToBTC(),
 			Depends:          make([]string, 0),
 		}
 		for _, txIn := range tx.MsgTx().TxIn {
@@ -1537,6 +2189,159 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// mempoolEntry returns a verbose entry describing the passed transaction in the
+// pool along with its relationships to the other transactions in the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntry(txD *TxDesc) *btcjson.GetMempoolEntryResult {
+	tx := txD.Tx
+	entry := &btcjson.GetMempoolEntryResult{
+		VSize:           int32(GetTxVirtualSize(tx)),
+		Size:            int32(tx.MsgTx().SerializeSize()),
+		Weight:          blockchain.GetTransactionWeight(tx),
+		Fee:             btcutil.Amount(txD.Fee).ToBTC(),
+		ModifiedFee:     btcutil.Amount(txD.ModifiedFee()).ToBTC(),
+		Time:            txD.Added.Unix(),
+		Height:          int64(txD.Height),
+		DescendantCount: txD.DescendantCount,
+		DescendantSize:  txD.DescendantSize,
+		DescendantFees:  btcutil.Amount(txD.DescendantFees).ToBTC(),
+		AncestorCount:   txD.AncestorCount,
+		AncestorSize:    txD.AncestorSize,
+		AncestorFees:    btcutil.Amount(txD.AncestorFees).ToBTC(),
+		WTxId:           tx.WitnessHash().String(),
+		Fees: btcjson.MempoolFees{
+			Base:       btcutil.Amount(txD.Fee).ToBTC(),
+			Modified:   btcutil.Amount(txD.ModifiedFee()).ToBTC(),
+			Ancestor:   btcutil.Amount(txD.AncestorFees).ToBTC(),
+			Descendant: btcutil.Amount(txD.DescendantFees).ToBTC(),
+		},
+		Depends:           make([]string, 0),
+		SpentBy:           make([]string, 0),
+		BIP125Replaceable: mp.signalsReplacement(tx, nil),
+	}
+
+	// Record the unconfirmed parents of the transaction along with the
+	// transactions spending its outputs, each of them only once.
+	seen := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		if _, exists := mp.pool[hash]; exists {
+			entry.Depends = append(entry.Depends, hash.String())
+			seen[hash] = struct{}{}
+		}
+	}
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		spender, exists := mp.outpoints[prevOut]
+		if !exists {
+			continue
+		}
+		if _, ok := seen[*spender.Hash()]; ok {
+			continue
+		}
+		entry.SpentBy = append(entry.SpentBy, spender.Hash().String())
+		seen[*spender.Hash()] = struct{}{}
+	}
+
+	return entry
+}
+
+// MempoolEntry returns a verbose entry describing the transaction with the
+// passed hash in the main pool.  An error is returned when the transaction is
+// not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(hash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	txD, exists := mp.pool[*hash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	return mp.mempoolEntry(txD), nil
+}
+
+// mempoolEntries returns the verbose entries of the transactions related to the
+// transaction with the passed hash in the main pool as determined by the passed
+// function, keyed by their hashes.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) mempoolEntries(hash *chainhash.Hash,
+	related func(*btcutil.Tx) map[chainhash.Hash]*btcutil.Tx) (
+	map[string]*btcjson.GetMempoolEntryResult, error) {
+
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	txD, exists := mp.pool[*hash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+
+	relatedTxns := related(txD.Tx)
+	entries := make(map[string]*btcjson.GetMempoolEntryResult,
+		len(relatedTxns))
+	for relatedHash := range relatedTxns {
+		entries[relatedHash.String()] = mp.mempoolEntry(
+			mp.pool[relatedHash])
+	}
+	return entries, nil
+}
+
+// MempoolAncestors returns the verbose entries of all of the unconfirmed
+// ancestors in the main pool of the transaction with the passed hash, keyed by
+// their hashes.  An error is returned when the transaction is not in the main
+// pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolAncestors(hash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	return mp.mempoolEntries(hash, func(tx *btcutil.Tx) map[chainhash.Hash]*btcutil.Tx {
+		return mp.txAncestors(tx, nil)
+	})
+}
+
+// MempoolDescendants returns the verbose entries of all of the descendants in
+// the main pool of the transaction with the passed hash, keyed by their
+// hashes.  An error is returned when the transaction is not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolDescendants(hash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	return mp.mempoolEntries(hash, func(tx *btcutil.Tx) map[chainhash.Hash]*btcutil.Tx {
+		return mp.txDescendants(tx, nil)
+	})
+}
+
+// Usage returns the total serialized size in bytes of the transactions in the
+// main pool.  It does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Usage() int64 {
+	mp.mtx.RLock()
+	totalSize := mp.totalSize
+	mp.mtx.RUnlock()
+
+	return totalSize
+}
+
+// MinFeeRate returns the minimum fee rate per kilobyte transactions must pay
+// to be accepted into the main pool since transactions were evicted from it.
+// It is zero unless the pool was full recently.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeRate() btcutil.Amount {
+	mp.mtx.Lock()
+	minFeeRate := mp.minFeeRate()
+	mp.mtx.Unlock()
+
+	return btcutil.Amount(minFeeRate)
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1555,5 +2360,6 @@ func New(cfg *Config) *TxPool {
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*btcutil.Tx),
+		feeDeltas:      make(map[chainhash.Hash]int64),
 	}
 }
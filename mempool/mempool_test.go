@@ -14,6 +14,7 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -324,6 +325,10 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 				MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 				MinRelayTxFee:        1000, // 1 Satoshi per byte
 				MaxTxVersion:         1,
+				MaxAncestorCount:     DefaultMaxAncestorCount,
+				MaxAncestorSize:      DefaultMaxAncestorSize,
+				MaxDescendantCount:   DefaultMaxDescendantCount,
+				MaxDescendantSize:    DefaultMaxDescendantSize,
 			},
 			ChainParams:      chainParams,
 			FetchUtxoView:    chain.FetchUtxoView,
@@ -1452,6 +1457,409 @@ func TestAncestorsDescendants(t *testing.T) {
 	}
 }
 
+// TestPackageStats ensures the ancestor and descendant package statistics of
+// the transactions in the mempool are tracked as transactions are added and
+// removed.
+func TestPackageStats(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	// We'll be creating the following chain of unconfirmed transactions
+	// where B and C spend A and D spends B and C:
+	//
+	//       B
+	//     /   \
+	//   A       D
+	//     \   /
+	//       C
+	const fee = 1000
+	a := ctx.addSignedTx(outputs[:1], 2, fee, false, false)
+	b := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(a, 0)}, 1, 2*fee,
+		false, false,
+	)
+	c := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(a, 1)}, 1, 3*fee,
+		false, false,
+	)
+	d := ctx.addSignedTx(
+		[]spendableOutput{
+			txOutToSpendableOut(b, 0), txOutToSpendableOut(c, 0),
+		}, 1, 4*fee, false, false,
+	)
+
+	// testStats ensures the package statistics of the transaction match
+	// the ones of the passed transactions.
+	testStats := func(tx *btcutil.Tx, ancestors, descendants []*btcutil.Tx) {
+		t.Helper()
+
+		sum := func(txns []*btcutil.Tx) (int64, int64, int64) {
+			var size, fees int64
+			for _, tx := range txns {
+				txD := harness.txPool.pool[*tx.Hash()]
+				size += GetTxVirtualSize(tx)
+				fees += txD.Fee
+			}
+			return int64(len(txns)), size, fees
+		}
+
+		txD := harness.txPool.pool[*tx.Hash()]
+		count, size, fees := sum(ancestors)
+		if txD.AncestorCount != count || txD.AncestorSize != size ||
+			txD.AncestorFees != fees {
+
+			t.Fatalf("unexpected ancestor stats %d/%d/%d, want "+
+				"%d/%d/%d", txD.AncestorCount, txD.AncestorSize,
+				txD.AncestorFees, count, size, fees)
+		}
+		count, size, fees = sum(descendants)
+		if txD.DescendantCount != count ||
+			txD.DescendantSize != size ||
+			txD.DescendantFees != fees {
+
+			t.Fatalf("unexpected descendant stats %d/%d/%d, want "+
+				"%d/%d/%d", txD.DescendantCount,
+				txD.DescendantSize, txD.DescendantFees, count,
+				size, fees)
+		}
+	}
+
+	all := []*btcutil.Tx{a, b, c, d}
+	testStats(a, []*btcutil.Tx{a}, all)
+	testStats(b, []*btcutil.Tx{a, b}, []*btcutil.Tx{b, d})
+	testStats(c, []*btcutil.Tx{a, c}, []*btcutil.Tx{c, d})
+	testStats(d, all, []*btcutil.Tx{d})
+
+	// Removing A as if it was mined leaves its descendants in the pool
+	// without it as an ancestor.
+	harness.txPool.RemoveTransaction(a, false)
+	testStats(b, []*btcutil.Tx{b}, []*btcutil.Tx{b, d})
+	testStats(d, []*btcutil.Tx{b, c, d}, []*btcutil.Tx{d})
+
+	// Removing C along with its redeemers removes D as a descendant of B.
+	harness.txPool.RemoveTransaction(c, true)
+	testStats(b, []*btcutil.Tx{b}, []*btcutil.Tx{b})
+}
+
+// TestPackageLimits ensures transactions which would exceed the ancestor and
+// descendant package limits are rejected.
+func TestPackageLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	// A chain of transactions is accepted up to the maximum number of
+	// ancestors.
+	chainedTxns, err := harness.CreateTxChain(outputs[0],
+		DefaultMaxAncestorCount+1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns[:DefaultMaxAncestorCount] {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	tx := chainedTxns[DefaultMaxAncestorCount]
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "too many unconfirmed "+
+		"ancestors") {
+
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, false)
+
+	// Once the first transaction of the chain is mined, the final one is
+	// accepted.
+	harness.txPool.RemoveTransaction(chainedTxns[0], false)
+	harness.chain.utxos.AddTxOuts(chainedTxns[0], harness.chain.BestHeight())
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// A transaction can't have more descendants than the maximum number,
+	// which includes the transaction itself.
+	harness.txPool.cfg.Policy.MaxDescendantCount = 3
+	coinbase := ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 3, 0, false, false)
+	for i := uint32(0); i < 2; i++ {
+		ctx.addSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, i),
+		}, 1, 0, false, false)
+	}
+	tx, err = harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 2),
+	}, 1, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "descendant count") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// Transactions exceeding the size limits are rejected as well.
+	harness.txPool.cfg.Policy.MaxDescendantCount = DefaultMaxDescendantCount
+	harness.txPool.cfg.Policy.MaxDescendantSize = 3 * GetTxVirtualSize(tx)
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "descendant size") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	harness.txPool.cfg.Policy.MaxDescendantSize = DefaultMaxDescendantSize
+	harness.txPool.cfg.Policy.MaxAncestorSize = GetTxVirtualSize(tx)
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "ancestor size") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+}
+
+// TestPoolSizeLimit ensures the transactions with the lowest fee rates are
+// evicted once the mempool exceeds its maximum size and that the minimum fee
+// rate for new transactions is raised accordingly.
+func TestPoolSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Fill the pool with three transactions paying different fees and
+	// limit its size to them.
+	coinbase := ctx.addCoinbaseTx(6)
+	spend := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+	low := ctx.addSignedTx(spend(0), 1, 1000, false, false)
+	mid := ctx.addSignedTx(spend(1), 1, 2000, false, false)
+	high := ctx.addSignedTx(spend(2), 1, 3000, false, false)
+	if txPool.Usage() == 0 || txPool.MinFeeRate() != 0 {
+		t.Fatalf("unexpected usage %d and minimum fee rate %v",
+			txPool.Usage(), txPool.MinFeeRate())
+	}
+	txPool.cfg.Policy.MaxPoolSize = txPool.Usage()
+
+	// Adding another transaction evicts the one paying the lowest fee
+	// rate and raises the minimum fee rate above it.
+	ctx.addSignedTx(spend(3), 1, 4000, false, false)
+	testPoolMembership(ctx, low, false, false)
+	testPoolMembership(ctx, mid, false, true)
+	testPoolMembership(ctx, high, false, true)
+	lowFeeRate := 1000 * 1000 / GetTxVirtualSize(low)
+	minFeeRate := int64(txPool.MinFeeRate())
+	if minFeeRate <= lowFeeRate {
+		t.Fatalf("minimum fee rate %d is not above the evicted fee "+
+			"rate %d", minFeeRate, lowFeeRate)
+	}
+
+	// Transactions paying less than the minimum fee rate are rejected.
+	tx, err := harness.CreateSignedTx(spend(4), 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool minimum fee") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// Transactions paying the minimum fee rate are still rejected when
+	// they would be evicted right away.
+	fee := btcutil.Amount((minFeeRate + 100) * GetTxVirtualSize(tx) / 1000)
+	tx, err = harness.CreateSignedTx(spend(5), 1, fee, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, false)
+	if txPool.Usage() > txPool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("usage %d exceeds the maximum pool size %d",
+			txPool.Usage(), txPool.cfg.Policy.MaxPoolSize)
+	}
+}
+
+// TestEvictedReplacement ensures the transactions replaced by a transaction
+// which is evicted right away since the pool is full are restored without
+// being reported as removed and added again.
+func TestEvictedReplacement(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Fill the pool with a replaceable transaction paying a low fee along
+	// with a child and other transactions paying high fees, and limit its
+	// size to them.
+	coinbase := ctx.addCoinbaseTx(3)
+	spend := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+	replaced := ctx.addSignedTx(spend(0), 1, 1000, true, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(replaced, 0),
+	}, 1, 1000, false, false)
+	high1 := ctx.addSignedTx(spend(1), 1, 50000, false, false)
+	high2 := ctx.addSignedTx(spend(2), 1, 50000, false, false)
+	txPool.cfg.Policy.MaxPoolSize = txPool.Usage()
+
+	var added, removed []chainhash.Hash
+	txPool.cfg.TxAdded = func(tx *btcutil.Tx) {
+		added = append(added, *tx.Hash())
+	}
+	txPool.cfg.TxRemoved = func(tx *btcutil.Tx) {
+		removed = append(removed, *tx.Hash())
+	}
+
+	// The replacement is larger than the transactions it replaces while
+	// paying the lowest fee rate, so it is evicted right away.
+	tx, err := harness.CreateSignedTx(spend(0), 10, 5000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+
+	// The replaced transactions must be back in the pool along with their
+	// package statistics.
+	testPoolMembership(ctx, tx, false, false)
+	testPoolMembership(ctx, replaced, false, true)
+	testPoolMembership(ctx, child, false, true)
+	testPoolMembership(ctx, high1, false, true)
+	testPoolMembership(ctx, high2, false, true)
+	if got := txPool.pool[*replaced.Hash()].DescendantCount; got != 2 {
+		t.Fatalf("unexpected descendant count of restored "+
+			"transaction: got %d, want 2", got)
+	}
+	if got := txPool.pool[*child.Hash()].AncestorCount; got != 2 {
+		t.Fatalf("unexpected ancestor count of restored "+
+			"transaction: got %d, want 2", got)
+	}
+	if txPool.Usage() > txPool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("usage %d exceeds the maximum pool size %d",
+			txPool.Usage(), txPool.cfg.Policy.MaxPoolSize)
+	}
+	if len(txPool.evictQueue) != len(txPool.pool) {
+		t.Fatalf("eviction queue has %d transactions while the pool "+
+			"has %d", len(txPool.evictQueue), len(txPool.pool))
+	}
+
+	// Only the replacement is reported as added and removed since the
+	// replaced transactions never left the pool as far as the callbacks
+	// are concerned.
+	want := []chainhash.Hash{*tx.Hash()}
+	if !reflect.DeepEqual(added, want) {
+		t.Fatalf("unexpected added transactions: got %v, want %v",
+			added, want)
+	}
+	if !reflect.DeepEqual(removed, want) {
+		t.Fatalf("unexpected removed transactions: got %v, want %v",
+			removed, want)
+	}
+}
+
+// TestExpireTransactions ensures transactions which have been in the mempool
+// for longer than the expiry time are removed along with their descendants.
+func TestExpireTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+	txPool.cfg.Policy.Expiry = time.Hour
+
+	parent := ctx.addSignedTx(outputs, 1, 0, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 0, false, false)
+	coinbase := ctx.addCoinbaseTx(1)
+	other := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 0, false, false)
+
+	// Nothing expires until the parent is older than the expiry time.
+	txPool.ExpireTransactions()
+	testPoolMembership(ctx, parent, false, true)
+
+	txPool.pool[*parent.Hash()].Added = time.Now().Add(-2 * time.Hour)
+	txPool.ExpireTransactions()
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+	testPoolMembership(ctx, other, false, true)
+}
+
+// TestTxAddedRemovedCallbacks ensures the TxAdded and TxRemoved callbacks are
+// called as transactions enter and leave the pool and that transactions
+// removed for being mined are not reported.
+func TestTxAddedRemovedCallbacks(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	var added, removed []chainhash.Hash
+	txPool.cfg.TxAdded = func(tx *btcutil.Tx) {
+		added = append(added, *tx.Hash())
+	}
+	txPool.cfg.TxRemoved = func(tx *btcutil.Tx) {
+		removed = append(removed, *tx.Hash())
+	}
+
+	parent := ctx.addSignedTx(outputs, 1, 0, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 0, false, false)
+	wantAdded := []chainhash.Hash{*parent.Hash(), *child.Hash()}
+	if !reflect.DeepEqual(added, wantAdded) {
+		t.Fatalf("unexpected added transactions: got %v, want %v",
+			added, wantAdded)
+	}
+
+	// Removing a mined transaction must not be reported, while removing
+	// its spender must be.
+	txPool.RemoveMinedTransaction(parent)
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, true)
+	if len(removed) != 0 {
+		t.Fatalf("unexpected removed transactions: %v", removed)
+	}
+	txPool.RemoveTransaction(child, true)
+	wantRemoved := []chainhash.Hash{*child.Hash()}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Fatalf("unexpected removed transactions: got %v, want %v",
+			removed, wantRemoved)
+	}
+}
+
 // TestRBF tests the different cases required for a transaction to properly
 // replace its conflicts given that they all signal replacement.
 func TestRBF(t *testing.T) {
@@ -1531,6 +1939,12 @@ func TestRBF(t *testing.T) {
 			name: "exceeds maximum conflicts",
 			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
 				const numDescendants = 100
+
+				// The parent must be allowed to have more
+				// descendants than the default policy allows.
+				policy := &ctx.harness.txPool.cfg.Policy
+				policy.MaxDescendantCount = numDescendants + 1
+
 				coinbaseOuts := make(
 					[]spendableOutput, numDescendants,
 				)
@@ -1851,3 +2265,245 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestPrioritiseTransaction ensures fee deltas adjust the package statistics
+// of transactions along with their mining descriptors and are taken into
+// account when validating replacements and evicting transactions.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	coinbase := ctx.addCoinbaseTx(6)
+	spend := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+
+	// Fee deltas of transactions which are not in the pool yet are
+	// applied once they are added.
+	parent, err := harness.CreateSignedTx(spend(0), 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	txPool.PrioritiseTransaction(parent.Hash(), 5000)
+	_, err = txPool.ProcessTransaction(parent, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 2000, false, false)
+	parentDesc := txPool.pool[*parent.Hash()]
+	childDesc := txPool.pool[*child.Hash()]
+	if parentDesc.Fee != 1000 || parentDesc.ModifiedFee() != 6000 {
+		t.Fatalf("unexpected fee %d and modified fee %d",
+			parentDesc.Fee, parentDesc.ModifiedFee())
+	}
+	if parentDesc.DescendantFees != 8000 || childDesc.AncestorFees != 8000 {
+		t.Fatalf("unexpected descendant fees %d and ancestor fees %d",
+			parentDesc.DescendantFees, childDesc.AncestorFees)
+	}
+
+	// Fee deltas of transactions in the pool are cumulative and update
+	// the packages the transaction is part of.
+	txPool.PrioritiseTransaction(child.Hash(), 500)
+	txPool.PrioritiseTransaction(child.Hash(), 500)
+	if childDesc.FeeDelta != 1000 || childDesc.AncestorFees != 9000 ||
+		childDesc.DescendantFees != 3000 ||
+		parentDesc.DescendantFees != 9000 {
+
+		t.Fatalf("unexpected fee delta %d and package fees %d/%d/%d",
+			childDesc.FeeDelta, childDesc.AncestorFees,
+			childDesc.DescendantFees, parentDesc.DescendantFees)
+	}
+	for _, miningDesc := range txPool.MiningDescs() {
+		want := txPool.pool[*miningDesc.Tx.Hash()].FeeDelta
+		if miningDesc.FeeDelta != want {
+			t.Fatalf("unexpected mining fee delta %d, want %d",
+				miningDesc.FeeDelta, want)
+		}
+	}
+
+	// Fee deltas which are changed back to zero are removed.
+	txPool.PrioritiseTransaction(child.Hash(), -1000)
+	if _, exists := txPool.feeDeltas[*child.Hash()]; exists {
+		t.Fatalf("fee delta of zero was kept")
+	}
+	if childDesc.AncestorFees != 8000 || parentDesc.DescendantFees != 8000 {
+		t.Fatalf("unexpected ancestor fees %d and descendant fees %d",
+			childDesc.AncestorFees, parentDesc.DescendantFees)
+	}
+
+	// Replacements must pay more than the modified fees of the
+	// transactions they replace and their own fee delta is applied.
+	replaceable := ctx.addSignedTx(spend(1), 1, 1000, true, false)
+	txPool.PrioritiseTransaction(replaceable.Hash(), 10000)
+	replacement, err := harness.CreateSignedTx(spend(1), 1, 5000, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "insufficient") {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	txPool.PrioritiseTransaction(replacement.Hash(), 10000)
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(ctx, replaceable, false, false)
+
+	// Transactions are evicted based on their modified fees.
+	low := ctx.addSignedTx(spend(2), 1, 1000, false, false)
+	txPool.PrioritiseTransaction(low.Hash(), 100000)
+	mid := ctx.addSignedTx(spend(3), 1, 1500, false, false)
+	txPool.cfg.Policy.MaxPoolSize = txPool.Usage()
+	ctx.addSignedTx(spend(4), 1, 4000, false, false)
+	testPoolMembership(ctx, low, false, true)
+	testPoolMembership(ctx, mid, false, false)
+}
+
+// TestMempoolEntries ensures the verbose entries of transactions in the pool
+// along with their ancestors and descendants are reported correctly.
+func TestMempoolEntries(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Create a parent with two outputs, a replaceable child spending both
+	// of them and a grandchild spending the child.
+	coinbase := ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 2, 1000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+		txOutToSpendableOut(parent, 1),
+	}, 1, 2000, true, false)
+	grandchild := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(child, 0),
+	}, 1, 3000, false, false)
+
+	entry, err := txPool.MempoolEntry(child.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve mempool entry: %v", err)
+	}
+	if !reflect.DeepEqual(entry.Depends, []string{parent.Hash().String()}) {
+		t.Fatalf("unexpected depends %v", entry.Depends)
+	}
+	if !reflect.DeepEqual(entry.SpentBy,
+		[]string{grandchild.Hash().String()}) {
+
+		t.Fatalf("unexpected spentby %v", entry.SpentBy)
+	}
+	if entry.WTxId != child.WitnessHash().String() {
+		t.Fatalf("unexpected wtxid %v", entry.WTxId)
+	}
+	if !entry.BIP125Replaceable {
+		t.Fatal("child is not reported as replaceable")
+	}
+	if entry.VSize != int32(GetTxVirtualSize(child)) {
+		t.Fatalf("unexpected vsize %d", entry.VSize)
+	}
+	wantFees := btcjson.MempoolFees{
+		Base:       btcutil.Amount(2000).ToBTC(),
+		Modified:   btcutil.Amount(2000).ToBTC(),
+		Ancestor:   btcutil.Amount(3000).ToBTC(),
+		Descendant: btcutil.Amount(5000).ToBTC(),
+	}
+	if entry.Fees != wantFees {
+		t.Fatalf("unexpected fees %+v, want %+v", entry.Fees, wantFees)
+	}
+	if entry.AncestorCount != 2 || entry.DescendantCount != 2 {
+		t.Fatalf("unexpected ancestor/descendant count %d/%d",
+			entry.AncestorCount, entry.DescendantCount)
+	}
+
+	// The grandchild inherits the replaceability of its ancestor.
+	entry, err = txPool.MempoolEntry(grandchild.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve mempool entry: %v", err)
+	}
+	if !entry.BIP125Replaceable {
+		t.Fatal("grandchild is not reported as replaceable")
+	}
+	if len(entry.SpentBy) != 0 {
+		t.Fatalf("unexpected spentby %v", entry.SpentBy)
+	}
+
+	// The parent neither signals replacement itself nor has unconfirmed
+	// ancestors signaling it.
+	entry, err = txPool.MempoolEntry(parent.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve mempool entry: %v", err)
+	}
+	if entry.BIP125Replaceable {
+		t.Fatal("parent is reported as replaceable")
+	}
+	if len(entry.Depends) != 0 {
+		t.Fatalf("unexpected depends %v", entry.Depends)
+	}
+
+	// checkEntries ensures the passed entries are for exactly the passed
+	// transactions.
+	checkEntries := func(entries map[string]*btcjson.GetMempoolEntryResult,
+		txns ...*btcutil.Tx) {
+
+		t.Helper()
+
+		if len(entries) != len(txns) {
+			t.Fatalf("unexpected number of entries %d, want %d",
+				len(entries), len(txns))
+		}
+		for _, tx := range txns {
+			if _, ok := entries[tx.Hash().String()]; !ok {
+				t.Fatalf("missing entry for transaction %v",
+					tx.Hash())
+			}
+		}
+	}
+
+	ancestors, err := txPool.MempoolAncestors(grandchild.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve ancestors: %v", err)
+	}
+	checkEntries(ancestors, parent, child)
+	ancestors, err = txPool.MempoolAncestors(parent.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve ancestors: %v", err)
+	}
+	checkEntries(ancestors)
+
+	descendants, err := txPool.MempoolDescendants(parent.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve descendants: %v", err)
+	}
+	checkEntries(descendants, child, grandchild)
+	descendants, err = txPool.MempoolDescendants(child.Hash())
+	if err != nil {
+		t.Fatalf("unable to retrieve descendants: %v", err)
+	}
+	checkEntries(descendants, grandchild)
+
+	// Transactions which are not in the pool are rejected.
+	unknown := chainhash.Hash{0x01}
+	if _, err := txPool.MempoolEntry(&unknown); err == nil {
+		t.Fatal("MempoolEntry succeeded for unknown transaction")
+	}
+	if _, err := txPool.MempoolAncestors(&unknown); err == nil {
+		t.Fatal("MempoolAncestors succeeded for unknown transaction")
+	}
+	if _, err := txPool.MempoolDescendants(&unknown); err == nil {
+		t.Fatal("MempoolDescendants succeeded for unknown transaction")
+	}
+}
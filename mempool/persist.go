@@ -0,0 +1,308 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// mempoolDumpVersion is the version of the format the mempool is
+	// persisted in.
+	mempoolDumpVersion = 1
+
+	// maxPersistedFeeDeltas is the maximum number of fee deltas of
+	// transactions which are not in the pool that are loaded.  It prevents
+	// a corrupt file from causing excessive memory allocations.
+	maxPersistedFeeDeltas = 1000000
+
+	// maxPersistedUnbroadcast is the maximum number of unbroadcast
+	// transaction hashes that are read.  It prevents a corrupt file from
+	// causing excessive memory allocations.
+	maxPersistedUnbroadcast = 1000000
+)
+
+// errLoadInterrupted is returned by Load when it is interrupted.
+var errLoadInterrupted = errors.New("loading the mempool was interrupted")
+
+// persistedTx houses the details of a transaction in the pool which are
+// persisted.
+type persistedTx struct {
+	tx       *btcutil.Tx
+	added    time.Time
+	feeDelta int64
+}
+
+// Save writes the transactions in the main pool, along with the time they were
+// added and their fee deltas, to the passed writer so they can be loaded by
+// Load after a restart.  The fee deltas of transactions which are not in the
+// pool are written as well.  It does not include the orphan pool.
+//
+// The format is compatible with version 1 of the mempool.dat file of bitcoind
+// and all integers are little endian:
+//
+//	version (uint64), number of transactions (uint64)
+//	for each transaction:
+//	  transaction including witness data, time added in unix seconds (int64),
+//	  fee delta in satoshi (int64)
+//	number of remaining fee deltas (varint)
+//	for each remaining fee delta: transaction hash, fee delta (int64)
+//	number of unbroadcast transactions (varint)
+//	for each unbroadcast transaction: transaction hash
+//
+// The set of unbroadcast transactions is always empty since the pool doesn't
+// track whether transactions have been announced to peers.
+//
+// The transactions are ordered such that each transaction follows all of its
+// ancestors in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Save(w io.Writer) error {
+	mp.mtx.RLock()
+	txns := make([]persistedTx, 0, len(mp.pool))
+	for hash, txD := range mp.pool {
+		txns = append(txns, persistedTx{
+			tx:       txD.Tx,
+			added:    txD.Added,
+			feeDelta: mp.feeDeltas[hash],
+		})
+	}
+	feeDeltas := make(map[chainhash.Hash]int64, len(mp.feeDeltas))
+	for hash, feeDelta := range mp.feeDeltas {
+		if _, exists := mp.pool[hash]; !exists {
+			feeDeltas[hash] = feeDelta
+		}
+	}
+	ancestorCounts := make(map[chainhash.Hash]int64, len(mp.pool))
+	for hash, txD := range mp.pool {
+		ancestorCounts[hash] = txD.AncestorCount
+	}
+	mp.mtx.RUnlock()
+
+	// A transaction always has more ancestors in the pool than any of its
+	// ancestors, so ordering them by the number of ancestors ensures the
+	// transactions can be loaded in order.
+	sort.Slice(txns, func(i, j int) bool {
+		return ancestorCounts[*txns[i].tx.Hash()] <
+			ancestorCounts[*txns[j].tx.Hash()]
+	})
+
+	var buf [8]byte
+	writeUint64 := func(n uint64) error {
+		binary.LittleEndian.PutUint64(buf[:], n)
+		_, err := w.Write(buf[:])
+		return err
+	}
+	if err := writeUint64(mempoolDumpVersion); err != nil {
+		return err
+	}
+	if err := writeUint64(uint64(len(txns))); err != nil {
+		return err
+	}
+	for _, ptx := range txns {
+		if err := ptx.tx.MsgTx().Serialize(w); err != nil {
+			return err
+		}
+		if err := writeUint64(uint64(ptx.added.Unix())); err != nil {
+			return err
+		}
+		if err := writeUint64(uint64(ptx.feeDelta)); err != nil {
+			return err
+		}
+	}
+
+	err := wire.WriteVarInt(w, 0, uint64(len(feeDeltas)))
+	if err != nil {
+		return err
+	}
+	for hash, feeDelta := range feeDeltas {
+		if _, err := w.Write(hash[:]); err != nil {
+			return err
+		}
+		if err := writeUint64(uint64(feeDelta)); err != nil {
+			return err
+		}
+	}
+
+	// Write an empty set of unbroadcast transactions.
+	return wire.WriteVarInt(w, 0, 0)
+}
+
+// Load reads transactions written by Save from the passed reader and processes
+// them as if they were newly received, except that they keep the time they
+// were originally added to the pool, are not rate limited, and are neither
+// recorded for fee estimation nor reported through the TxAdded callback since
+// they are not new.  Transactions which were added longer ago than the expiry
+// time of the policy are skipped.  Transactions which are rejected, including
+// ones whose parents were rejected, are logged and skipped, so the pool only
+// contains transactions which are valid as of the current best chain.  The fee
+// deltas are restored as well, while the set of unbroadcast transactions is
+// read and ignored.
+//
+// Loading is stopped with an error when the passed interrupt channel is
+// closed.  Note that Load does not mark the pool as loaded, see SetLoaded.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader, interrupt <-chan struct{}) error {
+	var buf [8]byte
+	readUint64 := func() (uint64, error) {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint64(buf[:]), nil
+	}
+
+	version, err := readUint64()
+	if err != nil {
+		return err
+	}
+	if version != mempoolDumpVersion {
+		return fmt.Errorf("unsupported mempool file version %d", version)
+	}
+	numTxns, err := readUint64()
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if mp.cfg.Policy.Expiry != 0 {
+		cutoff = time.Now().Add(-mp.cfg.Policy.Expiry)
+	}
+	var numAccepted, numFailed, numExpired, numDuplicate int
+	for i := uint64(0); i < numTxns; i++ {
+		select {
+		case <-interrupt:
+			return errLoadInterrupted
+		default:
+		}
+
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(r); err != nil {
+			return err
+		}
+		addedUnix, err := readUint64()
+		if err != nil {
+			return err
+		}
+		feeDelta, err := readUint64()
+		if err != nil {
+			return err
+		}
+
+		tx := btcutil.NewTx(&msgTx)
+		if feeDelta != 0 {
+			mp.mtx.Lock()
+			mp.feeDeltas[*tx.Hash()] = int64(feeDelta)
+			mp.mtx.Unlock()
+		}
+
+		added := time.Unix(int64(addedUnix), 0)
+		if added.Before(cutoff) {
+			numExpired++
+			continue
+		}
+		if mp.HaveTransaction(tx.Hash()) {
+			numDuplicate++
+			continue
+		}
+
+		// The transactions are ordered such that their parents are
+		// loaded first, so a transaction can only have missing parents
+		// when they were rejected or expired, in which case it is
+		// rejected as well.
+		mp.mtx.Lock()
+		missingParents, txD, err := mp.maybeAcceptTransaction(tx, true,
+			false, true, false, nil)
+		if err == nil && len(missingParents) > 0 {
+			err = fmt.Errorf("missing parent transaction %v",
+				missingParents[0])
+		}
+		if err != nil {
+			mp.mtx.Unlock()
+			log.Debugf("Failed to load transaction %v from the "+
+				"persisted mempool: %v", tx.Hash(), err)
+			numFailed++
+			continue
+		}
+
+		// Keep the time the transaction was originally added unless
+		// the clock went backwards since.
+		if added.Before(txD.Added) {
+			txD.Added = added
+		}
+		mp.processOrphans(tx)
+		mp.mtx.Unlock()
+		numAccepted++
+	}
+
+	numFeeDeltas, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if numFeeDeltas > maxPersistedFeeDeltas {
+		return fmt.Errorf("too many fee deltas in mempool file: %d",
+			numFeeDeltas)
+	}
+	for i := uint64(0); i < numFeeDeltas; i++ {
+		var hash chainhash.Hash
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return err
+		}
+		feeDelta, err := readUint64()
+		if err != nil {
+			return err
+		}
+
+		mp.mtx.Lock()
+		mp.feeDeltas[hash] = int64(feeDelta)
+		mp.mtx.Unlock()
+	}
+
+	numUnbroadcast, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if numUnbroadcast > maxPersistedUnbroadcast {
+		return fmt.Errorf("too many unbroadcast transactions in mempool "+
+			"file: %d", numUnbroadcast)
+	}
+	for i := uint64(0); i < numUnbroadcast; i++ {
+		var hash chainhash.Hash
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Loaded %d %s from the persisted mempool (%d failed, %d "+
+		"expired, %d already in the pool)", numAccepted,
+		pickNoun(numAccepted, "transaction", "transactions"), numFailed,
+		numExpired, numDuplicate)
+	return nil
+}
+
+// SetLoaded marks the attempt to load the persisted pool as finished.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetLoaded() {
+	atomic.StoreInt32(&mp.loaded, 1)
+}
+
+// IsLoaded returns whether or not the attempt to load the persisted pool has
+// finished.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) IsLoaded() bool {
+	return atomic.LoadInt32(&mp.loaded) == 1
+}
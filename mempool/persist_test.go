@@ -0,0 +1,144 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestSaveLoad ensures the transactions in the pool can be saved and loaded
+// into another pool along with the time they were added and the fee deltas,
+// that expired and no longer valid transactions are skipped, and that loaded
+// transactions are not reported as new.
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+	txPool.cfg.Policy.Expiry = time.Hour
+
+	// Create a chain of transactions, a transaction which will be expired
+	// and a transaction which will be double spent by the chain.
+	chain, err := harness.CreateTxChain(outputs[0], 5)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chain {
+		_, err := txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("unable to process transaction: %v", err)
+		}
+	}
+	coinbase := ctx.addCoinbaseTx(2)
+	expired := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 0, false, false)
+	conflicted := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 0, false, false)
+
+	addedTime := time.Unix(time.Now().Add(-time.Minute*30).Unix(), 0)
+	txPool.pool[*chain[1].Hash()].Added = addedTime
+	txPool.pool[*expired.Hash()].Added = time.Now().Add(-time.Hour * 2)
+	txPool.feeDeltas[*chain[2].Hash()] = 1000
+	otherHash := chainhash.Hash{0x01}
+	txPool.feeDeltas[otherHash] = -1000
+
+	var buf bytes.Buffer
+	if err := txPool.Save(&buf); err != nil {
+		t.Fatalf("unable to save pool: %v", err)
+	}
+
+	// Spend the input of the conflicted transaction in the chain.
+	harness.chain.utxos.LookupEntry(conflicted.MsgTx().TxIn[0].
+		PreviousOutPoint).Spend()
+
+	var added []chainhash.Hash
+	newCfg := txPool.cfg
+	newCfg.FeeEstimator = NewSmartFeeEstimator()
+	newCfg.TxAdded = func(tx *btcutil.Tx) {
+		added = append(added, *tx.Hash())
+	}
+	newPool := New(&newCfg)
+	if newPool.IsLoaded() {
+		t.Fatalf("new pool is marked as loaded")
+	}
+	err = newPool.Load(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("unable to load pool: %v", err)
+	}
+	newPool.SetLoaded()
+	if !newPool.IsLoaded() {
+		t.Fatalf("pool is not marked as loaded")
+	}
+
+	newCtx := &testContext{t, &poolHarness{txPool: newPool}}
+	for _, tx := range chain {
+		testPoolMembership(newCtx, tx, false, true)
+	}
+	testPoolMembership(newCtx, expired, false, false)
+	testPoolMembership(newCtx, conflicted, false, false)
+	if got := newPool.pool[*chain[1].Hash()].Added; !got.Equal(addedTime) {
+		t.Fatalf("unexpected time added %v, want %v", got, addedTime)
+	}
+	if len(added) != 0 {
+		t.Fatalf("loaded transactions reported as added: %v", added)
+	}
+	if n := len(newCfg.FeeEstimator.tracked); n != 0 {
+		t.Fatalf("%d loaded transactions recorded for fee estimation",
+			n)
+	}
+	if newPool.feeDeltas[*chain[2].Hash()] != 1000 ||
+		newPool.feeDeltas[otherHash] != -1000 ||
+		len(newPool.feeDeltas) != 2 {
+
+		t.Fatalf("unexpected fee deltas %v", newPool.feeDeltas)
+	}
+	if got := newPool.pool[*chain[2].Hash()].FeeDelta; got != 1000 {
+		t.Fatalf("unexpected fee delta %d of loaded transaction", got)
+	}
+
+	// Transactions which are already in the pool are skipped.
+	err = newPool.Load(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("unable to load pool: %v", err)
+	}
+	if newPool.Count() != len(chain) {
+		t.Fatalf("unexpected number of transactions %d, want %d",
+			newPool.Count(), len(chain))
+	}
+
+	// The set of unbroadcast transactions written by bitcoind is read.
+	unbroadcast := append([]byte(nil), buf.Bytes()[:buf.Len()-1]...)
+	unbroadcast = append(unbroadcast, 0x01)
+	unbroadcast = append(unbroadcast, chain[0].Hash()[:]...)
+	err = New(&txPool.cfg).Load(bytes.NewReader(unbroadcast), nil)
+	if err != nil {
+		t.Fatalf("unable to load pool with unbroadcast "+
+			"transactions: %v", err)
+	}
+
+	// Unknown versions and truncated files are rejected.
+	badVersion := append([]byte{0x02}, buf.Bytes()[1:]...)
+	err = New(&txPool.cfg).Load(bytes.NewReader(badVersion), nil)
+	if err == nil {
+		t.Fatalf("loaded pool with unknown version")
+	}
+	truncated := buf.Bytes()[:buf.Len()-1]
+	err = New(&txPool.cfg).Load(bytes.NewReader(truncated), nil)
+	if err == nil {
+		t.Fatalf("loaded truncated pool")
+	}
+}
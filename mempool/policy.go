@@ -49,6 +49,26 @@ const (
 	// for larger transactions.  This value is in Satoshi/1000 bytes.
 	DefaultMinRelayTxFee = btcutil.Amount(1000)
 
+	// DefaultMaxAncestorCount is the default maximum number of unconfirmed
+	// ancestors, including the transaction itself, a transaction in the
+	// memory pool may have.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum total virtual size of
+	// a transaction in the memory pool along with all of its unconfirmed
+	// ancestors.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// descendants in the memory pool, including the transaction itself,
+	// any transaction in the memory pool may have.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum total virtual size
+	// of any transaction in the memory pool along with all of its
+	// descendants in the memory pool.
+	DefaultMaxDescendantSize = 101000
+
 	// maxStandardMultiSigKeys is the maximum number of public keys allowed
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
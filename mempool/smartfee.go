@@ -0,0 +1,1007 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// The smart fee estimator tracks how long transactions of different fee rates
+// take to confirm over three time horizons.  Each horizon counts
+// confirmations in periods of scale blocks and decays its data points by the
+// decay factor with every block, which gives the data points a half-life of
+// roughly 18 blocks, 144 blocks and 1008 blocks respectively.
+const (
+	// shortBlockPeriods, shortScale and shortDecay define the short time
+	// horizon which tracks up to 12 blocks.
+	shortBlockPeriods = 12
+	shortScale        = 1
+	shortDecay        = .962
+
+	// medBlockPeriods, medScale and medDecay define the medium time
+	// horizon which tracks up to 48 blocks.
+	medBlockPeriods = 24
+	medScale        = 2
+	medDecay        = .9952
+
+	// longBlockPeriods, longScale and longDecay define the long time
+	// horizon which tracks up to 1008 blocks.
+	longBlockPeriods = 42
+	longScale        = 24
+	longDecay        = .99931
+
+	// halfSuccessPct, successPct and doubleSuccessPct are the required
+	// confirmation rates for half of, exactly and double the requested
+	// confirmation target.
+	halfSuccessPct   = .6
+	successPct       = .85
+	doubleSuccessPct = .95
+
+	// sufficientFeeTxs is the number of transactions per block a range of
+	// fee buckets must see on average to be considered for an estimate of
+	// the medium and long horizons.
+	sufficientFeeTxs = 0.1
+
+	// sufficientTxsShort is the number of transactions per block a range
+	// of fee buckets must see on average to be considered for an estimate
+	// of the short horizon.
+	sufficientTxsShort = 0.5
+
+	// minBucketFeeRate and maxBucketFeeRate are the lowest and highest
+	// fee bucket boundaries in satoshis per kilo virtual byte.
+	minBucketFeeRate = 1000
+	maxBucketFeeRate = 1e7
+
+	// feeSpacing is the spacing of the fee buckets.  Each bucket boundary
+	// is 5% higher than the previous one.
+	feeSpacing = 1.05
+
+	// maxSavedBuckets and maxSavedConfirms bound the number of buckets and
+	// the number of confirmations tracked by a restored fee estimator.
+	maxSavedBuckets  = 1000
+	maxSavedConfirms = 6 * 24 * 7
+
+	btcPerSatoshi = 1e-8
+)
+
+var (
+	// SmartFeeEstimatorDatabaseKey is the key that we use to store the
+	// smart fee estimator in the database.
+	SmartFeeEstimatorDatabaseKey = []byte("smartfeeestimator")
+
+	// EstimateFeeDatabaseKey is the key the fee estimator of earlier
+	// versions stored its state under.  It is only read to migrate that
+	// state with MigrateFeeEstimatorState.
+	EstimateFeeDatabaseKey = []byte("estimatefee")
+)
+
+// BtcPerKilobyte is number with units of bitcoins per kilobyte.
+type BtcPerKilobyte float64
+
+// txConfirmStats tracks the number of transactions that confirmed within a
+// number of blocks for every fee bucket.  All data points are stored as
+// exponentially decaying moving averages.
+type txConfirmStats struct {
+	// buckets holds the upper bounds of the fee buckets.  It is shared by
+	// all stats of an estimator.
+	buckets []float64
+
+	// txCtAvg is the moving average of the number of confirmed
+	// transactions in each bucket.
+	txCtAvg []float64
+
+	// confAvg is the moving average of the number of transactions in each
+	// bucket that confirmed within a number of periods.  confAvg[Y][X] is
+	// the number of transactions in bucket X that confirmed within Y+1
+	// periods.
+	confAvg [][]float64
+
+	// failAvg is the moving average of the number of transactions in each
+	// bucket that left the mempool unconfirmed after a number of periods.
+	failAvg [][]float64
+
+	// feeRateAvg is the moving average of the sum of the fee rates of the
+	// confirmed transactions in each bucket.
+	feeRateAvg []float64
+
+	decay float64
+	scale uint32
+
+	// unconfTxs is the number of transactions that entered the mempool at
+	// each height modulo the number of tracked confirmations and are still
+	// unconfirmed.  oldUnconfTxs is the number of transactions that are
+	// unconfirmed for longer than that.
+	unconfTxs    [][]int
+	oldUnconfTxs []int
+}
+
+// newTxConfirmStats returns confirmation stats for the provided buckets that
+// track up to maxPeriods periods of scale blocks.
+func newTxConfirmStats(buckets []float64, maxPeriods uint32, decay float64,
+	scale uint32) *txConfirmStats {
+
+	stats := &txConfirmStats{
+		buckets:    buckets,
+		txCtAvg:    make([]float64, len(buckets)),
+		confAvg:    make([][]float64, maxPeriods),
+		failAvg:    make([][]float64, maxPeriods),
+		feeRateAvg: make([]float64, len(buckets)),
+		decay:      decay,
+		scale:      scale,
+	}
+	for i := range stats.confAvg {
+		stats.confAvg[i] = make([]float64, len(buckets))
+		stats.failAvg[i] = make([]float64, len(buckets))
+	}
+	stats.resizeUnconfirmed()
+	return stats
+}
+
+// resizeUnconfirmed resets the unconfirmed transaction counters.
+func (s *txConfirmStats) resizeUnconfirmed() {
+	s.unconfTxs = make([][]int, s.maxConfirms())
+	for i := range s.unconfTxs {
+		s.unconfTxs[i] = make([]int, len(s.buckets))
+	}
+	s.oldUnconfTxs = make([]int, len(s.buckets))
+}
+
+// maxConfirms returns the highest number of confirmations tracked.
+func (s *txConfirmStats) maxConfirms() uint32 {
+	return s.scale * uint32(len(s.confAvg))
+}
+
+// bucketIndex returns the index of the bucket the provided fee rate falls in.
+func (s *txConfirmStats) bucketIndex(feeRate float64) int {
+	return sort.SearchFloat64s(s.buckets, feeRate)
+}
+
+// unconfIndex returns the index into unconfTxs for the provided height.
+func (s *txConfirmStats) unconfIndex(height int64) int {
+	n := int64(len(s.unconfTxs))
+	return int((height%n + n) % n)
+}
+
+// clearCurrent moves the transactions that are unconfirmed for longer than
+// tracked to the old unconfirmed counters to make room for the transactions
+// of the new block at the provided height.
+func (s *txConfirmStats) clearCurrent(height int32) {
+	index := s.unconfIndex(int64(height))
+	for j := range s.buckets {
+		s.oldUnconfTxs[j] += s.unconfTxs[index][j]
+		s.unconfTxs[index][j] = 0
+	}
+}
+
+// record adds a transaction with the provided fee rate that confirmed after
+// blocksToConfirm blocks.
+func (s *txConfirmStats) record(blocksToConfirm int32, feeRate float64) {
+	if blocksToConfirm < 1 {
+		return
+	}
+	periodsToConfirm := (uint32(blocksToConfirm) + s.scale - 1) / s.scale
+	bucket := s.bucketIndex(feeRate)
+	for i := periodsToConfirm; i <= uint32(len(s.confAvg)); i++ {
+		s.confAvg[i-1][bucket]++
+	}
+	s.txCtAvg[bucket]++
+	s.feeRateAvg[bucket] += feeRate
+}
+
+// updateMovingAverages decays all data points.
+func (s *txConfirmStats) updateMovingAverages() {
+	for j := range s.buckets {
+		for i := range s.confAvg {
+			s.confAvg[i][j] *= s.decay
+			s.failAvg[i][j] *= s.decay
+		}
+		s.feeRateAvg[j] *= s.decay
+		s.txCtAvg[j] *= s.decay
+	}
+}
+
+// newTx records a transaction with the provided fee rate that entered the
+// mempool at the provided height and returns its bucket index.
+func (s *txConfirmStats) newTx(height int32, feeRate float64) int {
+	bucket := s.bucketIndex(feeRate)
+	s.unconfTxs[s.unconfIndex(int64(height))][bucket]++
+	return bucket
+}
+
+// removeTx removes an unconfirmed transaction that entered the mempool at
+// entryHeight from the unconfirmed counters.  A transaction that left the
+// mempool without being included in a block counts as a failure for all the
+// periods it was unconfirmed for.
+func (s *txConfirmStats) removeTx(entryHeight, bestSeenHeight int32,
+	bucket int, inBlock bool) {
+
+	// The best seen height is not updated yet for a new block.
+	blocksAgo := bestSeenHeight - entryHeight
+	if bestSeenHeight == 0 {
+		blocksAgo = 0
+	}
+	if blocksAgo < 0 {
+		return
+	}
+
+	if int(blocksAgo) >= len(s.unconfTxs) {
+		if s.oldUnconfTxs[bucket] > 0 {
+			s.oldUnconfTxs[bucket]--
+		}
+	} else {
+		index := s.unconfIndex(int64(entryHeight))
+		if s.unconfTxs[index][bucket] > 0 {
+			s.unconfTxs[index][bucket]--
+		}
+	}
+
+	// Only count a failure if the transaction was unconfirmed for an
+	// entire period.
+	if !inBlock && uint32(blocksAgo) >= s.scale {
+		periodsAgo := uint32(blocksAgo) / s.scale
+		for i := uint32(0); i < periodsAgo && i < uint32(len(s.failAvg)); i++ {
+			s.failAvg[i][bucket]++
+		}
+	}
+}
+
+// estimateMedianVal returns the fee rate that confirmed within confTarget
+// blocks with a success rate of at least successBreakPoint, or -1 if there is
+// no such fee rate.
+//
+// Buckets are combined starting from the highest fee rate until the combined
+// range has enough data points.  The range is then tested for the success
+// rate, and the estimate is the average fee rate of the bucket holding the
+// median transaction of the lowest fee rate range that still succeeded.
+func (s *txConfirmStats) estimateMedianVal(confTarget uint32,
+	sufficientTxVal, successBreakPoint float64, bestSeenHeight int32) float64 {
+
+	// Counters for the current range of buckets.
+	var nConf, totalNum, failNum float64
+	var extraNum int
+
+	periodTarget := (confTarget + s.scale - 1) / s.scale
+	maxBucket := len(s.buckets) - 1
+
+	// The near and far buckets define the current range of combined
+	// buckets, and the best buckets are the last range that had a high
+	// enough success rate.
+	curNearBucket, curFarBucket := maxBucket, maxBucket
+	bestNearBucket, bestFarBucket := maxBucket, maxBucket
+
+	foundAnswer := false
+	newBucketRange := true
+	for bucket := maxBucket; bucket >= 0; bucket-- {
+		if newBucketRange {
+			curNearBucket = bucket
+			newBucketRange = false
+		}
+		curFarBucket = bucket
+		nConf += s.confAvg[periodTarget-1][bucket]
+		totalNum += s.txCtAvg[bucket]
+		failNum += s.failAvg[periodTarget-1][bucket]
+		for confct := confTarget; confct < s.maxConfirms(); confct++ {
+			index := s.unconfIndex(int64(bestSeenHeight) - int64(confct))
+			extraNum += s.unconfTxs[index][bucket]
+		}
+		extraNum += s.oldUnconfTxs[bucket]
+
+		// Only test for success once the range has enough confirmed
+		// data points so each confirmation target looks at the same
+		// amount of data.
+		if totalNum < sufficientTxVal/(1-s.decay) {
+			continue
+		}
+		curPct := nConf / (totalNum + failNum + float64(extraNum))
+		if curPct < successBreakPoint {
+			continue
+		}
+
+		// The range succeeded, so remember it and start a new one.
+		foundAnswer = true
+		nConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		bestNearBucket, bestFarBucket = curNearBucket, curFarBucket
+		newBucketRange = true
+	}
+
+	if !foundAnswer {
+		return -1
+	}
+
+	// Report the average fee rate of the bucket holding the median
+	// transaction of the best range.  This is a compromise between the
+	// median, which can't be calculated since individual transactions are
+	// not kept, and the average of the range, which is less accurate.
+	minBucket, maxBucket := bestFarBucket, bestNearBucket
+	var txSum float64
+	for j := minBucket; j <= maxBucket; j++ {
+		txSum += s.txCtAvg[j]
+	}
+	if txSum == 0 {
+		return -1
+	}
+	txSum /= 2
+	for j := minBucket; j <= maxBucket; j++ {
+		if s.txCtAvg[j] < txSum {
+			txSum -= s.txCtAvg[j]
+			continue
+		}
+		return s.feeRateAvg[j] / s.txCtAvg[j]
+	}
+	return -1
+}
+
+// serialize writes the moving averages of the stats to w.  The unconfirmed
+// counters are not saved.
+func (s *txConfirmStats) serialize(w io.Writer) {
+	binary.Write(w, binary.BigEndian, s.decay)
+	binary.Write(w, binary.BigEndian, s.scale)
+	binary.Write(w, binary.BigEndian, s.feeRateAvg)
+	binary.Write(w, binary.BigEndian, s.txCtAvg)
+	binary.Write(w, binary.BigEndian, uint32(len(s.confAvg)))
+	for i := range s.confAvg {
+		binary.Write(w, binary.BigEndian, s.confAvg[i])
+	}
+	for i := range s.failAvg {
+		binary.Write(w, binary.BigEndian, s.failAvg[i])
+	}
+}
+
+// deserializeTxConfirmStats reads stats for the provided buckets that were
+// written by serialize from r.
+func deserializeTxConfirmStats(r io.Reader, buckets []float64) (*txConfirmStats, error) {
+	var decay float64
+	var scale, maxPeriods uint32
+	if err := binary.Read(r, binary.BigEndian, &decay); err != nil {
+		return nil, err
+	}
+	if decay <= 0 || decay >= 1 {
+		return nil, fmt.Errorf("invalid decay %v", decay)
+	}
+	if err := binary.Read(r, binary.BigEndian, &scale); err != nil {
+		return nil, err
+	}
+	if scale == 0 {
+		return nil, errors.New("invalid scale 0")
+	}
+
+	feeRateAvg := make([]float64, len(buckets))
+	if err := binary.Read(r, binary.BigEndian, feeRateAvg); err != nil {
+		return nil, err
+	}
+	txCtAvg := make([]float64, len(buckets))
+	if err := binary.Read(r, binary.BigEndian, txCtAvg); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &maxPeriods); err != nil {
+		return nil, err
+	}
+	if maxPeriods == 0 || uint64(maxPeriods)*uint64(scale) > maxSavedConfirms {
+		return nil, fmt.Errorf("invalid number of periods %d for "+
+			"scale %d", maxPeriods, scale)
+	}
+
+	stats := newTxConfirmStats(buckets, maxPeriods, decay, scale)
+	stats.feeRateAvg = feeRateAvg
+	stats.txCtAvg = txCtAvg
+	for i := range stats.confAvg {
+		if err := binary.Read(r, binary.BigEndian, stats.confAvg[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range stats.failAvg {
+		if err := binary.Read(r, binary.BigEndian, stats.failAvg[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// trackedTransaction houses the data about a mempool transaction that is
+// tracked by the smart fee estimator.
+type trackedTransaction struct {
+	// height is the block height when the transaction entered the
+	// mempool.
+	height int32
+
+	// bucket is the index of the fee bucket of the transaction.
+	bucket int
+
+	// feeRate is the fee rate of the transaction in satoshis per kilo
+	// virtual byte.
+	feeRate float64
+}
+
+// SmartFeeEstimator estimates the fee rate required for a transaction to
+// confirm within a number of blocks based on how long the transactions it
+// observed in the mempool took to confirm.  The observations are tracked in
+// exponentially spaced fee buckets over a short, medium and long time horizon
+// whose data points decay exponentially with every block, which allows the
+// estimates to quickly follow fee spikes while still providing estimates for
+// far away confirmation targets.
+//
+// Blocks that are not higher than the highest block seen so far, such as
+// those connected during a reorganization, are ignored.
+//
+// It is safe for concurrent access.
+type SmartFeeEstimator struct {
+	mtx sync.Mutex
+
+	buckets    []float64
+	shortStats *txConfirmStats
+	feeStats   *txConfirmStats
+	longStats  *txConfirmStats
+
+	tracked map[chainhash.Hash]*trackedTransaction
+
+	// bestSeenHeight is the height of the highest block registered.
+	bestSeenHeight int32
+
+	// firstRecordedHeight is the height of the first block which
+	// confirmed a tracked transaction since the estimator was created.
+	firstRecordedHeight int32
+
+	// historicalFirst and historicalBest are the range of blocks the
+	// state of a restored estimator was gathered from.
+	historicalFirst int32
+	historicalBest  int32
+}
+
+// NewSmartFeeEstimator returns a new smart fee estimator without any data.
+func NewSmartFeeEstimator() *SmartFeeEstimator {
+	var buckets []float64
+	for boundary := float64(minBucketFeeRate); boundary <= maxBucketFeeRate; boundary *= feeSpacing {
+		buckets = append(buckets, boundary)
+	}
+	buckets = append(buckets, math.Inf(1))
+
+	return newSmartFeeEstimator(buckets)
+}
+
+// newSmartFeeEstimator returns a new smart fee estimator for the provided fee
+// buckets.
+func newSmartFeeEstimator(buckets []float64) *SmartFeeEstimator {
+	return &SmartFeeEstimator{
+		buckets: buckets,
+		shortStats: newTxConfirmStats(buckets, shortBlockPeriods,
+			shortDecay, shortScale),
+		feeStats: newTxConfirmStats(buckets, medBlockPeriods, medDecay,
+			medScale),
+		longStats: newTxConfirmStats(buckets, longBlockPeriods,
+			longDecay, longScale),
+		tracked: make(map[chainhash.Hash]*trackedTransaction),
+	}
+}
+
+// ObserveTransaction is called when a new transaction enters the mempool.
+// Transactions that entered the mempool while the estimator has not seen the
+// current best block are ignored.
+func (e *SmartFeeEstimator) ObserveTransaction(t *TxDesc) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	hash := *t.Tx.Hash()
+	if _, ok := e.tracked[hash]; ok {
+		return
+	}
+	if t.Height != e.bestSeenHeight {
+		return
+	}
+
+	feeRate := float64(t.FeePerKB)
+	bucket := e.feeStats.newTx(t.Height, feeRate)
+	e.shortStats.newTx(t.Height, feeRate)
+	e.longStats.newTx(t.Height, feeRate)
+	e.tracked[hash] = &trackedTransaction{
+		height:  t.Height,
+		bucket:  bucket,
+		feeRate: feeRate,
+	}
+}
+
+// removeTx stops tracking the transaction with the provided hash and returns
+// it, or nil if it isn't tracked.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) removeTx(hash *chainhash.Hash, inBlock bool) *trackedTransaction {
+	tracked, ok := e.tracked[*hash]
+	if !ok {
+		return nil
+	}
+	e.feeStats.removeTx(tracked.height, e.bestSeenHeight, tracked.bucket,
+		inBlock)
+	e.shortStats.removeTx(tracked.height, e.bestSeenHeight,
+		tracked.bucket, inBlock)
+	e.longStats.removeTx(tracked.height, e.bestSeenHeight, tracked.bucket,
+		inBlock)
+	delete(e.tracked, *hash)
+	return tracked
+}
+
+// RemoveTransaction is called when a transaction leaves the mempool without
+// being included in a block.  This counts as a failure to confirm for the fee
+// rate of the transaction.
+func (e *SmartFeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
+	e.mtx.Lock()
+	e.removeTx(hash, false)
+	e.mtx.Unlock()
+}
+
+// RegisterBlock informs the estimator of a new block connected to the main
+// chain.  It must be called before the transactions of the block are removed
+// from the mempool.
+func (e *SmartFeeEstimator) RegisterBlock(block *btcutil.Block) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	height := block.Height()
+	if height <= e.bestSeenHeight {
+		return
+	}
+	e.bestSeenHeight = height
+
+	for _, stats := range []*txConfirmStats{e.feeStats, e.shortStats,
+		e.longStats} {
+
+		stats.clearCurrent(height)
+		stats.updateMovingAverages()
+	}
+
+	var counted int
+	for _, tx := range block.Transactions() {
+		tracked := e.removeTx(tx.Hash(), true)
+		if tracked == nil {
+			continue
+		}
+		blocksToConfirm := height - tracked.height
+		if blocksToConfirm <= 0 {
+			continue
+		}
+		e.feeStats.record(blocksToConfirm, tracked.feeRate)
+		e.shortStats.record(blocksToConfirm, tracked.feeRate)
+		e.longStats.record(blocksToConfirm, tracked.feeRate)
+		counted++
+	}
+
+	if e.firstRecordedHeight == 0 && counted > 0 {
+		e.firstRecordedHeight = height
+		log.Debugf("Smart fee estimator recording data from height %d",
+			height)
+	}
+}
+
+// blockSpan returns the number of blocks the estimator has recorded data for
+// since it was created.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) blockSpan() uint32 {
+	if e.firstRecordedHeight == 0 {
+		return 0
+	}
+	return uint32(e.bestSeenHeight - e.firstRecordedHeight)
+}
+
+// historicalBlockSpan returns the number of blocks the restored state was
+// recorded over, or zero if the restored state is too old to be useful.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) historicalBlockSpan() uint32 {
+	if e.historicalFirst == 0 {
+		return 0
+	}
+	if int64(e.historicalBest) < int64(e.bestSeenHeight)-int64(e.blockSpan()) {
+		return 0
+	}
+	return uint32(e.historicalBest - e.historicalFirst)
+}
+
+// maxUsableEstimate returns the highest confirmation target estimates can be
+// provided for given the number of blocks data was recorded for.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) maxUsableEstimate() uint32 {
+	span := e.blockSpan()
+	if historical := e.historicalBlockSpan(); historical > span {
+		span = historical
+	}
+	maxUsable := span / 2
+	if maxConfirms := e.longStats.maxConfirms(); maxUsable > maxConfirms {
+		maxUsable = maxConfirms
+	}
+	return maxUsable
+}
+
+// estimateCombinedFee returns the lowest fee rate that confirmed within
+// confTarget blocks at the provided success rate using the shortest horizon
+// that tracks the target.  When checkShorterHorizon is set, the highest
+// targets of the shorter horizons are considered as well, which keeps the
+// estimates monotonically decreasing for increasing targets.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) estimateCombinedFee(confTarget uint32,
+	successThreshold float64, checkShorterHorizon bool) float64 {
+
+	if confTarget < 1 || confTarget > e.longStats.maxConfirms() {
+		return -1
+	}
+
+	var estimate float64
+	switch {
+	case confTarget <= e.shortStats.maxConfirms():
+		estimate = e.shortStats.estimateMedianVal(confTarget,
+			sufficientTxsShort, successThreshold, e.bestSeenHeight)
+	case confTarget <= e.feeStats.maxConfirms():
+		estimate = e.feeStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, e.bestSeenHeight)
+	default:
+		estimate = e.longStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, e.bestSeenHeight)
+	}
+
+	if !checkShorterHorizon {
+		return estimate
+	}
+	if confTarget > e.feeStats.maxConfirms() {
+		medMax := e.feeStats.estimateMedianVal(e.feeStats.maxConfirms(),
+			sufficientFeeTxs, successThreshold, e.bestSeenHeight)
+		if medMax > 0 && (estimate == -1 || medMax < estimate) {
+			estimate = medMax
+		}
+	}
+	if confTarget > e.shortStats.maxConfirms() {
+		shortMax := e.shortStats.estimateMedianVal(
+			e.shortStats.maxConfirms(), sufficientTxsShort,
+			successThreshold, e.bestSeenHeight)
+		if shortMax > 0 && (estimate == -1 || shortMax < estimate) {
+			estimate = shortMax
+		}
+	}
+	return estimate
+}
+
+// estimateConservativeFee returns the highest fee rate of the medium and long
+// horizons that confirmed within doubleTarget blocks at the highest success
+// rate.
+//
+// This function MUST be called with the estimator lock held.
+func (e *SmartFeeEstimator) estimateConservativeFee(doubleTarget uint32) float64 {
+	estimate := float64(-1)
+	if doubleTarget <= e.shortStats.maxConfirms() {
+		estimate = e.feeStats.estimateMedianVal(doubleTarget,
+			sufficientFeeTxs, doubleSuccessPct, e.bestSeenHeight)
+	}
+	if doubleTarget <= e.feeStats.maxConfirms() {
+		longEstimate := e.longStats.estimateMedianVal(doubleTarget,
+			sufficientFeeTxs, doubleSuccessPct, e.bestSeenHeight)
+		if longEstimate > estimate {
+			estimate = longEstimate
+		}
+	}
+	return estimate
+}
+
+// MaxConfirmTarget returns the highest confirmation target the estimator
+// tracks.
+func (e *SmartFeeEstimator) MaxConfirmTarget() uint32 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	return e.longStats.maxConfirms()
+}
+
+// EstimateSmartFee returns the fee rate a transaction needs to pay to confirm
+// within confTarget blocks along with the confirmation target the estimate is
+// actually for, which is lower than the requested one when not enough data
+// has been recorded yet.  A fee rate of zero is returned when no estimate is
+// available.
+//
+// Conservative estimates also consider the longer time horizons so short
+// term drops of the fee rates lower the estimate less than economical
+// estimates do.
+func (e *SmartFeeEstimator) EstimateSmartFee(confTarget uint32,
+	conservative bool) (BtcPerKilobyte, uint32) {
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if confTarget == 0 || confTarget > e.longStats.maxConfirms() {
+		return 0, confTarget
+	}
+
+	// It's not possible to get reasonable estimates for a target of 1.
+	if confTarget == 1 {
+		confTarget = 2
+	}
+	if maxUsable := e.maxUsableEstimate(); confTarget > maxUsable {
+		confTarget = maxUsable
+	}
+	if confTarget <= 1 {
+		return 0, confTarget
+	}
+
+	// The shorter horizons are checked for half and the actual target to
+	// keep the estimates monotonically decreasing.  The same is done for
+	// double the target of economical estimates, while conservative
+	// estimates take the maximum over all horizons instead so short term
+	// drops of the fee rates don't lower them too much.
+	median := e.estimateCombinedFee(confTarget/2, halfSuccessPct, true)
+	actualEst := e.estimateCombinedFee(confTarget, successPct, true)
+	if actualEst > median {
+		median = actualEst
+	}
+	doubleEst := e.estimateCombinedFee(2*confTarget, doubleSuccessPct,
+		!conservative)
+	if doubleEst > median {
+		median = doubleEst
+	}
+	if conservative || median == -1 {
+		consEst := e.estimateConservativeFee(2 * confTarget)
+		if consEst > median {
+			median = consEst
+		}
+	}
+	if median < 0 {
+		return 0, confTarget
+	}
+
+	return BtcPerKilobyte(math.Round(median) * btcPerSatoshi), confTarget
+}
+
+// EstimateFee returns the fee rate a transaction needs to pay to confirm
+// within numBlocks blocks with a high probability using the medium time
+// horizon only.  It exists to serve the estimatefee RPC, new code should use
+// EstimateSmartFee instead.
+func (e *SmartFeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if numBlocks == 0 {
+		return -1, errors.New("cannot confirm transaction in zero blocks")
+	}
+	if numBlocks > e.feeStats.maxConfirms() {
+		return -1, fmt.Errorf("can only estimate fees for up to %d "+
+			"blocks from now", e.feeStats.maxConfirms())
+	}
+	if numBlocks == 1 {
+		return -1, errors.New("cannot estimate fees for transactions " +
+			"to confirm in the next block")
+	}
+
+	median := e.feeStats.estimateMedianVal(numBlocks, sufficientFeeTxs,
+		doubleSuccessPct, e.bestSeenHeight)
+	if median < 0 {
+		return -1, errors.New("insufficient data to estimate fee")
+	}
+	return BtcPerKilobyte(math.Round(median) * btcPerSatoshi), nil
+}
+
+// In case the format for the serialized version of the SmartFeeEstimator
+// changes, we use a version number.  If the version number changes, the
+// saved state is discarded and estimation starts over.
+const smartFeeEstimatorSaveVersion = 1
+
+// SmartFeeEstimatorState represents a saved SmartFeeEstimator that can be
+// restored with data from an earlier session of the program.
+type SmartFeeEstimatorState []byte
+
+// Save records the current state of the SmartFeeEstimator to a []byte that
+// can be restored later.  Only the moving averages are saved, transactions
+// that are still unconfirmed are not.
+func (e *SmartFeeEstimator) Save() SmartFeeEstimatorState {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	w := bytes.NewBuffer(make([]byte, 0))
+
+	binary.Write(w, binary.BigEndian, uint32(smartFeeEstimatorSaveVersion))
+	binary.Write(w, binary.BigEndian, e.bestSeenHeight)
+
+	// Save the range of blocks the data was recorded over.  Prefer the
+	// data of this session unless it covers a lot fewer blocks than the
+	// restored data.
+	if e.blockSpan() > e.historicalBlockSpan()/2 {
+		binary.Write(w, binary.BigEndian, e.firstRecordedHeight)
+		binary.Write(w, binary.BigEndian, e.bestSeenHeight)
+	} else {
+		binary.Write(w, binary.BigEndian, e.historicalFirst)
+		binary.Write(w, binary.BigEndian, e.historicalBest)
+	}
+
+	binary.Write(w, binary.BigEndian, uint32(len(e.buckets)))
+	binary.Write(w, binary.BigEndian, e.buckets)
+	e.feeStats.serialize(w)
+	e.shortStats.serialize(w)
+	e.longStats.serialize(w)
+
+	return SmartFeeEstimatorState(w.Bytes())
+}
+
+// RestoreSmartFeeEstimator takes a SmartFeeEstimatorState that was previously
+// returned by Save and restores it to a SmartFeeEstimator.
+func RestoreSmartFeeEstimator(data SmartFeeEstimatorState) (*SmartFeeEstimator, error) {
+	r := bytes.NewReader([]byte(data))
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != smartFeeEstimatorSaveVersion {
+		return nil, fmt.Errorf("incorrect version: expected %d found %d",
+			smartFeeEstimatorSaveVersion, version)
+	}
+
+	var bestSeenHeight, historicalFirst, historicalBest int32
+	for _, v := range []*int32{&bestSeenHeight, &historicalFirst,
+		&historicalBest} {
+
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	if historicalFirst < 0 || historicalFirst > historicalBest ||
+		historicalBest > bestSeenHeight {
+
+		return nil, fmt.Errorf("invalid recorded block range %d-%d "+
+			"with best height %d", historicalFirst, historicalBest,
+			bestSeenHeight)
+	}
+
+	var numBuckets uint32
+	if err := binary.Read(r, binary.BigEndian, &numBuckets); err != nil {
+		return nil, err
+	}
+	if numBuckets <= 1 || numBuckets > maxSavedBuckets {
+		return nil, fmt.Errorf("invalid number of buckets %d",
+			numBuckets)
+	}
+	buckets := make([]float64, numBuckets)
+	if err := binary.Read(r, binary.BigEndian, buckets); err != nil {
+		return nil, err
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return nil, errors.New("buckets are not sorted")
+	}
+
+	e := newSmartFeeEstimator(buckets)
+	for _, stats := range []**txConfirmStats{&e.feeStats, &e.shortStats,
+		&e.longStats} {
+
+		var err error
+		*stats, err = deserializeTxConfirmStats(r, buckets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e.bestSeenHeight = bestSeenHeight
+	e.historicalFirst = historicalFirst
+	e.historicalBest = historicalBest
+
+	return e, nil
+}
+
+// legacyEstimateFeeSaveVersion is the version of the state saved by the fee
+// estimator of earlier versions.
+const legacyEstimateFeeSaveVersion = 1
+
+// legacyObservedTx houses the data of a transaction recorded in the state
+// saved by the fee estimator of earlier versions.
+type legacyObservedTx struct {
+	hash chainhash.Hash
+
+	// feeRate is the fee rate of the transaction in satoshis per virtual
+	// byte.
+	feeRate float64
+
+	// observed is the block height when the transaction entered the
+	// mempool.
+	observed int32
+
+	// mined is the height of the block the transaction was mined in, or
+	// mining.UnminedHeight if it wasn't mined.
+	mined int32
+}
+
+// MigrateFeeEstimatorState converts the state saved by the fee estimator of
+// earlier versions under EstimateFeeDatabaseKey to a SmartFeeEstimator.
+//
+// That estimator only kept a sample of the transactions it saw confirming
+// within 25 blocks.  The confirmations of the sample are replayed in the order
+// of the blocks they were mined in, so the moving averages of the returned
+// estimator decay the same way they would have if the estimator had observed
+// the transactions itself.  Transactions that were still unconfirmed are not
+// migrated.
+func MigrateFeeEstimatorState(data []byte) (*SmartFeeEstimator, error) {
+	r := bytes.NewReader(data)
+
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != legacyEstimateFeeSaveVersion {
+		return nil, fmt.Errorf("incorrect version: expected %d found %d",
+			legacyEstimateFeeSaveVersion, version)
+	}
+
+	// The state starts with the parameters of the estimator, of which only
+	// the height of the last registered block is needed.
+	var params struct {
+		MaxRollback         uint32
+		BinSize             int32
+		MaxReplacements     int32
+		MinRegisteredBlocks uint32
+		LastKnownHeight     int32
+		NumBlocksRegistered uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &params); err != nil {
+		return nil, err
+	}
+	lastKnownHeight := params.LastKnownHeight
+
+	// Read the observed transactions that were mined.  The bins and the
+	// blocks kept for rollbacks that follow only reference them.
+	var numObserved uint32
+	if err := binary.Read(r, binary.BigEndian, &numObserved); err != nil {
+		return nil, err
+	}
+	var mined []legacyObservedTx
+	for i := uint32(0); i < numObserved; i++ {
+		var tx legacyObservedTx
+		for _, v := range []interface{}{&tx.hash, &tx.feeRate,
+			&tx.observed, &tx.mined} {
+
+			if err := binary.Read(r, binary.BigEndian, v); err != nil {
+				return nil, err
+			}
+		}
+		if tx.mined <= tx.observed || tx.mined > lastKnownHeight {
+			continue
+		}
+		mined = append(mined, tx)
+	}
+	sort.Slice(mined, func(i, j int) bool {
+		return mined[i].mined < mined[j].mined
+	})
+
+	e := NewSmartFeeEstimator()
+	e.bestSeenHeight = lastKnownHeight
+	if len(mined) == 0 {
+		return e, nil
+	}
+
+	// Replay the confirmations block by block up to the last registered
+	// block so the data points are decayed accordingly.
+	allStats := []*txConfirmStats{e.feeStats, e.shortStats, e.longStats}
+	firstMined := mined[0].mined
+	for height := firstMined; height <= lastKnownHeight; height++ {
+		for _, stats := range allStats {
+			stats.updateMovingAverages()
+		}
+		for len(mined) > 0 && mined[0].mined == height {
+			tx := mined[0]
+			mined = mined[1:]
+
+			feeRate := tx.feeRate * 1000
+			for _, stats := range allStats {
+				stats.record(tx.mined-tx.observed, feeRate)
+			}
+		}
+	}
+	e.historicalFirst = firstMined
+	e.historicalBest = lastKnownHeight
+
+	return e, nil
+}
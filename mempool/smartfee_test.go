@@ -0,0 +1,329 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// smartFeeTester interacts with a SmartFeeEstimator the way the mempool and
+// the sync manager do.
+type smartFeeTester struct {
+	e       *SmartFeeEstimator
+	version int32
+	height  int32
+}
+
+// newTx returns a new unique transaction paying the provided fee rate in
+// satoshis per kilo virtual byte that entered the mempool at the current
+// height and makes the estimator observe it.
+func (sft *smartFeeTester) newTx(feePerKB int64) *TxDesc {
+	sft.version++
+	txD := &TxDesc{
+		TxDesc: mining.TxDesc{
+			Tx: btcutil.NewTx(&wire.MsgTx{
+				Version: sft.version,
+			}),
+			Height:   sft.height,
+			FeePerKB: feePerKB,
+		},
+	}
+	sft.e.ObserveTransaction(txD)
+	return txD
+}
+
+// newBlock registers a new block containing the provided transactions.
+func (sft *smartFeeTester) newBlock(txs []*TxDesc) {
+	sft.height++
+	msgBlock := &wire.MsgBlock{}
+	for _, txD := range txs {
+		msgBlock.Transactions = append(msgBlock.Transactions,
+			txD.Tx.MsgTx())
+	}
+	block := btcutil.NewBlock(msgBlock)
+	block.SetHeight(sft.height)
+	sft.e.RegisterBlock(block)
+}
+
+// satoshiPerKB converts a fee rate in satoshis per kilo virtual byte to the
+// fee rate returned by the estimator.
+func satoshiPerKB(feeRate float64) BtcPerKilobyte {
+	return BtcPerKilobyte(feeRate * btcPerSatoshi)
+}
+
+// runSmartFeeTester simulates numBlocks blocks where high fee transactions
+// confirm in the next block and low fee transactions confirm after six
+// blocks.
+func runSmartFeeTester(sft *smartFeeTester, numBlocks int) {
+	const (
+		highFee = 10000
+		lowFee  = 2000
+	)
+
+	var pending [][]*TxDesc
+	for i := 0; i < numBlocks; i++ {
+		var confirmed []*TxDesc
+		if len(pending) > 0 {
+			confirmed = append(confirmed, pending[len(pending)-1][:10]...)
+		}
+		if len(pending) >= 6 {
+			confirmed = append(confirmed, pending[len(pending)-6][10:]...)
+		}
+		sft.newBlock(confirmed)
+
+		var txs []*TxDesc
+		for j := 0; j < 10; j++ {
+			txs = append(txs, sft.newTx(highFee))
+		}
+		for j := 0; j < 10; j++ {
+			txs = append(txs, sft.newTx(lowFee))
+		}
+		pending = append(pending, txs)
+	}
+}
+
+// TestSmartFeeEstimator tests the estimates of the SmartFeeEstimator.
+func TestSmartFeeEstimator(t *testing.T) {
+	sft := &smartFeeTester{e: NewSmartFeeEstimator()}
+
+	// Without any data, no estimate can be made.
+	if rate, _ := sft.e.EstimateSmartFee(6, true); rate != 0 {
+		t.Fatalf("unexpected estimate without data: %v", rate)
+	}
+	if max := sft.e.MaxConfirmTarget(); max != longBlockPeriods*longScale {
+		t.Fatalf("unexpected max confirmation target %d", max)
+	}
+
+	runSmartFeeTester(sft, 200)
+
+	tests := []struct {
+		name         string
+		confTarget   uint32
+		conservative bool
+		rate         BtcPerKilobyte
+		blocks       uint32
+	}{
+		{"next block", 1, true, satoshiPerKB(10000), 2},
+		{"two blocks", 2, false, satoshiPerKB(10000), 2},
+		{"twelve blocks economical", 12, false, satoshiPerKB(2000), 12},
+		{"twelve blocks conservative", 12, true, satoshiPerKB(2000), 12},
+
+		// Only blocks 2 through 200 recorded confirmations, so the
+		// target is limited to half that many blocks.
+		{"limited target", 500, false, satoshiPerKB(2000), 99},
+
+		// Targets that are not tracked can't be estimated.
+		{"zero", 0, false, 0, 0},
+		{"too high", 1009, false, 0, 1009},
+	}
+	for _, test := range tests {
+		rate, blocks := sft.e.EstimateSmartFee(test.confTarget,
+			test.conservative)
+		if rate != test.rate || blocks != test.blocks {
+			t.Errorf("%s: got rate %v for %d blocks, want rate %v "+
+				"for %d blocks", test.name, rate, blocks, test.rate,
+				test.blocks)
+		}
+	}
+
+	// Legacy estimates use the medium horizon only.
+	rate, err := sft.e.EstimateFee(2)
+	if err != nil {
+		t.Fatalf("EstimateFee: unexpected error: %v", err)
+	}
+	if rate != satoshiPerKB(10000) {
+		t.Fatalf("EstimateFee: got %v, want %v", rate, satoshiPerKB(10000))
+	}
+	for _, numBlocks := range []uint32{0, 1, 49} {
+		if _, err := sft.e.EstimateFee(numBlocks); err == nil {
+			t.Errorf("EstimateFee(%d): did not return an error",
+				numBlocks)
+		}
+	}
+}
+
+// TestSmartFeeEstimatorFailures ensures transactions that leave the mempool
+// without being confirmed raise the estimates.
+func TestSmartFeeEstimatorFailures(t *testing.T) {
+	sft := &smartFeeTester{e: NewSmartFeeEstimator()}
+	runSmartFeeTester(sft, 200)
+
+	before, _ := sft.e.EstimateSmartFee(12, false)
+
+	// Evict a lot of low fee transactions after they were unconfirmed for
+	// ten blocks.
+	var evicted []*TxDesc
+	for i := 0; i < 500; i++ {
+		evicted = append(evicted, sft.newTx(2000))
+	}
+	for i := 0; i < 10; i++ {
+		sft.newBlock(nil)
+	}
+	numTracked := len(sft.e.tracked)
+	for _, txD := range evicted {
+		sft.e.RemoveTransaction(txD.Tx.Hash())
+	}
+	if len(sft.e.tracked) != numTracked-len(evicted) {
+		t.Fatalf("%d transactions are tracked, want %d",
+			len(sft.e.tracked), numTracked-len(evicted))
+	}
+
+	after, _ := sft.e.EstimateSmartFee(12, false)
+	if after <= before {
+		t.Fatalf("estimate did not increase after failures: got %v, "+
+			"before %v", after, before)
+	}
+}
+
+// TestSmartFeeEstimatorSave tests saving and restoring a SmartFeeEstimator.
+func TestSmartFeeEstimatorSave(t *testing.T) {
+	sft := &smartFeeTester{e: NewSmartFeeEstimator()}
+	runSmartFeeTester(sft, 100)
+
+	state := sft.e.Save()
+	restored, err := RestoreSmartFeeEstimator(state)
+	if err != nil {
+		t.Fatalf("RestoreSmartFeeEstimator: unexpected error: %v", err)
+	}
+	if !bytes.Equal(restored.Save(), state) {
+		t.Fatalf("restored fee estimator saves a different state")
+	}
+
+	// The restored estimator provides the same estimates based on the
+	// historical data.
+	for _, confTarget := range []uint32{2, 6, 12, 40} {
+		for _, conservative := range []bool{false, true} {
+			wantRate, wantBlocks := sft.e.EstimateSmartFee(
+				confTarget, conservative)
+			rate, blocks := restored.EstimateSmartFee(confTarget,
+				conservative)
+			if rate != wantRate || blocks != wantBlocks {
+				t.Errorf("target %d: got rate %v for %d blocks, "+
+					"want rate %v for %d blocks", confTarget,
+					rate, blocks, wantRate, wantBlocks)
+			}
+		}
+	}
+
+	// Blocks at or below the best height of the saved state are ignored.
+	restoredTester := &smartFeeTester{e: restored, height: sft.height - 1}
+	restoredTester.newBlock(nil)
+	if restored.bestSeenHeight != sft.height {
+		t.Fatalf("unexpected best height %d", restored.bestSeenHeight)
+	}
+
+	// Truncated and corrupted states must be rejected.
+	for i := 0; i < len(state); i += len(state)/50 + 1 {
+		if _, err := RestoreSmartFeeEstimator(state[:i]); err == nil {
+			t.Errorf("RestoreSmartFeeEstimator did not return an "+
+				"error for state truncated to %d bytes", i)
+		}
+	}
+	badVersion := append([]byte(nil), state...)
+	badVersion[3]++
+	if _, err := RestoreSmartFeeEstimator(badVersion); err == nil {
+		t.Errorf("RestoreSmartFeeEstimator did not return an error " +
+			"for an unknown version")
+	}
+}
+
+// TestMigrateFeeEstimatorState tests migrating the state saved by the fee
+// estimator of earlier versions to a SmartFeeEstimator.
+func TestMigrateFeeEstimatorState(t *testing.T) {
+	const lastKnownHeight = 200
+
+	// Serialize a state where ten transactions paying 10 satoshis per
+	// virtual byte confirmed in the next block and ten transactions paying
+	// 2 satoshis per virtual byte confirmed after six blocks at every
+	// height, along with a transaction that is still unconfirmed.
+	var txs []legacyObservedTx
+	for height := int32(2); height <= lastKnownHeight; height++ {
+		for i := 0; i < 10; i++ {
+			txs = append(txs, legacyObservedTx{
+				feeRate:  10,
+				observed: height - 1,
+				mined:    height,
+			})
+			if height > 6 {
+				txs = append(txs, legacyObservedTx{
+					feeRate:  2,
+					observed: height - 6,
+					mined:    height,
+				})
+			}
+		}
+	}
+	txs = append(txs, legacyObservedTx{
+		feeRate:  1,
+		observed: lastKnownHeight,
+		mined:    mining.UnminedHeight,
+	})
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint32{
+		legacyEstimateFeeSaveVersion, 2, 100, 10, 3, lastKnownHeight,
+		lastKnownHeight, uint32(len(txs)),
+	})
+	for i := range txs {
+		txs[i].hash[0] = byte(i)
+		txs[i].hash[1] = byte(i >> 8)
+		binary.Write(&buf, binary.BigEndian, txs[i].hash)
+		binary.Write(&buf, binary.BigEndian, txs[i].feeRate)
+		binary.Write(&buf, binary.BigEndian, txs[i].observed)
+		binary.Write(&buf, binary.BigEndian, txs[i].mined)
+	}
+
+	// The bins and the blocks kept for rollbacks are not migrated, so
+	// leave them empty.
+	binary.Write(&buf, binary.BigEndian, make([]uint32, 25+1))
+	state := buf.Bytes()
+
+	e, err := MigrateFeeEstimatorState(state)
+	if err != nil {
+		t.Fatalf("MigrateFeeEstimatorState: unexpected error: %v", err)
+	}
+	if e.bestSeenHeight != lastKnownHeight {
+		t.Fatalf("unexpected best height %d", e.bestSeenHeight)
+	}
+	if e.historicalFirst != 2 || e.historicalBest != lastKnownHeight {
+		t.Fatalf("unexpected recorded block range %d-%d",
+			e.historicalFirst, e.historicalBest)
+	}
+
+	tests := []struct {
+		confTarget uint32
+		rate       BtcPerKilobyte
+		blocks     uint32
+	}{
+		{2, satoshiPerKB(10000), 2},
+		{12, satoshiPerKB(2000), 12},
+	}
+	for _, test := range tests {
+		rate, blocks := e.EstimateSmartFee(test.confTarget, false)
+		if rate != test.rate || blocks != test.blocks {
+			t.Errorf("target %d: got rate %v for %d blocks, want "+
+				"rate %v for %d blocks", test.confTarget, rate,
+				blocks, test.rate, test.blocks)
+		}
+	}
+
+	// Truncated states and states of an unknown version must be rejected.
+	if _, err := MigrateFeeEstimatorState(state[:40]); err == nil {
+		t.Errorf("MigrateFeeEstimatorState did not return an error " +
+			"for a truncated state")
+	}
+	badVersion := append([]byte(nil), state...)
+	badVersion[3]++
+	if _, err := MigrateFeeEstimatorState(badVersion); err == nil {
+		t.Errorf("MigrateFeeEstimatorState did not return an error " +
+			"for an unknown version")
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxPackageCount is the maximum number of transactions in a package which
+// can be tested with TestAccept.
+const MaxPackageCount = 25
+
+// TestAcceptResult houses the result of testing whether a transaction of a
+// package would be accepted to the pool.
+type TestAcceptResult struct {
+	// Tx is the tested transaction.
+	Tx *btcutil.Tx
+
+	// Err is the reason the transaction would be rejected.  It is nil
+	// when the transaction would be accepted.
+	Err error
+
+	// VSize is the virtual size of the transaction.  It is only set when
+	// the transaction would be accepted.
+	VSize int64
+
+	// Fee is the fee paid by the transaction in satoshi.  It is only set
+	// when the transaction would be accepted.
+	Fee int64
+}
+
+// txPackage houses the state of the transactions of a package which were
+// validated by maybeAcceptTransaction without adding them to the pool so the
+// following transactions of the package can be validated as if they were.
+type txPackage struct {
+	// numTxns is the total number of transactions in the package.
+	numTxns int
+
+	// maxFeeRate is the maximum fee rate in satoshi per kilo virtual byte
+	// the package transactions may pay.  Zero disables the limit.
+	maxFeeRate btcutil.Amount
+
+	// descs houses the package transactions which were validated so far
+	// along with copies of the descriptors of their ancestors in the pool.
+	// The package statistics of all of them are updated as if the package
+	// transactions were in the pool.
+	descs map[chainhash.Hash]*TxDesc
+
+	// ancestors houses the ancestors in the pool and the package of each
+	// package transaction which was validated so far.
+	ancestors map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx
+
+	// spent houses the outpoints spent by the package transactions which
+	// were validated so far.
+	spent map[wire.OutPoint]*btcutil.Tx
+}
+
+// newTxPackage returns a new empty package for the passed number of
+// transactions and maximum fee rate.
+func newTxPackage(numTxns int, maxFeeRate btcutil.Amount) *txPackage {
+	return &txPackage{
+		numTxns:    numTxns,
+		maxFeeRate: maxFeeRate,
+		descs:      make(map[chainhash.Hash]*TxDesc),
+		ancestors:  make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx),
+		spent:      make(map[wire.OutPoint]*btcutil.Tx),
+	}
+}
+
+// checkDoubleSpend ensures the passed transaction doesn't spend any outputs
+// which are already spent by other transactions of the package.  Replacing
+// transactions in the pool is only supported when the package consists of a
+// single transaction.
+func (p *txPackage) checkDoubleSpend(tx *btcutil.Tx, isReplacement bool) error {
+	if isReplacement && p.numTxns > 1 {
+		str := fmt.Sprintf("transaction %v replaces transactions in "+
+			"the memory pool which is not supported in packages",
+			tx.Hash())
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		conflict, ok := p.spent[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+		str := fmt.Sprintf("output %v already spent by transaction %v "+
+			"in the package", txIn.PreviousOutPoint, conflict.Hash())
+		return txRuleError(wire.RejectDuplicate, str)
+	}
+
+	return nil
+}
+
+// checkMaxFeeRate ensures the passed transaction paying the passed fee doesn't
+// exceed the maximum fee rate of the package, which guards against
+// accidentally overpaying.
+func (p *txPackage) checkMaxFeeRate(tx *btcutil.Tx, fee int64) error {
+	if p.maxFeeRate == 0 {
+		return nil
+	}
+
+	vsize := GetTxVirtualSize(tx)
+	if fee*1000 <= int64(p.maxFeeRate)*vsize {
+		return nil
+	}
+	return txRuleError(wire.RejectNonstandard, "max-fee-exceeded")
+}
+
+// addInputUtxos adds the outputs of the package transactions which are
+// referenced by the passed transaction and missing from the passed view.
+func (p *txPackage) addInputUtxos(tx *btcutil.Tx,
+	utxoView *blockchain.UtxoViewpoint) {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(*prevOut)
+		if entry != nil && !entry.IsSpent() {
+			continue
+		}
+
+		if _, exists := p.ancestors[prevOut.Hash]; exists {
+			// AddTxOut ignores out of range index values, so it is
+			// safe to call without bounds checking here.
+			utxoView.AddTxOut(p.descs[prevOut.Hash].Tx,
+				prevOut.Index, mining.UnminedHeight)
+		}
+	}
+}
+
+// packageTxAncestors returns all of the unconfirmed ancestors of the passed
+// transaction in the pool and in the optional package.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) packageTxAncestors(tx *btcutil.Tx,
+	pkg *txPackage) map[chainhash.Hash]*btcutil.Tx {
+
+	ancestors := mp.txAncestors(tx, nil)
+	if pkg == nil {
+		return ancestors
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		moreAncestors, ok := pkg.ancestors[parentHash]
+		if !ok {
+			continue
+		}
+		ancestors[parentHash] = pkg.descs[parentHash].Tx
+		for hash, ancestor := range moreAncestors {
+			ancestors[hash] = ancestor
+		}
+	}
+
+	return ancestors
+}
+
+// packageTxDesc returns the descriptor of the passed unconfirmed transaction
+// in the optional package, falling back to the one in the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) packageTxDesc(hash chainhash.Hash, pkg *txPackage) *TxDesc {
+	if pkg != nil {
+		if txD, ok := pkg.descs[hash]; ok {
+			return txD
+		}
+	}
+	return mp.pool[hash]
+}
+
+// addPackageTx adds the passed transaction, which was validated as if the
+// transactions of the package were in the pool, to the package.  It is the
+// counterpart of addTransaction when testing packages.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) addPackageTx(pkg *txPackage,
+	utxoView *blockchain.UtxoViewpoint, tx *btcutil.Tx, height int32,
+	fee int64) *TxDesc {
+
+	vsize := GetTxVirtualSize(tx)
+	txD := &TxDesc{
+		TxDesc: mining.TxDesc{
+			Tx:       tx,
+			Added:    time.Now(),
+			Height:   height,
+			Fee:      fee,
+			FeePerKB: fee * 1000 / vsize,
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
+		},
+		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
+		AncestorCount:    1,
+		AncestorSize:     vsize,
+		DescendantCount:  1,
+		DescendantSize:   vsize,
+	}
+	modifiedFee := txD.ModifiedFee()
+	txD.AncestorFees = modifiedFee
+	txD.DescendantFees = modifiedFee
+
+	// The transaction joins the descendant packages of its ancestors.
+	// Copies of the descriptors of its ancestors in the pool are updated
+	// to leave the pool untouched.
+	ancestors := mp.packageTxAncestors(tx, pkg)
+	for hash := range ancestors {
+		ancestor, ok := pkg.descs[hash]
+		if !ok {
+			descCopy := *mp.pool[hash]
+			ancestor = &descCopy
+			pkg.descs[hash] = ancestor
+		}
+		ancestor.DescendantCount++
+		ancestor.DescendantSize += vsize
+		ancestor.DescendantFees += modifiedFee
+
+		txD.AncestorCount++
+		txD.AncestorSize += GetTxVirtualSize(ancestor.Tx)
+		txD.AncestorFees += ancestor.ModifiedFee()
+	}
+
+	pkg.descs[*tx.Hash()] = txD
+	pkg.ancestors[*tx.Hash()] = ancestors
+	for _, txIn := range tx.MsgTx().TxIn {
+		pkg.spent[txIn.PreviousOutPoint] = tx
+	}
+
+	return txD
+}
+
+// checkPackage ensures the passed transactions form a valid package to be
+// tested.  They must not exceed the maximum number of transactions in a
+// package, must not contain duplicates and must be sorted such that each
+// transaction follows all of its parents in the package.
+func checkPackage(txns []*btcutil.Tx) error {
+	if len(txns) == 0 {
+		return txRuleError(wire.RejectInvalid, "package is empty")
+	}
+	if len(txns) > MaxPackageCount {
+		str := fmt.Sprintf("package contains %d transactions which "+
+			"exceeds the maximum of %d", len(txns), MaxPackageCount)
+		return txRuleError(wire.RejectInvalid, str)
+	}
+
+	later := make(map[chainhash.Hash]struct{}, len(txns))
+	for _, tx := range txns {
+		if _, exists := later[*tx.Hash()]; exists {
+			str := fmt.Sprintf("package contains transaction %v "+
+				"more than once", tx.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+		later[*tx.Hash()] = struct{}{}
+	}
+	for _, tx := range txns {
+		delete(later, *tx.Hash())
+		for _, txIn := range tx.MsgTx().TxIn {
+			parentHash := txIn.PreviousOutPoint.Hash
+			if _, exists := later[parentHash]; exists {
+				str := fmt.Sprintf("package is not sorted: "+
+					"transaction %v spends later "+
+					"transaction %v", tx.Hash(), parentHash)
+				return txRuleError(wire.RejectInvalid, str)
+			}
+		}
+	}
+
+	return nil
+}
+
+// TestAccept tests whether each of the passed transactions would be accepted
+// to the pool, applying the same validation and policy as
+// MaybeAcceptTransaction, without adding them to the pool or changing it
+// otherwise.  The transactions form a package which must be sorted such that
+// each transaction follows all of its parents in the package.  Each
+// transaction is tested as if the transactions preceding it in the package
+// which would be accepted were already in the pool, so transactions spending
+// outputs of rejected transactions are rejected as well.  Replacing
+// transactions in the pool is only supported when testing a single
+// transaction.
+//
+// Transactions paying a fee rate above the passed maximum fee rate in satoshi
+// per kilo virtual byte are rejected, so their descendants in the package are
+// rejected as well.  A maximum fee rate of zero disables the limit.
+//
+// Note that a transaction which would be accepted might still be evicted right
+// away when the pool is full.
+//
+// An error is returned when the transactions don't form a valid package,
+// otherwise there is a result for each transaction in the same order.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TestAccept(txns []*btcutil.Tx,
+	maxFeeRate btcutil.Amount) ([]*TestAcceptResult, error) {
+
+	if err := checkPackage(txns); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access.  The write lock is needed since the
+	// rolling minimum fee rate is updated during validation.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	pkg := newTxPackage(len(txns), maxFeeRate)
+	results := make([]*TestAcceptResult, 0, len(txns))
+	for _, tx := range txns {
+		result := &TestAcceptResult{Tx: tx}
+		results = append(results, result)
+
+		missingParents, txD, err := mp.maybeAcceptTransaction(tx, true,
+			false, true, true, pkg)
+		switch {
+		case err != nil:
+			result.Err = err
+
+		case len(missingParents) > 0:
+			str := fmt.Sprintf("transaction %v references outputs "+
+				"of unknown or fully-spent transaction %v",
+				tx.Hash(), missingParents[0])
+			result.Err = txRuleError(wire.RejectDuplicate, str)
+
+		default:
+			result.VSize = GetTxVirtualSize(tx)
+			result.Fee = txD.Fee
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestTestAccept ensures testing packages of transactions reports whether each
+// of them would be accepted to the pool without changing it.
+func TestTestAccept(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	coinbase := ctx.addCoinbaseTx(6)
+	outputs := make([]spendableOutput, 0, 6)
+	for i := uint32(0); i < 6; i++ {
+		outputs = append(outputs, txOutToSpendableOut(coinbase, i))
+	}
+
+	// createTx creates a transaction spending the passed outputs with the
+	// passed fee.
+	createTx := func(inputs []spendableOutput, numOutputs uint32,
+		fee btcutil.Amount, signalsReplacement bool) *btcutil.Tx {
+
+		t.Helper()
+
+		tx, err := harness.CreateSignedTx(inputs, numOutputs, fee,
+			signalsReplacement)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+
+	// testAccept tests the passed package and ensures the results match
+	// the passed expected acceptance and that the pool is unchanged.
+	testAccept := func(txns []*btcutil.Tx, allowed []bool) []*TestAcceptResult {
+		t.Helper()
+
+		poolCount := txPool.Count()
+		inPool := make([]bool, len(txns))
+		for i, tx := range txns {
+			inPool[i] = txPool.IsTransactionInPool(tx.Hash())
+		}
+		results, err := txPool.TestAccept(txns, 0)
+		if err != nil {
+			t.Fatalf("unable to test package: %v", err)
+		}
+		if len(results) != len(txns) {
+			t.Fatalf("unexpected number of results %d, want %d",
+				len(results), len(txns))
+		}
+		for i, result := range results {
+			if result.Tx != txns[i] {
+				t.Fatalf("result %d is for transaction %v, "+
+					"want %v", i, result.Tx.Hash(),
+					txns[i].Hash())
+			}
+			if (result.Err == nil) != allowed[i] {
+				t.Fatalf("transaction %d allowed: %v, want "+
+					"%v (err: %v)", i, result.Err == nil,
+					allowed[i], result.Err)
+			}
+			if result.Err != nil {
+				if _, ok := result.Err.(RuleError); !ok {
+					t.Fatalf("unexpected error type %T "+
+						"for transaction %d", result.Err,
+						i)
+				}
+			}
+			testPoolMembership(ctx, result.Tx, false, inPool[i])
+		}
+		if txPool.Count() != poolCount {
+			t.Fatalf("pool size changed from %d to %d", poolCount,
+				txPool.Count())
+		}
+		return results
+	}
+
+	// A package where each transaction spends its parent is accepted and
+	// reports the virtual size and fee of each transaction.
+	parent := createTx(outputs[:1], 2, 1000, false)
+	child := createTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 2000, false)
+	grandchild := createTx([]spendableOutput{
+		txOutToSpendableOut(child, 0),
+		txOutToSpendableOut(parent, 1),
+	}, 1, 3000, false)
+	results := testAccept([]*btcutil.Tx{parent, child, grandchild},
+		[]bool{true, true, true})
+	for i, fee := range []int64{1000, 2000, 3000} {
+		if results[i].Fee != fee {
+			t.Fatalf("unexpected fee %d for transaction %d, want "+
+				"%d", results[i].Fee, i, fee)
+		}
+		vsize := GetTxVirtualSize(results[i].Tx)
+		if results[i].VSize != vsize {
+			t.Fatalf("unexpected virtual size %d for transaction "+
+				"%d, want %d", results[i].VSize, i, vsize)
+		}
+	}
+
+	// A child on its own is rejected since its parent is unknown.
+	testAccept([]*btcutil.Tx{child}, []bool{false})
+
+	// The descendants of a rejected transaction are rejected as well.
+	orphan := createTx([]spendableOutput{{
+		outPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+		amount:   outputs[1].amount,
+	}}, 1, 1000, false)
+	dependent := createTx([]spendableOutput{
+		txOutToSpendableOut(orphan, 0),
+	}, 1, 1000, false)
+	testAccept([]*btcutil.Tx{orphan, dependent}, []bool{false, false})
+
+	// Transactions of a package double spending each other are rejected.
+	spend1 := createTx(outputs[2:3], 1, 1000, false)
+	spend2 := createTx(outputs[2:3], 1, 2000, false)
+	testAccept([]*btcutil.Tx{spend1, spend2}, []bool{true, false})
+
+	// Once the parent is in the pool, the rest of the package builds on
+	// it and the package statistics of the parent are left untouched.
+	_, err = txPool.ProcessTransaction(parent, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process transaction: %v", err)
+	}
+	testAccept([]*btcutil.Tx{parent, child, grandchild},
+		[]bool{false, true, true})
+	parentDesc := txPool.pool[*parent.Hash()]
+	if parentDesc.DescendantCount != 1 ||
+		parentDesc.DescendantSize != GetTxVirtualSize(parent) {
+
+		t.Fatalf("unexpected descendant package %d/%d",
+			parentDesc.DescendantCount, parentDesc.DescendantSize)
+	}
+
+	// The package limits take the pool and the package into account.
+	txPool.cfg.Policy.MaxAncestorCount = 2
+	testAccept([]*btcutil.Tx{child, grandchild}, []bool{true, false})
+	txPool.cfg.Policy.MaxAncestorCount = DefaultMaxAncestorCount
+	txPool.cfg.Policy.MaxDescendantCount = 2
+	testAccept([]*btcutil.Tx{child, grandchild}, []bool{true, false})
+	txPool.cfg.Policy.MaxDescendantCount = DefaultMaxDescendantCount
+
+	// Replacing a transaction in the pool is only supported when testing
+	// a single transaction.
+	replaceable := ctx.addSignedTx(outputs[4:5], 1, 1000, true, false)
+	replacement := createTx(outputs[4:5], 1, 5000, true)
+	unrelated := createTx(outputs[5:6], 1, 1000, false)
+	testAccept([]*btcutil.Tx{replacement}, []bool{true})
+	testAccept([]*btcutil.Tx{replacement, unrelated}, []bool{false, true})
+	testPoolMembership(ctx, replaceable, false, true)
+
+	// A transaction paying more than the maximum fee rate is rejected
+	// along with its descendants in the package, which can't spend its
+	// outputs then.
+	expensive := createTx(outputs[5:6], 1, 100000, false)
+	cheapChild := createTx([]spendableOutput{
+		txOutToSpendableOut(expensive, 0),
+	}, 1, 1000, false)
+	maxFeeRate := btcutil.Amount(50000 * 1000 /
+		GetTxVirtualSize(expensive))
+	results, err = txPool.TestAccept(
+		[]*btcutil.Tx{expensive, cheapChild}, maxFeeRate,
+	)
+	if err != nil {
+		t.Fatalf("unable to test package: %v", err)
+	}
+	if results[0].Err == nil ||
+		results[0].Err.Error() != "max-fee-exceeded" {
+
+		t.Fatalf("unexpected error for expensive transaction: %v",
+			results[0].Err)
+	}
+	if code, _ := extractRejectCode(results[1].Err); code !=
+		wire.RejectDuplicate {
+
+		t.Fatalf("unexpected error for child of expensive "+
+			"transaction: %v", results[1].Err)
+	}
+
+	// Without the limit, both of them are accepted.
+	testAccept([]*btcutil.Tx{expensive, cheapChild}, []bool{true, true})
+
+	// Packages which are not sorted, contain duplicates or are too large
+	// are rejected as a whole.
+	chain, err := harness.CreateTxChain(outputs[3], MaxPackageCount+1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	invalidPackages := [][]*btcutil.Tx{
+		nil,
+		{chain[1], chain[0]},
+		{chain[0], chain[1], chain[0]},
+		chain,
+	}
+	for i, txns := range invalidPackages {
+		_, err := txPool.TestAccept(txns, 0)
+		if _, ok := err.(RuleError); !ok {
+			t.Fatalf("package %d: unexpected error %v", i, err)
+		}
+		rejectCode, _ := extractRejectCode(err)
+		if rejectCode != wire.RejectInvalid {
+			t.Fatalf("package %d: unexpected reject code %v", i,
+				rejectCode)
+		}
+	}
+	allowed := make([]bool, MaxPackageCount)
+	for i := range allowed {
+		allowed[i] = true
+	}
+	testAccept(chain[:MaxPackageCount], allowed)
+}
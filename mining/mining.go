@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -51,6 +52,17 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is an adjustment in Satoshi to the fee of the transaction
+	// which is only used to prioritize it.  The fee actually paid by the
+	// transaction remains Fee.
+	FeeDelta int64
+}
+
+// ModifiedFee returns the fee of the transaction adjusted by its fee delta,
+// which is used to prioritize the transaction.
+func (txD *TxDesc) ModifiedFee() int64 {
+	return txD.Fee + txD.FeeDelta
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -77,15 +89,133 @@ type TxSource interface {
 // which have not been mined into a block yet.
 type txPrioItem struct {
 	tx       *btcutil.Tx
-	fee      int64
 	priority float64
+	weight   int64
+	size     int64
+
+	// fee is the fee of the transaction adjusted by its fee delta, which
+	// is used to prioritize it.  The fee actually paid by the transaction
+	// is fee minus feeDelta.
+	fee      int64
+	feeDelta int64
+
+	// feePerKB is the fee per kilobyte of the ancestor package of the
+	// transaction, which consists of the transaction along with all of
+	// its ancestors that have not been included in the block yet.  It is
+	// the fee per kilobyte of the transaction itself when it doesn't
+	// depend on any other transactions in the source pool.
 	feePerKB int64
 
+	// pkgFee and pkgSize are the total fee and virtual size of the
+	// ancestor package of the transaction.
+	pkgFee  int64
+	pkgSize int64
+
 	// dependsOn holds a map of transaction hashes which this one depends
 	// on.  It will only be set when the transaction references other
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// ancestors and descendants hold all transactions in the source pool
+	// this one depends on directly or indirectly and all transactions that
+	// depend on this one respectively.
+	ancestors   map[chainhash.Hash]*txPrioItem
+	descendants map[chainhash.Hash]*txPrioItem
+
+	// included is set once the transaction is added to the block, while
+	// rejected is set when the transaction can't be added to the block.
+	included bool
+	rejected bool
+
+	// index is the index of the item in the priority queue, or -1 if it
+	// is not in the queue.
+	index int
+}
+
+// calcFeePerKB returns the fee per kilobyte of the ancestor package of the
+// transaction.
+func (item *txPrioItem) calcFeePerKB() int64 {
+	if item.pkgSize == 0 {
+		return 0
+	}
+	return item.pkgFee * 1000 / item.pkgSize
+}
+
+// ancestorPackage returns the transactions that must be added to a block in
+// order to add the transaction.  Those are its ancestors that are not in the
+// block yet, ordered so each transaction comes after the ones it depends on,
+// followed by the transaction itself.  False is returned when the transaction
+// or one of its ancestors was rejected.
+func (item *txPrioItem) ancestorPackage() ([]*txPrioItem, bool) {
+	if item.rejected {
+		return nil, false
+	}
+
+	pkg := make([]*txPrioItem, 0, len(item.ancestors)+1)
+	for _, ancestor := range item.ancestors {
+		if ancestor.included {
+			continue
+		}
+		if ancestor.rejected {
+			return nil, false
+		}
+		pkg = append(pkg, ancestor)
+	}
+
+	// A transaction has more ancestors than any of its own ancestors, so
+	// sorting by the number of ancestors orders them by their
+	// dependencies.
+	sort.Slice(pkg, func(i, j int) bool {
+		return len(pkg[i].ancestors) < len(pkg[j].ancestors)
+	})
+	return append(pkg, item), true
+}
+
+// linkAncestors determines the ancestors and descendants of all the passed
+// items along with the fee and size of their ancestor packages.  Items which
+// depend on transactions that are not available are rejected.
+func linkAncestors(items map[chainhash.Hash]*txPrioItem) {
+	var link func(item *txPrioItem)
+	link = func(item *txPrioItem) {
+		if item.ancestors != nil {
+			return
+		}
+		item.ancestors = make(map[chainhash.Hash]*txPrioItem)
+		for hash := range item.dependsOn {
+			parent, ok := items[hash]
+			if !ok {
+				item.rejected = true
+				continue
+			}
+			link(parent)
+			if parent.rejected {
+				item.rejected = true
+			}
+			item.ancestors[hash] = parent
+			for ancestorHash, ancestor := range parent.ancestors {
+				item.ancestors[ancestorHash] = ancestor
+			}
+		}
+	}
+	for _, item := range items {
+		link(item)
+	}
+
+	for hash, item := range items {
+		item.pkgFee = item.fee
+		item.pkgSize = item.size
+		for _, ancestor := range item.ancestors {
+			item.pkgFee += ancestor.fee
+			item.pkgSize += ancestor.size
+			if ancestor.descendants == nil {
+				ancestor.descendants = make(
+					map[chainhash.Hash]*txPrioItem)
+			}
+			ancestor.descendants[hash] = item
+		}
+		item.feePerKB = item.calcFeePerKB()
+	}
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -116,12 +246,16 @@ func (pq *txPriorityQueue) Less(i, j int) bool {
 // part of the heap.Interface implementation.
 func (pq *txPriorityQueue) Swap(i, j int) {
 	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
 func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+	item := x.(*txPrioItem)
+	item.index = len(pq.items)
+	pq.items = append(pq.items, item)
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
@@ -131,6 +265,7 @@ func (pq *txPriorityQueue) Pop() interface{} {
 	item := pq.items[n-1]
 	pq.items[n-1] = nil
 	pq.items = pq.items[0 : n-1]
+	item.index = -1
 	return item
 }
 
@@ -404,6 +539,14 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // dependency map so they can be added to the priority queue once the
 // transactions they depend on have been included.
 //
+// When prioritizing by fee, every transaction is considered together with its
+// ancestor package, which consists of the transaction along with all of the
+// transactions it depends on in the source pool that are not in the block yet.
+// The fee per kilobyte of the whole package is used and the package is added
+// to the block at once, so a child transaction paying a high fee pulls its
+// low-fee parents into the block (child-pays-for-parent).  The packages of the
+// remaining transactions are updated as their ancestors are added.
+//
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
 // the priority queue is updated to prioritize by fees per kilobyte (then
@@ -490,6 +633,10 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	// in the block once each transaction has been included.
 	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
 
+	// prioItems holds all transactions which are candidates for inclusion
+	// in the block keyed by their hash.
+	prioItems := make(map[chainhash.Hash]*txPrioItem, len(sourceTxns))
+
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
 	// coinbase.  This allows the code below to simply append details about
@@ -535,7 +682,7 @@ mempoolLoop:
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
 		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
+		prioItem := &txPrioItem{tx: tx, index: -1}
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
 			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
@@ -575,15 +722,16 @@ mempoolLoop:
 		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
 			nextBlockHeight)
 
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
-		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+		// Record the fee along with the weight and virtual size of the
+		// transaction.  The fee per kilobyte of its ancestor package is
+		// calculated below once all dependencies are known.
+		prioItem.fee = txDesc.ModifiedFee()
+		prioItem.feeDelta = txDesc.FeeDelta
+		prioItem.weight = blockchain.GetTransactionWeight(tx)
+		prioItem.size = (prioItem.weight +
+			(blockchain.WitnessScaleFactor - 1)) /
+			blockchain.WitnessScaleFactor
+		prioItems[*tx.Hash()] = prioItem
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
@@ -591,6 +739,24 @@ mempoolLoop:
 		mergeUtxoView(blockUtxos, utxos)
 	}
 
+	// Determine the ancestor packages of all transactions and add them to
+	// the priority queue to mark them ready for inclusion in the block.
+	// When sorting by priority, only transactions without dependencies
+	// are ready.  When sorting by fee, every transaction is ready since
+	// it is added along with its ancestor package.
+	linkAncestors(prioItems)
+	for _, prioItem := range prioItems {
+		if prioItem.rejected {
+			log.Tracef("Skipping tx %s because it depends on a "+
+				"transaction which is not available",
+				prioItem.tx.Hash())
+			continue
+		}
+		if sortedByFee || prioItem.dependsOn == nil {
+			heap.Push(priorityQueue, prioItem)
+		}
+	}
+
 	log.Tracef("Priority queue len %d, dependers len %d",
 		priorityQueue.Len(), len(dependers))
 
@@ -614,24 +780,162 @@ mempoolLoop:
 
 	witnessIncluded := false
 
+	// addTx adds the passed transaction to the block once it passes all
+	// of the checks that apply to the individual transaction.  Those
+	// transactions which don't are rejected, which also prevents all of
+	// the transactions that depend on them from being added.
+	addTx := func(prioItem *txPrioItem) bool {
+		tx := prioItem.tx
+
+		// Grab any transactions which depend on this one.
+		deps := dependers[*tx.Hash()]
+
+		// Enforce maximum block size.  Also check for overflow.
+		txWeight := uint32(prioItem.weight)
+		blockPlusTxWeight := blockWeight + txWeight
+		if blockPlusTxWeight < blockWeight ||
+			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+
+			log.Tracef("Skipping tx %s because it would exceed "+
+				"the max block weight", tx.Hash())
+			logSkippedDeps(tx, deps)
+			prioItem.rejected = true
+			return false
+		}
+
+		// Enforce maximum signature operation cost per block.  Also
+		// check for overflow.
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+			blockUtxos, true, segwitActive)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"GetSigOpCost: %v", tx.Hash(), err)
+			logSkippedDeps(tx, deps)
+			prioItem.rejected = true
+			return false
+		}
+		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
+			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+			log.Tracef("Skipping tx %s because it would "+
+				"exceed the maximum sigops per block", tx.Hash())
+			logSkippedDeps(tx, deps)
+			prioItem.rejected = true
+			return false
+		}
+
+		// Ensure the transaction inputs pass all of the necessary
+		// preconditions before allowing it to be added to the block.
+		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
+			blockUtxos, g.chainParams)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"CheckTransactionInputs: %v", tx.Hash(), err)
+			logSkippedDeps(tx, deps)
+			prioItem.rejected = true
+			return false
+		}
+		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
+			txscript.StandardVerifyFlags, g.sigCache,
+			g.hashCache)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"ValidateTransactionScripts: %v", tx.Hash(), err)
+			logSkippedDeps(tx, deps)
+			prioItem.rejected = true
+			return false
+		}
+
+		// Spend the transaction inputs in the block utxo view and add
+		// an entry for it to ensure any transactions which reference
+		// this one have it available as an input and can ensure they
+		// aren't double spending.
+		spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+		// Add the transaction to the block, increment counters, and
+		// save the fees and signature operation counts to the block
+		// template.
+		blockTxns = append(blockTxns, tx)
+		blockWeight += txWeight
+		blockSigOpCost += int64(sigOpCost)
+		totalFees += prioItem.fee - prioItem.feeDelta
+		txFees = append(txFees, prioItem.fee-prioItem.feeDelta)
+		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+
+		log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)",
+			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
+
+		// The transaction is no longer part of the ancestor packages of
+		// the transactions which depend on it, so update their package
+		// fee rates along with their position in the priority queue.
+		prioItem.included = true
+		if prioItem.index >= 0 {
+			heap.Remove(priorityQueue, prioItem.index)
+		}
+		for _, item := range prioItem.descendants {
+			item.pkgFee -= prioItem.fee
+			item.pkgSize -= prioItem.size
+			item.feePerKB = item.calcFeePerKB()
+			if item.index >= 0 {
+				heap.Fix(priorityQueue, item.index)
+			}
+		}
+
+		// Add transactions which depend on this one (and also do not
+		// have any other unsatisified dependencies) to the priority
+		// queue.
+		for _, item := range deps {
+			// Add the transaction to the priority queue if there
+			// are no more dependencies after this one.
+			delete(item.dependsOn, *tx.Hash())
+			if len(item.dependsOn) == 0 && item.index < 0 &&
+				!item.rejected {
+
+				heap.Push(priorityQueue, item)
+			}
+		}
+
+		return true
+	}
+
 	// Choose which transactions make it into the block.
 	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
+		// Grab the highest priority (or highest fee per kilobyte of the
+		// ancestor package depending on the sort order) transaction
+		// along with the ancestors which must be added before it.
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
 		tx := prioItem.tx
+		pkg, ok := prioItem.ancestorPackage()
+		if !ok {
+			log.Tracef("Skipping tx %s since it depends on a "+
+				"rejected transaction", tx.Hash())
+			continue
+		}
+
+		var pkgWeight uint32
+		pkgHasWitness := false
+		for _, item := range pkg {
+			pkgWeight += uint32(item.weight)
+			if item.tx.HasWitness() {
+				pkgHasWitness = true
+			}
+		}
 
 		switch {
 		// If segregated witness has not been activated yet, then we
 		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
+		case !segwitActive && pkgHasWitness:
+			for _, item := range pkg {
+				if item.tx.HasWitness() {
+					item.rejected = true
+				}
+			}
 			continue
 
 		// Otherwise, Keep track of if we've included a transaction
 		// with witness data or not. If so, then we'll need to include
 		// the witness commitment as the last output in the coinbase
 		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
+		case segwitActive && !witnessIncluded && pkgHasWitness:
 			// If we're about to include a transaction bearing
 			// witness data, then we'll also need to include a
 			// witness commitment in the coinbase transaction.
@@ -660,36 +964,15 @@ mempoolLoop:
 			witnessIncluded = true
 		}
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
-
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+		// Skip the package when it would exceed the maximum block
+		// weight.  The transactions it contains remain eligible on
+		// their own or as part of smaller packages.
+		blockPlusPkgWeight := blockWeight + pkgWeight
+		if blockPlusPkgWeight < blockWeight ||
+			blockPlusPkgWeight >= g.policy.BlockMaxWeight {
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
+			log.Tracef("Skipping tx %s because its package would "+
+				"exceed the max block weight", tx.Hash())
 			continue
 		}
 
@@ -697,39 +980,50 @@ mempoolLoop:
 		// minimum block size.
 		if sortedByFee &&
 			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
+			blockPlusPkgWeight >= g.policy.BlockMinWeight {
 
 			log.Tracef("Skipping tx %s with feePerKB %d "+
 				"< TxMinFreeFee %d and block weight %d >= "+
 				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
+				g.policy.TxMinFreeFee, blockPlusPkgWeight,
 				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
+			logSkippedDeps(tx, dependers[*tx.Hash()])
 			continue
 		}
 
 		// Prioritize by fee per kilobyte once the block is larger than
 		// the priority size or there are no more high-priority
 		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
+		if !sortedByFee && (blockPlusPkgWeight >= g.policy.BlockPrioritySize ||
 			prioItem.priority <= MinHighPriority) {
 
 			log.Tracef("Switching to sort by fees per "+
 				"kilobyte blockSize %d >= BlockPrioritySize "+
 				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
+				blockPlusPkgWeight, g.policy.BlockPrioritySize,
 				prioItem.priority, MinHighPriority)
 
 			sortedByFee = true
 			priorityQueue.SetLessFunc(txPQByFee)
 
+			// Every remaining transaction is ready for inclusion
+			// along with its ancestor package now, so add those
+			// that still have dependencies to the priority queue.
+			for _, item := range prioItems {
+				if item != prioItem && item.index < 0 &&
+					!item.included && !item.rejected {
+
+					heap.Push(priorityQueue, item)
+				}
+			}
+
 			// Put the transaction back into the priority queue and
 			// skip it so it is re-priortized by fees if it won't
 			// fit into the high-priority section or the priority
 			// is too low.  Otherwise this transaction will be the
 			// final one in the high-priority section, so just fall
 			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
+			if blockPlusPkgWeight > g.policy.BlockPrioritySize ||
 				prioItem.priority < MinHighPriority {
 
 				heap.Push(priorityQueue, prioItem)
@@ -737,54 +1031,13 @@ mempoolLoop:
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
-
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+		// Add the ancestor package to the block in dependency order.
+		// Ancestors which are added remain in the block even when a
+		// later transaction of the package fails its checks since they
+		// are valid on their own.
+		for _, item := range pkg {
+			if !addTx(item) {
+				break
 			}
 		}
 	}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
 // TestTxFeePrioHeap ensures the priority queue for transaction fees and
@@ -108,3 +109,98 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestAncestorPackages ensures the ancestor packages of transactions are
+// determined and ordered by their package fee rates as expected.
+func TestAncestorPackages(t *testing.T) {
+	// Create a low fee parent with a high fee child and a medium fee
+	// transaction without dependencies, along with a transaction which
+	// depends on one that is not available.
+	hash := func(b byte) chainhash.Hash { return chainhash.Hash{b} }
+	dependsOn := func(hashes ...chainhash.Hash) map[chainhash.Hash]struct{} {
+		deps := make(map[chainhash.Hash]struct{})
+		for _, h := range hashes {
+			deps[h] = struct{}{}
+		}
+		return deps
+	}
+	parent := &txPrioItem{fee: 100, size: 100, index: -1}
+	child := &txPrioItem{fee: 900, size: 100, index: -1,
+		dependsOn: dependsOn(hash(1))}
+	grandchild := &txPrioItem{fee: 0, size: 200, index: -1,
+		dependsOn: dependsOn(hash(1), hash(2))}
+	other := &txPrioItem{fee: 400, size: 100, index: -1}
+	orphan := &txPrioItem{fee: 10000, size: 100, index: -1,
+		dependsOn: dependsOn(hash(9))}
+	items := map[chainhash.Hash]*txPrioItem{
+		hash(1): parent,
+		hash(2): child,
+		hash(3): grandchild,
+		hash(4): other,
+		hash(5): orphan,
+	}
+	linkAncestors(items)
+
+	tests := []struct {
+		name        string
+		item        *txPrioItem
+		feePerKB    int64
+		ancestors   int
+		descendants int
+		rejected    bool
+	}{
+		{"parent", parent, 1000, 0, 2, false},
+		{"child", child, 5000, 1, 1, false},
+		{"grandchild", grandchild, 2500, 2, 0, false},
+		{"other", other, 4000, 0, 0, false},
+		{"orphan", orphan, 100000, 0, 0, true},
+	}
+	for _, test := range tests {
+		if test.item.feePerKB != test.feePerKB {
+			t.Errorf("%s: got feePerKB %d, want %d", test.name,
+				test.item.feePerKB, test.feePerKB)
+		}
+		if len(test.item.ancestors) != test.ancestors {
+			t.Errorf("%s: got %d ancestors, want %d", test.name,
+				len(test.item.ancestors), test.ancestors)
+		}
+		if len(test.item.descendants) != test.descendants {
+			t.Errorf("%s: got %d descendants, want %d", test.name,
+				len(test.item.descendants), test.descendants)
+		}
+		if test.item.rejected != test.rejected {
+			t.Errorf("%s: got rejected %v, want %v", test.name,
+				test.item.rejected, test.rejected)
+		}
+	}
+
+	// The package of the grandchild lists its ancestors before it.
+	pkg, ok := grandchild.ancestorPackage()
+	if !ok || len(pkg) != 3 || pkg[0] != parent || pkg[1] != child ||
+		pkg[2] != grandchild {
+
+		t.Fatalf("unexpected grandchild package %v (ok %v)", pkg, ok)
+	}
+	if _, ok := orphan.ancestorPackage(); ok {
+		t.Fatalf("package of rejected transaction is available")
+	}
+
+	// The child package is selected before the transaction without
+	// dependencies even though the parent pays a lower fee rate.
+	priorityQueue := newTxPriorityQueue(len(items), true)
+	for _, item := range []*txPrioItem{parent, child, grandchild, other} {
+		heap.Push(priorityQueue, item)
+	}
+	if item := heap.Pop(priorityQueue).(*txPrioItem); item != child {
+		t.Fatalf("unexpected first package with feePerKB %d",
+			item.feePerKB)
+	}
+	if child.index != -1 {
+		t.Fatalf("popped item has index %d", child.index)
+	}
+	for _, item := range priorityQueue.items {
+		if priorityQueue.items[item.index] != item {
+			t.Fatalf("item has wrong index %d", item.index)
+		}
+	}
+}
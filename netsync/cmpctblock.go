@@ -0,0 +1,418 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// maxPartialBlocksPerPeer is the maximum number of partial blocks
+	// which may wait for missing transactions from a single peer at a
+	// time.  Further compact blocks from the peer are requested as full
+	// blocks instead.
+	maxPartialBlocksPerPeer = 2
+
+	// maxPartialBlocks is the maximum number of partial blocks which may
+	// wait for missing transactions from all peers combined.
+	maxPartialBlocks = 16
+)
+
+// partialBlock houses a block that is being reconstructed from a compact block
+// while waiting for the missing transactions requested from a peer.
+type partialBlock struct {
+	peer    *peerpkg.Peer
+	header  wire.BlockHeader
+	txns    []*wire.MsgTx
+	missing []uint32
+}
+
+var (
+	// errMalformedCmpctBlock is returned by reconstructBlock when a
+	// compact block can't describe a valid block, for example because it
+	// has no transactions or prefilled transactions with indexes that are
+	// out of range or duplicated.  The peer that sent it is misbehaving.
+	errMalformedCmpctBlock = errors.New("malformed compact block")
+
+	// errDuplicateShortID is returned by reconstructBlock when a compact
+	// block contains the same short id more than once.  This may happen
+	// by chance for a valid block, so the full block must be requested
+	// instead.
+	errDuplicateShortID = errors.New("duplicate short id in compact block")
+)
+
+// reconstructBlock attempts to fill in the transactions of the passed compact
+// block from its prefilled transactions and the transactions in the provided
+// mempool transactions.  It returns the transactions of the block with nil
+// entries for the transactions that could not be found along with the indexes
+// of those entries.
+//
+// A short id that matches multiple mempool transactions is treated as missing
+// since it is not possible to know which of them is in the block.
+// errMalformedCmpctBlock is returned when the compact block is malformed and
+// errDuplicateShortID when the block can't be reconstructed because it
+// contains duplicate short ids.
+func reconstructBlock(msg *wire.MsgCmpctBlock, pool []*btcutil.Tx) ([]*wire.MsgTx, []uint32, error) {
+	numTxns := msg.TotalTxns()
+	if numTxns == 0 {
+		return nil, nil, errMalformedCmpctBlock
+	}
+
+	// Place the prefilled transactions and keep track of the index of the
+	// remaining transactions in the block by their short ids.
+	txns := make([]*wire.MsgTx, numTxns)
+	for _, ptx := range msg.PrefilledTxs {
+		if int(ptx.Index) >= numTxns || ptx.Tx == nil ||
+			txns[ptx.Index] != nil {
+
+			return nil, nil, errMalformedCmpctBlock
+		}
+		txns[ptx.Index] = ptx.Tx
+	}
+	shortIDs := make(map[uint64]int, len(msg.ShortIDs))
+	var next int
+	for _, id := range msg.ShortIDs {
+		for txns[next] != nil {
+			next++
+		}
+		if _, ok := shortIDs[id]; ok {
+			return nil, nil, errDuplicateShortID
+		}
+		shortIDs[id] = next
+		next++
+	}
+
+	// Fill in the transactions from the mempool.  Any short id that
+	// matches more than one transaction is a collision and the
+	// transaction must be requested from the peer.
+	key := msg.SipHashKey()
+	collisions := make(map[int]struct{})
+	for _, tx := range pool {
+		idx, ok := shortIDs[wire.ShortTxID(&key, tx.WitnessHash())]
+		if !ok {
+			continue
+		}
+		if _, ok := collisions[idx]; ok {
+			continue
+		}
+		if txns[idx] != nil {
+			txns[idx] = nil
+			collisions[idx] = struct{}{}
+			continue
+		}
+		txns[idx] = tx.MsgTx()
+	}
+
+	var missing []uint32
+	for i, tx := range txns {
+		if tx == nil {
+			missing = append(missing, uint32(i))
+		}
+	}
+	return txns, missing, nil
+}
+
+// fill fills in the missing transactions of the partial block with the passed
+// transactions received in a blocktxn message.  It returns false when the
+// number of transactions doesn't match the number of missing transactions.
+func (p *partialBlock) fill(txns []*wire.MsgTx) bool {
+	if len(txns) != len(p.missing) {
+		return false
+	}
+	for i, idx := range p.missing {
+		p.txns[idx] = txns[i]
+	}
+	p.missing = nil
+	return true
+}
+
+// checkReconstructedBlock returns whether or not the merkle root and witness
+// commitment of the passed reconstructed block match its header.  A mismatch
+// is typically the result of a short id collision with a mempool transaction
+// and the full block must be requested in that case.
+func checkReconstructedBlock(block *btcutil.Block) bool {
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	calculatedMerkleRoot := merkles[len(merkles)-1]
+	if !block.MsgBlock().Header.MerkleRoot.IsEqual(calculatedMerkleRoot) {
+		return false
+	}
+	return blockchain.ValidateWitnessCommitment(block) == nil
+}
+
+// requestFullBlock requests the full block with the passed hash from the peer.
+// This is used when a block could not be reconstructed from a compact block.
+func (sm *SyncManager) requestFullBlock(peer *peerpkg.Peer,
+	state *peerSyncState, hash *chainhash.Hash) {
+
+	limitAdd(sm.requestedBlocks, *hash, maxRequestedBlocks)
+	limitAdd(state.requestedBlocks, *hash, maxRequestedBlocks)
+
+	gdmsg := wire.NewMsgGetDataSizeHint(1)
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock, hash))
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// processCmpctBlock hands a block that was successfully reconstructed from a
+// compact block over to the normal block handling.  The full block is
+// requested instead when the reconstructed block doesn't match its header.
+func (sm *SyncManager) processCmpctBlock(peer *peerpkg.Peer,
+	state *peerSyncState, header *wire.BlockHeader, txns []*wire.MsgTx) {
+
+	msgBlock := wire.MsgBlock{
+		Header:       *header,
+		Transactions: txns,
+	}
+	block := btcutil.NewBlock(&msgBlock)
+	if !checkReconstructedBlock(block) {
+		log.Debugf("Reconstructed block %v from %s does not match "+
+			"its header -- requesting full block", block.Hash(),
+			peer)
+		sm.requestFullBlock(peer, state, block.Hash())
+		return
+	}
+
+	// The block was requested in one form or another, so make sure it is
+	// treated as such by the block handler.
+	limitAdd(sm.requestedBlocks, *block.Hash(), maxRequestedBlocks)
+	limitAdd(state.requestedBlocks, *block.Hash(), maxRequestedBlocks)
+
+	log.Debugf("Reconstructed block %v from compact block sent by %s",
+		block.Hash(), peer)
+	sm.handleBlockMsg(&blockMsg{block: block, peer: peer})
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  The block is
+// reconstructed from the transactions in the mempool and the missing
+// transactions, if any, are requested from the peer.
+func (sm *SyncManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	peer := cmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received cmpctblock message from unknown peer %s",
+			peer)
+		return
+	}
+
+	msg := cmsg.cmpctBlock
+	blockHash := msg.Header.BlockHash()
+
+	// Compact blocks are only useful for blocks that build on blocks we
+	// already know about, so ignore unrequested compact blocks while
+	// syncing and those that we already have.
+	_, requested := state.requestedBlocks[blockHash]
+	if !requested && !sm.current() {
+		log.Debugf("Ignoring unrequested compact block %v from %s "+
+			"while not current", blockHash, peer)
+		return
+	}
+	haveBlock, err := sm.chain.HaveBlock(&blockHash)
+	if err != nil || haveBlock {
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		return
+	}
+	if _, ok := sm.partialBlocks[blockHash]; ok {
+		return
+	}
+
+	// Fall back to requesting the full block when the parent is unknown
+	// so it is handled through the normal orphan processing.
+	haveParent, err := sm.chain.HaveBlock(&msg.Header.PrevBlock)
+	if err != nil || !haveParent {
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	// Ensure the header is valid before committing any resources to
+	// reconstructing the block, so peers can't make us store junk
+	// compact blocks and request their transactions without doing the
+	// proof of work.
+	if err := sm.chain.CheckBlockHeader(&msg.Header); err != nil {
+		if _, ok := err.(blockchain.RuleError); ok {
+			log.Infof("Rejected compact block %v from %s: %v",
+				blockHash, peer, err)
+		} else {
+			log.Errorf("Failed to check header of compact block "+
+				"%v: %v", blockHash, err)
+		}
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+
+		code, reason := mempool.ErrToRejectErr(err)
+		peer.PushRejectMsg(wire.CmdCmpctBlock, code, reason, &blockHash,
+			false)
+		return
+	}
+
+	txDescs := sm.txMemPool.TxDescs()
+	pool := make([]*btcutil.Tx, 0, len(txDescs))
+	for _, txDesc := range txDescs {
+		pool = append(pool, txDesc.Tx)
+	}
+	txns, missing, err := reconstructBlock(msg, pool)
+	switch {
+	case err == errMalformedCmpctBlock:
+		log.Warnf("Received malformed compact block %v from %s -- "+
+			"disconnecting", blockHash, peer.Addr())
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		peer.Disconnect()
+		return
+
+	case err != nil:
+		log.Debugf("Unable to reconstruct compact block %v from %s: "+
+			"%v -- requesting full block", blockHash, peer, err)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+	if len(missing) == 0 {
+		sm.processCmpctBlock(peer, state, &msg.Header, txns)
+		return
+	}
+
+	// Request the full block instead of waiting for the missing
+	// transactions when too many partial blocks are already waiting, which
+	// bounds the memory used by them.
+	if len(sm.partialBlocks) >= maxPartialBlocks ||
+		sm.numPartialBlocks(peer) >= maxPartialBlocksPerPeer {
+
+		log.Debugf("Too many partial blocks in flight -- requesting "+
+			"full block %v from %s", blockHash, peer)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	// Request the missing transactions from the peer and wait for them to
+	// arrive before processing the block.
+	gbmsg := wire.NewMsgGetBlockTxn(&blockHash)
+	gbmsg.Indexes = missing
+	sm.partialBlocks[blockHash] = &partialBlock{
+		peer:    peer,
+		header:  msg.Header,
+		txns:    txns,
+		missing: missing,
+	}
+	limitAdd(sm.requestedBlocks, blockHash, maxRequestedBlocks)
+	limitAdd(state.requestedBlocks, blockHash, maxRequestedBlocks)
+
+	log.Debugf("Requesting %d of %d transactions of compact block %v "+
+		"from %s", len(missing), len(txns), blockHash, peer)
+	peer.QueueMessage(gbmsg, nil)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers.  The
+// transactions are used to complete the associated partial block which is
+// then processed.
+func (sm *SyncManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received blocktxn message from unknown peer %s", peer)
+		return
+	}
+
+	msg := bmsg.blockTxn
+	partial, ok := sm.partialBlocks[msg.BlockHash]
+	if !ok || partial.peer != peer {
+		log.Debugf("Ignoring unrequested blocktxn for block %v from "+
+			"%s", msg.BlockHash, peer)
+		return
+	}
+	delete(sm.partialBlocks, msg.BlockHash)
+
+	if !partial.fill(msg.Transactions) {
+		log.Debugf("Received %d transactions for block %v from %s "+
+			"instead of the %d requested -- requesting full block",
+			len(msg.Transactions), msg.BlockHash, peer,
+			len(partial.missing))
+		sm.requestFullBlock(peer, state, &msg.BlockHash)
+		return
+	}
+
+	sm.processCmpctBlock(peer, state, &partial.header, partial.txns)
+}
+
+// numPartialBlocks returns the number of partial blocks that are waiting on
+// transactions from the passed peer.
+func (sm *SyncManager) numPartialBlocks(peer *peerpkg.Peer) int {
+	var n int
+	for _, partial := range sm.partialBlocks {
+		if partial.peer == peer {
+			n++
+		}
+	}
+	return n
+}
+
+// clearPartialBlocks removes all partial blocks that are waiting on
+// transactions from the passed peer.
+func (sm *SyncManager) clearPartialBlocks(peer *peerpkg.Peer) {
+	for hash, partial := range sm.partialBlocks {
+		if partial.peer == peer {
+			delete(sm.partialBlocks, hash)
+		}
+	}
+}
+
+// updateCmpctHBPeers selects the passed peer, which just provided a new block
+// that was connected to the main chain, as a peer that announces new blocks
+// via compact blocks (high-bandwidth mode) when it supports compact blocks.
+// Only the configured number of peers that most recently provided new blocks
+// are kept in high-bandwidth mode and the others are switched back to
+// low-bandwidth mode.
+func (sm *SyncManager) updateCmpctHBPeers(peer *peerpkg.Peer) {
+	if sm.maxCmpctHBPeers <= 0 ||
+		peer.CmpctBlockVersion() != wire.CmpctBlockVersion2 {
+
+		return
+	}
+
+	// Move the peer to the back of the list when it is already selected.
+	for i, hbPeer := range sm.cmpctHBPeers {
+		if hbPeer == peer {
+			copy(sm.cmpctHBPeers[i:], sm.cmpctHBPeers[i+1:])
+			sm.cmpctHBPeers[len(sm.cmpctHBPeers)-1] = peer
+			return
+		}
+	}
+
+	// Evict the peer that least recently provided a new block when the
+	// limit is reached.
+	if len(sm.cmpctHBPeers) >= sm.maxCmpctHBPeers {
+		evicted := sm.cmpctHBPeers[0]
+		sm.cmpctHBPeers = sm.cmpctHBPeers[1:]
+		if err := evicted.PushSendCmpctMsg(false); err != nil {
+			log.Debugf("Unable to disable high-bandwidth compact "+
+				"blocks for %s: %v", evicted, err)
+		}
+	}
+
+	if err := peer.PushSendCmpctMsg(true); err != nil {
+		log.Debugf("Unable to enable high-bandwidth compact blocks "+
+			"for %s: %v", peer, err)
+		return
+	}
+	sm.cmpctHBPeers = append(sm.cmpctHBPeers, peer)
+	log.Debugf("Selected %s as high-bandwidth compact block peer", peer)
+}
+
+// removeCmpctHBPeer removes the passed peer from the list of high-bandwidth
+// compact block peers.
+func (sm *SyncManager) removeCmpctHBPeer(peer *peerpkg.Peer) {
+	for i, hbPeer := range sm.cmpctHBPeers {
+		if hbPeer == peer {
+			sm.cmpctHBPeers = append(sm.cmpctHBPeers[:i],
+				sm.cmpctHBPeers[i+1:]...)
+			return
+		}
+	}
+}
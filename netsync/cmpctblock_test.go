@@ -0,0 +1,210 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testCmpctBlock returns a block with a coinbase and the passed number of
+// additional transactions along with a compact block for it.
+func testCmpctBlock(numTxns int) (*wire.MsgBlock, *wire.MsgCmpctBlock) {
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{Version: 1},
+	}
+	for i := 0; i <= numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: uint32(i)},
+		})
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		tx.LockTime = uint32(i)
+		block.Transactions = append(block.Transactions, tx)
+	}
+	coinbase := block.Transactions[0]
+	coinbase.TxIn[0].PreviousOutPoint.Index = wire.MaxPrevOutIndex
+
+	utilBlock := btcutil.NewBlock(block)
+	merkles := blockchain.BuildMerkleTreeStore(utilBlock.Transactions(),
+		false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	return block, wire.NewMsgCmpctBlockFromBlock(block, 1)
+}
+
+// TestReconstructBlock ensures blocks are reconstructed from compact blocks
+// and the mempool as expected.
+func TestReconstructBlock(t *testing.T) {
+	block, msg := testCmpctBlock(3)
+	txns := block.Transactions
+	poolTx := func(i int) *btcutil.Tx {
+		return btcutil.NewTx(txns[i])
+	}
+
+	dupShortID := *msg
+	dupShortID.ShortIDs = []uint64{msg.ShortIDs[0], msg.ShortIDs[1],
+		msg.ShortIDs[0]}
+
+	prefilledOutOfRange := *msg
+	prefilledOutOfRange.PrefilledTxs = []*wire.PrefilledTx{
+		{Index: 4, Tx: txns[0]},
+	}
+
+	prefilledDup := *msg
+	prefilledDup.ShortIDs = msg.ShortIDs[:2]
+	prefilledDup.PrefilledTxs = []*wire.PrefilledTx{
+		{Index: 0, Tx: txns[0]},
+		{Index: 0, Tx: txns[3]},
+	}
+
+	prefilledNil := *msg
+	prefilledNil.PrefilledTxs = []*wire.PrefilledTx{{Index: 0}}
+
+	empty := *msg
+	empty.ShortIDs = nil
+	empty.PrefilledTxs = nil
+
+	tests := []struct {
+		name    string
+		msg     *wire.MsgCmpctBlock
+		pool    []*btcutil.Tx
+		txns    []*wire.MsgTx
+		missing []uint32
+		err     error
+	}{
+		{
+			name: "all short ids match",
+			msg:  msg,
+			pool: []*btcutil.Tx{poolTx(3), poolTx(1), poolTx(2)},
+			txns: txns,
+		},
+		{
+			name:    "missing transactions",
+			msg:     msg,
+			pool:    []*btcutil.Tx{poolTx(2)},
+			txns:    []*wire.MsgTx{txns[0], nil, txns[2], nil},
+			missing: []uint32{1, 3},
+		},
+		{
+			// The same transaction wrapped twice stands in for
+			// two mempool transactions with the same short id
+			// since finding an actual 48-bit collision is not
+			// feasible in a test.
+			name: "short id collision",
+			msg:  msg,
+			pool: []*btcutil.Tx{poolTx(1), poolTx(2), poolTx(2),
+				poolTx(3)},
+			txns:    []*wire.MsgTx{txns[0], txns[1], nil, txns[3]},
+			missing: []uint32{2},
+		},
+		{
+			name: "duplicate short ids",
+			msg:  &dupShortID,
+			pool: []*btcutil.Tx{poolTx(1), poolTx(2), poolTx(3)},
+			err:  errDuplicateShortID,
+		},
+		{
+			name: "prefilled index out of range",
+			msg:  &prefilledOutOfRange,
+			err:  errMalformedCmpctBlock,
+		},
+		{
+			name: "duplicate prefilled index",
+			msg:  &prefilledDup,
+			err:  errMalformedCmpctBlock,
+		},
+		{
+			name: "prefilled transaction missing",
+			msg:  &prefilledNil,
+			err:  errMalformedCmpctBlock,
+		},
+		{
+			name: "no transactions",
+			msg:  &empty,
+			err:  errMalformedCmpctBlock,
+		},
+	}
+
+	for _, test := range tests {
+		gotTxns, gotMissing, err := reconstructBlock(test.msg, test.pool)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.err)
+			continue
+		}
+		if !reflect.DeepEqual(gotTxns, test.txns) {
+			t.Errorf("%s: got transactions %v, want %v", test.name,
+				gotTxns, test.txns)
+		}
+		if !reflect.DeepEqual(gotMissing, test.missing) {
+			t.Errorf("%s: got missing indexes %v, want %v",
+				test.name, gotMissing, test.missing)
+		}
+	}
+}
+
+// TestPartialBlockFill ensures a partial block is completed by the
+// transactions of a blocktxn message and the result matches the header of the
+// block.
+func TestPartialBlockFill(t *testing.T) {
+	block, msg := testCmpctBlock(3)
+	txns := block.Transactions
+
+	pool := []*btcutil.Tx{btcutil.NewTx(txns[2])}
+	reconstructed, missing, err := reconstructBlock(msg, pool)
+	if err != nil {
+		t.Fatalf("reconstructBlock: unexpected error: %v", err)
+	}
+	partial := &partialBlock{
+		header:  msg.Header,
+		txns:    reconstructed,
+		missing: missing,
+	}
+
+	// A blocktxn message with the wrong number of transactions doesn't
+	// complete the block.
+	if partial.fill([]*wire.MsgTx{txns[1]}) {
+		t.Fatalf("fill accepted too few transactions")
+	}
+
+	// The transactions are placed at the missing indexes in order.
+	if !partial.fill([]*wire.MsgTx{txns[1], txns[3]}) {
+		t.Fatalf("fill rejected the missing transactions")
+	}
+	if !reflect.DeepEqual(partial.txns, txns) {
+		t.Fatalf("got transactions %v, want %v", partial.txns, txns)
+	}
+	completed := btcutil.NewBlock(&wire.MsgBlock{
+		Header:       partial.header,
+		Transactions: partial.txns,
+	})
+	if !checkReconstructedBlock(completed) {
+		t.Fatalf("completed block does not match its header")
+	}
+
+	// Transactions in the wrong order produce a block that doesn't match
+	// its header.
+	partial = &partialBlock{
+		header:  msg.Header,
+		txns:    []*wire.MsgTx{txns[0], nil, txns[2], nil},
+		missing: []uint32{1, 3},
+	}
+	if !partial.fill([]*wire.MsgTx{txns[3], txns[1]}) {
+		t.Fatalf("fill rejected the missing transactions")
+	}
+	completed = btcutil.NewBlock(&wire.MsgBlock{
+		Header:       partial.header,
+		Transactions: partial.txns,
+	})
+	if checkReconstructedBlock(completed) {
+		t.Fatalf("block with misordered transactions matches its " +
+			"header")
+	}
+}
@@ -37,5 +37,10 @@ type Config struct {
 	DisableCheckpoints bool
 	MaxPeers           int
 
-	FeeEstimator *mempool.FeeEstimator
+	// MaxCmpctHBPeers is the maximum number of peers that are requested to
+	// announce new blocks by sending compact blocks directly.  Zero
+	// disables high-bandwidth compact block relay.
+	MaxCmpctHBPeers int
+
+	FeeEstimator *mempool.SmartFeeEstimator
 }
@@ -86,6 +86,22 @@ type notFoundMsg struct {
 	peer     *peerpkg.Peer
 }
 
+// cmpctBlockMsg packages a bitcoin cmpctblock message and the peer it came
+// from together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *peerpkg.Peer
+	reply      chan struct{}
+}
+
+// blockTxnMsg packages a bitcoin blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *peerpkg.Peer
+	reply    chan struct{}
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *peerpkg.Peer
@@ -204,8 +220,13 @@ type SyncManager struct {
 	startHeader      *list.Element
 	nextCheckpoint   *chaincfg.Checkpoint
 
+	// The following fields are used for compact block relay.
+	partialBlocks   map[chainhash.Hash]*partialBlock
+	cmpctHBPeers    []*peerpkg.Peer
+	maxCmpctHBPeers int
+
 	// An optional fee estimator.
-	feeEstimator *mempool.FeeEstimator
+	feeEstimator *mempool.SmartFeeEstimator
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
@@ -370,6 +391,7 @@ func (sm *SyncManager) startSync() {
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
 		sm.syncPeer = bestPeer
+		sm.fetchBackgroundBlocks()
 
 		// Reset the last progress time now that we have a non-nil
 		// syncPeer to avoid instantly detecting it as stalled in the
@@ -436,6 +458,12 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 	}
 
+	// Signal support for receiving compact blocks to peers that are
+	// capable of sending them.
+	if err := peer.PushSendCmpctMsg(false); err != nil {
+		log.Debugf("Not using compact blocks with %s: %v", peer, err)
+	}
+
 	// Start syncing by choosing the best candidate if needed.
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
@@ -512,6 +540,8 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 	log.Infof("Lost peer %s", peer)
 
 	sm.clearRequestedState(state)
+	sm.clearPartialBlocks(peer)
+	sm.removeCmpctHBPeer(peer)
 
 	if peer == sm.syncPeer {
 		// Update the sync peer. The server has already disconnected the
@@ -704,6 +734,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(state.requestedBlocks, *blockHash)
 	delete(sm.requestedBlocks, *blockHash)
+	delete(sm.partialBlocks, *blockHash)
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
@@ -789,6 +820,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		heightUpdate = best.Height
 		blkHashUpdate = &best.Hash
 
+		// Prefer the peer for announcing new blocks via compact
+		// blocks when it provided the new tip.
+		if best.Hash == *blockHash && sm.current() {
+			sm.updateCmpctHBPeers(peer)
+		}
+
 		// Clear the rejected transactions.
 		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
 	}
@@ -805,6 +842,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
+	// Request more of the blocks the background chainstate is missing
+	// when the request queue of the sync peer is getting short.
+	if peer == sm.syncPeer {
+		sm.fetchBackgroundBlocks()
+	}
+
 	// Nothing more to do if we aren't in headers-first mode.
 	if !sm.headersFirstMode {
 		return
@@ -911,6 +954,54 @@ func (sm *SyncManager) fetchHeaderBlocks() {
 	}
 }
 
+// fetchBackgroundBlocks requests the blocks the background chainstate needs
+// to validate the utxo snapshot the chain was bootstrapped from from the sync
+// peer when it has fewer than minInFlightBlocks blocks in flight.  Nothing is
+// requested when the chain wasn't bootstrapped from a snapshot or the sync
+// peer doesn't serve the full chain.
+func (sm *SyncManager) fetchBackgroundBlocks() {
+	if sm.syncPeer == nil ||
+		sm.syncPeer.Services()&wire.SFNodeNetwork != wire.SFNodeNetwork {
+
+		return
+	}
+	syncPeerState, exists := sm.peerStates[sm.syncPeer]
+	if !exists || len(syncPeerState.requestedBlocks) >= minInFlightBlocks {
+		return
+	}
+
+	// The missing blocks include the ones which are already in flight, so
+	// ask for enough of them to fill up the request queue.
+	maxBlocks := len(sm.requestedBlocks) + minInFlightBlocks*2
+	missing := sm.chain.MissingBackgroundBlocks(maxBlocks)
+	if len(missing) == 0 {
+		return
+	}
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(missing)))
+	for _, hash := range missing {
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
+		}
+
+		sm.requestedBlocks[*hash] = struct{}{}
+		syncPeerState.requestedBlocks[*hash] = struct{}{}
+
+		// If we're fetching from a witness enabled peer, then ensure
+		// that we receive all the witness data in the blocks.
+		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
+		if sm.syncPeer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
+		}
+		gdmsg.AddInvVect(iv)
+		if len(syncPeerState.requestedBlocks) >= minInFlightBlocks*2 {
+			break
+		}
+	}
+	if len(gdmsg.InvList) > 0 {
+		sm.syncPeer.QueueMessage(gdmsg, nil)
+	}
+}
+
 // handleHeadersMsg handles block header messages from all peers.  Headers are
 // requested when performing a headers-first sync.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
@@ -1031,6 +1122,8 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 		// verify the hash was actually announced by the peer
 		// before deleting from the global requested maps.
 		switch inv.Type {
+		case wire.InvTypeCmpctBlock:
+			fallthrough
 		case wire.InvTypeWitnessBlock:
 			fallthrough
 		case wire.InvTypeBlock:
@@ -1265,6 +1358,16 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 					iv.Type = wire.InvTypeWitnessBlock
 				}
 
+				// Request a compact block instead when the
+				// chain is current and the peer supports them
+				// since most of the transactions are likely
+				// already in the mempool.
+				if sm.current() && peer.CmpctBlockVersion() ==
+					wire.CmpctBlockVersion2 {
+
+					iv.Type = wire.InvTypeCmpctBlock
+				}
+
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1325,6 +1428,14 @@ out:
 				sm.handleBlockMsg(msg)
 				msg.reply <- struct{}{}
 
+			case *cmpctBlockMsg:
+				sm.handleCmpctBlockMsg(msg)
+				msg.reply <- struct{}{}
+
+			case *blockTxnMsg:
+				sm.handleBlockTxnMsg(msg)
+				msg.reply <- struct{}{}
+
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
@@ -1415,6 +1526,13 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			break
 		}
 
+		// Register block with the fee estimator, if it exists.  This
+		// must happen before the transactions of the block are removed
+		// from the transaction pool so they are counted as confirmed.
+		if sm.feeEstimator != nil {
+			sm.feeEstimator.RegisterBlock(block)
+		}
+
 		// Remove all of the transactions (except the coinbase) in the
 		// connected block from the transaction pool.  Secondly, remove any
 		// transactions which are now double spends as a result of these
@@ -1423,7 +1541,7 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveMinedTransaction(tx)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1431,20 +1549,6 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			sm.peerNotifier.AnnounceNewTransactions(acceptedTxs)
 		}
 
-		// Register block with the fee estimator, if it exists.
-		if sm.feeEstimator != nil {
-			err := sm.feeEstimator.RegisterBlock(block)
-
-			// If an error is somehow generated then the fee estimator
-			// has entered an invalid state. Since it doesn't know how
-			// to recover, create a new one.
-			if err != nil {
-				sm.feeEstimator = mempool.NewFeeEstimator(
-					mempool.DefaultEstimateFeeMaxRollback,
-					mempool.DefaultEstimateFeeMinRegisteredBlocks)
-			}
-		}
-
 	// A block has been disconnected from the main block chain.
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*btcutil.Block)
@@ -1465,11 +1569,6 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				sm.txMemPool.RemoveTransaction(tx, true)
 			}
 		}
-
-		// Rollback previous block recorded by the fee estimator.
-		if sm.feeEstimator != nil {
-			sm.feeEstimator.Rollback(block.Hash())
-		}
 	}
 }
 
@@ -1543,6 +1642,32 @@ func (sm *SyncManager) QueueNotFound(notFound *wire.MsgNotFound, peer *peerpkg.P
 	sm.msgChan <- &notFoundMsg{notFound: notFound, peer: peer}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.  Responds to the done channel argument after the compact
+// block message is processed.
+func (sm *SyncManager) QueueCmpctBlock(msg *wire.MsgCmpctBlock, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more compact blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &cmpctBlockMsg{cmpctBlock: msg, peer: peer, reply: done}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block
+// handling queue.  Responds to the done channel argument after the message is
+// processed.
+func (sm *SyncManager) QueueBlockTxn(msg *wire.MsgBlockTxn, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more transactions if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &blockTxnMsg{blockTxn: msg, peer: peer, reply: done}
+}
+
 // DonePeer informs the blockmanager that a peer has disconnected.
 func (sm *SyncManager) DonePeer(peer *peerpkg.Peer) {
 	// Ignore if we are shutting down.
@@ -1630,6 +1755,8 @@ func New(config *Config) (*SyncManager, error) {
 		msgChan:         make(chan interface{}, config.MaxPeers*3),
 		headerList:      list.New(),
 		quit:            make(chan struct{}),
+		partialBlocks:   make(map[chainhash.Hash]*partialBlock),
+		maxCmpctHBPeers: config.MaxCmpctHBPeers,
 		feeEstimator:    config.FeeEstimator,
 	}
 
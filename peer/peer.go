@@ -203,6 +203,22 @@ type MessageListeners struct {
 	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 message.
 	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
 
+	// OnSendCmpct is invoked when a peer receives a sendcmpct bitcoin
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin
+	// message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -462,6 +478,8 @@ type Peer struct {
 	verAckReceived       bool
 	witnessEnabled       bool
 	sendAddrV2           bool
+	cmpctBlockVersion    uint64 // compact block version sent by remote
+	cmpctHighBandwidth   bool   // remote wants cmpctblock announcements
 
 	wireEncoding wire.MessageEncoding
 
@@ -539,6 +557,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// IsKnownInventory returns whether or not the passed inventory is in the cache
+// of known inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) IsKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Contains(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -837,6 +863,54 @@ func (p *Peer) WantsAddrV2() bool {
 	return wantsAddrV2
 }
 
+// CmpctBlockVersion returns the compact block version the peer signalled
+// support for via a sendcmpct message.  Only version 2 compact blocks, which
+// include witness data, are supported, so the result is either 0 when the peer
+// does not support compact blocks or wire.CmpctBlockVersion2.
+//
+// This function is safe for concurrent access.
+func (p *Peer) CmpctBlockVersion() uint64 {
+	p.flagsMtx.Lock()
+	version := p.cmpctBlockVersion
+	p.flagsMtx.Unlock()
+
+	return version
+}
+
+// WantsCmpctBlocks returns if the peer requested new blocks to be announced by
+// sending cmpctblock messages directly instead of inventory vectors or
+// headers, which is known as high-bandwidth mode in BIP0152.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	wantsCmpctBlocks := p.cmpctBlockVersion != 0 && p.cmpctHighBandwidth
+	p.flagsMtx.Unlock()
+
+	return wantsCmpctBlocks
+}
+
+// PushSendCmpctMsg sends a sendcmpct message to the connected peer to signal
+// support for receiving version 2 compact blocks.  When announce is true, the
+// peer is also requested to announce new blocks by sending cmpctblock messages
+// directly.  It returns an error if the peer does not support compact blocks.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushSendCmpctMsg(announce bool) error {
+	if p.ProtocolVersion() < wire.SendCmpctVersion {
+		return fmt.Errorf("peer %s does not support compact blocks "+
+			"(protocol version %d)", p, p.ProtocolVersion())
+	}
+	if !p.IsWitnessEnabled() {
+		return fmt.Errorf("peer %s does not support compact blocks "+
+			"since it is not witness enabled", p)
+	}
+
+	msg := wire.NewMsgSendCmpct(announce, wire.CmpctBlockVersion2)
+	p.QueueMessage(msg, nil)
+	return nil
+}
+
 // PushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
@@ -1214,12 +1288,18 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, merkleblock, tx, or notfound message.
+		// Expects a block, cmpctblock, merkleblock, tx, or notfound
+		// message.
 		pendingResponses[wire.CmdBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdMerkleBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
 
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
 	case wire.CmdGetHeaders:
 		// Expects a headers message.  Use a longer deadline since it
 		// can take a while for the remote peer to load all of the
@@ -1277,12 +1357,15 @@ out:
 				switch msgCmd := msg.message.Command(); msgCmd {
 				case wire.CmdBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
@@ -1413,11 +1496,10 @@ out:
 			}
 
 			// Since the protocol version is 70016 but we don't
-			// implement compact blocks, we have to ignore unknown
-			// messages after the version-verack handshake. This
-			// matches bitcoind's behavior and is necessary since
-			// compact blocks negotiation occurs after the
-			// handshake.
+			// implement every message introduced up to that
+			// version, such as wtxidrelay, we have to ignore
+			// unknown messages after the version-verack handshake.
+			// This matches bitcoind's behavior.
 			if err == wire.ErrUnknownMessage {
 				log.Debugf("Received unknown message from %s:"+
 					" %v", p, err)
@@ -1573,6 +1655,11 @@ out:
 				p.cfg.Listeners.OnCFHeaders(p, msg)
 			}
 
+		case *wire.MsgCFCheckpt:
+			if p.cfg.Listeners.OnCFCheckpt != nil {
+				p.cfg.Listeners.OnCFCheckpt(p, msg)
+			}
+
 		case *wire.MsgFeeFilter:
 			if p.cfg.Listeners.OnFeeFilter != nil {
 				p.cfg.Listeners.OnFeeFilter(p, msg)
@@ -1612,6 +1699,38 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendCmpct:
+			// Only version 2 compact blocks are supported since
+			// they are the only ones that include witness data.
+			// Other versions are ignored as required by BIP0152.
+			p.flagsMtx.Lock()
+			if msg.Version == wire.CmpctBlockVersion2 &&
+				p.witnessEnabled {
+
+				p.cmpctBlockVersion = msg.Version
+				p.cmpctHighBandwidth = msg.Announce
+			}
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
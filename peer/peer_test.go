@@ -355,7 +355,7 @@ func TestPeerConnection(t *testing.T) {
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
-	ok := make(chan wire.Message, 22)
+	ok := make(chan wire.Message, 27)
 	peerCfg := &peer.Config{
 		Listeners: peer.MessageListeners{
 			OnGetAddr: func(p *peer.Peer, msg *wire.MsgGetAddr) {
@@ -415,6 +415,9 @@ func TestPeerListeners(t *testing.T) {
 			OnCFHeaders: func(p *peer.Peer, msg *wire.MsgCFHeaders) {
 				ok <- msg
 			},
+			OnCFCheckpt: func(p *peer.Peer, msg *wire.MsgCFCheckpt) {
+				ok <- msg
+			},
 			OnFeeFilter: func(p *peer.Peer, msg *wire.MsgFeeFilter) {
 				ok <- msg
 			},
@@ -449,6 +452,18 @@ func TestPeerListeners(t *testing.T) {
 			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
 				ok <- msg
 			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
 		},
 		UserAgentName:     "peer",
 		UserAgentVersion:  "1.0",
@@ -568,6 +583,11 @@ func TestPeerListeners(t *testing.T) {
 			"OnCFHeaders",
 			wire.NewMsgCFHeaders(),
 		},
+		{
+			"OnCFCheckpt",
+			wire.NewMsgCFCheckpt(wire.GCSFilterRegular,
+				&chainhash.Hash{}, 0),
+		},
 		{
 			"OnFeeFilter",
 			wire.NewMsgFeeFilter(15000),
@@ -599,6 +619,23 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(false, wire.CmpctBlockVersion2),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewBlockHeader(1,
+				&chainhash.Hash{}, &chainhash.Hash{}, 1, 1), 1),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
 		{
 			"OnSendAddrV2",
 			wire.NewMsgSendAddrV2(),
@@ -1209,3 +1246,110 @@ func TestSendAddrV2Handshake(t *testing.T) {
 		outPeer.WaitForDisconnect()
 	}
 }
+
+// TestSendCmpct tests that the compact block preferences signalled by a remote
+// peer via sendcmpct messages are recorded as expected.
+func TestSendCmpct(t *testing.T) {
+	verack := make(chan struct{}, 2)
+	sendCmpct := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				sendCmpct <- struct{}{}
+			},
+		},
+		ChainParams:    &chaincfg.MainNetParams,
+		Services:       wire.SFNodeNetwork | wire.SFNodeWitness,
+		AllowSelfConns: true,
+	}
+
+	inPeer := peer.NewInboundPeer(peerCfg)
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	err = setupPeerConnection(inPeer, outPeer)
+	if err != nil {
+		t.Fatalf("setupPeerConnection: failed: %v", err)
+	}
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-verack:
+		case <-time.After(time.Second * 2):
+			t.Fatalf("TestSendCmpct: verack timeout")
+		}
+	}
+
+	if inPeer.CmpctBlockVersion() != 0 || inPeer.WantsCmpctBlocks() {
+		t.Fatalf("TestSendCmpct: compact blocks unexpectedly " +
+			"negotiated before sendcmpct")
+	}
+
+	tests := []struct {
+		name        string
+		announce    bool
+		version     uint64
+		wantVersion uint64
+		wantCmpct   bool
+	}{
+		{
+			name:        "unsupported version 1 is ignored",
+			announce:    true,
+			version:     wire.CmpctBlockVersion1,
+			wantVersion: 0,
+			wantCmpct:   false,
+		},
+		{
+			name:        "low-bandwidth version 2",
+			announce:    false,
+			version:     wire.CmpctBlockVersion2,
+			wantVersion: wire.CmpctBlockVersion2,
+			wantCmpct:   false,
+		},
+		{
+			name:        "high-bandwidth version 2",
+			announce:    true,
+			version:     wire.CmpctBlockVersion2,
+			wantVersion: wire.CmpctBlockVersion2,
+			wantCmpct:   true,
+		},
+		{
+			name:        "unknown version keeps previous state",
+			announce:    false,
+			version:     3,
+			wantVersion: wire.CmpctBlockVersion2,
+			wantCmpct:   true,
+		},
+	}
+
+	for _, test := range tests {
+		outPeer.QueueMessage(wire.NewMsgSendCmpct(test.announce,
+			test.version), nil)
+		select {
+		case <-sendCmpct:
+		case <-time.After(time.Second * 2):
+			t.Fatalf("%s: sendcmpct timeout", test.name)
+		}
+
+		if v := inPeer.CmpctBlockVersion(); v != test.wantVersion {
+			t.Fatalf("%s: unexpected compact block version - got "+
+				"%d, want %d", test.name, v, test.wantVersion)
+		}
+		if inPeer.WantsCmpctBlocks() != test.wantCmpct {
+			t.Fatalf("%s: unexpected high-bandwidth mode - got "+
+				"%v, want %v", test.name,
+				inPeer.WantsCmpctBlocks(), test.wantCmpct)
+		}
+	}
+
+	// A peer that supports compact blocks must accept a sendcmpct message.
+	if err := inPeer.PushSendCmpctMsg(false); err != nil {
+		t.Fatalf("PushSendCmpctMsg: unexpected err %v", err)
+	}
+}
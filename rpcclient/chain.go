@@ -508,6 +508,83 @@ func (c *Client) GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
 	return c.GetBlockChainInfoAsync().Receive()
 }
 
+// FutureGetChainTipsResult is a future promise to deliver the result of a
+// GetChainTipsAsync RPC invocation (or an applicable error).
+type FutureGetChainTipsResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the tips
+// of all known branches of the block tree.
+func (r FutureGetChainTipsResult) Receive() ([]*btcjson.GetChainTipsResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of getchaintips result objects.
+	var chainTips []*btcjson.GetChainTipsResult
+	err = json.Unmarshal(res, &chainTips)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainTips, nil
+}
+
+// GetChainTipsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetChainTips for the blocking version and more details.
+func (c *Client) GetChainTipsAsync() FutureGetChainTipsResult {
+	cmd := btcjson.NewGetChainTipsCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetChainTips returns information about the tips of all known branches of the
+// block tree, including the main chain and any stale or competing branches.
+func (c *Client) GetChainTips() ([]*btcjson.GetChainTipsResult, error) {
+	return c.GetChainTipsAsync().Receive()
+}
+
+// FutureGetChainStatesResult is a future promise to deliver the result of a
+// GetChainStatesAsync RPC invocation (or an applicable error).
+type FutureGetChainStatesResult chan *Response
+
+// Receive waits for the Response promised by the future and returns
+// information about the chainstates.
+func (r FutureGetChainStatesResult) Receive() (*btcjson.GetChainStatesResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getchainstates result object.
+	var result btcjson.GetChainStatesResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetChainStatesAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetChainStates for the blocking version and more details.
+func (c *Client) GetChainStatesAsync() FutureGetChainStatesResult {
+	cmd := btcjson.NewGetChainStatesCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetChainStates returns information about the chainstates, which include a
+// background chainstate while the utxo snapshot the chain was bootstrapped
+// from is being validated.
+func (c *Client) GetChainStates() (*btcjson.GetChainStatesResult, error) {
+	return c.GetChainStatesAsync().Receive()
+}
+
 // FutureGetBlockFilterResult is a future promise to deliver the result of a
 // GetBlockFilterAsync RPC invocation (or an applicable error).
 type FutureGetBlockFilterResult chan *Response
@@ -724,6 +801,227 @@ func (c *Client) GetMempoolEntry(txHash string) (*btcjson.GetMempoolEntryResult,
 	return c.GetMempoolEntryAsync(txHash).Receive()
 }
 
+// FutureGetMempoolAncestorsResult is a future promise to deliver the result of
+// a GetMempoolAncestorsAsync RPC invocation (or an applicable error).
+type FutureGetMempoolAncestorsResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of all unconfirmed ancestors in the memory pool of the transaction.
+func (r FutureGetMempoolAncestorsResult) Receive() ([]*chainhash.Hash, error) {
+	// The result is an array of transaction hashes like the one of the
+	// getrawmempool command.
+	return FutureGetRawMempoolResult(r).Receive()
+}
+
+// GetMempoolAncestorsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolAncestors for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsAsync(txHash *chainhash.Hash) FutureGetMempoolAncestorsResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash.String(),
+		btcjson.Bool(false))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolAncestors returns the hashes of all unconfirmed ancestors in the
+// memory pool of the transaction in the memory pool with the given hash.
+//
+// See GetMempoolAncestorsVerbose to retrieve data structures with information
+// about the ancestors instead.
+func (c *Client) GetMempoolAncestors(txHash *chainhash.Hash) ([]*chainhash.Hash, error) {
+	return c.GetMempoolAncestorsAsync(txHash).Receive()
+}
+
+// FutureGetMempoolAncestorsVerboseResult is a future promise to deliver the
+// result of a GetMempoolAncestorsVerboseAsync RPC invocation (or an applicable
+// error).
+type FutureGetMempoolAncestorsVerboseResult chan *Response
+
+// Receive waits for the Response promised by the future and returns a map of
+// transaction hashes to an associated data structure with information about the
+// transaction for all unconfirmed ancestors in the memory pool of the
+// transaction.
+func (r FutureGetMempoolAncestorsVerboseResult) Receive() (map[string]btcjson.GetMempoolEntryResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a map of strings (tx hashes) to their
+	// detailed results.
+	var mempoolEntries map[string]btcjson.GetMempoolEntryResult
+	err = json.Unmarshal(res, &mempoolEntries)
+	if err != nil {
+		return nil, err
+	}
+	return mempoolEntries, nil
+}
+
+// GetMempoolAncestorsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolAncestorsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsVerboseAsync(txHash *chainhash.Hash) FutureGetMempoolAncestorsVerboseResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash.String(),
+		btcjson.Bool(true))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolAncestorsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// unconfirmed ancestors in the memory pool of the transaction in the memory
+// pool with the given hash.
+//
+// See GetMempoolAncestors to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolAncestorsVerbose(txHash *chainhash.Hash) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolAncestorsVerboseAsync(txHash).Receive()
+}
+
+// FutureGetMempoolDescendantsResult is a future promise to deliver the result
+// of a GetMempoolDescendantsAsync RPC invocation (or an applicable error).
+type FutureGetMempoolDescendantsResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of all descendants in the memory pool of the transaction.
+func (r FutureGetMempoolDescendantsResult) Receive() ([]*chainhash.Hash, error) {
+	// The result is an array of transaction hashes like the one of the
+	// getrawmempool command.
+	return FutureGetRawMempoolResult(r).Receive()
+}
+
+// GetMempoolDescendantsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolDescendants for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsAsync(txHash *chainhash.Hash) FutureGetMempoolDescendantsResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash.String(),
+		btcjson.Bool(false))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolDescendants returns the hashes of all descendants in the memory
+// pool of the transaction in the memory pool with the given hash.
+//
+// See GetMempoolDescendantsVerbose to retrieve data structures with information
+// about the descendants instead.
+func (c *Client) GetMempoolDescendants(txHash *chainhash.Hash) ([]*chainhash.Hash, error) {
+	return c.GetMempoolDescendantsAsync(txHash).Receive()
+}
+
+// FutureGetMempoolDescendantsVerboseResult is a future promise to deliver the
+// result of a GetMempoolDescendantsVerboseAsync RPC invocation (or an
+// applicable error).
+type FutureGetMempoolDescendantsVerboseResult chan *Response
+
+// Receive waits for the Response promised by the future and returns a map of
+// transaction hashes to an associated data structure with information about the
+// transaction for all descendants in the memory pool of the transaction.
+func (r FutureGetMempoolDescendantsVerboseResult) Receive() (map[string]btcjson.GetMempoolEntryResult, error) {
+	// The result has the same format as the verbose result of the
+	// getmempoolancestors command.
+	return FutureGetMempoolAncestorsVerboseResult(r).Receive()
+}
+
+// GetMempoolDescendantsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolDescendantsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsVerboseAsync(txHash *chainhash.Hash) FutureGetMempoolDescendantsVerboseResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash.String(),
+		btcjson.Bool(true))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolDescendantsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// descendants in the memory pool of the transaction in the memory pool with the
+// given hash.
+//
+// See GetMempoolDescendants to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolDescendantsVerbose(txHash *chainhash.Hash) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolDescendantsVerboseAsync(txHash).Receive()
+}
+
+// FutureGetMempoolInfoResult is a future promise to deliver the result of a
+// GetMempoolInfoAsync RPC invocation (or an applicable error).
+type FutureGetMempoolInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns information
+// about the memory pool.
+func (r FutureGetMempoolInfoResult) Receive() (*btcjson.GetMempoolInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getmempoolinfo result object.
+	var mempoolInfoResult btcjson.GetMempoolInfoResult
+	err = json.Unmarshal(res, &mempoolInfoResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mempoolInfoResult, nil
+}
+
+// GetMempoolInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetMempoolInfo for the blocking version and more details.
+func (c *Client) GetMempoolInfoAsync() FutureGetMempoolInfoResult {
+	cmd := btcjson.NewGetMempoolInfoCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolInfo returns information about the memory pool, such as its size
+// and whether or not the mempool saved on the last shutdown was loaded.
+func (c *Client) GetMempoolInfo() (*btcjson.GetMempoolInfoResult, error) {
+	return c.GetMempoolInfoAsync().Receive()
+}
+
+// FutureSaveMempoolResult is a future promise to deliver the result of a
+// SaveMempoolAsync RPC invocation (or an applicable error).
+type FutureSaveMempoolResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the path
+// of the file the memory pool was saved to.
+func (r FutureSaveMempoolResult) Receive() (string, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Unmarshal result as a savemempool result object.
+	var result btcjson.SaveMempoolResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Filename, nil
+}
+
+// SaveMempoolAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See SaveMempool for the blocking version and more details.
+func (c *Client) SaveMempoolAsync() FutureSaveMempoolResult {
+	cmd := btcjson.NewSaveMempoolCmd()
+	return c.SendCmd(cmd)
+}
+
+// SaveMempool saves the memory pool to the file in the data directory of the
+// server which is loaded on startup and returns its path.
+func (c *Client) SaveMempool() (string, error) {
+	return c.SaveMempoolAsync().Receive()
+}
+
 // FutureGetRawMempoolResult is a future promise to deliver the result of a
 // GetRawMempoolAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolResult chan *Response
@@ -1064,6 +1362,186 @@ func (c *Client) GetTxOutSetInfo() (*btcjson.GetTxOutSetInfoResult, error) {
 	return c.GetTxOutSetInfoAsync().Receive()
 }
 
+// GetTxOutSetInfoHashTypeAsync returns an instance of a type that can be used
+// to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetTxOutSetInfoHashType for the blocking version and more details.
+func (c *Client) GetTxOutSetInfoHashTypeAsync(hashType btcjson.TxOutSetHashType) FutureGetTxOutSetInfoResult {
+	cmd := btcjson.NewGetTxOutSetInfoCmdWithHashType(&hashType)
+	return c.SendCmd(cmd)
+}
+
+// GetTxOutSetInfoHashType returns the statistics about the unspent transaction
+// output set along with the hash of the passed type committing to it.
+func (c *Client) GetTxOutSetInfoHashType(hashType btcjson.TxOutSetHashType) (*btcjson.GetTxOutSetInfoResult, error) {
+	return c.GetTxOutSetInfoHashTypeAsync(hashType).Receive()
+}
+
+// FutureDumpTxOutSetResult is a future promise to deliver the result of a
+// DumpTxOutSetAsync RPC invocation (or an applicable error).
+type FutureDumpTxOutSetResult chan *Response
+
+// Receive waits for the Response promised by the future and returns
+// information about the utxo snapshot which was written.
+func (r FutureDumpTxOutSetResult) Receive() (*btcjson.DumpTxOutSetResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a dumptxoutset result object.
+	var result btcjson.DumpTxOutSetResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DumpTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DumpTxOutSet for the blocking version and more details.
+func (c *Client) DumpTxOutSetAsync(path string) FutureDumpTxOutSetResult {
+	cmd := btcjson.NewDumpTxOutSetCmd(path)
+	return c.SendCmd(cmd)
+}
+
+// DumpTxOutSet writes a snapshot of the unspent transaction output set as of
+// the current best block to the passed path on the server, which is relative
+// to its data directory unless it is absolute.
+func (c *Client) DumpTxOutSet(path string) (*btcjson.DumpTxOutSetResult, error) {
+	return c.DumpTxOutSetAsync(path).Receive()
+}
+
+// FutureGetTxOutProofResult is a future promise to deliver the result of a
+// GetTxOutProofAsync RPC invocation (or an applicable error).
+type FutureGetTxOutProofResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the merkle
+// block proving the requested transactions were included in a block.
+func (r FutureGetTxOutProofResult) Receive() (*wire.MsgMerkleBlock, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a string.
+	var proofHex string
+	err = json.Unmarshal(res, &proofHex)
+	if err != nil {
+		return nil, err
+	}
+
+	serializedProof, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deserialize the merkle block and return it.
+	var mBlock wire.MsgMerkleBlock
+	err = mBlock.BtcDecode(bytes.NewReader(serializedProof),
+		wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mBlock, nil
+}
+
+// GetTxOutProofAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetTxOutProof for the blocking version and more details.
+func (c *Client) GetTxOutProofAsync(txHashes []*chainhash.Hash,
+	blockHash *chainhash.Hash) FutureGetTxOutProofResult {
+
+	txIDs := make([]string, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		txIDs = append(txIDs, txHash.String())
+	}
+
+	var hash *string
+	if blockHash != nil {
+		hash = btcjson.String(blockHash.String())
+	}
+
+	cmd := btcjson.NewGetTxOutProofCmd(txIDs, hash)
+	return c.SendCmd(cmd)
+}
+
+// GetTxOutProof returns a merkle block proving the passed transactions were
+// included in a block.  The block hash may be nil, in which case the server
+// must have the transaction index enabled to locate the block.
+func (c *Client) GetTxOutProof(txHashes []*chainhash.Hash,
+	blockHash *chainhash.Hash) (*wire.MsgMerkleBlock, error) {
+
+	return c.GetTxOutProofAsync(txHashes, blockHash).Receive()
+}
+
+// FutureVerifyTxOutProofResult is a future promise to deliver the result of a
+// VerifyTxOutProofAsync RPC invocation (or an applicable error).
+type FutureVerifyTxOutProofResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of the transactions the proof commits to.
+func (r FutureVerifyTxOutProofResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of strings.
+	var txIDs []string
+	err = json.Unmarshal(res, &txIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	txHashes := make([]*chainhash.Hash, 0, len(txIDs))
+	for _, txID := range txIDs {
+		txHash, err := chainhash.NewHashFromStr(txID)
+		if err != nil {
+			return nil, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes, nil
+}
+
+// VerifyTxOutProofAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See VerifyTxOutProof for the blocking version and more details.
+func (c *Client) VerifyTxOutProofAsync(proof *wire.MsgMerkleBlock) FutureVerifyTxOutProofResult {
+	proofHex := ""
+	if proof != nil {
+		var buf bytes.Buffer
+		err := proof.BtcEncode(&buf, wire.ProtocolVersion,
+			wire.BaseEncoding)
+		if err != nil {
+			return newFutureError(err)
+		}
+		proofHex = hex.EncodeToString(buf.Bytes())
+	}
+
+	cmd := btcjson.NewVerifyTxOutProofCmd(proofHex)
+	return c.SendCmd(cmd)
+}
+
+// VerifyTxOutProof verifies that the passed proof commits to transactions in
+// a block in the main chain and returns the hashes of those transactions.  An
+// empty list is returned when the proof is invalid.
+func (c *Client) VerifyTxOutProof(proof *wire.MsgMerkleBlock) ([]*chainhash.Hash, error) {
+	return c.VerifyTxOutProofAsync(proof).Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -1151,6 +1629,72 @@ func (c *Client) InvalidateBlock(blockHash *chainhash.Hash) error {
 	return c.InvalidateBlockAsync(blockHash).Receive()
 }
 
+// FutureReconsiderBlockResult is a future promise to deliver the result of a
+// ReconsiderBlockAsync RPC invocation (or an applicable error).
+type FutureReconsiderBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the block could not be reconsidered.
+func (r FutureReconsiderBlockResult) Receive() error {
+	_, err := ReceiveFuture(r)
+
+	return err
+}
+
+// ReconsiderBlockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ReconsiderBlock for the blocking version and more details.
+func (c *Client) ReconsiderBlockAsync(blockHash *chainhash.Hash) FutureReconsiderBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewReconsiderBlockCmd(hash)
+	return c.SendCmd(cmd)
+}
+
+// ReconsiderBlock removes the invalid status from a specific block along with
+// its ancestors and descendants, undoing the effects of InvalidateBlock.
+func (c *Client) ReconsiderBlock(blockHash *chainhash.Hash) error {
+	return c.ReconsiderBlockAsync(blockHash).Receive()
+}
+
+// FuturePreciousBlockResult is a future promise to deliver the result of a
+// PreciousBlockAsync RPC invocation (or an applicable error).
+type FuturePreciousBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the block could not be treated as precious.
+func (r FuturePreciousBlockResult) Receive() error {
+	_, err := ReceiveFuture(r)
+
+	return err
+}
+
+// PreciousBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See PreciousBlock for the blocking version and more details.
+func (c *Client) PreciousBlockAsync(blockHash *chainhash.Hash) FuturePreciousBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewPreciousBlockCmd(hash)
+	return c.SendCmd(cmd)
+}
+
+// PreciousBlock treats a specific block as if it was received before any other
+// block with the same amount of work.
+func (c *Client) PreciousBlock(blockHash *chainhash.Hash) error {
+	return c.PreciousBlockAsync(blockHash).Receive()
+}
+
 // FutureGetCFilterResult is a future promise to deliver the result of a
 // GetCFilterAsync RPC invocation (or an applicable error).
 type FutureGetCFilterResult chan *Response
@@ -1382,3 +1926,116 @@ func (c *Client) GetDescriptorInfoAsync(descriptor string) FutureGetDescriptorIn
 func (c *Client) GetDescriptorInfo(descriptor string) (*btcjson.GetDescriptorInfoResult, error) {
 	return c.GetDescriptorInfoAsync(descriptor).Receive()
 }
+
+// FutureScanTxOutSetResult is a future promise to deliver the result of a
+// ScanTxOutSetAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// unspent transaction outputs matching the scanned descriptors.
+func (r FutureScanTxOutSetResult) Receive() (*btcjson.ScanTxOutSetResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var scanResult btcjson.ScanTxOutSetResult
+	err = json.Unmarshal(res, &scanResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scanResult, nil
+}
+
+// ScanTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ScanTxOutSet for the blocking version and more details.
+func (c *Client) ScanTxOutSetAsync(scanObjects []btcjson.ScanObject) FutureScanTxOutSetResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.ScanTxOutSetActionStart,
+		&scanObjects)
+	return c.SendCmd(cmd)
+}
+
+// ScanTxOutSet scans the unspent transaction output set for outputs matching
+// the passed descriptors and returns them.  The call blocks until the scan
+// completes or is aborted with ScanTxOutSetAbort.
+func (c *Client) ScanTxOutSet(scanObjects []btcjson.ScanObject) (*btcjson.ScanTxOutSetResult, error) {
+	return c.ScanTxOutSetAsync(scanObjects).Receive()
+}
+
+// FutureScanTxOutSetStatusResult is a future promise to deliver the result of
+// a ScanTxOutSetStatusAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetStatusResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// progress of the scan in progress, or nil if there is no scan in progress.
+func (r FutureScanTxOutSetStatusResult) Receive() (*btcjson.ScanTxOutSetStatusResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var status *btcjson.ScanTxOutSetStatusResult
+	err = json.Unmarshal(res, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// ScanTxOutSetStatusAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ScanTxOutSetStatus for the blocking version and more details.
+func (c *Client) ScanTxOutSetStatusAsync() FutureScanTxOutSetStatusResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.ScanTxOutSetActionStatus, nil)
+	return c.SendCmd(cmd)
+}
+
+// ScanTxOutSetStatus returns the progress of the scantxoutset scan in progress,
+// or nil if there is no scan in progress.
+func (c *Client) ScanTxOutSetStatus() (*btcjson.ScanTxOutSetStatusResult, error) {
+	return c.ScanTxOutSetStatusAsync().Receive()
+}
+
+// FutureScanTxOutSetAbortResult is a future promise to deliver the result of a
+// ScanTxOutSetAbortAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetAbortResult chan *Response
+
+// Receive waits for the Response promised by the future and returns whether
+// the scan in progress was aborted.
+func (r FutureScanTxOutSetAbortResult) Receive() (bool, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return false, err
+	}
+
+	var aborted bool
+	err = json.Unmarshal(res, &aborted)
+	if err != nil {
+		return false, err
+	}
+
+	return aborted, nil
+}
+
+// ScanTxOutSetAbortAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ScanTxOutSetAbort for the blocking version and more details.
+func (c *Client) ScanTxOutSetAbortAsync() FutureScanTxOutSetAbortResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.ScanTxOutSetActionAbort, nil)
+	return c.SendCmd(cmd)
+}
+
+// ScanTxOutSetAbort aborts the scantxoutset scan in progress and returns
+// whether there was a scan to abort.
+func (c *Client) ScanTxOutSetAbort() (bool, error) {
+	return c.ScanTxOutSetAbortAsync().Receive()
+}
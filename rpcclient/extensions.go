@@ -235,6 +235,48 @@ func (c *Client) GetCurrentNet() (wire.BitcoinNet, error) {
 	return c.GetCurrentNetAsync().Receive()
 }
 
+// FutureGetUtxoCacheInfoResult is a future promise to deliver the result of a
+// GetUtxoCacheInfoAsync RPC invocation (or an applicable error).
+type FutureGetUtxoCacheInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns statistics
+// about the utxo cache of the server.
+func (r FutureGetUtxoCacheInfoResult) Receive() (*btcjson.GetUtxoCacheInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getutxocacheinfo result object.
+	var info btcjson.GetUtxoCacheInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetUtxoCacheInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetUtxoCacheInfo for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetUtxoCacheInfoAsync() FutureGetUtxoCacheInfoResult {
+	cmd := btcjson.NewGetUtxoCacheInfoCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetUtxoCacheInfo returns statistics about the utxo cache of the server such
+// as its size and the number of cache hits, misses and flushes.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetUtxoCacheInfo() (*btcjson.GetUtxoCacheInfoResult, error) {
+	return c.GetUtxoCacheInfoAsync().Receive()
+}
+
 // FutureGetHeadersResult is a future promise to deliver the result of a
 // getheaders RPC invocation (or an applicable error).
 //
@@ -383,6 +383,68 @@ func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainh
 	return c.SendRawTransactionAsync(tx, allowHighFees).Receive()
 }
 
+// FutureTestMempoolAcceptResult is a future promise to deliver the result
+// of a TestMempoolAccept RPC invocation (or an applicable error).
+type FutureTestMempoolAcceptResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the result
+// of testing whether each of the transactions would be accepted to the
+// mempool.
+func (r FutureTestMempoolAcceptResult) Receive() (
+	[]*btcjson.TestMempoolAcceptResult, error) {
+
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of test results.
+	var results []*btcjson.TestMempoolAcceptResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// TestMempoolAcceptAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See TestMempoolAccept for the blocking version and more details.
+func (c *Client) TestMempoolAcceptAsync(txns []*wire.MsgTx,
+	maxFeeRate float64) FutureTestMempoolAcceptResult {
+
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewTestMempoolAcceptCmd(rawTxns, &maxFeeRate)
+	return c.SendCmd(cmd)
+}
+
+// TestMempoolAccept tests whether each of the passed transactions would be
+// accepted to the mempool of the server without adding them or relaying them.
+// The transactions form a package which must be sorted such that each
+// transaction follows all of its parents, and each transaction is tested as if
+// the transactions preceding it which would be accepted were already in the
+// mempool.
+//
+// The maximum fee rate is specified in BTC/kvB and transactions paying a higher
+// fee rate are rejected.  A maximum fee rate of 0 disables the check.
+func (c *Client) TestMempoolAccept(txns []*wire.MsgTx,
+	maxFeeRate float64) ([]*btcjson.TestMempoolAcceptResult, error) {
+
+	return c.TestMempoolAcceptAsync(txns, maxFeeRate).Receive()
+}
+
 // FutureSignRawTransactionResult is a future promise to deliver the result
 // of one of the SignRawTransactionAsync family of RPC invocations (or an
 // applicable error).
@@ -882,3 +944,192 @@ func (c *Client) DecodeScriptAsync(serializedScript []byte) FutureDecodeScriptRe
 func (c *Client) DecodeScript(serializedScript []byte) (*btcjson.DecodeScriptResult, error) {
 	return c.DecodeScriptAsync(serializedScript).Receive()
 }
+
+// FuturePsbtResult is a future promise to deliver the result of an RPC
+// invocation which returns a base64-encoded PSBT (or an applicable error).
+type FuturePsbtResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// base64-encoded PSBT.
+func (r FuturePsbtResult) Receive() (string, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Unmarshal result as a string.
+	var b64Psbt string
+	err = json.Unmarshal(res, &b64Psbt)
+	if err != nil {
+		return "", err
+	}
+
+	return b64Psbt, nil
+}
+
+// FutureDecodePsbtResult is a future promise to deliver the result of a
+// DecodePsbtAsync RPC invocation (or an applicable error).
+type FutureDecodePsbtResult chan *Response
+
+// Receive waits for the Response promised by the future and returns
+// information about a PSBT given its base64 encoding.
+func (r FutureDecodePsbtResult) Receive() (*btcjson.DecodePsbtResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a decodepsbt result object.
+	var decodePsbtResult btcjson.DecodePsbtResult
+	err = json.Unmarshal(res, &decodePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decodePsbtResult, nil
+}
+
+// DecodePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DecodePsbt for the blocking version and more details.
+func (c *Client) DecodePsbtAsync(b64Psbt string) FutureDecodePsbtResult {
+	cmd := btcjson.NewDecodePsbtCmd(b64Psbt)
+	return c.SendCmd(cmd)
+}
+
+// DecodePsbt returns information about a PSBT given its base64 encoding.
+func (c *Client) DecodePsbt(b64Psbt string) (*btcjson.DecodePsbtResult, error) {
+	return c.DecodePsbtAsync(b64Psbt).Receive()
+}
+
+// CombinePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See CombinePsbt for the blocking version and more details.
+func (c *Client) CombinePsbtAsync(b64Psbts []string) FuturePsbtResult {
+	cmd := btcjson.NewCombinePsbtCmd(b64Psbts)
+	return c.SendCmd(cmd)
+}
+
+// CombinePsbt combines the passed base64-encoded PSBTs for the same
+// transaction into a single PSBT containing the data of all of them.
+func (c *Client) CombinePsbt(b64Psbts []string) (string, error) {
+	return c.CombinePsbtAsync(b64Psbts).Receive()
+}
+
+// ConvertToPsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ConvertToPsbt for the blocking version and more details.
+func (c *Client) ConvertToPsbtAsync(tx *wire.MsgTx, permitSigData bool,
+	version uint32) FuturePsbtResult {
+
+	txHex := ""
+	if tx != nil {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		txHex = hex.EncodeToString(buf.Bytes())
+	}
+
+	cmd := btcjson.NewConvertToPsbtCmd(
+		txHex, &permitSigData, nil, &version,
+	)
+	return c.SendCmd(cmd)
+}
+
+// ConvertToPsbt converts the passed transaction to a base64-encoded PSBT of the
+// passed version.  Signature data of the inputs is dropped when permitSigData
+// is set, otherwise the conversion fails when it is present.
+func (c *Client) ConvertToPsbt(tx *wire.MsgTx, permitSigData bool,
+	version uint32) (string, error) {
+
+	return c.ConvertToPsbtAsync(tx, permitSigData, version).Receive()
+}
+
+// FutureFinalizePsbtResult is a future promise to deliver the result of a
+// FinalizePsbtAsync RPC invocation (or an applicable error).
+type FutureFinalizePsbtResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// finalized PSBT or the extracted transaction.
+func (r FutureFinalizePsbtResult) Receive() (*btcjson.FinalizePsbtResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a finalizepsbt result object.
+	var finalizePsbtResult btcjson.FinalizePsbtResult
+	err = json.Unmarshal(res, &finalizePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &finalizePsbtResult, nil
+}
+
+// FinalizePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See FinalizePsbt for the blocking version and more details.
+func (c *Client) FinalizePsbtAsync(b64Psbt string,
+	extract bool) FutureFinalizePsbtResult {
+
+	cmd := btcjson.NewFinalizePsbtCmd(b64Psbt, &extract)
+	return c.SendCmd(cmd)
+}
+
+// FinalizePsbt finalizes the inputs of the passed base64-encoded PSBT which
+// have all data they need.  When extract is set and all inputs are finalized,
+// the serialized transaction is returned instead of the PSBT.
+func (c *Client) FinalizePsbt(b64Psbt string,
+	extract bool) (*btcjson.FinalizePsbtResult, error) {
+
+	return c.FinalizePsbtAsync(b64Psbt, extract).Receive()
+}
+
+// FutureAnalyzePsbtResult is a future promise to deliver the result of an
+// AnalyzePsbtAsync RPC invocation (or an applicable error).
+type FutureAnalyzePsbtResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// analysis of a PSBT.
+func (r FutureAnalyzePsbtResult) Receive() (*btcjson.AnalyzePsbtResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an analyzepsbt result object.
+	var analyzePsbtResult btcjson.AnalyzePsbtResult
+	err = json.Unmarshal(res, &analyzePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &analyzePsbtResult, nil
+}
+
+// AnalyzePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AnalyzePsbt for the blocking version and more details.
+func (c *Client) AnalyzePsbtAsync(b64Psbt string) FutureAnalyzePsbtResult {
+	cmd := btcjson.NewAnalyzePsbtCmd(b64Psbt)
+	return c.SendCmd(cmd)
+}
+
+// AnalyzePsbt returns which data is missing to complete the passed
+// base64-encoded PSBT and which role needs to process it next.
+func (c *Client) AnalyzePsbt(b64Psbt string) (*btcjson.AnalyzePsbtResult, error) {
+	return c.AnalyzePsbtAsync(b64Psbt).Receive()
+}
@@ -6,7 +6,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"container/list"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
@@ -16,11 +18,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,6 +38,8 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bloom"
+	"github.com/btcsuite/btcd/btcutil/descriptor"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
@@ -41,6 +49,7 @@ import (
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
 	"github.com/btcsuite/websocket"
 )
 
@@ -75,6 +84,19 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxDescriptorRange is the maximum number of indices a ranged
+	// descriptor can be expanded at by a single RPC command.
+	maxDescriptorRange = 1000000
+
+	// defaultScanRangeEnd is the last index ranged descriptors are
+	// expanded at by the scantxoutset command when no range is specified.
+	defaultScanRangeEnd = 1000
+
+	// scanProgressInterval is the number of unspent outputs the
+	// scantxoutset command scans in between updates of its progress and
+	// checks for whether it has been aborted.
+	scanProgressInterval = 8192
 )
 
 var (
@@ -135,7 +157,10 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"debuglevel":             handleDebugLevel,
 	"decoderawtransaction":   handleDecodeRawTransaction,
 	"decodescript":           handleDecodeScript,
+	"deriveaddresses":        handleDeriveAddresses,
+	"dumptxoutset":           handleDumpTxOutSet,
 	"estimatefee":            handleEstimateFee,
+	"estimatesmartfee":       handleEstimateSmartFee,
 	"generate":               handleGenerate,
 	"getaddednodeinfo":       handleGetAddedNodeInfo,
 	"getbestblock":           handleGetBestBlock,
@@ -145,16 +170,23 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getblockcount":          handleGetBlockCount,
 	"getblockhash":           handleGetBlockHash,
 	"getblockheader":         handleGetBlockHeader,
+	"getblockstats":          handleGetBlockStats,
 	"getblocktemplate":       handleGetBlockTemplate,
 	"getcfilter":             handleGetCFilter,
 	"getcfilterheader":       handleGetCFilterHeader,
+	"getchainstates":         handleGetChainStates,
+	"getchaintips":           handleGetChainTips,
 	"getconnectioncount":     handleGetConnectionCount,
 	"getcurrentnet":          handleGetCurrentNet,
+	"getdescriptorinfo":      handleGetDescriptorInfo,
 	"getdifficulty":          handleGetDifficulty,
 	"getgenerate":            handleGetGenerate,
 	"gethashespersec":        handleGetHashesPerSec,
 	"getheaders":             handleGetHeaders,
 	"getinfo":                handleGetInfo,
+	"getmempoolancestors":    handleGetMempoolAncestors,
+	"getmempooldescendants":  handleGetMempoolDescendants,
+	"getmempoolentry":        handleGetMempoolEntry,
 	"getmempoolinfo":         handleGetMempoolInfo,
 	"getmininginfo":          handleGetMiningInfo,
 	"getnettotals":           handleGetNetTotals,
@@ -164,19 +196,31 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getrawmempool":          handleGetRawMempool,
 	"getrawtransaction":      handleGetRawTransaction,
 	"gettxout":               handleGetTxOut,
+	"gettxoutproof":          handleGetTxOutProof,
+	"gettxoutsetinfo":        handleGetTxOutSetInfo,
+	"getutxocacheinfo":       handleGetUtxoCacheInfo,
+	"getzmqnotifications":    handleGetZmqNotifications,
 	"help":                   handleHelp,
+	"invalidateblock":        handleInvalidateBlock,
 	"node":                   handleNode,
 	"ping":                   handlePing,
+	"preciousblock":          handlePreciousBlock,
+	"prioritisetransaction":  handlePrioritiseTransaction,
+	"reconsiderblock":        handleReconsiderBlock,
+	"savemempool":            handleSaveMempool,
+	"scantxoutset":           handleScanTxOutSet,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
 	"setgenerate":            handleSetGenerate,
 	"signmessagewithprivkey": handleSignMessageWithPrivKey,
 	"stop":                   handleStop,
 	"submitblock":            handleSubmitBlock,
+	"testmempoolaccept":      handleTestMempoolAccept,
 	"uptime":                 handleUptime,
 	"validateaddress":        handleValidateAddress,
 	"verifychain":            handleVerifyChain,
 	"verifymessage":          handleVerifyMessage,
+	"verifytxoutproof":       handleVerifyTxOutProof,
 	"version":                handleVersion,
 }
 
@@ -200,7 +244,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   {},
 	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
-	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
 	"importprivkey":          {},
@@ -231,13 +274,17 @@ var rpcAskWallet = map[string]struct{}{
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
-	"getchaintips":     {},
-	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
 	"getwork":          {},
-	"invalidateblock":  {},
-	"preciousblock":    {},
-	"reconsiderblock":  {},
+
+	// The PSBT commands depend on the PSBT combiner and version 2 support
+	// of the btcutil/psbt module, which can't be required until a version
+	// of it containing them is released.
+	"analyzepsbt":   {},
+	"combinepsbt":   {},
+	"converttopsbt": {},
+	"decodepsbt":    {},
+	"finalizepsbt":  {},
 }
 
 // Commands that are available to a limited user
@@ -259,30 +306,41 @@ var rpcLimited = map[string]struct{}{
 	"createrawtransaction":  {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
+	"deriveaddresses":       {},
 	"estimatefee":           {},
+	"estimatesmartfee":      {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
 	"getblockhash":          {},
 	"getblockheader":        {},
+	"getblockstats":         {},
 	"getcfilter":            {},
 	"getcfilterheader":      {},
+	"getchainstates":        {},
 	"getcurrentnet":         {},
+	"getdescriptorinfo":     {},
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
+	"getmempoolancestors":   {},
+	"getmempooldescendants": {},
+	"getmempoolentry":       {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
 	"gettxout":              {},
+	"gettxoutproof":         {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
+	"verifytxoutproof":      {},
 	"version":               {},
 }
 
@@ -860,6 +918,162 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// parseDescriptorRange returns the first and last index of the passed
+// descriptor range, which is either the end of the range or [begin,end].
+func parseDescriptorRange(r *btcjson.DescriptorRange) (int, int, *btcjson.RPCError) {
+	var begin, end int
+	switch v := r.Value.(type) {
+	case int:
+		end = v
+	case []int:
+		begin, end = v[0], v[1]
+	default:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be an integer or [begin,end]",
+		}
+	}
+	if begin < 0 || end < 0 {
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range should be greater or equal than 0",
+		}
+	}
+	if begin > end {
+		return 0, 0, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Range specified as [begin,end] must not have " +
+				"begin after end",
+		}
+	}
+
+	// Only unhardened indices can be derived from a range, so the end
+	// must fit in 31 bits for the index to not wrap when it's converted
+	// to a uint32.
+	if end > math.MaxInt32 {
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "End of range is too high",
+		}
+	}
+	if end-begin >= maxDescriptorRange {
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range is too large",
+		}
+	}
+	return begin, end, nil
+}
+
+// handleDeriveAddresses implements the deriveaddresses command.
+func handleDeriveAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DeriveAddressesCmd)
+
+	desc, err := descriptor.Parse(c.Descriptor, true, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: err.Error(),
+		}
+	}
+
+	// Determine the range of indices to derive.  A range is required for
+	// ranged descriptors and not allowed otherwise.
+	var begin, end int
+	switch {
+	case c.Range == nil && desc.IsRange():
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be specified for a ranged descriptor",
+		}
+
+	case c.Range != nil && !desc.IsRange():
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Range should not be specified for an " +
+				"un-ranged descriptor",
+		}
+
+	case c.Range != nil:
+		var rpcErr *btcjson.RPCError
+		begin, end, rpcErr = parseDescriptorRange(c.Range)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+	}
+
+	addresses := make(btcjson.DeriveAddressesResult, 0, end-begin+1)
+	for index := begin; index <= end; index++ {
+		addr, err := desc.Address(uint32(index))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: err.Error(),
+			}
+		}
+		addresses = append(addresses, addr.EncodeAddress())
+	}
+	return addresses, nil
+}
+
+// handleDumpTxOutSet implements the dumptxoutset command.
+func handleDumpTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DumpTxOutSetCmd)
+
+	// Relative paths are relative to the data directory.  The snapshot is
+	// written to a temporary file first so an incomplete snapshot is never
+	// mistaken for a complete one.
+	path := cleanAndExpandPath(c.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.DataDir, path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("%s already exists.  If you are sure "+
+				"this is what you want, move it out of the way "+
+				"first", path),
+		}
+	}
+	tmpPath := path + ".incomplete"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0600)
+	if err != nil {
+		context := "Failed to create utxo snapshot file"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The dump is stopped when the client disconnects.
+	w := bufio.NewWriter(file)
+	info, err := s.cfg.Chain.DumpUtxoSnapshot(w, closeChan)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		context := "Failed to dump utxo snapshot"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.DumpTxOutSetResult{
+		CoinsWritten: info.NumUtxos,
+		BaseHash:     info.BaseHash.String(),
+		BaseHeight:   info.BaseHeight,
+		Path:         path,
+		TxOutSetHash: info.HashSerialized.String(),
+		NChainTx:     info.ChainTxCount,
+	}, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
@@ -882,7 +1096,61 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
-// handleGenerate handles generate commands.
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	maxTarget := int64(s.cfg.FeeEstimator.MaxConfirmTarget())
+	if c.ConfTarget < 1 || c.ConfTarget > maxTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", maxTarget),
+		}
+	}
+
+	// Conservative estimates are the default.
+	conservative := true
+	if c.EstimateMode != nil {
+		mode := btcjson.EstimateSmartFeeMode(strings.ToUpper(
+			string(*c.EstimateMode)))
+		switch mode {
+		case btcjson.EstimateModeUnset, btcjson.EstimateModeConservative:
+		case btcjson.EstimateModeEconomical:
+			conservative = false
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid estimate_mode parameter",
+			}
+		}
+	}
+
+	feeRate, blocks := s.cfg.FeeEstimator.EstimateSmartFee(
+		uint32(c.ConfTarget), conservative)
+	result := &btcjson.EstimateSmartFeeResult{
+		Blocks: int64(blocks),
+	}
+	if feeRate <= 0 {
+		result.Errors = []string{"Insufficient data or no feerate found"}
+		return result, nil
+	}
+
+	// Never estimate a fee rate below the minimum relay fee since the
+	// transaction would not be relayed otherwise.
+	btcPerKb := float64(feeRate)
+	if minRelayFee := cfg.minRelayTxFee.ToBTC(); btcPerKb < minRelayFee {
+		btcPerKb = minRelayFee
+	}
+	result.FeeRate = &btcPerKb
+
+	return result, nil
+}
+
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
 	// created blocks to.
@@ -1200,12 +1468,28 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		BestBlockHash: chainSnapshot.Hash.String(),
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
-		Pruned:        false,
 		SoftForks: &btcjson.SoftForks{
 			Bip9SoftForks: make(map[string]*btcjson.Bip9SoftForkDescription),
 		},
 	}
 
+	// Report whether block data has been deleted from the database along
+	// with the height of the oldest block which is still available.
+	pruned, err := chain.IsPruned()
+	if err != nil {
+		context := "Failed to determine whether blocks were pruned"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	chainInfo.Pruned = pruned
+	if chainInfo.Pruned {
+		pruneHeight, err := chain.PruneHeight()
+		if err != nil {
+			context := "Failed to determine prune height"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		chainInfo.PruneHeight = pruneHeight
+	}
+
 	// Next, populate the response with information describing the current
 	// status of soft-forks deployed via the super-majority block
 	// signalling mechanism.
@@ -1399,6 +1683,403 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	return blockHeaderReply, nil
 }
 
+const (
+	// blockStatsCacheSize is the maximum number of block statistics kept
+	// in the cache of the getblockstats RPC.
+	blockStatsCacheSize = 1000
+
+	// perUTXOOverhead is the number of bytes every unspent output is
+	// assumed to take in the utxo set in addition to the serialized
+	// output for the purpose of the utxo size statistics.  It consists of
+	// the outpoint, the height along with the coinbase flag.
+	perUTXOOverhead = chainhash.HashSize + 4 + 4 + 1
+)
+
+// blockStatsNames is the set of statistics that can be selected in the
+// getblockstats RPC, which are the JSON field names of its result.
+var blockStatsNames = func() map[string]struct{} {
+	names := make(map[string]struct{})
+	rt := reflect.TypeOf(btcjson.GetBlockStatsResult{})
+	for i := 0; i < rt.NumField(); i++ {
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		names[name] = struct{}{}
+	}
+	return names
+}()
+
+// blockStatsCache is a fixed size cache of the statistics computed by the
+// getblockstats RPC keyed by block hash.  The statistics of a block never
+// change, so the least recently used entries are evicted only to bound its
+// size.
+type blockStatsCache struct {
+	mtx     sync.Mutex
+	entries map[chainhash.Hash]*list.Element
+	lru     *list.List
+}
+
+// blockStatsCacheEntry is an entry of the block statistics cache.
+type blockStatsCacheEntry struct {
+	hash  chainhash.Hash
+	stats *btcjson.GetBlockStatsResult
+}
+
+// newBlockStatsCache returns a new empty block statistics cache.
+func newBlockStatsCache() *blockStatsCache {
+	return &blockStatsCache{
+		entries: make(map[chainhash.Hash]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Lookup returns the cached statistics of the block with the passed hash, or
+// nil if they are not cached.
+func (c *blockStatsCache) Lookup(hash *chainhash.Hash) *btcjson.GetBlockStatsResult {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[*hash]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*blockStatsCacheEntry).stats
+}
+
+// Add caches the statistics of the block with the passed hash, evicting the
+// least recently used entry if the cache is full.
+func (c *blockStatsCache) Add(hash *chainhash.Hash, stats *btcjson.GetBlockStatsResult) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.entries[*hash]; ok {
+		return
+	}
+	if c.lru.Len() >= blockStatsCacheSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*blockStatsCacheEntry).hash)
+	}
+	entry := &blockStatsCacheEntry{hash: *hash, stats: stats}
+	c.entries[*hash] = c.lru.PushFront(entry)
+}
+
+// truncatedMedian returns the median of the passed values, rounded down to an
+// integer, or zero if there are none.  The values are sorted in place.
+func truncatedMedian(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// feeRateWeight houses the fee rate of a transaction in satoshi per virtual
+// byte along with its weight.
+type feeRateWeight struct {
+	feeRate int64
+	weight  int64
+}
+
+// feeRatePercentiles returns the 10th, 25th, 50th, 75th and 90th percentiles
+// of the fee rates of the passed transactions weighted by their weight.  The
+// transactions are sorted in place.
+func feeRatePercentiles(txns []feeRateWeight) []int64 {
+	percentiles := make([]int64, 5)
+	if len(txns) == 0 {
+		return percentiles
+	}
+
+	sort.Slice(txns, func(i, j int) bool {
+		return txns[i].feeRate < txns[j].feeRate
+	})
+
+	var totalWeight int64
+	for _, tx := range txns {
+		totalWeight += tx.weight
+	}
+	thresholds := []float64{
+		float64(totalWeight) / 10,
+		float64(totalWeight) / 4,
+		float64(totalWeight) / 2,
+		float64(totalWeight) * 3 / 4,
+		float64(totalWeight) * 9 / 10,
+	}
+
+	// Each percentile is the fee rate of the transaction at which the
+	// cumulative weight reaches the percentile of the total weight.
+	var cumulativeWeight int64
+	next := 0
+	for _, tx := range txns {
+		cumulativeWeight += tx.weight
+		for next < len(thresholds) &&
+			float64(cumulativeWeight) >= thresholds[next] {
+
+			percentiles[next] = tx.feeRate
+			next++
+		}
+	}
+
+	// Rounding may leave the highest percentiles unset, so use the
+	// highest fee rate for them.
+	for ; next < len(thresholds); next++ {
+		percentiles[next] = txns[len(txns)-1].feeRate
+	}
+
+	return percentiles
+}
+
+// calcBlockStats computes the statistics returned by the getblockstats RPC for
+// the passed block.  The passed spent outputs must be the spend journal of the
+// block, which provides the outputs spent by its transactions in order.
+func calcBlockStats(block *btcutil.Block, stxos []blockchain.SpentTxOut,
+	medianTime time.Time, params *chaincfg.Params) (*btcjson.GetBlockStatsResult, error) {
+
+	height := block.Height()
+	txns := block.Transactions()
+	stats := &btcjson.GetBlockStatsResult{
+		Hash:       block.Hash().String(),
+		Height:     int64(height),
+		MedianTime: medianTime.Unix(),
+		Subsidy:    blockchain.CalcBlockSubsidy(height, params),
+		Time:       block.MsgBlock().Header.Timestamp.Unix(),
+		Txs:        int64(len(txns)),
+	}
+
+	var (
+		fees         []int64
+		txSizes      []int64
+		feeRates     []feeRateWeight
+		inputs       int64
+		outputs      int64
+		stxoIdx      int
+		minFee       int64 = math.MaxInt64
+		minFeeRate   int64 = math.MaxInt64
+		minTxSize    int64 = math.MaxInt64
+		numNonCbTxns       = int64(len(txns) - 1)
+	)
+	for _, tx := range txns {
+		msgTx := tx.MsgTx()
+		isCoinBase := blockchain.IsCoinBaseTx(msgTx)
+		outputs += int64(len(msgTx.TxOut))
+
+		var txTotalOut int64
+		for _, txOut := range msgTx.TxOut {
+			txTotalOut += txOut.Value
+			outSize := int64(txOut.SerializeSize()) + perUTXOOverhead
+			stats.UTXOSizeIncrease += outSize
+
+			// The genesis block doesn't change the utxo set and
+			// unspendable outputs are never added to it.
+			if height == 0 || txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			stats.UTXOIncreaseActual++
+			stats.UTXOSizeIncreaseActual += outSize
+		}
+
+		if isCoinBase {
+			continue
+		}
+		inputs += int64(len(msgTx.TxIn))
+		stats.TotalOut += txTotalOut
+
+		var txTotalIn int64
+		for range msgTx.TxIn {
+			if stxoIdx >= len(stxos) {
+				return nil, fmt.Errorf("spend journal of block "+
+					"%v is missing spent outputs", block.Hash())
+			}
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+
+			txTotalIn += stxo.Amount
+			prevOut := wire.TxOut{
+				Value:    stxo.Amount,
+				PkScript: stxo.PkScript,
+			}
+			inSize := int64(prevOut.SerializeSize()) + perUTXOOverhead
+			stats.UTXOSizeIncrease -= inSize
+			stats.UTXOSizeIncreaseActual -= inSize
+		}
+
+		txSize := int64(msgTx.SerializeSize())
+		weight := blockchain.GetTransactionWeight(tx)
+		if tx.HasWitness() {
+			stats.SegWitTxs++
+			stats.SegWitTotalSize += txSize
+			stats.SegWitTotalWeight += weight
+		}
+		stats.TotalSize += txSize
+		stats.TotalWeight += weight
+		txSizes = append(txSizes, txSize)
+		if txSize < minTxSize {
+			minTxSize = txSize
+		}
+		if txSize > stats.MaxTxSize {
+			stats.MaxTxSize = txSize
+		}
+
+		fee := txTotalIn - txTotalOut
+		feeRate := fee * blockchain.WitnessScaleFactor / weight
+		stats.TotalFee += fee
+		fees = append(fees, fee)
+		feeRates = append(feeRates, feeRateWeight{feeRate, weight})
+		if fee < minFee {
+			minFee = fee
+		}
+		if fee > stats.MaxFee {
+			stats.MaxFee = fee
+		}
+		if feeRate < minFeeRate {
+			minFeeRate = feeRate
+		}
+		if feeRate > stats.MaxFeeRate {
+			stats.MaxFeeRate = feeRate
+		}
+	}
+
+	stats.Ins = inputs
+	stats.Outs = outputs
+	stats.UTXOIncrease = outputs - inputs
+	stats.UTXOIncreaseActual -= inputs
+	if numNonCbTxns > 0 {
+		stats.AverageFee = stats.TotalFee / numNonCbTxns
+		stats.AverageTxSize = stats.TotalSize / numNonCbTxns
+		stats.AverageFeeRate = stats.TotalFee *
+			blockchain.WitnessScaleFactor / stats.TotalWeight
+		stats.MinFee = minFee
+		stats.MinFeeRate = minFeeRate
+		stats.MinTxSize = minTxSize
+	}
+	stats.MedianFee = truncatedMedian(fees)
+	stats.MedianTxSize = truncatedMedian(txSizes)
+	stats.FeeratePercentiles = feeRatePercentiles(feeRates)
+
+	return stats, nil
+}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+
+	// Look up the hash of the requested block which is either specified
+	// by its height in the main chain or by its hash.
+	var hash *chainhash.Hash
+	switch hashOrHeight := c.HashOrHeight.Value.(type) {
+	case int:
+		best := s.cfg.Chain.BestSnapshot()
+		if hashOrHeight < 0 {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d is "+
+					"negative", hashOrHeight),
+			}
+		}
+		if hashOrHeight > int(best.Height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d after "+
+					"current tip %d", hashOrHeight, best.Height),
+			}
+		}
+		var err error
+		hash, err = s.cfg.Chain.BlockHashByHeight(int32(hashOrHeight))
+		if err != nil {
+			context := "Failed to obtain block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+	case string:
+		var err error
+		hash, err = chainhash.NewHashFromStr(hashOrHeight)
+		if err != nil {
+			return nil, rpcDecodeHexError(hashOrHeight)
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Block hash or height must be specified",
+		}
+	}
+
+	// Validate the requested statistics before doing any work.
+	if c.Stats != nil {
+		for _, stat := range *c.Stats {
+			if _, ok := blockStatsNames[stat]; !ok {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Invalid selected "+
+						"statistic %s", stat),
+				}
+			}
+		}
+	}
+
+	stats := s.blockStatsCache.Lookup(hash)
+	if stats == nil {
+		// The spend journal is only available for blocks in the main
+		// chain.
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+		block, err := s.cfg.Chain.BlockByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+		stxos, err := s.cfg.Chain.FetchSpendJournal(block)
+		if err != nil {
+			context := "Failed to fetch spend journal"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		medianTime, err := s.cfg.Chain.PastMedianTime(
+			&block.MsgBlock().Header)
+		if err != nil {
+			context := "Failed to obtain median time"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		stats, err = calcBlockStats(block, stxos, medianTime,
+			s.cfg.ChainParams)
+		if err != nil {
+			context := "Failed to calculate block statistics"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		s.blockStatsCache.Add(hash, stats)
+	}
+
+	if c.Stats == nil || len(*c.Stats) == 0 {
+		return stats, nil
+	}
+
+	// Only return the selected statistics.
+	statsJSON, err := json.Marshal(stats)
+	if err != nil {
+		context := "Failed to marshal block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	var allStats map[string]json.RawMessage
+	if err := json.Unmarshal(statsJSON, &allStats); err != nil {
+		context := "Failed to unmarshal block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	selected := make(map[string]json.RawMessage, len(*c.Stats))
+	for _, stat := range *c.Stats {
+		selected[stat] = allStats[stat]
+	}
+	return selected, nil
+}
+
 // encodeTemplateID encodes the passed details into an ID that can be used to
 // uniquely identify a block template.
 func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
@@ -2252,6 +2933,47 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hash.String(), nil
 }
 
+// handleGetChainStates implements the getchainstates command.
+func handleGetChainStates(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	chainStates := s.cfg.Chain.ChainStates()
+	results := make([]btcjson.ChainStateResult, 0, len(chainStates))
+	for _, chainState := range chainStates {
+		result := btcjson.ChainStateResult{
+			Blocks:        chainState.Height,
+			BestBlockHash: chainState.Hash.String(),
+			Difficulty: getDifficultyRatio(chainState.Bits,
+				s.cfg.ChainParams),
+			VerificationProgress: chainState.Progress,
+			Validated:            chainState.Validated,
+		}
+		if chainState.SnapshotHash != nil {
+			result.SnapshotBlockHash = chainState.SnapshotHash.String()
+		}
+		results = append(results, result)
+	}
+
+	return &btcjson.GetChainStatesResult{
+		Headers:     s.cfg.Chain.BestSnapshot().Height,
+		ChainStates: results,
+	}, nil
+}
+
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	chainTips := s.cfg.Chain.ChainTips()
+	results := make([]btcjson.GetChainTipsResult, 0, len(chainTips))
+	for _, tip := range chainTips {
+		results = append(results, btcjson.GetChainTipsResult{
+			Height:    tip.Height,
+			Hash:      tip.Hash.String(),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status.String(),
+		})
+	}
+
+	return results, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2262,6 +2984,39 @@ func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return s.cfg.ChainParams.Net, nil
 }
 
+// handleGetDescriptorInfo implements the getdescriptorinfo command.
+func handleGetDescriptorInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetDescriptorInfoCmd)
+
+	desc, err := descriptor.Parse(c.Descriptor, false, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: err.Error(),
+		}
+	}
+
+	// The checksum is of the descriptor as provided, without any checksum
+	// it already has.
+	checksum, err := descriptor.Checksum(
+		strings.SplitN(c.Descriptor, "#", 2)[0],
+	)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: err.Error(),
+		}
+	}
+
+	return &btcjson.GetDescriptorInfoResult{
+		Descriptor:     desc.String(),
+		Checksum:       checksum,
+		IsRange:        desc.IsRange(),
+		IsSolvable:     desc.IsSolvable(),
+		HasPrivateKeys: desc.HasPrivateKeys(),
+	}, nil
+}
+
 // handleGetDifficulty implements the getdifficulty command.
 func handleGetDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
@@ -2338,8 +3093,84 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return ret, nil
 }
 
-// handleGetMempoolInfo implements the getmempoolinfo command.
-func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+// mempoolEntriesReply returns the reply to the getmempoolancestors and
+// getmempooldescendants commands for the passed entries, which is either the
+// entries keyed by their hashes or an array of their hashes when the verbose
+// flag is not set.
+func mempoolEntriesReply(entries map[string]*btcjson.GetMempoolEntryResult,
+	verbose *bool) interface{} {
+
+	if verbose != nil && *verbose {
+		return entries
+	}
+
+	hashStrings := make([]string, 0, len(entries))
+	for hashString := range entries {
+		hashStrings = append(hashStrings, hashString)
+	}
+	sort.Strings(hashStrings)
+	return hashStrings
+}
+
+// rpcNotInMempoolError is a convenience function for returning a nicely
+// formatted RPC error which indicates the transaction is not in the mempool.
+func rpcNotInMempoolError() *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidAddressOrKey,
+		Message: "Transaction not in mempool",
+	}
+}
+
+// handleGetMempoolAncestors implements the getmempoolancestors command.
+func handleGetMempoolAncestors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entries, err := s.cfg.TxMemPool.MempoolAncestors(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError()
+	}
+
+	return mempoolEntriesReply(entries, c.Verbose), nil
+}
+
+// handleGetMempoolDescendants implements the getmempooldescendants command.
+func handleGetMempoolDescendants(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entries, err := s.cfg.TxMemPool.MempoolDescendants(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError()
+	}
+
+	return mempoolEntriesReply(entries, c.Verbose), nil
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entry, err := s.cfg.TxMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError()
+	}
+
+	return entry, nil
+}
+
+// handleGetMempoolInfo implements the getmempoolinfo command.
+func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
 
 	var numBytes int64
@@ -2347,9 +3178,21 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	// The minimum fee rate for transactions to be accepted is never below
+	// the minimum relay fee.
+	mempoolMinFee := s.cfg.TxMemPool.MinFeeRate()
+	if mempoolMinFee < cfg.minRelayTxFee {
+		mempoolMinFee = cfg.minRelayTxFee
+	}
+
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Loaded:        s.cfg.TxMemPool.IsLoaded(),
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		Usage:         s.cfg.TxMemPool.Usage(),
+		MaxMempool:    cfg.MaxMempool * 1000000,
+		MempoolMinFee: mempoolMinFee.ToBTC(),
+		MinRelayTxFee: cfg.minRelayTxFee.ToBTC(),
 	}
 
 	return ret, nil
@@ -2814,6 +3657,183 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return txOutReply, nil
 }
 
+// handleGetTxOutProof implements the gettxoutproof command.
+func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutProofCmd)
+
+	// Convert the provided transaction hashes and ensure there are no
+	// duplicates.
+	if len(c.TxIDs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid parameter, txids must not be empty",
+		}
+	}
+	txHashes := make([]*chainhash.Hash, 0, len(c.TxIDs))
+	seen := make(map[chainhash.Hash]struct{}, len(c.TxIDs))
+	for _, txID := range c.TxIDs {
+		txHash, err := chainhash.NewHashFromStr(txID)
+		if err != nil {
+			return nil, rpcDecodeHexError(txID)
+		}
+		if _, ok := seen[*txHash]; ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid parameter, "+
+					"duplicated txid: %s", txID),
+			}
+		}
+		seen[*txHash] = struct{}{}
+		txHashes = append(txHashes, txHash)
+	}
+
+	// Determine the block to generate the proof for.  Use the explicitly
+	// provided block hash when there is one, and otherwise look up the
+	// block containing the first transaction in the transaction index.
+	var blockHash *chainhash.Hash
+	if c.BlockHash != nil {
+		hash, err := chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		blockHash = hash
+	} else {
+		if s.cfg.TxIndex == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: "The transaction index must be " +
+					"enabled to locate transactions without " +
+					"a block hash (specify --txindex)",
+			}
+		}
+
+		blockRegion, err := s.cfg.TxIndex.TxBlockRegion(txHashes[0])
+		if err != nil {
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Transaction not yet in block",
+			}
+		}
+		blockHash = blockRegion.Hash
+	}
+
+	// Load the block from the database.
+	var blkBytes []byte
+	err := s.cfg.DB.View(func(dbTx database.Tx) error {
+		var err error
+		blkBytes, err = dbTx.FetchBlock(blockHash)
+		return err
+	})
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+	blk, err := btcutil.NewBlockFromBytes(blkBytes)
+	if err != nil {
+		context := "Failed to deserialize block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Create the proof and ensure every requested transaction is in it.
+	mBlock, matchedIndices := bloom.NewMerkleBlockFromTxHashes(blk, txHashes)
+	if len(matchedIndices) != len(txHashes) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Not all transactions found in specified " +
+				"or retrieved block",
+		}
+	}
+
+	var buf bytes.Buffer
+	err = mBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		context := "Failed to encode merkle block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// handleGetTxOutSetInfo implements the gettxoutsetinfo command.
+func handleGetTxOutSetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutSetInfoCmd)
+
+	hashType := blockchain.UtxoSetHashSerialized
+	if c.HashType != nil {
+		switch *c.HashType {
+		case btcjson.TxOutSetHashSerialized:
+		case btcjson.TxOutSetHashMuHash:
+			hashType = blockchain.UtxoSetHashMuHash
+		case btcjson.TxOutSetHashNone:
+			hashType = blockchain.UtxoSetHashNone
+		default:
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("%s is not a valid hash_type",
+					*c.HashType),
+			}
+		}
+	}
+
+	// The utxo set is scanned unless the MuHash is requested and the
+	// statistics are maintained incrementally.  The scan is stopped when
+	// the client disconnects.
+	stats, err := s.cfg.Chain.FetchUtxoSetStats(hashType, closeChan)
+	if err != nil {
+		context := "Failed to fetch utxo set statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.GetTxOutSetInfoResult{
+		Height:         int64(stats.Height),
+		BestBlock:      stats.Hash,
+		Transactions:   stats.Transactions,
+		TxOuts:         stats.TxOuts,
+		BogoSize:       stats.BogoSize,
+		HashSerialized: stats.HashSerialized,
+		MuHash:         stats.MuHash,
+		DiskSize:       stats.DiskSize,
+		TotalAmount:    btcutil.Amount(stats.TotalAmount),
+	}, nil
+}
+
+// handleGetUtxoCacheInfo implements the getutxocacheinfo command.
+func handleGetUtxoCacheInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	stats := s.cfg.Chain.UtxoCacheStats()
+	return &btcjson.GetUtxoCacheInfoResult{
+		Entries:       stats.Entries,
+		Size:          stats.Size,
+		MaxSize:       stats.MaxSize,
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		Flushes:       stats.Flushes,
+		LastFlushHash: stats.LastFlushHash.String(),
+		LastFlushTime: stats.LastFlushTime.Unix(),
+	}, nil
+}
+
+// handleGetZmqNotifications implements the getzmqnotifications command.
+func handleGetZmqNotifications(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	result := []btcjson.ZmqNotificationResult{}
+	if s.cfg.ZMQNotifier == nil {
+		return result, nil
+	}
+
+	for _, notification := range s.cfg.ZMQNotifier.Notifications() {
+		result = append(result, btcjson.ZmqNotificationResult{
+			Type:          notification.Type,
+			Address:       notification.Address,
+			HighWaterMark: notification.HighWaterMark,
+		})
+	}
+	return result, nil
+}
+
 // handleHelp implements the help command.
 func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.HelpCmd)
@@ -2853,6 +3873,56 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// chainBlockHash decodes the passed block hash and ensures the block is known
+// to the chain.
+func chainBlockHash(s *rpcServer, blockHash string) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(blockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	return hash, nil
+}
+
+// blockStatusRPCError converts an error returned by the chain when
+// invalidating, reconsidering or prioritising a block to an RPC error with the
+// passed message prefix.  Unknown blocks are reported as not found and
+// violated rules as invalid parameters.
+func blockStatusRPCError(err error, prefix string) *btcjson.RPCError {
+	code := btcjson.ErrRPCDatabase
+	if ruleErr, ok := err.(blockchain.RuleError); ok {
+		code = btcjson.ErrRPCInvalidParameter
+		if ruleErr.ErrorCode == blockchain.ErrUnknownBlock {
+			code = btcjson.ErrRPCBlockNotFound
+		}
+	}
+	return &btcjson.RPCError{
+		Code:    code,
+		Message: prefix + err.Error(),
+	}
+}
+
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.InvalidateBlockCmd)
+	hash, err := chainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cfg.Chain.InvalidateBlock(hash); err != nil {
+		return nil, blockStatusRPCError(err, "Unable to invalidate block: ")
+	}
+
+	return nil, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -2866,6 +3936,58 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handlePreciousBlock implements the preciousblock command.
+func handlePreciousBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PreciousBlockCmd)
+	hash, err := chainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cfg.Chain.PreciousBlock(hash); err != nil {
+		return nil, blockStatusRPCError(err, "Unable to treat block as precious: ")
+	}
+
+	return nil, nil
+}
+
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	// Priority deltas are no longer supported, so only the fee delta
+	// may be set.
+	if c.Dummy != 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Priority is no longer supported, dummy " +
+				"argument to prioritisetransaction must be 0",
+		}
+	}
+
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+	return true, nil
+}
+
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ReconsiderBlockCmd)
+	hash, err := chainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cfg.Chain.ReconsiderBlock(hash); err != nil {
+		return nil, blockStatusRPCError(err, "Unable to reconsider block: ")
+	}
+
+	return nil, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
@@ -3087,6 +4209,255 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// utxoScanState houses state that is used in between multiple RPC invocations
+// of scantxoutset.  It allows the progress of the scan in progress to be
+// queried and the scan to be aborted by other invocations.
+type utxoScanState struct {
+	sync.Mutex
+	running  bool
+	progress float64
+	abort    chan struct{}
+}
+
+// start marks a new scan as running and returns the channel that is closed
+// when it is to be aborted.  It returns false if a scan is already running.
+func (state *utxoScanState) start() (<-chan struct{}, bool) {
+	state.Lock()
+	defer state.Unlock()
+
+	if state.running {
+		return nil, false
+	}
+	state.running = true
+	state.progress = 0
+	state.abort = make(chan struct{})
+	return state.abort, true
+}
+
+// finish marks the scan in progress as no longer running.
+func (state *utxoScanState) finish() {
+	state.Lock()
+	state.running = false
+	state.abort = nil
+	state.Unlock()
+}
+
+// setProgress sets the progress of the scan in progress as a percentage.
+func (state *utxoScanState) setProgress(progress float64) {
+	state.Lock()
+	state.progress = progress
+	state.Unlock()
+}
+
+// status returns the progress of the scan in progress as a percentage, and
+// false if there is no scan in progress.
+func (state *utxoScanState) status() (float64, bool) {
+	state.Lock()
+	defer state.Unlock()
+
+	return state.progress, state.running
+}
+
+// requestAbort requests the scan in progress to be aborted.  It returns false
+// if there is no scan in progress or it has already been aborted.
+func (state *utxoScanState) requestAbort() bool {
+	state.Lock()
+	defer state.Unlock()
+
+	if !state.running || state.abort == nil {
+		return false
+	}
+	close(state.abort)
+	state.abort = nil
+	return true
+}
+
+// errScanAborted is returned from the utxo set scan callback to stop the
+// scantxoutset command when it is aborted.
+var errScanAborted = errors.New("scan aborted")
+
+// inferDescriptor returns a descriptor with checksum for the passed public key
+// script.  The script is described by its address when it has one, and by its
+// raw hex encoding otherwise.
+func inferDescriptor(pkScript []byte, params *chaincfg.Params) string {
+	desc := "raw(" + hex.EncodeToString(pkScript) + ")"
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err == nil && len(addrs) == 1 && class != txscript.PubKeyTy &&
+		class != txscript.MultiSigTy {
+
+		desc = "addr(" + addrs[0].EncodeAddress() + ")"
+	}
+
+	// Descriptors for standard scripts and addresses only contain valid
+	// characters, so adding the checksum can't fail.
+	desc, _ = descriptor.AddChecksum(desc)
+	return desc
+}
+
+// handleSaveMempool implements the savemempool command.
+func handleSaveMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Saving the mempool before it was loaded would overwrite the file
+	// with the transactions of the last shutdown.
+	if !s.cfg.TxMemPool.IsLoaded() {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The mempool was not loaded yet",
+		}
+	}
+
+	path, err := saveMempool(s.cfg.TxMemPool)
+	if err != nil {
+		context := "Failed to save mempool"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.SaveMempoolResult{Filename: path}, nil
+}
+
+// handleScanTxOutSet implements the scantxoutset command.
+func handleScanTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+
+	switch c.Action {
+	case btcjson.ScanTxOutSetActionStatus:
+		progress, running := s.utxoScanState.status()
+		if !running {
+			return nil, nil
+		}
+		return &btcjson.ScanTxOutSetStatusResult{
+			Progress: progress,
+		}, nil
+
+	case btcjson.ScanTxOutSetActionAbort:
+		return s.utxoScanState.requestAbort(), nil
+
+	case btcjson.ScanTxOutSetActionStart:
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid action '%s'", c.Action),
+		}
+	}
+
+	if c.ScanObjects == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "scanobjects argument is required for the start action",
+		}
+	}
+
+	// Expand the descriptors into the set of scripts to scan for.
+	params := s.cfg.ChainParams
+	scripts := make(map[string]struct{})
+	for _, obj := range *c.ScanObjects {
+		desc, err := descriptor.Parse(obj.Desc, false, params)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: err.Error(),
+			}
+		}
+
+		var begin, end int
+		switch {
+		case obj.Range != nil && !desc.IsRange():
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Range should not be specified for an " +
+					"un-ranged descriptor",
+			}
+
+		case obj.Range != nil:
+			var rpcErr *btcjson.RPCError
+			begin, end, rpcErr = parseDescriptorRange(obj.Range)
+			if rpcErr != nil {
+				return nil, rpcErr
+			}
+
+		case desc.IsRange():
+			end = defaultScanRangeEnd
+		}
+
+		for index := begin; index <= end; index++ {
+			exp, err := desc.Expand(uint32(index))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidAddressOrKey,
+					Message: err.Error(),
+				}
+			}
+			scripts[string(exp.OutputScript)] = struct{}{}
+		}
+	}
+
+	abort, ok := s.utxoScanState.start()
+	if !ok {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Scan already in progress, use action " +
+				"\"abort\" or \"status\"",
+		}
+	}
+	defer s.utxoScanState.finish()
+
+	// Scan the utxo set for outputs paying to any of the scripts.  The
+	// scan is aborted when requested, when the client disconnects and when
+	// the server shuts down.
+	result := &btcjson.ScanTxOutSetResult{
+		Unspents: []btcjson.ScanTxOutSetUnspent{},
+	}
+	var totalAmount int64
+	bestHash, height, err := s.cfg.Chain.ScanUtxoSet(func(outpoint wire.OutPoint,
+		entry *blockchain.UtxoEntry) error {
+
+		result.TxOuts++
+		if result.TxOuts%scanProgressInterval == 0 {
+			select {
+			case <-abort:
+				return errScanAborted
+			case <-closeChan:
+				return errScanAborted
+			case <-s.quit:
+				return errScanAborted
+			default:
+			}
+
+			// The outputs are visited in order of their hash, so
+			// use its leading bytes to estimate the progress.
+			prefix := uint16(outpoint.Hash[0])<<8 | uint16(outpoint.Hash[1])
+			s.utxoScanState.setProgress(float64(prefix) * 100 / 0xffff)
+		}
+
+		pkScript := entry.PkScript()
+		if _, ok := scripts[string(pkScript)]; !ok {
+			return nil
+		}
+		totalAmount += entry.Amount()
+		result.Unspents = append(result.Unspents, btcjson.ScanTxOutSetUnspent{
+			TxID:         outpoint.Hash.String(),
+			Vout:         outpoint.Index,
+			ScriptPubKey: hex.EncodeToString(pkScript),
+			Desc:         inferDescriptor(pkScript, params),
+			Amount:       btcutil.Amount(entry.Amount()).ToBTC(),
+			Height:       entry.BlockHeight(),
+		})
+		return nil
+	})
+	if err != nil && err != errScanAborted {
+		context := "Failed to scan utxo set"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result.Success = err == nil
+	if bestHash != nil {
+		result.Height = height
+		result.BestBlock = bestHash.String()
+	}
+	result.TotalAmount = btcutil.Amount(totalAmount).ToBTC()
+	return result, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3577,6 +4948,92 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
+
+	if len(c.RawTxns) == 0 || len(c.RawTxns) > mempool.MaxPackageCount {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Array must contain between 1 and "+
+				"%d transactions", mempool.MaxPackageCount),
+		}
+	}
+
+	// The maximum fee rate is specified in BTC/kvB.
+	var maxFeeRate btcutil.Amount
+	if c.MaxFeeRate != nil {
+		var err error
+		maxFeeRate, err = btcutil.NewAmount(*c.MaxFeeRate)
+		if err != nil || maxFeeRate < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid maxfeerate",
+			}
+		}
+	}
+
+	txns := make([]*btcutil.Tx, 0, len(c.RawTxns))
+	for _, hexStr := range c.RawTxns {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, btcutil.NewTx(&msgTx))
+	}
+
+	// Test the transactions without adding them to the mempool or relaying
+	// them.  Transactions paying a fee rate above the maximum are rejected
+	// along with their descendants to guard against accidentally
+	// overpaying.
+	results, err := s.cfg.TxMemPool.TestAccept(txns, maxFeeRate)
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Package rejected: " + err.Error(),
+			}
+		}
+		context := "Failed to test transactions"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	reply := make([]btcjson.TestMempoolAcceptResult, 0, len(results))
+	for _, result := range results {
+		tx := result.Tx
+		acceptResult := btcjson.TestMempoolAcceptResult{
+			TxID:  tx.Hash().String(),
+			Wtxid: tx.WitnessHash().String(),
+		}
+
+		if result.Err != nil {
+			acceptResult.RejectReason = result.Err.Error()
+			reply = append(reply, acceptResult)
+			continue
+		}
+
+		acceptResult.Allowed = true
+		acceptResult.VSize = result.VSize
+		acceptResult.Fees = &btcjson.TestMempoolAcceptFees{
+			Base: btcutil.Amount(result.Fee).ToBTC(),
+		}
+		reply = append(reply, acceptResult)
+	}
+
+	return reply, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3744,6 +5201,63 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return address.EncodeAddress() == c.Address, nil
 }
 
+// handleVerifyTxOutProof implements the verifytxoutproof command.
+func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyTxOutProofCmd)
+
+	// Deserialize the proof.
+	hexStr := c.Proof
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedProof, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mBlock wire.MsgMerkleBlock
+	err = mBlock.BtcDecode(bytes.NewReader(serializedProof),
+		wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Proof decode failed: " + err.Error(),
+		}
+	}
+
+	// A proof with an invalid partial merkle tree doesn't prove anything,
+	// so return an empty list in that case.
+	txHashes, err := bloom.ExtractMatches(&mBlock)
+	if err != nil {
+		return []string{}, nil
+	}
+
+	// The proof is only valid when the block it commits to is in the main
+	// chain and contains the number of transactions the proof claims.
+	blockHash := mBlock.Header.BlockHash()
+	if !s.cfg.Chain.MainChainHasBlock(&blockHash) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in chain",
+		}
+	}
+	numTxns, err := s.cfg.Chain.BlockTxCount(&blockHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block not available: " + err.Error(),
+		}
+	}
+	if numTxns != uint64(mBlock.Transactions) {
+		return []string{}, nil
+	}
+
+	txIDs := make([]string, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		txIDs = append(txIDs, txHash.String())
+	}
+	return txIDs, nil
+}
+
 // handleVersion implements the version command.
 //
 // NOTE: This is a btcsuite extension ported from github.com/decred/dcrd.
@@ -3773,6 +5287,8 @@ type rpcServer struct {
 	wg                     sync.WaitGroup
 	gbtWorkState           *gbtWorkState
 	helpCacher             *helpCacher
+	blockStatsCache        *blockStatsCache
+	utxoScanState          utxoScanState
 	requestProcessShutdown chan struct{}
 	quit                   chan int
 }
@@ -4604,7 +6120,11 @@ type rpcserverConfig struct {
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
-	FeeEstimator *mempool.FeeEstimator
+	FeeEstimator *mempool.SmartFeeEstimator
+
+	// ZMQNotifier publishes block and transaction events to ZMQ
+	// subscribers.  It is nil when no ZMQ notifications are enabled.
+	ZMQNotifier *zmq.Notifier
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
@@ -4614,6 +6134,7 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 		statusLines:            make(map[int]string),
 		gbtWorkState:           newGbtWorkState(config.TimeSource),
 		helpCacher:             newHelpCacher(),
+		blockStatsCache:        newBlockStatsCache(),
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
 	}
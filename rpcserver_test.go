@@ -0,0 +1,309 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestFeeRatePercentiles ensures the fee rate percentiles are weighted by the
+// weight of the transactions.
+func TestFeeRatePercentiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		txns []feeRateWeight
+		want []int64
+	}{
+		{
+			name: "no transactions",
+			txns: nil,
+			want: []int64{0, 0, 0, 0, 0},
+		},
+		{
+			name: "single transaction",
+			txns: []feeRateWeight{{5, 400}},
+			want: []int64{5, 5, 5, 5, 5},
+		},
+		{
+			name: "equal weights",
+			txns: []feeRateWeight{
+				{10, 4}, {9, 4}, {8, 4}, {7, 4}, {6, 4},
+				{5, 4}, {4, 4}, {3, 4}, {2, 4}, {1, 4},
+			},
+			want: []int64{1, 3, 5, 8, 9},
+		},
+		{
+			// The heavy transaction accounts for 80% of the total
+			// weight, so it determines all but the lowest
+			// percentile even though it's only one of three.
+			name: "heavy transaction",
+			txns: []feeRateWeight{{3, 800}, {1, 100}, {2, 100}},
+			want: []int64{1, 3, 3, 3, 3},
+		},
+		{
+			name: "light transactions",
+			txns: []feeRateWeight{{1, 900}, {50, 50}, {100, 50}},
+			want: []int64{1, 1, 1, 1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		got := feeRatePercentiles(test.txns)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got percentiles %v, want %v", test.name,
+				got, test.want)
+		}
+	}
+}
+
+// TestCalcBlockStats ensures the statistics of a block containing a legacy and
+// a segwit transaction are computed from the block and its spent outputs.
+func TestCalcBlockStats(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	p2pkhScript := make([]byte, 25)
+	p2wpkhScript := append([]byte{txscript.OP_0, 20}, make([]byte, 20)...)
+	nullDataScript := []byte{txscript.OP_RETURN, 1, 0x01}
+
+	// The legacy transaction spends 100000 satoshi and pays a fee of
+	// 10000 satoshi.
+	legacyTx := wire.NewMsgTx(1)
+	legacyTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+		SignatureScript:  make([]byte, 107),
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	legacyTx.AddTxOut(wire.NewTxOut(60000, p2pkhScript))
+	legacyTx.AddTxOut(wire.NewTxOut(30000, p2pkhScript))
+
+	// The segwit transaction spends 50000 satoshi and pays a fee of 1000
+	// satoshi.
+	segwitTx := wire.NewMsgTx(2)
+	segwitTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x02}},
+		Witness:          wire.TxWitness{make([]byte, 72), make([]byte, 33)},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	segwitTx.AddTxOut(wire.NewTxOut(49000, p2wpkhScript))
+
+	// The coinbase has an unspendable output which is not added to the
+	// utxo set.
+	coinbaseTx := wire.NewMsgTx(1)
+	coinbaseTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x01, 0x64, 0x00},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbaseTx.AddTxOut(wire.NewTxOut(50*btcutil.SatoshiPerBitcoin+11000,
+		p2pkhScript))
+	coinbaseTx.AddTxOut(wire.NewTxOut(0, nullDataScript))
+
+	blockTime := time.Unix(1600000000, 0)
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{Timestamp: blockTime},
+		Transactions: []*wire.MsgTx{
+			coinbaseTx, legacyTx, segwitTx,
+		},
+	})
+	block.SetHeight(100)
+	stxos := []blockchain.SpentTxOut{
+		{Amount: 100000, PkScript: p2pkhScript, Height: 90},
+		{Amount: 50000, PkScript: p2wpkhScript, Height: 95},
+	}
+	medianTime := blockTime.Add(-time.Hour)
+
+	stats, err := calcBlockStats(block, stxos, medianTime, params)
+	if err != nil {
+		t.Fatalf("calcBlockStats: unexpected error: %v", err)
+	}
+
+	txns := block.Transactions()
+	legacySize := int64(legacyTx.SerializeSize())
+	segwitSize := int64(segwitTx.SerializeSize())
+	legacyWeight := blockchain.GetTransactionWeight(txns[1])
+	segwitWeight := blockchain.GetTransactionWeight(txns[2])
+	legacyFeeRate := 10000 * blockchain.WitnessScaleFactor / legacyWeight
+	segwitFeeRate := 1000 * blockchain.WitnessScaleFactor / segwitWeight
+	if segwitWeight >= legacyWeight || segwitFeeRate >= legacyFeeRate {
+		t.Fatalf("unexpected weights %d/%d and fee rates %d/%d",
+			legacyWeight, segwitWeight, legacyFeeRate,
+			segwitFeeRate)
+	}
+
+	checks := []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{"height", stats.Height, 100},
+		{"time", stats.Time, blockTime.Unix()},
+		{"mediantime", stats.MedianTime, medianTime.Unix()},
+		{"subsidy", stats.Subsidy, 50 * btcutil.SatoshiPerBitcoin},
+		{"txs", stats.Txs, 3},
+		{"ins", stats.Ins, 2},
+		{"outs", stats.Outs, 5},
+		{"utxo_increase", stats.UTXOIncrease, 3},
+		{"utxo_increase_actual", stats.UTXOIncreaseActual, 2},
+		{"total_out", stats.TotalOut, 139000},
+
+		// Fees are aggregated over the non-coinbase transactions.
+		{"totalfee", stats.TotalFee, 11000},
+		{"minfee", stats.MinFee, 1000},
+		{"maxfee", stats.MaxFee, 10000},
+		{"avgfee", stats.AverageFee, 5500},
+		{"medianfee", stats.MedianFee, 5500},
+		{"minfeerate", stats.MinFeeRate, segwitFeeRate},
+		{"maxfeerate", stats.MaxFeeRate, legacyFeeRate},
+		{"avgfeerate", stats.AverageFeeRate, 11000 *
+			blockchain.WitnessScaleFactor /
+			(legacyWeight + segwitWeight)},
+
+		// Only the segwit transaction counts towards the segwit
+		// statistics.
+		{"swtxs", stats.SegWitTxs, 1},
+		{"swtotal_size", stats.SegWitTotalSize, segwitSize},
+		{"swtotal_weight", stats.SegWitTotalWeight, segwitWeight},
+
+		{"total_size", stats.TotalSize, legacySize + segwitSize},
+		{"total_weight", stats.TotalWeight, legacyWeight + segwitWeight},
+		{"mintxsize", stats.MinTxSize, segwitSize},
+		{"maxtxsize", stats.MaxTxSize, legacySize},
+		{"avgtxsize", stats.AverageTxSize, (legacySize + segwitSize) / 2},
+		{"mediantxsize", stats.MedianTxSize, (legacySize + segwitSize) / 2},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s: got %d, want %d", check.name, check.got,
+				check.want)
+		}
+	}
+
+	// The lowest percentile falls on the segwit transaction with the lower
+	// fee rate and the highest on the legacy transaction.
+	if stats.FeeratePercentiles[0] != segwitFeeRate ||
+		stats.FeeratePercentiles[4] != legacyFeeRate {
+
+		t.Errorf("got fee rate percentiles %v, want %d to %d",
+			stats.FeeratePercentiles, segwitFeeRate, legacyFeeRate)
+	}
+
+	// The spent outputs are removed from the utxo set, which shrinks by
+	// their size, while the unspendable output is only counted by the
+	// statistic which doesn't exclude it.
+	var outSizes, spentSizes int64
+	for _, tx := range txns {
+		for _, txOut := range tx.MsgTx().TxOut {
+			outSizes += int64(txOut.SerializeSize()) + perUTXOOverhead
+		}
+	}
+	for _, stxo := range stxos {
+		txOut := wire.TxOut{Value: stxo.Amount, PkScript: stxo.PkScript}
+		spentSizes += int64(txOut.SerializeSize()) + perUTXOOverhead
+	}
+	nullDataSize := int64(coinbaseTx.TxOut[1].SerializeSize()) +
+		perUTXOOverhead
+	if stats.UTXOSizeIncrease != outSizes-spentSizes {
+		t.Errorf("got utxo_size_inc %d, want %d",
+			stats.UTXOSizeIncrease, outSizes-spentSizes)
+	}
+	if stats.UTXOSizeIncreaseActual != outSizes-spentSizes-nullDataSize {
+		t.Errorf("got utxo_size_inc_actual %d, want %d",
+			stats.UTXOSizeIncreaseActual,
+			outSizes-spentSizes-nullDataSize)
+	}
+
+	// A block with only a coinbase has no fee statistics.
+	coinbaseBlock := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbaseTx},
+	})
+	coinbaseBlock.SetHeight(100)
+	stats, err = calcBlockStats(coinbaseBlock, nil, medianTime, params)
+	if err != nil {
+		t.Fatalf("calcBlockStats: unexpected error: %v", err)
+	}
+	if stats.MinFee != 0 || stats.MinFeeRate != 0 || stats.MinTxSize != 0 ||
+		stats.AverageFeeRate != 0 {
+
+		t.Errorf("unexpected fee statistics for coinbase only block: "+
+			"%+v", stats)
+	}
+
+	// A spend journal missing spent outputs is rejected.
+	_, err = calcBlockStats(block, stxos[:1], medianTime, params)
+	if err == nil {
+		t.Fatal("calcBlockStats: expected error for incomplete spend " +
+			"journal")
+	}
+}
+
+// TestParseDescriptorRange ensures descriptor ranges are parsed and that
+// ranges which can't be derived are rejected.
+func TestParseDescriptorRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		value     interface{}
+		wantBegin int
+		wantEnd   int
+		wantErr   bool
+	}{
+		{name: "end only", value: 10, wantBegin: 0, wantEnd: 10},
+		{name: "begin and end", value: []int{5, 10}, wantBegin: 5, wantEnd: 10},
+		{
+			name:      "highest unhardened index",
+			value:     []int{math.MaxInt32, math.MaxInt32},
+			wantBegin: math.MaxInt32,
+			wantEnd:   math.MaxInt32,
+		},
+		{name: "negative", value: []int{-1, 10}, wantErr: true},
+		{name: "begin after end", value: []int{10, 5}, wantErr: true},
+		{name: "too large", value: maxDescriptorRange, wantErr: true},
+		{name: "hardened end", value: math.MaxInt32 + 1, wantErr: true},
+		{
+			name:    "wrapping range",
+			value:   []int{math.MaxUint32 + 1, math.MaxUint32 + 2},
+			wantErr: true,
+		},
+		{name: "invalid type", value: "10", wantErr: true},
+	}
+
+	for _, test := range tests {
+		r := &btcjson.DescriptorRange{Value: test.value}
+		begin, end, err := parseDescriptorRange(r)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			} else if err.Code != btcjson.ErrRPCInvalidParameter {
+				t.Errorf("%s: got error code %d, want %d",
+					test.name, err.Code,
+					btcjson.ErrRPCInvalidParameter)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if begin != test.wantBegin || end != test.wantEnd {
+			t.Errorf("%s: got range [%d,%d], want [%d,%d]", test.name,
+				begin, end, test.wantBegin, test.wantEnd)
+		}
+	}
+}
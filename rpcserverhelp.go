@@ -115,6 +115,26 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DeriveAddressesCmd help.
+	"deriveaddresses--synopsis":  "Derives one or more addresses corresponding to an output descriptor.",
+	"deriveaddresses-descriptor": "The descriptor, including its checksum",
+	"deriveaddresses-range":      "If the descriptor is ranged, the end or [begin,end] of the range of indices to derive",
+	"descriptorrange-value":      "The end or [begin,end] of the range",
+	"deriveaddresses--result0":   "The derived addresses",
+
+	// DumpTxOutSetCmd help.
+	"dumptxoutset--synopsis": "Writes a snapshot of the unspent transaction output set as of the current best block to a file.\n" +
+		"The snapshot can be used to bootstrap a new node with the --loadsnapshot option when its hash is listed in the chain parameters.",
+	"dumptxoutset-path": "The path of the file to write the snapshot to, which must not exist yet -- relative paths are relative to the data directory",
+
+	// DumpTxOutSetResult help.
+	"dumptxoutsetresult-coins_written": "The number of unspent transaction outputs written to the snapshot",
+	"dumptxoutsetresult-base_hash":     "The hash of the block the snapshot is based on",
+	"dumptxoutsetresult-base_height":   "The height of the block the snapshot is based on",
+	"dumptxoutsetresult-path":          "The absolute path of the snapshot file",
+	"dumptxoutsetresult-txoutset_hash": "The hash_serialized_2 hash of the unspent transaction output set in the snapshot",
+	"dumptxoutsetresult-nchaintx":      "The number of transactions in the chain up to and including the block the snapshot is based on",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
@@ -124,6 +144,25 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee rate in bitcoins per " +
+		"kilo virtual byte required for a transaction to be mined within " +
+		"a number of blocks.",
+	"estimatesmartfee-conftarget": "The confirmation target in blocks",
+	"estimatesmartfee-estimatemode": "The fee estimate mode, either " +
+		"ECONOMICAL or CONSERVATIVE. Conservative estimates consider a " +
+		"longer history and are less affected by short-term drops of the " +
+		"fee rates",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "The estimated fee rate in BTC/kvB " +
+		"(only present if no errors occurred)",
+	"estimatesmartfeeresult-errors": "Errors encountered during processing " +
+		"(only present if the fee rate could not be estimated)",
+	"estimatesmartfeeresult-blocks": "The number of blocks the estimate is " +
+		"valid for, which may be lower than the requested target when " +
+		"not enough data is available",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
@@ -178,7 +217,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-difficulty":           "The current chain difficulty",
 	"getblockchaininforesult-mediantime":           "The median time from the PoV of the best block in the chain",
 	"getblockchaininforesult-verificationprogress": "An estimate for how much of the best chain we've verified",
-	"getblockchaininforesult-pruned":               "A bool that indicates if the node is pruned or not",
+	"getblockchaininforesult-pruned":               "Whether or not block data has been deleted from the database by pruning",
 	"getblockchaininforesult-pruneheight":          "The lowest block retained in the current pruned chain",
 	"getblockchaininforesult-chainwork":            "The total cumulative work in the best chain",
 	"getblockchaininforesult-size_on_disk":         "The estimated size of the block and undo files on disk",
@@ -287,6 +326,47 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis":    "Returns statistics about the fees, sizes and outputs of a block in the main chain.\nAll amounts are in satoshis and fee rates in satoshis per virtual byte.",
+	"getblockstats-hashorheight": "The hash or height of the block",
+	"getblockstats-stats":        "Only return the statistics with these names",
+
+	// HashOrHeight help.
+	"hashorheight-value": "The block hash as a string or the block height as a number",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":               "Average fee of the transactions in the block",
+	"getblockstatsresult-avgfeerate":           "Average fee rate of the transactions in the block",
+	"getblockstatsresult-avgtxsize":            "Average serialized size of the transactions in the block",
+	"getblockstatsresult-feerate_percentiles":  "The 10th, 25th, 50th, 75th and 90th percentiles of the fee rates weighted by transaction weight",
+	"getblockstatsresult-blockhash":            "The hash of the block",
+	"getblockstatsresult-height":               "The height of the block",
+	"getblockstatsresult-ins":                  "The number of inputs, excluding the coinbase",
+	"getblockstatsresult-maxfee":               "Maximum fee of the transactions in the block",
+	"getblockstatsresult-maxfeerate":           "Maximum fee rate of the transactions in the block",
+	"getblockstatsresult-maxtxsize":            "Maximum serialized size of the transactions in the block",
+	"getblockstatsresult-medianfee":            "Median fee of the transactions in the block",
+	"getblockstatsresult-mediantime":           "The median time of the past 11 blocks",
+	"getblockstatsresult-mediantxsize":         "Median serialized size of the transactions in the block",
+	"getblockstatsresult-minfee":               "Minimum fee of the transactions in the block",
+	"getblockstatsresult-minfeerate":           "Minimum fee rate of the transactions in the block",
+	"getblockstatsresult-mintxsize":            "Minimum serialized size of the transactions in the block",
+	"getblockstatsresult-outs":                 "The number of outputs, including the coinbase",
+	"getblockstatsresult-swtotal_size":         "Total serialized size of the segwit transactions",
+	"getblockstatsresult-swtotal_weight":       "Total weight of the segwit transactions",
+	"getblockstatsresult-swtxs":                "The number of segwit transactions",
+	"getblockstatsresult-subsidy":              "The block subsidy",
+	"getblockstatsresult-time":                 "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-total_out":            "Total amount of the outputs, excluding the coinbase",
+	"getblockstatsresult-total_size":           "Total serialized size of the transactions, excluding the coinbase",
+	"getblockstatsresult-total_weight":         "Total weight of the transactions, excluding the coinbase",
+	"getblockstatsresult-totalfee":             "Total fees of the transactions in the block",
+	"getblockstatsresult-txs":                  "The number of transactions, including the coinbase",
+	"getblockstatsresult-utxo_increase":        "The increase or decrease in the number of unspent outputs",
+	"getblockstatsresult-utxo_size_inc":        "The increase or decrease in the size of the utxo set",
+	"getblockstatsresult-utxo_increase_actual": "The increase or decrease in the number of unspent outputs, excluding unspendable outputs",
+	"getblockstatsresult-utxo_size_inc_actual": "The increase or decrease in the size of the utxo set, excluding unspendable outputs",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
 	"templaterequest-capabilities": "List of capabilities",
@@ -359,6 +439,37 @@ var helpDescsEnUS = map[string]string{
 	"getcfilterheader-hash":       "The hash of the block",
 	"getcfilterheader--result0":   "The block's gcs filter header",
 
+	// GetChainStatesCmd help.
+	"getchainstates--synopsis": "Returns information about the chainstates.\n" +
+		"There are two chainstates while the unspent transaction output snapshot the chain was bootstrapped from is validated in the background: " +
+		"the one validating the chain up to the snapshot block followed by the one of the best chain.",
+
+	// GetChainStatesResult help.
+	"getchainstatesresult-headers":     "The height of the best chain",
+	"getchainstatesresult-chainstates": "The chainstates ordered from the one validating the chain in the background to the one of the best chain",
+
+	// ChainStateResult help.
+	"chainstateresult-blocks":               "The height of the tip of the chainstate",
+	"chainstateresult-bestblockhash":        "The hash of the tip of the chainstate",
+	"chainstateresult-difficulty":           "The difficulty of the tip of the chainstate",
+	"chainstateresult-verificationprogress": "An estimate of the progress of the chainstate between 0 and 1",
+	"chainstateresult-snapshot_blockhash":   "The hash of the block the snapshot the chainstate was created from is based on (only present while the snapshot is not validated)",
+	"chainstateresult-validated":            "Whether or not the chain up to the tip of the chainstate has been fully validated",
+
+	// GetChainTipsCmd help.
+	"getchaintips--synopsis": "Returns information about all known tips in the block tree, including the main chain and orphaned branches.",
+
+	// GetChainTipsResult help.
+	"getchaintipsresult-height":    "The height of the chain tip",
+	"getchaintipsresult-hash":      "The block hash of the chain tip",
+	"getchaintipsresult-branchlen": "The length of the branch connecting the tip to the main chain (zero for the main chain)",
+	"getchaintipsresult-status": "The status of the chain tip: " +
+		"active (the tip of the main chain), " +
+		"valid-fork (a fully validated branch that is not part of the main chain), " +
+		"valid-headers (all blocks are available, but the branch was never fully validated), " +
+		"headers-only (not all blocks of the branch are available), " +
+		"or invalid (the branch contains at least one invalid block)",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -367,6 +478,17 @@ var helpDescsEnUS = map[string]string{
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifer",
 
+	// GetDescriptorInfoCmd help.
+	"getdescriptorinfo--synopsis":  "Analyses an output descriptor.",
+	"getdescriptorinfo-descriptor": "The descriptor",
+
+	// GetDescriptorInfoResult help.
+	"getdescriptorinforesult-descriptor":     "The descriptor in canonical form, without private keys",
+	"getdescriptorinforesult-checksum":       "The checksum for the input descriptor",
+	"getdescriptorinforesult-isrange":        "Whether the descriptor is ranged",
+	"getdescriptorinforesult-issolvable":     "Whether the descriptor is solvable",
+	"getdescriptorinforesult-hasprivatekeys": "Whether the input descriptor contained at least one private key",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
@@ -418,12 +540,63 @@ var helpDescsEnUS = map[string]string{
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetMempoolAncestorsCmd help.
+	"getmempoolancestors--synopsis":   "Returns all of the unconfirmed ancestors in the memory pool of a transaction in the memory pool.",
+	"getmempoolancestors-txid":        "The hash of the transaction",
+	"getmempoolancestors-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempoolancestors--condition0": "verbose=false",
+	"getmempoolancestors--condition1": "verbose=true",
+	"getmempoolancestors--result0":    "Array of transaction hashes",
+
+	// GetMempoolDescendantsCmd help.
+	"getmempooldescendants--synopsis":   "Returns all of the descendants in the memory pool of a transaction in the memory pool.",
+	"getmempooldescendants-txid":        "The hash of the transaction",
+	"getmempooldescendants-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempooldescendants--condition0": "verbose=false",
+	"getmempooldescendants--condition1": "verbose=true",
+	"getmempooldescendants--result0":    "Array of transaction hashes",
+
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns information about a transaction in the memory pool.",
+	"getmempoolentry-txid":      "The hash of the transaction",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-vsize":              "The virtual size of the transaction",
+	"getmempoolentryresult-size":               "Transaction size in bytes",
+	"getmempoolentryresult-weight":             "The transaction's weight (between vsize*4-3 and vsize*4)",
+	"getmempoolentryresult-fee":                "Transaction fee in bitcoins",
+	"getmempoolentryresult-modifiedfee":        "Transaction fee adjusted by its fee delta from prioritisetransaction in bitcoins",
+	"getmempoolentryresult-time":               "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":             "Block height when transaction entered the pool",
+	"getmempoolentryresult-descendantcount":    "Number of in-mempool descendant transactions (including this one)",
+	"getmempoolentryresult-descendantsize":     "Virtual size of in-mempool descendants (including this one)",
+	"getmempoolentryresult-descendantfees":     "Modified fees of in-mempool descendants (including this one) in bitcoins",
+	"getmempoolentryresult-ancestorcount":      "Number of in-mempool ancestor transactions (including this one)",
+	"getmempoolentryresult-ancestorsize":       "Virtual size of in-mempool ancestors (including this one)",
+	"getmempoolentryresult-ancestorfees":       "Modified fees of in-mempool ancestors (including this one) in bitcoins",
+	"getmempoolentryresult-wtxid":              "The witness hash of the transaction",
+	"getmempoolentryresult-fees":               "The fees of the transaction and its packages in bitcoins",
+	"getmempoolentryresult-depends":            "Unconfirmed transactions used as inputs for this transaction",
+	"getmempoolentryresult-spentby":            "Unconfirmed transactions spending outputs of this transaction",
+	"getmempoolentryresult-bip125-replaceable": "Whether or not the transaction can be replaced since it or one of its unconfirmed ancestors signals replacement",
+
+	// MempoolFees help.
+	"mempoolfees-base":       "Transaction fee in bitcoins",
+	"mempoolfees-modified":   "Transaction fee adjusted by its fee delta in bitcoins",
+	"mempoolfees-ancestor":   "Modified fees of in-mempool ancestors (including this one) in bitcoins",
+	"mempoolfees-descendant": "Modified fees of in-mempool descendants (including this one) in bitcoins",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-loaded":        "Whether or not the attempt to load the mempool saved on the last shutdown has finished",
+	"getmempoolinforesult-bytes":         "Size in bytes of the mempool",
+	"getmempoolinforesult-size":          "Number of transactions in the mempool",
+	"getmempoolinforesult-usage":         "Total serialized size in bytes of the transactions in the mempool which is limited by maxmempool",
+	"getmempoolinforesult-maxmempool":    "Maximum total serialized size in bytes of the transactions in the mempool",
+	"getmempoolinforesult-mempoolminfee": "Minimum fee rate in BTC/kB for transactions to be accepted, which is raised when transactions are evicted from a full mempool",
+	"getmempoolinforesult-minrelaytxfee": "Minimum relay fee rate in BTC/kB for transactions",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -496,10 +669,17 @@ var helpDescsEnUS = map[string]string{
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
+	"getrawmempoolverboseresult-modifiedfee":      "Transaction fee adjusted by its fee delta from prioritisetransaction in bitcoins",
 	"getrawmempoolverboseresult-time":             "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
 	"getrawmempoolverboseresult-height":           "Block height when transaction entered the pool",
 	"getrawmempoolverboseresult-startingpriority": "Priority when transaction entered the pool",
 	"getrawmempoolverboseresult-currentpriority":  "Current priority",
+	"getrawmempoolverboseresult-descendantcount":  "Number of in-mempool descendant transactions (including this one)",
+	"getrawmempoolverboseresult-descendantsize":   "Virtual size of in-mempool descendants (including this one)",
+	"getrawmempoolverboseresult-descendantfees":   "Modified fees of in-mempool descendants (including this one) in bitcoins",
+	"getrawmempoolverboseresult-ancestorcount":    "Number of in-mempool ancestor transactions (including this one)",
+	"getrawmempoolverboseresult-ancestorsize":     "Virtual size of in-mempool ancestors (including this one)",
+	"getrawmempoolverboseresult-ancestorfees":     "Modified fees of in-mempool ancestors (including this one) in bitcoins",
 	"getrawmempoolverboseresult-depends":          "Unconfirmed transactions used as inputs for this transaction",
 	"getrawmempoolverboseresult-vsize":            "The virtual size of a transaction",
 	"getrawmempoolverboseresult-weight":           "The transaction's weight (between vsize*4-3 and vsize*4)",
@@ -533,6 +713,50 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutProofCmd help.
+	"gettxoutproof--synopsis": "Returns a hex-encoded proof that the given transactions were included in a block.\n" +
+		"Unless the block hash is specified, the transaction index must be enabled (--txindex) to locate the block.",
+	"gettxoutproof-txids":     "The hashes of the transactions to prove",
+	"gettxoutproof-blockhash": "The hash of the block to look for the transactions in",
+	"gettxoutproof--result0":  "The serialized merkle block proving the transactions as a hex-encoded string",
+
+	// GetTxOutSetInfoCmd help.
+	"gettxoutsetinfo--synopsis": "Returns statistics about the unspent transaction output set along with a hash committing to it.\n" +
+		"The statistics are computed by scanning the unspent transaction output set, which might take a while, unless the muhash hash type is requested and btcd is run with --utxosetstats.",
+	"gettxoutsetinfo-hashtype": "The hash to compute (hash_serialized_2, muhash or none)",
+
+	// GetTxOutSetInfoResult help.
+	"gettxoutsetinforesult-height":            "The height of the block the statistics are up to date with",
+	"gettxoutsetinforesult-bestblock":         "The hash of the block the statistics are up to date with",
+	"gettxoutsetinforesult-transactions":      "The number of transactions with unspent outputs (omitted when the unspent transaction output set was not scanned)",
+	"gettxoutsetinforesult-txouts":            "The number of unspent transaction outputs",
+	"gettxoutsetinforesult-bogosize":          "A database-independent measure of the size of the unspent transaction output set",
+	"gettxoutsetinforesult-hash_serialized_2": "The double SHA256 hash of the serialized unspent transaction output set (only for the hash_serialized_2 hash type)",
+	"gettxoutsetinforesult-muhash":            "The MuHash3072 of the unspent transaction output set (only for the muhash hash type)",
+	"gettxoutsetinforesult-disk_size":         "The size of the unspent transaction output set in the database (omitted when the unspent transaction output set was not scanned)",
+	"gettxoutsetinforesult-total_amount":      "The total amount of all unspent transaction outputs in BTC",
+
+	// GetZmqNotificationsCmd help.
+	"getzmqnotifications--synopsis": "Returns information about the active ZeroMQ notifications.",
+
+	// ZmqNotificationResult help.
+	"zmqnotificationresult-type":    "Type of the notification",
+	"zmqnotificationresult-address": "Address of the publisher",
+	"zmqnotificationresult-hwm":     "Outbound message high water mark",
+
+	// GetUtxoCacheInfoCmd help.
+	"getutxocacheinfo--synopsis": "Returns statistics about the in-memory UTXO cache.",
+
+	// GetUtxoCacheInfoResult help.
+	"getutxocacheinforesult-entries":       "The number of entries in the cache, including spent outputs which are yet to be removed from the database",
+	"getutxocacheinforesult-size":          "The approximate memory used by the cache in bytes",
+	"getutxocacheinforesult-maxsize":       "The size in bytes at which the cache is flushed to the database",
+	"getutxocacheinforesult-hits":          "The number of lookups satisfied by the cache",
+	"getutxocacheinforesult-misses":        "The number of lookups that required a database read",
+	"getutxocacheinforesult-flushes":       "The number of times the cache has been flushed to the database",
+	"getutxocacheinforesult-lastflushhash": "The hash of the best block as of the most recent flush",
+	"getutxocacheinforesult-lastflushtime": "The time of the most recent flush in seconds since 1 Jan 1970 GMT",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -541,10 +765,73 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Permanently marks a block and all of its descendants as invalid.\n" +
+		"The chain is reorganized to the valid block with the most work when the block is part of the main chain.",
+	"invalidateblock-blockhash": "The hash of the block to invalidate",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// PreciousBlockCmd help.
+	"preciousblock--synopsis": "Treats a block as if it was received before other blocks with the same work.\n" +
+		"The chain is reorganized to the block when it has the same amount of work as the current best block.",
+	"preciousblock-blockhash": "The hash of the block to mark as precious",
+
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Adjusts the fee of a transaction, which need not be in the mempool yet, by a fee delta.\n" +
+		"The modified fee is used when selecting transactions for block templates, validating replacements and evicting transactions from a full mempool, while the fee actually paid is unchanged.\n" +
+		"Fee deltas are cumulative and persisted along with the mempool.",
+	"prioritisetransaction-txid":     "The hash of the transaction",
+	"prioritisetransaction-dummy":    "Unused, must be 0",
+	"prioritisetransaction-feedelta": "The fee delta in satoshi to add to the current fee delta of the transaction (can be negative)",
+	"prioritisetransaction--result0": "Always true",
+
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes the invalid status from a block and its ancestors and descendants.\n" +
+		"This undoes the effect of invalidateblock and reorganizes the chain to the valid block with the most work.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
+	// SaveMempoolCmd help.
+	"savemempool--synopsis": "Saves the mempool to mempool.dat in the data directory, which is loaded on startup.",
+
+	// SaveMempoolResult help.
+	"savemempoolresult-filename": "The path of the file the mempool was saved to",
+
+	// ScanTxOutSetCmd help.
+	"scantxoutset--synopsis": "Scans the unspent transaction output set for outputs matching a set of output descriptors.\n" +
+		"Only one scan can run at a time.  The status action reports the progress of the scan in progress and the abort action stops it.",
+	"scantxoutset-action":      "The action to execute (start, abort or status)",
+	"scantxoutset-scanobjects": "The output descriptors to scan for, required for the start action",
+	"scantxoutset--condition0": "action=start",
+	"scantxoutset--condition1": "action=status",
+	"scantxoutset--condition2": "action=abort",
+	"scantxoutset--result2":    "Whether the scan in progress was aborted",
+
+	// ScanObject help.
+	"scanobject-desc":  "The output descriptor",
+	"scanobject-range": "The end or [begin,end] of the range of indices to scan for ranged descriptors (default: 1000)",
+
+	// ScanTxOutSetResult help.
+	"scantxoutsetresult-success":      "Whether the scan completed without being aborted",
+	"scantxoutsetresult-txouts":       "The number of unspent transaction outputs scanned",
+	"scantxoutsetresult-height":       "The height of the best block at the time of the scan",
+	"scantxoutsetresult-bestblock":    "The hash of the best block at the time of the scan",
+	"scantxoutsetresult-unspents":     "The unspent transaction outputs matching the descriptors",
+	"scantxoutsetresult-total_amount": "The total amount of all matching unspent transaction outputs in BTC",
+
+	// ScanTxOutSetUnspent help.
+	"scantxoutsetunspent-txid":         "The hash of the transaction",
+	"scantxoutsetunspent-vout":         "The index of the output",
+	"scantxoutsetunspent-scriptPubKey": "The hex-encoded public key script of the output",
+	"scantxoutsetunspent-desc":         "A descriptor for the public key script of the output",
+	"scantxoutsetunspent-amount":       "The value of the output in BTC",
+	"scantxoutsetunspent-height":       "The height of the block containing the transaction",
+
+	// ScanTxOutSetStatusResult help.
+	"scantxoutsetstatusresult-progress": "The approximate progress of the scan in progress as a percentage",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -595,6 +882,24 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Tests whether raw transactions would be accepted to the mempool without adding them or relaying them.\n" +
+		"The transactions form a package which must be sorted such that each transaction follows all of its parents in the package.\n" +
+		"Each transaction is tested as if the transactions preceding it which would be accepted were already in the mempool.",
+	"testmempoolaccept-rawtxns":    "The serialized, hex-encoded transactions to test",
+	"testmempoolaccept-maxfeerate": "Reject transactions paying a higher fee rate in BTC/kvB (0 disables the check)",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-wtxid":         "The witness hash of the transaction",
+	"testmempoolacceptresult-allowed":       "Whether or not the transaction would be accepted to the mempool",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction (only when allowed)",
+	"testmempoolacceptresult-fees":          "The fees paid by the transaction (only when allowed)",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected (only when not allowed)",
+
+	// TestMempoolAcceptFees help.
+	"testmempoolacceptfees-base": "The fee paid by the transaction in BTC",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid":         "Whether or not the address is valid",
 	"validateaddresschainresult-address":         "The bitcoin address (only when isvalid is true)",
@@ -624,6 +929,12 @@ var helpDescsEnUS = map[string]string{
 	"verifymessage-message":   "The signed message",
 	"verifymessage--result0":  "Whether or not the signature verified",
 
+	// VerifyTxOutProofCmd help.
+	"verifytxoutproof--synopsis": "Verifies that a proof points to transactions in a block in the main chain.\n" +
+		"An empty list is returned when the proof is invalid.",
+	"verifytxoutproof-proof":    "The hex-encoded proof generated by gettxoutproof",
+	"verifytxoutproof--result0": "The hashes of the transactions the proof commits to",
+
 	// -------- Websocket-specific help --------
 
 	// Session help.
@@ -717,7 +1028,10 @@ var rpcResultTypes = map[string][]interface{}{
 	"debuglevel":             {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"deriveaddresses":        {(*btcjson.DeriveAddressesResult)(nil)},
+	"dumptxoutset":           {(*btcjson.DumpTxOutSetResult)(nil)},
 	"estimatefee":            {(*float64)(nil)},
+	"estimatesmartfee":       {(*btcjson.EstimateSmartFeeResult)(nil)},
 	"generate":               {(*[]string)(nil)},
 	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
 	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
@@ -726,17 +1040,24 @@ var rpcResultTypes = map[string][]interface{}{
 	"getblockcount":          {(*int64)(nil)},
 	"getblockhash":           {(*string)(nil)},
 	"getblockheader":         {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockstats":          {(*btcjson.GetBlockStatsResult)(nil)},
 	"getblocktemplate":       {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getblockchaininfo":      {(*btcjson.GetBlockChainInfoResult)(nil)},
 	"getcfilter":             {(*string)(nil)},
 	"getcfilterheader":       {(*string)(nil)},
+	"getchainstates":         {(*btcjson.GetChainStatesResult)(nil)},
+	"getchaintips":           {(*[]btcjson.GetChainTipsResult)(nil)},
 	"getconnectioncount":     {(*int32)(nil)},
 	"getcurrentnet":          {(*uint32)(nil)},
+	"getdescriptorinfo":      {(*btcjson.GetDescriptorInfoResult)(nil)},
 	"getdifficulty":          {(*float64)(nil)},
 	"getgenerate":            {(*bool)(nil)},
 	"gethashespersec":        {(*float64)(nil)},
 	"getheaders":             {(*[]string)(nil)},
 	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolancestors":    {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempooldescendants":  {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolentry":        {(*btcjson.GetMempoolEntryResult)(nil)},
 	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
@@ -746,19 +1067,31 @@ var rpcResultTypes = map[string][]interface{}{
 	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
 	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutproof":          {(*string)(nil)},
+	"gettxoutsetinfo":        {(*btcjson.GetTxOutSetInfoResult)(nil)},
+	"getutxocacheinfo":       {(*btcjson.GetUtxoCacheInfoResult)(nil)},
+	"getzmqnotifications":    {(*[]btcjson.ZmqNotificationResult)(nil)},
 	"node":                   nil,
 	"help":                   {(*string)(nil), (*string)(nil)},
+	"invalidateblock":        nil,
 	"ping":                   nil,
+	"preciousblock":          nil,
+	"prioritisetransaction":  {(*bool)(nil)},
+	"reconsiderblock":        nil,
+	"savemempool":            {(*btcjson.SaveMempoolResult)(nil)},
+	"scantxoutset":           {(*btcjson.ScanTxOutSetResult)(nil), (*btcjson.ScanTxOutSetStatusResult)(nil), (*bool)(nil)},
 	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":     {(*string)(nil)},
 	"setgenerate":            nil,
 	"signmessagewithprivkey": {(*string)(nil)},
 	"stop":                   {(*string)(nil)},
 	"submitblock":            {nil, (*string)(nil)},
+	"testmempoolaccept":      {(*[]btcjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                 {(*int64)(nil)},
 	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":            {(*bool)(nil)},
 	"verifymessage":          {(*bool)(nil)},
+	"verifytxoutproof":       {(*[]string)(nil)},
 	"version":                {(*map[string]btcjson.VersionResult)(nil)},
 
 	// Websocket commands.
@@ -6,14 +6,18 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -38,6 +42,7 @@ import (
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmq"
 	"github.com/decred/dcrd/lru"
 )
 
@@ -58,6 +63,22 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// maxCmpctBlockDepth is the maximum depth from the tip of the main
+	// chain of blocks that are served as compact blocks and for which
+	// getblocktxn requests are answered.  Older blocks are unlikely to be
+	// reconstructed from the mempool of the peer, so the full block is
+	// served instead.
+	maxCmpctBlockDepth = 10
+
+	// mempoolExpiryInterval is the interval at which transactions that
+	// have been in the mempool for longer than the mempool expiry time
+	// are removed.
+	mempoolExpiryInterval = time.Minute * 10
+
+	// mempoolFileName is the name of the file in the data directory the
+	// mempool is persisted to.
+	mempoolFileName = "mempool.dat"
 )
 
 var (
@@ -241,13 +262,22 @@ type server struct {
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
-	feeEstimator *mempool.FeeEstimator
+	feeEstimator *mempool.SmartFeeEstimator
+
+	// zmqNotifier publishes block and transaction events to ZMQ
+	// subscribers.  It is nil when no ZMQ notifications are enabled.
+	zmqNotifier *zmq.Notifier
 
 	// cfCheckptCaches stores a cached slice of filter headers for cfcheckpt
 	// messages for each filter type.
 	cfCheckptCaches    map[wire.FilterType][]cfHeaderKV
 	cfCheckptCachesMtx sync.RWMutex
 
+	// cfCheckptMsgCache stores the most recent cfcheckpt message served
+	// for each filter type.
+	cfCheckptMsgCache    map[wire.FilterType]*wire.MsgCFCheckpt
+	cfCheckptMsgCacheMtx sync.Mutex
+
 	// agentBlacklist is a list of blacklisted substrings by which to filter
 	// user agents.
 	agentBlacklist []string
@@ -636,6 +666,67 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	<-sp.blockProcessed
 }
 
+// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.
+// It blocks until the compact block has been processed by the sync manager,
+// which either reconstructs the block from the mempool or requests the
+// missing transactions.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Add the block to the known inventory for the peer.
+	blockHash := msg.Header.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	sp.AddKnownInventory(iv)
+
+	// Intentionally block further receives until the compact block is
+	// processed for the same reasons as full blocks.
+	sp.server.syncManager.QueueCmpctBlock(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin message.  It
+// blocks until the block the transactions complete has been processed.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	sp.server.syncManager.QueueBlockTxn(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin message.
+// It responds with the requested transactions of the block in a blocktxn
+// message.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	// Ignore getblocktxn requests if not in sync.
+	if !sp.server.syncManager.IsCurrent() {
+		return
+	}
+
+	block, err := sp.server.chain.BlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch block %v requested by %v: %v",
+			msg.BlockHash, sp, err)
+		return
+	}
+
+	// Only serve transactions for recent blocks in the same way compact
+	// blocks are only served for recent blocks.  Peers must request the
+	// full block otherwise.
+	best := sp.server.chain.BestSnapshot()
+	if best.Height-block.Height() >= maxCmpctBlockDepth {
+		peerLog.Debugf("Ignoring getblocktxn for block %v from %v "+
+			"that is too deep", msg.BlockHash, sp)
+		return
+	}
+
+	txns := block.MsgBlock().Transactions
+	reply := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, idx := range msg.Indexes {
+		if int(idx) >= len(txns) {
+			sp.addBanScore(100, 0, "getblocktxn out of range")
+			return
+		}
+		reply.AddTransaction(txns[idx])
+	}
+	sp.QueueMessageWithEncoding(reply, nil, wire.WitnessEncoding)
+}
+
 // OnInv is invoked when a peer receives an inv bitcoin message and is
 // used to examine the inventory being advertised by the remote peer and react
 // accordingly.  We pass the message down to blockmanager which will call
@@ -723,6 +814,8 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeFilteredWitnessBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeFilteredBlock:
@@ -829,22 +922,91 @@ func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
 	sp.QueueMessage(&wire.MsgHeaders{Headers: blockHeaders}, nil)
 }
 
-// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin message.
-func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
-	// Ignore getcfilters requests if not in sync.
-	if !sp.server.syncManager.IsCurrent() {
-		return
+// validateCFRequest ensures a getcfilters, getcfheaders or getcfcheckpt
+// request from the peer conforms to BIP0157 and returns the height of the
+// provided stop hash.  The peer is disconnected when it requests filters even
+// though we don't advertise the service, requests a filter type we don't
+// maintain, or provides a stop hash that is not known to us.  False is returned
+// when the request must not be answered.
+func (sp *serverPeer) validateCFRequest(cmd string, filterType wire.FilterType,
+	stopHash *chainhash.Hash) (int32, bool) {
+
+	// Peers must not request filters from nodes that don't advertise the
+	// compact filters service.
+	if sp.server.cfIndex == nil {
+		peerLog.Infof("Peer %v sent %s even though compact filters "+
+			"are disabled -- disconnecting", sp, cmd)
+		sp.Disconnect()
+		return 0, false
 	}
 
 	// We'll also ensure that the remote party is requesting a set of
 	// filters that we actually currently maintain.
-	switch msg.FilterType {
+	switch filterType {
 	case wire.GCSFilterRegular:
 		break
 
 	default:
-		peerLog.Debug("Filter request for unknown filter: %v",
-			msg.FilterType)
+		peerLog.Infof("Peer %v sent %s for unknown filter type %v "+
+			"-- disconnecting", sp, cmd, filterType)
+		sp.Disconnect()
+		return 0, false
+	}
+
+	// The stop hash must be a block in the main chain.  A block we know
+	// about that is not in the main chain is likely the result of a
+	// reorganization the peer hasn't seen yet, so the request is only
+	// ignored in that case.
+	stopHeight, err := sp.server.chain.BlockHeightByHash(stopHash)
+	if err != nil {
+		if _, err := sp.server.chain.HeaderByHash(stopHash); err != nil {
+			peerLog.Infof("Peer %v sent %s with unknown stop hash %v "+
+				"-- disconnecting", sp, cmd, stopHash)
+			sp.Disconnect()
+			return 0, false
+		}
+
+		peerLog.Debugf("Ignoring %s from %v with stop hash %v that is "+
+			"not in the main chain", cmd, sp, stopHash)
+		return 0, false
+	}
+
+	return stopHeight, true
+}
+
+// validateCFRange ensures the range of blocks requested by a getcfilters or
+// getcfheaders message is valid and doesn't exceed the provided maximum number
+// of blocks.  The peer is disconnected otherwise.
+func (sp *serverPeer) validateCFRange(cmd string, startHeight uint32,
+	stopHeight int32, maxResults int) bool {
+
+	if startHeight > uint32(stopHeight) ||
+		uint32(stopHeight)-startHeight >= uint32(maxResults) {
+
+		peerLog.Infof("Peer %v sent %s with invalid range [%d, %d] "+
+			"-- disconnecting", sp, cmd, startHeight, stopHeight)
+		sp.Disconnect()
+		return false
+	}
+
+	return true
+}
+
+// OnGetCFilters is invoked when a peer receives a getcfilters bitcoin message.
+func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
+	// Ignore getcfilters requests if not in sync.
+	if !sp.server.syncManager.IsCurrent() {
+		return
+	}
+
+	stopHeight, ok := sp.validateCFRequest(wire.CmdGetCFilters,
+		msg.FilterType, &msg.StopHash)
+	if !ok {
+		return
+	}
+	if !sp.validateCFRange(wire.CmdGetCFilters, msg.StartHeight,
+		stopHeight, wire.MaxGetCFiltersReqRange) {
+
 		return
 	}
 
@@ -871,17 +1033,33 @@ func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
 		return
 	}
 
+	// Make sure all of the filters are available before sending any of
+	// them since the peer expects a response for each of the blocks.
 	for i, filterBytes := range filters {
 		if len(filterBytes) == 0 {
 			peerLog.Warnf("Could not obtain cfilter for %v",
 				hashes[i])
 			return
 		}
+	}
+
+	// Wait for the final filter to be sent before processing any further
+	// messages from the peer.  This prevents a peer from queuing up far
+	// more filters than we can send in a reasonable time, wasting memory.
+	doneChan := make(chan struct{}, 1)
+	for i, filterBytes := range filters {
+		var dc chan<- struct{}
+		if i == len(filters)-1 {
+			dc = doneChan
+		}
 
 		filterMsg := wire.NewMsgCFilter(
 			msg.FilterType, &hashes[i], filterBytes,
 		)
-		sp.QueueMessage(filterMsg, nil)
+		sp.QueueMessage(filterMsg, dc)
+	}
+	if len(filters) > 0 {
+		<-doneChan
 	}
 }
 
@@ -892,15 +1070,14 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 		return
 	}
 
-	// We'll also ensure that the remote party is requesting a set of
-	// headers for filters that we actually currently maintain.
-	switch msg.FilterType {
-	case wire.GCSFilterRegular:
-		break
+	stopHeight, ok := sp.validateCFRequest(wire.CmdGetCFHeaders,
+		msg.FilterType, &msg.StopHash)
+	if !ok {
+		return
+	}
+	if !sp.validateCFRange(wire.CmdGetCFHeaders, msg.StartHeight,
+		stopHeight, wire.MaxCFHeadersPerMsg) {
 
-	default:
-		peerLog.Debug("Filter request for unknown headers for "+
-			"filter: %v", msg.FilterType)
 		return
 	}
 
@@ -920,6 +1097,7 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 	)
 	if err != nil {
 		peerLog.Debugf("Invalid getcfheaders request: %v", err)
+		return
 	}
 
 	// This is possible if StartHeight is one greater that the height of
@@ -1009,15 +1187,22 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 		return
 	}
 
-	// We'll also ensure that the remote party is requesting a set of
-	// checkpoints for filters that we actually currently maintain.
-	switch msg.FilterType {
-	case wire.GCSFilterRegular:
-		break
+	if _, ok := sp.validateCFRequest(wire.CmdGetCFCheckpt,
+		msg.FilterType, &msg.StopHash); !ok {
 
-	default:
-		peerLog.Debug("Filter request for unknown checkpoints for "+
-			"filter: %v", msg.FilterType)
+		return
+	}
+
+	// Light clients typically request checkpoints up to the current tip
+	// repeatedly, so serve the most recent response again when the stop
+	// hash matches.  The checkpoints for a block in the main chain never
+	// change, so the cached message can't become stale.
+	sp.server.cfCheckptMsgCacheMtx.Lock()
+	cachedMsg := sp.server.cfCheckptMsgCache[msg.FilterType]
+	sp.server.cfCheckptMsgCacheMtx.Unlock()
+	if cachedMsg != nil && cachedMsg.StopHash == msg.StopHash {
+		peerLog.Tracef("Serving cached cfcheckpt for %v", msg.StopHash)
+		sp.QueueMessage(cachedMsg, nil)
 		return
 	}
 
@@ -1150,6 +1335,10 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 		sp.server.cfCheckptCaches[msg.FilterType] = checkptCache
 	}
 
+	sp.server.cfCheckptMsgCacheMtx.Lock()
+	sp.server.cfCheckptMsgCache[msg.FilterType] = checkptMsg
+	sp.server.cfCheckptMsgCacheMtx.Unlock()
+
 	sp.QueueMessage(checkptMsg, nil)
 }
 
@@ -1621,6 +1810,60 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
+// pushCmpctBlockMsg sends a cmpctblock message for the provided block hash to
+// the connected peer.  Compact blocks are only sent for recent blocks to peers
+// that negotiated version 2 compact blocks and the full block is sent instead
+// otherwise.  An error is returned if the block hash is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash,
+	doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+
+	blk, err := sp.server.chain.BlockByHash(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	// Compact blocks are only sent for recent blocks to peers that support
+	// version 2.  The full block is sent otherwise, which includes the case
+	// where the compact block can't be created.
+	best := sp.server.chain.BestSnapshot()
+	var msg wire.Message = blk.MsgBlock()
+	if sp.CmpctBlockVersion() == wire.CmpctBlockVersion2 &&
+		best.Height-blk.Height() < maxCmpctBlockDepth {
+
+		cmpctBlock, err := newCmpctBlockMsg(blk)
+		if err != nil {
+			peerLog.Warnf("Unable to create compact block %v: %v",
+				hash, err)
+		} else {
+			msg = cmpctBlock
+		}
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessageWithEncoding(msg, doneChan, wire.WitnessEncoding)
+	return nil
+}
+
+// newCmpctBlockMsg returns a cmpctblock message for the passed block using a
+// random nonce.
+func newCmpctBlockMsg(block *btcutil.Block) (*wire.MsgCmpctBlock, error) {
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewMsgCmpctBlockFromBlock(block.MsgBlock(), nonce), nil
+}
+
 // pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
 // the connected peer.  Since a merkle block requires the peer to have a filter
 // loaded, this call will simply be ignored if there is no filter loaded.  An
@@ -1868,11 +2111,43 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// The compact block for a block inventory is only created once when the
+	// first peer that requested high-bandwidth compact blocks is found.
+	var cmpctBlock *wire.MsgCmpctBlock
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// If the inventory is a block and the peer requested
+		// high-bandwidth compact blocks, send the compact block
+		// directly instead of announcing it.
+		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsCmpctBlocks() {
+			if sp.IsKnownInventory(msg.invVect) {
+				return
+			}
+			if cmpctBlock == nil {
+				block, err := s.chain.BlockByHash(&msg.invVect.Hash)
+				if err != nil {
+					peerLog.Warnf("Unable to fetch block %v "+
+						"for compact block relay: %v",
+						msg.invVect.Hash, err)
+					return
+				}
+				cmpctBlock, err = newCmpctBlockMsg(block)
+				if err != nil {
+					peerLog.Warnf("Unable to create compact "+
+						"block %v: %v", msg.invVect.Hash,
+						err)
+					return
+				}
+			}
+			sp.AddKnownInventory(msg.invVect)
+			sp.QueueMessageWithEncoding(cmpctBlock, nil,
+				wire.WitnessEncoding)
+			return
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
@@ -2130,6 +2405,9 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnInv:          sp.OnInv,
 			OnHeaders:      sp.OnHeaders,
 			OnGetData:      sp.OnGetData,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
 			OnGetBlocks:    sp.OnGetBlocks,
 			OnGetHeaders:   sp.OnGetHeaders,
 			OnGetCFilters:  sp.OnGetCFilters,
@@ -2306,6 +2584,14 @@ out:
 	s.syncManager.Stop()
 	s.addrManager.Stop()
 
+	// Write the utxo cache to the database now that no more blocks will be
+	// processed so the utxo set does not need to be reconstructed on the
+	// next startup.
+	srvrLog.Infof("Flushing the UTXO cache to the database...")
+	if err := s.chain.FlushUtxoCache(blockchain.FlushRequired); err != nil {
+		srvrLog.Errorf("Unable to flush the UTXO cache: %v", err)
+	}
+
 	// Drain channels before exiting so nothing is left waiting around
 	// to send.
 cleanup:
@@ -2454,6 +2740,97 @@ cleanup:
 	s.wg.Done()
 }
 
+// mempoolExpiryHandler periodically removes transactions that have been in the
+// mempool for longer than the mempool expiry time.
+func (s *server) mempoolExpiryHandler() {
+	ticker := time.NewTicker(mempoolExpiryInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			s.txMemPool.ExpireTransactions()
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
+// mempoolFilePath returns the path of the file the mempool is persisted to.
+func mempoolFilePath() string {
+	return filepath.Join(cfg.DataDir, mempoolFileName)
+}
+
+// saveMempool writes the transactions in the mempool to the mempool file in the
+// data directory and returns its path.  The file is replaced atomically so a
+// partially written file never replaces a complete one.
+func saveMempool(txMemPool *mempool.TxPool) (string, error) {
+	path := mempoolFilePath()
+	tmpPath := path + ".new"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(file)
+	err = txMemPool.Save(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return path, nil
+}
+
+// mempoolLoadHandler loads the transactions persisted to the mempool file in
+// the data directory into the mempool and marks the mempool as loaded once
+// done.  It must be run as a goroutine.
+func (s *server) mempoolLoadHandler() {
+	defer s.wg.Done()
+
+	if !cfg.NoPersistMempool {
+		path := mempoolFilePath()
+		file, err := os.Open(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			srvrLog.Errorf("Unable to open mempool file: %v", err)
+		default:
+			err := s.txMemPool.Load(bufio.NewReader(file), s.quit)
+			file.Close()
+			if err != nil {
+				select {
+				case <-s.quit:
+					// The mempool is not marked as loaded
+					// to avoid overwriting the file with
+					// a partially loaded mempool.
+					return
+				default:
+				}
+				srvrLog.Errorf("Unable to load mempool from %s: %v",
+					path, err)
+			}
+		}
+	}
+
+	s.txMemPool.SetLoaded()
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2476,6 +2853,16 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	// Start the handler which removes expired transactions from the
+	// mempool.
+	s.wg.Add(1)
+	go s.mempoolExpiryHandler()
+
+	// Load the transactions persisted on the last shutdown into the
+	// mempool.
+	s.wg.Add(1)
+	go s.mempoolLoadHandler()
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2490,6 +2877,11 @@ func (s *server) Start() {
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start accepting ZMQ subscribers if notifications are enabled.
+	if s.zmqNotifier != nil {
+		s.zmqNotifier.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2511,10 +2903,27 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Disconnect all ZMQ subscribers.
+	if s.zmqNotifier != nil {
+		s.zmqNotifier.Stop()
+	}
+
+	// Save the mempool unless it is yet to be loaded, in which case the
+	// file still holds the transactions of the last shutdown.
+	if !cfg.NoPersistMempool && s.txMemPool.IsLoaded() {
+		path, err := saveMempool(s.txMemPool)
+		if err != nil {
+			srvrLog.Errorf("Unable to save mempool: %v", err)
+		} else {
+			srvrLog.Infof("Saved mempool to %s", path)
+		}
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
-		metadata.Put(mempool.EstimateFeeDatabaseKey, s.feeEstimator.Save())
+		metadata.Put(mempool.SmartFeeEstimatorDatabaseKey,
+			s.feeEstimator.Save())
 
 		return nil
 	})
@@ -2730,6 +3139,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.Prune != 0 {
+		services &^= wire.SFNodeNetwork
+		services |= wire.SFNodeNetworkLimited
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
@@ -2772,6 +3185,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
+		cfCheckptMsgCache:    make(map[wire.FilterType]*wire.MsgCFCheckpt),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
 	}
@@ -2820,35 +3234,88 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		checkpoints = mergeCheckpoints(s.chainParams.Checkpoints, cfg.addCheckpoints)
 	}
 
+	// Add the utxo snapshots given on the command line to the ones known
+	// for the network.  The chain parameters are copied so the global
+	// parameters of the network are left untouched.
+	blockchainParams := s.chainParams
+	if len(cfg.addAssumeUtxos) > 0 {
+		params := *s.chainParams
+		params.AssumeUtxo = mergeAssumeUtxos(params.AssumeUtxo,
+			cfg.addAssumeUtxos)
+		blockchainParams = &params
+	}
+
+	// Open the utxo snapshot to bootstrap the chain from when requested.
+	var snapshot io.Reader
+	if cfg.LoadSnapshot != "" {
+		snapshotFile, err := os.Open(cfg.LoadSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer snapshotFile.Close()
+
+		srvrLog.Infof("Loading utxo snapshot from %s", cfg.LoadSnapshot)
+		snapshot = bufio.NewReader(snapshotFile)
+	}
+
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:               s.db,
+		Interrupt:        interrupt,
+		ChainParams:      blockchainParams,
+		Checkpoints:      checkpoints,
+		TimeSource:       s.timeSource,
+		SigCache:         s.sigCache,
+		IndexManager:     indexManager,
+		HashCache:        s.hashCache,
+		Prune:            cfg.Prune * 1024 * 1024,
+		UtxoCacheMaxSize: uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		UtxoSetStats:     cfg.UtxoSetStats,
+		Snapshot:         snapshot,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Search for a FeeEstimator state in the database. If none can be found
-	// or if it cannot be loaded, create a new one.
+	// The blocks before the utxo snapshot the chain was bootstrapped from
+	// are not available until it has been validated, so only advertise the
+	// recent blocks in the meantime.
+	if chainStates := s.chain.ChainStates(); len(chainStates) > 1 {
+		s.services &^= wire.SFNodeNetwork
+		s.services |= wire.SFNodeNetworkLimited
+	}
+
+	// Search for a SmartFeeEstimator state in the database. If none can be
+	// found or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
-		feeEstimationData := metadata.Get(mempool.EstimateFeeDatabaseKey)
+
+		// Migrate the state of the fee estimator used by earlier
+		// versions.  It is only used when there is no state of the
+		// smart fee estimator, which is restored below otherwise.
+		legacyData := metadata.Get(mempool.EstimateFeeDatabaseKey)
+		if legacyData != nil {
+			metadata.Delete(mempool.EstimateFeeDatabaseKey)
+
+			var err error
+			s.feeEstimator, err = mempool.MigrateFeeEstimatorState(
+				legacyData)
+			if err != nil {
+				peerLog.Errorf("Failed to migrate fee estimator %v",
+					err)
+			}
+		}
+
+		feeEstimationData := metadata.Get(mempool.SmartFeeEstimatorDatabaseKey)
 		if feeEstimationData != nil {
 			// delete it from the database so that we don't try to restore the
 			// same thing again somehow.
-			metadata.Delete(mempool.EstimateFeeDatabaseKey)
+			metadata.Delete(mempool.SmartFeeEstimatorDatabaseKey)
 
 			// If there is an error, log it and make a new fee estimator.
 			var err error
-			s.feeEstimator, err = mempool.RestoreFeeEstimator(feeEstimationData)
+			s.feeEstimator, err = mempool.RestoreSmartFeeEstimator(feeEstimationData)
 
 			if err != nil {
 				peerLog.Errorf("Failed to restore fee estimator %v", err)
@@ -2858,12 +3325,31 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		return nil
 	})
 
-	// If no feeEstimator has been found, or if the one that has been found
-	// is behind somehow, create a new one and start over.
-	if s.feeEstimator == nil || s.feeEstimator.LastKnownHeight() != s.chain.BestSnapshot().Height {
-		s.feeEstimator = mempool.NewFeeEstimator(
-			mempool.DefaultEstimateFeeMaxRollback,
-			mempool.DefaultEstimateFeeMinRegisteredBlocks)
+	// If no feeEstimator has been found, create a new one and start over.
+	// A restored fee estimator that is behind the chain ignores the data
+	// it recorded earlier until it has seen enough new blocks.
+	if s.feeEstimator == nil {
+		s.feeEstimator = mempool.NewSmartFeeEstimator()
+	}
+
+	// Create the ZMQ notifier when any ZMQ notifications are enabled.  It
+	// is created before the mempool so it can be notified of every
+	// transaction added to and removed from the mempool.
+	if cfg.ZMQPubHashBlock != "" || cfg.ZMQPubHashTx != "" ||
+		cfg.ZMQPubRawBlock != "" || cfg.ZMQPubRawTx != "" ||
+		cfg.ZMQPubSequence != "" {
+
+		s.zmqNotifier, err = zmq.New(&zmq.Config{
+			HashBlock:     cfg.ZMQPubHashBlock,
+			HashTx:        cfg.ZMQPubHashTx,
+			RawBlock:      cfg.ZMQPubRawBlock,
+			RawTx:         cfg.ZMQPubRawTx,
+			Sequence:      cfg.ZMQPubSequence,
+			HighWaterMark: cfg.ZMQPubHWM,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	txC := mempool.Config{
@@ -2877,6 +3363,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
 			RejectReplacement:    cfg.RejectReplacement,
+			MaxAncestorCount:     cfg.LimitAncestorCount,
+			MaxAncestorSize:      cfg.LimitAncestorSize * 1000,
+			MaxDescendantCount:   cfg.LimitDescendantCount,
+			MaxDescendantSize:    cfg.LimitDescendantSize * 1000,
+			MaxPoolSize:          cfg.MaxMempool * 1000000,
+			Expiry:               cfg.MempoolExpiry,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2891,6 +3383,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
 	}
+	if s.zmqNotifier != nil {
+		txC.TxAdded = s.zmqNotifier.TransactionAccepted
+		txC.TxRemoved = s.zmqNotifier.TransactionRemoved
+	}
 	s.txMemPool = mempool.New(&txC)
 
 	s.syncManager, err = netsync.New(&netsync.Config{
@@ -2900,12 +3396,22 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		ChainParams:        s.chainParams,
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
+		MaxCmpctHBPeers:    cfg.MaxCmpctHBPeers,
 		FeeEstimator:       s.feeEstimator,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Feed the ZMQ notifier the block connected and disconnected
+	// notifications from the chain.  It subscribes after the sync manager,
+	// which removes the transactions conflicting with a connected block
+	// from the mempool, so those removals are published before the block
+	// like Bitcoin Core does.
+	if s.zmqNotifier != nil {
+		s.chain.Subscribe(s.zmqNotifier.HandleBlockchainNotification)
+	}
+
 	// Create the mining policy and block template generator based on the
 	// configuration options.
 	//
@@ -3043,6 +3549,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			AddrIndex:    s.addrIndex,
 			CfIndex:      s.cfIndex,
 			FeeEstimator: s.feeEstimator,
+			ZMQNotifier:  s.zmqNotifier,
 		})
 		if err != nil {
 			return nil, err
@@ -3333,6 +3840,32 @@ func mergeCheckpoints(defaultCheckpoints, additional []chaincfg.Checkpoint) []ch
 	return checkpoints
 }
 
+// mergeAssumeUtxos returns two slices of utxo snapshot entries merged into one
+// slice such that the entries are sorted by height.  In the case the additional
+// entries contain an entry with the same height as a default entry, the
+// additional entry will take precedence and overwrite the default one.
+func mergeAssumeUtxos(defaultAssumeUtxos, additional []chaincfg.AssumeUtxo) []chaincfg.AssumeUtxo {
+	extra := make(map[int32]chaincfg.AssumeUtxo)
+	for _, assumeUtxo := range additional {
+		extra[assumeUtxo.Height] = assumeUtxo
+	}
+
+	numDefault := len(defaultAssumeUtxos)
+	assumeUtxos := make([]chaincfg.AssumeUtxo, 0, numDefault+len(extra))
+	for _, assumeUtxo := range defaultAssumeUtxos {
+		if _, exists := extra[assumeUtxo.Height]; !exists {
+			assumeUtxos = append(assumeUtxos, assumeUtxo)
+		}
+	}
+	for _, assumeUtxo := range extra {
+		assumeUtxos = append(assumeUtxos, assumeUtxo)
+	}
+	sort.Slice(assumeUtxos, func(i, j int) bool {
+		return assumeUtxos[i].Height < assumeUtxos[j].Height
+	})
+	return assumeUtxos
+}
+
 // HasUndesiredUserAgent determines whether the server should continue to pursue
 // a connection with this peer based on its advertised user agent. It performs
 // the following steps:
@@ -285,6 +285,10 @@ type Engine struct {
 	witnessProgram  []byte
 	inputAmount     int64
 	taprootCtx      *taprootExecutionCtx
+
+	// stepHook is invoked with the trace of each step executed by the
+	// engine when set.
+	stepHook StepHook
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -777,6 +781,17 @@ func (vm *Engine) verifyWitnessProgram(witness wire.TxWitness) error {
 // DisasmPC returns the string for the disassembly of the opcode that will be
 // next to execute when Step is called.
 func (vm *Engine) DisasmPC() (string, error) {
+	disasm, err := vm.disasmNextOpcode()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02x:%04x: %s", vm.scriptIdx, vm.opcodeIdx,
+		disasm), nil
+}
+
+// disasmNextOpcode returns the disassembly of the opcode that will be next to
+// execute when Step is called without the position of the program counter.
+func (vm *Engine) disasmNextOpcode() (string, error) {
 	if err := vm.checkValidPC(); err != nil {
 		return "", err
 	}
@@ -810,8 +825,7 @@ func (vm *Engine) DisasmPC() (string, error) {
 
 	var buf strings.Builder
 	disasmOpcode(&buf, peekTokenizer.op, peekTokenizer.Data(), false)
-	return fmt.Sprintf("%02x:%04x: %s", vm.scriptIdx, vm.opcodeIdx,
-		buf.String()), nil
+	return buf.String(), nil
 }
 
 // DisasmScript returns the disassembly string for the script at the requested
@@ -905,7 +919,24 @@ func (vm *Engine) CheckErrorCondition(finalScript bool) error {
 //
 // The result of calling Step or any other method is undefined if an error is
 // returned.
+//
+// The step hook of the engine, if any, is invoked with the trace of the step
+// before returning.
 func (vm *Engine) Step() (done bool, err error) {
+	if vm.stepHook == nil {
+		return vm.step()
+	}
+
+	step := vm.newTraceStep()
+	done, err = vm.step()
+	vm.finishTraceStep(step, err)
+	vm.stepHook(step)
+
+	return done, err
+}
+
+// step executes the next instruction as described by Step.
+func (vm *Engine) step() (done bool, err error) {
 	// Verify the engine is pointing to a valid program counter.
 	if err := vm.checkValidPC(); err != nil {
 		return true, err
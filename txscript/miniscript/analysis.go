@@ -0,0 +1,357 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+// maxInt is a size which is either valid or denotes the absence of a
+// satisfaction or dissatisfaction.  Adding sizes results in an invalid size if
+// either of them is invalid, while the maximum of two sizes is only invalid if
+// both of them are.
+type maxInt struct {
+	valid bool
+	value uint32
+}
+
+// noSize is the invalid size.
+var noSize = maxInt{}
+
+// size returns the valid size of the passed value.
+func size(value uint32) maxInt {
+	return maxInt{valid: true, value: value}
+}
+
+// add returns the sum of the passed sizes.
+func (a maxInt) add(b maxInt) maxInt {
+	if !a.valid || !b.valid {
+		return noSize
+	}
+	return size(a.value + b.value)
+}
+
+// or returns the maximum of the passed sizes.
+func (a maxInt) or(b maxInt) maxInt {
+	switch {
+	case !a.valid:
+		return b
+	case !b.valid:
+		return a
+	case a.value >= b.value:
+		return a
+	}
+	return b
+}
+
+// sizes are the maximum sizes of the satisfactions and dissatisfactions of an
+// expression.
+type sizes struct {
+	sat  maxInt
+	dsat maxInt
+}
+
+// opsCount is the number of non-push opcodes of an expression along with the
+// maximum number of opcodes executed by its satisfactions and
+// dissatisfactions in addition to those, which are the keys checked by
+// CHECKMULTISIG.
+type opsCount struct {
+	count uint32
+	sizes
+}
+
+// thresholdSizes returns the sizes of satisfying exactly k of the passed
+// subexpressions as well as of dissatisfying all of them.
+func thresholdSizes(k uint32, subs []sizes) sizes {
+	// sats[i] is the size of satisfying i of the subexpressions processed
+	// so far while dissatisfying the others.
+	sats := []maxInt{size(0)}
+	for _, sub := range subs {
+		next := make([]maxInt, 0, len(sats)+1)
+		next = append(next, sats[0].add(sub.dsat))
+		for j := 1; j < len(sats); j++ {
+			next = append(next, sats[j].add(sub.dsat).or(
+				sats[j-1].add(sub.sat),
+			))
+		}
+		next = append(next, sats[len(sats)-1].add(sub.sat))
+		sats = next
+	}
+
+	return sizes{sat: sats[k], dsat: sats[0]}
+}
+
+// computeOps returns the number of opcodes of the passed node given those of
+// its subexpressions.
+func computeOps(n *Node) opsCount {
+	var x, y, z opsCount
+	if len(n.subs) > 0 {
+		x = n.subs[0].ops
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].ops
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].ops
+	}
+
+	switch n.fragment {
+	case FragmentJust0:
+		return opsCount{0, sizes{noSize, size(0)}}
+	case FragmentJust1:
+		return opsCount{0, sizes{size(0), noSize}}
+	case FragmentPkK:
+		return opsCount{0, sizes{size(0), size(0)}}
+	case FragmentPkH:
+		return opsCount{3, sizes{size(0), size(0)}}
+	case FragmentOlder, FragmentAfter:
+		return opsCount{1, sizes{size(0), noSize}}
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		return opsCount{4, sizes{size(0), size(0)}}
+
+	case FragmentWrapA:
+		return opsCount{x.count + 2, x.sizes}
+	case FragmentWrapS, FragmentWrapC, FragmentWrapN:
+		return opsCount{x.count + 1, x.sizes}
+	case FragmentWrapD:
+		return opsCount{x.count + 3, sizes{x.sat, size(0)}}
+	case FragmentWrapV:
+		// The verification is fused into the last opcode of the
+		// subexpression unless it requires an explicit OP_VERIFY.
+		count := x.count
+		if n.subs[0].typ.Has(PropX) {
+			count++
+		}
+		return opsCount{count, sizes{x.sat, noSize}}
+	case FragmentWrapJ:
+		return opsCount{x.count + 4, sizes{x.sat, size(0)}}
+
+	case FragmentAndV:
+		return opsCount{x.count + y.count, sizes{x.sat.add(y.sat), noSize}}
+	case FragmentAndB:
+		return opsCount{x.count + y.count + 1, sizes{
+			x.sat.add(y.sat), x.dsat.add(y.dsat),
+		}}
+	case FragmentOrB:
+		return opsCount{x.count + y.count + 1, sizes{
+			x.sat.add(y.dsat).or(x.dsat.add(y.sat)),
+			x.dsat.add(y.dsat),
+		}}
+	case FragmentOrD:
+		return opsCount{x.count + y.count + 3, sizes{
+			x.sat.or(x.dsat.add(y.sat)), x.dsat.add(y.dsat),
+		}}
+	case FragmentOrC:
+		return opsCount{x.count + y.count + 2, sizes{
+			x.sat.or(x.dsat.add(y.sat)), noSize,
+		}}
+	case FragmentOrI:
+		return opsCount{x.count + y.count + 3, sizes{
+			x.sat.or(y.sat), x.dsat.or(y.dsat),
+		}}
+	case FragmentAndOr:
+		return opsCount{x.count + y.count + z.count + 3, sizes{
+			x.sat.add(y.sat).or(x.dsat.add(z.sat)),
+			x.dsat.add(z.dsat),
+		}}
+
+	case FragmentMulti:
+		// CHECKMULTISIG counts the keys as executed opcodes.
+		numKeys := uint32(len(n.keys))
+		return opsCount{1, sizes{size(numKeys), size(numKeys)}}
+	case FragmentMultiA:
+		return opsCount{uint32(len(n.keys)) + 1, sizes{size(0), size(0)}}
+
+	case FragmentThresh:
+		var count uint32
+		subs := make([]sizes, 0, len(n.subs))
+		for _, sub := range n.subs {
+			count += sub.ops.count + 1
+			subs = append(subs, sub.ops.sizes)
+		}
+		return opsCount{count, thresholdSizes(n.k, subs)}
+	}
+
+	return opsCount{}
+}
+
+// computeStackSize returns the maximum number of witness stack elements of the
+// satisfactions and dissatisfactions of the passed node given those of its
+// subexpressions.
+func computeStackSize(n *Node) sizes {
+	var x, y, z sizes
+	if len(n.subs) > 0 {
+		x = n.subs[0].stack
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].stack
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].stack
+	}
+
+	switch n.fragment {
+	case FragmentJust0:
+		return sizes{noSize, size(0)}
+	case FragmentJust1, FragmentOlder, FragmentAfter:
+		return sizes{size(0), noSize}
+	case FragmentPkK:
+		return sizes{size(1), size(1)}
+	case FragmentPkH:
+		return sizes{size(2), size(2)}
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		return sizes{size(1), size(1)}
+
+	case FragmentWrapA, FragmentWrapS, FragmentWrapC, FragmentWrapN:
+		return x
+	case FragmentWrapD:
+		return sizes{x.sat.add(size(1)), size(1)}
+	case FragmentWrapV:
+		return sizes{x.sat, noSize}
+	case FragmentWrapJ:
+		return sizes{x.sat, size(1)}
+
+	case FragmentAndV:
+		return sizes{x.sat.add(y.sat), noSize}
+	case FragmentAndB:
+		return sizes{x.sat.add(y.sat), x.dsat.add(y.dsat)}
+	case FragmentOrB:
+		return sizes{
+			x.sat.add(y.dsat).or(x.dsat.add(y.sat)),
+			x.dsat.add(y.dsat),
+		}
+	case FragmentOrD:
+		return sizes{x.sat.or(x.dsat.add(y.sat)), x.dsat.add(y.dsat)}
+	case FragmentOrC:
+		return sizes{x.sat.or(x.dsat.add(y.sat)), noSize}
+	case FragmentOrI:
+		// The branch is selected by an additional element.
+		return sizes{
+			x.sat.or(y.sat).add(size(1)),
+			x.dsat.or(y.dsat).add(size(1)),
+		}
+	case FragmentAndOr:
+		return sizes{
+			x.sat.add(y.sat).or(x.dsat.add(z.sat)),
+			x.dsat.add(z.dsat),
+		}
+
+	case FragmentMulti:
+		// CHECKMULTISIG consumes an additional dummy element.
+		return sizes{size(n.k + 1), size(n.k + 1)}
+	case FragmentMultiA:
+		numKeys := uint32(len(n.keys))
+		return sizes{size(numKeys), size(numKeys)}
+
+	case FragmentThresh:
+		subs := make([]sizes, 0, len(n.subs))
+		for _, sub := range n.subs {
+			subs = append(subs, sub.stack)
+		}
+		return thresholdSizes(n.k, subs)
+	}
+
+	return sizes{}
+}
+
+// computeWitnessSize returns the maximum size in bytes of the witness stack
+// elements of the satisfactions and dissatisfactions of the passed node given
+// those of its subexpressions.  The size of each element includes its length
+// prefix.
+func computeWitnessSize(n *Node) sizes {
+	var x, y, z sizes
+	if len(n.subs) > 0 {
+		x = n.subs[0].wit
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].wit
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].wit
+	}
+
+	// Including the sighash type, ECDSA signatures with low S values are
+	// at most 72 bytes and schnorr signatures are at most 65 bytes.  An
+	// empty element, which is used for dissatisfactions, is just the
+	// length prefix.
+	sigSize, keySize := size(1+72), size(1+33)
+	if n.ctx == Tapscript {
+		sigSize, keySize = size(1+65), size(1+32)
+	}
+	empty, one := size(1), size(2)
+
+	switch n.fragment {
+	case FragmentJust0:
+		return sizes{noSize, size(0)}
+	case FragmentJust1, FragmentOlder, FragmentAfter:
+		return sizes{size(0), noSize}
+	case FragmentPkK:
+		return sizes{sigSize, empty}
+	case FragmentPkH:
+		return sizes{sigSize.add(keySize), empty.add(keySize)}
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		// Preimages are 32 bytes and so are the dissatisfactions.
+		return sizes{size(1 + 32), size(1 + 32)}
+
+	case FragmentWrapA, FragmentWrapS, FragmentWrapC, FragmentWrapN:
+		return x
+	case FragmentWrapD:
+		return sizes{x.sat.add(one), empty}
+	case FragmentWrapV:
+		return sizes{x.sat, noSize}
+	case FragmentWrapJ:
+		return sizes{x.sat, empty}
+
+	case FragmentAndV:
+		return sizes{x.sat.add(y.sat), noSize}
+	case FragmentAndB:
+		return sizes{x.sat.add(y.sat), x.dsat.add(y.dsat)}
+	case FragmentOrB:
+		return sizes{
+			x.sat.add(y.dsat).or(x.dsat.add(y.sat)),
+			x.dsat.add(y.dsat),
+		}
+	case FragmentOrD:
+		return sizes{x.sat.or(x.dsat.add(y.sat)), x.dsat.add(y.dsat)}
+	case FragmentOrC:
+		return sizes{x.sat.or(x.dsat.add(y.sat)), noSize}
+	case FragmentOrI:
+		// The first branch is selected by a one and the second by an
+		// empty element.
+		return sizes{
+			x.sat.add(one).or(y.sat.add(empty)),
+			x.dsat.add(one).or(y.dsat.add(empty)),
+		}
+	case FragmentAndOr:
+		return sizes{
+			x.sat.add(y.sat).or(x.dsat.add(z.sat)),
+			x.dsat.add(z.dsat),
+		}
+
+	case FragmentMulti:
+		// The signatures are preceded by the empty dummy element.
+		return sizes{
+			size(n.k*sigSize.value + empty.value),
+			size((n.k + 1) * empty.value),
+		}
+	case FragmentMultiA:
+		numKeys := uint32(len(n.keys))
+		return sizes{
+			size(n.k*sigSize.value + (numKeys-n.k)*empty.value),
+			size(numKeys * empty.value),
+		}
+
+	case FragmentThresh:
+		subs := make([]sizes, 0, len(n.subs))
+		for _, sub := range n.subs {
+			subs = append(subs, sub.wit)
+		}
+		return thresholdSizes(n.k, subs)
+	}
+
+	return sizes{}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package miniscript implements miniscript, a language for writing a structured
+subset of bitcoin scripts which can be analyzed, composed and satisfied
+generically.
+
+A complete description of miniscript can be found at
+https://bitcoin.sipa.be/miniscript.  The following only serves as a quick
+overview to provide information on how to use the package.
+
+# Expressions
+
+Expressions are parsed from their string representation with Parse, such as
+"or_d(pk(K1),and_v(v:pk(K2),older(144)))" for a script which can be spent with
+a signature for K1 or with a signature for K2 after 144 blocks.  Keys are hex
+encoded compressed public keys in the P2WSH context and x-only public keys in
+the tapscript context.  Script encodes an expression to its script and
+DecodeScript decodes a script back to its expression.
+
+# Types
+
+Each expression has a type, which determines how it can be composed with other
+expressions, and a set of properties describing its satisfactions.  Only
+expressions with consistent types are valid, which guarantees they are correct.
+SanityCheck additionally checks that an expression can always be satisfied
+without malleability and within the resource limits of its context.
+
+# Satisfaction
+
+Satisfy computes the smallest witness satisfying an expression given the
+signatures, preimages and timelocks provided by a Satisfier, such as
+WitnessData.  MaxWitnessSize provides the maximum size of such a witness, which
+is needed to estimate the fee of a spending transaction before signing it.
+*/
+package miniscript
@@ -0,0 +1,868 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// maxP2WSHScriptSize is the maximum size of a standard P2WSH witness
+	// script.
+	maxP2WSHScriptSize = 3600
+
+	// maxP2WSHStackItems is the maximum number of standard P2WSH witness
+	// stack items, excluding the witness script.
+	maxP2WSHStackItems = 100
+
+	// maxTapscriptSize is the maximum size of a tapscript, which is only
+	// bounded by the maximum standard weight of the spending transaction.
+	maxTapscriptSize = 400000
+
+	// maxMultiAKeys is the maximum number of keys of a multi_a fragment,
+	// which would otherwise exceed the stack size limit of tapscript.
+	maxMultiAKeys = txscript.MaxStackSize - 1
+)
+
+// Context is the script context a miniscript expression is used in, which
+// determines the encoding of keys, the available fragments and the resource
+// limits the expression is subject to.
+type Context uint8
+
+const (
+	// P2WSH is the context of witness v0 scripts.
+	P2WSH Context = iota
+
+	// Tapscript is the context of leaf scripts of witness v1 outputs as
+	// defined in BIP 342.
+	Tapscript
+)
+
+// String returns the name of the context.
+func (c Context) String() string {
+	switch c {
+	case P2WSH:
+		return "P2WSH"
+	case Tapscript:
+		return "Tapscript"
+	}
+	return fmt.Sprintf("Unknown Context (%d)", uint8(c))
+}
+
+// Fragment identifies the kind of a miniscript expression.
+type Fragment uint8
+
+const (
+	// FragmentJust0 is the 0 fragment, which is never satisfiable.
+	FragmentJust0 Fragment = iota
+
+	// FragmentJust1 is the 1 fragment, which is always satisfied.
+	FragmentJust1
+
+	// FragmentPkK is the pk_k(key) fragment, which pushes a key.
+	FragmentPkK
+
+	// FragmentPkH is the pk_h(key) fragment, which pushes a key given
+	// its hash.
+	FragmentPkH
+
+	// FragmentOlder is the older(n) fragment, which requires a relative
+	// timelock of n.
+	FragmentOlder
+
+	// FragmentAfter is the after(n) fragment, which requires an absolute
+	// timelock of n.
+	FragmentAfter
+
+	// FragmentSha256 is the sha256(h) fragment, which requires the SHA-256
+	// preimage of h.
+	FragmentSha256
+
+	// FragmentHash256 is the hash256(h) fragment, which requires the
+	// double SHA-256 preimage of h.
+	FragmentHash256
+
+	// FragmentRipemd160 is the ripemd160(h) fragment, which requires the
+	// RIPEMD-160 preimage of h.
+	FragmentRipemd160
+
+	// FragmentHash160 is the hash160(h) fragment, which requires the
+	// RIPEMD-160 of SHA-256 preimage of h.
+	FragmentHash160
+
+	// FragmentWrapA is the a: wrapper, which moves the top of the stack to
+	// the alternate stack while executing its subexpression.
+	FragmentWrapA
+
+	// FragmentWrapS is the s: wrapper, which swaps the top two stack
+	// elements before executing its subexpression.
+	FragmentWrapS
+
+	// FragmentWrapC is the c: wrapper, which checks a signature against
+	// the key pushed by its subexpression.
+	FragmentWrapC
+
+	// FragmentWrapD is the d: wrapper, which only executes its
+	// subexpression if the top of the stack is true.
+	FragmentWrapD
+
+	// FragmentWrapV is the v: wrapper, which verifies its subexpression.
+	FragmentWrapV
+
+	// FragmentWrapJ is the j: wrapper, which only executes its
+	// subexpression if the top of the stack is not empty.
+	FragmentWrapJ
+
+	// FragmentWrapN is the n: wrapper, which converts the result of its
+	// subexpression to 0 or 1.
+	FragmentWrapN
+
+	// FragmentAndV is the and_v(X,Y) fragment.
+	FragmentAndV
+
+	// FragmentAndB is the and_b(X,Y) fragment.
+	FragmentAndB
+
+	// FragmentAndOr is the andor(X,Y,Z) fragment.
+	FragmentAndOr
+
+	// FragmentOrB is the or_b(X,Z) fragment.
+	FragmentOrB
+
+	// FragmentOrC is the or_c(X,Z) fragment.
+	FragmentOrC
+
+	// FragmentOrD is the or_d(X,Z) fragment.
+	FragmentOrD
+
+	// FragmentOrI is the or_i(X,Z) fragment.
+	FragmentOrI
+
+	// FragmentThresh is the thresh(k,X1,...,Xn) fragment.
+	FragmentThresh
+
+	// FragmentMulti is the multi(k,key1,...,keyn) fragment, which is only
+	// available in the P2WSH context.
+	FragmentMulti
+
+	// FragmentMultiA is the multi_a(k,key1,...,keyn) fragment, which is
+	// only available in the tapscript context.
+	FragmentMultiA
+)
+
+// fragmentNames are the names of the fragments used by the string
+// representation of miniscript expressions.
+var fragmentNames = map[Fragment]string{
+	FragmentJust0:     "0",
+	FragmentJust1:     "1",
+	FragmentPkK:       "pk_k",
+	FragmentPkH:       "pk_h",
+	FragmentOlder:     "older",
+	FragmentAfter:     "after",
+	FragmentSha256:    "sha256",
+	FragmentHash256:   "hash256",
+	FragmentRipemd160: "ripemd160",
+	FragmentHash160:   "hash160",
+	FragmentWrapA:     "a",
+	FragmentWrapS:     "s",
+	FragmentWrapC:     "c",
+	FragmentWrapD:     "d",
+	FragmentWrapV:     "v",
+	FragmentWrapJ:     "j",
+	FragmentWrapN:     "n",
+	FragmentAndV:      "and_v",
+	FragmentAndB:      "and_b",
+	FragmentAndOr:     "andor",
+	FragmentOrB:       "or_b",
+	FragmentOrC:       "or_c",
+	FragmentOrD:       "or_d",
+	FragmentOrI:       "or_i",
+	FragmentThresh:    "thresh",
+	FragmentMulti:     "multi",
+	FragmentMultiA:    "multi_a",
+}
+
+// String returns the name of the fragment.
+func (f Fragment) String() string {
+	if name, ok := fragmentNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown Fragment (%d)", uint8(f))
+}
+
+// hashSize returns the size of the hash of the passed hash fragment.
+func hashSize(f Fragment) int {
+	switch f {
+	case FragmentSha256, FragmentHash256:
+		return 32
+	}
+	return 20
+}
+
+// Node is a miniscript expression.  Nodes are immutable and always valid,
+// which means their types are consistent with the types of their
+// subexpressions.
+type Node struct {
+	fragment Fragment
+	k        uint32
+	keys     [][]byte
+	data     []byte
+	subs     []*Node
+
+	ctx   Context
+	typ   Type
+	ops   opsCount
+	stack sizes
+	wit   sizes
+}
+
+// newNode returns a new node of the passed fragment and arguments after
+// computing its type and resource usage.  An error is returned if the node is
+// invalid in the passed context.
+func newNode(ctx Context, fragment Fragment, k uint32, keys [][]byte,
+	data []byte, subs ...*Node) (*Node, error) {
+
+	n := &Node{
+		fragment: fragment,
+		k:        k,
+		keys:     keys,
+		data:     data,
+		subs:     subs,
+		ctx:      ctx,
+	}
+
+	n.typ = sanitizeType(computeType(n))
+	if n.typ == 0 {
+		return nil, fmt.Errorf("%s has an invalid type", n)
+	}
+	n.ops = computeOps(n)
+	n.stack = computeStackSize(n)
+	n.wit = computeWitnessSize(n)
+
+	return n, nil
+}
+
+// Fragment returns the kind of the expression.
+func (n *Node) Fragment() Fragment {
+	return n.fragment
+}
+
+// K returns the threshold of thresh, multi and multi_a expressions and the
+// timelock of older and after expressions.
+func (n *Node) K() uint32 {
+	return n.k
+}
+
+// Keys returns the keys of pk_k, pk_h, multi and multi_a expressions.
+func (n *Node) Keys() [][]byte {
+	return n.keys
+}
+
+// Data returns the hash of hash expressions.
+func (n *Node) Data() []byte {
+	return n.data
+}
+
+// Subs returns the subexpressions of wrappers and combinators.
+func (n *Node) Subs() []*Node {
+	return n.subs
+}
+
+// Context returns the script context of the expression.
+func (n *Node) Context() Context {
+	return n.ctx
+}
+
+// Type returns the type of the expression.
+func (n *Node) Type() Type {
+	return n.typ
+}
+
+// IsNonMalleable returns whether the expression always has a satisfaction
+// which a third party can't modify.
+func (n *Node) IsNonMalleable() bool {
+	return n.typ.Has(PropM)
+}
+
+// NeedsSignature returns whether every satisfaction of the expression
+// requires a signature.
+func (n *Node) NeedsSignature() bool {
+	return n.typ.Has(PropS)
+}
+
+// HasTimelockMix returns whether the expression has a satisfaction path which
+// requires both a height and a time based timelock of the same kind, which
+// can never be satisfied.
+func (n *Node) HasTimelockMix() bool {
+	return !n.typ.Has(PropK)
+}
+
+// MaxOps returns the maximum number of non-push opcodes executed by a
+// satisfaction of the expression, which is limited in the P2WSH context.
+// False is returned if the expression can't be satisfied.
+func (n *Node) MaxOps() (uint32, bool) {
+	if !n.ops.sat.valid {
+		return 0, false
+	}
+	return n.ops.count + n.ops.sat.value, true
+}
+
+// MaxWitnessElements returns the maximum number of witness stack elements of a
+// satisfaction of the expression, excluding the script itself.  False is
+// returned if the expression can't be satisfied.
+func (n *Node) MaxWitnessElements() (uint32, bool) {
+	return n.stack.sat.value, n.stack.sat.valid
+}
+
+// MaxWitnessSize returns the maximum size in bytes of the witness stack
+// elements of a satisfaction of the expression, including their length
+// prefixes but excluding the script itself.  False is returned if the
+// expression can't be satisfied.
+func (n *Node) MaxWitnessSize() (uint32, bool) {
+	return n.wit.sat.value, n.wit.sat.valid
+}
+
+// IsValidTopLevel returns whether the expression can be used as the whole
+// script of an output, which requires it to be of type B and within the
+// script size limit of its context.
+func (n *Node) IsValidTopLevel() bool {
+	if !n.typ.Has(TypeB) {
+		return false
+	}
+	maxSize := maxP2WSHScriptSize
+	if n.ctx == Tapscript {
+		maxSize = maxTapscriptSize
+	}
+	return n.scriptSize() <= maxSize
+}
+
+// SanityCheck returns an error if the expression is not sane, which means it
+// can't be satisfied without malleability, has a satisfaction without a
+// signature, mixes timelocks, exceeds the resource limits of its context or
+// reuses keys.
+func (n *Node) SanityCheck() error {
+	switch {
+	case !n.IsValidTopLevel():
+		return errors.New("not a valid top level expression")
+	case !n.IsNonMalleable():
+		return errors.New("has malleable satisfactions")
+	case !n.NeedsSignature():
+		return errors.New("has satisfactions without a signature")
+	case n.HasTimelockMix():
+		return errors.New("mixes height and time based timelocks")
+	}
+
+	if n.ctx == P2WSH {
+		if ops, ok := n.MaxOps(); ok && ops > txscript.MaxOpsPerScript {
+			return fmt.Errorf("executes up to %d opcodes, the "+
+				"maximum is %d", ops, txscript.MaxOpsPerScript)
+		}
+	}
+
+	maxStackItems := uint32(maxP2WSHStackItems)
+	if n.ctx == Tapscript {
+		maxStackItems = txscript.MaxStackSize
+	}
+	if items, ok := n.MaxWitnessElements(); ok && items > maxStackItems {
+		return fmt.Errorf("satisfactions require up to %d stack items, "+
+			"the maximum is %d", items, maxStackItems)
+	}
+
+	keys := make(map[string]struct{})
+	var dupKey []byte
+	n.walk(func(node *Node) {
+		for _, key := range node.keys {
+			if _, ok := keys[string(key)]; ok && dupKey == nil {
+				dupKey = key
+			}
+			keys[string(key)] = struct{}{}
+		}
+	})
+	if dupKey != nil {
+		return fmt.Errorf("key %x is used more than once", dupKey)
+	}
+
+	return nil
+}
+
+// walk invokes the passed function with the node and all its subexpressions
+// in pre-order.
+func (n *Node) walk(f func(*Node)) {
+	f(n)
+	for _, sub := range n.subs {
+		sub.walk(f)
+	}
+}
+
+// String returns the miniscript representation of the expression, using the
+// pk, pkh, and_n, t:, l: and u: shorthands where possible.
+func (n *Node) String() string {
+	var b strings.Builder
+	n.writeString(&b, false)
+	return b.String()
+}
+
+// writeString writes the string representation of the node to the passed
+// builder.  Wrapped specifies whether the node is the subexpression of a
+// wrapper, in which case it is separated from the wrapper letters by a colon
+// unless it is a wrapper itself.
+func (n *Node) writeString(b *strings.Builder, wrapped bool) {
+	// The wrapper letters of nested wrappers are written one after the
+	// other, followed by a colon and the wrapped expression.
+	var wrapper string
+	switch n.fragment {
+	case FragmentWrapA, FragmentWrapS, FragmentWrapD, FragmentWrapV,
+		FragmentWrapJ, FragmentWrapN:
+
+		wrapper = n.fragment.String()
+
+	case FragmentWrapC:
+		// The pk and pkh shorthands don't count as wrappers.
+		switch n.subs[0].fragment {
+		case FragmentPkK, FragmentPkH:
+		default:
+			wrapper = "c"
+		}
+
+	case FragmentAndV:
+		if n.subs[1].fragment == FragmentJust1 {
+			b.WriteString("t")
+			n.subs[0].writeString(b, true)
+			return
+		}
+
+	case FragmentOrI:
+		if n.subs[0].fragment == FragmentJust0 {
+			b.WriteString("l")
+			n.subs[1].writeString(b, true)
+			return
+		}
+		if n.subs[1].fragment == FragmentJust0 {
+			b.WriteString("u")
+			n.subs[0].writeString(b, true)
+			return
+		}
+	}
+	if wrapper != "" {
+		b.WriteString(wrapper)
+		n.subs[0].writeString(b, true)
+		return
+	}
+
+	if wrapped {
+		b.WriteString(":")
+	}
+
+	switch n.fragment {
+	case FragmentJust0, FragmentJust1:
+		b.WriteString(n.fragment.String())
+
+	case FragmentPkK, FragmentPkH:
+		fmt.Fprintf(b, "%s(%x)", n.fragment, n.keys[0])
+
+	case FragmentWrapC:
+		name := "pk"
+		if n.subs[0].fragment == FragmentPkH {
+			name = "pkh"
+		}
+		fmt.Fprintf(b, "%s(%x)", name, n.subs[0].keys[0])
+
+	case FragmentOlder, FragmentAfter:
+		fmt.Fprintf(b, "%s(%d)", n.fragment, n.k)
+
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		fmt.Fprintf(b, "%s(%x)", n.fragment, n.data)
+
+	case FragmentMulti, FragmentMultiA:
+		fmt.Fprintf(b, "%s(%d", n.fragment, n.k)
+		for _, key := range n.keys {
+			fmt.Fprintf(b, ",%x", key)
+		}
+		b.WriteString(")")
+
+	default:
+		subs := n.subs
+		switch {
+		case n.fragment == FragmentAndOr &&
+			subs[2].fragment == FragmentJust0:
+
+			b.WriteString("and_n(")
+			subs = subs[:2]
+
+		case n.fragment == FragmentThresh:
+			fmt.Fprintf(b, "thresh(%d,", n.k)
+
+		default:
+			fmt.Fprintf(b, "%s(", n.fragment)
+		}
+		for i, sub := range subs {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			sub.writeString(b, false)
+		}
+		b.WriteString(")")
+	}
+}
+
+// Parse parses the passed miniscript expression in the passed context.  An
+// error is returned if the expression is malformed or isn't valid as the whole
+// script of an output.
+func Parse(str string, ctx Context) (*Node, error) {
+	n, err := parseExpr(str, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsValidTopLevel() {
+		return nil, fmt.Errorf("%s is not a valid top level expression "+
+			"of type %s", n, n.typ)
+	}
+
+	return n, nil
+}
+
+// parseExpr parses the passed expression, which may be a subexpression.
+func parseExpr(str string, ctx Context) (*Node, error) {
+	// Wrappers are a sequence of letters separated from the expression
+	// they apply to by a colon.
+	end := strings.IndexByte(str, '(')
+	if end < 0 {
+		end = len(str)
+	}
+	if colon := strings.IndexByte(str[:end], ':'); colon >= 0 {
+		wrappers := str[:colon]
+		if wrappers == "" {
+			return nil, fmt.Errorf("missing wrappers in %q", str)
+		}
+		n, err := parseExpr(str[colon+1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// The wrapper closest to the expression is applied first.
+		for i := len(wrappers) - 1; i >= 0; i-- {
+			n, err = wrap(wrappers[i], n)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	}
+
+	switch str {
+	case "0":
+		return newNode(ctx, FragmentJust0, 0, nil, nil)
+	case "1":
+		return newNode(ctx, FragmentJust1, 0, nil, nil)
+	}
+
+	if end == len(str) || str[len(str)-1] != ')' {
+		return nil, fmt.Errorf("malformed expression %q", str)
+	}
+	name := str[:end]
+	args, err := splitArgs(str[end+1 : len(str)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// numArgs returns an error unless exactly the passed number of
+	// arguments were given.
+	numArgs := func(num int) error {
+		if len(args) != num {
+			return fmt.Errorf("%s takes %d arguments, got %d", name,
+				num, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "pk_k", "pk_h", "pk", "pkh":
+		if err := numArgs(1); err != nil {
+			return nil, err
+		}
+		key, err := parseKey(args[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		fragment := FragmentPkK
+		if name == "pk_h" || name == "pkh" {
+			fragment = FragmentPkH
+		}
+		n, err := newNode(ctx, fragment, 0, [][]byte{key}, nil)
+		if err != nil || name == "pk_k" || name == "pk_h" {
+			return n, err
+		}
+		return newNode(ctx, FragmentWrapC, 0, nil, nil, n)
+
+	case "older", "after":
+		if err := numArgs(1); err != nil {
+			return nil, err
+		}
+		k, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || k < 1 || k >= 1<<31 {
+			return nil, fmt.Errorf("invalid timelock %q", args[0])
+		}
+
+		fragment := FragmentOlder
+		if name == "after" {
+			fragment = FragmentAfter
+		}
+		return newNode(ctx, fragment, uint32(k), nil, nil)
+
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if err := numArgs(1); err != nil {
+			return nil, err
+		}
+
+		var fragment Fragment
+		switch name {
+		case "sha256":
+			fragment = FragmentSha256
+		case "hash256":
+			fragment = FragmentHash256
+		case "ripemd160":
+			fragment = FragmentRipemd160
+		default:
+			fragment = FragmentHash160
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil || len(hash) != hashSize(fragment) {
+			return nil, fmt.Errorf("invalid %s hash %q", name,
+				args[0])
+		}
+		return newNode(ctx, fragment, 0, nil, hash)
+
+	case "multi", "multi_a":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s takes a threshold and at "+
+				"least one key", name)
+		}
+
+		fragment, maxKeys := FragmentMulti, txscript.MaxPubKeysPerMultiSig
+		if name == "multi_a" {
+			fragment, maxKeys = FragmentMultiA, maxMultiAKeys
+		}
+		if (fragment == FragmentMulti) != (ctx == P2WSH) {
+			return nil, fmt.Errorf("%s is not available in the %v "+
+				"context", name, ctx)
+		}
+		if len(args)-1 > maxKeys {
+			return nil, fmt.Errorf("%s takes at most %d keys", name,
+				maxKeys)
+		}
+
+		k, err := parseThreshold(args[0], len(args)-1)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([][]byte, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			key, err := parseKey(arg, ctx)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		return newNode(ctx, fragment, k, keys, nil)
+
+	case "thresh":
+		if len(args) < 2 {
+			return nil, errors.New("thresh takes a threshold and at " +
+				"least one subexpression")
+		}
+		k, err := parseThreshold(args[0], len(args)-1)
+		if err != nil {
+			return nil, err
+		}
+		subs, err := parseSubs(args[1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(ctx, FragmentThresh, k, nil, nil, subs...)
+	}
+
+	combinators := map[string]struct {
+		fragment Fragment
+		numArgs  int
+	}{
+		"and_v": {FragmentAndV, 2},
+		"and_b": {FragmentAndB, 2},
+		"and_n": {FragmentAndOr, 2},
+		"andor": {FragmentAndOr, 3},
+		"or_b":  {FragmentOrB, 2},
+		"or_c":  {FragmentOrC, 2},
+		"or_d":  {FragmentOrD, 2},
+		"or_i":  {FragmentOrI, 2},
+	}
+	combinator, ok := combinators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fragment %q", name)
+	}
+	if err := numArgs(combinator.numArgs); err != nil {
+		return nil, err
+	}
+	subs, err := parseSubs(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and_n(X,Y) is a shorthand for andor(X,Y,0).
+	if name == "and_n" {
+		just0, err := newNode(ctx, FragmentJust0, 0, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, just0)
+	}
+	return newNode(ctx, combinator.fragment, 0, nil, nil, subs...)
+}
+
+// wrap applies the wrapper denoted by the passed letter to the passed node.
+func wrap(wrapper byte, n *Node) (*Node, error) {
+	ctx := n.ctx
+	switch wrapper {
+	case 'a':
+		return newNode(ctx, FragmentWrapA, 0, nil, nil, n)
+	case 's':
+		return newNode(ctx, FragmentWrapS, 0, nil, nil, n)
+	case 'c':
+		return newNode(ctx, FragmentWrapC, 0, nil, nil, n)
+	case 'd':
+		return newNode(ctx, FragmentWrapD, 0, nil, nil, n)
+	case 'v':
+		return newNode(ctx, FragmentWrapV, 0, nil, nil, n)
+	case 'j':
+		return newNode(ctx, FragmentWrapJ, 0, nil, nil, n)
+	case 'n':
+		return newNode(ctx, FragmentWrapN, 0, nil, nil, n)
+	}
+
+	// The remaining wrappers are shorthands for combinators with one of
+	// the constant fragments: t:X is and_v(X,1), l:X is or_i(0,X) and u:X
+	// is or_i(X,0).
+	if wrapper != 't' && wrapper != 'l' && wrapper != 'u' {
+		return nil, fmt.Errorf("unknown wrapper %q", wrapper)
+	}
+	constant := FragmentJust0
+	if wrapper == 't' {
+		constant = FragmentJust1
+	}
+	just, err := newNode(ctx, constant, 0, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch wrapper {
+	case 't':
+		return newNode(ctx, FragmentAndV, 0, nil, nil, n, just)
+	case 'l':
+		return newNode(ctx, FragmentOrI, 0, nil, nil, just, n)
+	}
+	return newNode(ctx, FragmentOrI, 0, nil, nil, n, just)
+}
+
+// splitArgs splits the passed comma separated arguments of an expression,
+// ignoring the commas of nested expressions.
+func splitArgs(str string) ([]string, error) {
+	var (
+		args  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses "+
+					"in %q", str)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", str)
+	}
+
+	return append(args, str[start:]), nil
+}
+
+// parseSubs parses the passed subexpressions.
+func parseSubs(args []string, ctx Context) ([]*Node, error) {
+	subs := make([]*Node, 0, len(args))
+	for _, arg := range args {
+		sub, err := parseExpr(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// parseThreshold parses the threshold of thresh, multi and multi_a
+// expressions, which must be between one and the passed number of
+// subexpressions or keys.
+func parseThreshold(str string, max int) (uint32, error) {
+	k, err := strconv.ParseUint(str, 10, 32)
+	if err != nil || k < 1 || k > uint64(max) {
+		return 0, fmt.Errorf("invalid threshold %q for %d "+
+			"subexpressions or keys", str, max)
+	}
+	return uint32(k), nil
+}
+
+// parseKey parses the passed hex encoded key, which must be a compressed
+// public key in the P2WSH context and an x-only public key in the tapscript
+// context.
+func parseKey(str string, ctx Context) ([]byte, error) {
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q: %v", str, err)
+	}
+	if err := checkKey(key, ctx); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// checkKey returns an error unless the passed key is valid in the passed
+// context.
+func checkKey(key []byte, ctx Context) error {
+	if ctx == Tapscript {
+		if len(key) != schnorr.PubKeyBytesLen {
+			return fmt.Errorf("invalid x-only key %x", key)
+		}
+		if _, err := schnorr.ParsePubKey(key); err != nil {
+			return fmt.Errorf("invalid x-only key %x: %v", key, err)
+		}
+		return nil
+	}
+
+	if len(key) != btcec.PubKeyBytesLenCompressed {
+		return fmt.Errorf("invalid compressed key %x", key)
+	}
+	if _, err := btcec.ParsePubKey(key); err != nil {
+		return fmt.Errorf("invalid compressed key %x: %v", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScriptVectors tests the encoding, decoding and resource usage of the
+// test vectors of the miniscript test suite of Bitcoin Core.
+func TestScriptVectors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr    string
+		script  string
+		typ     string
+		ops     uint32
+		stack   uint32
+		witness uint32
+	}{{
+		expr:    "lltvln:after(1231488000)",
+		script:  "6300676300676300670400046749b1926869516868",
+		typ:     "Bdumxik",
+		ops:     12,
+		stack:   3,
+		witness: 3,
+	}, {
+		expr:    "uuj:and_v(v:multi(2,03d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a,025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc),after(1231488000))",
+		script:  "6363829263522103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a21025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc52af0400046749b168670068670068",
+		typ:     "Bdsmxik",
+		ops:     14,
+		stack:   5,
+		witness: 151,
+	}, {
+		expr:    "or_b(un:multi(2,03daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee8729,024ce119c96e2fa357200b559b2f7dd5a5f02d5290aff74b03f3e471b273211c97),al:older(16))",
+		script:  "63522103daed4f2be3a8bf278e70132fb0beb7522f570e144bf615c07e996d443dee872921024ce119c96e2fa357200b559b2f7dd5a5f02d5290aff74b03f3e471b273211c9752ae926700686b63006760b2686c9b",
+		typ:     "Bduxhk",
+		ops:     14,
+		stack:   5,
+		witness: 151,
+	}, {
+		expr:    "j:and_v(vdv:after(1567547623),older(2016))",
+		script:  "829263766304e7e06e5db169686902e007b268",
+		typ:     "Bondemxhik",
+		ops:     11,
+		stack:   1,
+		witness: 2,
+	}, {
+		expr:    "t:and_v(vu:hash256(131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b),v:sha256(ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc5))",
+		script:  "6382012088aa20131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b876700686982012088a820ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc58851",
+		typ:     "Bufmxk",
+		ops:     12,
+		stack:   3,
+		witness: 68,
+	}, {
+		expr:    "t:andor(multi(3,02d7924d4f7d43ea965a465ae3095ff41131e5946f3c85f79e44adbcf8e27e080e,03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556,02e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13),v:older(4194305),v:sha256(9267d3dbed802941483f1afa2a6bc68de5f653128aca9bf1461c5d0a3ad36ed2))",
+		script:  "532102d7924d4f7d43ea965a465ae3095ff41131e5946f3c85f79e44adbcf8e27e080e2103fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a14602975562102e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd1353ae6482012088a8209267d3dbed802941483f1afa2a6bc68de5f653128aca9bf1461c5d0a3ad36ed2886703010040b2696851",
+		typ:     "Bufmxgk",
+		ops:     13,
+		stack:   5,
+		witness: 220,
+	}, {
+		expr:    "or_d(multi(1,02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9),or_b(multi(3,022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a01,032fa2104d6b38d11b0230010559879124e42ab8dfeff5ff29dc9cdadd4ecacc3f,03d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a),su:after(500000)))",
+		script:  "512102f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f951ae73645321022f01e5e15cca351daff3843fb70f3c2f0a1bdd05e5af888a67784ef3e10a2a0121032fa2104d6b38d11b0230010559879124e42ab8dfeff5ff29dc9cdadd4ecacc3f2103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a53ae7c630320a107b16700689b68",
+		typ:     "Bduemxjk",
+		ops:     15,
+		stack:   7,
+		witness: 223,
+	}, {
+		expr:    "or_d(sha256(38df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b6),and_n(un:after(499999999),older(4194305)))",
+		script:  "82012088a82038df1c1f64a24a77b23393bca50dff872e31edc4f3b5aa3b90ad0b82f4f089b68773646304ff64cd1db19267006864006703010040b26868",
+		typ:     "Bdexgjk",
+		ops:     16,
+		stack:   2,
+		witness: 35,
+	}, {
+		expr:    "pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65)",
+		script:  "2103d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65ac",
+		typ:     "Bondusemk",
+		ops:     1,
+		stack:   1,
+		witness: 73,
+	}, {
+		expr:    "pkh(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65)",
+		script:  "76a914fcd35ddacad9f2d5be5e464639441c6065e6955d88ac",
+		typ:     "Bndusemk",
+		ops:     4,
+		stack:   2,
+		witness: 107,
+	}}
+
+	for _, test := range tests {
+		n, err := Parse(test.expr, P2WSH)
+		require.NoError(t, err, test.expr)
+		require.Equal(t, test.expr, n.String())
+		require.Equal(t, test.script, hex.EncodeToString(n.Script()),
+			test.expr)
+		require.Equal(t, sortedType(test.typ), n.Type().String(),
+			test.expr)
+
+		ops, ok := n.MaxOps()
+		require.True(t, ok)
+		require.Equal(t, test.ops, ops, test.expr)
+		stack, ok := n.MaxWitnessElements()
+		require.True(t, ok)
+		require.Equal(t, test.stack, stack, test.expr)
+		witness, ok := n.MaxWitnessSize()
+		require.True(t, ok)
+		require.Equal(t, test.witness, witness, test.expr)
+
+		// The script decodes to an equivalent expression, which
+		// encodes to the same script.
+		var pkhKeys [][]byte
+		n.walk(func(node *Node) {
+			if node.fragment == FragmentPkH {
+				pkhKeys = append(pkhKeys, node.keys...)
+			}
+		})
+		decoded, err := DecodeScript(n.Script(), P2WSH, pkhKeys)
+		require.NoError(t, err, test.expr)
+		require.Equal(t, test.script,
+			hex.EncodeToString(decoded.Script()))
+		require.Equal(t, n.Type(), decoded.Type())
+	}
+}
+
+// sortedType returns the passed type letters in the order used by the string
+// representation of types.
+func sortedType(letters string) string {
+	var b strings.Builder
+	for _, letter := range typeLetters {
+		if strings.ContainsRune(letters, letter) {
+			b.WriteRune(letter)
+		}
+	}
+	return b.String()
+}
+
+// TestValidity tests the type checking rules against the validity test vectors
+// of the miniscript test suite of Bitcoin Core.
+func TestValidity(t *testing.T) {
+	t.Parallel()
+
+	const (
+		valid = 1 << iota
+		nonMalleable
+		needsSig
+		timelockMix
+	)
+	tests := []struct {
+		expr  string
+		flags int
+	}{
+		{"l:older(1)", valid | nonMalleable},
+		{"l:older(0)", 0},
+		{"l:older(2147483647)", valid | nonMalleable},
+		{"l:older(2147483648)", 0},
+		{"u:after(1)", valid | nonMalleable},
+		{"u:after(0)", 0},
+		{"u:after(2147483647)", valid | nonMalleable},
+		{"u:after(2147483648)", 0},
+		{"andor(0,1,1)", valid | nonMalleable},
+		{"andor(a:0,1,1)", 0},
+		{"andor(0,a:1,a:1)", 0},
+		{"andor(1,1,1)", 0},
+		{"andor(n:or_i(0,after(1)),1,1)", valid},
+		{"andor(or_i(0,after(1)),1,1)", 0},
+		{"c:andor(0,pk_k(03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7),pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00))", valid | nonMalleable | needsSig},
+		{"t:andor(0,v:1,v:1)", valid | nonMalleable},
+		{"and_v(v:1,1)", valid | nonMalleable},
+		{"t:and_v(v:1,v:1)", valid | nonMalleable},
+		{"c:and_v(v:1,pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00))", valid | nonMalleable | needsSig},
+		{"and_v(1,1)", 0},
+		{"and_v(pk_k(02352bbf4a4cdd12564f93fa332ce333301d9ad40271f8107181340aef25be59d5),1)", 0},
+		{"and_v(v:1,a:1)", 0},
+		{"and_b(1,a:1)", valid | nonMalleable},
+		{"and_b(1,1)", 0},
+		{"and_b(v:1,a:1)", 0},
+		{"and_b(a:1,a:1)", 0},
+		{"and_b(pk_k(025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc),a:1)", 0},
+		{"or_b(0,a:0)", valid | nonMalleable | needsSig},
+		{"or_b(1,a:0)", 0},
+		{"or_b(0,a:1)", 0},
+		{"or_b(0,0)", 0},
+		{"or_b(v:0,a:0)", 0},
+		{"or_b(a:0,a:0)", 0},
+		{"or_b(pk_k(025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc),a:0)", 0},
+		{"t:or_c(0,v:1)", valid | nonMalleable},
+		{"t:or_c(a:0,v:1)", 0},
+		{"t:or_c(1,v:1)", 0},
+		{"t:or_c(n:or_i(0,after(1)),v:1)", valid},
+		{"t:or_c(or_i(0,after(1)),v:1)", 0},
+		{"t:or_c(0,1)", 0},
+		{"or_d(0,1)", valid | nonMalleable},
+		{"or_d(a:0,1)", 0},
+		{"or_d(1,1)", 0},
+		{"or_d(n:or_i(0,after(1)),1)", valid},
+		{"or_d(or_i(0,after(1)),1)", 0},
+		{"or_d(0,v:1)", 0},
+		{"or_i(1,1)", valid},
+		{"t:or_i(v:1,v:1)", valid},
+		{"c:or_i(pk_k(03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7),pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00))", valid | nonMalleable | needsSig},
+		{"or_i(a:1,a:1)", 0},
+		{"or_b(l:after(100),al:after(1000000000))", valid},
+		{"and_b(after(100),a:after(1000000000))", valid | nonMalleable | timelockMix},
+		{"thresh(2,after(100),a:after(1000000000))", 0},
+		{"thresh(1,c:pk_k(03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7),sc:pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00))", valid | nonMalleable | needsSig},
+		{"thresh(2,c:pk_k(03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7),sc:pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00),sln:older(144))", valid | nonMalleable | needsSig},
+		{"thresh(3,c:pk_k(03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7),sc:pk_k(036d2b085e9e382ed10b69fc311a03f8641ccfff21574de0927513a49d9a688a00),sln:older(4194305),sln:older(144))", valid | nonMalleable | needsSig | timelockMix},
+	}
+
+	for _, test := range tests {
+		n, err := Parse(test.expr, P2WSH)
+		if test.flags&valid == 0 {
+			require.Error(t, err, test.expr)
+			continue
+		}
+		require.NoError(t, err, test.expr)
+		require.Equal(t, test.flags&nonMalleable != 0,
+			n.IsNonMalleable(), test.expr)
+		require.Equal(t, test.flags&needsSig != 0, n.NeedsSignature(),
+			test.expr)
+		require.Equal(t, test.flags&timelockMix != 0,
+			n.HasTimelockMix(), test.expr)
+	}
+}
+
+// TestTapscript tests the differences of the tapscript context, which has
+// x-only keys, multi_a instead of multi and a d: wrapper with the u property.
+func TestTapscript(t *testing.T) {
+	t.Parallel()
+
+	const (
+		key1 = "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+		key2 = "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"
+		key3 = "e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13"
+	)
+
+	expr := "multi_a(2," + key1 + "," + key2 + "," + key3 + ")"
+	n, err := Parse(expr, Tapscript)
+	require.NoError(t, err)
+	require.Equal(t, "20"+key1+"ac20"+key2+"ba20"+key3+"ba529c",
+		hex.EncodeToString(n.Script()))
+	require.Equal(t, sortedType("Budemsk"), n.Type().String())
+	require.NoError(t, n.SanityCheck())
+	witness, ok := n.MaxWitnessSize()
+	require.True(t, ok)
+	require.EqualValues(t, 2*66+1, witness)
+
+	decoded, err := DecodeScript(n.Script(), Tapscript, nil)
+	require.NoError(t, err)
+	require.Equal(t, expr, decoded.String())
+
+	// The fragments and keys of one context are invalid in the other.
+	_, err = Parse(expr, P2WSH)
+	require.Error(t, err)
+	_, err = DecodeScript(n.Script(), P2WSH, nil)
+	require.Error(t, err)
+	_, err = Parse("multi(1,"+key1+")", Tapscript)
+	require.Error(t, err)
+	_, err = Parse("pk(02"+key1+")", Tapscript)
+	require.Error(t, err)
+	_, err = Parse("pk("+key1+")", P2WSH)
+	require.Error(t, err)
+
+	// Only tapscript makes d: wrappers of type u, which is required by
+	// the subexpressions of thresh.
+	expr = "thresh(1,pk(" + key1 + "),sdv:older(144))"
+	_, err = Parse(expr, Tapscript)
+	require.NoError(t, err)
+	_, err = Parse(strings.ReplaceAll(
+		expr, key1, "02"+key1,
+	), P2WSH)
+	require.Error(t, err)
+}
+
+// TestParseErrors tests that malformed expressions are rejected.
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	const key = "03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65"
+	tests := []string{
+		"",
+		"pk(" + key,
+		"pk(" + key + "))",
+		"pk(" + key + ",)",
+		":pk(" + key + ")",
+		"x:pk(" + key + ")",
+		"pk_x(" + key + ")",
+		"pk(" + key[:64] + ")",
+		"pk(04" + key[2:] + ")",
+		"older(1,2)",
+		"older(-1)",
+		"older(one)",
+		"sha256(00)",
+		"hash160(" + strings.Repeat("00", 32) + ")",
+		"multi(0," + key + ")",
+		"multi(2," + key + ")",
+		"multi(1)",
+		"thresh(2,pk(" + key + "))",
+		"and_v(v:pk(" + key + "))",
+		"andor(pk(" + key + "),1)",
+		"v:pk(" + key + ")",
+		"pk_k(" + key + ")",
+	}
+	for _, expr := range tests {
+		_, err := Parse(expr, P2WSH)
+		require.Error(t, err, expr)
+	}
+
+	// The encoding of multi is limited to 20 keys.
+	keys := make([]string, 0, txscript.MaxPubKeysPerMultiSig+1)
+	for i := 0; i <= txscript.MaxPubKeysPerMultiSig; i++ {
+		keys = append(keys, key)
+	}
+	_, err := Parse("multi(1,"+strings.Join(keys, ",")+")", P2WSH)
+	require.Error(t, err)
+}
+
+// TestDecodeScriptErrors tests that scripts which aren't the minimal encoding
+// of a valid miniscript expression are rejected.
+func TestDecodeScriptErrors(t *testing.T) {
+	t.Parallel()
+
+	const key = "03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65"
+	keyBytes, err := hex.DecodeString(key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		script string
+	}{{
+		name:   "empty",
+		script: "",
+	}, {
+		name:   "unfused verify",
+		script: "21" + key + "ac6951",
+	}, {
+		name:   "non-minimal number",
+		script: "21" + key + "ad03000100b2",
+	}, {
+		name:   "trailing opcode",
+		script: "21" + key + "ac75",
+	}, {
+		name:   "key type",
+		script: "21" + key,
+	}, {
+		name:   "not top level",
+		script: "21" + key + "ad",
+	}, {
+		name:   "malformed push",
+		script: "21" + key[:10],
+	}, {
+		name:   "unknown key hash",
+		script: "76a914" + strings.Repeat("00", 20) + "88ac",
+	}}
+
+	for _, test := range tests {
+		script, err := hex.DecodeString(test.script)
+		require.NoError(t, err)
+		_, err = DecodeScript(script, P2WSH, [][]byte{keyBytes})
+		require.Error(t, err, test.name)
+	}
+
+	// The minimal encoding of the non-minimal number is accepted.
+	script, err := hex.DecodeString("21" + key + "ad020001b2")
+	require.NoError(t, err)
+	n, err := DecodeScript(script, P2WSH, nil)
+	require.NoError(t, err)
+	require.Equal(t, "and_v(v:pk("+key+"),older(256))", n.String())
+}
+
+// TestSanityCheck tests that expressions which are valid but not sane are
+// detected.
+func TestSanityCheck(t *testing.T) {
+	t.Parallel()
+
+	const (
+		key1 = "03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65"
+		key2 = "03a0434d9e47f3c86235477c7b1ae6ae5d3442d49b1943c2b752a68e2a47e247c7"
+	)
+	tests := []struct {
+		expr string
+		sane bool
+	}{
+		{"or_d(pk(" + key1 + "),and_v(v:pk(" + key2 + "),older(144)))", true},
+		{"or_d(pk(" + key1 + "),and_v(v:pk(" + key1 + "),older(144)))", false},
+		{"or_i(pk(" + key1 + "),older(144))", false},
+		{"or_b(pk(" + key1 + "),s:pk(" + key2 + "))", true},
+		{"and_v(v:sha256(" + strings.Repeat("00", 32) + "),pk(" + key1 + "))", true},
+		{"or_d(sha256(" + strings.Repeat("00", 32) + "),pk(" + key1 + "))", false},
+		{"and_v(v:pk(" + key1 + "),and_b(after(100),a:after(1000000000)))", false},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.expr, P2WSH)
+		require.NoError(t, err, test.expr)
+		if test.sane {
+			require.NoError(t, n.SanityCheck(), test.expr)
+		} else {
+			require.Error(t, n.SanityCheck(), test.expr)
+		}
+	}
+
+	// A thresh of many keys exceeds the opcode limit of P2WSH, but not of
+	// tapscript.
+	const xOnlyKey = "d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65"
+	thresh := func(key string) string {
+		subs := []string{"pk(" + key + ")"}
+		for i := 0; i < 70; i++ {
+			subs = append(subs, "s:pk("+key+")")
+		}
+		return "thresh(70," + strings.Join(subs, ",") + ")"
+	}
+	n, err := Parse(thresh(key1), P2WSH)
+	require.NoError(t, err)
+	err = n.SanityCheck()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "opcodes")
+
+	n, err = Parse(thresh(xOnlyKey), Tapscript)
+	require.NoError(t, err)
+	err = n.SanityCheck()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "more than once")
+}
@@ -0,0 +1,490 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	// ErrNotSatisfiable is returned when an expression can't be satisfied
+	// with the signatures, preimages and timelocks provided.
+	ErrNotSatisfiable = errors.New("expression is not satisfiable")
+
+	// ErrMalleableSatisfaction is returned when a non-malleable
+	// satisfaction is requested, but only malleable satisfactions are
+	// available.
+	ErrMalleableSatisfaction = errors.New("no non-malleable satisfaction " +
+		"is available")
+)
+
+// Satisfier provides the signatures, preimages and timelocks available to
+// satisfy an expression.
+type Satisfier interface {
+	// Signature returns the signature for the passed key, including the
+	// sighash type, or false if it isn't available.
+	Signature(key []byte) ([]byte, bool)
+
+	// Preimage returns the preimage of the passed hash of the passed hash
+	// fragment, or false if it isn't available.
+	Preimage(fragment Fragment, hash []byte) ([]byte, bool)
+
+	// CheckOlder returns whether the relative timelock of an older
+	// expression is satisfied by the spending input.
+	CheckOlder(n uint32) bool
+
+	// CheckAfter returns whether the absolute timelock of an after
+	// expression is satisfied by the spending transaction.
+	CheckAfter(n uint32) bool
+}
+
+// WitnessData is a Satisfier backed by known signatures and preimages as well
+// as the timelocks of the spending transaction.
+type WitnessData struct {
+	// Signatures maps the hex encoded keys to their signatures, including
+	// the sighash type.
+	Signatures map[string][]byte
+
+	// Preimages are the known preimages, which are matched against the
+	// hashes of all hash fragments.
+	Preimages [][]byte
+
+	// Sequence is the sequence number of the spending input.
+	Sequence uint32
+
+	// LockTime is the lock time of the spending transaction.
+	LockTime uint32
+}
+
+// A compile-time assertion to ensure WitnessData implements the Satisfier
+// interface.
+var _ Satisfier = (*WitnessData)(nil)
+
+// Signature returns the signature for the passed key.
+//
+// This is part of the Satisfier interface.
+func (w *WitnessData) Signature(key []byte) ([]byte, bool) {
+	sig, ok := w.Signatures[hex.EncodeToString(key)]
+	return sig, ok
+}
+
+// Preimage returns the preimage of the passed hash.
+//
+// This is part of the Satisfier interface.
+func (w *WitnessData) Preimage(fragment Fragment, hash []byte) ([]byte,
+	bool) {
+
+	for _, preimage := range w.Preimages {
+		if len(preimage) == 32 &&
+			bytes.Equal(hashPreimage(fragment, preimage), hash) {
+
+			return preimage, true
+		}
+	}
+	return nil, false
+}
+
+// CheckOlder returns whether the sequence number of the spending input
+// satisfies the relative timelock as defined in BIP 112.
+//
+// This is part of the Satisfier interface.
+func (w *WitnessData) CheckOlder(n uint32) bool {
+	const typeMask = wire.SequenceLockTimeIsSeconds
+
+	if w.Sequence&wire.SequenceLockTimeDisabled != 0 ||
+		w.Sequence&typeMask != n&typeMask {
+
+		return false
+	}
+	return w.Sequence&wire.SequenceLockTimeMask >=
+		n&wire.SequenceLockTimeMask
+}
+
+// CheckAfter returns whether the lock time of the spending transaction
+// satisfies the absolute timelock as defined in BIP 65.
+//
+// This is part of the Satisfier interface.
+func (w *WitnessData) CheckAfter(n uint32) bool {
+	if w.Sequence == wire.MaxTxInSequenceNum ||
+		(w.LockTime < txscript.LockTimeThreshold) !=
+			(n < txscript.LockTimeThreshold) {
+
+		return false
+	}
+	return w.LockTime >= n
+}
+
+// hashPreimage returns the hash of the passed preimage computed by the passed
+// hash fragment.
+func hashPreimage(fragment Fragment, preimage []byte) []byte {
+	switch fragment {
+	case FragmentSha256:
+		hash := sha256.Sum256(preimage)
+		return hash[:]
+
+	case FragmentHash256:
+		return chainhash.DoubleHashB(preimage)
+
+	case FragmentRipemd160:
+		hasher := ripemd160.New()
+		hasher.Write(preimage)
+		return hasher.Sum(nil)
+
+	case FragmentHash160:
+		return btcutil.Hash160(preimage)
+	}
+
+	return nil
+}
+
+// witnessStack is a candidate satisfaction or dissatisfaction of an
+// expression along with the properties needed to choose between candidates.
+// The last element of the stack is the top of the stack.
+type witnessStack struct {
+	available bool
+	hasSig    bool
+	malleable bool
+	size      int
+	stack     [][]byte
+}
+
+// stackOf returns the available witness stack consisting of the passed
+// elements.
+func stackOf(elements ...[]byte) witnessStack {
+	w := witnessStack{available: true, stack: elements}
+	for _, element := range elements {
+		w.size += len(element) + 1
+	}
+	return w
+}
+
+var (
+	// emptyStack is the available witness stack without elements.
+	emptyStack = stackOf()
+
+	// unavailable is the witness stack of satisfactions and
+	// dissatisfactions which aren't available.
+	unavailable = witnessStack{}
+)
+
+// zero returns the witness stack consisting of an empty element.
+func zero() witnessStack {
+	return stackOf([]byte{})
+}
+
+// one returns the witness stack consisting of the element one.
+func one() witnessStack {
+	return stackOf([]byte{1})
+}
+
+// withSig returns the witness stack marked as containing a signature.
+func (w witnessStack) withSig() witnessStack {
+	w.hasSig = true
+	return w
+}
+
+// withMalleable returns the witness stack marked as malleable if the passed
+// condition holds.
+func (w witnessStack) withMalleable(malleable bool) witnessStack {
+	w.malleable = w.malleable || malleable
+	return w
+}
+
+// add returns the concatenation of the passed witness stacks, with the
+// elements of the second one on top.
+func (w witnessStack) add(other witnessStack) witnessStack {
+	if !w.available || !other.available {
+		return unavailable
+	}
+
+	stack := make([][]byte, 0, len(w.stack)+len(other.stack))
+	stack = append(stack, w.stack...)
+	stack = append(stack, other.stack...)
+	return witnessStack{
+		available: true,
+		hasSig:    w.hasSig || other.hasSig,
+		malleable: w.malleable || other.malleable,
+		size:      w.size + other.size,
+		stack:     stack,
+	}
+}
+
+// or returns the preferred one of the passed alternative witness stacks.
+func (w witnessStack) or(other witnessStack) witnessStack {
+	switch {
+	case !w.available:
+		return other
+	case !other.available:
+		return w
+
+	// A third party could always replace an alternative requiring a
+	// signature by one which doesn't, so the latter must be used.
+	case !w.hasSig && other.hasSig:
+		return w
+	case w.hasSig && !other.hasSig:
+		return other
+
+	// If neither alternative requires a signature, a third party could
+	// replace either by the other one.
+	case !w.hasSig && !other.hasSig:
+		w.malleable = true
+		other.malleable = true
+
+	// If both alternatives require a signature, the non-malleable one is
+	// preferred.
+	case other.malleable && !w.malleable:
+		return w
+	case w.malleable && !other.malleable:
+		return other
+	}
+
+	if w.size <= other.size {
+		return w
+	}
+	return other
+}
+
+// satisfactions are the preferred satisfaction and dissatisfaction of an
+// expression.
+type satisfactions struct {
+	sat  witnessStack
+	dsat witnessStack
+}
+
+// Satisfy returns the witness stack satisfying the expression using the
+// signatures, preimages and timelocks provided by the passed satisfier.  The
+// witness script, and the control block in the tapscript context, have to be
+// appended to the returned stack to spend an output.
+//
+// If nonMalleable is set, an error is returned unless the returned witness
+// requires a signature and can't be modified by third parties.  Otherwise the
+// smallest available satisfaction is returned.
+func (n *Node) Satisfy(s Satisfier, nonMalleable bool) ([][]byte, error) {
+	sat := n.satisfy(s).sat
+	if !sat.available {
+		return nil, ErrNotSatisfiable
+	}
+	if nonMalleable && (sat.malleable || !sat.hasSig) {
+		return nil, ErrMalleableSatisfaction
+	}
+
+	return sat.stack, nil
+}
+
+// satisfy returns the satisfactions of the node using the data provided by the
+// passed satisfier.
+func (n *Node) satisfy(s Satisfier) satisfactions {
+	subs := make([]satisfactions, 0, len(n.subs))
+	for _, sub := range n.subs {
+		subs = append(subs, sub.satisfy(s))
+	}
+	var x, y, z satisfactions
+	if len(subs) > 0 {
+		x = subs[0]
+	}
+	if len(subs) > 1 {
+		y = subs[1]
+	}
+	if len(subs) > 2 {
+		z = subs[2]
+	}
+
+	// sigStack returns the witness stack consisting of the signature for
+	// the passed key, if available.
+	sigStack := func(key []byte) witnessStack {
+		sig, ok := s.Signature(key)
+		if !ok {
+			return unavailable
+		}
+		return stackOf(sig).withSig()
+	}
+
+	switch n.fragment {
+	case FragmentJust0:
+		return satisfactions{sat: unavailable, dsat: emptyStack}
+
+	case FragmentJust1:
+		return satisfactions{sat: emptyStack, dsat: unavailable}
+
+	case FragmentPkK:
+		return satisfactions{sat: sigStack(n.keys[0]), dsat: zero()}
+
+	case FragmentPkH:
+		key := stackOf(n.keys[0])
+		return satisfactions{
+			sat:  sigStack(n.keys[0]).add(key),
+			dsat: zero().add(key),
+		}
+
+	case FragmentOlder, FragmentAfter:
+		sat := unavailable
+		if n.fragment == FragmentOlder && s.CheckOlder(n.k) ||
+			n.fragment == FragmentAfter && s.CheckAfter(n.k) {
+
+			sat = emptyStack
+		}
+		return satisfactions{sat: sat, dsat: unavailable}
+
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		// Any 32 byte value which isn't the preimage dissatisfies the
+		// expression, so the dissatisfaction is malleable.
+		sat := unavailable
+		if preimage, ok := s.Preimage(n.fragment, n.data); ok {
+			sat = stackOf(preimage)
+		}
+		dsat := stackOf(make([]byte, 32)).withMalleable(true)
+		return satisfactions{sat: sat, dsat: dsat}
+
+	case FragmentWrapA, FragmentWrapS, FragmentWrapC, FragmentWrapN:
+		return x
+
+	case FragmentWrapD:
+		return satisfactions{sat: x.sat.add(one()), dsat: zero()}
+
+	case FragmentWrapV:
+		return satisfactions{sat: x.sat, dsat: unavailable}
+
+	case FragmentWrapJ:
+		// A dissatisfaction of the subexpression without a signature
+		// results in a malleable dissatisfaction, since it may be
+		// used instead of the empty element.
+		dsat := zero().withMalleable(
+			x.dsat.available && !x.dsat.hasSig,
+		)
+		return satisfactions{sat: x.sat, dsat: dsat}
+
+	case FragmentAndV:
+		return satisfactions{
+			sat:  y.sat.add(x.sat),
+			dsat: y.dsat.add(x.sat),
+		}
+
+	case FragmentAndB:
+		return satisfactions{
+			sat: y.sat.add(x.sat),
+			dsat: y.dsat.add(x.dsat).
+				or(y.sat.add(x.dsat).withMalleable(true)).
+				or(y.dsat.add(x.sat).withMalleable(true)),
+		}
+
+	case FragmentOrB:
+		return satisfactions{
+			sat: y.dsat.add(x.sat).
+				or(y.sat.add(x.dsat)).
+				or(y.sat.add(x.sat).withMalleable(true)),
+			dsat: y.dsat.add(x.dsat),
+		}
+
+	case FragmentOrC:
+		return satisfactions{
+			sat:  x.sat.or(y.sat.add(x.dsat)),
+			dsat: unavailable,
+		}
+
+	case FragmentOrD:
+		return satisfactions{
+			sat:  x.sat.or(y.sat.add(x.dsat)),
+			dsat: y.dsat.add(x.dsat),
+		}
+
+	case FragmentOrI:
+		return satisfactions{
+			sat:  x.sat.add(one()).or(y.sat.add(zero())),
+			dsat: x.dsat.add(one()).or(y.dsat.add(zero())),
+		}
+
+	case FragmentAndOr:
+		return satisfactions{
+			sat:  y.sat.add(x.sat).or(z.sat.add(x.dsat)),
+			dsat: y.dsat.add(x.sat).or(z.dsat.add(x.dsat)),
+		}
+
+	case FragmentMulti:
+		// sats[i] is the best satisfaction with i signatures for the
+		// keys processed so far, preceded by the dummy element.  The
+		// signatures are in the order of their keys.
+		sats := []witnessStack{zero()}
+		for _, key := range n.keys {
+			sig := sigStack(key)
+			next := make([]witnessStack, 0, len(sats)+1)
+			next = append(next, sats[0])
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].or(sats[j-1].add(sig)))
+			}
+			next = append(next, sats[len(sats)-1].add(sig))
+			sats = next
+		}
+
+		dsat := zero()
+		for i := uint32(0); i < n.k; i++ {
+			dsat = dsat.add(zero())
+		}
+		return satisfactions{sat: sats[n.k], dsat: dsat}
+
+	case FragmentMultiA:
+		// sats[i] is the best satisfaction with i signatures for the
+		// keys processed so far, with an empty element for each of the
+		// other keys.  The keys are processed in reverse order, since
+		// the signature for the first key is checked first.
+		sats := []witnessStack{emptyStack}
+		for i := len(n.keys) - 1; i >= 0; i-- {
+			sig := sigStack(n.keys[i])
+			next := make([]witnessStack, 0, len(sats)+1)
+			next = append(next, sats[0].add(zero()))
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(zero()).or(
+					sats[j-1].add(sig),
+				))
+			}
+			next = append(next, sats[len(sats)-1].add(sig))
+			sats = next
+		}
+		return satisfactions{sat: sats[n.k], dsat: sats[0]}
+
+	case FragmentThresh:
+		// sats[i] is the best witness satisfying i of the
+		// subexpressions processed so far and dissatisfying the
+		// others.  The subexpressions are processed in reverse order,
+		// since the witness of the first one is on top of the stack.
+		sats := []witnessStack{emptyStack}
+		for i := len(subs) - 1; i >= 0; i-- {
+			sub := subs[i]
+			next := make([]witnessStack, 0, len(sats)+1)
+			next = append(next, sats[0].add(sub.dsat))
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(sub.dsat).or(
+					sats[j-1].add(sub.sat),
+				))
+			}
+			next = append(next, sats[len(sats)-1].add(sub.sat))
+			sats = next
+		}
+
+		// Satisfying any other number of subexpressions than the
+		// threshold dissatisfies the expression, but only satisfying
+		// none of them isn't malleable.
+		dsat := unavailable
+		for i, sat := range sats {
+			if uint32(i) == n.k {
+				continue
+			}
+			dsat = dsat.or(sat.withMalleable(i != 0))
+		}
+		return satisfactions{sat: sats[n.k], dsat: dsat}
+	}
+
+	return satisfactions{sat: unavailable, dsat: unavailable}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns the private key of the passed test key index.
+func testKey(i byte) *btcec.PrivateKey {
+	privKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{i}, 32))
+	return privKey
+}
+
+// spendTx returns a transaction with a single input with the passed sequence
+// number and the passed lock time.
+func spendTx(sequence, lockTime uint32) *wire.MsgTx {
+	return &wire.MsgTx{
+		Version: 2,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+			Sequence:         sequence,
+		}},
+		TxOut:    []*wire.TxOut{{Value: 90_000}},
+		LockTime: lockTime,
+	}
+}
+
+// witnessSize returns the size of the passed witness stack elements, including
+// their length prefixes.
+func witnessSize(stack [][]byte) uint32 {
+	var size uint32
+	for _, element := range stack {
+		size += uint32(len(element)) + 1
+	}
+	return size
+}
+
+// TestSatisfyP2WSH tests that the satisfactions of a P2WSH expression are
+// valid witnesses for the script engine.
+func TestSatisfyP2WSH(t *testing.T) {
+	t.Parallel()
+
+	const amount = 100_000
+	privKeyA, privKeyB := testKey(1), testKey(2)
+	expr := fmt.Sprintf("or_d(pk(%x),and_v(v:pk(%x),older(144)))",
+		privKeyA.PubKey().SerializeCompressed(),
+		privKeyB.PubKey().SerializeCompressed())
+	n, err := Parse(expr, P2WSH)
+	require.NoError(t, err)
+	require.NoError(t, n.SanityCheck())
+
+	witnessScript := n.Script()
+	scriptHash := sha256.Sum256(witnessScript)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(scriptHash[:]).Script()
+	require.NoError(t, err)
+	maxWitnessSize, ok := n.MaxWitnessSize()
+	require.True(t, ok)
+
+	tests := []struct {
+		name     string
+		keys     []*btcec.PrivateKey
+		sequence uint32
+		err      error
+		witness  int
+	}{{
+		name:     "first key",
+		keys:     []*btcec.PrivateKey{privKeyA},
+		sequence: wire.MaxTxInSequenceNum,
+		witness:  1,
+	}, {
+		name:     "second key after timelock",
+		keys:     []*btcec.PrivateKey{privKeyB},
+		sequence: 144,
+		witness:  2,
+	}, {
+		name:     "second key before timelock",
+		keys:     []*btcec.PrivateKey{privKeyB},
+		sequence: 143,
+		err:      ErrNotSatisfiable,
+	}, {
+		name:     "both keys",
+		keys:     []*btcec.PrivateKey{privKeyA, privKeyB},
+		sequence: 144,
+		witness:  1,
+	}, {
+		name:     "no keys",
+		sequence: 144,
+		err:      ErrNotSatisfiable,
+	}}
+
+	for _, test := range tests {
+		tx := spendTx(test.sequence, 0)
+		prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+			pkScript, amount,
+		)
+		sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+
+		data := &WitnessData{
+			Signatures: make(map[string][]byte),
+			Sequence:   test.sequence,
+		}
+		for _, key := range test.keys {
+			sig, err := txscript.RawTxInWitnessSignature(
+				tx, sigHashes, 0, amount, witnessScript,
+				txscript.SigHashAll, key,
+			)
+			require.NoError(t, err)
+			pubKey := key.PubKey().SerializeCompressed()
+			data.Signatures[hex.EncodeToString(pubKey)] = sig
+		}
+
+		stack, err := n.Satisfy(data, true)
+		require.Equal(t, test.err, err, test.name)
+		if test.err != nil {
+			continue
+		}
+		require.Len(t, stack, test.witness, test.name)
+		require.LessOrEqual(t, witnessSize(stack), maxWitnessSize)
+
+		tx.TxIn[0].Witness = append(stack, witnessScript)
+		vm, err := txscript.NewEngine(
+			pkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+			sigHashes, amount, prevOutFetcher,
+		)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), test.name)
+	}
+}
+
+// TestSatisfyTapscript tests that the satisfactions of a tapscript expression
+// are valid witnesses for the script engine.
+func TestSatisfyTapscript(t *testing.T) {
+	t.Parallel()
+
+	const amount = 100_000
+	privKeys := []*btcec.PrivateKey{testKey(1), testKey(2), testKey(3)}
+	preimage := bytes.Repeat([]byte{0x42}, 32)
+	hash := sha256.Sum256(preimage)
+	expr := fmt.Sprintf("and_v(v:multi_a(2,%x,%x,%x),sha256(%x))",
+		schnorr.SerializePubKey(privKeys[0].PubKey()),
+		schnorr.SerializePubKey(privKeys[1].PubKey()),
+		schnorr.SerializePubKey(privKeys[2].PubKey()), hash)
+	n, err := Parse(expr, Tapscript)
+	require.NoError(t, err)
+	require.NoError(t, n.SanityCheck())
+
+	internalKey := testKey(4).PubKey()
+	leaf := txscript.NewBaseTapLeaf(n.Script())
+	tree := txscript.AssembleTaprootScriptTree(leaf)
+	rootHash := tree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_1).
+		AddData(schnorr.SerializePubKey(outputKey)).Script()
+	require.NoError(t, err)
+	ctrlBlock := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+	ctrlBlockBytes, err := ctrlBlock.ToBytes()
+	require.NoError(t, err)
+	maxWitnessSize, ok := n.MaxWitnessSize()
+	require.True(t, ok)
+
+	tests := []struct {
+		name      string
+		keys      []int
+		preimages [][]byte
+		err       error
+	}{{
+		name:      "first and last key",
+		keys:      []int{0, 2},
+		preimages: [][]byte{preimage},
+	}, {
+		name:      "all keys",
+		keys:      []int{0, 1, 2},
+		preimages: [][]byte{preimage},
+	}, {
+		name:      "one key",
+		keys:      []int{1},
+		preimages: [][]byte{preimage},
+		err:       ErrNotSatisfiable,
+	}, {
+		name:      "wrong preimage",
+		keys:      []int{0, 1},
+		preimages: [][]byte{bytes.Repeat([]byte{0x43}, 32)},
+		err:       ErrNotSatisfiable,
+	}}
+
+	for _, test := range tests {
+		tx := spendTx(wire.MaxTxInSequenceNum, 0)
+		prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+			pkScript, amount,
+		)
+		sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+
+		data := &WitnessData{
+			Signatures: make(map[string][]byte),
+			Preimages:  test.preimages,
+		}
+		for _, i := range test.keys {
+			sig, err := txscript.RawTxInTapscriptSignature(
+				tx, sigHashes, 0, amount, pkScript, leaf,
+				txscript.SigHashDefault, privKeys[i],
+			)
+			require.NoError(t, err)
+			pubKey := schnorr.SerializePubKey(privKeys[i].PubKey())
+			data.Signatures[hex.EncodeToString(pubKey)] = sig
+		}
+
+		stack, err := n.Satisfy(data, true)
+		require.Equal(t, test.err, err, test.name)
+		if test.err != nil {
+			continue
+		}
+
+		// The stack consists of the preimage and an element for each
+		// key of the multi_a.
+		require.Len(t, stack, 4, test.name)
+		require.LessOrEqual(t, witnessSize(stack), maxWitnessSize)
+
+		tx.TxIn[0].Witness = append(stack, leaf.Script, ctrlBlockBytes)
+		vm, err := txscript.NewEngine(
+			pkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+			sigHashes, amount, prevOutFetcher,
+		)
+		require.NoError(t, err)
+		require.NoError(t, vm.Execute(), test.name)
+	}
+}
+
+// TestSatisfyMalleability tests that satisfactions which could be modified by
+// third parties are only returned when malleable satisfactions are allowed.
+func TestSatisfyMalleability(t *testing.T) {
+	t.Parallel()
+
+	pubKey := testKey(1).PubKey().SerializeCompressed()
+	n, err := Parse(fmt.Sprintf("or_i(pk(%x),after(100))", pubKey), P2WSH)
+	require.NoError(t, err)
+	require.True(t, n.IsNonMalleable())
+	require.False(t, n.NeedsSignature())
+
+	// The timelock path doesn't require a signature, so a third party
+	// could spend the output with the same witness in another
+	// transaction.
+	data := &WitnessData{LockTime: 100}
+	_, err = n.Satisfy(data, true)
+	require.Equal(t, ErrMalleableSatisfaction, err)
+	stack, err := n.Satisfy(data, false)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{}}, stack)
+
+	// The timelock is not satisfied with a lock time of another kind.
+	data.LockTime = txscript.LockTimeThreshold + 100
+	_, err = n.Satisfy(data, false)
+	require.Equal(t, ErrNotSatisfiable, err)
+
+	// Given a signature, the timelock path is still preferred since a
+	// third party could replace the signature path with it anyway.
+	sig := bytes.Repeat([]byte{1}, 72)
+	data = &WitnessData{
+		Signatures: map[string][]byte{hex.EncodeToString(pubKey): sig},
+		LockTime:   100,
+	}
+	stack, err = n.Satisfy(data, false)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{}}, stack)
+
+	// Without the timelock, only the signature path is available.
+	data.LockTime = 99
+	stack, err = n.Satisfy(data, true)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{sig, {1}}, stack)
+}
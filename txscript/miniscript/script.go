@@ -0,0 +1,728 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// hashOpcodes are the opcodes computing the hashes of the hash fragments.
+var hashOpcodes = map[Fragment]byte{
+	FragmentSha256:    txscript.OP_SHA256,
+	FragmentHash256:   txscript.OP_HASH256,
+	FragmentRipemd160: txscript.OP_RIPEMD160,
+	FragmentHash160:   txscript.OP_HASH160,
+}
+
+// appendPush appends the canonical push of the passed data to the script.
+func appendPush(script, data []byte) []byte {
+	// The pushed keys and hashes are far below the size limits of the
+	// builder, so it can't fail.
+	push, _ := txscript.NewScriptBuilder().AddData(data).Script()
+	return append(script, push...)
+}
+
+// appendInt appends the canonical push of the passed number to the script.
+func appendInt(script []byte, val uint32) []byte {
+	push, _ := txscript.NewScriptBuilder().AddInt64(int64(val)).Script()
+	return append(script, push...)
+}
+
+// Script returns the script encoding of the expression.
+func (n *Node) Script() []byte {
+	return n.appendScript(nil, false)
+}
+
+// scriptSize returns the size of the script encoding of the expression.
+func (n *Node) scriptSize() int {
+	return len(n.Script())
+}
+
+// appendScript appends the encoding of the node to the passed script.  Verify
+// specifies whether the node is followed by an OP_VERIFY, which is fused into
+// its last opcode if possible.
+func (n *Node) appendScript(script []byte, verify bool) []byte {
+	// verifyOp returns the verifying variant of the passed opcode if the
+	// node is verified.
+	verifyOp := func(opcode, verifyOpcode byte) byte {
+		if verify {
+			return verifyOpcode
+		}
+		return opcode
+	}
+
+	switch n.fragment {
+	case FragmentJust0:
+		return append(script, txscript.OP_0)
+
+	case FragmentJust1:
+		return append(script, txscript.OP_1)
+
+	case FragmentPkK:
+		return appendPush(script, n.keys[0])
+
+	case FragmentPkH:
+		script = append(script, txscript.OP_DUP, txscript.OP_HASH160)
+		script = appendPush(script, btcutil.Hash160(n.keys[0]))
+		return append(script, txscript.OP_EQUALVERIFY)
+
+	case FragmentOlder:
+		script = appendInt(script, n.k)
+		return append(script, txscript.OP_CHECKSEQUENCEVERIFY)
+
+	case FragmentAfter:
+		script = appendInt(script, n.k)
+		return append(script, txscript.OP_CHECKLOCKTIMEVERIFY)
+
+	case FragmentSha256, FragmentHash256, FragmentRipemd160,
+		FragmentHash160:
+
+		// The size of the preimage is checked to prevent
+		// malleability.
+		script = append(script, txscript.OP_SIZE)
+		script = appendInt(script, 32)
+		script = append(script, txscript.OP_EQUALVERIFY,
+			hashOpcodes[n.fragment])
+		script = appendPush(script, n.data)
+		return append(script, verifyOp(
+			txscript.OP_EQUAL, txscript.OP_EQUALVERIFY,
+		))
+
+	case FragmentWrapA:
+		script = append(script, txscript.OP_TOALTSTACK)
+		script = n.subs[0].appendScript(script, false)
+		return append(script, txscript.OP_FROMALTSTACK)
+
+	case FragmentWrapS:
+		script = append(script, txscript.OP_SWAP)
+		return n.subs[0].appendScript(script, verify)
+
+	case FragmentWrapC:
+		script = n.subs[0].appendScript(script, false)
+		return append(script, verifyOp(
+			txscript.OP_CHECKSIG, txscript.OP_CHECKSIGVERIFY,
+		))
+
+	case FragmentWrapD:
+		script = append(script, txscript.OP_DUP, txscript.OP_IF)
+		script = n.subs[0].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentWrapV:
+		script = n.subs[0].appendScript(script, true)
+		if n.subs[0].typ.Has(PropX) {
+			script = append(script, txscript.OP_VERIFY)
+		}
+		return script
+
+	case FragmentWrapJ:
+		script = append(script, txscript.OP_SIZE,
+			txscript.OP_0NOTEQUAL, txscript.OP_IF)
+		script = n.subs[0].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentWrapN:
+		script = n.subs[0].appendScript(script, false)
+		return append(script, txscript.OP_0NOTEQUAL)
+
+	case FragmentAndV:
+		script = n.subs[0].appendScript(script, false)
+		return n.subs[1].appendScript(script, verify)
+
+	case FragmentAndB:
+		script = n.subs[0].appendScript(script, false)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_BOOLAND)
+
+	case FragmentOrB:
+		script = n.subs[0].appendScript(script, false)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_BOOLOR)
+
+	case FragmentOrC:
+		script = n.subs[0].appendScript(script, false)
+		script = append(script, txscript.OP_NOTIF)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentOrD:
+		script = n.subs[0].appendScript(script, false)
+		script = append(script, txscript.OP_IFDUP, txscript.OP_NOTIF)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentOrI:
+		script = append(script, txscript.OP_IF)
+		script = n.subs[0].appendScript(script, false)
+		script = append(script, txscript.OP_ELSE)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentAndOr:
+		script = n.subs[0].appendScript(script, false)
+		script = append(script, txscript.OP_NOTIF)
+		script = n.subs[2].appendScript(script, false)
+		script = append(script, txscript.OP_ELSE)
+		script = n.subs[1].appendScript(script, false)
+		return append(script, txscript.OP_ENDIF)
+
+	case FragmentMulti:
+		script = appendInt(script, n.k)
+		for _, key := range n.keys {
+			script = appendPush(script, key)
+		}
+		script = appendInt(script, uint32(len(n.keys)))
+		return append(script, verifyOp(
+			txscript.OP_CHECKMULTISIG,
+			txscript.OP_CHECKMULTISIGVERIFY,
+		))
+
+	case FragmentMultiA:
+		for i, key := range n.keys {
+			script = appendPush(script, key)
+			if i == 0 {
+				script = append(script, txscript.OP_CHECKSIG)
+			} else {
+				script = append(script, txscript.OP_CHECKSIGADD)
+			}
+		}
+		script = appendInt(script, n.k)
+		return append(script, verifyOp(
+			txscript.OP_NUMEQUAL, txscript.OP_NUMEQUALVERIFY,
+		))
+
+	case FragmentThresh:
+		for i, sub := range n.subs {
+			script = sub.appendScript(script, false)
+			if i > 0 {
+				script = append(script, txscript.OP_ADD)
+			}
+		}
+		script = appendInt(script, n.k)
+		return append(script, verifyOp(
+			txscript.OP_EQUAL, txscript.OP_EQUALVERIFY,
+		))
+	}
+
+	return script
+}
+
+// token is a single opcode of a script along with its data.
+type token struct {
+	opcode byte
+	data   []byte
+}
+
+// fusedVerifyOpcodes maps the opcodes with a fused verification to the
+// opcodes they verify.
+var fusedVerifyOpcodes = map[byte]byte{
+	txscript.OP_EQUALVERIFY:         txscript.OP_EQUAL,
+	txscript.OP_CHECKSIGVERIFY:      txscript.OP_CHECKSIG,
+	txscript.OP_CHECKMULTISIGVERIFY: txscript.OP_CHECKMULTISIG,
+	txscript.OP_NUMEQUALVERIFY:      txscript.OP_NUMEQUAL,
+}
+
+// decoder decodes a miniscript expression from the tokens of a script, which
+// are processed in reverse order since the last opcodes of an expression
+// identify it.
+type decoder struct {
+	ctx Context
+
+	// tokens are the tokens of the script in reverse order, with opcodes
+	// with a fused verification split into the opcode they verify
+	// followed by an OP_VERIFY.
+	tokens []token
+	pos    int
+
+	// keys maps the hashes of the keys of pk_h expressions to the keys.
+	keys map[string][]byte
+}
+
+// DecodeScript decodes the miniscript expression encoded by the passed script
+// in the passed context.  Since the script only commits to the hashes of the
+// keys of pk_h expressions, their keys have to be passed.  An error is
+// returned if the script is not the encoding of a valid top level miniscript
+// expression.
+func DecodeScript(script []byte, ctx Context, pkhKeys [][]byte) (*Node,
+	error) {
+
+	maxSize := maxP2WSHScriptSize
+	if ctx == Tapscript {
+		maxSize = maxTapscriptSize
+	}
+	if len(script) > maxSize {
+		return nil, fmt.Errorf("script size %d exceeds the maximum of "+
+			"%d", len(script), maxSize)
+	}
+
+	d := &decoder{ctx: ctx, keys: make(map[string][]byte)}
+	for _, key := range pkhKeys {
+		d.keys[string(btcutil.Hash160(key))] = key
+	}
+
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		opcode := tokenizer.Opcode()
+		if verified, ok := fusedVerifyOpcodes[opcode]; ok {
+			d.tokens = append(d.tokens, token{opcode: verified})
+			opcode = txscript.OP_VERIFY
+		}
+		d.tokens = append(d.tokens, token{
+			opcode: opcode,
+			data:   tokenizer.Data(),
+		})
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(d.tokens)-1; i < j; i, j = i+1, j-1 {
+		d.tokens[i], d.tokens[j] = d.tokens[j], d.tokens[i]
+	}
+
+	n, err := d.decodeBKV()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.tokens) {
+		return nil, errors.New("script contains opcodes which are not " +
+			"part of the expression")
+	}
+	if !n.IsValidTopLevel() {
+		return nil, fmt.Errorf("%s is not a valid top level expression "+
+			"of type %s", n, n.typ)
+	}
+
+	// Any script decoding to an expression differs from its encoding if
+	// it uses non-minimal pushes or an explicit OP_VERIFY which could be
+	// fused.
+	if !bytes.Equal(n.Script(), script) {
+		return nil, errors.New("script is not the minimal encoding of " +
+			"the expression")
+	}
+
+	return n, nil
+}
+
+// peek returns the opcode of the token the passed offset after the current
+// one.  The returned opcode is OP_INVALIDOPCODE if there is no such token.
+func (d *decoder) peek(offset int) byte {
+	if d.pos+offset >= len(d.tokens) {
+		return txscript.OP_INVALIDOPCODE
+	}
+	return d.tokens[d.pos+offset].opcode
+}
+
+// peekData returns the data of the token the passed offset after the current
+// one, or nil if there is no such token.
+func (d *decoder) peekData(offset int) []byte {
+	if d.pos+offset >= len(d.tokens) {
+		return nil
+	}
+	return d.tokens[d.pos+offset].data
+}
+
+// peekNumber returns the number pushed by the token the passed offset after
+// the current one.  False is returned if the token doesn't push a number.
+func (d *decoder) peekNumber(offset int) (int64, bool) {
+	opcode := d.peek(offset)
+	switch {
+	case opcode == txscript.OP_0:
+		return 0, true
+
+	case opcode >= txscript.OP_1 && opcode <= txscript.OP_16:
+		return int64(opcode - (txscript.OP_1 - 1)), true
+
+	case opcode >= txscript.OP_DATA_1 && opcode <= txscript.OP_DATA_5:
+		// Numbers are encoded in little endian with the sign being
+		// the most significant bit.  Non-minimal encodings are
+		// rejected once the expression is encoded again.
+		data := d.peekData(offset)
+		var num int64
+		for i, b := range data {
+			num |= int64(b) << (8 * uint(i))
+		}
+		if data[len(data)-1]&0x80 != 0 {
+			num &^= 0x80 << (8 * uint(len(data)-1))
+			num = -num
+		}
+		return num, true
+	}
+
+	return 0, false
+}
+
+// expect consumes the current token if it is the passed opcode and returns an
+// error otherwise.
+func (d *decoder) expect(opcode byte) error {
+	if d.peek(0) != opcode {
+		return fmt.Errorf("expected opcode 0x%02x at opcode %d "+
+			"from the end", opcode, d.pos)
+	}
+	d.pos++
+	return nil
+}
+
+// node returns a new node of the decoded fragment and arguments.
+func (d *decoder) node(fragment Fragment, k uint32, keys [][]byte,
+	data []byte, subs ...*Node) (*Node, error) {
+
+	return newNode(d.ctx, fragment, k, keys, data, subs...)
+}
+
+// decodeBKV decodes an expression of type B, K or V, which may be a sequence
+// of expressions joined by and_v.
+func (d *decoder) decodeBKV() (*Node, error) {
+	n, err := d.decodeSingle()
+	if err != nil {
+		return nil, err
+	}
+
+	// An expression preceded by another one is the second subexpression
+	// of an and_v, unless it is the start of a branch, the subexpression
+	// of the a: wrapper or the second subexpression of a combinator.
+	switch d.peek(0) {
+	case txscript.OP_INVALIDOPCODE, txscript.OP_IF, txscript.OP_NOTIF,
+		txscript.OP_ELSE, txscript.OP_TOALTSTACK, txscript.OP_SWAP:
+
+		return n, nil
+	}
+	x, err := d.decodeBKV()
+	if err != nil {
+		return nil, err
+	}
+	return d.node(FragmentAndV, 0, nil, nil, x, n)
+}
+
+// decodeW decodes an expression of type W, which is either an a: or an s:
+// wrapper.
+func (d *decoder) decodeW() (*Node, error) {
+	if d.peek(0) == txscript.OP_FROMALTSTACK {
+		d.pos++
+		sub, err := d.decodeBKV()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.expect(txscript.OP_TOALTSTACK); err != nil {
+			return nil, err
+		}
+		return d.node(FragmentWrapA, 0, nil, nil, sub)
+	}
+
+	sub, err := d.decodeSingle()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.expect(txscript.OP_SWAP); err != nil {
+		return nil, err
+	}
+	return d.node(FragmentWrapS, 0, nil, nil, sub)
+}
+
+// decodeWrapped decodes the subexpression of a wrapper identified by the
+// current token and returns the wrapper.
+func (d *decoder) decodeWrapped(fragment Fragment) (*Node, error) {
+	d.pos++
+	sub, err := d.decodeSingle()
+	if err != nil {
+		return nil, err
+	}
+	return d.node(fragment, 0, nil, nil, sub)
+}
+
+// decodeSingle decodes a single expression, which isn't a sequence of
+// expressions joined by and_v.
+func (d *decoder) decodeSingle() (*Node, error) {
+	keySize := 33
+	if d.ctx == Tapscript {
+		keySize = 32
+	}
+
+	switch opcode := d.peek(0); opcode {
+	case txscript.OP_INVALIDOPCODE:
+		return nil, errors.New("unexpected end of script")
+
+	case txscript.OP_0:
+		d.pos++
+		return d.node(FragmentJust0, 0, nil, nil)
+
+	case txscript.OP_1:
+		d.pos++
+		return d.node(FragmentJust1, 0, nil, nil)
+
+	case txscript.OP_DATA_32, txscript.OP_DATA_33:
+		key := d.peekData(0)
+		if len(key) != keySize {
+			return nil, fmt.Errorf("unexpected push of %d bytes",
+				len(key))
+		}
+		if err := checkKey(key, d.ctx); err != nil {
+			return nil, err
+		}
+		d.pos++
+		return d.node(FragmentPkK, 0, [][]byte{key}, nil)
+
+	case txscript.OP_VERIFY:
+		// DUP HASH160 <hash> EQUALVERIFY is pk_h, which is the only
+		// fragment containing an OP_VERIFY.
+		hash := d.peekData(2)
+		if d.peek(1) == txscript.OP_EQUAL && len(hash) == 20 &&
+			d.peek(3) == txscript.OP_HASH160 &&
+			d.peek(4) == txscript.OP_DUP {
+
+			key, ok := d.keys[string(hash)]
+			if !ok {
+				return nil, fmt.Errorf("unknown key hash %x",
+					hash)
+			}
+			d.pos += 5
+			return d.node(FragmentPkH, 0, [][]byte{key}, nil)
+		}
+		return d.decodeWrapped(FragmentWrapV)
+
+	case txscript.OP_CHECKSEQUENCEVERIFY, txscript.OP_CHECKLOCKTIMEVERIFY:
+		k, ok := d.peekNumber(1)
+		if !ok || k < 1 || k >= 1<<31 {
+			return nil, errors.New("invalid timelock")
+		}
+		d.pos += 2
+
+		fragment := FragmentOlder
+		if opcode == txscript.OP_CHECKLOCKTIMEVERIFY {
+			fragment = FragmentAfter
+		}
+		return d.node(fragment, uint32(k), nil, nil)
+
+	case txscript.OP_EQUAL:
+		// SIZE <32> EQUALVERIFY <hash opcode> <hash> EQUAL is a hash
+		// fragment.
+		hash := d.peekData(1)
+		preimageSize, _ := d.peekNumber(5)
+		if len(hash) > 0 && d.peek(3) == txscript.OP_VERIFY &&
+			d.peek(4) == txscript.OP_EQUAL && preimageSize == 32 &&
+			d.peek(6) == txscript.OP_SIZE {
+
+			for fragment, hashOpcode := range hashOpcodes {
+				if d.peek(2) == hashOpcode &&
+					len(hash) == hashSize(fragment) {
+
+					d.pos += 7
+					return d.node(fragment, 0, nil, hash)
+				}
+			}
+		}
+
+		// Otherwise it is the end of a thresh, which is followed by
+		// the first subexpression and the remaining ones, each
+		// followed by an OP_ADD.
+		k, ok := d.peekNumber(1)
+		if !ok {
+			return nil, errors.New("unexpected OP_EQUAL")
+		}
+		d.pos += 2
+
+		var subs []*Node
+		for d.peek(0) == txscript.OP_ADD {
+			d.pos++
+			sub, err := d.decodeW()
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, sub)
+		}
+		first, err := d.decodeSingle()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, first)
+		reverseNodes(subs)
+		if k < 1 || k > int64(len(subs)) {
+			return nil, fmt.Errorf("invalid threshold %d for %d "+
+				"subexpressions", k, len(subs))
+		}
+		return d.node(FragmentThresh, uint32(k), nil, nil, subs...)
+
+	case txscript.OP_CHECKMULTISIG:
+		if d.ctx != P2WSH {
+			return nil, errors.New("multi is only available in " +
+				"the P2WSH context")
+		}
+		numKeys, ok := d.peekNumber(1)
+		if !ok || numKeys < 1 ||
+			numKeys > txscript.MaxPubKeysPerMultiSig {
+
+			return nil, errors.New("invalid number of keys")
+		}
+		keys := make([][]byte, 0, numKeys)
+		for i := 0; i < int(numKeys); i++ {
+			key := d.peekData(2 + i)
+			if len(key) != keySize {
+				return nil, errors.New("invalid multi key")
+			}
+			if err := checkKey(key, d.ctx); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		reverseKeys(keys)
+		k, ok := d.peekNumber(2 + int(numKeys))
+		if !ok || k < 1 || k > numKeys {
+			return nil, errors.New("invalid multi threshold")
+		}
+		d.pos += 3 + int(numKeys)
+		return d.node(FragmentMulti, uint32(k), keys, nil)
+
+	case txscript.OP_NUMEQUAL:
+		if d.ctx != Tapscript {
+			return nil, errors.New("multi_a is only available in " +
+				"the tapscript context")
+		}
+		k, ok := d.peekNumber(1)
+		if !ok {
+			return nil, errors.New("unexpected OP_NUMEQUAL")
+		}
+		d.pos += 2
+
+		// Each key is followed by an OP_CHECKSIGADD except for the
+		// first one, which is followed by an OP_CHECKSIG.
+		var keys [][]byte
+		for {
+			opcode := d.peek(0)
+			key := d.peekData(1)
+			if opcode != txscript.OP_CHECKSIGADD &&
+				opcode != txscript.OP_CHECKSIG ||
+				len(key) != keySize {
+
+				return nil, errors.New("invalid multi_a key")
+			}
+			if err := checkKey(key, d.ctx); err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			d.pos += 2
+			if opcode == txscript.OP_CHECKSIG {
+				break
+			}
+		}
+		reverseKeys(keys)
+		if len(keys) > maxMultiAKeys || k < 1 ||
+			k > int64(len(keys)) {
+
+			return nil, errors.New("invalid multi_a threshold")
+		}
+		return d.node(FragmentMultiA, uint32(k), keys, nil)
+
+	case txscript.OP_CHECKSIG:
+		return d.decodeWrapped(FragmentWrapC)
+
+	case txscript.OP_0NOTEQUAL:
+		return d.decodeWrapped(FragmentWrapN)
+
+	case txscript.OP_BOOLAND, txscript.OP_BOOLOR:
+		d.pos++
+		y, err := d.decodeW()
+		if err != nil {
+			return nil, err
+		}
+		x, err := d.decodeSingle()
+		if err != nil {
+			return nil, err
+		}
+
+		fragment := FragmentAndB
+		if opcode == txscript.OP_BOOLOR {
+			fragment = FragmentOrB
+		}
+		return d.node(fragment, 0, nil, nil, x, y)
+
+	case txscript.OP_ENDIF:
+		d.pos++
+		return d.decodeEndIf()
+	}
+
+	return nil, fmt.Errorf("unexpected opcode 0x%02x at opcode %d from "+
+		"the end", d.peek(0), d.pos)
+}
+
+// decodeEndIf decodes a conditional expression ending with the OP_ENDIF which
+// was just consumed.
+func (d *decoder) decodeEndIf() (*Node, error) {
+	last, err := d.decodeBKV()
+	if err != nil {
+		return nil, err
+	}
+
+	switch d.peek(0) {
+	case txscript.OP_ELSE:
+		// IF X ELSE Z ENDIF is or_i and X NOTIF Z ELSE Y ENDIF is
+		// andor.
+		d.pos++
+		first, err := d.decodeBKV()
+		if err != nil {
+			return nil, err
+		}
+		switch d.peek(0) {
+		case txscript.OP_IF:
+			d.pos++
+			return d.node(FragmentOrI, 0, nil, nil, first, last)
+
+		case txscript.OP_NOTIF:
+			d.pos++
+			x, err := d.decodeSingle()
+			if err != nil {
+				return nil, err
+			}
+			return d.node(FragmentAndOr, 0, nil, nil, x, last, first)
+		}
+
+	case txscript.OP_IF:
+		// DUP IF X ENDIF is d:X and SIZE 0NOTEQUAL IF X ENDIF is j:X.
+		switch {
+		case d.peek(1) == txscript.OP_DUP:
+			d.pos += 2
+			return d.node(FragmentWrapD, 0, nil, nil, last)
+
+		case d.peek(1) == txscript.OP_0NOTEQUAL &&
+			d.peek(2) == txscript.OP_SIZE:
+
+			d.pos += 3
+			return d.node(FragmentWrapJ, 0, nil, nil, last)
+		}
+
+	case txscript.OP_NOTIF:
+		// X IFDUP NOTIF Z ENDIF is or_d and X NOTIF Z ENDIF is or_c.
+		d.pos++
+		fragment := FragmentOrC
+		if d.peek(0) == txscript.OP_IFDUP {
+			d.pos++
+			fragment = FragmentOrD
+		}
+		x, err := d.decodeSingle()
+		if err != nil {
+			return nil, err
+		}
+		return d.node(fragment, 0, nil, nil, x, last)
+	}
+
+	return nil, errors.New("unexpected conditional")
+}
+
+// reverseNodes reverses the order of the passed nodes in place.
+func reverseNodes(nodes []*Node) {
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+}
+
+// reverseKeys reverses the order of the passed keys in place.
+func reverseKeys(keys [][]byte) {
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miniscript
+
+import (
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Type is the type of a miniscript expression, which consists of exactly one
+// of the basic types B, V, K and W along with any number of type properties.
+// The zero value denotes an invalid expression.
+type Type uint32
+
+const (
+	// TypeB is the base type of expressions which push a nonzero value
+	// when satisfied and an exact zero when dissatisfied.
+	TypeB Type = 1 << iota
+
+	// TypeV is the verify type of expressions which continue execution
+	// when satisfied and abort it otherwise.
+	TypeV
+
+	// TypeK is the key type of expressions which push a public key that
+	// a signature has to be checked against.
+	TypeK
+
+	// TypeW is the wrapped type of expressions which take their inputs
+	// from one below the top of the stack.
+	TypeW
+
+	// PropZ denotes expressions which consume exactly zero stack elements.
+	PropZ
+
+	// PropO denotes expressions which consume exactly one stack element.
+	PropO
+
+	// PropN denotes expressions whose satisfactions never require the top
+	// stack element to be zero.
+	PropN
+
+	// PropD denotes expressions which have a dissatisfaction that does not
+	// require a signature.
+	PropD
+
+	// PropU denotes expressions which push exactly one when satisfied.
+	PropU
+
+	// PropE denotes expressions with a unique dissatisfaction, which does
+	// not require a signature, and for which every other dissatisfaction
+	// requires a signature.
+	PropE
+
+	// PropF denotes forced expressions, which have no dissatisfactions
+	// that don't require a signature.
+	PropF
+
+	// PropS denotes safe expressions, whose satisfactions all require a
+	// signature.
+	PropS
+
+	// PropM denotes non-malleable expressions, which always have a
+	// non-malleable satisfaction.
+	PropM
+
+	// PropX denotes expressions whose last opcode is not EQUAL,
+	// CHECKSIG, CHECKMULTISIG or NUMEQUAL and thus need an explicit
+	// OP_VERIFY when verified.
+	PropX
+
+	// PropG denotes expressions containing a relative time timelock.
+	PropG
+
+	// PropH denotes expressions containing a relative height timelock.
+	PropH
+
+	// PropI denotes expressions containing an absolute time timelock.
+	PropI
+
+	// PropJ denotes expressions containing an absolute height timelock.
+	PropJ
+
+	// PropK denotes expressions which do not contain a combination of
+	// height and time timelocks.
+	PropK
+)
+
+// typeLetters are the letters denoting the basic types and type properties in
+// the order of the bits of Type.
+const typeLetters = "BVKWzonduefsmxghijk"
+
+// Has returns whether the type has all of the passed basic types and type
+// properties.
+func (t Type) Has(props Type) bool {
+	return t&props == props
+}
+
+// String returns the letters of the basic type and type properties of the
+// type, such as "Bdemsu".
+func (t Type) String() string {
+	var b strings.Builder
+	for i := range typeLetters {
+		if t&(1<<uint(i)) != 0 {
+			b.WriteByte(typeLetters[i])
+		}
+	}
+	return b.String()
+}
+
+// typeIf returns the passed type if the condition holds, and the zero type
+// otherwise.
+func typeIf(cond bool, t Type) Type {
+	if cond {
+		return t
+	}
+	return 0
+}
+
+// timelocksCombinable returns whether the timelocks of the passed types can be
+// satisfied together, which is not the case if one of them uses height and the
+// other time.
+func timelocksCombinable(x, y Type) bool {
+	return !(x.Has(PropG) && y.Has(PropH) || x.Has(PropH) && y.Has(PropG) ||
+		x.Has(PropI) && y.Has(PropJ) || x.Has(PropJ) && y.Has(PropI))
+}
+
+// timelockProps are the properties describing the timelocks an expression
+// contains.
+const timelockProps = PropG | PropH | PropI | PropJ
+
+// computeType returns the type of the passed node given the types of its
+// subexpressions, which have already been computed.  The zero type is returned
+// if the node is invalid.
+func computeType(n *Node) Type {
+	var x, y, z Type
+	if len(n.subs) > 0 {
+		x = n.subs[0].typ
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].typ
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].typ
+	}
+
+	switch n.fragment {
+	case FragmentPkK:
+		return TypeK | PropO | PropN | PropU | PropD | PropE | PropM |
+			PropS | PropX | PropK
+
+	case FragmentPkH:
+		return TypeK | PropN | PropU | PropD | PropE | PropM | PropS |
+			PropX | PropK
+
+	case FragmentOlder:
+		timeBased := n.k&wire.SequenceLockTimeIsSeconds != 0
+		return typeIf(timeBased, PropG) | typeIf(!timeBased, PropH) |
+			TypeB | PropZ | PropF | PropM | PropX | PropK
+
+	case FragmentAfter:
+		timeBased := n.k >= txscript.LockTimeThreshold
+		return typeIf(timeBased, PropI) | typeIf(!timeBased, PropJ) |
+			TypeB | PropZ | PropF | PropM | PropX | PropK
+
+	case FragmentSha256, FragmentRipemd160, FragmentHash256,
+		FragmentHash160:
+
+		return TypeB | PropO | PropN | PropU | PropD | PropM | PropK
+
+	case FragmentJust1:
+		return TypeB | PropZ | PropU | PropF | PropM | PropX | PropK
+
+	case FragmentJust0:
+		return TypeB | PropZ | PropU | PropD | PropE | PropM | PropS |
+			PropX | PropK
+
+	case FragmentWrapA:
+		return typeIf(x.Has(TypeB), TypeW) |
+			x&(timelockProps|PropK) |
+			x&(PropU|PropD|PropF|PropE|PropM|PropS) |
+			PropX
+
+	case FragmentWrapS:
+		return typeIf(x.Has(TypeB|PropO), TypeW) |
+			x&(timelockProps|PropK) |
+			x&(PropU|PropD|PropF|PropE|PropM|PropS|PropX)
+
+	case FragmentWrapC:
+		return typeIf(x.Has(TypeK), TypeB) |
+			x&(timelockProps|PropK) |
+			x&(PropO|PropN|PropD|PropF|PropE|PropM) |
+			PropU | PropS
+
+	case FragmentWrapD:
+		// The d: wrapper only has the u property in tapscript, where
+		// MINIMALIF is a consensus rule, but not in P2WSH where it is
+		// only a policy rule.
+		return typeIf(x.Has(TypeV|PropZ), TypeB) |
+			typeIf(x.Has(PropZ), PropO) |
+			typeIf(x.Has(PropF), PropE) |
+			x&(timelockProps|PropK) |
+			x&(PropM|PropS) |
+			typeIf(n.ctx == Tapscript, PropU) |
+			PropN | PropD | PropX
+
+	case FragmentWrapV:
+		return typeIf(x.Has(TypeB), TypeV) |
+			x&(timelockProps|PropK) |
+			x&(PropZ|PropO|PropN|PropM|PropS) |
+			PropF | PropX
+
+	case FragmentWrapJ:
+		return typeIf(x.Has(TypeB|PropN), TypeB) |
+			typeIf(x.Has(PropF), PropE) |
+			x&(timelockProps|PropK) |
+			x&(PropO|PropU|PropM|PropS) |
+			PropN | PropD | PropX
+
+	case FragmentWrapN:
+		return x&(timelockProps|PropK) |
+			x&(TypeB|PropZ|PropO|PropN|PropD|PropF|PropE|PropM|PropS) |
+			PropU | PropX
+
+	case FragmentAndV:
+		return typeIf(x.Has(TypeV), y&(TypeK|TypeV|TypeB)) |
+			x&PropN | typeIf(x.Has(PropZ), y&PropN) |
+			typeIf((x|y).Has(PropZ), (x|y)&PropO) |
+			x&y&(PropD|PropM|PropZ) |
+			(x|y)&PropS |
+			typeIf(y.Has(PropF) || x.Has(PropS), PropF) |
+			y&(PropU|PropX) |
+			(x|y)&timelockProps |
+			typeIf((x&y).Has(PropK) && timelocksCombinable(x, y),
+				PropK)
+
+	case FragmentAndB:
+		return typeIf(y.Has(TypeW), x&TypeB) |
+			typeIf((x|y).Has(PropZ), (x|y)&PropO) |
+			x&PropN | typeIf(x.Has(PropZ), y&PropN) |
+			typeIf((x&y).Has(PropS), x&y&PropE) |
+			x&y&(PropD|PropZ|PropM) |
+			typeIf((x&y).Has(PropF) || x.Has(PropS|PropF) ||
+				y.Has(PropS|PropF), PropF) |
+			(x|y)&PropS |
+			PropU | PropX |
+			(x|y)&timelockProps |
+			typeIf((x&y).Has(PropK) && timelocksCombinable(x, y),
+				PropK)
+
+	case FragmentOrB:
+		return typeIf(x.Has(TypeB|PropD) && y.Has(TypeW|PropD), TypeB) |
+			typeIf((x|y).Has(PropZ), (x|y)&PropO) |
+			typeIf((x|y).Has(PropS) && (x&y).Has(PropE),
+				x&y&PropM) |
+			x&y&(PropZ|PropS|PropE) |
+			PropD | PropU | PropX |
+			(x|y)&timelockProps |
+			x&y&PropK
+
+	case FragmentOrD:
+		return typeIf(x.Has(TypeB|PropD|PropU), y&TypeB) |
+			typeIf(y.Has(PropZ), x&PropO) |
+			typeIf(x.Has(PropE) && (x|y).Has(PropS), x&y&PropM) |
+			x&y&(PropZ|PropS) |
+			y&(PropU|PropF|PropD|PropE) |
+			PropX |
+			(x|y)&timelockProps |
+			x&y&PropK
+
+	case FragmentOrC:
+		return typeIf(x.Has(TypeB|PropD|PropU), y&TypeV) |
+			typeIf(y.Has(PropZ), x&PropO) |
+			typeIf(x.Has(PropE) && (x|y).Has(PropS), x&y&PropM) |
+			x&y&(PropZ|PropS) |
+			PropF | PropX |
+			(x|y)&timelockProps |
+			x&y&PropK
+
+	case FragmentOrI:
+		return x&y&(TypeV|TypeB|TypeK|PropU|PropF|PropS) |
+			typeIf((x&y).Has(PropZ), PropO) |
+			typeIf((x|y).Has(PropF), (x|y)&PropE) |
+			typeIf((x|y).Has(PropS), x&y&PropM) |
+			(x|y)&PropD |
+			PropX |
+			(x|y)&timelockProps |
+			x&y&PropK
+
+	case FragmentAndOr:
+		return typeIf(x.Has(TypeB|PropD|PropU),
+			y&z&(TypeB|TypeK|TypeV)) |
+			x&y&z&PropZ |
+			typeIf((x|(y&z)).Has(PropZ), (x|(y&z))&PropO) |
+			y&z&PropU |
+			typeIf(x.Has(PropS) || y.Has(PropF), z&PropF) |
+			z&PropD |
+			typeIf(x.Has(PropS) || y.Has(PropF), z&PropE) |
+			typeIf(x.Has(PropE) && (x|y|z).Has(PropS),
+				x&y&z&PropM) |
+			z&(x|y)&PropS |
+			PropX |
+			(x|y|z)&timelockProps |
+			typeIf((x&y&z).Has(PropK) && timelocksCombinable(x, y),
+				PropK)
+
+	case FragmentMulti:
+		return TypeB | PropN | PropU | PropD | PropE | PropM | PropS |
+			PropK
+
+	case FragmentMultiA:
+		return TypeB | PropU | PropD | PropE | PropM | PropS | PropK
+
+	case FragmentThresh:
+		var (
+			allE, allM = true, true
+			args, numS uint32
+			acc        = PropK
+		)
+		for i, sub := range n.subs {
+			t := sub.typ
+
+			// The first subexpression must be Bdu and all others
+			// Wdu.
+			required := TypeW | PropD | PropU
+			if i == 0 {
+				required = TypeB | PropD | PropU
+			}
+			if !t.Has(required) {
+				return 0
+			}
+
+			if !t.Has(PropE) {
+				allE = false
+			}
+			if !t.Has(PropM) {
+				allM = false
+			}
+			if t.Has(PropS) {
+				numS++
+			}
+			switch {
+			case t.Has(PropZ):
+			case t.Has(PropO):
+				args++
+			default:
+				args += 2
+			}
+
+			// The threshold contains a combination of timelocks if
+			// more than one subexpression is required and two of
+			// them contain different kinds of timelocks.
+			acc = (acc|t)&timelockProps |
+				typeIf((acc&t).Has(PropK) && (n.k <= 1 ||
+					timelocksCombinable(acc, t)), PropK)
+		}
+		numSubs := uint32(len(n.subs))
+
+		return TypeB | PropD | PropU |
+			typeIf(args == 0, PropZ) |
+			typeIf(args == 1, PropO) |
+			typeIf(allE && numS == numSubs, PropE) |
+			typeIf(allE && allM && numS >= numSubs-n.k, PropM) |
+			typeIf(numS >= numSubs-n.k+1, PropS) |
+			acc
+	}
+
+	return 0
+}
+
+// sanitizeType returns the passed type if it has exactly one basic type and no
+// conflicting properties, and the zero type otherwise.
+func sanitizeType(t Type) Type {
+	var numTypes int
+	for _, basic := range []Type{TypeB, TypeV, TypeK, TypeW} {
+		if t.Has(basic) {
+			numTypes++
+		}
+	}
+	if numTypes != 1 {
+		return 0
+	}
+
+	// The type rules never produce properties which conflict with each
+	// other or lack the properties they imply, so such a type indicates
+	// an invalid expression as well.
+	switch {
+	case t.Has(PropZ | PropO), t.Has(PropN | PropZ), t.Has(PropN | TypeW),
+		t.Has(TypeV | PropD), t.Has(TypeV | PropU), t.Has(PropE | PropF),
+		t.Has(TypeV | PropE), t.Has(PropD | PropF):
+
+		return 0
+
+	case t.Has(TypeK) && !t.Has(PropU|PropS),
+		t.Has(PropE) && !t.Has(PropD),
+		t.Has(TypeV) && !t.Has(PropF),
+		t.Has(PropZ) && !t.Has(PropM):
+
+		return 0
+	}
+
+	return t
+}
@@ -5,7 +5,9 @@
 package txscript
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
@@ -95,7 +97,7 @@ func RawTxInTaprootSignature(tx *wire.MsgTx, sigHashes *TxSigHashes, idx int,
 
 	// If this is sighash default, then we can just return the signature
 	// directly.
-	if hashType&SigHashDefault == SigHashDefault {
+	if hashType == SigHashDefault {
 		return sig, nil
 	}
 
@@ -306,6 +308,10 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 	case NullDataTy:
 		return nil, class, nil, 0,
 			errors.New("can't sign NULLDATA transactions")
+	case WitnessV1TaprootTy:
+		return nil, class, nil, 0,
+			errors.New("taproot outputs must be signed with " +
+				"SignTaprootOutput")
 	default:
 		return nil, class, nil, 0,
 			errors.New("can't sign unknown transactions")
@@ -545,6 +551,10 @@ func (sc ScriptClosure) GetScript(address btcutil.Address) ([]byte, error) {
 // will be merged in a type-dependent manner with the newly generated.
 // signature script.
 //
+// Taproot outputs can't be signed with this function since their witness
+// depends on the previous outputs of all inputs, so SignTaprootOutput must be
+// used for them instead.
+//
 // NOTE: This function is only valid for version 0 scripts.  Since the function
 // does not accept a script version, the results are undefined for other script
 // versions.
@@ -580,3 +590,341 @@ func SignTxOutput(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 		addresses, nrequired, sigScript, previousScript)
 	return mergedScript, nil
 }
+
+// TaprootKeyDB is an interface type provided to SignTaprootOutput, it
+// encapsulates any user state required to get the private keys for the public
+// keys of a taproot output.  The public keys are parsed from their x-only
+// encoding and thus always have an even y coordinate, so implementations should
+// compare them by their schnorr.SerializePubKey encoding.  A nil private key
+// and no error are returned for keys which are not available.
+type TaprootKeyDB interface {
+	GetTaprootKey(*btcec.PublicKey) (*btcec.PrivateKey, error)
+}
+
+// TaprootKeyClosure implements TaprootKeyDB with a closure.
+type TaprootKeyClosure func(*btcec.PublicKey) (*btcec.PrivateKey, error)
+
+// GetTaprootKey implements TaprootKeyDB by returning the result of calling the
+// closure.
+func (kc TaprootKeyClosure) GetTaprootKey(pubKey *btcec.PublicKey) (*btcec.PrivateKey, error) {
+	return kc(pubKey)
+}
+
+// TaprootScriptDB is an interface type provided to SignTaprootOutput, it
+// encapsulates any user state required to get the internal key and the
+// tapscript tree committed to by a taproot address.  A nil tree is returned
+// for outputs which only commit to their internal key as specified in BIP 86.
+type TaprootScriptDB interface {
+	GetTaprootScriptTree(*btcutil.AddressTaproot) (*btcec.PublicKey,
+		*IndexedTapScriptTree, error)
+}
+
+// TaprootScriptClosure implements TaprootScriptDB with a closure.
+type TaprootScriptClosure func(*btcutil.AddressTaproot) (*btcec.PublicKey,
+	*IndexedTapScriptTree, error)
+
+// GetTaprootScriptTree implements TaprootScriptDB by returning the result of
+// calling the closure.
+func (sc TaprootScriptClosure) GetTaprootScriptTree(
+	address *btcutil.AddressTaproot) (*btcec.PublicKey,
+	*IndexedTapScriptTree, error) {
+
+	return sc(address)
+}
+
+// extractTapscriptSigners extracts the x-only public keys and the number of
+// required signatures from the passed tapscript leaf script if it is either a
+// single key script or a multisig script built with OP_CHECKSIGADD as
+// specified in BIP 342.  The returned flag is false for other scripts.
+func extractTapscriptSigners(script []byte) ([][]byte, int, bool) {
+	// A single key script is of the form:
+	//  <32-byte pubkey> OP_CHECKSIG
+	if len(script) == 34 && script[0] == OP_DATA_32 &&
+		script[33] == OP_CHECKSIG {
+
+		return [][]byte{script[1:33]}, 1, true
+	}
+
+	// A multisig script is of the form:
+	//  PUBKEY OP_CHECKSIG PUBKEY OP_CHECKSIGADD ... NUM_SIGS OP_NUMEQUAL
+	//
+	// Fail fast if the script doesn't end with OP_NUMEQUAL.
+	if len(script) < 2 || script[len(script)-1] != OP_NUMEQUAL {
+		return nil, 0, false
+	}
+
+	// The script starts with a series of public keys, each of which is
+	// followed by OP_CHECKSIG for the first key and by OP_CHECKSIGADD for
+	// all others.
+	var pubKeys [][]byte
+	tokenizer := MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		data := tokenizer.Data()
+		if len(data) != schnorr.PubKeyBytesLen {
+			break
+		}
+
+		checkSigOp := byte(OP_CHECKSIGADD)
+		if len(pubKeys) == 0 {
+			checkSigOp = OP_CHECKSIG
+		}
+		if !tokenizer.Next() || tokenizer.Opcode() != checkSigOp {
+			return nil, 0, false
+		}
+		pubKeys = append(pubKeys, data)
+	}
+	if tokenizer.Err() != nil || len(pubKeys) == 0 {
+		return nil, 0, false
+	}
+
+	// The public keys are followed by the number of required signatures,
+	// which is either a small integer or a minimally encoded number, and
+	// the final OP_NUMEQUAL.
+	var requiredSigs int
+	if op := tokenizer.Opcode(); isSmallInt(op) {
+		requiredSigs = asSmallInt(op)
+	} else {
+		num, err := makeScriptNum(tokenizer.Data(), true, maxScriptNumLen)
+		if err != nil {
+			return nil, 0, false
+		}
+		requiredSigs = int(num)
+	}
+	if requiredSigs < 1 || requiredSigs > len(pubKeys) {
+		return nil, 0, false
+	}
+	if !tokenizer.Next() || tokenizer.Opcode() != OP_NUMEQUAL ||
+		!tokenizer.Done() {
+
+		return nil, 0, false
+	}
+
+	return pubKeys, requiredSigs, true
+}
+
+// tapscriptSpend houses the details of spending a taproot output through one
+// of the leaves of its tapscript tree.
+type tapscriptSpend struct {
+	proof     *TapscriptProof
+	ctrlBlock []byte
+
+	// privKeys holds the private keys to sign with for each of the public
+	// keys of the leaf script in script order, which are nil for the keys
+	// which don't sign.
+	privKeys []*btcec.PrivateKey
+
+	// witnessSize is the serialized size of the witness of the spend.
+	witnessSize int
+}
+
+// witnessElementSize returns the serialized size of a witness element of the
+// passed length including its length prefix.
+func witnessElementSize(length int) int {
+	return wire.VarIntSerializeSize(uint64(length)) + length
+}
+
+// tapscriptSpendForLeaf returns the details of spending the passed leaf of a
+// tapscript tree with the keys available from the key database.  It returns
+// nil when the leaf isn't a known template or not enough of its keys are
+// available.
+func tapscriptSpendForLeaf(proof *TapscriptProof, internalKey *btcec.PublicKey,
+	sigLen int, kdb TaprootKeyDB) (*tapscriptSpend, error) {
+
+	if proof.LeafVersion != BaseLeafVersion {
+		return nil, nil
+	}
+	pubKeys, requiredSigs, ok := extractTapscriptSigners(proof.Script)
+	if !ok {
+		return nil, nil
+	}
+
+	// Exactly the required number of signatures must be provided, so keys
+	// are only looked up until enough of them are available.
+	privKeys := make([]*btcec.PrivateKey, len(pubKeys))
+	var numSigs int
+	for i := 0; i < len(pubKeys) && numSigs < requiredSigs; i++ {
+		pubKey, err := schnorr.ParsePubKey(pubKeys[i])
+		if err != nil {
+			continue
+		}
+		privKey, err := kdb.GetTaprootKey(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		if privKey == nil {
+			continue
+		}
+
+		privKeys[i] = privKey
+		numSigs++
+	}
+	if numSigs < requiredSigs {
+		return nil, nil
+	}
+
+	ctrlBlock := proof.ToControlBlock(internalKey)
+	ctrlBlockBytes, err := ctrlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// The witness consists of a signature for each signing key, an empty
+	// element for each other key, the leaf script and the control block.
+	witnessSize := wire.VarIntSerializeSize(uint64(len(pubKeys) + 2))
+	witnessSize += numSigs*witnessElementSize(sigLen) +
+		(len(pubKeys)-numSigs)*witnessElementSize(0)
+	witnessSize += witnessElementSize(len(proof.Script))
+	witnessSize += witnessElementSize(len(ctrlBlockBytes))
+
+	return &tapscriptSpend{
+		proof:       proof,
+		ctrlBlock:   ctrlBlockBytes,
+		privKeys:    privKeys,
+		witnessSize: witnessSize,
+	}, nil
+}
+
+// SignTaprootOutput signs the taproot output spent by input idx of the given
+// tx with a signature type of hashType and returns the complete witness of the
+// input.  The previous output is looked up with prevOutFetcher, which must
+// also be the one used to compute sigHashes.  The internal key and tapscript
+// tree committed to by the output are looked up by calling sdb with the
+// taproot address of the output, and any keys required are looked up by
+// calling kdb.
+//
+// The output is spent through the key path whenever the private key for the
+// internal key is available, since it results in the smallest witness.
+// Otherwise, the output is spent through the leaf of the tapscript tree with
+// the smallest witness among those for which enough keys are available.
+// Leaves are only considered if their script is either a single key script of
+// the form:
+//
+//	<pubkey> OP_CHECKSIG
+//
+// or a multisig script of the form:
+//
+//	<pubkey> OP_CHECKSIG <pubkey> OP_CHECKSIGADD ... NUM_SIGS OP_NUMEQUAL
+func SignTaprootOutput(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
+	sigHashes *TxSigHashes, prevOutFetcher PrevOutputFetcher,
+	hashType SigHashType, kdb TaprootKeyDB,
+	sdb TaprootScriptDB) (wire.TxWitness, error) {
+
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("transaction input index %d is out of "+
+			"range", idx)
+	}
+	prevOut := prevOutFetcher.FetchPrevOutput(
+		tx.TxIn[idx].PreviousOutPoint,
+	)
+	if prevOut == nil {
+		return nil, fmt.Errorf("previous output %v of input %d not "+
+			"found", tx.TxIn[idx].PreviousOutPoint, idx)
+	}
+
+	class, addresses, _, err := ExtractPkScriptAddrs(
+		prevOut.PkScript, chainParams,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if class != WitnessV1TaprootTy {
+		return nil, fmt.Errorf("can't sign %v output as taproot", class)
+	}
+	address := addresses[0].(*btcutil.AddressTaproot)
+
+	internalKey, scriptTree, err := sdb.GetTaprootScriptTree(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the output actually commits to the internal key and the
+	// tapscript tree, since the witness would be invalid otherwise.
+	var rootHash []byte
+	if scriptTree != nil {
+		hash := scriptTree.RootNode.TapHash()
+		rootHash = hash[:]
+	}
+	outputKey := ComputeTaprootOutputKey(internalKey, rootHash)
+	if !bytes.Equal(schnorr.SerializePubKey(outputKey),
+		address.ScriptAddress()) {
+
+		return nil, errors.New("taproot output doesn't commit to the " +
+			"internal key and tapscript tree")
+	}
+
+	// Spend through the key path if possible.  Keys are always looked up
+	// by their x-only encoding.
+	internalKey, err = schnorr.ParsePubKey(
+		schnorr.SerializePubKey(internalKey),
+	)
+	if err != nil {
+		return nil, err
+	}
+	privKey, err := kdb.GetTaprootKey(internalKey)
+	if err != nil {
+		return nil, err
+	}
+	if privKey != nil {
+		sig, err := RawTxInTaprootSignature(
+			tx, sigHashes, idx, prevOut.Value, prevOut.PkScript,
+			rootHash, hashType, privKey,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return wire.TxWitness{sig}, nil
+	}
+	if scriptTree == nil {
+		return nil, errors.New("no key available for taproot key " +
+			"path spend")
+	}
+
+	// Otherwise, select the script path with the smallest witness.  The
+	// sighash type is only appended to signatures when it isn't the
+	// default.
+	sigLen := schnorr.SignatureSize
+	if hashType != SigHashDefault {
+		sigLen++
+	}
+	var best *tapscriptSpend
+	for i := range scriptTree.LeafMerkleProofs {
+		spend, err := tapscriptSpendForLeaf(
+			&scriptTree.LeafMerkleProofs[i], internalKey, sigLen, kdb,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if spend != nil &&
+			(best == nil || spend.witnessSize < best.witnessSize) {
+
+			best = spend
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no key available for taproot key " +
+			"path spend and no satisfiable tapscript leaf")
+	}
+
+	// The first key of the script is checked against the top stack
+	// element, so the signatures are pushed in reverse script order.
+	witness := make(wire.TxWitness, 0, len(best.privKeys)+2)
+	for i := len(best.privKeys) - 1; i >= 0; i-- {
+		if best.privKeys[i] == nil {
+			witness = append(witness, nil)
+			continue
+		}
+
+		sig, err := RawTxInTapscriptSignature(
+			tx, sigHashes, idx, prevOut.Value, prevOut.PkScript,
+			best.proof.TapLeaf, hashType, best.privKeys[i],
+		)
+		if err != nil {
+			return nil, err
+		}
+		witness = append(witness, sig)
+	}
+	witness = append(witness, best.proof.Script, best.ctrlBlock)
+
+	return witness, nil
+}
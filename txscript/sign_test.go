@@ -5,11 +5,14 @@
 package txscript
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -1692,3 +1695,313 @@ nexttest:
 		}
 	}
 }
+
+// TestExtractTapscriptSigners ensures the keys and number of required
+// signatures are only extracted from single key and multisig tapscripts.
+func TestExtractTapscriptSigners(t *testing.T) {
+	t.Parallel()
+
+	key1 := "DATA_32 0x" + strings.Repeat("01", 32)
+	key2 := "DATA_32 0x" + strings.Repeat("02", 32)
+	key3 := "DATA_32 0x" + strings.Repeat("03", 32)
+
+	tests := []struct {
+		name         string
+		script       string
+		numPubKeys   int
+		requiredSigs int
+		valid        bool
+	}{{
+		name:         "single key",
+		script:       key1 + " CHECKSIG",
+		numPubKeys:   1,
+		requiredSigs: 1,
+		valid:        true,
+	}, {
+		name: "2-of-3 multisig",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIGADD " + key3 +
+			" CHECKSIGADD 2 NUMEQUAL",
+		numPubKeys:   3,
+		requiredSigs: 2,
+		valid:        true,
+	}, {
+		name:         "1-of-1 multisig",
+		script:       key1 + " CHECKSIG 1 NUMEQUAL",
+		numPubKeys:   1,
+		requiredSigs: 1,
+		valid:        true,
+	}, {
+		name:   "checksigverify",
+		script: key1 + " CHECKSIGVERIFY",
+	}, {
+		name:   "compressed key",
+		script: "DATA_33 0x02" + strings.Repeat("01", 32) + " CHECKSIG",
+	}, {
+		name:   "first key with checksigadd",
+		script: key1 + " CHECKSIGADD " + key2 + " CHECKSIGADD 1 NUMEQUAL",
+	}, {
+		name:   "later key with checksig",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIG 1 NUMEQUAL",
+	}, {
+		name:   "no required signatures",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIGADD 0 NUMEQUAL",
+	}, {
+		name:   "too many required signatures",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIGADD 3 NUMEQUAL",
+	}, {
+		name:   "non-minimal required signatures",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIGADD 0x020100 NUMEQUAL",
+	}, {
+		name:   "numequalverify",
+		script: key1 + " CHECKSIG " + key2 + " CHECKSIGADD 1 NUMEQUALVERIFY",
+	}, {
+		name: "trailing opcode",
+		script: key1 + " CHECKSIG " + key2 +
+			" CHECKSIGADD 1 NUMEQUAL NUMEQUAL",
+	}}
+
+	for _, test := range tests {
+		script := mustParseShortForm(test.script)
+		pubKeys, requiredSigs, valid := extractTapscriptSigners(script)
+		if valid != test.valid {
+			t.Errorf("%s: unexpected validity -- got %v, want %v",
+				test.name, valid, test.valid)
+			continue
+		}
+		if len(pubKeys) != test.numPubKeys {
+			t.Errorf("%s: unexpected number of keys -- got %d, "+
+				"want %d", test.name, len(pubKeys),
+				test.numPubKeys)
+		}
+		if requiredSigs != test.requiredSigs {
+			t.Errorf("%s: unexpected required signatures -- got "+
+				"%d, want %d", test.name, requiredSigs,
+				test.requiredSigs)
+		}
+	}
+}
+
+// TestSignTaprootOutput ensures taproot outputs are spent through the key path
+// or the cheapest satisfiable script path depending on the available keys, and
+// that the resulting witnesses are valid.
+func TestSignTaprootOutput(t *testing.T) {
+	t.Parallel()
+
+	privKeys := make([]*btcec.PrivateKey, 5)
+	for i := range privKeys {
+		privKeys[i], _ = btcec.PrivKeyFromBytes(
+			bytes.Repeat([]byte{byte(i + 1)}, 32),
+		)
+	}
+	internalKey := privKeys[0].PubKey()
+	xOnly := func(i int) []byte {
+		return schnorr.SerializePubKey(privKeys[i].PubKey())
+	}
+
+	// The tree contains a single key leaf, a 2-of-3 multisig leaf and a
+	// leaf which isn't a known template.
+	singleKeyScript, err := NewScriptBuilder().AddData(xOnly(1)).
+		AddOp(OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	multiSigScript, err := NewScriptBuilder().
+		AddData(xOnly(2)).AddOp(OP_CHECKSIG).
+		AddData(xOnly(3)).AddOp(OP_CHECKSIGADD).
+		AddData(xOnly(4)).AddOp(OP_CHECKSIGADD).
+		AddInt64(2).AddOp(OP_NUMEQUAL).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	scriptTree := AssembleTaprootScriptTree(
+		NewBaseTapLeaf([]byte{OP_TRUE}),
+		NewBaseTapLeaf(multiSigScript),
+		NewBaseTapLeaf(singleKeyScript),
+	)
+	otherTree := AssembleTaprootScriptTree(
+		NewBaseTapLeaf(singleKeyScript),
+	)
+
+	tests := []struct {
+		name       string
+		scriptTree *IndexedTapScriptTree
+		signTree   *IndexedTapScriptTree
+		hashType   SigHashType
+		keys       []int
+		leafScript []byte
+		valid      bool
+	}{{
+		name:     "bip 86 key path",
+		hashType: SigHashDefault,
+		keys:     []int{0},
+		valid:    true,
+	}, {
+		name:     "bip 86 without key",
+		hashType: SigHashDefault,
+		keys:     []int{1, 2, 3, 4},
+	}, {
+		name:       "key path",
+		scriptTree: scriptTree,
+		signTree:   scriptTree,
+		hashType:   SigHashAll,
+		keys:       []int{0, 1, 2, 3},
+		valid:      true,
+	}, {
+		name:       "single key leaf",
+		scriptTree: scriptTree,
+		signTree:   scriptTree,
+		hashType:   SigHashDefault,
+		keys:       []int{1, 2, 3},
+		leafScript: singleKeyScript,
+		valid:      true,
+	}, {
+		name:       "multisig leaf",
+		scriptTree: scriptTree,
+		signTree:   scriptTree,
+		hashType:   SigHashSingle | SigHashAnyOneCanPay,
+		keys:       []int{2, 4},
+		leafScript: multiSigScript,
+		valid:      true,
+	}, {
+		name:       "multisig leaf with all keys",
+		scriptTree: scriptTree,
+		signTree:   scriptTree,
+		hashType:   SigHashDefault,
+		keys:       []int{2, 3, 4},
+		leafScript: multiSigScript,
+		valid:      true,
+	}, {
+		name:       "not enough keys",
+		scriptTree: scriptTree,
+		signTree:   scriptTree,
+		hashType:   SigHashDefault,
+		keys:       []int{3},
+	}, {
+		name:       "wrong script tree",
+		scriptTree: scriptTree,
+		signTree:   otherTree,
+		hashType:   SigHashDefault,
+		keys:       []int{0, 1},
+	}}
+
+	for _, test := range tests {
+		var rootHash []byte
+		if test.scriptTree != nil {
+			hash := test.scriptTree.RootNode.TapHash()
+			rootHash = hash[:]
+		}
+		outputKey := ComputeTaprootOutputKey(internalKey, rootHash)
+		address, err := btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(outputKey),
+			&chaincfg.MainNetParams,
+		)
+		if err != nil {
+			t.Fatalf("%s: unable to create address: %v", test.name,
+				err)
+		}
+		pkScript, err := PayToAddrScript(address)
+		if err != nil {
+			t.Fatalf("%s: unable to create script: %v", test.name,
+				err)
+		}
+
+		// Spend the taproot output along with another input, which is
+		// committed to by the signatures as well.
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+		})
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+		})
+		tx.AddTxOut(&wire.TxOut{Value: 1000, PkScript: pkScript})
+		tx.AddTxOut(&wire.TxOut{Value: 2000, PkScript: pkScript})
+		prevOutFetcher := NewMultiPrevOutFetcher(nil)
+		prevOutFetcher.AddPrevOut(tx.TxIn[0].PreviousOutPoint,
+			&wire.TxOut{Value: 1500, PkScript: []byte{OP_TRUE}})
+		prevOutFetcher.AddPrevOut(tx.TxIn[1].PreviousOutPoint,
+			&wire.TxOut{Value: 2500, PkScript: pkScript})
+		sigHashes := NewTxSigHashes(tx, prevOutFetcher)
+
+		kdb := TaprootKeyClosure(func(pubKey *btcec.PublicKey) (
+			*btcec.PrivateKey, error) {
+
+			for _, i := range test.keys {
+				if bytes.Equal(schnorr.SerializePubKey(pubKey),
+					xOnly(i)) {
+
+					return privKeys[i], nil
+				}
+			}
+			return nil, nil
+		})
+		sdb := TaprootScriptClosure(func(
+			addr *btcutil.AddressTaproot) (*btcec.PublicKey,
+			*IndexedTapScriptTree, error) {
+
+			if addr.String() != address.String() {
+				return nil, nil, errors.New("unknown address")
+			}
+			return internalKey, test.signTree, nil
+		})
+
+		witness, err := SignTaprootOutput(
+			&chaincfg.MainNetParams, tx, 1, sigHashes,
+			prevOutFetcher, test.hashType, kdb, sdb,
+		)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: signed output without the required "+
+					"keys", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unable to sign output: %v", test.name,
+				err)
+			continue
+		}
+
+		// Ensure the expected spend path was selected.
+		switch {
+		case test.leafScript == nil && len(witness) != 1:
+			t.Errorf("%s: expected key path spend, got witness "+
+				"with %d elements", test.name, len(witness))
+			continue
+		case test.leafScript != nil && (len(witness) < 3 ||
+			!bytes.Equal(witness[len(witness)-2], test.leafScript)):
+
+			t.Errorf("%s: expected script path spend of leaf "+
+				"%x, got witness %x", test.name,
+				test.leafScript, witness)
+			continue
+		}
+
+		tx.TxIn[1].Witness = witness
+		vm, err := NewEngine(
+			pkScript, tx, 1, StandardVerifyFlags, nil, sigHashes,
+			2500, prevOutFetcher,
+		)
+		if err != nil {
+			t.Errorf("%s: unable to create engine: %v", test.name,
+				err)
+			continue
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: invalid witness: %v", test.name, err)
+		}
+	}
+
+	// Outputs other than taproot outputs can't be signed.
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{})
+	prevOutFetcher := NewCannedPrevOutputFetcher([]byte{OP_TRUE}, 1000)
+	_, err = SignTaprootOutput(
+		&chaincfg.MainNetParams, tx, 0,
+		NewTxSigHashes(tx, prevOutFetcher), prevOutFetcher,
+		SigHashDefault, nil, nil,
+	)
+	if err == nil {
+		t.Errorf("signed non-taproot output")
+	}
+}
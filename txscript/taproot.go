@@ -300,8 +300,9 @@ func TweakTaprootPrivKey(privKey *btcec.PrivateKey,
 	scriptRoot []byte) *btcec.PrivateKey {
 
 	// If the corresponding public key has an odd y coordinate, then we'll
-	// negate the private key as specified in BIP 341.
-	privKeyScalar := &privKey.Key
+	// negate the private key as specified in BIP 341.  The scalar is
+	// copied so the passed private key isn't modified.
+	privKeyScalar := new(btcec.ModNScalar).Set(&privKey.Key)
 	pubKeyBytes := privKey.PubKey().SerializeCompressed()
 	if pubKeyBytes[0] == secp.PubKeyFormatCompressedOdd {
 		privKeyScalar.Negate()
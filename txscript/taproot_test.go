@@ -185,8 +185,13 @@ func TestTaprootScriptSpendTweak(t *testing.T) {
 		// script root.
 		tweakedPub := ComputeTaprootOutputKey(privKey.PubKey(), x[:])
 
-		// Now we'll generate the corresponding tweaked private key.
+		// Now we'll generate the corresponding tweaked private key,
+		// which must leave the original private key untouched.
+		origKey := privKey.Key
 		tweakedPriv := TweakTaprootPrivKey(privKey, x[:])
+		if !privKey.Key.Equals(&origKey) {
+			return false
+		}
 
 		// The public key for this private key should be the same as
 		// the tweaked public key we generate above.
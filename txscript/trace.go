@@ -0,0 +1,167 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// TraceStep describes the execution of a single opcode by the engine.  Stack
+// items are hex encoded with the top of the stack being the last item.
+type TraceStep struct {
+	// ScriptIndex is the index of the script the opcode belongs to.  Index
+	// 0 is the signature script and 1 is the public key script, followed
+	// by the redeem script of pay-to-script-hash and the witness script of
+	// witness programs.
+	ScriptIndex int `json:"scriptIndex"`
+
+	// OpcodeIndex is the index of the opcode within its script.
+	OpcodeIndex int `json:"opcodeIndex"`
+
+	// Opcode is the disassembly of the opcode, including its data in the
+	// case of data pushes.
+	Opcode string `json:"opcode"`
+
+	// Executed specifies whether the opcode was executed or skipped since
+	// it is part of a conditional branch which is not executing.
+	Executed bool `json:"executed"`
+
+	// StackBefore and StackAfter are the data stack before and after the
+	// opcode executed.
+	StackBefore []string `json:"stackBefore"`
+	StackAfter  []string `json:"stackAfter"`
+
+	// AltStackBefore and AltStackAfter are the alternate data stack before
+	// and after the opcode executed.
+	AltStackBefore []string `json:"altStackBefore"`
+	AltStackAfter  []string `json:"altStackAfter"`
+
+	// CondStack is the conditional execution state after the opcode
+	// executed, with each entry being one of "true", "false" or "skip",
+	// from the outermost to the innermost conditional.
+	CondStack []string `json:"condStack"`
+
+	// SigOpsBudget is the remaining signature operations budget of
+	// tapscript execution after the opcode executed.  It is nil for all
+	// other scripts.
+	SigOpsBudget *int32 `json:"sigOpsBudget,omitempty"`
+
+	// Error is the error the opcode failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// StepHook defines the signature of the function the engine invokes with the
+// trace of each step it executed.
+type StepHook func(step *TraceStep)
+
+// SetStepHook sets the function which is invoked with the trace of each step
+// executed by the engine, either through Step or Execute.  Passing nil
+// disables tracing.
+func (vm *Engine) SetStepHook(hook StepHook) {
+	vm.stepHook = hook
+}
+
+// Trace is the recorded execution of the scripts of a transaction input.
+type Trace struct {
+	// Steps are the steps executed by the engine in order.
+	Steps []*TraceStep `json:"steps"`
+
+	// Error is the error the execution failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// ExportJSON returns the trace encoded as indented JSON.
+func (t *Trace) ExportJSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// TraceExecution executes all scripts in the passed engine like Execute while
+// recording the trace of each step.  The error of the execution is recorded in
+// the returned trace as well.
+func TraceExecution(vm *Engine) *Trace {
+	trace := &Trace{Steps: []*TraceStep{}}
+
+	hook := vm.stepHook
+	vm.SetStepHook(func(step *TraceStep) {
+		trace.Steps = append(trace.Steps, step)
+		if hook != nil {
+			hook(step)
+		}
+	})
+	defer vm.SetStepHook(hook)
+
+	if err := vm.Execute(); err != nil {
+		trace.Error = err.Error()
+	}
+
+	return trace
+}
+
+// hexStack returns the hex encoding of the passed stack items.
+func hexStack(items [][]byte) []string {
+	stack := make([]string, 0, len(items))
+	for _, item := range items {
+		stack = append(stack, hex.EncodeToString(item))
+	}
+	return stack
+}
+
+// newTraceStep returns the trace of the step about to be executed, populated
+// with the current state of the engine.
+func (vm *Engine) newTraceStep() *TraceStep {
+	// The disassembly fails for an invalid program counter, in which case
+	// the step fails as well and its error is recorded.
+	opcode, _ := vm.disasmNextOpcode()
+
+	// Conditional opcodes are always executed, even in branches which are
+	// not executing, since they track the nesting of the conditionals.
+	executed := vm.isBranchExecuting()
+	if vm.checkValidPC() == nil {
+		peekTokenizer := vm.tokenizer
+		if peekTokenizer.Next() &&
+			isOpcodeConditional(peekTokenizer.Opcode()) {
+
+			executed = true
+		}
+	}
+
+	return &TraceStep{
+		ScriptIndex:    vm.scriptIdx,
+		OpcodeIndex:    vm.opcodeIdx,
+		Opcode:         opcode,
+		Executed:       executed,
+		StackBefore:    hexStack(vm.GetStack()),
+		AltStackBefore: hexStack(vm.GetAltStack()),
+	}
+}
+
+// finishTraceStep populates the passed trace with the state of the engine after
+// the step was executed with the passed result.
+func (vm *Engine) finishTraceStep(step *TraceStep, err error) {
+	step.StackAfter = hexStack(vm.GetStack())
+	step.AltStackAfter = hexStack(vm.GetAltStack())
+
+	step.CondStack = make([]string, 0, len(vm.condStack))
+	for _, cond := range vm.condStack {
+		switch cond {
+		case OpCondTrue:
+			step.CondStack = append(step.CondStack, "true")
+		case OpCondFalse:
+			step.CondStack = append(step.CondStack, "false")
+		default:
+			step.CondStack = append(step.CondStack, "skip")
+		}
+	}
+
+	if vm.taprootCtx != nil {
+		budget := vm.taprootCtx.sigOpsBudget
+		step.SigOpsBudget = &budget
+	}
+
+	if err != nil {
+		step.Error = err.Error()
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// traceTestTx returns a transaction with a single input spending an output
+// with the passed signature script and witness.
+func traceTestTx(sigScript []byte, witness wire.TxWitness) *wire.MsgTx {
+	return &wire.MsgTx{
+		Version: 2,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+			SignatureScript:  sigScript,
+			Witness:          witness,
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000}},
+	}
+}
+
+// TestTraceExecution tests that the trace of a script execution records the
+// opcodes, stacks and conditional execution state of each step.
+func TestTraceExecution(t *testing.T) {
+	t.Parallel()
+
+	pkScript := mustParseShortForm("1 IF 2 ELSE 3 ENDIF 2 EQUAL")
+	vm, err := NewEngine(
+		pkScript, traceTestTx(nil, nil), 0, 0, nil, nil, 0, nil,
+	)
+	require.NoError(t, err)
+
+	trace := TraceExecution(vm)
+	require.Empty(t, trace.Error)
+	require.Len(t, trace.Steps, 8)
+
+	expected := []struct {
+		opcode    string
+		executed  bool
+		stack     []string
+		condStack []string
+	}{
+		{"OP_1", true, []string{"01"}, []string{}},
+		{"OP_IF", true, []string{}, []string{"true"}},
+		{"OP_2", true, []string{"02"}, []string{"true"}},
+		{"OP_ELSE", true, []string{"02"}, []string{"false"}},
+		{"OP_3", false, []string{"02"}, []string{"false"}},
+		{"OP_ENDIF", true, []string{"02"}, []string{}},
+		{"OP_2", true, []string{"02", "02"}, []string{}},
+		{"OP_EQUAL", true, []string{"01"}, []string{}},
+	}
+	for i, step := range trace.Steps {
+		require.Equal(t, 1, step.ScriptIndex)
+		require.Equal(t, i, step.OpcodeIndex)
+		require.Equal(t, expected[i].opcode, step.Opcode)
+		require.Equal(t, expected[i].executed, step.Executed)
+		require.Equal(t, expected[i].stack, step.StackAfter)
+		require.Equal(t, expected[i].condStack, step.CondStack)
+		require.Nil(t, step.SigOpsBudget)
+		require.Empty(t, step.Error)
+
+		if i > 0 {
+			require.Equal(
+				t, trace.Steps[i-1].StackAfter, step.StackBefore,
+			)
+		}
+	}
+
+	// The trace survives a JSON round trip.
+	exported, err := trace.ExportJSON()
+	require.NoError(t, err)
+	var imported Trace
+	require.NoError(t, json.Unmarshal(exported, &imported))
+	require.Equal(t, trace, &imported)
+}
+
+// TestTraceExecutionError tests that the error of a failing step is recorded in
+// the trace of the step as well as the trace of the execution.
+func TestTraceExecutionError(t *testing.T) {
+	t.Parallel()
+
+	pkScript := mustParseShortForm("1 RETURN")
+	vm, err := NewEngine(
+		pkScript, traceTestTx(nil, nil), 0, 0, nil, nil, 0, nil,
+	)
+	require.NoError(t, err)
+
+	// A step hook set before is invoked as well.
+	var hooked int
+	vm.SetStepHook(func(*TraceStep) {
+		hooked++
+	})
+
+	trace := TraceExecution(vm)
+	require.Len(t, trace.Steps, 2)
+	require.Equal(t, 2, hooked)
+	require.Equal(t, "OP_RETURN", trace.Steps[1].Opcode)
+	require.NotEmpty(t, trace.Steps[1].Error)
+	require.Equal(t, trace.Steps[1].Error, trace.Error)
+}
+
+// TestTraceTapscript tests that the trace of a tapscript spend records the
+// signature operations budget once the witness program is verified.
+func TestTraceTapscript(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	leaf := NewBaseTapLeaf(mustParseShortForm("2 DROP 1"))
+	tree := AssembleTaprootScriptTree(leaf)
+	rootHash := tree.RootNode.TapHash()
+	outputKey := ComputeTaprootOutputKey(privKey.PubKey(), rootHash[:])
+	pkScript, err := NewScriptBuilder().AddOp(OP_1).
+		AddData(schnorr.SerializePubKey(outputKey)).Script()
+	require.NoError(t, err)
+
+	ctrlBlock := tree.LeafMerkleProofs[0].ToControlBlock(privKey.PubKey())
+	ctrlBlockBytes, err := ctrlBlock.ToBytes()
+	require.NoError(t, err)
+	witness := wire.TxWitness{leaf.Script, ctrlBlockBytes}
+
+	tx := traceTestTx(nil, witness)
+	prevOutFetcher := NewCannedPrevOutputFetcher(pkScript, 1000)
+	vm, err := NewEngine(
+		pkScript, tx, 0, StandardVerifyFlags, nil,
+		NewTxSigHashes(tx, prevOutFetcher), 1000, prevOutFetcher,
+	)
+	require.NoError(t, err)
+
+	trace := TraceExecution(vm)
+	require.Empty(t, trace.Error)
+	require.Len(t, trace.Steps, 5)
+
+	// The witness program is verified after the public key script, which
+	// starts the execution of the leaf script.
+	require.Nil(t, trace.Steps[0].SigOpsBudget)
+	budget := sigOpsDelta + int32(witness.SerializeSize())
+	for _, step := range trace.Steps[1:] {
+		require.NotNil(t, step.SigOpsBudget)
+		require.Equal(t, budget, *step.SigOpsBudget)
+	}
+	for _, step := range trace.Steps[2:] {
+		require.Equal(t, 2, step.ScriptIndex)
+	}
+}
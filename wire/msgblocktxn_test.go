@@ -0,0 +1,169 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxn tests the MsgBlockTxn API.
+func TestBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	msg := NewMsgBlockTxn(&blockOne.Header.PrevBlock)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000000)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure transactions are added properly.
+	tx := blockOne.Transactions[0].Copy()
+	if err := msg.AddTransaction(tx); err != nil {
+		t.Fatalf("AddTransaction: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(msg.Transactions, []*MsgTx{tx}) {
+		t.Errorf("AddTransaction: wrong transactions - got %v, want %v",
+			spew.Sdump(msg.Transactions), spew.Sdump(tx))
+	}
+
+	// Older protocol versions should fail since the message didn't exist
+	// yet.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, SendCmpctVersion-1, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgBlockTxn succeeded with old protocol " +
+			"version when it should have failed")
+	}
+}
+
+// TestBlockTxnWire tests the MsgBlockTxn wire encode and decode for various
+// protocol versions.
+func TestBlockTxnWire(t *testing.T) {
+	msg := NewMsgBlockTxn(&blockOne.Header.PrevBlock)
+	msg.AddTransaction(blockOne.Transactions[0])
+	msgEncoded := append(append([]byte{}, blockOneBytes[4:36]...), 0x01)
+	msgEncoded = append(msgEncoded, blockOneBytes[81:]...)
+
+	tests := []struct {
+		in   *MsgBlockTxn    // Message to encode
+		out  *MsgBlockTxn    // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version.
+		{msg, msg, msgEncoded, ProtocolVersion, BaseEncoding},
+
+		// Protocol version SendCmpctVersion.
+		{msg, msg, msgEncoded, SendCmpctVersion, WitnessEncoding},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgBlockTxn
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(&msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgBlockTxn to confirm error paths work correctly.
+func TestBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	msg := NewMsgBlockTxn(&blockOne.Header.PrevBlock)
+	msg.AddTransaction(blockOne.Transactions[0])
+	msgEncoded := append(append([]byte{}, blockOneBytes[4:36]...), 0x01)
+	msgEncoded = append(msgEncoded, blockOneBytes[81:]...)
+
+	// Number of transactions which exceeds the max allowed.
+	var buf bytes.Buffer
+	WriteVarInt(&buf, pver, maxTxPerBlock+1)
+	exceedMaxTxns := append(append([]byte{}, msgEncoded[:32]...),
+		buf.Bytes()...)
+
+	tests := []struct {
+		in       *MsgBlockTxn // Value to encode
+		buf      []byte       // Wire encoding
+		pver     uint32       // Protocol version for wire encoding
+		max      int          // Max size of fixed buffer to induce errors
+		writeErr error        // Expected write error
+		readErr  error        // Expected read error
+	}{
+		// Force error in block hash.
+		{msg, msgEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in num transactions.
+		{msg, msgEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in transactions.
+		{msg, msgEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{msg, msgEncoded, SendCmpctVersion - 1, len(msgEncoded),
+			wireErr, wireErr},
+		// Force error with too many transactions.
+		{msg, exceedMaxTxns, pver, len(msgEncoded), nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// Decode from wire format.
+		var msg MsgBlockTxn
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
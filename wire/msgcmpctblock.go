@@ -0,0 +1,349 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/aead/siphash"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// ShortTxIDLen is the number of bytes used to serialize a short
+	// transaction id in a compact block.
+	ShortTxIDLen = 6
+
+	// shortTxIDMask is the mask applied to the siphash of a transaction
+	// hash in order to produce its 48-bit short transaction id.
+	shortTxIDMask = (1 << (ShortTxIDLen * 8)) - 1
+)
+
+// PrefilledTx defines a transaction that is included directly in a compact
+// block along with its index in the block.  The coinbase transaction is always
+// prefilled since it can't possibly be in the mempool of the receiver.
+//
+// NOTE: The index is the absolute index of the transaction within the block.
+// It is differentially encoded when the message is serialized as required by
+// BIP0152.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a bitcoin
+// cmpctblock message.  It is used to relay a block by only including a short
+// transaction id for each transaction the receiver is expected to already know
+// about along with a set of prefilled transactions.
+//
+// Use the AddShortID and AddPrefilledTx functions to build up the lists of
+// short transaction ids and prefilled transactions.
+//
+// This message was not added until protocol versions starting with
+// SendCmpctVersion.
+type MsgCmpctBlock struct {
+	Header       BlockHeader
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+// AddShortID adds a short transaction id to the message.  Only the lower 48
+// bits of the passed id are used.
+func (msg *MsgCmpctBlock) AddShortID(id uint64) error {
+	if msg.TotalTxns()+1 > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message [max %v]",
+			maxTxPerBlock)
+		return messageError("MsgCmpctBlock.AddShortID", str)
+	}
+
+	msg.ShortIDs = append(msg.ShortIDs, id&shortTxIDMask)
+	return nil
+}
+
+// AddPrefilledTx adds a prefilled transaction at the provided absolute index to
+// the message.  Prefilled transactions must be added in order of increasing
+// index.
+func (msg *MsgCmpctBlock) AddPrefilledTx(index uint32, tx *MsgTx) error {
+	if msg.TotalTxns()+1 > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message [max %v]",
+			maxTxPerBlock)
+		return messageError("MsgCmpctBlock.AddPrefilledTx", str)
+	}
+	if n := len(msg.PrefilledTxs); n > 0 &&
+		index <= msg.PrefilledTxs[n-1].Index {
+
+		str := fmt.Sprintf("prefilled transaction index %d is not "+
+			"greater than the previous index %d", index,
+			msg.PrefilledTxs[n-1].Index)
+		return messageError("MsgCmpctBlock.AddPrefilledTx", str)
+	}
+
+	msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+		Index: index,
+		Tx:    tx,
+	})
+	return nil
+}
+
+// TotalTxns returns the total number of transactions in the block the message
+// represents.
+func (msg *MsgCmpctBlock) TotalTxns() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+// SipHashKey returns the key used to compute the short transaction ids of the
+// message.  It is the first 16 bytes of the single SHA256 of the serialized
+// block header followed by the little endian nonce.
+func (msg *MsgCmpctBlock) SipHashKey() [siphash.KeySize]byte {
+	// Ignore the error returns since there is no way the encode could fail
+	// except being out of memory which would cause a run-time panic.
+	buf := bytes.NewBuffer(make([]byte, 0, blockHeaderLen+8))
+	_ = writeBlockHeader(buf, 0, &msg.Header)
+	_ = writeElement(buf, msg.Nonce)
+
+	var key [siphash.KeySize]byte
+	sum := sha256.Sum256(buf.Bytes())
+	copy(key[:], sum[:siphash.KeySize])
+	return key
+}
+
+// ShortTxID returns the 48-bit short transaction id for the passed transaction
+// hash using the provided siphash key.  The hash must be the witness hash of
+// the transaction for version 2 compact blocks and the transaction hash
+// otherwise.
+func ShortTxID(key *[siphash.KeySize]byte, hash *chainhash.Hash) uint64 {
+	return siphash.Sum64(hash[:], key) & shortTxIDMask
+}
+
+// readShortTxID reads a 6-byte little endian short transaction id from r.
+func readShortTxID(r io.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:ShortTxIDLen]); err != nil {
+		return 0, err
+	}
+	return littleEndian.Uint64(b[:]), nil
+}
+
+// writeShortTxID writes the passed short transaction id to w as 6 little
+// endian bytes.
+func writeShortTxID(w io.Writer, id uint64) error {
+	var b [8]byte
+	littleEndian.PutUint64(b[:], id)
+	_, err := w.Write(b[:ShortTxIDLen])
+	return err
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < SendCmpctVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	// Read num short ids and limit to max.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.ShortIDs = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		id, err := readShortTxID(r)
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, id)
+	}
+
+	// Read num prefilled transactions and limit the total number of
+	// transactions to max.
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock-uint64(len(msg.ShortIDs)) {
+		str := fmt.Sprintf("too many prefilled transactions for "+
+			"message [count %v, max %v]", count,
+			maxTxPerBlock-len(msg.ShortIDs))
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	// Each index is encoded as the difference from the previous index
+	// minus one.
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, count)
+	var index uint64
+	for i := uint64(0); i < count; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if diff >= maxTxPerBlock {
+			str := fmt.Sprintf("prefilled transaction index "+
+				"offset %d exceeds max %d", diff,
+				maxTxPerBlock-1)
+			return messageError("MsgCmpctBlock.BtcDecode", str)
+		}
+		if i > 0 {
+			diff += index + 1
+		}
+		index = diff
+		if index >= maxTxPerBlock {
+			str := fmt.Sprintf("prefilled transaction index %d "+
+				"exceeds max %d", index, maxTxPerBlock-1)
+			return messageError("MsgCmpctBlock.BtcDecode", str)
+		}
+
+		tx := MsgTx{}
+		err = tx.BtcDecode(r, pver, enc)
+		if err != nil {
+			return err
+		}
+		msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+			Index: uint32(index),
+			Tx:    &tx,
+		})
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < SendCmpctVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	// Limit to max transactions per block.
+	if msg.TotalTxns() > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", msg.TotalTxns(), maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+	for _, id := range msg.ShortIDs {
+		err = writeShortTxID(w, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs)))
+	if err != nil {
+		return err
+	}
+	for i, ptx := range msg.PrefilledTxs {
+		diff := ptx.Index
+		if i > 0 {
+			prev := msg.PrefilledTxs[i-1].Index
+			if ptx.Index <= prev {
+				str := fmt.Sprintf("prefilled transaction "+
+					"index %d is not greater than the "+
+					"previous index %d", ptx.Index, prev)
+				return messageError("MsgCmpctBlock.BtcEncode",
+					str)
+			}
+			diff = ptx.Index - prev - 1
+		}
+		err = WriteVarInt(w, pver, uint64(diff))
+		if err != nil {
+			return err
+		}
+		err = ptx.Tx.BtcEncode(w, pver, enc)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new bitcoin cmpctblock message that conforms to
+// the Message interface.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(bh *BlockHeader, nonce uint64) *MsgCmpctBlock {
+	return &MsgCmpctBlock{
+		Header:       *bh,
+		Nonce:        nonce,
+		ShortIDs:     make([]uint64, 0),
+		PrefilledTxs: make([]*PrefilledTx, 0),
+	}
+}
+
+// NewMsgCmpctBlockFromBlock returns a new version 2 bitcoin cmpctblock message
+// for the passed block using the provided nonce.  The coinbase transaction is
+// prefilled while all other transactions are represented by short ids computed
+// from their witness hashes.
+func NewMsgCmpctBlockFromBlock(block *MsgBlock, nonce uint64) *MsgCmpctBlock {
+	msg := &MsgCmpctBlock{
+		Header:       block.Header,
+		Nonce:        nonce,
+		ShortIDs:     make([]uint64, 0, len(block.Transactions)),
+		PrefilledTxs: make([]*PrefilledTx, 0, 1),
+	}
+
+	key := msg.SipHashKey()
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+				Index: 0,
+				Tx:    tx,
+			})
+			continue
+		}
+
+		wtxid := tx.WitnessHash()
+		msg.ShortIDs = append(msg.ShortIDs, ShortTxID(&key, &wtxid))
+	}
+
+	return msg
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCmpctBlock tests the MsgCmpctBlock API.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	msg := NewMsgCmpctBlock(&blockOne.Header, 0x0102030405060708)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000000)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure short ids are truncated to 48 bits.
+	if err := msg.AddShortID(0xffffffffffffffff); err != nil {
+		t.Fatalf("AddShortID: unexpected error %v", err)
+	}
+	if msg.ShortIDs[0] != 0xffffffffffff {
+		t.Errorf("AddShortID: short id was not truncated - got %x",
+			msg.ShortIDs[0])
+	}
+
+	// Ensure prefilled transactions must be added in increasing order.
+	if err := msg.AddPrefilledTx(1, blockOne.Transactions[0]); err != nil {
+		t.Fatalf("AddPrefilledTx: unexpected error %v", err)
+	}
+	if err := msg.AddPrefilledTx(1, blockOne.Transactions[0]); err == nil {
+		t.Errorf("AddPrefilledTx: succeeded with a duplicate index")
+	}
+	if msg.TotalTxns() != 2 {
+		t.Errorf("TotalTxns: wrong number of transactions - got %d, "+
+			"want %d", msg.TotalTxns(), 2)
+	}
+
+	// Ensure the total number of transactions is limited.
+	msg.ShortIDs = make([]uint64, maxTxPerBlock-1)
+	if err := msg.AddShortID(0); err == nil {
+		t.Errorf("AddShortID: succeeded with too many transactions")
+	}
+	if err := msg.AddPrefilledTx(2, blockOne.Transactions[0]); err == nil {
+		t.Errorf("AddPrefilledTx: succeeded with too many transactions")
+	}
+
+	// Force too many transactions to test the encode limit.
+	msg.ShortIDs = append(msg.ShortIDs, 0)
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgCmpctBlock succeeded with too many " +
+			"transactions when it should have failed")
+	}
+
+	// Older protocol versions should fail since the message didn't exist
+	// yet.
+	msg.ShortIDs = nil
+	err = msg.BtcEncode(&buf, SendCmpctVersion-1, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgCmpctBlock succeeded with old protocol " +
+			"version when it should have failed")
+	}
+}
+
+// TestShortTxID ensures the short transaction ids are calculated as specified
+// by BIP0152.
+func TestShortTxID(t *testing.T) {
+	msg := NewMsgCmpctBlock(&blockOne.Header, 0x0102030405060708)
+	key := msg.SipHashKey()
+
+	// The only transaction in block one has the merkle root as its hash.
+	txHash := blockOne.Transactions[0].TxHash()
+	if txHash != blockOne.Header.MerkleRoot {
+		t.Fatalf("unexpected transaction hash %v", txHash)
+	}
+
+	wantID := uint64(0x56623a742b65)
+	if id := ShortTxID(&key, &txHash); id != wantID {
+		t.Errorf("ShortTxID: wrong short id - got %x, want %x", id,
+			wantID)
+	}
+
+	// Changing the nonce must result in a different key.
+	msg.Nonce++
+	if msg.SipHashKey() == key {
+		t.Errorf("SipHashKey: key did not change with the nonce")
+	}
+}
+
+// TestNewMsgCmpctBlockFromBlock ensures compact blocks created from a block
+// prefill the coinbase and use the witness hashes for the short ids.
+func TestNewMsgCmpctBlockFromBlock(t *testing.T) {
+	tx := blockOne.Transactions[0].Copy()
+	tx.TxIn[0].Witness = TxWitness{{0x01}}
+	block := NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(tx)
+
+	msg := NewMsgCmpctBlockFromBlock(block, 1)
+	if msg.TotalTxns() != 2 || len(msg.PrefilledTxs) != 1 {
+		t.Fatalf("NewMsgCmpctBlockFromBlock: unexpected number of "+
+			"transactions %d with %d prefilled", msg.TotalTxns(),
+			len(msg.PrefilledTxs))
+	}
+	if msg.PrefilledTxs[0].Index != 0 ||
+		msg.PrefilledTxs[0].Tx != blockOne.Transactions[0] {
+
+		t.Fatalf("NewMsgCmpctBlockFromBlock: coinbase not prefilled")
+	}
+
+	key := msg.SipHashKey()
+	wtxid := tx.WitnessHash()
+	if want := ShortTxID(&key, &wtxid); msg.ShortIDs[0] != want {
+		t.Fatalf("NewMsgCmpctBlockFromBlock: wrong short id - got %x, "+
+			"want %x", msg.ShortIDs[0], want)
+	}
+}
+
+// TestCmpctBlockWire tests the MsgCmpctBlock wire encode and decode for
+// various protocol versions.
+func TestCmpctBlockWire(t *testing.T) {
+	tests := []struct {
+		in   *MsgCmpctBlock  // Message to encode
+		out  *MsgCmpctBlock  // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version.
+		{
+			&cmpctBlockOne, &cmpctBlockOne, cmpctBlockOneBytes,
+			ProtocolVersion, BaseEncoding,
+		},
+
+		// Protocol version SendCmpctVersion.
+		{
+			&cmpctBlockOne, &cmpctBlockOne, cmpctBlockOneBytes,
+			SendCmpctVersion, WitnessEncoding,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgCmpctBlock
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(&msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestCmpctBlockWireErrors performs negative tests against wire encode and
+// decode of MsgCmpctBlock to confirm error paths work correctly.
+func TestCmpctBlockWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	tests := []struct {
+		in       *MsgCmpctBlock // Value to encode
+		buf      []byte         // Wire encoding
+		pver     uint32         // Protocol version for wire encoding
+		max      int            // Max size of fixed buffer to induce errors
+		writeErr error          // Expected write error
+		readErr  error          // Expected read error
+	}{
+		// Force error in header.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in nonce.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 80, io.ErrShortWrite, io.EOF},
+		// Force error in num short ids.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 88, io.ErrShortWrite, io.EOF},
+		// Force error in short ids.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 89, io.ErrShortWrite, io.EOF},
+		// Force error in num prefilled transactions.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 101, io.ErrShortWrite, io.EOF},
+		// Force error in first prefilled index.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 102, io.ErrShortWrite, io.EOF},
+		// Force error in first prefilled transaction.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 103, io.ErrShortWrite, io.EOF},
+		// Force error in second prefilled index.
+		{&cmpctBlockOne, cmpctBlockOneBytes, pver, 237, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{&cmpctBlockOne, cmpctBlockOneBytes, SendCmpctVersion - 1, 372,
+			wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// Decode from wire format.
+		var msg MsgCmpctBlock
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
+
+// TestCmpctBlockOverflowErrors performs tests to ensure decoding compact
+// blocks that are intentionally crafted to use large values for the number of
+// transactions and prefilled indexes are handled properly.  This could
+// otherwise potentially be used as an attack vector.
+func TestCmpctBlockOverflowErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Create bytes for a compact block that claims to have more than the
+	// max allowed short ids.
+	var buf bytes.Buffer
+	WriteVarInt(&buf, pver, maxTxPerBlock+1)
+	numShortIDsOffset := 88
+	exceedMaxShortIDs := make([]byte, numShortIDsOffset)
+	copy(exceedMaxShortIDs, cmpctBlockOneBytes[:numShortIDsOffset])
+	exceedMaxShortIDs = append(exceedMaxShortIDs, buf.Bytes()...)
+
+	// Create bytes for a compact block that claims to have more than the
+	// max allowed prefilled transactions in addition to the short ids.
+	buf.Reset()
+	WriteVarInt(&buf, pver, maxTxPerBlock-1)
+	numPrefilledOffset := 101
+	exceedMaxPrefilled := make([]byte, numPrefilledOffset)
+	copy(exceedMaxPrefilled, cmpctBlockOneBytes[:numPrefilledOffset])
+	exceedMaxPrefilled = append(exceedMaxPrefilled, buf.Bytes()...)
+
+	// Create bytes for a compact block with a prefilled index offset that
+	// exceeds the max allowed index.
+	buf.Reset()
+	WriteVarInt(&buf, pver, maxTxPerBlock)
+	indexOffset := 237
+	exceedMaxIndex := make([]byte, indexOffset)
+	copy(exceedMaxIndex, cmpctBlockOneBytes[:indexOffset])
+	exceedMaxIndex = append(exceedMaxIndex, buf.Bytes()...)
+
+	tests := []struct {
+		buf  []byte // Wire encoding
+		pver uint32 // Protocol version for wire encoding
+		err  error  // Expected error
+	}{
+		{exceedMaxShortIDs, pver, &MessageError{}},
+		{exceedMaxPrefilled, pver, &MessageError{}},
+		{exceedMaxIndex, pver, &MessageError{}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Decode from wire format.
+		var msg MsgCmpctBlock
+		r := bytes.NewReader(test.buf)
+		err := msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, reflect.TypeOf(test.err))
+			continue
+		}
+	}
+}
+
+// cmpctBlockOne is a compact block created from the header of block one of the
+// block chain with two short ids and its coinbase transaction prefilled at the
+// indexes 0 and 3.
+var cmpctBlockOne = MsgCmpctBlock{
+	Header:   blockOne.Header,
+	Nonce:    0x0102030405060708,
+	ShortIDs: []uint64{0x56623a742b65, 0x010203040506},
+	PrefilledTxs: []*PrefilledTx{
+		{Index: 0, Tx: blockOne.Transactions[0]},
+		{Index: 3, Tx: blockOne.Transactions[0]},
+	},
+}
+
+// cmpctBlockOneBytes is the serialized bytes for cmpctBlockOne.
+var cmpctBlockOneBytes = func() []byte {
+	var b []byte
+	b = append(b, blockOneBytes[:80]...)                          // Header
+	b = append(b, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01) // Nonce
+	b = append(b, 0x02)                                           // Num short ids
+	b = append(b, 0x65, 0x2b, 0x74, 0x3a, 0x62, 0x56)             // Short id 1
+	b = append(b, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01)             // Short id 2
+	b = append(b, 0x02)                                           // Num prefilled txns
+	b = append(b, 0x00)                                           // Index 0
+	b = append(b, blockOneBytes[81:]...)                          // Coinbase
+	b = append(b, 0x02)                                           // Index 3 (3-0-1)
+	b = append(b, blockOneBytes[81:]...)                          // Coinbase
+	return b
+}()
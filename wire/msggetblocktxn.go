@@ -0,0 +1,172 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// MsgGetBlockTxn implements the Message interface and represents a bitcoin
+// getblocktxn message.  It is used to request the transactions of a block
+// previously announced via a cmpctblock message that could not be found in the
+// mempool of the requesting peer.  The peer is expected to reply with a
+// blocktxn message.
+//
+// NOTE: The indexes are the absolute indexes of the transactions within the
+// block.  They are differentially encoded when the message is serialized as
+// required by BIP0152.
+//
+// This message was not added until protocol versions starting with
+// SendCmpctVersion.
+type MsgGetBlockTxn struct {
+	BlockHash chainhash.Hash
+	Indexes   []uint32
+}
+
+// AddIndex adds a transaction index to the message.  Indexes must be added in
+// increasing order.
+func (msg *MsgGetBlockTxn) AddIndex(index uint32) error {
+	if len(msg.Indexes)+1 > maxTxPerBlock {
+		str := fmt.Sprintf("too many indexes for message [max %v]",
+			maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.AddIndex", str)
+	}
+	if n := len(msg.Indexes); n > 0 && index <= msg.Indexes[n-1] {
+		str := fmt.Sprintf("index %d is not greater than the previous "+
+			"index %d", index, msg.Indexes[n-1])
+		return messageError("MsgGetBlockTxn.AddIndex", str)
+	}
+
+	msg.Indexes = append(msg.Indexes, index)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < SendCmpctVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	err := readElement(r, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	// Read num indexes and limit to max.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many indexes for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	// Each index is encoded as the difference from the previous index
+	// minus one.
+	msg.Indexes = make([]uint32, 0, count)
+	var index uint64
+	for i := uint64(0); i < count; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if diff >= maxTxPerBlock {
+			str := fmt.Sprintf("index offset %d exceeds max %d",
+				diff, maxTxPerBlock-1)
+			return messageError("MsgGetBlockTxn.BtcDecode", str)
+		}
+		if i > 0 {
+			diff += index + 1
+		}
+		index = diff
+		if index >= maxTxPerBlock {
+			str := fmt.Sprintf("index %d exceeds max %d", index,
+				maxTxPerBlock-1)
+			return messageError("MsgGetBlockTxn.BtcDecode", str)
+		}
+		msg.Indexes = append(msg.Indexes, uint32(index))
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < SendCmpctVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcEncode", str)
+	}
+
+	// Limit to max indexes per message.
+	count := len(msg.Indexes)
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many indexes for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.BtcEncode", str)
+	}
+
+	err := writeElement(w, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(count))
+	if err != nil {
+		return err
+	}
+	for i, index := range msg.Indexes {
+		diff := index
+		if i > 0 {
+			prev := msg.Indexes[i-1]
+			if index <= prev {
+				str := fmt.Sprintf("index %d is not greater "+
+					"than the previous index %d", index,
+					prev)
+				return messageError("MsgGetBlockTxn.BtcEncode",
+					str)
+			}
+			diff = index - prev - 1
+		}
+		err = WriteVarInt(w, pver, uint64(diff))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num indexes (varInt) + max allowed indexes which can
+	// each take up to 3 bytes when differentially encoded.
+	return chainhash.HashSize + MaxVarIntPayload + (maxTxPerBlock * 3)
+}
+
+// NewMsgGetBlockTxn returns a new bitcoin getblocktxn message that conforms to
+// the Message interface.  See MsgGetBlockTxn for details.
+func NewMsgGetBlockTxn(blockHash *chainhash.Hash) *MsgGetBlockTxn {
+	return &MsgGetBlockTxn{
+		BlockHash: *blockHash,
+		Indexes:   make([]uint32, 0),
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxn tests the MsgGetBlockTxn API.
+func TestGetBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	msg := NewMsgGetBlockTxn(&blockOne.Header.PrevBlock)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Block hash + num indexes (varInt) + max allowed indexes.
+	wantPayload := uint32(32 + 9 + (maxTxPerBlock * 3))
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure indexes must be added in increasing order.
+	if err := msg.AddIndex(5); err != nil {
+		t.Fatalf("AddIndex: unexpected error %v", err)
+	}
+	if err := msg.AddIndex(5); err == nil {
+		t.Errorf("AddIndex: succeeded with a duplicate index")
+	}
+	if err := msg.AddIndex(2); err == nil {
+		t.Errorf("AddIndex: succeeded with a decreasing index")
+	}
+
+	// Ensure encoding fails when the indexes are not increasing.
+	msg.Indexes = append(msg.Indexes, 1)
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgGetBlockTxn succeeded with decreasing " +
+			"indexes when it should have failed")
+	}
+
+	// Older protocol versions should fail since the message didn't exist
+	// yet.
+	msg.Indexes = nil
+	err = msg.BtcEncode(&buf, SendCmpctVersion-1, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("encode of MsgGetBlockTxn succeeded with old " +
+			"protocol version when it should have failed")
+	}
+}
+
+// TestGetBlockTxnWire tests the MsgGetBlockTxn wire encode and decode for
+// various numbers of indexes and protocol versions.
+func TestGetBlockTxnWire(t *testing.T) {
+	hash := chainhash.Hash{0x01, 0x02, 0x03}
+	hashBytes := make([]byte, 32)
+	copy(hashBytes, []byte{0x01, 0x02, 0x03})
+
+	noIndexes := NewMsgGetBlockTxn(&hash)
+	noIndexesEncoded := append(append([]byte{}, hashBytes...),
+		0x00, // Num indexes
+	)
+
+	multiIndexes := NewMsgGetBlockTxn(&hash)
+	multiIndexes.AddIndex(0)
+	multiIndexes.AddIndex(1)
+	multiIndexes.AddIndex(5)
+	multiIndexes.AddIndex(300)
+	multiIndexesEncoded := append(append([]byte{}, hashBytes...),
+		0x04,             // Num indexes
+		0x00,             // Index 0
+		0x00,             // Index 1 (1-0-1)
+		0x03,             // Index 5 (5-1-1)
+		0xfd, 0x26, 0x01, // Index 300 (300-5-1)
+	)
+
+	tests := []struct {
+		in   *MsgGetBlockTxn // Message to encode
+		out  *MsgGetBlockTxn // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version with no indexes.
+		{
+			noIndexes, noIndexes, noIndexesEncoded,
+			ProtocolVersion, BaseEncoding,
+		},
+
+		// Latest protocol version with multiple indexes.
+		{
+			multiIndexes, multiIndexes, multiIndexesEncoded,
+			ProtocolVersion, BaseEncoding,
+		},
+
+		// Protocol version SendCmpctVersion with multiple indexes.
+		{
+			multiIndexes, multiIndexes, multiIndexesEncoded,
+			SendCmpctVersion, WitnessEncoding,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgGetBlockTxn
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(&msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGetBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgGetBlockTxn to confirm error paths work correctly.
+func TestGetBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	wireErr := &MessageError{}
+
+	hash := chainhash.Hash{0x01}
+	baseGetBlockTxn := NewMsgGetBlockTxn(&hash)
+	baseGetBlockTxn.AddIndex(0)
+	baseGetBlockTxn.AddIndex(2)
+	baseGetBlockTxnEncoded := make([]byte, 32, 35)
+	baseGetBlockTxnEncoded[0] = 0x01
+	baseGetBlockTxnEncoded = append(baseGetBlockTxnEncoded,
+		0x02, // Num indexes
+		0x00, // Index 0
+		0x01, // Index 2 (2-0-1)
+	)
+
+	// Index offset which exceeds the max allowed index.
+	var buf bytes.Buffer
+	WriteVarInt(&buf, pver, maxTxPerBlock)
+	exceedMaxIndex := append(append([]byte{},
+		baseGetBlockTxnEncoded[:32]...), 0x01)
+	exceedMaxIndex = append(exceedMaxIndex, buf.Bytes()...)
+
+	// Number of indexes which exceeds the max allowed.
+	buf.Reset()
+	WriteVarInt(&buf, pver, maxTxPerBlock+1)
+	exceedMaxIndexes := append(append([]byte{},
+		baseGetBlockTxnEncoded[:32]...), buf.Bytes()...)
+
+	tests := []struct {
+		in       *MsgGetBlockTxn // Value to encode
+		buf      []byte          // Wire encoding
+		pver     uint32          // Protocol version for wire encoding
+		max      int             // Max size of fixed buffer to induce errors
+		writeErr error           // Expected write error
+		readErr  error           // Expected read error
+	}{
+		// Force error in block hash.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in num indexes.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in first index.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error in second index.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 34, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, SendCmpctVersion - 1,
+			35, wireErr, wireErr},
+		// Force error with index offset which exceeds max.
+		{baseGetBlockTxn, exceedMaxIndex, pver, len(exceedMaxIndex) + 1,
+			nil, wireErr},
+		// Force error with too many indexes.
+		{baseGetBlockTxn, exceedMaxIndexes, pver, len(exceedMaxIndexes) + 1,
+			nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// Decode from wire format.
+		var msg MsgGetBlockTxn
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
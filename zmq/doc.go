@@ -0,0 +1,32 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package zmq implements a ZeroMQ publisher for block and transaction events that
+is compatible with the ZMQ notification interface of Bitcoin Core.
+
+The package contains a pure Go implementation of a ZeroMQ PUB socket speaking
+version 3.0 of the ZeroMQ Message Transport Protocol (ZMTP) over tcp with the
+NULL security mechanism, so no ZeroMQ library is required.  Any ZeroMQ SUB
+socket is able to connect and subscribe to the published topics.
+
+The following topics are supported:
+
+	hashblock  the hash of each block connected to the main chain
+	hashtx     the hash of each transaction accepted to the mempool or
+	           included in a connected or disconnected block
+	rawblock   the serialized block for each block connected to the main chain
+	rawtx      the serialized transaction for each hashtx event
+	sequence   the hash of connected (C) and disconnected (D) blocks as well as
+	           transactions accepted to (A) and removed from (R) the mempool
+	           along with the mempool sequence number, where transactions
+	           removed because they were included in a block are implied by
+	           the connected block
+
+Every message is a multipart message consisting of the topic, the body and a
+4-byte little-endian sequence number that is incremented for each message
+published on the topic.  Hashes are published in the same byte order they are
+displayed in, which is the reverse of their internal byte order.
+*/
+package zmq
@@ -0,0 +1,336 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Topics published by the notifier.  They match the topics used by Bitcoin
+// Core so existing subscribers work unchanged.
+const (
+	TopicHashBlock = "hashblock"
+	TopicHashTx    = "hashtx"
+	TopicRawBlock  = "rawblock"
+	TopicRawTx     = "rawtx"
+	TopicSequence  = "sequence"
+)
+
+// Labels of the messages published on the sequence topic.
+const (
+	sequenceBlockConnected    = 'C'
+	sequenceBlockDisconnected = 'D'
+	sequenceTxAccepted        = 'A'
+	sequenceTxRemoved         = 'R'
+)
+
+// DefaultHighWaterMark is the default number of outbound messages that are
+// queued for each subscriber before further messages are dropped.
+const DefaultHighWaterMark = 1000
+
+// Config is a descriptor containing the notifier configuration.  Each address
+// enables publishing of the associated topic on the provided ZeroMQ endpoint
+// such as tcp://127.0.0.1:28332.  Multiple topics may share the same address.
+type Config struct {
+	// HashBlock is the address to publish block hashes on.
+	HashBlock string
+
+	// HashTx is the address to publish transaction hashes on.
+	HashTx string
+
+	// RawBlock is the address to publish serialized blocks on.
+	RawBlock string
+
+	// RawTx is the address to publish serialized transactions on.
+	RawTx string
+
+	// Sequence is the address to publish block connection and
+	// disconnection as well as mempool acceptance and removal events on.
+	Sequence string
+
+	// HighWaterMark is the number of outbound messages that are queued
+	// for each subscriber before further messages are dropped.
+	HighWaterMark int
+}
+
+// Notification describes an enabled notification as reported by the
+// getzmqnotifications RPC.
+type Notification struct {
+	// Type is the type of the notification such as pubhashblock.
+	Type string
+
+	// Address is the address the notification is published on.
+	Address string
+
+	// HighWaterMark is the outbound message high water mark.
+	HighWaterMark int
+}
+
+// Notifier publishes block and transaction events to ZeroMQ subscribers using
+// the same framing as Bitcoin Core.  Every message consists of the topic, the
+// body and a 4-byte little-endian sequence number that is incremented for each
+// message published on the topic, which allows subscribers to detect missed
+// messages.
+type Notifier struct {
+	cfg        Config
+	publishers map[string]*publisher
+	topics     map[string]*publisher
+
+	// mtx protects the sequence numbers and ensures messages are queued
+	// in the same order their sequence numbers were assigned.
+	mtx        sync.Mutex
+	sequences  map[string]uint32
+	mempoolSeq uint64
+}
+
+// New returns a new notifier that publishes the topics enabled by the passed
+// configuration.  The listeners are created immediately, but subscribers are
+// not accepted until Start is called.
+func New(cfg *Config) (*Notifier, error) {
+	n := Notifier{
+		cfg:        *cfg,
+		publishers: make(map[string]*publisher),
+		topics:     make(map[string]*publisher),
+		sequences:  make(map[string]uint32),
+	}
+	if n.cfg.HighWaterMark <= 0 {
+		n.cfg.HighWaterMark = DefaultHighWaterMark
+	}
+
+	for topic, address := range n.topicAddresses() {
+		if address == "" {
+			continue
+		}
+		pub, ok := n.publishers[address]
+		if !ok {
+			var err error
+			pub, err = newPublisher(address, n.cfg.HighWaterMark)
+			if err != nil {
+				for _, pub := range n.publishers {
+					pub.listener.Close()
+				}
+				return nil, err
+			}
+			n.publishers[address] = pub
+		}
+		n.topics[topic] = pub
+	}
+
+	return &n, nil
+}
+
+// topicAddresses returns the configured address of each topic.
+func (n *Notifier) topicAddresses() map[string]string {
+	return map[string]string{
+		TopicHashBlock: n.cfg.HashBlock,
+		TopicHashTx:    n.cfg.HashTx,
+		TopicRawBlock:  n.cfg.RawBlock,
+		TopicRawTx:     n.cfg.RawTx,
+		TopicSequence:  n.cfg.Sequence,
+	}
+}
+
+// Start begins accepting subscribers on all configured addresses.
+func (n *Notifier) Start() {
+	for _, pub := range n.publishers {
+		log.Infof("Publishing ZMQ notifications on %s", pub.address)
+		pub.start()
+	}
+}
+
+// Stop disconnects all subscribers and stops listening for new ones.
+func (n *Notifier) Stop() {
+	for _, pub := range n.publishers {
+		pub.stop()
+	}
+}
+
+// Notifications returns the enabled notifications sorted by type.
+func (n *Notifier) Notifications() []Notification {
+	notifications := make([]Notification, 0, len(n.topics))
+	for topic, pub := range n.topics {
+		notifications = append(notifications, Notification{
+			Type:          "pub" + topic,
+			Address:       pub.address,
+			HighWaterMark: pub.highWaterMark,
+		})
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].Type < notifications[j].Type
+	})
+	return notifications
+}
+
+// publish sends the provided body on the topic along with the next sequence
+// number of the topic.  Nothing is done when the topic is not enabled.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publish(topic string, body []byte) {
+	pub, ok := n.topics[topic]
+	if !ok {
+		return
+	}
+
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], n.sequences[topic])
+	n.sequences[topic]++
+
+	pub.publish([][]byte{[]byte(topic), body, seq[:]})
+}
+
+// reversedHash returns the passed hash with its bytes reversed which is the
+// byte order hashes are published in.
+func reversedHash(hash *chainhash.Hash) []byte {
+	reversed := make([]byte, chainhash.HashSize)
+	for i, b := range hash {
+		reversed[chainhash.HashSize-1-i] = b
+	}
+	return reversed
+}
+
+// publishTx publishes the hash and the serialized transaction on the hashtx
+// and rawtx topics.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publishTx(tx *btcutil.Tx) {
+	if _, ok := n.topics[TopicHashTx]; ok {
+		n.publish(TopicHashTx, reversedHash(tx.Hash()))
+	}
+	if _, ok := n.topics[TopicRawTx]; ok {
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			log.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+			return
+		}
+		n.publish(TopicRawTx, buf.Bytes())
+	}
+}
+
+// publishSequence publishes an event with the provided label for the hash on
+// the sequence topic.  The mempool sequence number is appended to mempool
+// events.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publishSequence(hash *chainhash.Hash, label byte,
+	mempoolSeq *uint64) {
+
+	if _, ok := n.topics[TopicSequence]; !ok {
+		return
+	}
+
+	body := make([]byte, 0, chainhash.HashSize+1+8)
+	body = append(body, reversedHash(hash)...)
+	body = append(body, label)
+	if mempoolSeq != nil {
+		var seq [8]byte
+		binary.LittleEndian.PutUint64(seq[:], *mempoolSeq)
+		body = append(body, seq[:]...)
+	}
+	n.publish(TopicSequence, body)
+}
+
+// BlockConnected publishes the block on the hashblock and rawblock topics, all
+// of its transactions on the hashtx and rawtx topics and a block connected
+// event on the sequence topic.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) BlockConnected(block *btcutil.Block) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if _, ok := n.topics[TopicHashBlock]; ok {
+		n.publish(TopicHashBlock, reversedHash(block.Hash()))
+	}
+	if _, ok := n.topics[TopicRawBlock]; ok {
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Unable to serialize block %v: %v",
+				block.Hash(), err)
+		} else {
+			n.publish(TopicRawBlock, blockBytes)
+		}
+	}
+	for _, tx := range block.Transactions() {
+		n.publishTx(tx)
+	}
+	n.publishSequence(block.Hash(), sequenceBlockConnected, nil)
+}
+
+// BlockDisconnected publishes all transactions of the block on the hashtx and
+// rawtx topics and a block disconnected event on the sequence topic.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) BlockDisconnected(block *btcutil.Block) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		n.publishTx(tx)
+	}
+	n.publishSequence(block.Hash(), sequenceBlockDisconnected, nil)
+}
+
+// TransactionAccepted publishes a transaction that was accepted to the mempool
+// on the hashtx and rawtx topics along with a mempool acceptance event on the
+// sequence topic.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) TransactionAccepted(tx *btcutil.Tx) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.publishTx(tx)
+	n.mempoolSeq++
+	mempoolSeq := n.mempoolSeq
+	n.publishSequence(tx.Hash(), sequenceTxAccepted, &mempoolSeq)
+}
+
+// TransactionRemoved publishes a mempool removal event on the sequence topic
+// for a transaction that was removed from the mempool for any reason other
+// than being included in a block, such as being replaced, conflicting with a
+// block, expiring or being evicted.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) TransactionRemoved(tx *btcutil.Tx) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.mempoolSeq++
+	mempoolSeq := n.mempoolSeq
+	n.publishSequence(tx.Hash(), sequenceTxRemoved, &mempoolSeq)
+}
+
+// HandleBlockchainNotification publishes block connected and disconnected
+// notifications from the blockchain.  It is intended to be registered with
+// blockchain.BlockChain.Subscribe.
+func (n *Notifier) HandleBlockchainNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		block, ok := notification.Data.(*btcutil.Block)
+		if !ok {
+			log.Warnf("Chain connected notification is not a block.")
+			return
+		}
+		n.BlockConnected(block)
+
+	case blockchain.NTBlockDisconnected:
+		block, ok := notification.Data.(*btcutil.Block)
+		if !ok {
+			log.Warnf("Chain disconnected notification is not a " +
+				"block.")
+			return
+		}
+		n.BlockDisconnected(block)
+	}
+}
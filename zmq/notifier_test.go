@@ -0,0 +1,261 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// testSubscriber is a minimal ZMTP SUB socket used to receive messages from a
+// notifier in the tests.
+type testSubscriber struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newTestSubscriber connects to the passed publisher, performs the handshake
+// and subscribes to the provided topics.  It waits until the publisher
+// processed the subscriptions.
+func newTestSubscriber(t *testing.T, pub *publisher,
+	topics ...string) *testSubscriber {
+
+	conn, err := net.Dial("tcp", pub.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect to publisher: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(time.Second * 10))
+	s := &testSubscriber{conn: conn, r: bufio.NewReader(conn)}
+
+	if err := writeGreeting(conn, false); err != nil {
+		t.Fatalf("unable to write greeting: %v", err)
+	}
+	if err := readGreeting(s.r); err != nil {
+		t.Fatalf("unable to read greeting: %v", err)
+	}
+	if err := writeReady(conn, "SUB"); err != nil {
+		t.Fatalf("unable to write READY: %v", err)
+	}
+	socketType, err := readReady(s.r)
+	if err != nil {
+		t.Fatalf("unable to read READY: %v", err)
+	}
+	if socketType != "PUB" {
+		t.Fatalf("unexpected socket type %q", socketType)
+	}
+	for _, topic := range topics {
+		err := writeFrame(conn, 0, append([]byte{1}, topic...))
+		if err != nil {
+			t.Fatalf("unable to subscribe: %v", err)
+		}
+	}
+
+	// Wait for the publisher to process all subscriptions.
+	for i := 0; ; i++ {
+		subscribed := 0
+		pub.mtx.Lock()
+		for sub := range pub.subscribers {
+			for _, topic := range topics {
+				if sub.matches([]byte(topic)) {
+					subscribed++
+				}
+			}
+		}
+		pub.mtx.Unlock()
+		if subscribed == len(topics) {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("timeout waiting for subscriptions")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return s
+}
+
+// readMessage reads a multipart message from the publisher.
+func (s *testSubscriber) readMessage(t *testing.T) [][]byte {
+	var frames [][]byte
+	for {
+		flags, body, err := readFrame(s.r)
+		if err != nil {
+			t.Fatalf("unable to read frame: %v", err)
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames
+		}
+	}
+}
+
+// seqBytes returns the serialized sequence number of a message.
+func seqBytes(seq uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], seq)
+	return b[:]
+}
+
+// TestNotifier ensures the notifier publishes the expected messages with
+// Bitcoin Core compatible framing and sequence numbers.
+func TestNotifier(t *testing.T) {
+	const address = "tcp://127.0.0.1:0"
+	n, err := New(&Config{
+		HashBlock: address,
+		RawBlock:  address,
+		HashTx:    address,
+		Sequence:  address,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	n.Start()
+	defer n.Stop()
+
+	// All topics share the same address and therefore publisher.
+	if len(n.publishers) != 1 {
+		t.Fatalf("unexpected number of publishers %d", len(n.publishers))
+	}
+	wantNotifications := []Notification{
+		{"pubhashblock", address, DefaultHighWaterMark},
+		{"pubhashtx", address, DefaultHighWaterMark},
+		{"pubrawblock", address, DefaultHighWaterMark},
+		{"pubsequence", address, DefaultHighWaterMark},
+	}
+	if got := n.Notifications(); !reflect.DeepEqual(got, wantNotifications) {
+		t.Fatalf("Notifications: got %v, want %v", got,
+			wantNotifications)
+	}
+
+	pub := n.topics[TopicHashBlock]
+	sub := newTestSubscriber(t, pub, TopicHashBlock, TopicRawBlock,
+		TopicSequence)
+	defer sub.conn.Close()
+
+	block := btcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		t.Fatalf("unable to serialize block: %v", err)
+	}
+
+	// Hashes are published in the byte order they are displayed in.
+	blockHash := reversedHash(block.Hash())
+	wantHash, _ := hex.DecodeString("000000000019d6689c085ae165831e93" +
+		"4ff763ae46a2a6c172b3f1b60a8ce26f")
+	if !bytes.Equal(blockHash, wantHash) {
+		t.Fatalf("unexpected block hash %x, want %x", blockHash,
+			wantHash)
+	}
+
+	tx := block.Transactions()[0]
+	txHash := reversedHash(tx.Hash())
+
+	// Connect and disconnect the block, accept its transaction to the
+	// mempool twice and remove it again.  The hashtx messages are not received since the subscriber
+	// didn't subscribe to them, but they still use sequence numbers of
+	// their own topic.
+	n.BlockConnected(block)
+	n.BlockDisconnected(block)
+	n.TransactionAccepted(tx)
+	n.TransactionAccepted(tx)
+	n.TransactionRemoved(tx)
+
+	mempoolSeq := func(seq uint64) []byte {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], seq)
+		return b[:]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	wantMessages := [][][]byte{
+		{[]byte(TopicHashBlock), blockHash, seqBytes(0)},
+		{[]byte(TopicRawBlock), blockBytes, seqBytes(0)},
+		{[]byte(TopicSequence), concat(blockHash, []byte{'C'}),
+			seqBytes(0)},
+		{[]byte(TopicSequence), concat(blockHash, []byte{'D'}),
+			seqBytes(1)},
+		{[]byte(TopicSequence), concat(txHash, []byte{'A'},
+			mempoolSeq(1)), seqBytes(2)},
+		{[]byte(TopicSequence), concat(txHash, []byte{'A'},
+			mempoolSeq(2)), seqBytes(3)},
+		{[]byte(TopicSequence), concat(txHash, []byte{'R'},
+			mempoolSeq(3)), seqBytes(4)},
+	}
+	for i, want := range wantMessages {
+		got := sub.readMessage(t)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("message #%d: got %x, want %x", i, got, want)
+		}
+	}
+
+	// The hashtx topic was published once for the connected block, once
+	// for the disconnected block and twice for the mempool, but not for
+	// the removal from the mempool.
+	if seq := n.sequences[TopicHashTx]; seq != 4 {
+		t.Fatalf("unexpected hashtx sequence number %d", seq)
+	}
+}
+
+// TestNotifierHighWaterMark ensures messages beyond the high water mark are
+// dropped instead of blocking the notifier.
+func TestNotifierHighWaterMark(t *testing.T) {
+	n, err := New(&Config{
+		HashTx:        "tcp://127.0.0.1:0",
+		HighWaterMark: 2,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	n.Start()
+	defer n.Stop()
+
+	pub := n.topics[TopicHashTx]
+	sub := newTestSubscriber(t, pub, TopicHashTx)
+	defer sub.conn.Close()
+
+	// Publish far more messages than the high water mark without reading
+	// any of them and make sure the notifier doesn't block.
+	tx := btcutil.NewTx(chaincfg.MainNetParams.GenesisBlock.Transactions[0])
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100000; i++ {
+			n.TransactionAccepted(tx)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatalf("notifier blocked on slow subscriber")
+	}
+
+	// The first message must still be delivered.
+	got := sub.readMessage(t)
+	want := [][]byte{[]byte(TopicHashTx), reversedHash(tx.Hash()),
+		seqBytes(0)}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected message: got %x, want %x", got, want)
+	}
+}
+
+// TestNotifierInvalidAddress ensures invalid addresses are rejected.
+func TestNotifierInvalidAddress(t *testing.T) {
+	_, err := New(&Config{
+		HashBlock: "tcp://127.0.0.1:0",
+		RawTx:     "ipc:///tmp/btcd.sock",
+	})
+	if err == nil {
+		t.Fatalf("New did not return an error for invalid address")
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// handshakeTimeout is the maximum amount of time a subscriber has to
+	// complete the ZMTP handshake after connecting.
+	handshakeTimeout = time.Second * 10
+
+	// writeTimeout is the maximum amount of time writing a message to a
+	// subscriber may take before the subscriber is disconnected.
+	writeTimeout = time.Minute
+)
+
+// parseAddress converts a ZeroMQ endpoint such as tcp://127.0.0.1:28332 to the
+// address to listen on.  Only the tcp transport is supported and a host of *
+// listens on all interfaces as it does for ZeroMQ.
+func parseAddress(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid ZMQ address %q: %v", address, err)
+	}
+	if u.Scheme != "tcp" {
+		return "", fmt.Errorf("invalid ZMQ address %q: only the tcp "+
+			"transport is supported", address)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return "", fmt.Errorf("invalid ZMQ address %q: %v", address, err)
+	}
+	if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// subscriber houses a connected ZMTP SUB socket along with the topic prefixes
+// it subscribed to and the queue of messages waiting to be written to it.
+type subscriber struct {
+	conn      net.Conn
+	sendQueue chan [][]byte
+	quit      chan struct{}
+
+	mtx           sync.Mutex
+	subscriptions map[string]int
+}
+
+// subscribe adds the provided topic prefix to the subscriptions.  ZeroMQ counts
+// subscriptions, so the same prefix must be unsubscribed as many times as it
+// was subscribed.
+func (s *subscriber) subscribe(prefix []byte) {
+	s.mtx.Lock()
+	s.subscriptions[string(prefix)]++
+	s.mtx.Unlock()
+}
+
+// unsubscribe removes one subscription to the provided topic prefix.
+func (s *subscriber) unsubscribe(prefix []byte) {
+	s.mtx.Lock()
+	if n := s.subscriptions[string(prefix)]; n > 1 {
+		s.subscriptions[string(prefix)] = n - 1
+	} else {
+		delete(s.subscriptions, string(prefix))
+	}
+	s.mtx.Unlock()
+}
+
+// matches returns whether or not the subscriber subscribed to a prefix of the
+// provided topic.
+func (s *subscriber) matches(topic []byte) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for prefix := range s.subscriptions {
+		if bytes.HasPrefix(topic, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// publisher implements a ZeroMQ PUB socket that listens for subscribers on a
+// single tcp address.  Messages are queued for each subscriber up to the high
+// water mark and dropped beyond that, matching the behavior of ZeroMQ.
+type publisher struct {
+	address       string
+	highWaterMark int
+	listener      net.Listener
+
+	mtx         sync.Mutex
+	subscribers map[*subscriber]struct{}
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// newPublisher returns a new publisher listening on the provided ZeroMQ
+// endpoint.  Start must be called to begin accepting subscribers.
+func newPublisher(address string, highWaterMark int) (*publisher, error) {
+	listenAddr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &publisher{
+		address:       address,
+		highWaterMark: highWaterMark,
+		listener:      listener,
+		subscribers:   make(map[*subscriber]struct{}),
+		quit:          make(chan struct{}),
+	}, nil
+}
+
+// start begins accepting subscribers.
+func (p *publisher) start() {
+	p.wg.Add(1)
+	go p.acceptHandler()
+}
+
+// stop closes the listener, disconnects all subscribers and waits for all
+// goroutines to finish.
+func (p *publisher) stop() {
+	close(p.quit)
+	p.listener.Close()
+
+	p.mtx.Lock()
+	for sub := range p.subscribers {
+		sub.conn.Close()
+	}
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+}
+
+// publish queues the multipart message made up of the provided frames to all
+// subscribers that subscribed to a prefix of the first frame.  The message is
+// dropped for subscribers whose queue is full.
+func (p *publisher) publish(frames [][]byte) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for sub := range p.subscribers {
+		if !sub.matches(frames[0]) {
+			continue
+		}
+		select {
+		case sub.sendQueue <- frames:
+		default:
+			log.Debugf("Dropping %s message for subscriber %s on "+
+				"%s -- high water mark reached", frames[0],
+				sub.conn.RemoteAddr(), p.address)
+		}
+	}
+}
+
+// acceptHandler accepts subscribers until the publisher is stopped.  It must be
+// run as a goroutine.
+func (p *publisher) acceptHandler() {
+	defer p.wg.Done()
+
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			select {
+			case <-p.quit:
+				return
+			default:
+			}
+			log.Errorf("Unable to accept ZMQ subscriber on %s: %v",
+				p.address, err)
+			continue
+		}
+
+		p.wg.Add(1)
+		go p.handleSubscriber(conn)
+	}
+}
+
+// handleSubscriber performs the ZMTP handshake with a newly connected
+// subscriber and then processes its subscriptions until it disconnects.  It
+// must be run as a goroutine.
+func (p *publisher) handleSubscriber(conn net.Conn) {
+	defer p.wg.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := p.handshake(conn, r); err != nil {
+		log.Debugf("ZMQ handshake with %s on %s failed: %v",
+			conn.RemoteAddr(), p.address, err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	sub := &subscriber{
+		conn:          conn,
+		sendQueue:     make(chan [][]byte, p.highWaterMark),
+		quit:          make(chan struct{}),
+		subscriptions: make(map[string]int),
+	}
+	p.mtx.Lock()
+	select {
+	case <-p.quit:
+		p.mtx.Unlock()
+		return
+	default:
+	}
+	p.subscribers[sub] = struct{}{}
+	p.mtx.Unlock()
+
+	log.Debugf("New ZMQ subscriber %s on %s", conn.RemoteAddr(), p.address)
+
+	p.wg.Add(1)
+	go p.writeHandler(sub)
+
+	p.readHandler(sub, r)
+
+	p.mtx.Lock()
+	delete(p.subscribers, sub)
+	p.mtx.Unlock()
+	close(sub.quit)
+
+	log.Debugf("ZMQ subscriber %s on %s disconnected", conn.RemoteAddr(),
+		p.address)
+}
+
+// handshake exchanges greetings and READY commands with a subscriber and
+// ensures the remote socket is able to subscribe to a PUB socket.
+func (p *publisher) handshake(conn net.Conn, r *bufio.Reader) error {
+	if err := writeGreeting(conn, true); err != nil {
+		return err
+	}
+	if err := readGreeting(r); err != nil {
+		return err
+	}
+	if err := writeReady(conn, "PUB"); err != nil {
+		return err
+	}
+	socketType, err := readReady(r)
+	if err != nil {
+		return err
+	}
+	if socketType != "SUB" && socketType != "XSUB" {
+		return fmt.Errorf("incompatible socket type %s", socketType)
+	}
+	return nil
+}
+
+// readHandler processes subscriptions sent by the subscriber until it
+// disconnects.  ZMTP 3.0 subscribers send subscriptions as messages that start
+// with 1 to subscribe and 0 to unsubscribe, while ZMTP 3.1 subscribers may use
+// the SUBSCRIBE and CANCEL commands instead.
+func (p *publisher) readHandler(sub *subscriber, r *bufio.Reader) {
+	for {
+		flags, body, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		if flags&flagCommand != 0 {
+			name, data, err := decodeCommand(body)
+			if err != nil {
+				return
+			}
+			switch name {
+			case cmdSubscribe:
+				sub.subscribe(data)
+			case cmdCancel:
+				sub.unsubscribe(data)
+			}
+			continue
+		}
+
+		// Ignore anything other than single frame subscription
+		// messages.
+		if flags&flagMore != 0 || len(body) == 0 {
+			continue
+		}
+		switch body[0] {
+		case 1:
+			sub.subscribe(body[1:])
+		case 0:
+			sub.unsubscribe(body[1:])
+		}
+	}
+}
+
+// writeHandler writes the messages queued for the subscriber until it
+// disconnects.  It must be run as a goroutine.
+func (p *publisher) writeHandler(sub *subscriber) {
+	defer p.wg.Done()
+
+	w := bufio.NewWriter(sub.conn)
+	for {
+		select {
+		case frames := <-sub.sendQueue:
+			sub.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err := writeMessage(w, frames)
+			if err == nil {
+				err = w.Flush()
+			}
+			if err != nil {
+				log.Debugf("Unable to write to ZMQ subscriber "+
+					"%s on %s: %v", sub.conn.RemoteAddr(),
+					p.address, err)
+				sub.conn.Close()
+				return
+			}
+
+		case <-sub.quit:
+			return
+		}
+	}
+}
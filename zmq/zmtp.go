@@ -0,0 +1,243 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// zmtpVersionMajor and zmtpVersionMinor are the version of the ZeroMQ
+	// message transport protocol (ZMTP) announced in the greeting.  Version
+	// 3.0 is announced so peers that support newer versions fall back to
+	// sending subscriptions as messages.
+	zmtpVersionMajor = 3
+	zmtpVersionMinor = 0
+
+	// greetingLen is the length of the greeting each side of a ZMTP
+	// connection sends before the handshake.
+	greetingLen = 64
+
+	// mechanismLen is the length of the security mechanism name field in
+	// the greeting.
+	mechanismLen = 20
+
+	// flagMore, flagLong and flagCommand are the bits of the flags byte
+	// that precedes every frame.  flagMore indicates more frames of the
+	// same message follow, flagLong indicates the frame size is encoded
+	// as 8 bytes instead of one and flagCommand indicates a command frame.
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	// maxIncomingFrameSize is the maximum size of a frame read from a
+	// subscriber.  Subscribers only send commands and subscriptions, so
+	// anything larger is treated as a protocol violation.
+	maxIncomingFrameSize = 64 * 1024
+)
+
+// nullMechanism is the name of the only supported security mechanism.
+const nullMechanism = "NULL"
+
+// Command names used during and after the handshake.
+const (
+	cmdReady     = "READY"
+	cmdSubscribe = "SUBSCRIBE"
+	cmdCancel    = "CANCEL"
+)
+
+// propSocketType is the name of the metadata property that holds the socket
+// type of the sender of a READY command.
+const propSocketType = "Socket-Type"
+
+var (
+	// errBadSignature is returned when the greeting of the remote peer
+	// doesn't start with the ZMTP signature.
+	errBadSignature = errors.New("invalid ZMTP greeting signature")
+
+	// errFrameTooLarge is returned when the remote peer sends a frame
+	// larger than maxIncomingFrameSize.
+	errFrameTooLarge = errors.New("ZMTP frame too large")
+)
+
+// writeGreeting writes the ZMTP 3.0 greeting for the NULL security mechanism
+// to w.
+func writeGreeting(w io.Writer, asServer bool) error {
+	var greeting [greetingLen]byte
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = zmtpVersionMajor
+	greeting[11] = zmtpVersionMinor
+	copy(greeting[12:12+mechanismLen], nullMechanism)
+	if asServer {
+		greeting[32] = 1
+	}
+	_, err := w.Write(greeting[:])
+	return err
+}
+
+// readGreeting reads the greeting of the remote peer from r and ensures it
+// speaks ZMTP version 3 or later using the NULL security mechanism.
+func readGreeting(r io.Reader) error {
+	var greeting [greetingLen]byte
+	if _, err := io.ReadFull(r, greeting[:]); err != nil {
+		return err
+	}
+	if greeting[0] != 0xff || greeting[9]&0x01 != 0x01 {
+		return errBadSignature
+	}
+	if greeting[10] < zmtpVersionMajor {
+		return fmt.Errorf("unsupported ZMTP version %d.%d", greeting[10],
+			greeting[11])
+	}
+	mechanism := string(bytes.TrimRight(greeting[12:12+mechanismLen],
+		"\x00"))
+	if mechanism != nullMechanism {
+		return fmt.Errorf("unsupported ZMTP security mechanism %q",
+			mechanism)
+	}
+	return nil
+}
+
+// writeFrame writes a single frame with the provided flags and body to w.  The
+// long flag is set automatically as needed.
+func writeFrame(w io.Writer, flags byte, body []byte) error {
+	var hdr [9]byte
+	var hdrLen int
+	if len(body) > 255 {
+		hdr[0] = flags | flagLong
+		binary.BigEndian.PutUint64(hdr[1:], uint64(len(body)))
+		hdrLen = 9
+	} else {
+		hdr[0] = flags
+		hdr[1] = byte(len(body))
+		hdrLen = 2
+	}
+	if _, err := w.Write(hdr[:hdrLen]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// writeMessage writes a multipart message consisting of the passed frames to w.
+func writeMessage(w io.Writer, frames [][]byte) error {
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := writeFrame(w, flags, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFrame reads a single frame from r and returns its flags and body.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var hdr [9]byte
+	if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+		return 0, nil, err
+	}
+	flags := hdr[0]
+	size := uint64(hdr[1])
+	if flags&flagLong != 0 {
+		if _, err := io.ReadFull(r, hdr[2:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(hdr[1:])
+	}
+	if size > maxIncomingFrameSize {
+		return 0, nil, errFrameTooLarge
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// encodeCommand returns the body of a command frame with the provided name and
+// data.
+func encodeCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	return append(body, data...)
+}
+
+// decodeCommand splits the body of a command frame into its name and data.
+func decodeCommand(body []byte) (string, []byte, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return "", nil, errors.New("malformed ZMTP command")
+	}
+	nameLen := int(body[0])
+	return string(body[1 : 1+nameLen]), body[1+nameLen:], nil
+}
+
+// writeReady writes a READY command announcing the provided socket type to w.
+func writeReady(w io.Writer, socketType string) error {
+	var props bytes.Buffer
+	props.WriteByte(byte(len(propSocketType)))
+	props.WriteString(propSocketType)
+	var valueLen [4]byte
+	binary.BigEndian.PutUint32(valueLen[:], uint32(len(socketType)))
+	props.Write(valueLen[:])
+	props.WriteString(socketType)
+
+	return writeFrame(w, flagCommand, encodeCommand(cmdReady,
+		props.Bytes()))
+}
+
+// readReady reads the READY command of the remote peer from r and returns the
+// socket type it announced.
+func readReady(r io.Reader) (string, error) {
+	flags, body, err := readFrame(r)
+	if err != nil {
+		return "", err
+	}
+	if flags&flagCommand == 0 {
+		return "", errors.New("expected ZMTP READY command")
+	}
+	name, props, err := decodeCommand(body)
+	if err != nil {
+		return "", err
+	}
+	if name != cmdReady {
+		return "", fmt.Errorf("expected ZMTP READY command, got %q",
+			name)
+	}
+
+	// Parse the metadata properties looking for the socket type.
+	var socketType string
+	for len(props) > 0 {
+		nameLen := int(props[0])
+		if len(props) < 1+nameLen+4 {
+			return "", errors.New("malformed ZMTP READY metadata")
+		}
+		propName := string(props[1 : 1+nameLen])
+		props = props[1+nameLen:]
+		valueLen := binary.BigEndian.Uint32(props[:4])
+		props = props[4:]
+		if uint64(len(props)) < uint64(valueLen) {
+			return "", errors.New("malformed ZMTP READY metadata")
+		}
+		if strings.EqualFold(propName, propSocketType) {
+			socketType = string(props[:valueLen])
+		}
+		props = props[valueLen:]
+	}
+	if socketType == "" {
+		return "", errors.New("ZMTP READY command without socket type")
+	}
+	return socketType, nil
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2023 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestGreeting ensures the greeting is encoded as specified by ZMTP 3.0 and
+// that invalid greetings are rejected.
+func TestGreeting(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeGreeting(&buf, true); err != nil {
+		t.Fatalf("writeGreeting: unexpected error: %v", err)
+	}
+	want := "ff00000000000000007f03004e554c4c00000000000000000000000000000000" +
+		"0100000000000000000000000000000000000000000000000000000000000000"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("writeGreeting: unexpected greeting\ngot:  %s\nwant: %s",
+			got, want)
+	}
+	if err := readGreeting(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("readGreeting: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		value  byte
+	}{
+		{"bad signature start", 0, 0x00},
+		{"bad signature end", 9, 0x00},
+		{"ZMTP 2", 10, 0x01},
+		{"CURVE mechanism", 12, 'C'},
+	}
+	for _, test := range tests {
+		greeting := append([]byte(nil), buf.Bytes()...)
+		greeting[test.offset] = test.value
+		if err := readGreeting(bytes.NewReader(greeting)); err == nil {
+			t.Errorf("%s: readGreeting did not return an error",
+				test.name)
+		}
+	}
+
+	// A truncated greeting must also be rejected.
+	if err := readGreeting(bytes.NewReader(buf.Bytes()[:10])); err == nil {
+		t.Errorf("readGreeting did not return an error for truncated " +
+			"greeting")
+	}
+}
+
+// TestFrames ensures frames are encoded using the short and long form as
+// needed and decode back to the same flags and body.
+func TestFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		flags  byte
+		body   []byte
+		header string
+	}{
+		{"empty", 0, nil, "0000"},
+		{"short more", flagMore, []byte("hashblock"), "0109"},
+		{"short max", 0, bytes.Repeat([]byte{0x01}, 255), "00ff"},
+		{"long", 0, bytes.Repeat([]byte{0x02}, 256),
+			"020000000000000100"},
+		{"command", flagCommand, []byte{0x05}, "0401"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := writeFrame(&buf, test.flags, test.body); err != nil {
+			t.Errorf("%s: writeFrame: unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		header := hex.EncodeToString(buf.Bytes()[:len(test.header)/2])
+		if header != test.header {
+			t.Errorf("%s: unexpected header - got %s, want %s",
+				test.name, header, test.header)
+			continue
+		}
+
+		flags, body, err := readFrame(&buf)
+		if err != nil {
+			t.Errorf("%s: readFrame: unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		if flags&^flagLong != test.flags {
+			t.Errorf("%s: unexpected flags - got %x, want %x",
+				test.name, flags, test.flags)
+		}
+		if !bytes.Equal(body, test.body) {
+			t.Errorf("%s: unexpected body - got %x, want %x",
+				test.name, body, test.body)
+		}
+	}
+
+	// Frames from subscribers that exceed the maximum size must be
+	// rejected before allocating the body.
+	var buf bytes.Buffer
+	buf.Write([]byte{flagLong, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})
+	if _, _, err := readFrame(&buf); err != errFrameTooLarge {
+		t.Errorf("readFrame: unexpected error - got %v, want %v", err,
+			errFrameTooLarge)
+	}
+}
+
+// TestReady ensures READY commands round trip and malformed ones are rejected.
+func TestReady(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReady(&buf, "SUB"); err != nil {
+		t.Fatalf("writeReady: unexpected error: %v", err)
+	}
+	want := "04" + "19" + "055245414459" + "0b536f636b65742d54797065" +
+		"00000003" + "535542"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Fatalf("writeReady: unexpected command\ngot:  %s\nwant: %s",
+			got, want)
+	}
+	socketType, err := readReady(&buf)
+	if err != nil {
+		t.Fatalf("readReady: unexpected error: %v", err)
+	}
+	if socketType != "SUB" {
+		t.Fatalf("readReady: unexpected socket type %q", socketType)
+	}
+
+	tests := []struct {
+		name  string
+		frame string
+	}{
+		{"message frame", "0001" + "00"},
+		{"other command", "0406" + "0550494e4700"},
+		{"truncated name", "0402" + "0552"},
+		{"no socket type", "0406" + "055245414459"},
+		{"truncated property", "040d" + "055245414459" + "0b536f636b6574"},
+		{"truncated value", "0418" + "055245414459" +
+			"0b536f636b65742d54797065" + "00000003" + "5355"},
+	}
+	for _, test := range tests {
+		frame, _ := hex.DecodeString(test.frame)
+		if _, err := readReady(bytes.NewReader(frame)); err == nil {
+			t.Errorf("%s: readReady did not return an error",
+				test.name)
+		}
+	}
+}
+
+// TestParseAddress ensures ZeroMQ endpoints are converted to listen addresses
+// and unsupported endpoints are rejected.
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		valid   bool
+	}{
+		{"tcp://127.0.0.1:28332", "127.0.0.1:28332", true},
+		{"tcp://*:28332", ":28332", true},
+		{"tcp://[::1]:28332", "[::1]:28332", true},
+		{"ipc:///tmp/btcd", "", false},
+		{"tcp://127.0.0.1", "", false},
+		{"127.0.0.1:28332", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := parseAddress(test.address)
+		if test.valid != (err == nil) {
+			t.Errorf("parseAddress(%q): unexpected error %v",
+				test.address, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseAddress(%q): got %q, want %q",
+				test.address, got, test.want)
+		}
+	}
+}